@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/forward"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/server"
 )
 
 // Config holds the settings for the shared DuckDB store and the viewer it
@@ -29,6 +32,38 @@ type Config struct {
 	// distinguishes "disabled"/"" from the rest -- the ingest-suppressing
 	// "self" mode is exporter behavior.
 	Telemetry string `mapstructure:"telemetry"`
+
+	// AllowedOrigins lists the origins, besides the viewer's own, whose pages
+	// may call it -- each a bare scheme://host[:port]. Empty allows the
+	// viewer's loopback names and the Vite dev server on port 3001; "*" allows
+	// any origin. Any other request carrying an Origin header is refused, and
+	// so is one whose Host header does not name this server, which is what
+	// stops DNS rebinding. A name listed here is also accepted as a Host, so
+	// listing http://mybox:8000 is how a viewer bound to 0.0.0.0 is reached
+	// as mybox.
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+
+	// WatchDir names a directory of OTLP JSON-lines files -- the collector
+	// file exporter's output, rotated files included -- to tail into the
+	// store, for services that can write files but cannot reach an OTLP
+	// endpoint. How far each file has been read is kept in the store, so with
+	// Db set a restart carries on where it stopped; in memory, a restart
+	// reads the files again from the top, into a store that is empty anyway.
+	// Empty watches nothing.
+	WatchDir string `mapstructure:"watch_dir"`
+
+	// ReadOnly opens Db for viewing only, and Db may then also name an
+	// exportBundle zip or the directory it unzips to. Clear, delete, ingest
+	// and retention are refused, so an exporter writing to this extension
+	// fails every batch: --open runs it with no pipeline at all.
+	ReadOnly bool `mapstructure:"read_only"`
+
+	// ForwardEndpoints lists where forwardTelemetry may send what the store
+	// holds -- each an http(s):// OTLP/HTTP base URL or a grpc(s)://host:port.
+	// It is the only list the viewer will send to: an RPC names one of these
+	// or is refused, so a page that can reach the viewer cannot make it post
+	// telemetry somewhere of its choosing. Empty turns forwarding off.
+	ForwardEndpoints []string `mapstructure:"forward_endpoints"`
 }
 
 // Telemetry modes. Shared vocabulary with the desktop exporter's config.
@@ -56,6 +91,27 @@ func (cfg *Config) Validate() error {
 			cfg.Telemetry, TelemetryDisabled, TelemetryEnabled, TelemetrySelf)
 	}
 
+	if cfg.ReadOnly && cfg.Db == "" {
+		return fmt.Errorf("read_only needs db: there is nothing to view in a fresh in-memory store")
+	}
+
+	if cfg.ReadOnly && cfg.WatchDir != "" {
+		return fmt.Errorf("watch_dir cannot be used with read_only: a read-only store cannot take what it reads")
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if _, _, _, err := server.ParseOrigin(origin); err != nil {
+			return fmt.Errorf("invalid allowed_origins: %w", err)
+		}
+	}
+
+	if _, err := forward.NewAllowlist(cfg.ForwardEndpoints); err != nil {
+		return fmt.Errorf("invalid forward_endpoints: %w", err)
+	}
+
 	return nil
 }
 
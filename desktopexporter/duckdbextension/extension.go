@@ -2,6 +2,9 @@
 // serves it: the DuckDB database, the frontend HTTP server, and the retention
 // loop that keeps the store under its size cap.
 //
+// With watch_dir set it also tails a directory of OTLP files into the store
+// (see filewatch), which needs the store's lifetime for the same reason.
+//
 // It is an extension rather than part of the exporter because the collector
 // starts extensions before any pipeline component and shuts them down after
 // (service.Start / service.Shutdown document the order), which is exactly the
@@ -21,6 +24,7 @@ import (
 	"go.opentelemetry.io/collector/component"
 	"go.uber.org/zap"
 
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/filewatch"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/server"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/telemetry"
@@ -51,6 +55,9 @@ type DuckDBExtension struct {
 
 	retentionCancel context.CancelFunc
 	retentionDone   chan struct{}
+
+	watchCancel context.CancelFunc
+	watchDone   chan struct{}
 }
 
 func newDuckDBExtension(cfg *Config, set component.TelemetrySettings) (*DuckDBExtension, error) {
@@ -74,12 +81,16 @@ func (e *DuckDBExtension) Store() *store.Store {
 }
 
 func (e *DuckDBExtension) Start(ctx context.Context, _ component.Host) error {
-	str, err := store.NewStore(ctx, e.cfg.Db, e.logger)
+	var opts []store.Option
+	if e.cfg.ReadOnly {
+		opts = append(opts, store.ReadOnly())
+	}
+	str, err := store.NewStore(ctx, e.cfg.Db, e.logger, opts...)
 	if err != nil {
 		return err
 	}
 
-	srv, err := server.NewServer(e.cfg.Endpoint, str, e.logger, e.tel)
+	srv, err := server.NewServer(e.cfg.Endpoint, str, e.logger, e.tel, e.cfg.AllowedOrigins, e.cfg.ForwardEndpoints)
 	if err != nil {
 		str.Close()
 		return err
@@ -92,7 +103,12 @@ func (e *DuckDBExtension) Start(ctx context.Context, _ component.Host) error {
 		str.Close()
 		return err
 	}
-	if maxBytes < 0 {
+	switch {
+	case e.cfg.ReadOnly:
+		// Retention deletes, which a read-only store refuses; and whatever
+		// was opened is the size it is.
+		maxBytes = 0
+	case maxBytes < 0:
 		if e.cfg.Db == "" {
 			maxBytes = defaultMaxSizeInMemory
 		} else {
@@ -102,6 +118,16 @@ func (e *DuckDBExtension) Start(ctx context.Context, _ component.Host) error {
 	// The cap lives on the store so getStats can report it alongside usage.
 	str.SetRetentionCap(maxBytes)
 
+	// The watcher is built before the server starts so a store it cannot
+	// read its checkpoints from fails Start with nothing left running.
+	var watcher *filewatch.Watcher
+	if e.cfg.WatchDir != "" {
+		if watcher, err = filewatch.New(e.cfg.WatchDir, str, e.logger); err != nil {
+			str.Close()
+			return err
+		}
+	}
+
 	if err := srv.Start(); err != nil {
 		str.Close()
 		return err
@@ -118,10 +144,29 @@ func (e *DuckDBExtension) Start(ctx context.Context, _ component.Host) error {
 		e.retentionDone = make(chan struct{})
 		go e.runRetentionLoop(retentionCtx, e.retentionDone)
 	}
+
+	if watcher != nil {
+		watchCtx, cancel := context.WithCancel(context.Background())
+		e.watchCancel = cancel
+		e.watchDone = make(chan struct{})
+		go func() {
+			defer close(e.watchDone)
+			watcher.Run(watchCtx)
+		}()
+		e.logger.Info("Watching a directory for OTLP files", zap.String("dir", e.cfg.WatchDir))
+	}
 	return nil
 }
 
 func (e *DuckDBExtension) Shutdown(ctx context.Context) error {
+	// Stop the watcher first: it writes, and a line it is halfway through
+	// should land (or be cancelled) before anything it writes to goes away.
+	if e.watchCancel != nil {
+		e.watchCancel()
+		<-e.watchDone
+		e.watchCancel = nil
+	}
+
 	// Stop the retention loop and wait for any in-flight enforcement pass,
 	// so the store isn't closed out from under it.
 	if e.retentionCancel != nil {
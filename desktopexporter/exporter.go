@@ -2,7 +2,6 @@ package desktopexporter
 
 import (
 	"context"
-	"database/sql/driver"
 	"errors"
 
 	"go.opentelemetry.io/collector/component"
@@ -11,9 +10,7 @@ import (
 	"go.opentelemetry.io/collector/pdata/ptrace"
 
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
-	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
-	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/metrics"
-	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/sink"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/telemetry"
 )
 
@@ -81,6 +78,9 @@ func (e *desktopExporter) Start(_ context.Context, host component.Host) error {
 // context.Background() per merged batch (it must -- the client's request has
 // already completed), so nothing upstream bounds the write. See IngestTimeout
 // for why the bound exists and why it is set so far above the working range.
+//
+// The write itself, and feeding the live hub from traces and logs, is
+// sink's: the same path replay and the directory watcher take.
 func withIngestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
 	return context.WithTimeout(ctx, IngestTimeout)
 }
@@ -90,9 +90,7 @@ func (e *desktopExporter) pushTraces(ctx context.Context, source ptrace.Traces)
 	defer cancel()
 
 	ctx, end := e.tel.Ingest(ctx, "traces", source.SpanCount())
-	err := e.store.WithConn(func(conn driver.Conn) error {
-		return spans.Ingest(ctx, conn, source, e.store.FlushedIDs())
-	})
+	err := sink.Traces(ctx, e.store, source, nil)
 	end(err)
 	return err
 }
@@ -102,9 +100,7 @@ func (e *desktopExporter) pushMetrics(ctx context.Context, source pmetric.Metric
 	defer cancel()
 
 	ctx, end := e.tel.Ingest(ctx, "metrics", source.DataPointCount())
-	err := e.store.WithConn(func(conn driver.Conn) error {
-		return metrics.Ingest(ctx, conn, source, e.store.FlushedIDs())
-	})
+	err := sink.Metrics(ctx, e.store, source, nil)
 	end(err)
 	return err
 }
@@ -114,9 +110,7 @@ func (e *desktopExporter) pushLogs(ctx context.Context, source plog.Logs) error
 	defer cancel()
 
 	ctx, end := e.tel.Ingest(ctx, "logs", source.LogRecordCount())
-	err := e.store.WithConn(func(conn driver.Conn) error {
-		return logs.Ingest(ctx, conn, source, e.store.FlushedIDs())
-	})
+	err := sink.Logs(ctx, e.store, source, nil)
 	end(err)
 	return err
 }
@@ -0,0 +1,297 @@
+// Package filewatch tails a directory of OTLP JSON-lines files -- what the
+// collector's file exporter writes -- into the store, for services that can
+// write files but cannot reach an OTLP endpoint: a sandbox with no network, a
+// test harness whose collector only has a file exporter.
+//
+// The directory is polled rather than watched through the OS. A file only
+// ever grows or is renamed away by rotation, so a stat per file per poll
+// finds everything an event would, works the same on every platform and on
+// network and bind-mounted directories where inotify says nothing, and needs
+// no dependency.
+//
+// What has been read is checkpointed in the store's file_offsets table, keyed
+// by a fingerprint of each file's first line rather than by its path; the
+// table's DDL says why. Lines go through sink, the same write path the
+// desktop exporter takes, and each line's checkpoint is written on the same
+// connection straight after it -- so a restart resumes at the last line
+// stored, and at worst repeats the one line it died on.
+package filewatch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/sink"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/replay"
+)
+
+// DefaultPollInterval is how often the directory is scanned. The file
+// exporter flushes on its own schedule, so polling faster mostly finds
+// nothing; a second is below what anyone watching the viewer notices.
+const DefaultPollInterval = time.Second
+
+const (
+	loadOffsetsQuery = `select fingerprint, read_to from file_offsets`
+	saveOffsetQuery  = `insert or replace into file_offsets (fingerprint, path, read_to) values (?, ?, ?)`
+)
+
+// Watcher tails one directory into one store. Not safe for concurrent use:
+// Run is its only caller, from one goroutine.
+type Watcher struct {
+	dir      string
+	store    *store.Store
+	logger   *zap.Logger
+	interval time.Duration
+
+	// offsets is file_offsets, loaded once by New and kept in step with every
+	// row written since. The watcher is the table's only writer.
+	offsets map[string]int64
+
+	// unchanged is each path's size and modification time when it was last
+	// read to its end, so a poll can pass over a file nothing has written to
+	// without opening it.
+	unchanged map[string]fileStamp
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// New prepares a watcher for dir, loading the checkpoints s holds. dir need
+// not exist yet: a sandbox often creates its output directory only when it
+// first writes, and until then there is simply nothing to read.
+func New(dir string, s *store.Store, logger *zap.Logger) (*Watcher, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	offsets := map[string]int64{}
+	err := s.WithDBRead(func(db *sql.DB) error {
+		rows, err := db.Query(loadOffsetsQuery)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var fingerprint string
+			var readTo int64
+			if err := rows.Scan(&fingerprint, &readTo); err != nil {
+				return err
+			}
+			offsets[fingerprint] = readTo
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("filewatch: loading read offsets: %w", err)
+	}
+	return &Watcher{
+		dir:       dir,
+		store:     s,
+		logger:    logger,
+		interval:  DefaultPollInterval,
+		offsets:   offsets,
+		unchanged: map[string]fileStamp{},
+	}, nil
+}
+
+// Run polls until ctx is done, starting with an immediate pass so whatever
+// accumulated while nothing was watching is loaded at startup.
+func (w *Watcher) Run(ctx context.Context) {
+	if _, err := os.Stat(w.dir); errors.Is(err, fs.ErrNotExist) {
+		w.logger.Warn("watched directory does not exist yet; waiting for it", zap.String("dir", w.dir))
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads every watched file in the directory as far as it has complete
+// lines. A file that fails is reported and tried again next poll; the others
+// are not held up by it.
+func (w *Watcher) poll(ctx context.Context) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			w.logger.Warn("could not list the watched directory", zap.String("dir", w.dir), zap.Error(err))
+		}
+		return
+	}
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		if !e.Type().IsRegular() || !watched(e.Name()) {
+			continue
+		}
+		path := filepath.Join(w.dir, e.Name())
+		if err := w.readFile(ctx, path); err != nil && ctx.Err() == nil {
+			w.logger.Warn("could not read watched file", zap.String("path", path), zap.Error(err))
+		}
+	}
+}
+
+// watched reports whether a file name is one the file exporter writes. Its
+// rotated files keep the extension (traces-2026-03-01T12-00-00.000.json), so
+// they match too. Compressed rotations do not: by the time one exists, the
+// file it was compressed from has already been read.
+func watched(name string) bool {
+	return strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".jsonl")
+}
+
+// readFile ingests path from its checkpoint to its last complete line.
+func (w *Watcher) readFile(ctx context.Context, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	stamp := fileStamp{size: info.Size(), modTime: info.ModTime()}
+	if w.unchanged[path] == stamp {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	first, err := bufio.NewReader(f).ReadBytes('\n')
+	if err == io.EOF {
+		// Not one whole line yet, so nothing to identify the file by, and
+		// nothing to read either.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	fp := fingerprint(first)
+
+	from := w.offsets[fp]
+	if from > stamp.size {
+		// Same first line, less file: truncated and written again. What is
+		// there now has not been read.
+		w.logger.Info("watched file shrank; reading it from the start",
+			zap.String("path", path), zap.Int64("was", from), zap.Int64("now", stamp.size))
+		from = 0
+	}
+
+	r := bufio.NewReaderSize(io.NewSectionReader(f, from, stamp.size-from), 64<<10)
+	pos := from
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// Anything left is a line still being written.
+			break
+		} else if err != nil {
+			return err
+		}
+		end := pos + int64(len(line))
+		if err := w.ingestLine(ctx, fp, path, line, pos, end); err != nil {
+			return err
+		}
+		pos = end
+	}
+	w.unchanged[path] = stamp
+	return nil
+}
+
+// ingestLine stores one line and checkpoints past it.
+//
+// A line that is not OTLP, or that the store refuses, is reported and
+// stepped over: it will be just as wrong on the next poll, and retrying it
+// forever would stop everything behind it in the file. Only a store that is
+// closed or a poll that was cancelled leave the checkpoint where it is, since
+// those say nothing about the line.
+func (w *Watcher) ingestLine(ctx context.Context, fp, path string, line []byte, start, end int64) error {
+	line = bytes.TrimSpace(line)
+	checkpoint := func(conn driver.Conn) error {
+		return saveOffset(ctx, conn, fp, path, end)
+	}
+
+	var c replay.Capture
+	if len(line) > 0 {
+		if err := c.Read(path, bytes.NewReader(line)); err != nil {
+			w.logger.Warn("skipping a line that is not OTLP JSON",
+				zap.String("path", path), zap.Int64("offset", start), zap.Error(err))
+			c = replay.Capture{}
+		}
+	}
+
+	var err error
+	for _, td := range c.Traces {
+		err = errors.Join(err, sink.Traces(ctx, w.store, td, checkpoint))
+	}
+	for _, md := range c.Metrics {
+		err = errors.Join(err, sink.Metrics(ctx, w.store, md, checkpoint))
+	}
+	for _, ld := range c.Logs {
+		err = errors.Join(err, sink.Logs(ctx, w.store, ld, checkpoint))
+	}
+	if spans, points, logs := c.Counts(); spans+points+logs == 0 {
+		// Blank, skipped, or a request with nothing in it: nothing went
+		// through sink to carry the checkpoint.
+		err = w.store.WithConn(checkpoint)
+	}
+
+	switch {
+	case err == nil:
+	case ctx.Err() != nil, errors.Is(err, store.ErrStoreConnectionClosed):
+		return err
+	default:
+		w.logger.Warn("the store refused a line; skipping it",
+			zap.String("path", path), zap.Int64("offset", start), zap.Error(err))
+		if err := w.store.WithConn(checkpoint); err != nil {
+			return err
+		}
+	}
+	w.offsets[fp] = end
+	return nil
+}
+
+// fingerprint identifies a file by its first line: a whole export request,
+// timestamps and all, so two files sharing one is two copies of the same
+// data.
+func fingerprint(firstLine []byte) string {
+	sum := sha256.Sum256(bytes.TrimRight(firstLine, "\r\n"))
+	return hex.EncodeToString(sum[:])
+}
+
+func saveOffset(ctx context.Context, conn driver.Conn, fp, path string, readTo int64) error {
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		return errors.New("filewatch: the store's connection cannot execute statements")
+	}
+	_, err := execer.ExecContext(ctx, saveOffsetQuery, []driver.NamedValue{
+		{Ordinal: 1, Value: fp},
+		{Ordinal: 2, Value: path},
+		{Ordinal: 3, Value: readTo},
+	})
+	if err != nil {
+		return fmt.Errorf("filewatch: saving the read offset of %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package filewatch
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+)
+
+// spanLine is one export request as the file exporter writes it: a JSON line
+// holding a single span. n makes each one distinct.
+func spanLine(t *testing.T, n byte) []byte {
+	t.Helper()
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{n, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	span.SetSpanID(pcommon.SpanID{n, 2, 3, 4, 5, 6, 7, 8})
+	span.SetName("step")
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(time.Unix(1_700_000_000+int64(n), 0)))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(time.Unix(1_700_000_001+int64(n), 0)))
+	b, err := (&ptrace.JSONMarshaler{}).MarshalTraces(td)
+	require.NoError(t, err)
+	return append(b, '\n')
+}
+
+func logLine(t *testing.T, body string) []byte {
+	t.Helper()
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.SetTimestamp(pcommon.NewTimestampFromTime(time.Unix(1_700_000_000, 0)))
+	lr.Body().SetStr(body)
+	b, err := (&plog.JSONMarshaler{}).MarshalLogs(ld)
+	require.NoError(t, err)
+	return append(b, '\n')
+}
+
+func appendTo(t *testing.T, path string, chunks ...[]byte) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	defer f.Close()
+	for _, c := range chunks {
+		_, err := f.Write(c)
+		require.NoError(t, err)
+	}
+}
+
+func count(t *testing.T, s *store.Store, table string) int {
+	t.Helper()
+	var n int
+	require.NoError(t, s.WithDBRead(func(db *sql.DB) error {
+		return db.QueryRow(`select count(*) from ` + table).Scan(&n)
+	}))
+	return n
+}
+
+func newStore(t *testing.T, path string) *store.Store {
+	t.Helper()
+	s, err := store.NewStore(context.Background(), path, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func newWatcher(t *testing.T, dir string, s *store.Store) *Watcher {
+	t.Helper()
+	w, err := New(dir, s, nil)
+	require.NoError(t, err)
+	return w
+}
+
+func TestTailsCompleteLines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "traces.json")
+	s := newStore(t, "")
+	w := newWatcher(t, dir, s)
+
+	second := spanLine(t, 2)
+	appendTo(t, path, spanLine(t, 1), logLine(t, "hello"), second[:40])
+	w.poll(context.Background())
+	assert.Equal(t, 1, count(t, s, "spans"))
+	assert.Equal(t, 1, count(t, s, "logs"))
+
+	// The exporter finishes the line it was writing.
+	appendTo(t, path, second[40:])
+	w.poll(context.Background())
+	assert.Equal(t, 2, count(t, s, "spans"), "the finished line is read, and nothing twice")
+	assert.Equal(t, 1, count(t, s, "logs"))
+}
+
+// The file exporter's rotation renames the live file and starts a new one
+// under the old name. Neither may be read twice, and neither may be skipped.
+func TestFollowsRotation(t *testing.T) {
+	dir := t.TempDir()
+	live := filepath.Join(dir, "traces.json")
+	s := newStore(t, "")
+	w := newWatcher(t, dir, s)
+
+	appendTo(t, live, spanLine(t, 1))
+	w.poll(context.Background())
+	require.Equal(t, 1, count(t, s, "spans"))
+
+	// One more line lands just before rotation, and one in the new file.
+	appendTo(t, live, spanLine(t, 2))
+	require.NoError(t, os.Rename(live, filepath.Join(dir, "traces-2026-03-01T12-00-00.000.json")))
+	appendTo(t, live, spanLine(t, 3))
+	w.poll(context.Background())
+	assert.Equal(t, 3, count(t, s, "spans"))
+
+	w.poll(context.Background())
+	assert.Equal(t, 3, count(t, s, "spans"))
+}
+
+func TestResumesFromTheStoredOffset(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "traces.json")
+	db := filepath.Join(t.TempDir(), "watch.db")
+
+	appendTo(t, path, spanLine(t, 1), spanLine(t, 2))
+	s, err := store.NewStore(context.Background(), db, nil)
+	require.NoError(t, err)
+	newWatcher(t, dir, s).poll(context.Background())
+	require.Equal(t, 2, count(t, s, "spans"))
+	require.NoError(t, s.Close())
+
+	// Written while nothing was watching.
+	appendTo(t, path, spanLine(t, 3))
+
+	s = newStore(t, db)
+	newWatcher(t, dir, s).poll(context.Background())
+	assert.Equal(t, 3, count(t, s, "spans"), "only the line written since is new")
+}
+
+// A line that is not OTLP is stepped over, so it cannot hold back the lines
+// after it -- and its checkpoint is kept, so a restart does not trip on it
+// again.
+func TestSkipsLinesThatAreNotOTLP(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs.jsonl")
+	s := newStore(t, "")
+
+	appendTo(t, path, logLine(t, "before"), []byte("{\"not\": \"otlp\"}\n"), []byte("\n"), logLine(t, "after"))
+	newWatcher(t, dir, s).poll(context.Background())
+	assert.Equal(t, 2, count(t, s, "logs"))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{fingerprint(logLine(t, "before")): info.Size()}, newWatcher(t, dir, s).offsets)
+}
+
+func TestIgnoresOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	s := newStore(t, "")
+
+	appendTo(t, filepath.Join(dir, "notes.txt"), spanLine(t, 1))
+	appendTo(t, filepath.Join(dir, "traces-old.json.gz"), spanLine(t, 2))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub.json"), 0o700))
+	newWatcher(t, dir, s).poll(context.Background())
+	assert.Equal(t, 0, count(t, s, "spans"))
+}
+
+// A directory that does not exist yet is waited for, not an error.
+func TestWaitsForTheDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "later")
+	s := newStore(t, "")
+	w := newWatcher(t, dir, s)
+	w.interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Run(ctx)
+	}()
+
+	require.NoError(t, os.Mkdir(dir, 0o700))
+	appendTo(t, filepath.Join(dir, "traces.json"), spanLine(t, 1))
+	assert.Eventually(t, func() bool {
+		var n int
+		err := s.WithDBRead(func(db *sql.DB) error {
+			return db.QueryRow(`select count(*) from spans`).Scan(&n)
+		})
+		return err == nil && n == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
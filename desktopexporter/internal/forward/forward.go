@@ -0,0 +1,380 @@
+// Package forward sends telemetry rebuilt from the store on to another OTLP
+// endpoint -- the team's shared backend, typically, once a trace worth
+// keeping has turned up locally.
+//
+// Where it may send is fixed by whoever started the viewer, not by whoever
+// is calling it. The viewer's API is reachable from the browser, and without
+// a list an RPC naming its own destination would be a way to post everything
+// the store holds to any host a page chose. So an endpoint is sent to only if
+// it appears in an Allowlist built from the duckdb extension's
+// forward_endpoints, compared in one canonical form, and an HTTP redirect is
+// never followed off it.
+//
+// Each ResourceSpans, ResourceLogs or ResourceMetrics goes in its own export
+// request. That is the unit a caller can act on -- one service's batch
+// refused while the others went through -- and it keeps any one request near
+// the size its sender originally chose.
+package forward
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// ErrNotAllowed is an endpoint missing from the allowlist, or any endpoint
+// when the list is empty.
+var ErrNotAllowed = errors.New("endpoint is not in forward_endpoints")
+
+// batchTimeout bounds one export request. A backend that hangs should cost
+// the caller one failed batch, not the whole forward.
+const batchTimeout = 30 * time.Second
+
+// maxResponseBytes is how much of a response body is read: enough for any
+// partial-success message or error text, not enough to matter if an endpoint
+// answers with something enormous.
+const maxResponseBytes = 1 << 20
+
+// defaultGRPCPort is OTLP/gRPC's registered port, for a grpc:// endpoint
+// written without one.
+const defaultGRPCPort = "4317"
+
+// Endpoint is one destination. The scheme picks the transport:
+//
+//   - http://host[:port][/base] and https://... send OTLP/HTTP protobuf to
+//     base + /v1/traces, /v1/logs or /v1/metrics, as an OTLP exporter's
+//     endpoint setting does.
+//   - grpc://host[:port] sends OTLP/gRPC in plaintext, grpcs://host[:port]
+//     over TLS. The port defaults to 4317.
+type Endpoint struct {
+	// canonical is what two spellings of one endpoint have in common, and so
+	// what the allowlist compares.
+	canonical string
+	grpc      bool
+	tls       bool
+	// base is the OTLP/HTTP URL without the signal path; target is the
+	// gRPC host:port.
+	base   string
+	target string
+}
+
+// String returns the endpoint in the form the allowlist compares.
+func (e Endpoint) String() string {
+	return e.canonical
+}
+
+// ParseEndpoint reads an endpoint as forward_endpoints and forwardTelemetry
+// write it. Credentials, a query or a fragment are refused rather than kept:
+// credentials belong in headers, and the other two have no meaning to OTLP.
+func ParseEndpoint(s string) (Endpoint, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("endpoint %q: %w", s, err)
+	}
+	if u.Host == "" || u.Hostname() == "" {
+		return Endpoint{}, fmt.Errorf("endpoint %q: want scheme://host[:port], e.g. https://otlp.example.com or grpc://localhost:4317", s)
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return Endpoint{}, fmt.Errorf("endpoint %q: credentials, queries and fragments are not supported; pass credentials as headers", s)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Host)
+	switch scheme {
+	case "http", "https":
+		path := strings.TrimRight(u.EscapedPath(), "/")
+		base := scheme + "://" + host + path
+		return Endpoint{canonical: base, tls: scheme == "https", base: base}, nil
+	case "grpc", "grpcs":
+		if p := strings.TrimRight(u.Path, "/"); p != "" {
+			return Endpoint{}, fmt.Errorf("endpoint %q: a gRPC endpoint has no path", s)
+		}
+		if u.Port() == "" {
+			host = net.JoinHostPort(strings.ToLower(u.Hostname()), defaultGRPCPort)
+		}
+		return Endpoint{canonical: scheme + "://" + host, grpc: true, tls: scheme == "grpcs", target: host}, nil
+	default:
+		return Endpoint{}, fmt.Errorf("endpoint %q: scheme must be http, https, grpc or grpcs", s)
+	}
+}
+
+// Allowlist is the set of endpoints forwarding may reach. The zero value
+// allows nothing.
+type Allowlist struct {
+	endpoints map[string]Endpoint
+}
+
+// NewAllowlist parses every entry, failing on the first that does not parse
+// so a typo surfaces when the viewer starts rather than when someone first
+// tries to forward.
+func NewAllowlist(entries []string) (*Allowlist, error) {
+	a := &Allowlist{endpoints: make(map[string]Endpoint, len(entries))}
+	for _, entry := range entries {
+		ep, err := ParseEndpoint(entry)
+		if err != nil {
+			return nil, err
+		}
+		a.endpoints[ep.canonical] = ep
+	}
+	return a, nil
+}
+
+// Lookup returns the listed endpoint s names, or ErrNotAllowed.
+func (a *Allowlist) Lookup(s string) (Endpoint, error) {
+	if a == nil || len(a.endpoints) == 0 {
+		return Endpoint{}, fmt.Errorf("%w: forwarding is off until the duckdb extension lists endpoints", ErrNotAllowed)
+	}
+	ep, err := ParseEndpoint(s)
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("%w: %w", ErrNotAllowed, err)
+	}
+	listed, ok := a.endpoints[ep.canonical]
+	if !ok {
+		return Endpoint{}, fmt.Errorf("%w: %s", ErrNotAllowed, ep.canonical)
+	}
+	return listed, nil
+}
+
+// Batch is one export request, ready to send either way.
+type Batch struct {
+	// Service is the batch resource's service.name, for the report.
+	Service string
+	// Items counts the spans, log records or datapoints it carries.
+	Items int
+
+	path       string
+	proto      func() ([]byte, error)
+	exportGRPC func(ctx context.Context, cc *grpc.ClientConn) (partialSuccess, error)
+	readHTTP   func(body []byte) (partialSuccess, error)
+}
+
+// partialSuccess is what an endpoint that accepted a request says it dropped.
+type partialSuccess struct {
+	rejected int64
+	message  string
+}
+
+// TraceBatches splits td into one Batch per ResourceSpans.
+func TraceBatches(td ptrace.Traces) []Batch {
+	batches := make([]Batch, 0, td.ResourceSpans().Len())
+	for _, rs := range td.ResourceSpans().All() {
+		one := ptrace.NewTraces()
+		rs.CopyTo(one.ResourceSpans().AppendEmpty())
+		req := ptraceotlp.NewExportRequestFromTraces(one)
+		batches = append(batches, Batch{
+			Service: serviceName(rs.Resource()),
+			Items:   one.SpanCount(),
+			path:    "/v1/traces",
+			proto:   req.MarshalProto,
+			exportGRPC: func(ctx context.Context, cc *grpc.ClientConn) (partialSuccess, error) {
+				resp, err := ptraceotlp.NewGRPCClient(cc).Export(ctx, req)
+				if err != nil {
+					return partialSuccess{}, err
+				}
+				return partialSuccess{resp.PartialSuccess().RejectedSpans(), resp.PartialSuccess().ErrorMessage()}, nil
+			},
+			readHTTP: func(body []byte) (partialSuccess, error) {
+				resp := ptraceotlp.NewExportResponse()
+				if err := resp.UnmarshalProto(body); err != nil {
+					return partialSuccess{}, err
+				}
+				return partialSuccess{resp.PartialSuccess().RejectedSpans(), resp.PartialSuccess().ErrorMessage()}, nil
+			},
+		})
+	}
+	return batches
+}
+
+// LogBatches splits ld into one Batch per ResourceLogs.
+func LogBatches(ld plog.Logs) []Batch {
+	batches := make([]Batch, 0, ld.ResourceLogs().Len())
+	for _, rl := range ld.ResourceLogs().All() {
+		one := plog.NewLogs()
+		rl.CopyTo(one.ResourceLogs().AppendEmpty())
+		req := plogotlp.NewExportRequestFromLogs(one)
+		batches = append(batches, Batch{
+			Service: serviceName(rl.Resource()),
+			Items:   one.LogRecordCount(),
+			path:    "/v1/logs",
+			proto:   req.MarshalProto,
+			exportGRPC: func(ctx context.Context, cc *grpc.ClientConn) (partialSuccess, error) {
+				resp, err := plogotlp.NewGRPCClient(cc).Export(ctx, req)
+				if err != nil {
+					return partialSuccess{}, err
+				}
+				return partialSuccess{resp.PartialSuccess().RejectedLogRecords(), resp.PartialSuccess().ErrorMessage()}, nil
+			},
+			readHTTP: func(body []byte) (partialSuccess, error) {
+				resp := plogotlp.NewExportResponse()
+				if err := resp.UnmarshalProto(body); err != nil {
+					return partialSuccess{}, err
+				}
+				return partialSuccess{resp.PartialSuccess().RejectedLogRecords(), resp.PartialSuccess().ErrorMessage()}, nil
+			},
+		})
+	}
+	return batches
+}
+
+// MetricBatches splits md into one Batch per ResourceMetrics.
+func MetricBatches(md pmetric.Metrics) []Batch {
+	batches := make([]Batch, 0, md.ResourceMetrics().Len())
+	for _, rm := range md.ResourceMetrics().All() {
+		one := pmetric.NewMetrics()
+		rm.CopyTo(one.ResourceMetrics().AppendEmpty())
+		req := pmetricotlp.NewExportRequestFromMetrics(one)
+		batches = append(batches, Batch{
+			Service: serviceName(rm.Resource()),
+			Items:   one.DataPointCount(),
+			path:    "/v1/metrics",
+			proto:   req.MarshalProto,
+			exportGRPC: func(ctx context.Context, cc *grpc.ClientConn) (partialSuccess, error) {
+				resp, err := pmetricotlp.NewGRPCClient(cc).Export(ctx, req)
+				if err != nil {
+					return partialSuccess{}, err
+				}
+				return partialSuccess{resp.PartialSuccess().RejectedDataPoints(), resp.PartialSuccess().ErrorMessage()}, nil
+			},
+			readHTTP: func(body []byte) (partialSuccess, error) {
+				resp := pmetricotlp.NewExportResponse()
+				if err := resp.UnmarshalProto(body); err != nil {
+					return partialSuccess{}, err
+				}
+				return partialSuccess{resp.PartialSuccess().RejectedDataPoints(), resp.PartialSuccess().ErrorMessage()}, nil
+			},
+		})
+	}
+	return batches
+}
+
+func serviceName(res pcommon.Resource) string {
+	if v, ok := res.Attributes().Get("service.name"); ok {
+		return v.AsString()
+	}
+	return ""
+}
+
+// Result is how one batch fared. OK means the endpoint took all of it; a
+// batch it accepted in part is not OK, and says how many items it dropped.
+type Result struct {
+	Service  string `json:"service"`
+	Items    int    `json:"items"`
+	OK       bool   `json:"ok"`
+	Rejected int64  `json:"rejected"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Send exports each batch to ep, in order, with headers on every request --
+// as HTTP headers or as gRPC metadata. A batch that fails does not stop the
+// ones after it. The error is for failures before any batch could be tried,
+// such as a gRPC target that cannot be dialled at all.
+func Send(ctx context.Context, ep Endpoint, headers map[string]string, batches []Batch) ([]Result, error) {
+	send, closeFn, err := sender(ep, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	results := make([]Result, 0, len(batches))
+	for _, b := range batches {
+		r := Result{Service: b.Service, Items: b.Items}
+		batchCtx, cancel := context.WithTimeout(ctx, batchTimeout)
+		ps, err := send(batchCtx, b)
+		cancel()
+		switch {
+		case err != nil:
+			r.Error = err.Error()
+		case ps.rejected > 0 || ps.message != "":
+			r.Rejected = ps.rejected
+			r.Error = ps.message
+			if r.Error == "" {
+				r.Error = fmt.Sprintf("endpoint rejected %d of %d", ps.rejected, b.Items)
+			}
+		default:
+			r.OK = true
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// sender returns the per-batch send for ep's transport and what closes it.
+func sender(ep Endpoint, headers map[string]string) (func(context.Context, Batch) (partialSuccess, error), func(), error) {
+	if ep.grpc {
+		creds := insecure.NewCredentials()
+		if ep.tls {
+			creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+		}
+		cc, err := grpc.NewClient(ep.target, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, nil, fmt.Errorf("forward: %s: %w", ep, err)
+		}
+		md := metadata.New(headers)
+		return func(ctx context.Context, b Batch) (partialSuccess, error) {
+			return b.exportGRPC(metadata.NewOutgoingContext(ctx, md), cc)
+		}, func() { cc.Close() }, nil
+	}
+
+	client := &http.Client{
+		// A redirect is the endpoint naming a different destination, which
+		// the allowlist has not approved. Report it instead of following it.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return func(ctx context.Context, b Batch) (partialSuccess, error) {
+		return sendHTTP(ctx, client, ep.base+b.path, headers, b)
+	}, client.CloseIdleConnections, nil
+}
+
+func sendHTTP(ctx context.Context, client *http.Client, url string, headers map[string]string, b Batch) (partialSuccess, error) {
+	body, err := b.proto()
+	if err != nil {
+		return partialSuccess{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return partialSuccess{}, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	res, err := client.Do(req)
+	if err != nil {
+		return partialSuccess{}, err
+	}
+	defer res.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(res.Body, maxResponseBytes))
+	if err != nil {
+		return partialSuccess{}, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return partialSuccess{}, fmt.Errorf("%s answered %s: %s", url, res.Status, bytes.TrimSpace(respBody[:min(len(respBody), 512)]))
+	}
+	// An empty body is a full success; so is one that is not protobuf, since
+	// the status already said the request was taken.
+	if len(respBody) == 0 || !strings.HasPrefix(res.Header.Get("Content-Type"), "application/x-protobuf") {
+		return partialSuccess{}, nil
+	}
+	return b.readHTTP(respBody)
+}
@@ -0,0 +1,222 @@
+package forward_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/forward"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"https://OTLP.example.com/", "https://otlp.example.com"},
+		{"http://localhost:4318/prefix/", "http://localhost:4318/prefix"},
+		{"grpc://localhost", "grpc://localhost:4317"},
+		{"GRPCS://Collector:443", "grpcs://collector:443"},
+	} {
+		ep, err := forward.ParseEndpoint(tc.in)
+		require.NoError(t, err, tc.in)
+		assert.Equal(t, tc.want, ep.String(), tc.in)
+	}
+
+	for _, bad := range []string{
+		"", "localhost:4317", "ftp://example.com", "https://user:pw@example.com",
+		"https://example.com?x=1", "https://example.com#top", "grpc://localhost:4317/path",
+	} {
+		_, err := forward.ParseEndpoint(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	var empty *forward.Allowlist
+	_, err := empty.Lookup("https://otlp.example.com")
+	assert.ErrorIs(t, err, forward.ErrNotAllowed)
+
+	_, err = forward.NewAllowlist([]string{"https://ok.example.com", "not a url"})
+	assert.Error(t, err)
+
+	allow, err := forward.NewAllowlist([]string{"https://otlp.example.com", "grpc://localhost"})
+	require.NoError(t, err)
+
+	// Another spelling of a listed endpoint is the listed endpoint.
+	ep, err := allow.Lookup("HTTPS://otlp.example.com/")
+	require.NoError(t, err)
+	assert.Equal(t, "https://otlp.example.com", ep.String())
+	_, err = allow.Lookup("grpc://localhost:4317")
+	assert.NoError(t, err)
+
+	for _, other := range []string{
+		"https://otlp.example.com.attacker.test", "http://otlp.example.com",
+		"https://otlp.example.com/other", "grpc://localhost:4318", "nonsense",
+	} {
+		_, err := allow.Lookup(other)
+		assert.ErrorIs(t, err, forward.ErrNotAllowed, other)
+	}
+}
+
+func twoServices() ptrace.Traces {
+	td := ptrace.NewTraces()
+	for _, name := range []string{"frontend", "checkout"} {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", name)
+		spans := rs.ScopeSpans().AppendEmpty().Spans()
+		spans.AppendEmpty().SetName(name + "-a")
+		spans.AppendEmpty().SetName(name + "-b")
+	}
+	return td
+}
+
+func TestTraceBatches(t *testing.T) {
+	batches := forward.TraceBatches(twoServices())
+	require.Len(t, batches, 2)
+	assert.Equal(t, "frontend", batches[0].Service)
+	assert.Equal(t, "checkout", batches[1].Service)
+	assert.Equal(t, 2, batches[0].Items)
+
+	assert.Empty(t, forward.LogBatches(plog.NewLogs()))
+}
+
+// Every batch is posted to the signal's path with the caller's headers, and a
+// failed or partially rejected batch is reported without stopping the rest.
+func TestSendHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var services []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/base/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("Authorization"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req := ptraceotlp.NewExportRequest()
+		require.NoError(t, req.UnmarshalProto(body))
+		name, _ := req.Traces().ResourceSpans().At(0).Resource().Attributes().Get("service.name")
+
+		mu.Lock()
+		services = append(services, name.Str())
+		mu.Unlock()
+
+		switch name.Str() {
+		case "frontend":
+			resp := ptraceotlp.NewExportResponse()
+			resp.PartialSuccess().SetRejectedSpans(1)
+			resp.PartialSuccess().SetErrorMessage("span too large")
+			out, err := resp.MarshalProto()
+			require.NoError(t, err)
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			w.Write(out)
+		case "checkout":
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	ep, err := forward.ParseEndpoint(srv.URL + "/base")
+	require.NoError(t, err)
+	results, err := forward.Send(context.Background(), ep, map[string]string{"Authorization": "secret"},
+		forward.TraceBatches(twoServices()))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"frontend", "checkout"}, services)
+	assert.Equal(t, []forward.Result{
+		{Service: "frontend", Items: 2, Rejected: 1, Error: "span too large"},
+		{Service: "checkout", Items: 2, OK: true},
+	}, results)
+}
+
+func TestSendHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	ep, err := forward.ParseEndpoint(srv.URL)
+	require.NoError(t, err)
+	results, err := forward.Send(context.Background(), ep, nil, forward.TraceBatches(twoServices()))
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for _, r := range results {
+		assert.False(t, r.OK)
+		assert.Contains(t, r.Error, "429")
+		assert.Contains(t, r.Error, "quota exceeded")
+	}
+}
+
+// A redirect names somewhere the allowlist never approved, so it is reported,
+// not followed.
+func TestSendHTTPRefusesRedirect(t *testing.T) {
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect was followed")
+	}))
+	defer elsewhere.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, elsewhere.URL+r.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	ep, err := forward.ParseEndpoint(srv.URL)
+	require.NoError(t, err)
+	results, err := forward.Send(context.Background(), ep, nil, forward.TraceBatches(twoServices()))
+	require.NoError(t, err)
+	for _, r := range results {
+		assert.False(t, r.OK)
+		assert.Contains(t, r.Error, "307")
+	}
+}
+
+type traceReceiver struct {
+	ptraceotlp.UnimplementedGRPCServer
+	mu       sync.Mutex
+	spans    int
+	metadata []string
+}
+
+func (r *traceReceiver) Export(ctx context.Context, req ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans += req.Traces().SpanCount()
+	md, _ := metadata.FromIncomingContext(ctx)
+	r.metadata = append(r.metadata, md.Get("authorization")...)
+	return ptraceotlp.NewExportResponse(), nil
+}
+
+func TestSendGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	recv := &traceReceiver{}
+	ptraceotlp.RegisterGRPCServer(srv, recv)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	ep, err := forward.ParseEndpoint("grpc://" + lis.Addr().String())
+	require.NoError(t, err)
+	results, err := forward.Send(context.Background(), ep, map[string]string{"Authorization": "secret"},
+		forward.TraceBatches(twoServices()))
+	require.NoError(t, err)
+
+	assert.Equal(t, []forward.Result{
+		{Service: "frontend", Items: 2, OK: true},
+		{Service: "checkout", Items: 2, OK: true},
+	}, results)
+	recv.mu.Lock()
+	defer recv.mu.Unlock()
+	assert.Equal(t, 4, recv.spans)
+	assert.Equal(t, []string{"secret", "secret"}, recv.metadata)
+}
@@ -9,12 +9,14 @@ function renderFooter(props: {
   count: number
   label: 'trace' | 'log' | 'metric'
   onDeleteAll?: () => void
+  hasMore?: boolean
+  loadingMore?: boolean
+  onLoadMore?: () => void
 }) {
   setTestUrl('/logs')
   const onDeleteAll = props.onDeleteAll ?? vi.fn()
   renderWithContexts(SignalDrawerFooter, {
-    count: props.count,
-    label: props.label,
+    ...props,
     onDeleteAll,
   })
   return onDeleteAll
@@ -44,4 +46,29 @@ describe('SignalDrawerFooter', () => {
     )
     expect(onDeleteAll).toHaveBeenCalledTimes(1)
   })
+
+  it('offers no next page when the list is complete', () => {
+    renderFooter({ count: 3, label: 'trace', onLoadMore: vi.fn() })
+    expect(screen.queryByRole('button', { name: 'Load more' })).toBeNull()
+  })
+
+  it('loads the next page on request while more remain', async () => {
+    const onLoadMore = vi.fn()
+    renderFooter({ count: 50, label: 'trace', hasMore: true, onLoadMore })
+    expect(screen.getByText('50+ traces')).toBeInTheDocument()
+    await userEvent.click(screen.getByRole('button', { name: 'Load more' }))
+    expect(onLoadMore).toHaveBeenCalledTimes(1)
+  })
+
+  it('disables the button while a page is loading', () => {
+    renderFooter({
+      count: 1,
+      label: 'log',
+      hasMore: true,
+      loadingMore: true,
+      onLoadMore: vi.fn(),
+    })
+    expect(screen.getByText('1+ logs')).toBeInTheDocument()
+    expect(screen.getByRole('button', { name: 'Loading…' })).toBeDisabled()
+  })
 })
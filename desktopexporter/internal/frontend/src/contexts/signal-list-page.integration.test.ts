@@ -5,6 +5,7 @@ import { screen } from '@testing-library/svelte'
 import SignalListPageProbe from '@/test/SignalListPageProbe.svelte'
 import { navigateToItem } from '@/route'
 import { renderWithContexts, setTestUrl } from '@/test/render-helpers'
+import type { PagedResults, SearchResultEvent } from '@/types/api-types'
 import {
   beginListUpdate,
   cancelPendingListUpdates,
@@ -14,10 +15,15 @@ import {
 
 type Item = { id: string; name: string }
 
+/** A last page of items: nothing more to fetch. */
+function listed(items: Item[]): PagedResults<Item> {
+  return { items, fetchMore: vi.fn() }
+}
+
 function renderProbe(
   url: string,
   items: Item[],
-  fetchList = vi.fn(async () => items)
+  fetchList = vi.fn(async () => listed(items))
 ) {
   setTestUrl(url)
   return renderWithContexts(SignalListPageProbe, { fetchList })
@@ -47,7 +53,7 @@ describe('createSignalListPage integration', () => {
       { id: 'a', name: 'alfa' },
       { id: 'b', name: 'bravo' },
     ]
-    const fetchList = vi.fn(async () => items)
+    const fetchList = vi.fn(async () => listed(items))
     renderProbe('/logs', items, fetchList)
     await waitForListLoaded()
 
@@ -68,10 +74,10 @@ describe('createSignalListPage integration', () => {
   })
 
   it('does not clobber a shared-link id before the list finishes loading', async () => {
-    let resolveFetch!: (items: Item[]) => void
+    let resolveFetch!: (page: PagedResults<Item>) => void
     const fetchList = vi.fn(
       () =>
-        new Promise<Item[]>(resolve => {
+        new Promise<PagedResults<Item>>(resolve => {
           resolveFetch = resolve
         })
     )
@@ -82,7 +88,7 @@ describe('createSignalListPage integration', () => {
     expect(screen.getByTestId('loading').textContent).toBe('true')
     expect(window.location.pathname).toBe('/logs/deep-link-id')
 
-    resolveFetch([{ id: 'other', name: 'other' }])
+    resolveFetch(listed([{ id: 'other', name: 'other' }]))
     await waitForListLoaded()
     await tick()
 
@@ -101,7 +107,7 @@ describe('createSignalListPage integration', () => {
 
     setTestUrl('/logs/a')
     renderWithContexts(SignalListPageProbe, {
-      fetchList: async () => items,
+      fetchList: async () => listed(items),
       onContext: ctx => {
         page = ctx
       },
@@ -115,14 +121,14 @@ describe('createSignalListPage integration', () => {
   })
 
   it('does not let a stale list fetch overwrite newer search results', async () => {
-    let resolveSlowFetch!: (items: Item[]) => void
+    let resolveSlowFetch!: (page: PagedResults<Item>) => void
     let fetchCalls = 0
     const fetchList = vi.fn(async () => {
       fetchCalls++
       if (fetchCalls <= 1) {
-        return [{ id: 'a', name: 'alfa' }]
+        return listed([{ id: 'a', name: 'alfa' }])
       }
-      return new Promise<Item[]>(resolve => {
+      return new Promise<PagedResults<Item>>(resolve => {
         resolveSlowFetch = resolve
       })
     })
@@ -144,16 +150,18 @@ describe('createSignalListPage integration', () => {
     page!.handleSearchResults({
       signal: 'logs',
       updateSeq: searchSeq,
-      results: [{ id: 'search-only', name: 'search-only' }],
-    } as unknown as import('@/types/api-types').SearchResultEvent)
+      results: listed([{ id: 'search-only', name: 'search-only' }]),
+    } as unknown as SearchResultEvent)
     await tick()
 
     expect(screen.getByTestId('item-ids').textContent).toBe('search-only')
 
-    resolveSlowFetch([
-      { id: 'x', name: 'x-ray' },
-      { id: 'y', name: 'yankee' },
-    ])
+    resolveSlowFetch(
+      listed([
+        { id: 'x', name: 'x-ray' },
+        { id: 'y', name: 'yankee' },
+      ])
+    )
     await slowFetch
     await tick()
 
@@ -161,14 +169,14 @@ describe('createSignalListPage integration', () => {
   })
 
   it('does not let a stale search overwrite a newer list fetch', async () => {
-    let resolveSlowFetch!: (items: Item[]) => void
+    let resolveSlowFetch!: (page: PagedResults<Item>) => void
     let fetchCalls = 0
     const fetchList = vi.fn(async () => {
       fetchCalls++
       if (fetchCalls <= 1) {
-        return [{ id: 'a', name: 'alfa' }]
+        return listed([{ id: 'a', name: 'alfa' }])
       }
-      return new Promise<Item[]>(resolve => {
+      return new Promise<PagedResults<Item>>(resolve => {
         resolveSlowFetch = resolve
       })
     })
@@ -190,22 +198,103 @@ describe('createSignalListPage integration', () => {
     page!.handleSearchResults({
       signal: 'logs',
       updateSeq: staleSearchSeq,
-      results: [{ id: 'stale-search', name: 'stale' }],
-    } as unknown as import('@/types/api-types').SearchResultEvent)
+      results: listed([{ id: 'stale-search', name: 'stale' }]),
+    } as unknown as SearchResultEvent)
     await tick()
 
     expect(screen.getByTestId('item-ids').textContent).toBe('a')
 
-    resolveSlowFetch([
-      { id: 'x', name: 'x-ray' },
-      { id: 'y', name: 'yankee' },
-    ])
+    resolveSlowFetch(
+      listed([
+        { id: 'x', name: 'x-ray' },
+        { id: 'y', name: 'yankee' },
+      ])
+    )
     await slowFetch
     await tick()
 
     expect(screen.getByTestId('item-ids').textContent).toBe('x,y')
   })
 
+  it('loads the next page on request and appends it', async () => {
+    const fetchMore = vi.fn(async (cursor: string) => {
+      expect(cursor).toBe('page-2')
+      return {
+        items: [
+          { id: 'b', name: 'bravo' },
+          { id: 'c', name: 'charlie' },
+        ],
+      }
+    })
+    let page:
+      | import('@/contexts/signal-list-page.svelte').SignalListPage<Item>
+      | undefined
+    setTestUrl('/logs')
+    renderWithContexts(SignalListPageProbe, {
+      fetchList: async () => ({
+        items: [
+          { id: 'a', name: 'alfa' },
+          { id: 'b', name: 'bravo' },
+        ],
+        nextCursor: 'page-2',
+        fetchMore,
+      }),
+      onContext: ctx => {
+        page = ctx
+      },
+    })
+    await waitForListLoaded()
+
+    expect(fetchMore).not.toHaveBeenCalled()
+    expect(screen.getByTestId('has-more').textContent).toBe('true')
+
+    await page!.loadMore()
+    await tick()
+
+    expect(fetchMore).toHaveBeenCalledTimes(1)
+    // b arrived on both pages and is listed once.
+    expect(screen.getByTestId('item-ids').textContent).toBe('a,b,c')
+    expect(screen.getByTestId('has-more').textContent).toBe('false')
+
+    await page!.loadMore()
+    expect(fetchMore).toHaveBeenCalledTimes(1)
+  })
+
+  it('drops a next page that arrives after a newer search', async () => {
+    let resolveMore!: (page: { items: Item[] }) => void
+    let page:
+      | import('@/contexts/signal-list-page.svelte').SignalListPage<Item>
+      | undefined
+    setTestUrl('/logs')
+    renderWithContexts(SignalListPageProbe, {
+      fetchList: async () => ({
+        items: [{ id: 'a', name: 'alfa' }],
+        nextCursor: 'page-2',
+        fetchMore: () =>
+          new Promise<{ items: Item[] }>(resolve => {
+            resolveMore = resolve
+          }),
+      }),
+      onContext: ctx => {
+        page = ctx
+      },
+    })
+    await waitForListLoaded()
+
+    const more = page!.loadMore()
+    page!.handleSearchResults({
+      signal: 'logs',
+      updateSeq: beginListUpdate('logs'),
+      results: listed([{ id: 'search-only', name: 'search-only' }]),
+    } as unknown as SearchResultEvent)
+    resolveMore({ items: [{ id: 'z', name: 'zulu' }] })
+    await more
+    await tick()
+
+    expect(screen.getByTestId('item-ids').textContent).toBe('search-only')
+    expect(screen.getByTestId('has-more').textContent).toBe('false')
+  })
+
   it("does not let one signal's list update invalidate another signal's seq", () => {
     const logsSeq = beginListUpdate('logs')
     beginListUpdate('metrics')
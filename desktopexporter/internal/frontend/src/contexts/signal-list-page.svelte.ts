@@ -2,7 +2,8 @@
 //
 // Owns the duplicated wiring: mount/fetch, sort, URL-driven selection with
 // shared-link-safe auto-select, time-range refetch, stats polling refresh
-// indicator, footer keyboard nav, and search-result override. Callers keep
+// indicator, footer keyboard nav, search-result override, and loading the
+// next page of whichever list or search is showing. Callers keep
 // signal-specific sort fns, detail fetching, and Svelte snippets.
 //
 // File extension is `.svelte.ts` because we use `$state` / `$effect` inside.
@@ -16,7 +17,11 @@ import {
   type HistoryMode,
   type SignalName,
 } from '@/route'
-import type { SearchResultEvent } from '@/types/api-types'
+import type {
+  PagedResults,
+  ResultPage,
+  SearchResultEvent,
+} from '@/types/api-types'
 import type { SearchEditorAPI } from '@/components/shared/Search/search-editor-api'
 import {
   beginListUpdate,
@@ -29,7 +34,8 @@ export type SortDirection = 'asc' | 'desc'
 export type SignalListPageOptions<TItem> = {
   signal: SignalName
   getItemID: (item: TItem) => string
-  fetchList: () => Promise<TItem[]>
+  /** The first page of the unfiltered list, and how to fetch the rest. */
+  fetchList: () => Promise<PagedResults<TItem>>
   compare: (
     a: TItem,
     b: TItem,
@@ -46,6 +52,9 @@ export type SignalListPageOptions<TItem> = {
 export type SignalListPage<TItem> = {
   readonly items: TItem[]
   readonly loading: boolean
+  /** More rows match than are loaded; loadMore fetches the next page. */
+  readonly hasMore: boolean
+  readonly loadingMore: boolean
   readonly error: string | null
   readonly mounted: boolean
   readonly sortColumn: string
@@ -65,6 +74,7 @@ export type SignalListPage<TItem> = {
   handleRefresh(): void
   handleSearchResults(event: SearchResultEvent): void
   runListFetch(): Promise<void>
+  loadMore(): Promise<void>
 }
 
 const POLL_INTERVAL_MS = 3000
@@ -118,6 +128,14 @@ export function createSignalListPage<TItem>(
 
   let lastValidIndex = $state(0)
 
+  // The showing list's next page: the cursor, the search to fetch it with,
+  // and the list update that produced the rows -- a page arriving after a
+  // newer fetch or search began belongs to a list no longer shown.
+  let nextCursor = $state<string | undefined>(undefined)
+  let fetchMore: ((cursor: string) => Promise<ResultPage<TItem>>) | null = null
+  let itemsSeq = 0
+  let loadingMore = $state(false)
+
   let selectedID = $derived(
     signalIdFromPath(opts.signal, routeContext.route.path)
   )
@@ -193,7 +211,7 @@ export function createSignalListPage<TItem>(
       error = null
       const next = await opts.fetchList()
       if (!isLatestListUpdate(opts.signal, updateSeq)) return
-      items = next
+      showFirstPage(next, updateSeq)
       updateRefreshIndicator()
     } catch (err) {
       if (!isLatestListUpdate(opts.signal, updateSeq)) return
@@ -238,10 +256,43 @@ export function createSignalListPage<TItem>(
     if (event.signal !== opts.signal) return
     if (!isLatestListUpdate(event.signal, event.updateSeq)) return
     error = null
-    items = event.results as TItem[]
+    showFirstPage(event.results as PagedResults<TItem>, event.updateSeq)
     loading = false
   }
 
+  function showFirstPage(page: PagedResults<TItem>, updateSeq: number) {
+    items = page.items
+    nextCursor = page.nextCursor
+    fetchMore = page.fetchMore
+    itemsSeq = updateSeq
+  }
+
+  async function loadMore() {
+    const cursor = nextCursor
+    const fetchPage = fetchMore
+    const seq = itemsSeq
+    if (!cursor || !fetchPage || loadingMore) return
+    loadingMore = true
+    try {
+      const page = await fetchPage(cursor)
+      if (!isLatestListUpdate(opts.signal, seq)) return
+      // A row's sort key can move between pages -- a trace starts at its
+      // earliest span, and a late one moves it -- so a row can arrive on
+      // both; the list shows it once.
+      const seen = new Set(items.map(opts.getItemID))
+      items = [
+        ...items,
+        ...page.items.filter(item => !seen.has(opts.getItemID(item))),
+      ]
+      nextCursor = page.nextCursor
+    } catch (err) {
+      if (!isLatestListUpdate(opts.signal, seq)) return
+      error = err instanceof Error ? err.message : 'Failed to load more'
+    } finally {
+      loadingMore = false
+    }
+  }
+
   onMount(() => {
     mounted = true
   })
@@ -257,6 +308,12 @@ export function createSignalListPage<TItem>(
     get loading() {
       return loading
     },
+    get hasMore() {
+      return nextCursor !== undefined
+    },
+    get loadingMore() {
+      return loadingMore
+    },
     get error() {
       return error
     },
@@ -301,5 +358,6 @@ export function createSignalListPage<TItem>(
     handleRefresh,
     handleSearchResults,
     runListFetch,
+    loadMore,
   }
 }
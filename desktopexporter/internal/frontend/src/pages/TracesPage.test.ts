@@ -59,6 +59,7 @@ function makeTraceSummary(overrides: Partial<TraceSummary> = {}): TraceSummary {
 
 function makeStats(): Stats {
   return {
+    readOnly: false,
     traces: {
       traceCount: 1,
       spanCount: 1,
@@ -113,7 +114,7 @@ function makeTraceData(unplacedSpanCount: number): TraceData {
 }
 
 async function renderSelectedTrace(unplacedSpanCount: number) {
-  searchTraces.mockResolvedValue([makeTraceSummary()])
+  searchTraces.mockResolvedValue({ items: [makeTraceSummary()] })
   getStats.mockResolvedValue(makeStats())
   searchSpans.mockResolvedValue(makeTraceData(unplacedSpanCount))
   setTestUrl('/traces/trace-1')
@@ -429,6 +429,46 @@ describe('request parameters', () => {
   // explicit and the return type honest, not by changing the request. The
   // distinction that does survive serialisation is null and [] -- both real
   // values, both sent -- which is what the getMetric tests cover.
+  // One request per call: the views page through a window on request, and a
+  // service that walked the cursor itself would load the whole window again.
+  it('searchTraces fetches one page and hands back its cursor', async () => {
+    const fetchMock = vi.fn().mockResolvedValue({
+      ok: true,
+      json: async () => ({
+        jsonrpc: '2.0',
+        id: 1,
+        result: { items: [], nextCursor: 'page-3' },
+      }),
+    })
+    vi.stubGlobal('fetch', fetchMock)
+
+    const page = await telemetryAPI.searchTraces(2, 5, undefined, 'page-2')
+
+    expect(fetchMock).toHaveBeenCalledTimes(1)
+    expect(JSON.parse(fetchMock.mock.calls[0][1].body).params).toEqual({
+      startTime: '2000000',
+      endTime: '5000000',
+      cursor: 'page-2',
+    })
+    expect(page).toEqual({ items: [], nextCursor: 'page-3' })
+  })
+
+  it('reports the last page without a cursor', async () => {
+    vi.stubGlobal(
+      'fetch',
+      vi.fn().mockResolvedValue({
+        ok: true,
+        json: async () => ({
+          jsonrpc: '2.0',
+          id: 1,
+          result: { items: [], nextCursor: null },
+        }),
+      })
+    )
+    const page = await telemetryAPI.searchLogs(2, 5)
+    expect(page.nextCursor).toBeUndefined()
+  })
+
   it('omits query entirely when no query tree is supplied', async () => {
     const sent = captureRequest()
     await telemetryAPI.searchTraces(2, 5).catch(() => {})
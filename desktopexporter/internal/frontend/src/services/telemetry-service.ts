@@ -14,6 +14,7 @@ import type {
   ScalarAggregate,
   ScalarViewBucket,
   MetricAggregateEnvelope,
+  ResultPage,
 } from '@/types/api-types'
 import type {
   JsonAttributeDefinition,
@@ -25,8 +26,14 @@ import type {
   JsonMetricData,
   JsonMetricSummary,
   JsonMetricTimeseries,
+  JsonPage,
   JsonStats,
   JsonTraceData,
+  JsonTraceDescription,
+  JsonServiceGraph,
+  JsonOperationStats,
+  JsonExportBundle,
+  JsonTailedSpan,
   JsonTraceSummary,
   JsonAttributeType,
   JsonQueryNode,
@@ -131,6 +138,27 @@ function named<T extends object>(params: T): Partial<T> {
   ) as Partial<T>
 }
 
+// The per-process token mutating methods must present. The Go server writes
+// it into index.html as <meta name="viewer-token">; under the Vite dev server
+// that index.html is Vite's own, so the tag is missing and the token comes
+// from GET /token instead, once. Sent on every call rather than only the
+// mutating ones so the transport does not need a copy of the server's list.
+let viewerToken: Promise<string> | undefined
+
+function getViewerToken(): Promise<string> {
+  if (viewerToken === undefined) {
+    const meta = document.querySelector<HTMLMetaElement>(
+      'meta[name="viewer-token"]'
+    )
+    viewerToken = meta?.content
+      ? Promise.resolve(meta.content)
+      : fetch('/token')
+          .then((res) => (res.ok ? res.text() : ''))
+          .catch(() => '')
+  }
+  return viewerToken
+}
+
 // Generic JSON-RPC transport. T is a compile-time assertion of the wire
 // shape (see wire-types.ts), not runtime validation -- the backend is
 // trusted to serve what its projections declare.
@@ -155,10 +183,12 @@ async function callRPC<T>(
 
   let response: Response
   try {
+    const token = await getViewerToken()
     response = await fetch('/rpc', {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
+        'X-Viewer-Token': token,
       },
       body: JSON.stringify(request),
       signal,
@@ -181,6 +211,20 @@ async function callRPC<T>(
   return data.result as T
 }
 
+// The search methods serve {items, nextCursor} pages, and this fetches one:
+// the first without a cursor, each after it with the cursor the one before
+// returned. Walking to the end here would hand the views the whole window
+// again -- the thing paging exists to avoid -- so the views keep the cursor
+// and ask for the next page when the user does.
+async function callPagedRPC<T>(
+  method: string,
+  params: Record<string, unknown>,
+  cursor?: string
+): Promise<ResultPage<T>> {
+  const page = await callRPC<JsonPage<T>>(method, named({ ...params, cursor }))
+  return { items: page.items, nextCursor: page.nextCursor ?? undefined }
+}
+
 // Data Transformation Functions
 
 // Helper functions to deserialize timestamps
@@ -376,6 +420,7 @@ function metricSummariesFromJSON(json: JsonMetricSummary[]): MetricSummary[] {
 
 function statsFromJSON(json: JsonStats): Stats {
   return {
+    readOnly: json.storage.readOnly,
     traces: {
       ...json.traces,
       lastReceived: parseNullableBigInt(json.traces.lastReceived),
@@ -483,20 +528,22 @@ export let telemetryAPI = {
   searchTraces: async (
     startTime: number,
     endTime: number,
-    queryTree?: QueryNode
-  ): Promise<TraceSummary[]> => {
+    queryTree?: QueryNode,
+    cursor?: string
+  ): Promise<ResultPage<TraceSummary>> => {
     const startTimeNs = toNanoseconds(startTime)
     const endTimeNs = toNanoseconds(endTime)
 
-    const rawData = await callRPC<JsonTraceSummary[]>(
+    const page = await callPagedRPC<JsonTraceSummary>(
       'searchTraces',
       named({
         startTime: startTimeNs,
         endTime: endTimeNs,
         query: queryTree && convertQueryTreeForBackend(queryTree),
-      })
+      }),
+      cursor
     )
-    return traceSummariesFromJSON(rawData)
+    return { ...page, items: traceSummariesFromJSON(page.items) }
   },
 
   // signal is plumbed here first because searchSpans is the heaviest query
@@ -530,19 +577,21 @@ export let telemetryAPI = {
   searchLogs: async (
     startTime: number,
     endTime: number,
-    queryTree?: QueryNode
-  ): Promise<LogSummary[]> => {
+    queryTree?: QueryNode,
+    cursor?: string
+  ): Promise<ResultPage<LogSummary>> => {
     const startTimeNs = toNanoseconds(startTime)
     const endTimeNs = toNanoseconds(endTime)
-    const rawData = await callRPC<JsonLogSummary[]>(
+    const page = await callPagedRPC<JsonLogSummary>(
       'searchLogs',
       named({
         startTime: startTimeNs,
         endTime: endTimeNs,
         query: queryTree && convertQueryTreeForBackend(queryTree),
-      })
+      }),
+      cursor
     )
-    return logSummariesFromJSON(rawData)
+    return { ...page, items: logSummariesFromJSON(page.items) }
   },
 
   getLog: async (logID: string): Promise<LogData> => {
@@ -558,19 +607,21 @@ export let telemetryAPI = {
   searchMetricSummaries: async (
     startTime: number,
     endTime: number,
-    queryTree?: QueryNode
-  ): Promise<MetricSummary[]> => {
+    queryTree?: QueryNode,
+    cursor?: string
+  ): Promise<ResultPage<MetricSummary>> => {
     const startTimeNs = toNanoseconds(startTime)
     const endTimeNs = toNanoseconds(endTime)
-    const rawData = await callRPC<JsonMetricSummary[]>(
+    const page = await callPagedRPC<JsonMetricSummary>(
       'searchMetricSummaries',
       named({
         startTime: startTimeNs,
         endTime: endTimeNs,
         query: queryTree && convertQueryTreeForBackend(queryTree),
-      })
+      }),
+      cursor
     )
-    return metricSummariesFromJSON(rawData)
+    return { ...page, items: metricSummariesFromJSON(page.items) }
   },
 
   getMetric: async (
@@ -770,6 +821,103 @@ export let telemetryAPI = {
   getTraceSpanCount: async (traceID: string): Promise<number> => {
     return await callRPC<number>('getTraceSpanCount', named({ traceID }))
   },
+
+  // Served as the wire shape: nothing in it needs reviving until a view
+  // reads the ns strings, and none does yet.
+  describeTrace: (traceID: string) =>
+    callRPC<JsonTraceDescription>('describeTrace', named({ traceID })),
+
+  getServiceGraph: (
+    startTime: number,
+    endTime: number,
+    queryTree?: QueryNode
+  ) =>
+    callRPC<JsonServiceGraph>(
+      'getServiceGraph',
+      named({
+        startTime: toNanoseconds(startTime),
+        endTime: toNanoseconds(endTime),
+        query: queryTree && convertQueryTreeForBackend(queryTree),
+      })
+    ),
+
+  getOperationStats: (
+    startTime: number,
+    endTime: number,
+    queryTree?: QueryNode,
+    groupBy?: ('service' | 'name' | 'kind')[],
+    targetBuckets?: number
+  ) =>
+    callRPC<JsonOperationStats>(
+      'getOperationStats',
+      named({
+        startTime: toNanoseconds(startTime),
+        endTime: toNanoseconds(endTime),
+        query: queryTree && convertQueryTreeForBackend(queryTree),
+        groupBy,
+        targetBuckets,
+      })
+    ),
+
+  // Resolves to the zip as a Blob plus the name the server suggests, ready
+  // for a download link. Window bounds are optional; traceIDs narrows the
+  // bundle to those traces and their logs.
+  exportBundle: async (options?: {
+    startTime?: number
+    endTime?: number
+    traceIDs?: string[]
+  }): Promise<{ fileName: string; blob: Blob }> => {
+    const raw = await callRPC<JsonExportBundle>(
+      'exportBundle',
+      named({
+        startTime:
+          options?.startTime !== undefined
+            ? toNanoseconds(options.startTime)
+            : undefined,
+        endTime:
+          options?.endTime !== undefined
+            ? toNanoseconds(options.endTime)
+            : undefined,
+        traceIDs: options?.traceIDs,
+      })
+    )
+    const bytes = Uint8Array.from(atob(raw.data), c => c.charCodeAt(0))
+    return {
+      fileName: raw.fileName,
+      blob: new Blob([bytes], { type: 'application/zip' }),
+    }
+  },
+}
+
+// Live tail over GET /stream. Records arrive in the same wire shape
+// searchLogs items use (logs) or as JsonTailedSpan (spans); `dropped` reports
+// records that arrived while this client was behind and were never checked.
+// EventSource reconnects by itself after a dropped connection, resuming from
+// whatever arrives next. Returns a function that closes the stream.
+export function tailTelemetry<S extends 'logs' | 'spans'>(
+  signal: S,
+  queryTree: QueryNode | undefined,
+  handlers: {
+    onRecords: (
+      records: S extends 'logs' ? JsonLogSummary[] : JsonTailedSpan[]
+    ) => void
+    onDropped?: (count: number) => void
+  }
+): () => void {
+  const params = new URLSearchParams({ signal })
+  if (queryTree) {
+    params.set('query', JSON.stringify(convertQueryTreeForBackend(queryTree)))
+  }
+  const source = new EventSource(`/stream?${params}`)
+  source.addEventListener(signal, event => {
+    handlers.onRecords(JSON.parse((event as MessageEvent<string>).data))
+  })
+  source.addEventListener('dropped', event => {
+    handlers.onDropped?.(
+      JSON.parse((event as MessageEvent<string>).data).count as number
+    )
+  })
+  return () => source.close()
 }
 
 // Helper function to convert frontend query tree to minimal backend format
@@ -457,32 +457,51 @@ export type MetricStats = {
 }
 
 export type Stats = {
+  // The viewer was started with --open on a capture: clearing and deleting
+  // are refused.
+  readOnly: boolean
   traces: TraceStats
   logs: LogStats
   metrics: MetricStats
 }
 
+// One page of searchTraces / searchLogs / searchMetricSummaries. nextCursor
+// is absent on the last page.
+export type ResultPage<T> = {
+  items: T[]
+  nextCursor?: string
+}
+
+// A first page, carrying the search that produced it: fetchMore repeats that
+// search -- same window, same query -- from a cursor. The list that shows the
+// page asks for the next one when the user does, without having to remember
+// which search it is showing.
+export type PagedResults<T> = ResultPage<T> & {
+  fetchMore: (cursor: string) => Promise<ResultPage<T>>
+}
+
 // Discriminated union for search results.
 // `queryTree` is the parsed query that produced these results (undefined when no search active).
-// The logs variant carries LogSummary[] -- the lightweight card-shaped
+// `results` is the first page only; the list loads the rest on request.
+// The logs variant carries LogSummary rows -- the lightweight card-shaped
 // projection. Full LogData for a single row is fetched on demand via
 // the getLog(id) JSON-RPC method.
 export type SearchResultEvent =
   | {
       signal: 'traces'
-      results: TraceSummary[]
+      results: PagedResults<TraceSummary>
       queryTree?: unknown
       updateSeq: number
     }
   | {
       signal: 'logs'
-      results: LogSummary[]
+      results: PagedResults<LogSummary>
       queryTree?: unknown
       updateSeq: number
     }
   | {
       signal: 'metrics'
-      results: MetricSummary[]
+      results: PagedResults<MetricSummary>
       queryTree?: unknown
       updateSeq: number
     }
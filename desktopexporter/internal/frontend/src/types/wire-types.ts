@@ -52,6 +52,84 @@ export type JsonTraceSummary = {
   errorCount: number
 }
 
+// describeTrace: a trace's facts without its spans. Durations and times are
+// int64 ns as strings, like the summary's; depth is tree levels (1 for a lone
+// span), and a slowest span's depth is null when it sits on a parent cycle.
+export type JsonTraceDescription = {
+  traceID: string
+  spanCount: number
+  errorCount: number
+  services: string[]
+  rootSpan: {
+    spanID: string
+    name: string
+    serviceName: string | null
+    durationNs: string
+  } | null
+  depth: number
+  startTime: string
+  endTime: string
+  durationNs: string
+  missingParentCount: number
+  unplacedSpanCount: number
+  slowestSpans: {
+    spanID: string
+    name: string
+    serviceName: string | null
+    statusCode: string
+    depth: number | null
+    startOffsetNs: string
+    durationNs: string
+  }[]
+}
+
+// getServiceGraph. A null service is a span with no service.name. latencyNs
+// holds percentiles of the callee's span duration, int64 ns as strings.
+export type JsonServiceGraph = {
+  nodes: { service: string | null; spanCount: number; errorCount: number }[]
+  edges: {
+    source: string | null
+    target: string | null
+    kind: 'call' | 'messaging'
+    callCount: number
+    errorCount: number
+    latencyNs: { p50: string; p90: string; p99: string }
+  }[]
+}
+
+// getOperationStats. Each group carries only the dimension keys that were
+// asked for. buckets is null unless targetBuckets was set.
+export type JsonOperationStats = {
+  windowNs: string | null
+  bucketWidthNs: string | null
+  groups: {
+    service?: string | null
+    name?: string
+    kind?: string
+    count: number
+    errorCount: number
+    errorRatio: number
+    ratePerSecond: number | null
+    durationNs: { p50: string; p90: string; p99: string }
+    buckets:
+      | {
+          start: string
+          count: number
+          errorCount: number
+          ratePerSecond: number
+          durationNs: { p50: string; p90: string; p99: string }
+        }[]
+      | null
+  }[]
+}
+
+// exportBundle's result. `data` is the zip, base64-encoded.
+export type JsonExportBundle = {
+  fileName: string
+  sizeBytes: number
+  data: string
+}
+
 export type JsonEventData = {
   name: string
   timestamp: string
@@ -178,6 +256,20 @@ export type JsonLogSummary = {
   bodyPreview: string
 }
 
+// One span from the /stream live tail. The span itself rather than a trace
+// summary, since the trace is still arriving.
+export type JsonTailedSpan = {
+  traceID: string
+  spanID: string
+  parentSpanID: string | null
+  name: string
+  kind: string
+  serviceName: string | null
+  statusCode: string
+  startTime: string
+  durationNs: string
+}
+
 export type JsonLogData = {
   id: string
   timestamp: string
@@ -536,6 +628,9 @@ export type JsonStats = {
   storage: {
     sizeBytes: number
     maxSizeBytes: number
+    // True when the viewer was started with --open: clear and delete
+    // methods answer -32012, so the UI should not offer them.
+    readOnly: boolean
   }
   traces: JsonTraceStats
   logs: JsonLogStats
@@ -604,6 +699,15 @@ export type JsonAttributeMatch = JsonAttributeDefinition & {
   sampleValues: string[]
 }
 
+// --- Paging ---
+
+// searchTraces / searchLogs / searchMetricSummaries. nextCursor is an opaque
+// token to pass back as `cursor`; null on the last page.
+export type JsonPage<T> = {
+  items: T[]
+  nextCursor: string | null
+}
+
 // --- Mutation results ---
 
 // deleteSpansByTraceID / deleteSpanByID / deleteLogByID.
@@ -0,0 +1,303 @@
+package legacytrace
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// jaegerDocument is what the Jaeger UI's "Download JSON" writes, and what
+// its query API's /api/traces answers with: the traces under data. A single
+// trace object on its own is accepted too.
+type jaegerDocument struct {
+	Data []jaegerTrace `json:"data"`
+}
+
+type jaegerTrace struct {
+	TraceID   string                   `json:"traceID"`
+	Spans     []jaegerSpan             `json:"spans"`
+	Processes map[string]jaegerProcess `json:"processes"`
+}
+
+type jaegerSpan struct {
+	TraceID       string            `json:"traceID"`
+	SpanID        string            `json:"spanID"`
+	OperationName string            `json:"operationName"`
+	References    []jaegerReference `json:"references"`
+	StartTime     uint64            `json:"startTime"`
+	Duration      uint64            `json:"duration"`
+	Tags          []jaegerTag       `json:"tags"`
+	Logs          []jaegerLog       `json:"logs"`
+	ProcessID     string            `json:"processID"`
+	// Process is set instead of ProcessID by some tools that flatten the
+	// document; Jaeger itself always uses the processes table.
+	Process *jaegerProcess `json:"process"`
+}
+
+type jaegerReference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+type jaegerTag struct {
+	Key   string          `json:"key"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+type jaegerLog struct {
+	Timestamp uint64      `json:"timestamp"`
+	Fields    []jaegerTag `json:"fields"`
+}
+
+type jaegerProcess struct {
+	ServiceName string      `json:"serviceName"`
+	Tags        []jaegerTag `json:"tags"`
+}
+
+// IsJaeger reports whether a JSON object's top-level keys look like a Jaeger
+// document -- traces under data, or one trace's spans and processes -- rather
+// than an OTLP request.
+func IsJaeger(keys map[string]json.RawMessage) bool {
+	if _, ok := keys["data"]; ok {
+		return true
+	}
+	_, spans := keys["spans"]
+	_, processes := keys["processes"]
+	return spans && processes
+}
+
+// Jaeger converts a Jaeger JSON document.
+//
+// A span's process is its resource: serviceName becomes service.name and
+// the process tags the other resource attributes, with one resource per
+// distinct process. The first CHILD_OF reference within the span's own trace
+// is its parent -- or, failing one, the first FOLLOWS_FROM, which Jaeger
+// draws as a parent too -- and every other reference becomes a link carrying
+// opentracing.ref_type, as the collector's Jaeger receiver records it. Logs
+// become events, named by their event field. Tags keep their declared types.
+func Jaeger(b []byte) (ptrace.Traces, error) {
+	var doc jaegerDocument
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return ptrace.Traces{}, fmt.Errorf("jaeger: %w", err)
+	}
+	if !IsJaeger(keys) {
+		return ptrace.Traces{}, errors.New("jaeger: want traces under data, or a trace's spans and processes")
+	}
+	if _, ok := keys["data"]; ok {
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return ptrace.Traces{}, fmt.Errorf("jaeger: %w", err)
+		}
+	} else {
+		var one jaegerTrace
+		if err := json.Unmarshal(b, &one); err != nil {
+			return ptrace.Traces{}, fmt.Errorf("jaeger: %w", err)
+		}
+		doc.Data = []jaegerTrace{one}
+	}
+
+	out := newBuilder()
+	for t, trace := range doc.Data {
+		for i, js := range trace.Spans {
+			span := ptrace.NewSpan()
+			if err := js.fill(span); err != nil {
+				return ptrace.Traces{}, fmt.Errorf("jaeger: trace %d: span %d: %w", t, i, err)
+			}
+			sc := liftTags(span)
+
+			process := js.Process
+			if process == nil {
+				p, ok := trace.Processes[js.ProcessID]
+				if !ok {
+					return ptrace.Traces{}, fmt.Errorf("jaeger: trace %d: span %d: no process %q", t, i, js.ProcessID)
+				}
+				process = &p
+			}
+			// Processes are per trace in the document, so the same service
+			// reappears under a new id in every trace; keyed by content, its
+			// spans share one resource.
+			key, err := json.Marshal(process)
+			if err != nil {
+				return ptrace.Traces{}, fmt.Errorf("jaeger: %w", err)
+			}
+			var fillErr error
+			out.add(string(key), func(res pcommon.Resource) {
+				if process.ServiceName != "" {
+					res.Attributes().PutStr("service.name", process.ServiceName)
+				}
+				fillErr = putTags(res.Attributes(), process.Tags)
+			}, sc, span)
+			if fillErr != nil {
+				return ptrace.Traces{}, fmt.Errorf("jaeger: trace %d: process %q: %w", t, js.ProcessID, fillErr)
+			}
+		}
+	}
+	return out.td, nil
+}
+
+func (js *jaegerSpan) fill(span ptrace.Span) error {
+	tid, err := traceID(js.TraceID)
+	if err != nil {
+		return fmt.Errorf("traceID: %w", err)
+	}
+	sid, err := spanID(js.SpanID)
+	if err != nil {
+		return fmt.Errorf("spanID: %w", err)
+	}
+	span.SetTraceID(tid)
+	span.SetSpanID(sid)
+	span.SetName(js.OperationName)
+	span.SetStartTimestamp(microsToTimestamp(js.StartTime))
+	span.SetEndTimestamp(microsToTimestamp(js.StartTime + js.Duration))
+
+	type ref struct {
+		traceID pcommon.TraceID
+		spanID  pcommon.SpanID
+		refType string
+	}
+	refs := make([]ref, 0, len(js.References))
+	for i, r := range js.References {
+		rtid, err := traceID(r.TraceID)
+		if err != nil {
+			return fmt.Errorf("reference %d traceID: %w", i, err)
+		}
+		rsid, err := spanID(r.SpanID)
+		if err != nil {
+			return fmt.Errorf("reference %d spanID: %w", i, err)
+		}
+		refs = append(refs, ref{rtid, rsid, r.RefType})
+	}
+	parent := -1
+	for _, want := range []string{"CHILD_OF", "FOLLOWS_FROM"} {
+		for i, r := range refs {
+			if parent < 0 && r.refType == want && r.traceID == tid {
+				parent = i
+			}
+		}
+	}
+	for i, r := range refs {
+		if i == parent {
+			span.SetParentSpanID(r.spanID)
+			continue
+		}
+		link := span.Links().AppendEmpty()
+		link.SetTraceID(r.traceID)
+		link.SetSpanID(r.spanID)
+		switch r.refType {
+		case "CHILD_OF":
+			link.Attributes().PutStr("opentracing.ref_type", "child_of")
+		case "FOLLOWS_FROM":
+			link.Attributes().PutStr("opentracing.ref_type", "follows_from")
+		}
+	}
+
+	if err := putTags(span.Attributes(), js.Tags); err != nil {
+		return err
+	}
+
+	for i, l := range js.Logs {
+		ev := span.Events().AppendEmpty()
+		ev.SetTimestamp(microsToTimestamp(l.Timestamp))
+		if err := putTags(ev.Attributes(), l.Fields); err != nil {
+			return fmt.Errorf("log %d: %w", i, err)
+		}
+		if name, ok := ev.Attributes().Get("event"); ok && name.Type() == pcommon.ValueTypeStr {
+			ev.SetName(name.Str())
+			ev.Attributes().Remove("event")
+		}
+	}
+	return nil
+}
+
+// putTags writes Jaeger tags as attributes of their declared type. A tag
+// with no type, or one this does not know, takes the JSON value's own.
+func putTags(attrs pcommon.Map, tags []jaegerTag) error {
+	for _, tag := range tags {
+		if err := putTag(attrs, tag); err != nil {
+			return fmt.Errorf("tag %q: %w", tag.Key, err)
+		}
+	}
+	return nil
+}
+
+func putTag(attrs pcommon.Map, tag jaegerTag) error {
+	// UseNumber, so an int64 past 2^53 arrives intact rather than rounded
+	// through float64.
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(tag.Value))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	// Jaeger's own JSON writes every value as its JSON type, but hand-built
+	// documents often quote numbers and booleans; both are accepted.
+	str, isStr := v.(string)
+	switch tag.Type {
+	case "string":
+		if !isStr {
+			return fmt.Errorf("string tag holds %s", tag.Value)
+		}
+		attrs.PutStr(tag.Key, str)
+	case "bool":
+		switch {
+		case v == true, str == "true":
+			attrs.PutBool(tag.Key, true)
+		case v == false, str == "false":
+			attrs.PutBool(tag.Key, false)
+		default:
+			return fmt.Errorf("bool tag holds %s", tag.Value)
+		}
+	case "int64":
+		n, err := strconv.ParseInt(numberText(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("int64 tag holds %s", tag.Value)
+		}
+		attrs.PutInt(tag.Key, n)
+	case "float64":
+		f, err := strconv.ParseFloat(numberText(v), 64)
+		if err != nil {
+			return fmt.Errorf("float64 tag holds %s", tag.Value)
+		}
+		attrs.PutDouble(tag.Key, f)
+	case "binary":
+		raw, err := base64.StdEncoding.DecodeString(str)
+		if !isStr || err != nil {
+			return fmt.Errorf("binary tag is not base64: %s", tag.Value)
+		}
+		attrs.PutEmptyBytes(tag.Key).FromRaw(raw)
+	default:
+		switch x := v.(type) {
+		case string:
+			attrs.PutStr(tag.Key, x)
+		case bool:
+			attrs.PutBool(tag.Key, x)
+		case json.Number:
+			if n, err := x.Int64(); err == nil {
+				attrs.PutInt(tag.Key, n)
+			} else if f, err := x.Float64(); err == nil {
+				attrs.PutDouble(tag.Key, f)
+			}
+		default:
+			attrs.PutStr(tag.Key, string(tag.Value))
+		}
+	}
+	return nil
+}
+
+func numberText(v any) string {
+	switch x := v.(type) {
+	case json.Number:
+		return x.String()
+	case string:
+		return x
+	}
+	return ""
+}
@@ -0,0 +1,190 @@
+// Package legacytrace converts the two pre-OTLP trace formats people still
+// have lying around into ptrace.Traces: Zipkin v2 JSON, which older services
+// report, and the JSON the Jaeger UI downloads a trace as, which is how a
+// trace gets passed around in a chat thread.
+//
+// Both come out the way the collector's own translators would hand them to an
+// exporter, so once converted a span goes through the same write path as one
+// received over OTLP. The conventions OTLP-aware tracers use to smuggle OTLP
+// fields through these formats are honoured: otel.status_code and
+// otel.status_description set the status, otel.scope.name and
+// otel.scope.version (or the older otel.library.*) the scope, and
+// w3c.tracestate the trace state. Those tags are consumed, not kept as
+// attributes, since they would only repeat what the span now says directly.
+package legacytrace
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// ErrInvalidID is a trace or span id that is not hex, is too long, or is all
+// zeros -- which OTLP reserves for "no id".
+var ErrInvalidID = errors.New("invalid trace or span id")
+
+// Tags that stand for an OTLP field rather than an attribute.
+const (
+	tagStatusCode        = "otel.status_code"
+	tagStatusDescription = "otel.status_description"
+	tagScopeName         = "otel.scope.name"
+	tagScopeVersion      = "otel.scope.version"
+	tagLibraryName       = "otel.library.name"
+	tagLibraryVersion    = "otel.library.version"
+	tagTraceState        = "w3c.tracestate"
+	tagSpanKind          = "span.kind"
+	tagError             = "error"
+)
+
+// traceID reads a hex trace id. Both formats allow a 64-bit id, and Jaeger
+// drops leading zeros, so a short id is the low end of a 128-bit one.
+func traceID(s string) (pcommon.TraceID, error) {
+	var id pcommon.TraceID
+	if err := decodeID(s, id[:]); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+func spanID(s string) (pcommon.SpanID, error) {
+	var id pcommon.SpanID
+	if err := decodeID(s, id[:]); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+func decodeID(s string, dest []byte) error {
+	if len(s) == 0 || len(s) > 2*len(dest) {
+		return fmt.Errorf("%w: %q", ErrInvalidID, s)
+	}
+	padded := strings.Repeat("0", 2*len(dest)-len(s)) + s
+	if _, err := hex.Decode(dest, []byte(padded)); err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidID, s)
+	}
+	for _, b := range dest {
+		if b != 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q is all zeros", ErrInvalidID, s)
+}
+
+// microsToTimestamp converts the microseconds both formats count in.
+func microsToTimestamp(us uint64) pcommon.Timestamp {
+	return pcommon.Timestamp(us * 1000)
+}
+
+// spanKinds maps both formats' kind spellings -- Zipkin's upper case, the
+// span.kind tag's lower -- to OTLP's.
+var spanKinds = map[string]ptrace.SpanKind{
+	"client":   ptrace.SpanKindClient,
+	"server":   ptrace.SpanKindServer,
+	"producer": ptrace.SpanKindProducer,
+	"consumer": ptrace.SpanKindConsumer,
+	"internal": ptrace.SpanKindInternal,
+}
+
+// scope is the instrumentation scope a span's tags named.
+type scope struct {
+	name, version string
+}
+
+// liftTags moves the OTLP-convention tags out of span's attributes and onto
+// the span, returning the scope they named. The status is decided in the
+// order OTLP exporters write it: an explicit otel.status_code wins, and a
+// bare error tag -- what OpenTracing and Zipkin instrumentation set -- only
+// marks the span failed when there is none. A Zipkin error tag carries the
+// message; a Jaeger one is usually just true.
+func liftTags(span ptrace.Span) scope {
+	attrs := span.Attributes()
+	take := func(key string) (string, bool) {
+		v, ok := attrs.Get(key)
+		if !ok {
+			return "", false
+		}
+		s := v.AsString()
+		attrs.Remove(key)
+		return s, true
+	}
+
+	if kind, ok := take(tagSpanKind); ok && span.Kind() == ptrace.SpanKindUnspecified {
+		span.SetKind(spanKinds[strings.ToLower(kind)])
+	}
+
+	code, hasCode := take(tagStatusCode)
+	description, _ := take(tagStatusDescription)
+	errValue, hasError := take(tagError)
+	switch {
+	case hasCode && strings.EqualFold(code, "ERROR"):
+		span.Status().SetCode(ptrace.StatusCodeError)
+		span.Status().SetMessage(description)
+	case hasCode && strings.EqualFold(code, "OK"):
+		span.Status().SetCode(ptrace.StatusCodeOk)
+	case hasError && !strings.EqualFold(errValue, "false"):
+		span.Status().SetCode(ptrace.StatusCodeError)
+		if description == "" && errValue != "" && !strings.EqualFold(errValue, "true") {
+			description = errValue
+		}
+		span.Status().SetMessage(description)
+	}
+
+	if state, ok := take(tagTraceState); ok {
+		span.TraceState().FromRaw(state)
+	}
+
+	var sc scope
+	if name, ok := take(tagScopeName); ok {
+		sc.name = name
+	}
+	if version, ok := take(tagScopeVersion); ok {
+		sc.version = version
+	}
+	if name, ok := take(tagLibraryName); ok && sc.name == "" {
+		sc.name = name
+	}
+	if version, ok := take(tagLibraryVersion); ok && sc.version == "" {
+		sc.version = version
+	}
+	return sc
+}
+
+// builder gathers converted spans under one ResourceSpans per resource and
+// one ScopeSpans per scope within it, in the order each was first seen.
+type builder struct {
+	td        ptrace.Traces
+	resources map[string]ptrace.ResourceSpans
+	scopes    map[string]ptrace.ScopeSpans
+}
+
+func newBuilder() *builder {
+	return &builder{
+		td:        ptrace.NewTraces(),
+		resources: map[string]ptrace.ResourceSpans{},
+		scopes:    map[string]ptrace.ScopeSpans{},
+	}
+}
+
+// add moves span under the resource resourceKey names, building the resource
+// with fill the first time the key is seen.
+func (b *builder) add(resourceKey string, fill func(pcommon.Resource), sc scope, span ptrace.Span) {
+	rs, ok := b.resources[resourceKey]
+	if !ok {
+		rs = b.td.ResourceSpans().AppendEmpty()
+		fill(rs.Resource())
+		b.resources[resourceKey] = rs
+	}
+	scopeKey := resourceKey + "\x00" + sc.name + "\x00" + sc.version
+	ss, ok := b.scopes[scopeKey]
+	if !ok {
+		ss = rs.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName(sc.name)
+		ss.Scope().SetVersion(sc.version)
+		b.scopes[scopeKey] = ss
+	}
+	span.MoveTo(ss.Spans().AppendEmpty())
+}
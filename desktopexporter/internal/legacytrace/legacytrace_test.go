@@ -0,0 +1,272 @@
+package legacytrace_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/legacytrace"
+)
+
+const zipkinSpans = `[
+  {
+    "traceId": "463ac35c9f6413ad48485a3953bb6124",
+    "id": "a2fb4a1d1a96d312",
+    "name": "get /api",
+    "kind": "SERVER",
+    "timestamp": 1700000000000000,
+    "duration": 2500,
+    "localEndpoint": {"serviceName": "frontend", "ipv4": "10.0.0.1", "port": 8080},
+    "remoteEndpoint": {"ipv6": "::1", "port": 51234},
+    "annotations": [{"timestamp": 1700000000001000, "value": "wr"}],
+    "tags": {"http.method": "GET", "error": "upstream timed out"}
+  },
+  {
+    "traceId": "48485a3953bb6124",
+    "id": "b7ad6b7169203331",
+    "parentId": "a2fb4a1d1a96d312",
+    "name": "query",
+    "kind": "CLIENT",
+    "timestamp": 1700000000000500,
+    "duration": 1000,
+    "localEndpoint": {"serviceName": "frontend"},
+    "remoteEndpoint": {"serviceName": "postgres"},
+    "tags": {"otel.scope.name": "db-instrumentation", "otel.scope.version": "1.2.0", "otel.status_code": "OK"}
+  },
+  {
+    "traceId": "463ac35c9f6413ad48485a3953bb6124",
+    "id": "0000000000000003",
+    "parentId": "a2fb4a1d1a96d312",
+    "name": "render",
+    "timestamp": 1700000000001500,
+    "duration": 10,
+    "localEndpoint": {"serviceName": "backend"}
+  }
+]`
+
+func attrs(m pcommon.Map) map[string]any {
+	return m.AsRaw()
+}
+
+func TestZipkin(t *testing.T) {
+	td, err := legacytrace.Zipkin([]byte(zipkinSpans))
+	require.NoError(t, err)
+	require.Equal(t, 3, td.SpanCount())
+	require.Equal(t, 2, td.ResourceSpans().Len(), "one resource per service")
+
+	frontend := td.ResourceSpans().At(0)
+	assert.Equal(t, map[string]any{"service.name": "frontend"}, attrs(frontend.Resource().Attributes()))
+	require.Equal(t, 2, frontend.ScopeSpans().Len(), "one scope per otel.scope.name")
+
+	server := frontend.ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, "463ac35c9f6413ad48485a3953bb6124", server.TraceID().String())
+	assert.Equal(t, "a2fb4a1d1a96d312", server.SpanID().String())
+	assert.True(t, server.ParentSpanID().IsEmpty())
+	assert.Equal(t, "get /api", server.Name())
+	assert.Equal(t, ptrace.SpanKindServer, server.Kind())
+	assert.Equal(t, pcommon.Timestamp(1_700_000_000_000_000_000), server.StartTimestamp())
+	assert.Equal(t, pcommon.Timestamp(1_700_000_000_002_500_000), server.EndTimestamp())
+	assert.Equal(t, ptrace.StatusCodeError, server.Status().Code())
+	assert.Equal(t, "upstream timed out", server.Status().Message(), "a Zipkin error tag is the message")
+	assert.Equal(t, map[string]any{
+		"http.method":           "GET",
+		"network.local.address": "10.0.0.1",
+		"network.local.port":    int64(8080),
+		"network.peer.address":  "::1",
+		"network.peer.port":     int64(51234),
+	}, attrs(server.Attributes()))
+	require.Equal(t, 1, server.Events().Len())
+	assert.Equal(t, "wr", server.Events().At(0).Name())
+	assert.Equal(t, pcommon.Timestamp(1_700_000_000_001_000_000), server.Events().At(0).Timestamp())
+
+	db := frontend.ScopeSpans().At(1)
+	assert.Equal(t, "db-instrumentation", db.Scope().Name())
+	assert.Equal(t, "1.2.0", db.Scope().Version())
+	client := db.Spans().At(0)
+	assert.Equal(t, "000000000000000048485a3953bb6124", client.TraceID().String(), "a 64-bit id is the low half")
+	assert.Equal(t, "a2fb4a1d1a96d312", client.ParentSpanID().String())
+	assert.Equal(t, ptrace.SpanKindClient, client.Kind())
+	assert.Equal(t, ptrace.StatusCodeOk, client.Status().Code())
+	assert.Equal(t, map[string]any{"peer.service": "postgres"}, attrs(client.Attributes()))
+
+	local := td.ResourceSpans().At(1).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, ptrace.SpanKindInternal, local.Kind(), "no kind is a local span")
+	assert.Equal(t, ptrace.StatusCodeUnset, local.Status().Code())
+}
+
+func TestZipkinSharedSpans(t *testing.T) {
+	const pair = `[
+		{"traceId": "463ac35c9f6413ad48485a3953bb6124", "id": "a2fb4a1d1a96d312", "parentId": "0020000000000001",
+		 "kind": "%s", "name": "get /api", "timestamp": 1700000000000000, "duration": 10, "localEndpoint": {"serviceName": "%s"}},
+		{"traceId": "463ac35c9f6413ad48485a3953bb6124", "id": "a2fb4a1d1a96d312", "parentId": "0020000000000001",
+		 "kind": "%s", "name": "get /api", "timestamp": 1700000000000002, "duration": 6, "localEndpoint": {"serviceName": "%s"}%s}
+	]`
+	shared := pcommon.SpanID{0xa2, 0xfb, 0x4a, 0x1d, 0x1a, 0x96, 0xd3, 0x12}
+
+	for name, body := range map[string]string{
+		"marked":            fmt.Sprintf(pair, "CLIENT", "frontend", "SERVER", "backend", `, "shared": true`),
+		"unmarked":          fmt.Sprintf(pair, "CLIENT", "frontend", "SERVER", "backend", ""),
+		"unmarked, reverse": fmt.Sprintf(pair, "SERVER", "backend", "CLIENT", "frontend", ""),
+	} {
+		td, err := legacytrace.Zipkin([]byte(body))
+		require.NoError(t, err, name)
+		require.Equal(t, 2, td.SpanCount(), name)
+
+		byService := map[string]ptrace.Span{}
+		for _, rs := range td.ResourceSpans().All() {
+			svc, _ := rs.Resource().Attributes().Get("service.name")
+			byService[svc.Str()] = rs.ScopeSpans().At(0).Spans().At(0)
+		}
+		client, server := byService["frontend"], byService["backend"]
+		assert.Equal(t, shared, client.SpanID(), name)
+		assert.Equal(t, "0020000000000001", client.ParentSpanID().String(), name)
+		assert.Equal(t, legacytrace.UnsharedSpanID(client.TraceID(), shared), server.SpanID(), name)
+		assert.NotEqual(t, shared, server.SpanID(), name)
+		assert.Equal(t, shared, server.ParentSpanID(), "%s: the server half is under its client", name)
+	}
+}
+
+func TestZipkinRejects(t *testing.T) {
+	for name, doc := range map[string]string{
+		"not a list":    `{"traceId": "1"}`,
+		"bad trace id":  `[{"traceId": "xyz", "id": "1"}]`,
+		"zero span id":  `[{"traceId": "1", "id": "0000000000000000"}]`,
+		"long span id":  `[{"traceId": "1", "id": "00000000000000001"}]`,
+		"unknown kind":  `[{"traceId": "1", "id": "1", "kind": "SIDEWAYS"}]`,
+		"bad parent id": `[{"traceId": "1", "id": "1", "parentId": "nope"}]`,
+	} {
+		_, err := legacytrace.Zipkin([]byte(doc))
+		assert.Error(t, err, name)
+	}
+	_, err := legacytrace.Zipkin([]byte(`[{"traceId": "1", "id": "0"}]`))
+	assert.ErrorIs(t, err, legacytrace.ErrInvalidID)
+}
+
+const jaegerDownload = `{
+  "data": [{
+    "traceID": "48485a3953bb6124",
+    "spans": [
+      {
+        "traceID": "48485a3953bb6124",
+        "spanID": "a2fb4a1d1a96d312",
+        "operationName": "HTTP GET /cart",
+        "references": [],
+        "startTime": 1700000000000000,
+        "duration": 4000,
+        "tags": [
+          {"key": "span.kind", "type": "string", "value": "server"},
+          {"key": "http.status_code", "type": "int64", "value": 9007199254740993},
+          {"key": "error", "type": "bool", "value": true},
+          {"key": "otel.status_description", "type": "string", "value": "boom"},
+          {"key": "sampler.param", "type": "float64", "value": 0.5},
+          {"key": "payload", "type": "binary", "value": "AQI="},
+          {"key": "w3c.tracestate", "type": "string", "value": "vendor=1"}
+        ],
+        "logs": [
+          {"timestamp": 1700000000001000, "fields": [
+            {"key": "event", "type": "string", "value": "cache miss"},
+            {"key": "key", "type": "string", "value": "cart:42"}
+          ]}
+        ],
+        "processID": "p1"
+      },
+      {
+        "traceID": "48485a3953bb6124",
+        "spanID": "b7ad6b7169203331",
+        "operationName": "redis GET",
+        "references": [
+          {"refType": "FOLLOWS_FROM", "traceID": "0000000000000000000000000000abcd", "spanID": "1"},
+          {"refType": "CHILD_OF", "traceID": "000000000000000048485a3953bb6124", "spanID": "a2fb4a1d1a96d312"}
+        ],
+        "startTime": 1700000000000500,
+        "duration": 300,
+        "tags": [
+          {"key": "span.kind", "type": "string", "value": "client"},
+          {"key": "otel.library.name", "type": "string", "value": "go-redis"}
+        ],
+        "logs": [],
+        "processID": "p2"
+      }
+    ],
+    "processes": {
+      "p1": {"serviceName": "cart", "tags": [{"key": "hostname", "type": "string", "value": "box"}]},
+      "p2": {"serviceName": "cart", "tags": [{"key": "hostname", "type": "string", "value": "box"}]}
+    }
+  }],
+  "total": 0, "limit": 0, "offset": 0, "errors": null
+}`
+
+func TestJaeger(t *testing.T) {
+	td, err := legacytrace.Jaeger([]byte(jaegerDownload))
+	require.NoError(t, err)
+	require.Equal(t, 2, td.SpanCount())
+	require.Equal(t, 1, td.ResourceSpans().Len(), "identical processes are one resource")
+
+	rs := td.ResourceSpans().At(0)
+	assert.Equal(t, map[string]any{"service.name": "cart", "hostname": "box"}, attrs(rs.Resource().Attributes()))
+	require.Equal(t, 2, rs.ScopeSpans().Len())
+
+	server := rs.ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, "000000000000000048485a3953bb6124", server.TraceID().String())
+	assert.Equal(t, ptrace.SpanKindServer, server.Kind())
+	assert.Equal(t, ptrace.StatusCodeError, server.Status().Code())
+	assert.Equal(t, "boom", server.Status().Message())
+	assert.Equal(t, "vendor=1", server.TraceState().AsRaw())
+	assert.Equal(t, pcommon.Timestamp(1_700_000_000_004_000_000), server.EndTimestamp())
+	status, _ := server.Attributes().Get("http.status_code")
+	assert.Equal(t, int64(9007199254740993), status.Int(), "int64 tags are not rounded through float64")
+	param, _ := server.Attributes().Get("sampler.param")
+	assert.Equal(t, 0.5, param.Double())
+	payload, _ := server.Attributes().Get("payload")
+	assert.Equal(t, []byte{1, 2}, payload.Bytes().AsRaw())
+	assert.Equal(t, 3, server.Attributes().Len(), "the convention tags are consumed")
+
+	require.Equal(t, 1, server.Events().Len())
+	assert.Equal(t, "cache miss", server.Events().At(0).Name())
+	assert.Equal(t, map[string]any{"key": "cart:42"}, attrs(server.Events().At(0).Attributes()))
+
+	redis := rs.ScopeSpans().At(1)
+	assert.Equal(t, "go-redis", redis.Scope().Name())
+	client := redis.Spans().At(0)
+	assert.Equal(t, ptrace.SpanKindClient, client.Kind())
+	assert.Equal(t, "a2fb4a1d1a96d312", client.ParentSpanID().String(), "CHILD_OF in the same trace is the parent")
+	require.Equal(t, 1, client.Links().Len())
+	link := client.Links().At(0)
+	assert.Equal(t, "0000000000000000000000000000abcd", link.TraceID().String())
+	assert.Equal(t, "0000000000000001", link.SpanID().String())
+	assert.Equal(t, map[string]any{"opentracing.ref_type": "follows_from"}, attrs(link.Attributes()))
+}
+
+// A single trace object, as some tools save it, and a span whose only
+// reference is FOLLOWS_FROM, which is then its parent.
+func TestJaegerSingleTrace(t *testing.T) {
+	td, err := legacytrace.Jaeger([]byte(`{
+	  "traceID": "1",
+	  "spans": [{"traceID": "1", "spanID": "2", "operationName": "consume", "startTime": 1, "duration": 1,
+	             "references": [{"refType": "FOLLOWS_FROM", "traceID": "1", "spanID": "3"}],
+	             "tags": [{"key": "untyped", "value": 7}], "processID": "p1"}],
+	  "processes": {"p1": {"serviceName": "worker"}}
+	}`))
+	require.NoError(t, err)
+	span := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, "0000000000000003", span.ParentSpanID().String())
+	assert.Equal(t, 0, span.Links().Len())
+	assert.Equal(t, map[string]any{"untyped": int64(7)}, attrs(span.Attributes()))
+}
+
+func TestJaegerRejects(t *testing.T) {
+	for name, doc := range map[string]string{
+		"OTLP":            `{"resourceSpans": []}`,
+		"missing process": `{"data": [{"spans": [{"traceID": "1", "spanID": "2", "processID": "p9"}], "processes": {}}]}`,
+		"bad span id":     `{"data": [{"spans": [{"traceID": "1", "spanID": "zz", "processID": "p1"}], "processes": {"p1": {}}}]}`,
+		"mistyped tag": `{"data": [{"spans": [{"traceID": "1", "spanID": "2", "processID": "p1",
+			"tags": [{"key": "n", "type": "int64", "value": "many"}]}], "processes": {"p1": {}}}]}`,
+	} {
+		_, err := legacytrace.Jaeger([]byte(doc))
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,221 @@
+package legacytrace
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// zipkinSpan is one element of a Zipkin v2 JSON span list, as POSTed to
+// /api/v2/spans. Timestamps and durations are microseconds.
+type zipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId"`
+	Name           string             `json:"name"`
+	Kind           string             `json:"kind"`
+	Timestamp      uint64             `json:"timestamp"`
+	Duration       uint64             `json:"duration"`
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint"`
+	Annotations    []zipkinAnnotation `json:"annotations"`
+	Tags           map[string]string  `json:"tags"`
+	// Shared marks the server half of a span the client started: both
+	// halves carry the client's id.
+	Shared bool `json:"shared"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int64  `json:"port"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp uint64 `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// Zipkin converts a Zipkin v2 JSON span list.
+//
+// A span's localEndpoint is its service: its serviceName becomes the
+// resource's service.name, and spans are grouped into one resource per
+// service. The endpoint addresses go on the span, local and remote, under the
+// network.* names; the remote serviceName becomes peer.service. Annotations
+// become events named by their value. A span with no kind is Zipkin's "local"
+// span, which is OTLP's internal one.
+//
+// Zipkin's shared spans -- a server reusing its client's span id -- become two
+// spans, since the store keys spans by id: the server half is moved to an id
+// of its own, under the client. See Unshare. The half moved is the one marked
+// shared; failing the mark, of two spans repeating a trace and span id in the
+// batch, the server one, or the later when neither is. A half whose client
+// came in an earlier batch is the caller's to catch, against the store.
+func Zipkin(b []byte) (ptrace.Traces, error) {
+	var spans []zipkinSpan
+	if err := json.Unmarshal(b, &spans); err != nil {
+		return ptrace.Traces{}, fmt.Errorf("zipkin: %w", err)
+	}
+
+	converted := make([]ptrace.Span, len(spans))
+	for i, zs := range spans {
+		converted[i] = ptrace.NewSpan()
+		if err := zs.fill(converted[i]); err != nil {
+			return ptrace.Traces{}, fmt.Errorf("zipkin: span %d: %w", i, err)
+		}
+	}
+	for _, i := range sharedHalves(spans, converted) {
+		Unshare(converted[i])
+	}
+
+	out := newBuilder()
+	for i, zs := range spans {
+		span := converted[i]
+		sc := liftTags(span)
+		if span.Kind() == ptrace.SpanKindUnspecified {
+			span.SetKind(ptrace.SpanKindInternal)
+		}
+
+		service := ""
+		if zs.LocalEndpoint != nil {
+			service = zs.LocalEndpoint.ServiceName
+		}
+		out.add(service, func(res pcommon.Resource) {
+			if service != "" {
+				res.Attributes().PutStr("service.name", service)
+			}
+		}, sc, span)
+	}
+	return out.td, nil
+}
+
+// sharedHalves returns the positions of the spans Zipkin converts by moving
+// them off a shared id. Keyed by the parsed ids, not the strings: a 64-bit
+// trace id and its zero-padded 128-bit form are the same trace.
+func sharedHalves(spans []zipkinSpan, converted []ptrace.Span) []int {
+	type key struct {
+		trace pcommon.TraceID
+		span  pcommon.SpanID
+	}
+	var moved []int
+	first := map[key]int{}
+	for i, span := range converted {
+		if spans[i].Shared {
+			moved = append(moved, i)
+			continue
+		}
+		k := key{span.TraceID(), span.SpanID()}
+		j, seen := first[k]
+		switch {
+		case !seen:
+			first[k] = i
+		case converted[j].Kind() == ptrace.SpanKindServer:
+			moved = append(moved, j)
+			first[k] = i
+		default:
+			moved = append(moved, i)
+		}
+	}
+	return moved
+}
+
+// Unshare moves the server half of a shared span to an id of its own, with
+// the shared id -- the client's -- as its parent. The new id is derived from
+// the trace and the shared id rather than drawn at random, so a reporter
+// retrying the batch moves the half to the same id again.
+//
+// The server's children keep the shared id as their parent, and hang under
+// the client; Zipkin never said which half they belong to.
+func Unshare(span ptrace.Span) {
+	shared := span.SpanID()
+	span.SetParentSpanID(shared)
+	span.SetSpanID(UnsharedSpanID(span.TraceID(), shared))
+}
+
+// UnsharedSpanID is the id Unshare moves a server half sharing id to.
+func UnsharedSpanID(trace pcommon.TraceID, shared pcommon.SpanID) pcommon.SpanID {
+	h := fnv.New64a()
+	h.Write(trace[:])
+	h.Write(shared[:])
+	h.Write([]byte("zipkin shared server"))
+	var id pcommon.SpanID
+	binary.BigEndian.PutUint64(id[:], h.Sum64())
+	return id
+}
+
+func (zs *zipkinSpan) fill(span ptrace.Span) error {
+	tid, err := traceID(zs.TraceID)
+	if err != nil {
+		return fmt.Errorf("traceId: %w", err)
+	}
+	sid, err := spanID(zs.ID)
+	if err != nil {
+		return fmt.Errorf("id: %w", err)
+	}
+	span.SetTraceID(tid)
+	span.SetSpanID(sid)
+	if zs.ParentID != "" {
+		pid, err := spanID(zs.ParentID)
+		if err != nil {
+			return fmt.Errorf("parentId: %w", err)
+		}
+		span.SetParentSpanID(pid)
+	}
+
+	span.SetName(zs.Name)
+	if zs.Kind != "" {
+		kind, ok := spanKinds[strings.ToLower(zs.Kind)]
+		if !ok {
+			return fmt.Errorf("kind %q is not CLIENT, SERVER, PRODUCER or CONSUMER", zs.Kind)
+		}
+		span.SetKind(kind)
+	}
+	span.SetStartTimestamp(microsToTimestamp(zs.Timestamp))
+	span.SetEndTimestamp(microsToTimestamp(zs.Timestamp + zs.Duration))
+
+	attrs := span.Attributes()
+	for _, k := range slices.Sorted(maps.Keys(zs.Tags)) {
+		attrs.PutStr(k, zs.Tags[k])
+	}
+	if ep := zs.LocalEndpoint; ep != nil {
+		putAddress(attrs, "network.local.address", ep.IPv4, ep.IPv6)
+		if ep.Port != 0 {
+			attrs.PutInt("network.local.port", ep.Port)
+		}
+	}
+	if ep := zs.RemoteEndpoint; ep != nil {
+		if ep.ServiceName != "" {
+			attrs.PutStr("peer.service", ep.ServiceName)
+		}
+		putAddress(attrs, "network.peer.address", ep.IPv4, ep.IPv6)
+		if ep.Port != 0 {
+			attrs.PutInt("network.peer.port", ep.Port)
+		}
+	}
+
+	for _, a := range zs.Annotations {
+		ev := span.Events().AppendEmpty()
+		ev.SetName(a.Value)
+		ev.SetTimestamp(microsToTimestamp(a.Timestamp))
+	}
+	return nil
+}
+
+// putAddress records an endpoint's address, preferring IPv4 when an endpoint
+// has both, as Zipkin's own UI does.
+func putAddress(attrs pcommon.Map, key, ipv4, ipv6 string) {
+	switch {
+	case ipv4 != "":
+		attrs.PutStr(key, ipv4)
+	case ipv6 != "":
+		attrs.PutStr(key, ipv6)
+	}
+}
@@ -0,0 +1,233 @@
+package promql
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticSource is a Queryable over fixed series.
+type staticSource []Series
+
+func (s staticSource) Select(_ context.Context, matchers []*Matcher, mint, maxt int64) ([]Series, error) {
+	var out []Series
+	for _, series := range s {
+		if !series.Labels.matches(matchers) {
+			continue
+		}
+		var points []Point
+		for _, p := range series.Points {
+			if p.T >= mint && p.T <= maxt {
+				points = append(points, p)
+			}
+		}
+		out = append(out, Series{Labels: series.Labels, Points: points})
+	}
+	return out, nil
+}
+
+func labels(pairs ...string) Labels {
+	var ls Labels
+	for i := 0; i < len(pairs); i += 2 {
+		ls = append(ls, Label{Name: pairs[i], Value: pairs[i+1]})
+	}
+	return ls
+}
+
+// every15s is a series sampled every 15s from t=0 with the given values.
+func every15s(ls Labels, values ...float64) Series {
+	s := Series{Labels: ls}
+	for i, v := range values {
+		s.Points = append(s.Points, Point{T: int64(i) * 15_000, V: v})
+	}
+	return s
+}
+
+func at(seconds int64) time.Time {
+	return time.UnixMilli(seconds * 1000)
+}
+
+func instant(t *testing.T, src Queryable, query string, ts time.Time) Value {
+	t.Helper()
+	v, err := Instant(context.Background(), src, query, ts)
+	require.NoError(t, err, query)
+	return v
+}
+
+func TestInstantScalar(t *testing.T) {
+	v := instant(t, staticSource{}, "1 + 2 * 3", at(10))
+	assert.Equal(t, Scalar{T: 10_000, V: 7}, v)
+}
+
+// An instant selector takes each series' latest point, unless it is older
+// than the lookback.
+func TestInstantSelectorLookback(t *testing.T) {
+	src := staticSource{
+		every15s(labels("__name__", "up", "job", "a"), 1, 1, 0),
+	}
+	v := instant(t, src, "up", at(40))
+	assert.Equal(t, Vector{{Labels: labels("__name__", "up", "job", "a"), Point: Point{T: 40_000, V: 0}}}, v)
+
+	v = instant(t, src, "up", at(30+300))
+	assert.Empty(t, v, "five minutes after the last point the series is stale")
+}
+
+func TestRateAndIncrease(t *testing.T) {
+	// A counter going up 15 every 15s, with a reset to 0 after 60.
+	src := staticSource{
+		every15s(labels("__name__", "reqs_total", "job", "a"), 0, 15, 30, 45, 60, 0, 15, 30, 45),
+	}
+	// The 60s window (60, 120] holds points at 75..120: 0, 15, 30, 45 after
+	// the reset. A counter at 0 started inside the window, so there is
+	// nothing to extrapolate back to: 45 over 60s.
+	v := instant(t, src, "rate(reqs_total[1m])", at(120))
+	require.Len(t, v, 1)
+	assert.Equal(t, labels("job", "a"), v.(Vector)[0].Labels, "functions drop the metric name")
+	assert.InDelta(t, 0.75, v.(Vector)[0].V, 1e-9)
+
+	// Over (0, 120] the reset is corrected for: 15..60 then 0..45 is
+	// 45 + 45 = 90 over the seven intervals sampled, extrapolated to the
+	// eighth before the first point.
+	v = instant(t, src, "increase(reqs_total[2m])", at(120))
+	require.Len(t, v, 1)
+	assert.InDelta(t, 90.0*8/7, v.(Vector)[0].V, 1e-9)
+
+	// One point in the window has no rate.
+	v = instant(t, src, "rate(reqs_total[15s])", at(120))
+	assert.Empty(t, v)
+
+	// Nor do two at the same timestamp: no interval, not an Inf or NaN.
+	same := staticSource{{
+		Labels: labels("__name__", "reqs_total", "job", "a"),
+		Points: []Point{{T: 100_000, V: 10}, {T: 100_000, V: 25}},
+	}}
+	for _, query := range []string{"rate(reqs_total[1m])", "increase(reqs_total[1m])"} {
+		v = instant(t, same, query, at(120))
+		assert.Empty(t, v, query)
+	}
+}
+
+func TestAggregations(t *testing.T) {
+	src := staticSource{
+		every15s(labels("__name__", "x", "instance", "1", "job", "a"), 1),
+		every15s(labels("__name__", "x", "instance", "2", "job", "a"), 3),
+		every15s(labels("__name__", "x", "instance", "1", "job", "b"), 10),
+	}
+	for query, want := range map[string]Vector{
+		"sum(x)": {{Labels: Labels{}, Point: Point{V: 14}}},
+		"sum by (job) (x)": {
+			{Labels: labels("job", "a"), Point: Point{V: 4}},
+			{Labels: labels("job", "b"), Point: Point{V: 10}},
+		},
+		"avg(x) by (job)": {
+			{Labels: labels("job", "a"), Point: Point{V: 2}},
+			{Labels: labels("job", "b"), Point: Point{V: 10}},
+		},
+		"max without (instance) (x)": {
+			{Labels: labels("job", "a"), Point: Point{V: 3}},
+			{Labels: labels("job", "b"), Point: Point{V: 10}},
+		},
+		"min by (instance) (x)": {
+			{Labels: labels("instance", "1"), Point: Point{V: 1}},
+			{Labels: labels("instance", "2"), Point: Point{V: 3}},
+		},
+		"count(x)": {{Labels: Labels{}, Point: Point{V: 3}}},
+	} {
+		assert.Equal(t, want, instant(t, src, query, at(0)), query)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	src := staticSource{
+		every15s(labels("__name__", "errors", "job", "a"), 5),
+		every15s(labels("__name__", "errors", "job", "b"), 1),
+		every15s(labels("__name__", "requests", "job", "a"), 50),
+		every15s(labels("__name__", "requests", "job", "c"), 7),
+	}
+	// Vector to vector matches on every label but the name; job b and c
+	// have no partner and drop out.
+	v := instant(t, src, "errors / requests * 100", at(0))
+	assert.Equal(t, Vector{{Labels: labels("job", "a"), Point: Point{V: 10}}}, v)
+
+	v = instant(t, src, "-errors", at(0))
+	assert.Equal(t, Vector{
+		{Labels: labels("job", "a"), Point: Point{V: -5}},
+		{Labels: labels("job", "b"), Point: Point{V: -1}},
+	}, v)
+
+	// Dropping the name makes the two metrics one label set.
+	_, err := Instant(context.Background(), src, `{job="a"} * 2`, at(0))
+	require.ErrorIs(t, err, ErrBadQuery)
+	assert.Contains(t, err.Error(), "same labelset")
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	bucket := func(le string, v float64) Series {
+		return every15s(labels("__name__", "latency_bucket", "job", "a", "le", le), v)
+	}
+	src := staticSource{
+		bucket("0.1", 50),
+		bucket("0.5", 90),
+		bucket("1", 100),
+		bucket("+Inf", 100),
+	}
+	// The median is the 50th observation: the top of the first bucket.
+	v := instant(t, src, "histogram_quantile(0.5, latency_bucket)", at(0))
+	assert.Equal(t, Vector{{Labels: labels("job", "a"), Point: Point{V: 0.1}}}, v)
+
+	// The 70th is halfway through (0.1, 0.5].
+	v = instant(t, src, "histogram_quantile(0.7, latency_bucket)", at(0))
+	require.Len(t, v, 1)
+	assert.InDelta(t, 0.3, v.(Vector)[0].V, 1e-9)
+
+	// Out of range quantiles are infinities, as in Prometheus.
+	v = instant(t, src, "histogram_quantile(2, latency_bucket)", at(0))
+	assert.True(t, math.IsInf(v.(Vector)[0].V, 1))
+}
+
+func TestRangeQuery(t *testing.T) {
+	src := staticSource{
+		every15s(labels("__name__", "up", "job", "a"), 1, 2, 3, 4),
+	}
+	m, err := Range(context.Background(), src, "up * 10", at(0), at(60), 30*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, Matrix{{
+		Labels: labels("job", "a"),
+		Points: []Point{{T: 0, V: 10}, {T: 30_000, V: 30}, {T: 60_000, V: 40}},
+	}}, m)
+
+	m, err = Range(context.Background(), src, "1", at(0), at(30), 15*time.Second)
+	require.NoError(t, err)
+	require.Len(t, m, 1)
+	assert.Len(t, m[0].Points, 3)
+
+	_, err = Range(context.Background(), src, "up[1m]", at(0), at(60), time.Second)
+	assert.ErrorIs(t, err, ErrBadQuery)
+}
+
+// Values are encoded as the API encodes them: seconds, and the value as a
+// string so NaN and the infinities survive.
+func TestValueJSON(t *testing.T) {
+	b, err := json.Marshal(Vector{
+		{Labels: labels("job", "a"), Point: Point{T: 1_500, V: math.NaN()}},
+		{Labels: labels("job", "b"), Point: Point{T: 2_000, V: math.Inf(1)}},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"metric": {"job": "a"}, "value": [1.5, "NaN"]},
+		{"metric": {"job": "b"}, "value": [2, "+Inf"]}
+	]`, string(b))
+
+	b, err = json.Marshal(Matrix(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(b))
+
+	b, err = json.Marshal(Scalar{T: 1_000, V: 2})
+	require.NoError(t, err)
+	assert.Equal(t, `[1,"2"]`, string(b))
+}
@@ -0,0 +1,561 @@
+package promql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LookbackDelta is how far back an instant selector looks for a series' latest
+// sample, as in Prometheus: a series that stopped reporting drops out of a
+// query five minutes after its last point.
+const LookbackDelta = 5 * time.Minute
+
+// Label is one name-value pair of a series.
+type Label struct {
+	Name, Value string
+}
+
+// Labels is a label set, sorted by name.
+type Labels []Label
+
+// Get returns a label's value, or "" if it is not set.
+func (ls Labels) Get(name string) string {
+	for _, l := range ls {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// String renders the set as {a="b", c="d"}. Two sets are equal exactly when
+// their strings are, which is what series are grouped and matched by.
+func (ls Labels) String() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range ls {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(l.Name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(l.Value))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// MarshalJSON writes the set as an object, as the Prometheus API does.
+func (ls Labels) MarshalJSON() ([]byte, error) {
+	m := make(map[string]string, len(ls))
+	for _, l := range ls {
+		m[l.Name] = l.Value
+	}
+	return json.Marshal(m)
+}
+
+func (ls Labels) matches(matchers []*Matcher) bool {
+	for _, m := range matchers {
+		if !m.Matches(ls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// filter keeps the labels keep says to, in order.
+func (ls Labels) filter(keep func(name string) bool) Labels {
+	out := make(Labels, 0, len(ls))
+	for _, l := range ls {
+		if keep(l.Name) {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+func (ls Labels) dropName() Labels {
+	return ls.filter(func(name string) bool { return name != nameLabel })
+}
+
+// Point is one sample. T is in milliseconds, as everywhere in this package.
+type Point struct {
+	T int64
+	V float64
+}
+
+// MarshalJSON writes [seconds, "value"], the API's sample encoding. The value
+// is a string so NaN and the infinities survive JSON.
+func (p Point) MarshalJSON() ([]byte, error) {
+	b := []byte{'['}
+	b = strconv.AppendFloat(b, float64(p.T)/1000, 'f', -1, 64)
+	b = append(b, ',')
+	b = strconv.AppendQuote(b, strconv.FormatFloat(p.V, 'f', -1, 64))
+	return append(b, ']'), nil
+}
+
+// Series is a label set and its points in time order: a range vector's
+// element, and a query range result's.
+type Series struct {
+	Labels Labels
+	Points []Point
+}
+
+// MarshalJSON writes {"metric": ..., "values": [...]}.
+func (s Series) MarshalJSON() ([]byte, error) {
+	points := s.Points
+	if points == nil {
+		points = []Point{}
+	}
+	return json.Marshal(struct {
+		Metric Labels  `json:"metric"`
+		Values []Point `json:"values"`
+	}{s.Labels, points})
+}
+
+// Sample is an instant vector's element.
+type Sample struct {
+	Labels Labels
+	Point
+}
+
+// MarshalJSON writes {"metric": ..., "value": [...]}.
+func (s Sample) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Metric Labels `json:"metric"`
+		Value  Point  `json:"value"`
+	}{s.Labels, s.Point})
+}
+
+// Value is a query's result: a Scalar, a Vector or a Matrix.
+type Value interface {
+	// Type is the API's resultType.
+	Type() string
+}
+
+// Scalar is a number at a time.
+type Scalar Point
+
+// Vector is a set of series at one time.
+type Vector []Sample
+
+// Matrix is a set of series over a span of time.
+type Matrix []Series
+
+func (Scalar) Type() string { return "scalar" }
+func (Vector) Type() string { return "vector" }
+func (Matrix) Type() string { return "matrix" }
+
+// MarshalJSON writes a scalar as a bare sample.
+func (s Scalar) MarshalJSON() ([]byte, error) {
+	return Point(s).MarshalJSON()
+}
+
+// MarshalJSON writes an empty vector as [] rather than null.
+func (v Vector) MarshalJSON() ([]byte, error) {
+	if v == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]Sample(v))
+}
+
+// MarshalJSON writes an empty matrix as [] rather than null.
+func (m Matrix) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]Series(m))
+}
+
+// Queryable is where selectors read series from.
+type Queryable interface {
+	// Select returns the series matching every matcher, with their points
+	// in [mint, maxt].
+	Select(ctx context.Context, matchers []*Matcher, mint, maxt int64) ([]Series, error)
+}
+
+// Instant evaluates query at ts. The result is a Scalar or a Vector -- or a
+// Matrix, if the query is a bare range selector such as x[5m].
+func Instant(ctx context.Context, q Queryable, query string, ts time.Time) (Value, error) {
+	e, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	t := ts.UnixMilli()
+	ev, err := newEvaluator(ctx, q, e, t, t)
+	if err != nil {
+		return nil, err
+	}
+	if vs, ok := e.(*VectorSelector); ok && vs.Range != 0 {
+		return ev.rangeVector(vs, t), nil
+	}
+	v, err := ev.eval(e, t)
+	if err != nil {
+		return nil, err
+	}
+	if vec, ok := v.(Vector); ok {
+		sortVector(vec)
+	}
+	return v, nil
+}
+
+// Range evaluates query at every step from start to end, and gathers each
+// series' values into one element of the result. A scalar query becomes one
+// series with no labels.
+func Range(ctx context.Context, q Queryable, query string, start, end time.Time, step time.Duration) (Matrix, error) {
+	e, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	if vs, ok := e.(*VectorSelector); ok && vs.Range != 0 {
+		return nil, fmt.Errorf("%w: a range query cannot return a range vector; wrap it in a function such as rate", ErrBadQuery)
+	}
+	if step <= 0 {
+		return nil, fmt.Errorf("%w: step must be positive", ErrBadQuery)
+	}
+	mint, maxt, stepMs := start.UnixMilli(), end.UnixMilli(), step.Milliseconds()
+	if stepMs == 0 {
+		stepMs = 1
+	}
+	ev, err := newEvaluator(ctx, q, e, mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+	bySeries := map[string]*Series{}
+	var keys []string
+	for t := mint; t <= maxt; t += stepMs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		v, err := ev.eval(e, t)
+		if err != nil {
+			return nil, err
+		}
+		var vec Vector
+		switch v := v.(type) {
+		case Scalar:
+			vec = Vector{{Point: Point(v)}}
+		case Vector:
+			vec = v
+		}
+		for _, s := range vec {
+			key := s.Labels.String()
+			series, ok := bySeries[key]
+			if !ok {
+				series = &Series{Labels: s.Labels}
+				bySeries[key] = series
+				keys = append(keys, key)
+			}
+			series.Points = append(series.Points, Point{T: t, V: s.V})
+		}
+	}
+	slices.Sort(keys)
+	out := make(Matrix, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, *bySeries[k])
+	}
+	return out, nil
+}
+
+// evaluator holds the series every selector in a query reads, fetched once
+// for the whole evaluation rather than once per step.
+type evaluator struct {
+	data map[*VectorSelector][]Series
+}
+
+func newEvaluator(ctx context.Context, q Queryable, e Expr, mint, maxt int64) (*evaluator, error) {
+	ev := &evaluator{data: map[*VectorSelector][]Series{}}
+	var err error
+	walk(e, func(vs *VectorSelector) {
+		if err != nil {
+			return
+		}
+		// An instant selector looks back LookbackDelta from each step, a
+		// range selector its range; either way nothing older than that
+		// before the first step can be read.
+		from := mint - LookbackDelta.Milliseconds()
+		if vs.Range != 0 {
+			from = mint - vs.Range.Milliseconds()
+		}
+		ev.data[vs], err = q.Select(ctx, vs.Matchers, from, maxt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+func walk(e Expr, fn func(*VectorSelector)) {
+	switch e := e.(type) {
+	case *VectorSelector:
+		fn(e)
+	case *Call:
+		for _, a := range e.Args {
+			walk(a, fn)
+		}
+	case *Aggregate:
+		walk(e.Expr, fn)
+	case *Binary:
+		walk(e.LHS, fn)
+		walk(e.RHS, fn)
+	case *Negate:
+		walk(e.Expr, fn)
+	}
+}
+
+// eval evaluates e at t, to a Scalar or a Vector.
+func (ev *evaluator) eval(e Expr, t int64) (Value, error) {
+	switch e := e.(type) {
+	case *NumberLiteral:
+		return Scalar{T: t, V: e.Value}, nil
+	case *VectorSelector:
+		if e.Range != 0 {
+			return nil, fmt.Errorf("%w: a range vector can only be passed to a function", ErrBadQuery)
+		}
+		return ev.instantVector(e, t), nil
+	case *Call:
+		return ev.call(e, t)
+	case *Aggregate:
+		v, err := ev.vector(e.Expr, t)
+		if err != nil {
+			return nil, err
+		}
+		return aggregate(e, v, t), nil
+	case *Binary:
+		return ev.binary(e, t)
+	case *Negate:
+		v, err := ev.eval(e.Expr, t)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := v.(Scalar); ok {
+			return Scalar{T: t, V: -s.V}, nil
+		}
+		vec := v.(Vector)
+		out := make(Vector, 0, len(vec))
+		for _, s := range vec {
+			out = append(out, Sample{Labels: s.Labels.dropName(), Point: Point{T: t, V: -s.V}})
+		}
+		return out, checkDuplicates(out)
+	}
+	return nil, fmt.Errorf("%w: unexpected expression %T", ErrBadQuery, e)
+}
+
+// vector evaluates e where an instant vector is required.
+func (ev *evaluator) vector(e Expr, t int64) (Vector, error) {
+	v, err := ev.eval(e, t)
+	if err != nil {
+		return nil, err
+	}
+	vec, ok := v.(Vector)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected an instant vector, got a %s", ErrBadQuery, v.Type())
+	}
+	return vec, nil
+}
+
+// instantVector is each selected series' latest point at or before t, if it
+// is no older than LookbackDelta.
+func (ev *evaluator) instantVector(vs *VectorSelector, t int64) Vector {
+	var out Vector
+	from := t - LookbackDelta.Milliseconds()
+	for _, s := range ev.data[vs] {
+		i := searchAfter(s.Points, t) - 1
+		if i < 0 || s.Points[i].T <= from {
+			continue
+		}
+		out = append(out, Sample{Labels: s.Labels, Point: Point{T: t, V: s.Points[i].V}})
+	}
+	return out
+}
+
+// rangeVector is each selected series' points in (t - range, t]. Series with
+// none are left out.
+func (ev *evaluator) rangeVector(vs *VectorSelector, t int64) Matrix {
+	var out Matrix
+	from := t - vs.Range.Milliseconds()
+	for _, s := range ev.data[vs] {
+		lo, hi := searchAfter(s.Points, from), searchAfter(s.Points, t)
+		if lo == hi {
+			continue
+		}
+		out = append(out, Series{Labels: s.Labels, Points: s.Points[lo:hi]})
+	}
+	return out
+}
+
+// searchAfter is the index of the first point later than t.
+func searchAfter(points []Point, t int64) int {
+	i, _ := slices.BinarySearchFunc(points, t+1, func(p Point, t int64) int {
+		switch {
+		case p.T < t:
+			return -1
+		case p.T > t:
+			return 1
+		}
+		return 0
+	})
+	return i
+}
+
+func (ev *evaluator) binary(e *Binary, t int64) (Value, error) {
+	lhs, err := ev.eval(e.LHS, t)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := ev.eval(e.RHS, t)
+	if err != nil {
+		return nil, err
+	}
+	ls, lScalar := lhs.(Scalar)
+	rs, rScalar := rhs.(Scalar)
+	switch {
+	case lScalar && rScalar:
+		return Scalar{T: t, V: arith(e.Op, ls.V, rs.V)}, nil
+	case lScalar:
+		out := make(Vector, 0, len(rhs.(Vector)))
+		for _, s := range rhs.(Vector) {
+			out = append(out, Sample{Labels: s.Labels.dropName(), Point: Point{T: t, V: arith(e.Op, ls.V, s.V)}})
+		}
+		return out, checkDuplicates(out)
+	case rScalar:
+		out := make(Vector, 0, len(lhs.(Vector)))
+		for _, s := range lhs.(Vector) {
+			out = append(out, Sample{Labels: s.Labels.dropName(), Point: Point{T: t, V: arith(e.Op, s.V, rs.V)}})
+		}
+		return out, checkDuplicates(out)
+	}
+
+	// Vector to vector: one-to-one on every label but the name. A side with
+	// two series for one label set cannot be matched without group_left or
+	// group_right, which are not supported, so that is an error as it is in
+	// Prometheus.
+	right := map[string]Sample{}
+	for _, s := range rhs.(Vector) {
+		key := s.Labels.dropName().String()
+		if _, dup := right[key]; dup {
+			return nil, fmt.Errorf("%w: found duplicate series for the match group %s on the right-hand side of %s", ErrBadQuery, key, e.Op)
+		}
+		right[key] = s
+	}
+	var out Vector
+	seen := map[string]bool{}
+	for _, l := range lhs.(Vector) {
+		labels := l.Labels.dropName()
+		key := labels.String()
+		r, ok := right[key]
+		if !ok {
+			continue
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: found duplicate series for the match group %s on the left-hand side of %s", ErrBadQuery, key, e.Op)
+		}
+		seen[key] = true
+		out = append(out, Sample{Labels: labels, Point: Point{T: t, V: arith(e.Op, l.V, r.V)}})
+	}
+	return out, nil
+}
+
+func arith(op string, a, b float64) float64 {
+	switch op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	default:
+		return a / b
+	}
+}
+
+// checkDuplicates fails a vector with two series under one label set, which
+// dropping the metric name can make out of two different metrics.
+func checkDuplicates(v Vector) error {
+	seen := make(map[string]bool, len(v))
+	for _, s := range v {
+		key := s.Labels.String()
+		if seen[key] {
+			return fmt.Errorf("%w: vector cannot contain metrics with the same labelset %s", ErrBadQuery, key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+func sortVector(v Vector) {
+	slices.SortFunc(v, func(a, b Sample) int {
+		return strings.Compare(a.Labels.String(), b.Labels.String())
+	})
+}
+
+// aggregate reduces v by e's grouping.
+func aggregate(e *Aggregate, v Vector, t int64) Vector {
+	grouped := map[string]bool{}
+	for _, name := range e.Grouping {
+		grouped[name] = true
+	}
+	keep := func(name string) bool {
+		if e.Without {
+			return !grouped[name] && name != nameLabel
+		}
+		return grouped[name]
+	}
+	type group struct {
+		labels Labels
+		values []float64
+	}
+	groups := map[string]*group{}
+	var keys []string
+	for _, s := range v {
+		labels := s.Labels.filter(keep)
+		key := labels.String()
+		g, ok := groups[key]
+		if !ok {
+			g = &group{labels: labels}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.values = append(g.values, s.V)
+	}
+	out := make(Vector, 0, len(keys))
+	for _, k := range keys {
+		g := groups[k]
+		out = append(out, Sample{Labels: g.labels, Point: Point{T: t, V: reduce(e.Op, g.values)}})
+	}
+	return out
+}
+
+// reduce applies an aggregation to a group's values. min and max skip NaN
+// unless every value is NaN, as in Prometheus.
+func reduce(op string, values []float64) float64 {
+	switch op {
+	case "count":
+		return float64(len(values))
+	case "sum", "avg":
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		if op == "avg" {
+			return sum / float64(len(values))
+		}
+		return sum
+	}
+	out := math.NaN()
+	for _, v := range values {
+		switch {
+		case math.IsNaN(out):
+			out = v
+		case op == "min" && v < out, op == "max" && v > out:
+			out = v
+		}
+	}
+	return out
+}
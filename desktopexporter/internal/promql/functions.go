@@ -0,0 +1,208 @@
+package promql
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+)
+
+func (ev *evaluator) call(e *Call, t int64) (Value, error) {
+	switch e.Func {
+	case "rate", "increase":
+		vs := e.Args[0].(*VectorSelector)
+		var out Vector
+		for _, s := range ev.rangeVector(vs, t) {
+			v, ok := extrapolatedRate(s.Points, t-vs.Range.Milliseconds(), t, vs.Range, e.Func == "rate")
+			if !ok {
+				continue
+			}
+			out = append(out, Sample{Labels: s.Labels.dropName(), Point: Point{T: t, V: v}})
+		}
+		return out, checkDuplicates(out)
+	case "histogram_quantile":
+		q, err := ev.eval(e.Args[0], t)
+		if err != nil {
+			return nil, err
+		}
+		phi, ok := q.(Scalar)
+		if !ok {
+			return nil, fmt.Errorf("%w: histogram_quantile expects a scalar quantile, got a %s", ErrBadQuery, q.Type())
+		}
+		vec, err := ev.vector(e.Args[1], t)
+		if err != nil {
+			return nil, err
+		}
+		return histogramQuantile(phi.V, vec, t), nil
+	}
+	return nil, fmt.Errorf("%w: function %q is not supported", ErrBadQuery, e.Func)
+}
+
+// extrapolatedRate is Prometheus's rate and increase: the counter's increase
+// across the samples in the range, reset-corrected, then extrapolated towards
+// the range's edges -- all the way if the first or last sample is within
+// 1.1 sample intervals of its edge, half an interval if not, and never below
+// the point the counter would have been zero at. Fewer than two samples have
+// no rate, nor do samples that all share one timestamp -- an OTLP cumulative
+// point repeated in two exports, say: there is no interval to divide by.
+//
+// This is why increase over a range is rarely a whole number even for a
+// counter that only ever goes up by one: it estimates the increase over the
+// whole range, not the difference between two samples.
+func extrapolatedRate(points []Point, rangeStart, rangeEnd int64, rng time.Duration, isRate bool) (float64, bool) {
+	if len(points) < 2 {
+		return 0, false
+	}
+	first, last := points[0], points[len(points)-1]
+	result := last.V - first.V
+	prev := first.V
+	for _, p := range points[1:] {
+		if p.V < prev {
+			result += prev
+		}
+		prev = p.V
+	}
+
+	durationToStart := float64(first.T-rangeStart) / 1000
+	durationToEnd := float64(rangeEnd-last.T) / 1000
+	sampledInterval := float64(last.T-first.T) / 1000
+	if sampledInterval == 0 {
+		return 0, false
+	}
+	averageInterval := sampledInterval / float64(len(points)-1)
+
+	if result > 0 && first.V >= 0 {
+		if durationToZero := sampledInterval * (first.V / result); durationToZero < durationToStart {
+			durationToStart = durationToZero
+		}
+	}
+	threshold := averageInterval * 1.1
+	extrapolateTo := sampledInterval
+	if durationToStart < threshold {
+		extrapolateTo += durationToStart
+	} else {
+		extrapolateTo += averageInterval / 2
+	}
+	if durationToEnd < threshold {
+		extrapolateTo += durationToEnd
+	} else {
+		extrapolateTo += averageInterval / 2
+	}
+	factor := extrapolateTo / sampledInterval
+	if isRate {
+		factor /= rng.Seconds()
+	}
+	return result * factor, true
+}
+
+type bucket struct {
+	upperBound float64
+	count      float64
+}
+
+// histogramQuantile groups the _bucket series in v by every label but le and
+// the name, and estimates the phi-quantile of each group. Series without a
+// parseable le are skipped, as in Prometheus.
+func histogramQuantile(phi float64, v Vector, t int64) Vector {
+	type group struct {
+		labels  Labels
+		buckets []bucket
+	}
+	groups := map[string]*group{}
+	var keys []string
+	for _, s := range v {
+		ub, err := strconv.ParseFloat(s.Labels.Get("le"), 64)
+		if err != nil {
+			continue
+		}
+		labels := s.Labels.filter(func(name string) bool { return name != "le" && name != nameLabel })
+		key := labels.String()
+		g, ok := groups[key]
+		if !ok {
+			g = &group{labels: labels}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.buckets = append(g.buckets, bucket{upperBound: ub, count: s.V})
+	}
+	var out Vector
+	for _, k := range keys {
+		g := groups[k]
+		out = append(out, Sample{Labels: g.labels, Point: Point{T: t, V: bucketQuantile(phi, g.buckets)}})
+	}
+	return out
+}
+
+// bucketQuantile is Prometheus's: find the bucket the rank falls in and
+// interpolate linearly inside it. A quantile in the +Inf bucket is the
+// highest finite bound, and one in a first bucket with a bound at or below
+// zero is that bound. Without a +Inf bucket, or observations, it is NaN.
+func bucketQuantile(q float64, buckets []bucket) float64 {
+	switch {
+	case math.IsNaN(q):
+		return math.NaN()
+	case q < 0:
+		return math.Inf(-1)
+	case q > 1:
+		return math.Inf(+1)
+	}
+	slices.SortFunc(buckets, func(a, b bucket) int {
+		switch {
+		case a.upperBound < b.upperBound:
+			return -1
+		case a.upperBound > b.upperBound:
+			return 1
+		}
+		return 0
+	})
+	if len(buckets) == 0 || !math.IsInf(buckets[len(buckets)-1].upperBound, +1) {
+		return math.NaN()
+	}
+	// Coalesce buckets with the same bound, then make the counts
+	// monotonic: series scraped at slightly different moments can
+	// disagree by a few observations.
+	merged := buckets[:1]
+	for _, b := range buckets[1:] {
+		if b.upperBound == merged[len(merged)-1].upperBound {
+			merged[len(merged)-1].count += b.count
+			continue
+		}
+		merged = append(merged, b)
+	}
+	buckets = merged
+	max := math.Inf(-1)
+	for i := range buckets {
+		if buckets[i].count > max {
+			max = buckets[i].count
+		} else {
+			buckets[i].count = max
+		}
+	}
+	if len(buckets) < 2 {
+		return math.NaN()
+	}
+	observations := buckets[len(buckets)-1].count
+	if observations == 0 {
+		return math.NaN()
+	}
+	rank := q * observations
+	b := 0
+	for b < len(buckets)-1 && buckets[b].count < rank {
+		b++
+	}
+	if b == len(buckets)-1 {
+		return buckets[len(buckets)-2].upperBound
+	}
+	if b == 0 && buckets[0].upperBound <= 0 {
+		return buckets[0].upperBound
+	}
+	var start float64
+	end, count := buckets[b].upperBound, buckets[b].count
+	if b > 0 {
+		start = buckets[b-1].upperBound
+		count -= buckets[b-1].count
+		rank -= buckets[b-1].count
+	}
+	return start + (end-start)*(rank/count)
+}
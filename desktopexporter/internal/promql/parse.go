@@ -0,0 +1,697 @@
+// Package promql evaluates a subset of PromQL over the stored metrics, for the
+// Prometheus HTTP API the server offers, so a Grafana dashboard written
+// against Prometheus can be pointed at the viewer.
+//
+// The subset is what dashboards are mostly made of: selectors with the four
+// matchers, rate and increase over a range, sum, avg, min, max and count with
+// by or without, histogram_quantile, and + - * / between scalars and vectors.
+// Anything else -- other functions, offset, subqueries, comparison operators,
+// group modifiers -- is a parse error naming what was not understood, rather
+// than a result that is quietly different from Prometheus's.
+//
+// Stored OTel metrics are read under the names and labels the collector's
+// Prometheus exporter would give them: see source.go.
+package promql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrBadQuery is a query outside the supported subset, or not PromQL at all.
+var ErrBadQuery = errors.New("invalid PromQL query")
+
+// nameLabel is the label a metric name is matched as: x{a="b"} is
+// {__name__="x", a="b"}.
+const nameLabel = "__name__"
+
+// Expr is a parsed query.
+type Expr interface {
+	expr()
+}
+
+// NumberLiteral is a bare number: a scalar.
+type NumberLiteral struct {
+	Value float64
+}
+
+// VectorSelector selects series by matchers. A non-zero Range makes it a
+// range vector, x[5m].
+type VectorSelector struct {
+	Matchers []*Matcher
+	Range    time.Duration
+}
+
+// Call is one of the supported functions.
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+// Aggregate is sum, avg, min, max or count over a vector, grouped by the
+// Grouping labels -- or by everything but them when Without is set.
+type Aggregate struct {
+	Op       string
+	Expr     Expr
+	Grouping []string
+	Without  bool
+}
+
+// Binary is arithmetic between two operands.
+type Binary struct {
+	Op       string
+	LHS, RHS Expr
+}
+
+// Negate is unary minus.
+type Negate struct {
+	Expr Expr
+}
+
+func (*NumberLiteral) expr()  {}
+func (*VectorSelector) expr() {}
+func (*Call) expr()           {}
+func (*Aggregate) expr()      {}
+func (*Binary) expr()         {}
+func (*Negate) expr()         {}
+
+// MatchType is one of the four label matchers.
+type MatchType string
+
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// Matcher tests one label. A regular expression is anchored at both ends, as
+// in Prometheus, so job=~"api" matches api and not api-gateway.
+type Matcher struct {
+	Name  string
+	Type  MatchType
+	Value string
+	re    *regexp.Regexp
+}
+
+// NewMatcher builds a matcher, compiling its expression if it has one.
+func NewMatcher(t MatchType, name, value string) (*Matcher, error) {
+	m := &Matcher{Name: name, Type: t, Value: value}
+	if t == MatchRegexp || t == MatchNotRegexp {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w: label %s: %w", ErrBadQuery, name, err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+// Matches reports whether a label value passes. A missing label is the empty
+// string, so {foo=""} selects series without foo.
+func (m *Matcher) Matches(v string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return v == m.Value
+	case MatchNotEqual:
+		return v != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(v)
+	default:
+		return !m.re.MatchString(v)
+	}
+}
+
+// functions is every supported function and the argument kinds it takes.
+var functions = map[string][]argKind{
+	"rate":               {rangeArg},
+	"increase":           {rangeArg},
+	"histogram_quantile": {scalarArg, vectorArg},
+}
+
+var aggregations = map[string]bool{
+	"sum": true, "avg": true, "min": true, "max": true, "count": true,
+}
+
+type argKind int
+
+const (
+	scalarArg argKind = iota
+	vectorArg
+	rangeArg
+)
+
+// Parse parses a query.
+func Parse(input string) (Expr, error) {
+	p := &parser{in: input}
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.in) {
+		return nil, p.errorf("unexpected %q", p.rest())
+	}
+	return e, nil
+}
+
+// ParseSelector parses a bare series selector, as /api/v1/series takes in
+// match[].
+func ParseSelector(input string) ([]*Matcher, error) {
+	e, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	vs, ok := e.(*VectorSelector)
+	if !ok || vs.Range != 0 {
+		return nil, fmt.Errorf("%w: %q is not a series selector", ErrBadQuery, input)
+	}
+	return vs.Matchers, nil
+}
+
+type parser struct {
+	in  string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: at char %d: %s", ErrBadQuery, p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) rest() string {
+	r := p.in[p.pos:]
+	if len(r) > 20 {
+		r = r[:20] + "..."
+	}
+	return r
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.in) {
+		switch p.in[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		case '#':
+			for p.pos < len(p.in) && p.in[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next byte after whitespace, or 0 at the end.
+func (p *parser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.in) {
+		return 0
+	}
+	return p.in[p.pos]
+}
+
+func (p *parser) accept(tok string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.in[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(tok string) error {
+	if !p.accept(tok) {
+		if p.pos >= len(p.in) {
+			return p.errorf("expected %q, got end of query", tok)
+		}
+		return p.errorf("expected %q, got %q", tok, p.rest())
+	}
+	return nil
+}
+
+// parseExpr is the lowest-precedence level: + and -.
+func (p *parser) parseExpr() (Expr, error) {
+	lhs, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch p.peek() {
+		case '+', '-':
+			op = string(p.in[p.pos])
+		default:
+			return lhs, nil
+		}
+		p.pos++
+		if err := p.rejectModifiers(); err != nil {
+			return nil, err
+		}
+		rhs, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &Binary{Op: op, LHS: lhs, RHS: rhs}
+	}
+}
+
+func (p *parser) parseTerm() (Expr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch p.peek() {
+		case '*', '/':
+			op = string(p.in[p.pos])
+		case '%', '^':
+			return nil, p.errorf("operator %q is not supported", p.in[p.pos])
+		case '=', '!', '<', '>':
+			return nil, p.errorf("comparison operators are not supported")
+		default:
+			return lhs, nil
+		}
+		p.pos++
+		if err := p.rejectModifiers(); err != nil {
+			return nil, err
+		}
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &Binary{Op: op, LHS: lhs, RHS: rhs}
+	}
+}
+
+// rejectModifiers names the vector matching modifiers an operator can take,
+// which would otherwise be read as a call to a function called on.
+func (p *parser) rejectModifiers() error {
+	start := p.pos
+	word := p.ident(false)
+	p.skipSpace()
+	switch word {
+	case "on", "ignoring", "group_left", "group_right", "bool":
+		p.pos = start
+		return p.errorf("%s is not supported", word)
+	}
+	p.pos = start
+	return nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.accept("-") {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if n, ok := e.(*NumberLiteral); ok {
+			return &NumberLiteral{Value: -n.Value}, nil
+		}
+		return &Negate{Expr: e}, nil
+	}
+	if p.accept("+") {
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, p.errorf("unexpected end of query")
+	case c == '(':
+		p.pos++
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return p.parseRange(e)
+	case c == '{':
+		return p.parseSelector("")
+	case c >= '0' && c <= '9', c == '.':
+		return p.parseNumber()
+	case isIdentStart(rune(c)):
+		start := p.pos
+		name := p.ident(true)
+		lower := strings.ToLower(name)
+		switch {
+		case lower == "inf" || lower == "nan":
+			v, _ := strconv.ParseFloat(name, 64)
+			return &NumberLiteral{Value: v}, nil
+		case aggregations[name]:
+			return p.parseAggregate(name)
+		case p.peek() == '(':
+			if _, ok := functions[name]; !ok {
+				p.pos = start
+				return nil, p.errorf("function %q is not supported", name)
+			}
+			return p.parseCall(name)
+		}
+		return p.parseSelector(name)
+	}
+	return nil, p.errorf("unexpected %q", p.rest())
+}
+
+func (p *parser) parseNumber() (Expr, error) {
+	start := p.pos
+	for p.pos < len(p.in) {
+		c := p.in[p.pos]
+		isExp := (c == '+' || c == '-') && p.pos > start && (p.in[p.pos-1] == 'e' || p.in[p.pos-1] == 'E')
+		if (c >= '0' && c <= '9') || c == '.' || c == 'e' || c == 'E' || c == 'x' || c == 'X' ||
+			(c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') || isExp {
+			p.pos++
+			continue
+		}
+		break
+	}
+	text := p.in[start:p.pos]
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("bad number %q", text)
+	}
+	return &NumberLiteral{Value: v}, nil
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || r == ':' || (r < unicode.MaxASCII && unicode.IsLetter(r))
+}
+
+func isIdentChar(r rune) bool {
+	return isIdentStart(r) || (r >= '0' && r <= '9')
+}
+
+// ident reads a metric name, or with colons false a label name.
+func (p *parser) ident(colons bool) string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.in) {
+		r := rune(p.in[p.pos])
+		if !isIdentChar(r) || (!colons && r == ':') || (p.pos == start && r >= '0' && r <= '9') {
+			break
+		}
+		p.pos++
+	}
+	return p.in[start:p.pos]
+}
+
+func (p *parser) parseSelector(name string) (Expr, error) {
+	vs := &VectorSelector{}
+	if name != "" {
+		m, _ := NewMatcher(MatchEqual, nameLabel, name)
+		vs.Matchers = append(vs.Matchers, m)
+	}
+	if p.accept("{") {
+		for !p.accept("}") {
+			label := p.ident(false)
+			if label == "" {
+				return nil, p.errorf("expected a label name, got %q", p.rest())
+			}
+			var t MatchType
+			switch {
+			case p.accept("=~"):
+				t = MatchRegexp
+			case p.accept("!~"):
+				t = MatchNotRegexp
+			case p.accept("!="):
+				t = MatchNotEqual
+			case p.accept("="):
+				t = MatchEqual
+			default:
+				return nil, p.errorf("expected a matcher after %s", label)
+			}
+			value, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			if label == nameLabel && name != "" {
+				return nil, p.errorf("metric name given twice")
+			}
+			m, err := NewMatcher(t, label, value)
+			if err != nil {
+				return nil, err
+			}
+			vs.Matchers = append(vs.Matchers, m)
+			if !p.accept(",") {
+				if err := p.expect("}"); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+	}
+	// Prometheus refuses a selector every series would match, such as {}
+	// or {job=~".*"}: one that names nothing is a mistake, not a request for
+	// everything.
+	named := false
+	for _, m := range vs.Matchers {
+		if !m.Matches("") {
+			named = true
+		}
+	}
+	if !named {
+		return nil, p.errorf("a selector must contain at least one matcher that does not match the empty string")
+	}
+	if p.accept("offset") {
+		return nil, p.errorf("offset is not supported")
+	}
+	if p.accept("@") {
+		return nil, p.errorf("@ modifiers are not supported")
+	}
+	return p.parseRange(vs)
+}
+
+// parseRange reads an optional [duration] after a selector. After anything
+// else it would be a subquery, which is not supported.
+func (p *parser) parseRange(e Expr) (Expr, error) {
+	if p.peek() != '[' {
+		return e, nil
+	}
+	vs, ok := e.(*VectorSelector)
+	if !ok || vs.Range != 0 {
+		return nil, p.errorf("subqueries are not supported")
+	}
+	p.pos++
+	end := strings.IndexByte(p.in[p.pos:], ']')
+	if end < 0 {
+		return nil, p.errorf("unclosed range")
+	}
+	text := strings.TrimSpace(p.in[p.pos : p.pos+end])
+	if strings.Contains(text, ":") {
+		return nil, p.errorf("subqueries are not supported")
+	}
+	d, err := ParseDuration(text)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	if d <= 0 {
+		return nil, p.errorf("range must be positive, got %q", text)
+	}
+	p.pos += end + 1
+	vs.Range = d
+	return vs, nil
+}
+
+// ParseDuration reads a Prometheus duration: one or more number-unit pairs,
+// largest unit first, from ms up to y, as in 1h30m. A day is 24h, a week 7d
+// and a year 365d, as in Prometheus.
+func ParseDuration(s string) (time.Duration, error) {
+	units := []struct {
+		suffix string
+		d      time.Duration
+	}{
+		// ms before m and s, so 100ms is not read as 100m followed by s.
+		{"ms", time.Millisecond},
+		{"y", 365 * 24 * time.Hour},
+		{"w", 7 * 24 * time.Hour},
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	}
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+	var total time.Duration
+	rest := s
+	last := time.Duration(math.MaxInt64)
+	for rest != "" {
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("bad duration %q", s)
+		}
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("bad duration %q", s)
+		}
+		rest = rest[i:]
+		matched := false
+		for _, u := range units {
+			if strings.HasPrefix(rest, u.suffix) {
+				if u.d >= last {
+					return 0, fmt.Errorf("bad duration %q: units must go from largest to smallest", s)
+				}
+				if n > int64(math.MaxInt64/u.d) {
+					return 0, fmt.Errorf("duration %q is too long", s)
+				}
+				total += time.Duration(n) * u.d
+				last = u.d
+				rest = rest[len(u.suffix):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return 0, fmt.Errorf("bad duration %q: want a unit of ms, s, m, h, d, w or y", s)
+		}
+	}
+	return total, nil
+}
+
+// parseString reads a quoted label value: "..." or '...' with Go escapes, or
+// `...` raw.
+func (p *parser) parseString() (string, error) {
+	q := p.peek()
+	if q != '"' && q != '\'' && q != '`' {
+		return "", p.errorf("expected a quoted string, got %q", p.rest())
+	}
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.in) && p.in[p.pos] != q {
+		if p.in[p.pos] == '\\' && q != '`' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.in) {
+		p.pos = start
+		return "", p.errorf("unterminated string")
+	}
+	p.pos++
+	raw := p.in[start:p.pos]
+	if q == '\'' {
+		// strconv.Unquote reads single quotes as a rune literal, so swap
+		// them for double quotes, escaping any that were inside.
+		body := raw[1 : len(raw)-1]
+		body = strings.ReplaceAll(strings.ReplaceAll(body, `\'`, `'`), `"`, `\"`)
+		raw = `"` + body + `"`
+	}
+	s, err := strconv.Unquote(raw)
+	if err != nil {
+		p.pos = start
+		return "", p.errorf("bad string %s", raw)
+	}
+	return s, nil
+}
+
+func (p *parser) parseGrouping() ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var labels []string
+	for !p.accept(")") {
+		label := p.ident(false)
+		if label == "" {
+			return nil, p.errorf("expected a label name, got %q", p.rest())
+		}
+		labels = append(labels, label)
+		if !p.accept(",") {
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return labels, nil
+}
+
+// parseAggregate reads sum by (job) (x) or sum (x) by (job).
+func (p *parser) parseAggregate(op string) (Expr, error) {
+	agg := &Aggregate{Op: op}
+	readGrouping := func() (bool, error) {
+		start := p.pos
+		word := p.ident(false)
+		switch word {
+		case "by", "without":
+			labels, err := p.parseGrouping()
+			if err != nil {
+				return false, err
+			}
+			agg.Grouping, agg.Without = labels, word == "without"
+			return true, nil
+		}
+		p.pos = start
+		return false, nil
+	}
+	grouped, err := readGrouping()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	if agg.Expr, err = p.parseExpr(); err != nil {
+		return nil, err
+	}
+	if p.accept(",") {
+		return nil, p.errorf("%s takes one argument", op)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if !grouped {
+		if _, err := readGrouping(); err != nil {
+			return nil, err
+		}
+	}
+	return agg, nil
+}
+
+func (p *parser) parseCall(name string) (Expr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	call := &Call{Func: name}
+	for !p.accept(")") {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+		if !p.accept(",") {
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	kinds := functions[name]
+	if len(call.Args) != len(kinds) {
+		return nil, p.errorf("%s takes %d argument(s), got %d", name, len(kinds), len(call.Args))
+	}
+	for i, kind := range kinds {
+		vs, isSelector := call.Args[i].(*VectorSelector)
+		isRange := isSelector && vs.Range != 0
+		switch {
+		case kind == rangeArg && !isRange:
+			return nil, p.errorf("%s expects a range vector such as x[5m]", name)
+		case kind != rangeArg && isRange:
+			return nil, p.errorf("%s does not take a range vector", name)
+		}
+	}
+	return call, nil
+}
@@ -0,0 +1,110 @@
+package promql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSupportedSubset(t *testing.T) {
+	for _, q := range []string{
+		`1 + 1`,
+		`-2.5e3`,
+		`up`,
+		`http_requests_total{job="api", code=~"5..", method!="GET", path!~'/health.*'}`,
+		`{__name__="up"}`,
+		"rate(http_requests_total[5m])",
+		"increase(x[1h30m])",
+		`sum by (job) (rate(x[1m]))`,
+		`sum(rate(x[1m])) by (job, instance)`,
+		`avg without (instance) (x)`,
+		`max(x)`, `min(x)`, `count(x)`,
+		`histogram_quantile(0.99, sum by (le) (rate(http_server_duration_milliseconds_bucket[5m])))`,
+		`x / y * 100`,
+		`-x`,
+		`(x + y)`,
+		"x # a comment",
+	} {
+		_, err := Parse(q)
+		assert.NoError(t, err, q)
+	}
+}
+
+// What is not supported is an error naming it, not a different answer.
+func TestParseRejects(t *testing.T) {
+	for q, want := range map[string]string{
+		`irate(x[5m])`:                   `function "irate" is not supported`,
+		`x offset 5m`:                    "offset is not supported",
+		`x > 1`:                          "comparison operators are not supported",
+		`x % 2`:                          `operator '%' is not supported`,
+		`rate(x[5m:1m])`:                 "subqueries are not supported",
+		`x / on(job) y`:                  "on is not supported",
+		`rate(x)`:                        "expects a range vector",
+		`histogram_quantile(x)`:          "takes 2 argument(s), got 1",
+		`{}`:                             "at least one matcher",
+		`{job=~".*"}`:                    "at least one matcher",
+		`x{job="a"`:                      `expected "}"`,
+		`x[5]`:                           "want a unit",
+		`x[1m1h]`:                        "largest to smallest",
+		`x{job=~"("}`:                    "missing closing )",
+		`sum(x, y)`:                      "sum takes one argument",
+		`x and y`:                        "unexpected",
+		`x{job="a"}{job="b"}`:            "unexpected",
+		`rate(x[5m]) by (job)`:           "unexpected",
+		`x{__name__="y"}`:                "metric name given twice",
+		`histogram_quantile(0.9, x[5m])`: "does not take a range vector",
+	} {
+		_, err := Parse(q)
+		require.ErrorIs(t, err, ErrBadQuery, q)
+		assert.Contains(t, err.Error(), want, q)
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	ms, err := ParseSelector(`x{job="api"}`)
+	require.NoError(t, err)
+	require.Len(t, ms, 2)
+	assert.Equal(t, nameLabel, ms[0].Name)
+	assert.True(t, ms[1].Matches("api"))
+
+	_, err = ParseSelector(`rate(x[5m])`)
+	assert.ErrorIs(t, err, ErrBadQuery)
+	_, err = ParseSelector(`x[5m]`)
+	assert.ErrorIs(t, err, ErrBadQuery)
+}
+
+// Regular expressions are anchored, as they are in Prometheus.
+func TestMatcherRegexpIsAnchored(t *testing.T) {
+	m, err := NewMatcher(MatchRegexp, "job", "api|db")
+	require.NoError(t, err)
+	assert.True(t, m.Matches("api"))
+	assert.True(t, m.Matches("db"))
+	assert.False(t, m.Matches("api-gateway"))
+
+	m, err = NewMatcher(MatchNotRegexp, "job", "api")
+	require.NoError(t, err)
+	assert.True(t, m.Matches("api-gateway"))
+	assert.False(t, m.Matches("api"))
+}
+
+func TestParseDuration(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"100ms": 100 * time.Millisecond,
+		"30s":   30 * time.Second,
+		"5m":    5 * time.Minute,
+		"1h30m": 90 * time.Minute,
+		"1d":    24 * time.Hour,
+		"1w":    7 * 24 * time.Hour,
+		"1y":    365 * 24 * time.Hour,
+	} {
+		got, err := ParseDuration(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"", "5", "m", "1.5h", "5mm"} {
+		_, err := ParseDuration(in)
+		assert.Error(t, err, in)
+	}
+}
@@ -0,0 +1,374 @@
+package promql
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/metrics"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/rebuild"
+)
+
+// Source reads stored metrics as Prometheus series. It holds the connection
+// for one request: build it inside the store's read lock.
+//
+// The naming is the collector's Prometheus exporter's, so a query written
+// against a Prometheus fed by that exporter reads the same series here:
+//
+//   - the metric name is sanitized, then suffixed with its unit (ms becomes
+//     _milliseconds, By/s _bytes_per_second), and a monotonic Sum gets
+//     _total and a Gauge with unit 1 gets _ratio;
+//   - a Histogram becomes _bucket series with an le label, plus _sum and
+//     _count; a Summary is one series per quantile label, plus _sum and
+//     _count; an ExponentialHistogram, which has no le form, is only _sum and
+//     _count;
+//   - labels are the datapoint attributes, sanitized, plus job from
+//     service.namespace and service.name and instance from
+//     service.instance.id.
+//
+// Prometheus has no delta counters, so a delta series is read as its running
+// total from its first stored point.
+type Source struct {
+	db *sql.DB
+}
+
+// NewSource reads from db.
+func NewSource(db *sql.DB) *Source {
+	return &Source{db: db}
+}
+
+// Select implements Queryable. Times are milliseconds.
+func (s *Source) Select(ctx context.Context, matchers []*Matcher, mint, maxt int64) ([]Series, error) {
+	stored, err := metrics.ListPromSeries(ctx, s.db, nanos(mint), nanos(maxt))
+	if err != nil {
+		return nil, err
+	}
+	// Work out which stored series any wanted Prometheus series comes from
+	// before reading a single point.
+	var ids []string
+	wanted := map[string][]promSeries{}
+	for _, st := range stored {
+		for _, ps := range expand(st) {
+			if ps.labels.matches(matchers) {
+				if _, ok := wanted[st.ID]; !ok {
+					ids = append(ids, st.ID)
+				}
+				wanted[st.ID] = append(wanted[st.ID], ps)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	points, err := metrics.PromPoints(ctx, s.db, ids, nanos(mint), nanos(maxt))
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string][]metrics.PromPoint{}
+	for _, p := range points {
+		byID[p.SeriesID] = append(byID[p.SeriesID], p)
+	}
+	temporality := map[string]string{}
+	for _, st := range stored {
+		temporality[st.ID] = st.Temporality
+	}
+
+	var out []Series
+	for _, id := range ids {
+		for _, ps := range wanted[id] {
+			samples := ps.samples(byID[id], temporality[id] == "Delta", mint)
+			if len(samples) > 0 {
+				out = append(out, Series{Labels: ps.labels, Points: samples})
+			}
+		}
+	}
+	slices.SortFunc(out, func(a, b Series) int {
+		return strings.Compare(a.Labels.String(), b.Labels.String())
+	})
+	return out, nil
+}
+
+// LabelSets returns the label set of every series with a point in
+// [mint, maxt] that matches any one of the matcher sets -- or of every series,
+// if there are none. This is /api/v1/series, and what /labels and
+// /label/{name}/values are drawn from.
+func (s *Source) LabelSets(ctx context.Context, matcherSets [][]*Matcher, mint, maxt int64) ([]Labels, error) {
+	stored, err := metrics.ListPromSeries(ctx, s.db, nanos(mint), nanos(maxt))
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var out []Labels
+	for _, st := range stored {
+		for _, ps := range expand(st) {
+			match := len(matcherSets) == 0
+			for _, ms := range matcherSets {
+				if ps.labels.matches(ms) {
+					match = true
+					break
+				}
+			}
+			key := ps.labels.String()
+			if match && !seen[key] {
+				seen[key] = true
+				out = append(out, ps.labels)
+			}
+		}
+	}
+	slices.SortFunc(out, func(a, b Labels) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	return out, nil
+}
+
+// nanos converts milliseconds to the store's nanoseconds, saturating rather
+// than wrapping for the far-off bounds an open-ended request asks for.
+func nanos(ms int64) int64 {
+	switch {
+	case ms > math.MaxInt64/1_000_000:
+		return math.MaxInt64
+	case ms < math.MinInt64/1_000_000:
+		return math.MinInt64
+	}
+	return ms * 1_000_000
+}
+
+// promSeries is one of the Prometheus series a stored series becomes, and
+// how to read its value off a point.
+type promSeries struct {
+	labels Labels
+	// value reads a point, or reports that the point has no value for this
+	// series -- a bucket bound the point did not use.
+	value func(metrics.PromPoint) (float64, bool)
+}
+
+// samples reads the series off a stored series' points. A delta series is
+// summed from its first point, and what was summed before mint, which the
+// store returns only so the total starts in the right place, is dropped.
+func (ps promSeries) samples(points []metrics.PromPoint, delta bool, mint int64) []Point {
+	var out []Point
+	var total float64
+	for _, p := range points {
+		v, ok := ps.value(p)
+		if !ok {
+			continue
+		}
+		if delta {
+			total += v
+			v = total
+		}
+		t := p.Timestamp / 1e6
+		if t < mint {
+			continue
+		}
+		out = append(out, Point{T: t, V: v})
+	}
+	return out
+}
+
+func expand(st metrics.PromSeries) []promSeries {
+	base := seriesLabels(st)
+	name := metricName(st)
+	with := func(name string, extra ...Label) Labels {
+		ls := append(slices.Clone(base), Label{Name: nameLabel, Value: name})
+		ls = append(ls, extra...)
+		slices.SortFunc(ls, func(a, b Label) int { return strings.Compare(a.Name, b.Name) })
+		return ls
+	}
+	sum := promSeries{labels: with(name + "_sum"), value: func(p metrics.PromPoint) (float64, bool) { return p.Sum, true }}
+	count := promSeries{labels: with(name + "_count"), value: func(p metrics.PromPoint) (float64, bool) { return float64(p.Count), true }}
+
+	switch st.MetricType {
+	case "Histogram":
+		out := []promSeries{sum, count}
+		for _, bound := range st.Bounds {
+			out = append(out, promSeries{
+				labels: with(name+"_bucket", Label{Name: "le", Value: formatBound(bound)}),
+				value: func(p metrics.PromPoint) (float64, bool) {
+					// Buckets are per-bucket counts in OTLP and
+					// cumulative in Prometheus: le="10" counts
+					// everything at or below 10.
+					var n uint64
+					for i, b := range p.Bounds {
+						if i < len(p.BucketCounts) {
+							n += p.BucketCounts[i]
+						}
+						if b == bound {
+							return float64(n), true
+						}
+					}
+					return 0, false
+				},
+			})
+		}
+		out = append(out, promSeries{
+			labels: with(name+"_bucket", Label{Name: "le", Value: "+Inf"}),
+			value:  func(p metrics.PromPoint) (float64, bool) { return float64(p.Count), true },
+		})
+		return out
+	case "Summary":
+		out := []promSeries{sum, count}
+		for _, q := range st.Quantiles {
+			out = append(out, promSeries{
+				labels: with(name, Label{Name: "quantile", Value: formatBound(q)}),
+				value: func(p metrics.PromPoint) (float64, bool) {
+					for _, pq := range p.Quantiles {
+						if pq.Quantile == q {
+							return pq.Value, true
+						}
+					}
+					return 0, false
+				},
+			})
+		}
+		return out
+	case "ExponentialHistogram":
+		return []promSeries{sum, count}
+	}
+	return []promSeries{{
+		labels: with(name),
+		value:  func(p metrics.PromPoint) (float64, bool) { return p.Value, true },
+	}}
+}
+
+// formatBound renders a bucket bound or a quantile the way the Prometheus
+// exporter does, so le="0.005" rather than le="5e-03".
+func formatBound(b float64) string {
+	return strconv.FormatFloat(b, 'f', -1, 64)
+}
+
+// seriesLabels is the stored series' attributes as labels, plus job and
+// instance. Two attributes that sanitize to the same name are joined with a
+// semicolon, in the order of their original keys, as the exporter does.
+func seriesLabels(st metrics.PromSeries) Labels {
+	values := map[string][]string{}
+	attrs := slices.Clone(st.Attributes)
+	slices.SortFunc(attrs, func(a, b rebuild.Attribute) int { return strings.Compare(a.Key, b.Key) })
+	for _, a := range attrs {
+		name := labelName(a.Key)
+		values[name] = append(values[name], a.Value)
+	}
+	resource := map[string]string{}
+	for _, a := range st.Resource {
+		resource[a.Key] = a.Value
+	}
+	if job := resource["service.name"]; job != "" {
+		if ns := resource["service.namespace"]; ns != "" {
+			job = ns + "/" + job
+		}
+		values["job"] = []string{job}
+	}
+	if instance := resource["service.instance.id"]; instance != "" {
+		values["instance"] = []string{instance}
+	}
+	out := make(Labels, 0, len(values))
+	for name, vs := range values {
+		out = append(out, Label{Name: name, Value: strings.Join(vs, ";")})
+	}
+	return out
+}
+
+// labelName sanitizes an attribute key: anything but letters, digits and
+// underscores becomes an underscore, and a key that would then start with a
+// digit, or a single underscore, gets a key prefix -- a leading double
+// underscore is reserved for Prometheus's own labels.
+func labelName(key string) string {
+	b := []rune(key)
+	for i, r := range b {
+		if !isLabelChar(r) {
+			b[i] = '_'
+		}
+	}
+	name := string(b)
+	switch {
+	case name == "":
+		return "key_empty"
+	case name[0] >= '0' && name[0] <= '9':
+		return "key_" + name
+	case strings.HasPrefix(name, "_") && !strings.HasPrefix(name, "__"):
+		return "key" + name
+	}
+	return name
+}
+
+func isLabelChar(r rune) bool {
+	return r == '_' || (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+}
+
+// unitNames is the exporter's table of UCUM units to Prometheus suffixes.
+var unitNames = map[string]string{
+	"d": "days", "h": "hours", "min": "minutes", "s": "seconds",
+	"ms": "milliseconds", "us": "microseconds", "ns": "nanoseconds",
+	"By": "bytes", "KiBy": "kibibytes", "MiBy": "mebibytes", "GiBy": "gibibytes", "TiBy": "tibibytes",
+	"KBy": "kilobytes", "MBy": "megabytes", "GBy": "gigabytes", "TBy": "terabytes",
+	"m": "meters", "V": "volts", "A": "amperes", "J": "joules", "W": "watts", "g": "grams",
+	"Cel": "celsius", "Hz": "hertz", "%": "percent",
+}
+
+// perUnitNames is the table for the unit after a slash, in /s.
+var perUnitNames = map[string]string{
+	"s": "second", "m": "minute", "h": "hour", "d": "day", "w": "week", "mo": "month", "y": "year",
+}
+
+// metricName is the exporter's name for a stored series' metric: the name's
+// alphanumeric runs joined with underscores, then the unit, then _total or
+// _ratio. A unit the name already ends with is not added again.
+func metricName(st metrics.PromSeries) string {
+	tokens := nameTokens(st.Name)
+	unit, perUnit, _ := strings.Cut(st.Unit, "/")
+	// Annotations such as {requests} are for humans, not suffixes.
+	if strings.Contains(unit, "{") {
+		unit = ""
+	}
+	if strings.Contains(perUnit, "{") {
+		perUnit = ""
+	}
+	addToken := func(t string) {
+		if t != "" && !slices.Contains(tokens, t) {
+			tokens = append(tokens, t)
+		}
+	}
+	if u := unitName(unit, unitNames); u != "" {
+		addToken(u)
+	}
+	if u := unitName(perUnit, perUnitNames); u != "" {
+		if n := len(tokens); n < 2 || tokens[n-2] != "per" || tokens[n-1] != u {
+			tokens = append(tokens, "per", u)
+		}
+	}
+	switch {
+	case st.MetricType == "Sum" && st.IsMonotonic:
+		tokens = slices.DeleteFunc(tokens, func(t string) bool { return t == "total" })
+		tokens = append(tokens, "total")
+	case st.MetricType == "Gauge" && st.Unit == "1":
+		addToken("ratio")
+	}
+	name := strings.Join(tokens, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+func nameTokens(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !(r == ':' || (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))))
+	})
+}
+
+// unitName looks a unit up, or sanitizes one the table does not know. The
+// unit 1, dimensionless, has no name.
+func unitName(unit string, table map[string]string) string {
+	unit = strings.TrimSpace(unit)
+	if unit == "" || unit == "1" {
+		return ""
+	}
+	if name, ok := table[unit]; ok {
+		return name
+	}
+	return strings.Join(nameTokens(unit), "_")
+}
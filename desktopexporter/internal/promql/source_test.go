@@ -0,0 +1,187 @@
+package promql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/metrics"
+)
+
+var sourceBase = time.Unix(1_700_000_000, 0)
+
+// sourceFixture is one service reporting a cumulative counter, a delta
+// histogram and a summary, at 0s, 15s and 30s.
+func sourceFixture() pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "checkout")
+	rm.Resource().Attributes().PutStr("service.namespace", "shop")
+	rm.Resource().Attributes().PutStr("service.instance.id", "i-1")
+	sm := rm.ScopeMetrics().AppendEmpty()
+
+	counter := sm.Metrics().AppendEmpty()
+	counter.SetName("http.server.requests")
+	counter.SetUnit("{request}")
+	sum := counter.SetEmptySum()
+	sum.SetIsMonotonic(true)
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+
+	latency := sm.Metrics().AppendEmpty()
+	latency.SetName("http.server.duration")
+	latency.SetUnit("ms")
+	hist := latency.SetEmptyHistogram()
+	hist.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+
+	summary := sm.Metrics().AppendEmpty()
+	summary.SetName("gc.pause")
+	summary.SetUnit("s")
+	quantiles := summary.SetEmptySummary()
+
+	for i := 0; i < 3; i++ {
+		ts := pcommon.NewTimestampFromTime(sourceBase.Add(time.Duration(i) * 15 * time.Second))
+
+		s := sum.DataPoints().AppendEmpty()
+		s.SetTimestamp(ts)
+		s.SetIntValue(int64(10 * (i + 1)))
+		s.Attributes().PutStr("http.route", "/cart")
+
+		h := hist.DataPoints().AppendEmpty()
+		h.SetTimestamp(ts)
+		h.ExplicitBounds().FromRaw([]float64{5, 10})
+		h.BucketCounts().FromRaw([]uint64{1, 2, 1})
+		h.SetCount(4)
+		h.SetSum(30)
+
+		q := quantiles.DataPoints().AppendEmpty()
+		q.SetTimestamp(ts)
+		q.SetCount(uint64(i + 1))
+		q.SetSum(float64(i))
+		qv := q.QuantileValues().AppendEmpty()
+		qv.SetQuantile(0.99)
+		qv.SetValue(0.25)
+	}
+	return md
+}
+
+func setupSource(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.NewStore(context.Background(), "", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return metrics.Ingest(context.Background(), conn, sourceFixture(), s.FlushedIDs())
+	}))
+	return s
+}
+
+func readSource[T any](t *testing.T, s *store.Store, fn func(*Source) (T, error)) T {
+	t.Helper()
+	var out T
+	require.NoError(t, s.WithDBRead(func(db *sql.DB) error {
+		var err error
+		out, err = fn(NewSource(db))
+		return err
+	}))
+	return out
+}
+
+// Stored metrics are named and labelled as the collector's Prometheus
+// exporter would name and label them.
+func TestSourceLabelSets(t *testing.T) {
+	s := setupSource(t)
+	sets := readSource(t, s, func(src *Source) ([]Labels, error) {
+		return src.LabelSets(context.Background(), nil, 0, math.MaxInt64)
+	})
+	var got []string
+	for _, ls := range sets {
+		got = append(got, ls.String())
+	}
+	common := `instance="i-1", job="shop/checkout"`
+	assert.Equal(t, []string{
+		`{__name__="gc_pause_seconds", ` + common + `, quantile="0.99"}`,
+		`{__name__="gc_pause_seconds_count", ` + common + `}`,
+		`{__name__="gc_pause_seconds_sum", ` + common + `}`,
+		`{__name__="http_server_duration_milliseconds_bucket", ` + common + `, le="+Inf"}`,
+		`{__name__="http_server_duration_milliseconds_bucket", ` + common + `, le="10"}`,
+		`{__name__="http_server_duration_milliseconds_bucket", ` + common + `, le="5"}`,
+		`{__name__="http_server_duration_milliseconds_count", ` + common + `}`,
+		`{__name__="http_server_duration_milliseconds_sum", ` + common + `}`,
+		`{__name__="http_server_requests_total", http_route="/cart", ` + common + `}`,
+	}, got)
+
+	matchers, err := ParseSelector(`{__name__=~"http_server_duration.*", le="5"}`)
+	require.NoError(t, err)
+	sets = readSource(t, s, func(src *Source) ([]Labels, error) {
+		return src.LabelSets(context.Background(), [][]*Matcher{matchers}, 0, math.MaxInt64)
+	})
+	assert.Len(t, sets, 1)
+}
+
+// A delta histogram reads as a cumulative one, and its buckets count
+// everything at or below their bound.
+func TestSourceDeltaIsRunningTotal(t *testing.T) {
+	s := setupSource(t)
+	end := sourceBase.Add(30 * time.Second)
+	v := readSource(t, s, func(src *Source) (Value, error) {
+		return Instant(context.Background(), src, `http_server_duration_milliseconds_bucket{le="10"}`, end)
+	})
+	require.Len(t, v, 1)
+	assert.Equal(t, 9.0, v.(Vector)[0].V, "three points of three observations at or below 10")
+
+	// The running total starts at the series' first point, not the edge
+	// of the selected range.
+	v = readSource(t, s, func(src *Source) (Value, error) {
+		return Instant(context.Background(), src, `http_server_duration_milliseconds_count`, end)
+	})
+	assert.Equal(t, 12.0, v.(Vector)[0].V)
+
+	v = readSource(t, s, func(src *Source) (Value, error) {
+		return Instant(context.Background(), src,
+			`histogram_quantile(0.5, sum by (le) (increase(http_server_duration_milliseconds_bucket[1m])))`, end)
+	})
+	require.Len(t, v, 1)
+	assert.InDelta(t, 7.5, v.(Vector)[0].V, 1e-9, "half the observations are at or below 5, three quarters at or below 10")
+}
+
+func TestSourceRangeQuery(t *testing.T) {
+	s := setupSource(t)
+	m := readSource(t, s, func(src *Source) (Matrix, error) {
+		return Range(context.Background(), src, `sum by (http_route) (http_server_requests_total)`,
+			sourceBase, sourceBase.Add(30*time.Second), 15*time.Second)
+	})
+	require.Len(t, m, 1)
+	assert.Equal(t, labels("http_route", "/cart"), m[0].Labels)
+	assert.Equal(t, []float64{10, 20, 30}, []float64{m[0].Points[0].V, m[0].Points[1].V, m[0].Points[2].V})
+}
+
+func TestMetricName(t *testing.T) {
+	for _, c := range []struct {
+		series metrics.PromSeries
+		want   string
+	}{
+		{metrics.PromSeries{Name: "http.server.duration", Unit: "ms", MetricType: "Histogram"}, "http_server_duration_milliseconds"},
+		{metrics.PromSeries{Name: "system.network.io", Unit: "By", MetricType: "Sum", IsMonotonic: true}, "system_network_io_bytes_total"},
+		{metrics.PromSeries{Name: "queue.size", Unit: "{items}", MetricType: "Sum"}, "queue_size"},
+		{metrics.PromSeries{Name: "cpu.utilization", Unit: "1", MetricType: "Gauge"}, "cpu_utilization_ratio"},
+		{metrics.PromSeries{Name: "throughput", Unit: "By/s", MetricType: "Gauge"}, "throughput_bytes_per_second"},
+		{metrics.PromSeries{Name: "requests_total", MetricType: "Sum", IsMonotonic: true}, "requests_total"},
+		{metrics.PromSeries{Name: "latency_seconds", Unit: "s", MetricType: "Gauge"}, "latency_seconds"},
+		{metrics.PromSeries{Name: "2xx", MetricType: "Gauge"}, "_2xx"},
+	} {
+		assert.Equal(t, c.want, metricName(c.series), c.series.Name)
+	}
+	assert.Equal(t, "http_route", labelName("http.route"))
+	assert.Equal(t, "key_0", labelName("0"))
+	assert.Equal(t, "key_private", labelName("_private"))
+}
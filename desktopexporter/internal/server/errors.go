@@ -5,6 +5,9 @@ import (
 	"errors"
 
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/compare"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/facets"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/issues"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/metrics"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/search"
@@ -18,15 +21,19 @@ import (
 // returns the matching *NotFound error below -- never a null result. The
 // frontend service layer decides how each surfaces in the UI.
 const (
-	ErrCodeTraceNotFound   = -32001
-	ErrCodeLogNotFound     = -32002
-	ErrCodeMetricNotFound  = -32003
-	ErrCodeInvalidTraceID  = -32004
-	ErrCodeInvalidLogID    = -32005
-	ErrCodeInvalidQuery    = -32007
-	ErrCodeInvalidSpanID   = -32008
-	ErrCodeInvalidStreamID = -32009
-	ErrCodeRequestCanceled = -32010
+	ErrCodeTraceNotFound      = -32001
+	ErrCodeLogNotFound        = -32002
+	ErrCodeMetricNotFound     = -32003
+	ErrCodeInvalidTraceID     = -32004
+	ErrCodeInvalidLogID       = -32005
+	ErrCodeInvalidQuery       = -32007
+	ErrCodeInvalidSpanID      = -32008
+	ErrCodeInvalidStreamID    = -32009
+	ErrCodeRequestCanceled    = -32010
+	ErrCodeForbidden          = -32011
+	ErrCodeReadOnly           = -32012
+	ErrCodeEndpointNotAllowed = -32013
+	ErrCodeIssueNotFound      = -32014
 )
 
 // Custom JSON-RPC errors
@@ -39,6 +46,7 @@ var (
 	ErrInvalidQuery    = jsonrpc2.NewError(ErrCodeInvalidQuery, "Invalid query")
 	ErrInvalidSpanID   = jsonrpc2.NewError(ErrCodeInvalidSpanID, "Invalid span ID")
 	ErrInvalidStreamID = jsonrpc2.NewError(ErrCodeInvalidStreamID, "Invalid metric stream ID")
+	ErrIssueNotFound   = jsonrpc2.NewError(ErrCodeIssueNotFound, "Issue not found")
 
 	// ErrRequestCanceled covers a query abandoned by the caller -- the UI
 	// navigating away mid-poll, or a browser tab closing. DuckDB surfaces the
@@ -46,6 +54,20 @@ var (
 	// context.Canceled. Nobody is left to receive this, so it exists to keep
 	// cancellation out of the internal-error bucket rather than to be shown.
 	ErrRequestCanceled = jsonrpc2.NewError(ErrCodeRequestCanceled, "Request canceled")
+
+	// ErrForbidden is a mutating method called without the viewer token, or
+	// with a stale one from before a restart. See security.go.
+	ErrForbidden = jsonrpc2.NewError(ErrCodeForbidden, "Missing or invalid viewer token")
+
+	// ErrReadOnly is a mutating method called on a viewer started with
+	// --open. Distinct from ErrForbidden because no token would help: the
+	// store was opened read-only and refuses every write.
+	ErrReadOnly = jsonrpc2.NewError(ErrCodeReadOnly, "Viewer is read-only")
+
+	// ErrEndpointNotAllowed is a forwardTelemetry endpoint missing from the
+	// duckdb extension's forward_endpoints. Only whoever started the viewer
+	// can add one, which is the point.
+	ErrEndpointNotAllowed = jsonrpc2.NewError(ErrCodeEndpointNotAllowed, "Endpoint is not in forward_endpoints")
 )
 
 // mapStoreError maps store-layer sentinel errors to JSON-RPC errors.
@@ -65,9 +87,15 @@ func mapStoreError(err error) error {
 		return ErrLogsNotFound
 	case errors.Is(err, metrics.ErrStreamIDNotFound):
 		return ErrMetricNotFound
+	case errors.Is(err, issues.ErrIssueNotFound):
+		return ErrIssueNotFound
 	case errors.Is(err, spans.ErrInvalidTraceQuery), errors.Is(err, logs.ErrInvalidLogQuery),
-		errors.Is(err, metrics.ErrInvalidMetricQuery), errors.Is(err, search.ErrInvalidQuery):
+		errors.Is(err, metrics.ErrInvalidMetricQuery), errors.Is(err, issues.ErrInvalidIssueQuery),
+		errors.Is(err, facets.ErrInvalidFacetQuery), errors.Is(err, compare.ErrInvalidCompareQuery),
+		errors.Is(err, search.ErrInvalidQuery):
 		return ErrInvalidQuery
+	case errors.Is(err, store.ErrStoreReadOnly):
+		return ErrReadOnly
 	case errors.Is(err, store.ErrStoreConnectionClosed):
 		return jsonrpc2.ErrInternal
 	default:
@@ -0,0 +1,624 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/search"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+)
+
+// The Jaeger query API: the four GET routes Grafana's Jaeger data source and
+// the Jaeger UI read, answered from the store, so either can be pointed at the
+// viewer's port as though it were jaeger-query.
+//
+// Searching goes through the same query tree the viewer's own search builds,
+// and fetching through SearchSpans, so a trace reads the same here as in the
+// waterfall. Jaeger's shape is assembled from that result rather than from a
+// query of its own: processes are the trace's resources, spans come out in the
+// tree's depth-first order, and the OTel fields Jaeger has no slot for --
+// kind, status, scope, trace state -- become the tags the OTLP-to-Jaeger
+// translator writes, which are the ones legacytrace lifts back out on import.
+//
+// Reads only, like the read methods of /rpc, so no token is asked for.
+
+// defaultJaegerTraceLimit is how many traces a search returns when the caller
+// names no limit -- the Jaeger UI's own default, and Grafana's.
+const defaultJaegerTraceLimit = 20
+
+// maxJaegerTraceLimit caps what a caller can ask for. Each hit is a whole
+// trace read and reshaped, not a row of a list, so a search page's limit is
+// far too many; a larger ask is cut to this rather than refused, since the
+// Jaeger UI lets its user type any number.
+const maxJaegerTraceLimit = 50
+
+// jaegerResponse is jaeger-query's envelope. Total is filled for the service
+// and operation lists and left at zero for traces, as Jaeger does.
+type jaegerResponse struct {
+	Data   any           `json:"data"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+	Errors []jaegerError `json:"errors"`
+}
+
+type jaegerError struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+type jaegerTrace struct {
+	TraceID   string                   `json:"traceID"`
+	Spans     []jaegerSpan             `json:"spans"`
+	Processes map[string]jaegerProcess `json:"processes"`
+	Warnings  []string                 `json:"warnings"`
+}
+
+type jaegerSpan struct {
+	TraceID       string            `json:"traceID"`
+	SpanID        string            `json:"spanID"`
+	Flags         uint32            `json:"flags"`
+	OperationName string            `json:"operationName"`
+	References    []jaegerReference `json:"references"`
+	StartTime     int64             `json:"startTime"`
+	Duration      int64             `json:"duration"`
+	Tags          []jaegerKeyValue  `json:"tags"`
+	Logs          []jaegerLog       `json:"logs"`
+	ProcessID     string            `json:"processID"`
+	Warnings      []string          `json:"warnings"`
+}
+
+type jaegerReference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+type jaegerKeyValue struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+type jaegerLog struct {
+	Timestamp int64            `json:"timestamp"`
+	Fields    []jaegerKeyValue `json:"fields"`
+}
+
+type jaegerProcess struct {
+	ServiceName string           `json:"serviceName"`
+	Tags        []jaegerKeyValue `json:"tags"`
+}
+
+// storedTrace is the part of SearchSpans' response the conversion reads.
+type storedTrace struct {
+	TraceID    string                 `json:"traceID"`
+	TraceStart int64                  `json:"traceStart,string"`
+	Resources  map[string]storedOwner `json:"resources"`
+	Scopes     map[string]storedOwner `json:"scopes"`
+	Spans      []struct {
+		SpanData storedSpan `json:"spanData"`
+		Depth    int        `json:"depth"`
+	} `json:"spans"`
+}
+
+type storedOwner struct {
+	Name       string       `json:"name"`
+	Version    string       `json:"version"`
+	Attributes []storedAttr `json:"attributes"`
+}
+
+type storedAttr struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+type storedSpan struct {
+	TraceState   string       `json:"traceState"`
+	SpanID       string       `json:"spanID"`
+	ParentSpanID *string      `json:"parentSpanID"`
+	Flags        uint32       `json:"flags"`
+	Name         string       `json:"name"`
+	Kind         string       `json:"kind"`
+	Start        int64        `json:"start"`
+	Dur          int64        `json:"dur"`
+	Attributes   []storedAttr `json:"attributes"`
+	Events       []struct {
+		Name       string       `json:"name"`
+		Timestamp  int64        `json:"timestamp,string"`
+		Attributes []storedAttr `json:"attributes"`
+	} `json:"events"`
+	Links []struct {
+		TraceID    string       `json:"traceID"`
+		SpanID     string       `json:"spanID"`
+		Attributes []storedAttr `json:"attributes"`
+	} `json:"links"`
+	Resource      int    `json:"r"`
+	Scope         int    `json:"s"`
+	StatusCode    string `json:"statusCode"`
+	StatusMessage string `json:"statusMessage"`
+}
+
+// jaegerSpanKinds is the span.kind tag Jaeger uses for each stored kind.
+// Unspecified has none, so it writes no tag.
+var jaegerSpanKinds = map[string]string{
+	"Internal": "internal",
+	"Server":   "server",
+	"Client":   "client",
+	"Producer": "producer",
+	"Consumer": "consumer",
+}
+
+// servicesHandler is GET /api/services.
+func (s *Server) servicesHandler(writer http.ResponseWriter, request *http.Request) {
+	raw, err := storeRead(s.store, func(db *sql.DB) (json.RawMessage, error) {
+		return spans.ListServices(request.Context(), db)
+	})
+	s.writeJaegerList(writer, "/api/services", raw, err)
+}
+
+// operationsHandler is GET /api/services/{service}/operations: span names, as
+// the plain strings the legacy route returns rather than the {name, spanKind}
+// objects of the newer /api/operations, which neither client here needs.
+func (s *Server) operationsHandler(writer http.ResponseWriter, request *http.Request) {
+	service := request.PathValue("service")
+	raw, err := storeRead(s.store, func(db *sql.DB) (json.RawMessage, error) {
+		return spans.ListOperations(request.Context(), db, service)
+	})
+	s.writeJaegerList(writer, "/api/services/{service}/operations", raw, err)
+}
+
+func (s *Server) writeJaegerList(writer http.ResponseWriter, route string, raw json.RawMessage, err error) {
+	if err != nil {
+		s.writeJaegerStoreError(writer, route, err)
+		return
+	}
+	var names []string
+	if err := json.Unmarshal(raw, &names); err != nil {
+		s.writeJaegerStoreError(writer, route, err)
+		return
+	}
+	writeJaeger(writer, http.StatusOK, jaegerResponse{Data: names, Total: len(names)})
+}
+
+// traceHandler is GET /api/traces/{id}.
+func (s *Server) traceHandler(writer http.ResponseWriter, request *http.Request) {
+	traceID, err := parseJaegerTraceID(request.PathValue("id"))
+	if err != nil {
+		writeJaegerError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	trace, err := storeRead(s.store, func(db *sql.DB) (jaegerTrace, error) {
+		return fetchJaegerTrace(request.Context(), db, traceID)
+	})
+	if err != nil {
+		s.writeJaegerStoreError(writer, "/api/traces/{id}", err)
+		return
+	}
+	writeJaeger(writer, http.StatusOK, jaegerResponse{Data: []jaegerTrace{trace}})
+}
+
+// tracesHandler is GET /api/traces, Jaeger's trace search.
+//
+// service, operation, minDuration and maxDuration filter spans; tags -- a JSON
+// object, or repeated tag=key:value -- match a span attribute, a resource
+// attribute or an event attribute, with error and span.kind read as the
+// status and kind they were translated from. A trace is returned whole if any
+// one of its spans matches all of them, which is Jaeger's rule. start and end
+// are microseconds and default to everything stored; lookback is ignored,
+// since the Jaeger UI always sends the start it implies.
+func (s *Server) tracesHandler(writer http.ResponseWriter, request *http.Request) {
+	q, err := parseJaegerSearch(request)
+	if err != nil {
+		writeJaegerError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx := request.Context()
+	raw, err := storeRead(s.store, func(db *sql.DB) (json.RawMessage, error) {
+		return spans.SearchTracesPage(ctx, db, q.start, q.end, q.criteria, search.Page{Limit: q.limit})
+	})
+	if err != nil {
+		s.writeJaegerStoreError(writer, "/api/traces", err)
+		return
+	}
+	var page struct {
+		Items []struct {
+			TraceID string `json:"traceID"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(raw, &page); err != nil {
+		s.writeJaegerStoreError(writer, "/api/traces", err)
+		return
+	}
+
+	// One read per trace rather than one for them all, so ingest, which
+	// waits on the store's write lock, is held up for one trace at a time
+	// and not for the whole search. A trace deleted between the search and
+	// its read is left out, as it would have been a moment later.
+	traces := make([]jaegerTrace, 0, len(page.Items))
+	for _, item := range page.Items {
+		traceID, err := normalizeUUID(item.TraceID)
+		if err != nil {
+			s.writeJaegerStoreError(writer, "/api/traces", err)
+			return
+		}
+		trace, err := storeRead(s.store, func(db *sql.DB) (jaegerTrace, error) {
+			return fetchJaegerTrace(ctx, db, traceID)
+		})
+		if errors.Is(err, spans.ErrTraceIDNotFound) {
+			continue
+		}
+		if err != nil {
+			s.writeJaegerStoreError(writer, "/api/traces", err)
+			return
+		}
+		traces = append(traces, trace)
+	}
+	writeJaeger(writer, http.StatusOK, jaegerResponse{Data: traces})
+}
+
+type jaegerSearch struct {
+	start, end int64
+	limit      int
+	criteria   any
+}
+
+func parseJaegerSearch(request *http.Request) (jaegerSearch, error) {
+	values := request.URL.Query()
+	q := jaegerSearch{end: math.MaxInt64, limit: defaultJaegerTraceLimit}
+
+	var err error
+	if q.start, err = parseJaegerMicros(values.Get("start"), "start", 0); err != nil {
+		return q, err
+	}
+	if q.end, err = parseJaegerMicros(values.Get("end"), "end", math.MaxInt64); err != nil {
+		return q, err
+	}
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("limit must be a whole number, got %q", v)
+		}
+		// Zero is how the Jaeger UI says "the default".
+		if n > 0 {
+			q.limit = min(n, maxJaegerTraceLimit)
+		}
+	}
+
+	var conditions []search.QueryNode
+	if v := values.Get("service"); v != "" {
+		conditions = append(conditions, fieldCondition("serviceName", "", "=", v))
+	}
+	if v := values.Get("operation"); v != "" {
+		conditions = append(conditions, fieldCondition("name", "", "=", v))
+	}
+	for _, bound := range []struct{ param, op string }{{"minDuration", ">="}, {"maxDuration", "<="}} {
+		v := values.Get(bound.param)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return q, fmt.Errorf("%s must be a duration such as 1.2s or 100ms, got %q", bound.param, v)
+		}
+		conditions = append(conditions, fieldCondition("duration", "int64", bound.op, strconv.FormatInt(d.Nanoseconds(), 10)))
+	}
+
+	tags, err := parseJaegerTags(values["tags"], values["tag"])
+	if err != nil {
+		return q, err
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		conditions = append(conditions, tagCondition(k, tags[k]))
+	}
+
+	if len(conditions) > 0 {
+		q.criteria = &search.QueryNode{
+			ID:   "jaeger",
+			Type: "group",
+			Group: &search.QueryGroup{
+				LogicalOperator: "AND",
+				Children:        conditions,
+			},
+		}
+	}
+	return q, nil
+}
+
+// parseJaegerMicros reads a microsecond timestamp as nanoseconds.
+func parseJaegerMicros(v, name string, fallback int64) (int64, error) {
+	if v == "" {
+		return fallback, nil
+	}
+	us, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || us < 0 || us > math.MaxInt64/1000 {
+		return 0, fmt.Errorf("%s must be microseconds since the epoch, got %q", name, v)
+	}
+	return us * 1000, nil
+}
+
+// parseJaegerTags merges the two ways a tag filter arrives: tags as a JSON
+// object, which Grafana and the current Jaeger UI send, and tag=key:value,
+// the older form jaeger-query still takes.
+func parseJaegerTags(objects, pairs []string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, obj := range objects {
+		var m map[string]string
+		if err := json.Unmarshal([]byte(obj), &m); err != nil {
+			return nil, fmt.Errorf(`tags must be a JSON object of strings such as {"http.status_code":"500"}: %w`, err)
+		}
+		for k, v := range m {
+			tags[k] = v
+		}
+	}
+	for _, pair := range pairs {
+		k, v, ok := strings.Cut(pair, ":")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("tag must be key:value, got %q", pair)
+		}
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+func fieldCondition(name, typ, op, value string) search.QueryNode {
+	return search.QueryNode{
+		ID:   name,
+		Type: "condition",
+		Query: &search.Query{
+			Field:         &search.FieldDefinition{Name: name, SearchScope: "field", Type: typ},
+			FieldOperator: op,
+			Value:         value,
+		},
+	}
+}
+
+// tagCondition matches one Jaeger tag filter. Two tags are not attributes
+// here: they are what the translator writes for status and kind, and a trace
+// stored from OTLP has neither as an attribute, so they are searched as the
+// columns they came from. Anything else may be on the span, its resource --
+// Jaeger's process tags -- or one of its events, Jaeger's log fields.
+//
+// The attribute conditions carry no type, so the stored value is compared as
+// text: http.status_code=500 matches an int64 500, which is how Jaeger, whose
+// tag filters are strings, behaves.
+func tagCondition(key, value string) search.QueryNode {
+	switch key {
+	case "error":
+		if value == "true" {
+			return fieldCondition("statusCode", "", "=", "Error")
+		}
+		return fieldCondition("statusCode", "", "!=", "Error")
+	case "span.kind":
+		for kind, tag := range jaegerSpanKinds {
+			if tag == value {
+				return fieldCondition("kind", "", "=", kind)
+			}
+		}
+	}
+	children := make([]search.QueryNode, 0, 3)
+	for _, scope := range []string{"span", "resource", "event"} {
+		children = append(children, search.QueryNode{
+			ID:   scope + ":" + key,
+			Type: "condition",
+			Query: &search.Query{
+				Field:         &search.FieldDefinition{Name: key, SearchScope: "attribute", AttributeScope: scope},
+				FieldOperator: "=",
+				Value:         value,
+			},
+		})
+	}
+	return search.QueryNode{
+		ID:    "tag:" + key,
+		Type:  "group",
+		Group: &search.QueryGroup{LogicalOperator: "OR", Children: children},
+	}
+}
+
+// parseJaegerTraceID accepts a trace id as Jaeger prints it: hex, and only as
+// long as it needs to be -- a 64-bit id is 16 characters, and some clients
+// drop leading zeros as well. It is padded back to 128 bits.
+func parseJaegerTraceID(id string) (string, error) {
+	if id == "" || len(id) > 32 || strings.Trim(strings.ToLower(id), "0123456789abcdef") != "" {
+		return "", fmt.Errorf("trace id must be 1 to 32 hex characters, got %q", id)
+	}
+	return normalizeUUID(strings.Repeat("0", 32-len(id)) + id)
+}
+
+func fetchJaegerTrace(ctx context.Context, db *sql.DB, traceID string) (jaegerTrace, error) {
+	raw, err := spans.SearchSpans(ctx, db, traceID, nil)
+	if err != nil {
+		return jaegerTrace{}, err
+	}
+	var stored storedTrace
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return jaegerTrace{}, err
+	}
+	return toJaegerTrace(stored), nil
+}
+
+// toJaegerTrace reshapes one SearchSpans result. Each resource is a process,
+// numbered p1, p2 ... in the order the tree walk first reaches it -- the
+// store's own resource seq is shared across traces and would leave gaps.
+func toJaegerTrace(stored storedTrace) jaegerTrace {
+	trace := jaegerTrace{
+		TraceID:   stored.TraceID,
+		Spans:     make([]jaegerSpan, 0, len(stored.Spans)),
+		Processes: make(map[string]jaegerProcess, len(stored.Resources)),
+	}
+	processIDs := make(map[int]string, len(stored.Resources))
+	processID := func(seq int) string {
+		if id, ok := processIDs[seq]; ok {
+			return id
+		}
+		id := "p" + strconv.Itoa(len(processIDs)+1)
+		processIDs[seq] = id
+		trace.Processes[id] = jaegerProcessOf(stored.Resources[strconv.Itoa(seq)])
+		return id
+	}
+
+	for _, row := range stored.Spans {
+		sd := row.SpanData
+		span := jaegerSpan{
+			TraceID:       stored.TraceID,
+			SpanID:        sd.SpanID,
+			Flags:         sd.Flags,
+			OperationName: sd.Name,
+			References:    make([]jaegerReference, 0, 1+len(sd.Links)),
+			StartTime:     (stored.TraceStart + sd.Start) / 1000,
+			Duration:      sd.Dur / 1000,
+			Tags:          make([]jaegerKeyValue, 0, len(sd.Attributes)+4),
+			Logs:          make([]jaegerLog, 0, len(sd.Events)),
+			ProcessID:     processID(sd.Resource),
+		}
+
+		if sd.ParentSpanID != nil {
+			span.References = append(span.References, jaegerReference{"CHILD_OF", stored.TraceID, *sd.ParentSpanID})
+			// A root that has a parent is one whose parent never arrived: the
+			// walk starts a tree wherever a parent is missing.
+			if row.Depth == 0 {
+				span.Warnings = append(span.Warnings, "parent span "+*sd.ParentSpanID+" is not in this trace")
+			}
+		}
+		for _, l := range sd.Links {
+			refType := "FOLLOWS_FROM"
+			for _, a := range l.Attributes {
+				if a.Key == "opentracing.ref_type" && a.Value == "child_of" {
+					refType = "CHILD_OF"
+				}
+			}
+			span.References = append(span.References, jaegerReference{refType, l.TraceID, l.SpanID})
+		}
+
+		for _, a := range sd.Attributes {
+			span.Tags = append(span.Tags, jaegerTag(a))
+		}
+		if sc, ok := stored.Scopes[strconv.Itoa(sd.Scope)]; ok {
+			if sc.Name != "" {
+				span.Tags = append(span.Tags, jaegerKeyValue{"otel.scope.name", "string", sc.Name})
+			}
+			if sc.Version != "" {
+				span.Tags = append(span.Tags, jaegerKeyValue{"otel.scope.version", "string", sc.Version})
+			}
+		}
+		if kind, ok := jaegerSpanKinds[sd.Kind]; ok {
+			span.Tags = append(span.Tags, jaegerKeyValue{"span.kind", "string", kind})
+		}
+		switch sd.StatusCode {
+		case "Ok":
+			span.Tags = append(span.Tags, jaegerKeyValue{"otel.status_code", "string", "OK"})
+		case "Error":
+			span.Tags = append(span.Tags,
+				jaegerKeyValue{"otel.status_code", "string", "ERROR"},
+				jaegerKeyValue{"error", "bool", true})
+			if sd.StatusMessage != "" {
+				span.Tags = append(span.Tags, jaegerKeyValue{"otel.status_description", "string", sd.StatusMessage})
+			}
+		}
+		if sd.TraceState != "" {
+			span.Tags = append(span.Tags, jaegerKeyValue{"w3c.tracestate", "string", sd.TraceState})
+		}
+
+		for _, e := range sd.Events {
+			log := jaegerLog{Timestamp: e.Timestamp / 1000, Fields: make([]jaegerKeyValue, 0, 1+len(e.Attributes))}
+			if e.Name != "" {
+				log.Fields = append(log.Fields, jaegerKeyValue{"event", "string", e.Name})
+			}
+			for _, a := range e.Attributes {
+				log.Fields = append(log.Fields, jaegerTag(a))
+			}
+			span.Logs = append(span.Logs, log)
+		}
+
+		trace.Spans = append(trace.Spans, span)
+	}
+	return trace
+}
+
+// jaegerProcessOf is a resource as a process: service.name is the service,
+// and every other attribute a process tag.
+func jaegerProcessOf(res storedOwner) jaegerProcess {
+	process := jaegerProcess{Tags: make([]jaegerKeyValue, 0, len(res.Attributes))}
+	for _, a := range res.Attributes {
+		if a.Key == "service.name" && a.Type == "string" {
+			process.ServiceName = a.Value
+			continue
+		}
+		process.Tags = append(process.Tags, jaegerTag(a))
+	}
+	if process.ServiceName == "" {
+		// The OTel default for a resource that names no service, and
+		// something for the Jaeger UI to draw a row under.
+		process.ServiceName = "unknown_service"
+	}
+	return process
+}
+
+// jaegerTag types a stored attribute as Jaeger does. Jaeger has no arrays or
+// maps, so those go out as their JSON text, typed string, which is what the
+// OTLP translator does with them too. A value that does not parse as its
+// declared type -- a NaN double, say, which JSON cannot carry -- is sent as a
+// string rather than dropped.
+func jaegerTag(a storedAttr) jaegerKeyValue {
+	switch a.Type {
+	case "bool":
+		if b, err := strconv.ParseBool(a.Value); err == nil {
+			return jaegerKeyValue{a.Key, "bool", b}
+		}
+	case "int64":
+		if n, err := strconv.ParseInt(a.Value, 10, 64); err == nil {
+			return jaegerKeyValue{a.Key, "int64", n}
+		}
+	case "float64":
+		if f, err := strconv.ParseFloat(a.Value, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return jaegerKeyValue{a.Key, "float64", f}
+		}
+	case "bytes":
+		return jaegerKeyValue{a.Key, "binary", a.Value}
+	}
+	return jaegerKeyValue{a.Key, "string", a.Value}
+}
+
+// writeJaegerStoreError answers a failed read with the status Jaeger would:
+// 404 for a trace that is not there, 400 for a search the store refused.
+func (s *Server) writeJaegerStoreError(writer http.ResponseWriter, route string, err error) {
+	switch mapped := mapStoreError(err); {
+	case errors.Is(mapped, ErrTraceNotFound):
+		writeJaegerError(writer, http.StatusNotFound, "trace not found")
+	case errors.Is(mapped, ErrInvalidQuery):
+		writeJaegerError(writer, http.StatusBadRequest, err.Error())
+	case errors.Is(mapped, ErrRequestCanceled), errors.Is(err, store.ErrStoreConnectionClosed):
+		writeJaegerError(writer, http.StatusServiceUnavailable, "viewer is shutting down")
+	default:
+		s.logger.Error("serving "+route, zap.Error(err))
+		writeJaegerError(writer, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+func writeJaegerError(writer http.ResponseWriter, status int, msg string) {
+	writeJaeger(writer, status, jaegerResponse{Errors: []jaegerError{{Code: status, Msg: msg}}})
+}
+
+func writeJaeger(writer http.ResponseWriter, status int, body jaegerResponse) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	_ = json.NewEncoder(writer).Encode(body)
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/legacytrace"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/sink"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/telemetry"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite the golden response files")
+
+// jaegerFixture is two traces in Jaeger's own download format, so the
+// responses below can be read against what went in: a checkout that failed
+// in its payment call, and a healthy page load from another service.
+const jaegerFixture = `{"data": [
+  {"spans": [
+    {"traceID": "48485a3953bb6124", "spanID": "a2fb4a1d1a96d312", "operationName": "POST /checkout",
+     "startTime": 1700000000000000, "duration": 4000, "processID": "p1",
+     "tags": [{"key": "span.kind", "type": "string", "value": "server"},
+              {"key": "http.status_code", "type": "int64", "value": 500},
+              {"key": "error", "type": "bool", "value": true},
+              {"key": "otel.status_description", "type": "string", "value": "payment declined"},
+              {"key": "w3c.tracestate", "type": "string", "value": "vendor=1"}],
+     "logs": [{"timestamp": 1700000000001000, "fields": [
+              {"key": "event", "type": "string", "value": "retry"},
+              {"key": "attempt", "type": "int64", "value": 2}]}]},
+    {"traceID": "48485a3953bb6124", "spanID": "b7ad6b7169203331", "operationName": "charge",
+     "references": [{"refType": "CHILD_OF", "traceID": "48485a3953bb6124", "spanID": "a2fb4a1d1a96d312"},
+                    {"refType": "FOLLOWS_FROM", "traceID": "abcd", "spanID": "1"}],
+     "startTime": 1700000000000500, "duration": 3000, "processID": "p2",
+     "tags": [{"key": "span.kind", "type": "string", "value": "client"},
+              {"key": "otel.scope.name", "type": "string", "value": "payments-sdk"},
+              {"key": "otel.scope.version", "type": "string", "value": "1.4.0"},
+              {"key": "ratio", "type": "float64", "value": 0.25}]},
+    {"traceID": "48485a3953bb6124", "spanID": "00000000000000c3", "operationName": "late retry",
+     "references": [{"refType": "CHILD_OF", "traceID": "48485a3953bb6124", "spanID": "00000000000000ff"}],
+     "startTime": 1700000000003000, "duration": 10, "processID": "p1"}
+  ],
+  "processes": {
+    "p1": {"serviceName": "checkout", "tags": [{"key": "host.name", "type": "string", "value": "box"}]},
+    "p2": {"serviceName": "payments"}
+  }},
+  {"spans": [
+    {"traceID": "463ac35c9f6413ad48485a3953bb6124", "spanID": "0000000000000001", "operationName": "GET /",
+     "startTime": 1700000100000000, "duration": 120, "processID": "p1",
+     "tags": [{"key": "span.kind", "type": "string", "value": "server"},
+              {"key": "http.status_code", "type": "int64", "value": 200}]}
+  ],
+  "processes": {"p1": {"serviceName": "frontend"}}}
+]}`
+
+func setupJaegerServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	testServer, _ := setupJaegerServerAndStore(t)
+	return testServer
+}
+
+func setupJaegerServerAndStore(t *testing.T) (*httptest.Server, *store.Store) {
+	t.Helper()
+	str, err := store.NewStore(context.Background(), "", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { str.Close() })
+	s, err := NewServer("localhost:8000", str, zap.NewNop(), telemetry.Disabled(), nil, nil)
+	require.NoError(t, err)
+	testServer := httptest.NewServer(s.server.Handler)
+	t.Cleanup(testServer.Close)
+
+	td, err := legacytrace.Jaeger([]byte(jaegerFixture))
+	require.NoError(t, err)
+	require.NoError(t, sink.Traces(context.Background(), str, td, nil))
+	return testServer, str
+}
+
+// TestJaegerQueryAPIGolden pins each route's response to a file in
+// testdata/jaeger, written in the shape jaeger-query answers with. The files
+// are the contract: they are what Grafana and the Jaeger UI parse, so a diff
+// here is a change a client will see.
+//
+// Regenerate with: go test ./internal/server/ -run Golden -update-golden
+func TestJaegerQueryAPIGolden(t *testing.T) {
+	testServer := setupJaegerServer(t)
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"services", "/api/services", http.StatusOK},
+		{"operations", "/api/services/checkout/operations", http.StatusOK},
+		{"operations_unknown_service", "/api/services/nobody/operations", http.StatusOK},
+		{"trace", "/api/traces/48485a3953bb6124", http.StatusOK},
+		{"trace_not_found", "/api/traces/ffff", http.StatusNotFound},
+		{"trace_bad_id", "/api/traces/not-hex", http.StatusBadRequest},
+		{"search_all", "/api/traces", http.StatusOK},
+		{"search_service", "/api/traces?service=frontend&limit=20", http.StatusOK},
+		{"search_tags", "/api/traces?" + url.Values{"tags": {`{"http.status_code":"500","error":"true"}`}}.Encode(), http.StatusOK},
+		{"search_process_tag", "/api/traces?tag=host.name:box", http.StatusOK},
+		{"search_duration", "/api/traces?minDuration=3ms&maxDuration=3.5ms", http.StatusOK},
+		{"search_time_range", "/api/traces?start=1700000050000000&end=1700000200000000", http.StatusOK},
+		{"search_no_match", "/api/traces?service=checkout&operation=GET%20%2F", http.StatusOK},
+		{"search_bad_duration", "/api/traces?minDuration=soon", http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := http.Get(testServer.URL + tc.path)
+			require.NoError(t, err)
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			require.NoError(t, err)
+			assert.Equal(t, tc.status, res.StatusCode)
+			assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+			var pretty bytes.Buffer
+			require.NoError(t, json.Indent(&pretty, body, "", "  "))
+
+			path := filepath.Join("testdata", "jaeger", tc.name+".json")
+			if *updateGolden {
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+				require.NoError(t, os.WriteFile(path, pretty.Bytes(), 0o644))
+				return
+			}
+			want, err := os.ReadFile(path)
+			require.NoError(t, err, "missing golden file; run with -update-golden")
+			assert.Equal(t, string(want), pretty.String())
+		})
+	}
+}
+
+// A trace served here is a Jaeger document, so it has to import again: the
+// tags standing in for kind, status, scope and trace state are the ones the
+// importer lifts back into those fields rather than keeping as attributes.
+func TestJaegerTraceReimports(t *testing.T) {
+	testServer := setupJaegerServer(t)
+
+	res, err := http.Get(testServer.URL + "/api/traces/48485a3953bb6124")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+
+	served, err := legacytrace.Jaeger(body)
+	require.NoError(t, err)
+	original, err := legacytrace.Jaeger([]byte(jaegerFixture))
+	require.NoError(t, err)
+
+	// The fixture's second trace is not part of this response.
+	assert.Equal(t, original.SpanCount()-1, served.SpanCount())
+	assert.Equal(t, original.ResourceSpans().Len()-1, served.ResourceSpans().Len())
+	root := served.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, "POST /checkout", root.Name())
+	assert.Equal(t, "payment declined", root.Status().Message())
+	assert.Equal(t, "vendor=1", root.TraceState().AsRaw())
+	assert.Equal(t, map[string]any{"http.status_code": int64(500)}, root.Attributes().AsRaw())
+}
+
+// Every hit is read whole, so the limit is Jaeger's default when none is
+// named, and a larger one is cut to a cap rather than refused.
+func TestJaegerSearchLimit(t *testing.T) {
+	testServer, str := setupJaegerServerAndStore(t)
+
+	td := ptrace.NewTraces()
+	ss := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty()
+	for i := range maxJaegerTraceLimit + 5 {
+		span := ss.Spans().AppendEmpty()
+		span.SetTraceID(pcommon.TraceID{0xee, byte(i >> 8), byte(i)})
+		span.SetSpanID(pcommon.SpanID{0xee, byte(i >> 8), byte(i)})
+		span.SetName("bulk")
+		span.SetStartTimestamp(pcommon.Timestamp(1700000200000000000 + i))
+		span.SetEndTimestamp(pcommon.Timestamp(1700000200000001000 + i))
+	}
+	require.NoError(t, sink.Traces(context.Background(), str, td, nil))
+
+	for _, tc := range []struct {
+		query string
+		want  int
+	}{
+		{"", defaultJaegerTraceLimit},
+		{"?limit=0", defaultJaegerTraceLimit},
+		{"?limit=3", 3},
+		{"?limit=1500", maxJaegerTraceLimit},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			res, err := http.Get(testServer.URL + "/api/traces" + tc.query)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			var body struct {
+				Data []json.RawMessage `json:"data"`
+			}
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+			assert.Len(t, body.Data, tc.want)
+		})
+	}
+
+	res, err := http.Get(testServer.URL + "/api/traces?limit=-1")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
@@ -4,18 +4,31 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/forward"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/attributes"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/bundle"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/compare"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/facets"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/ingest"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/issues"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/metrics"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/search"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/stats"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.uber.org/zap"
 	"golang.org/x/exp/jsonrpc2"
 )
@@ -23,6 +36,10 @@ import (
 type JSONRPCHandler struct {
 	store  *store.Store
 	logger *zap.Logger
+
+	// forwardTo is where forwardTelemetry may send. NewServer sets it from
+	// the duckdb extension's forward_endpoints; nil refuses every endpoint.
+	forwardTo *forward.Allowlist
 }
 
 func NewJSONRPCHandler(store *store.Store, logger *zap.Logger) *JSONRPCHandler {
@@ -71,10 +88,28 @@ func (h *JSONRPCHandler) Handle(ctx context.Context, req *jsonrpc2.Request) (any
 		return h.searchTraces(ctx, req)
 	case "searchSpans":
 		return h.searchSpans(ctx, req)
+	case "describeTrace":
+		return h.describeTrace(ctx, req)
+	case "getCriticalPath":
+		return h.getCriticalPath(ctx, req)
+	case "diffTraces":
+		return h.diffTraces(ctx, req)
+	case "getServiceGraph":
+		return h.getServiceGraph(ctx, req)
+	case "getOperationStats":
+		return h.getOperationStats(ctx, req)
 	case "searchLogs":
 		return h.searchLogs(ctx, req)
 	case "getLog":
 		return h.getLog(ctx, req)
+	case "listIssues":
+		return h.listIssues(ctx, req)
+	case "getIssue":
+		return h.getIssue(ctx, req)
+	case "getFacets":
+		return h.getFacets(ctx, req)
+	case "compareAttributes":
+		return h.compareAttributes(ctx, req)
 	case "searchMetricSummaries":
 		return h.searchMetricSummaries(ctx, req)
 	case "getMetric":
@@ -109,6 +144,14 @@ func (h *JSONRPCHandler) Handle(ctx context.Context, req *jsonrpc2.Request) (any
 		return h.getStats(ctx)
 	case "getTraceSpanCount":
 		return h.getTraceSpanCount(ctx, req)
+	case "exportBundle":
+		return h.exportBundle(ctx, req)
+	case "exportOTLP":
+		return h.exportOTLP(ctx, req)
+	case "forwardTelemetry":
+		return h.forwardTelemetry(ctx, req)
+	case "rpc.discover":
+		return h.discover()
 	default:
 		return nil, jsonrpc2.ErrMethodNotFound
 	}
@@ -120,7 +163,7 @@ func (h *JSONRPCHandler) searchTraces(ctx context.Context, req *jsonrpc2.Request
 		return nil, jsonrpc2.ErrInvalidParams
 	}
 
-	if len(params) < 2 || len(params) > 3 {
+	if len(params) < 2 || len(params) > 5 {
 		return nil, jsonrpc2.ErrInvalidParams
 	}
 
@@ -135,12 +178,16 @@ func (h *JSONRPCHandler) searchTraces(ctx context.Context, req *jsonrpc2.Request
 	}
 
 	var query any
-	if len(params) == 3 {
+	if len(params) >= 3 {
 		query = params[2]
 	}
+	page, err := h.parsePageParams(params)
+	if err != nil {
+		return nil, err
+	}
 
 	summaries, err := storeRead(h.store, func(db *sql.DB) (json.RawMessage, error) {
-		return spans.SearchTraces(ctx, db, startTime, endTime, query)
+		return spans.SearchTracesPage(ctx, db, startTime, endTime, query, page)
 	})
 	if err != nil {
 		return nil, h.handleStoreError(err)
@@ -177,6 +224,170 @@ func (h *JSONRPCHandler) searchSpans(ctx context.Context, req *jsonrpc2.Request)
 	return result, nil
 }
 
+// describeTrace returns a trace's facts -- counts, services, root, depth,
+// slowest spans -- without its spans. See spans.DescribeTrace.
+func (h *JSONRPCHandler) describeTrace(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	var params []any
+	if err := decodeParams(req.Params, &params); err != nil {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	if len(params) != 1 {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	traceID, err := h.parseIDParam(params[0], ErrInvalidTraceID, normalizeUUID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := storeRead(h.store, func(db *sql.DB) (json.RawMessage, error) {
+		return spans.DescribeTrace(ctx, db, traceID)
+	})
+	if err != nil {
+		return nil, h.handleStoreError(err)
+	}
+	return result, nil
+}
+
+// getCriticalPath returns the spans a trace's latency is made of, as
+// segments, with each span's self time on the path. See spans.CriticalPath.
+func (h *JSONRPCHandler) getCriticalPath(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	var params []any
+	if err := decodeParams(req.Params, &params); err != nil {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	if len(params) != 1 {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	traceID, err := h.parseIDParam(params[0], ErrInvalidTraceID, normalizeUUID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := storeRead(h.store, func(db *sql.DB) (json.RawMessage, error) {
+		return spans.CriticalPath(ctx, db, traceID)
+	})
+	if err != nil {
+		return nil, h.handleStoreError(err)
+	}
+	return result, nil
+}
+
+// diffTraces aligns two traces' span trees and reports what was added,
+// removed or changed between them, and where the latency went. See
+// spans.DiffTraces.
+func (h *JSONRPCHandler) diffTraces(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	var params []any
+	if err := decodeParams(req.Params, &params); err != nil {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	if len(params) != 2 {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	traceA, err := h.parseIDParam(params[0], ErrInvalidTraceID, normalizeUUID)
+	if err != nil {
+		return nil, err
+	}
+	traceB, err := h.parseIDParam(params[1], ErrInvalidTraceID, normalizeUUID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := storeRead(h.store, func(db *sql.DB) (json.RawMessage, error) {
+		return spans.DiffTraces(ctx, db, traceA, traceB)
+	})
+	if err != nil {
+		return nil, h.handleStoreError(err)
+	}
+	return result, nil
+}
+
+// getServiceGraph takes the same window and query tree as searchTraces and
+// returns the service dependency graph of the traces it would list.
+func (h *JSONRPCHandler) getServiceGraph(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	var params []any
+	if err := decodeParams(req.Params, &params); err != nil {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	if len(params) < 2 || len(params) > 3 {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	startTime, err := h.parseTimestampParam(params[0], "startTime")
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := h.parseTimestampParam(params[1], "endTime")
+	if err != nil {
+		return nil, err
+	}
+	var query any
+	if len(params) == 3 {
+		query = params[2]
+	}
+	result, err := storeRead(h.store, func(db *sql.DB) (json.RawMessage, error) {
+		return spans.GetServiceGraph(ctx, db, startTime, endTime, query)
+	})
+	if err != nil {
+		return nil, h.handleStoreError(err)
+	}
+	return result, nil
+}
+
+// getOperationStats returns rate, errors and duration percentiles for the
+// spans a searchTraces query matches, grouped by groupBy and optionally
+// bucketed over time.
+func (h *JSONRPCHandler) getOperationStats(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	var params []any
+	if err := decodeParams(req.Params, &params); err != nil {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	if len(params) < 2 || len(params) > 5 {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	startTime, err := h.parseTimestampParam(params[0], "startTime")
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := h.parseTimestampParam(params[1], "endTime")
+	if err != nil {
+		return nil, err
+	}
+	var query any
+	if len(params) >= 3 {
+		query = params[2]
+	}
+
+	// Absent or null is one row per operation. An empty array is a real
+	// request -- one row for the whole selection -- so it is kept distinct.
+	groupBy := spans.DefaultOperationGroupBy
+	if len(params) >= 4 && params[3] != nil {
+		raw, ok := params[3].([]any)
+		if !ok {
+			return nil, fmt.Errorf("groupBy must be an array of dimension names: %w", jsonrpc2.ErrInvalidParams)
+		}
+		groupBy = make([]string, 0, len(raw))
+		for _, v := range raw {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("groupBy must be an array of dimension names: %w", jsonrpc2.ErrInvalidParams)
+			}
+			groupBy = append(groupBy, name)
+		}
+	}
+
+	var targetBuckets int64
+	if len(params) >= 5 && params[4] != nil {
+		targetBuckets, err = h.parseIntParam(params[4], "targetBuckets", 0, spans.MaxOperationBuckets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := storeRead(h.store, func(db *sql.DB) (json.RawMessage, error) {
+		return spans.GetOperationStats(ctx, db, startTime, endTime, query, groupBy, targetBuckets)
+	})
+	if err != nil {
+		return nil, h.handleStoreError(err)
+	}
+	return result, nil
+}
+
 func (h *JSONRPCHandler) clearTraces(ctx context.Context) (any, error) {
 	// Clear deletes the signal's own rows but never the dictionary: attribute,
 	// resource and scope rows are shared across signals, so only a sweep can
@@ -200,7 +411,7 @@ func (h *JSONRPCHandler) searchLogs(ctx context.Context, req *jsonrpc2.Request)
 	if err := decodeParams(req.Params, &params); err != nil {
 		return nil, jsonrpc2.ErrInvalidParams
 	}
-	if len(params) < 2 || len(params) > 3 {
+	if len(params) < 2 || len(params) > 5 {
 		return nil, jsonrpc2.ErrInvalidParams
 	}
 	startTime, err := h.parseTimestampParam(params[0], "startTime")
@@ -212,11 +423,15 @@ func (h *JSONRPCHandler) searchLogs(ctx context.Context, req *jsonrpc2.Request)
 		return nil, err
 	}
 	var query any
-	if len(params) == 3 {
+	if len(params) >= 3 {
 		query = params[2]
 	}
+	page, err := h.parsePageParams(params)
+	if err != nil {
+		return nil, err
+	}
 	result, err := storeRead(h.store, func(db *sql.DB) (json.RawMessage, error) {
-		return logs.Search(ctx, db, startTime, endTime, query)
+		return logs.SearchPage(ctx, db, startTime, endTime, query, page)
 	})
 	if err != nil {
 		return nil, h.handleStoreError(err)
@@ -265,7 +480,10 @@ func (h *JSONRPCHandler) getLog(ctx context.Context, req *jsonrpc2.Request) (any
 	return result, nil
 }
 
-func (h *JSONRPCHandler) searchMetricSummaries(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+// listIssues groups the exceptions in a window -- span events and logs alike
+// -- into issues by fingerprint. The query tree is searchTraces' and
+// searchLogs', applied to each signal that can express it. See issues.List.
+func (h *JSONRPCHandler) listIssues(ctx context.Context, req *jsonrpc2.Request) (any, error) {
 	var params []any
 	if err := decodeParams(req.Params, &params); err != nil {
 		return nil, jsonrpc2.ErrInvalidParams
@@ -285,8 +503,160 @@ func (h *JSONRPCHandler) searchMetricSummaries(ctx context.Context, req *jsonrpc
 	if len(params) == 3 {
 		query = params[2]
 	}
+	result, err := storeRead(h.store, func(db *sql.DB) (json.RawMessage, error) {
+		return issues.List(ctx, db, startTime, endTime, query)
+	})
+	if err != nil {
+		return nil, h.handleStoreError(err)
+	}
+	return result, nil
+}
+
+// getIssue returns one issue, found by the fingerprint listIssues reported,
+// with its latest occurrences. See issues.Get.
+func (h *JSONRPCHandler) getIssue(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	var params []any
+	if err := decodeParams(req.Params, &params); err != nil {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	if len(params) != 1 {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	fingerprint, err := h.parseIDParam(params[0], jsonrpc2.ErrInvalidParams, normalizeFingerprint)
+	if err != nil {
+		return nil, err
+	}
+	result, err := storeRead(h.store, func(db *sql.DB) (json.RawMessage, error) {
+		return issues.Get(ctx, db, fingerprint)
+	})
+	if err != nil {
+		return nil, h.handleStoreError(err)
+	}
+	return result, nil
+}
+
+// getFacets counts the most frequent values of each requested field or
+// attribute over the spans or logs a query matches. See facets.Get.
+func (h *JSONRPCHandler) getFacets(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	var params []any
+	if err := decodeParams(req.Params, &params); err != nil {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	if len(params) < 5 || len(params) > 6 {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	signal, _ := params[0].(string)
+	if signal != "traces" && signal != "logs" {
+		return nil, fmt.Errorf(`signal must be "traces" or "logs", got %v: %w`, params[0], jsonrpc2.ErrInvalidParams)
+	}
+	startTime, err := h.parseTimestampParam(params[1], "startTime")
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := h.parseTimestampParam(params[2], "endTime")
+	if err != nil {
+		return nil, err
+	}
+	query := params[3]
+
+	// Round-tripped through JSON rather than read field by field: a field
+	// definition is the same object a query condition carries, and this is
+	// how ParseQueryTree reads those.
+	raw, err := json.Marshal(params[4])
+	if err != nil {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	var fields []search.FieldDefinition
+	if err := json.Unmarshal(raw, &fields); err != nil || len(fields) == 0 || len(fields) > search.MaxFacetFields {
+		return nil, fmt.Errorf("fields must be an array of 1 to %d field definitions: %w",
+			search.MaxFacetFields, jsonrpc2.ErrInvalidParams)
+	}
+
+	limit := int64(search.DefaultFacetLimit)
+	if len(params) == 6 && params[5] != nil {
+		if limit, err = h.parseIntParam(params[5], "valueLimit", 1, search.MaxFacetLimit); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := storeRead(h.store, func(db *sql.DB) (json.RawMessage, error) {
+		return facets.Get(ctx, db, signal, startTime, endTime, query, fields, int(limit))
+	})
+	if err != nil {
+		return nil, h.handleStoreError(err)
+	}
+	return result, nil
+}
+
+// compareAttributes sets the attribute values of the spans or logs one query
+// matches against another's, most divergent keys first. See
+// compare.Attributes.
+func (h *JSONRPCHandler) compareAttributes(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	var params []any
+	if err := decodeParams(req.Params, &params); err != nil {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	if len(params) < 4 || len(params) > 5 {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	signal, _ := params[0].(string)
+	if signal != "traces" && signal != "logs" {
+		return nil, fmt.Errorf(`signal must be "traces" or "logs", got %v: %w`, params[0], jsonrpc2.ErrInvalidParams)
+	}
+	startTime, err := h.parseTimestampParam(params[1], "startTime")
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := h.parseTimestampParam(params[2], "endTime")
+	if err != nil {
+		return nil, err
+	}
+	// Unlike every other query param, the selection cannot be null: matching
+	// everything would leave the baseline nothing to be.
+	selection := params[3]
+	if selection == nil {
+		return nil, fmt.Errorf("selectionQuery must be a query tree: %w", jsonrpc2.ErrInvalidParams)
+	}
+	var baseline any
+	if len(params) == 5 {
+		baseline = params[4]
+	}
+
+	result, err := storeRead(h.store, func(db *sql.DB) (json.RawMessage, error) {
+		return compare.Attributes(ctx, db, signal, startTime, endTime, selection, baseline)
+	})
+	if err != nil {
+		return nil, h.handleStoreError(err)
+	}
+	return result, nil
+}
+
+func (h *JSONRPCHandler) searchMetricSummaries(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	var params []any
+	if err := decodeParams(req.Params, &params); err != nil {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	if len(params) < 2 || len(params) > 5 {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	startTime, err := h.parseTimestampParam(params[0], "startTime")
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := h.parseTimestampParam(params[1], "endTime")
+	if err != nil {
+		return nil, err
+	}
+	var query any
+	if len(params) >= 3 {
+		query = params[2]
+	}
+	page, err := h.parsePageParams(params)
+	if err != nil {
+		return nil, err
+	}
 	summaries, err := storeRead(h.store, func(db *sql.DB) (json.RawMessage, error) {
-		return metrics.SearchSummaries(ctx, db, startTime, endTime, query)
+		return metrics.SearchSummariesPage(ctx, db, startTime, endTime, query, page)
 	})
 	if err != nil {
 		return nil, h.handleStoreError(err)
@@ -859,7 +1229,7 @@ func (h *JSONRPCHandler) getStats(ctx context.Context) (any, error) {
 		if err != nil {
 			return nil, err
 		}
-		return stats.GetStats(ctx, db, sizeBytes, retentionCap)
+		return stats.GetStats(ctx, db, sizeBytes, retentionCap, h.store.ReadOnly())
 	})
 	if err != nil {
 		return nil, h.handleStoreError(err)
@@ -867,6 +1237,355 @@ func (h *JSONRPCHandler) getStats(ctx context.Context) (any, error) {
 	return result, nil
 }
 
+// exportBundleResult is exportBundle's answer. Data is the zip itself, which
+// encoding/json writes as base64.
+type exportBundleResult struct {
+	FileName  string `json:"fileName"`
+	SizeBytes int    `json:"sizeBytes"`
+	Data      []byte `json:"data"`
+}
+
+// exportBundle snapshots the store, or the part of it a window and a trace
+// list select, as a zipped DuckDB export. Every param is optional: a null or
+// absent bound leaves that end of the window open, and null or absent
+// traceIDs keeps every trace.
+//
+// The archive travels inside the response because the caller is the UI or the
+// export subcommand, and neither can reach a file the server writes -- the
+// server may be in a container, and the subcommand may be on the far side of
+// --host. The cost is base64 in memory twice over, which is why the bundle can
+// be narrowed.
+func (h *JSONRPCHandler) exportBundle(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	var params []any
+	if len(req.Params) > 0 {
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, jsonrpc2.ErrInvalidParams
+		}
+	}
+	if len(params) > 3 {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+
+	var filter bundle.Filter
+	var err error
+	if len(params) >= 1 && params[0] != nil {
+		if filter.Start, err = h.parseTimestampParam(params[0], "startTime"); err != nil {
+			return nil, err
+		}
+	}
+	if len(params) >= 2 && params[1] != nil {
+		if filter.End, err = h.parseTimestampParam(params[1], "endTime"); err != nil {
+			return nil, err
+		}
+		if filter.End <= 0 || filter.End < filter.Start {
+			return nil, fmt.Errorf("endTime must be after startTime: %w", jsonrpc2.ErrInvalidParams)
+		}
+	}
+	if len(params) >= 3 && params[2] != nil {
+		raw, ok := params[2].([]any)
+		if !ok || len(raw) == 0 {
+			return nil, fmt.Errorf("traceIDs must be a non-empty array of trace IDs: %w", jsonrpc2.ErrInvalidParams)
+		}
+		for _, p := range raw {
+			id, err := h.parseIDParam(p, ErrInvalidTraceID, normalizeUUID)
+			if err != nil {
+				return nil, err
+			}
+			filter.TraceIDs = append(filter.TraceIDs, id)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := bundle.Write(ctx, &buf, h.store.ExportDir(), filter, h.store.WithDBRead); err != nil {
+		return nil, h.handleStoreError(err)
+	}
+	return exportBundleResult{
+		FileName:  "otel-capture-" + time.Now().UTC().Format("20060102T150405Z") + ".zip",
+		SizeBytes: buf.Len(),
+		Data:      buf.Bytes(),
+	}, nil
+}
+
+// otlpSignal is what exportOTLP needs to know about one signal: how its ids
+// are refused, how a window selects them, and how they are rebuilt.
+type otlpSignal struct {
+	invalidIDErr error
+	// search is the signal's unpaged search, and idField the field of its rows
+	// that export takes.
+	search  func(ctx context.Context, db *sql.DB, startTime, endTime int64, query any) (json.RawMessage, error)
+	idField string
+	// export rebuilds ids and marshals them. The window reaches it only for
+	// metrics; see exportOTLP.
+	export func(ctx context.Context, db *sql.DB, ids []string, startTime, endTime int64) ([]byte, error)
+	// batches rebuilds ids for forwardTelemetry instead, one batch per
+	// resource. Metrics come with their whole history.
+	batches func(ctx context.Context, db *sql.DB, ids []string) ([]forward.Batch, error)
+}
+
+var otlpSignals = map[string]otlpSignal{
+	"traces": {
+		invalidIDErr: ErrInvalidTraceID,
+		search:       spans.SearchTraces,
+		idField:      "traceID",
+		export: func(ctx context.Context, db *sql.DB, ids []string, _, _ int64) ([]byte, error) {
+			td, err := spans.ExportOTLP(ctx, db, ids)
+			if err != nil {
+				return nil, err
+			}
+			return (&ptrace.JSONMarshaler{}).MarshalTraces(td)
+		},
+		batches: func(ctx context.Context, db *sql.DB, ids []string) ([]forward.Batch, error) {
+			td, err := spans.ExportOTLP(ctx, db, ids)
+			if err != nil {
+				return nil, err
+			}
+			return forward.TraceBatches(td), nil
+		},
+	},
+	"logs": {
+		invalidIDErr: ErrInvalidLogID,
+		search:       logs.Search,
+		idField:      "id",
+		export: func(ctx context.Context, db *sql.DB, ids []string, _, _ int64) ([]byte, error) {
+			ld, err := logs.ExportOTLP(ctx, db, ids)
+			if err != nil {
+				return nil, err
+			}
+			return (&plog.JSONMarshaler{}).MarshalLogs(ld)
+		},
+		batches: func(ctx context.Context, db *sql.DB, ids []string) ([]forward.Batch, error) {
+			ld, err := logs.ExportOTLP(ctx, db, ids)
+			if err != nil {
+				return nil, err
+			}
+			return forward.LogBatches(ld), nil
+		},
+	},
+	"metrics": {
+		invalidIDErr: ErrInvalidStreamID,
+		search:       metrics.SearchSummaries,
+		idField:      "id",
+		export: func(ctx context.Context, db *sql.DB, ids []string, startTime, endTime int64) ([]byte, error) {
+			md, err := metrics.ExportOTLP(ctx, db, ids, startTime, endTime)
+			if err != nil {
+				return nil, err
+			}
+			return (&pmetric.JSONMarshaler{}).MarshalMetrics(md)
+		},
+		batches: func(ctx context.Context, db *sql.DB, ids []string) ([]forward.Batch, error) {
+			md, err := metrics.ExportOTLP(ctx, db, ids, 0, math.MaxInt64)
+			if err != nil {
+				return nil, err
+			}
+			return forward.MetricBatches(md), nil
+		},
+	},
+}
+
+// exportOTLP rebuilds stored telemetry as the OTLP/JSON object a collector
+// would accept -- {"resourceSpans": [...]}, {"resourceLogs": [...]} or
+// {"resourceMetrics": [...]} -- so a capture can be replayed into another
+// backend or attached to a bug report as the thing that was sent.
+//
+// Params are signal, then either ids or a window:
+//
+//   - ids names what to export: trace ids, log ids or metric stream ids, as
+//     the matching search returns them. One the store does not hold is the
+//     signal's not-found error rather than a shorter export.
+//   - startTime and endTime with an optional query select instead, as the
+//     signal's search would -- every trace, log or stream it would list.
+//
+// Metric streams are long-lived, so for metrics the window also bounds which
+// datapoints come along; ids with no window exports a stream's whole history.
+// For traces and logs a window only ever selects, so it is refused beside ids
+// rather than read as a filter it would not be.
+//
+// The selection and the export run under one read lock, so a search match
+// cannot be retention-dropped before it is rebuilt.
+func (h *JSONRPCHandler) exportOTLP(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	var params []any
+	if err := decodeParams(req.Params, &params); err != nil {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	if len(params) < 1 || len(params) > 5 {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	for len(params) < 5 {
+		params = append(params, nil)
+	}
+
+	name, _ := params[0].(string)
+	signal, ok := otlpSignals[name]
+	if !ok {
+		return nil, fmt.Errorf(`signal must be "traces", "logs" or "metrics", got %v: %w`,
+			params[0], jsonrpc2.ErrInvalidParams)
+	}
+
+	var ids []string
+	if params[1] != nil {
+		raw, ok := params[1].([]any)
+		if !ok || len(raw) == 0 {
+			return nil, fmt.Errorf("ids must be a non-empty array: %w", jsonrpc2.ErrInvalidParams)
+		}
+		for _, p := range raw {
+			id, err := h.parseIDParam(p, signal.invalidIDErr, normalizeUUID)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	hasWindow := params[2] != nil || params[3] != nil
+	startTime, endTime := int64(0), int64(math.MaxInt64)
+	if hasWindow {
+		if params[2] == nil || params[3] == nil {
+			return nil, fmt.Errorf("startTime and endTime go together: %w", jsonrpc2.ErrInvalidParams)
+		}
+		var err error
+		if startTime, err = h.parseTimestampParam(params[2], "startTime"); err != nil {
+			return nil, err
+		}
+		if endTime, err = h.parseTimestampParam(params[3], "endTime"); err != nil {
+			return nil, err
+		}
+	}
+	query := params[4]
+	switch {
+	case ids == nil && !hasWindow:
+		return nil, fmt.Errorf("either ids or startTime and endTime is required: %w", jsonrpc2.ErrInvalidParams)
+	case ids != nil && query != nil:
+		return nil, fmt.Errorf("query selects what to export, so it cannot be combined with ids: %w",
+			jsonrpc2.ErrInvalidParams)
+	case ids != nil && hasWindow && name != "metrics":
+		return nil, fmt.Errorf("startTime and endTime cannot be combined with %s ids: %w",
+			name, jsonrpc2.ErrInvalidParams)
+	}
+
+	result, err := storeRead(h.store, func(db *sql.DB) (json.RawMessage, error) {
+		if ids == nil {
+			found, err := signal.search(ctx, db, startTime, endTime, query)
+			if err != nil {
+				return nil, err
+			}
+			if ids, err = searchResultIDs(found, signal.idField); err != nil {
+				return nil, err
+			}
+		}
+		return signal.export(ctx, db, ids, startTime, endTime)
+	})
+	if err != nil {
+		return nil, h.handleStoreError(err)
+	}
+	return result, nil
+}
+
+// forwardResult is forwardTelemetry's response: the endpoint as the allowlist
+// knows it, and one entry per export request sent to it.
+type forwardResult struct {
+	Endpoint string           `json:"endpoint"`
+	Batches  []forward.Result `json:"batches"`
+}
+
+// forwardTelemetry sends stored traces, logs or metric streams to another
+// OTLP endpoint -- a trace found locally, handed on to the team's backend.
+//
+// Params are endpoint, headers, signal and ids. endpoint must be one of the
+// duckdb extension's forward_endpoints, in any spelling that canonicalizes to
+// it; anything else is ErrEndpointNotAllowed before the store is touched.
+// headers is an object of strings sent with every request -- an API key,
+// typically -- or null. ids are as exportOTLP takes them, and an id the store
+// does not hold fails the whole call before anything is sent.
+//
+// The rebuild holds the read lock; sending does not, so a slow endpoint
+// never holds up retention or ingest. Each resource goes as its own request
+// and is reported on its own: a batch the endpoint refused or partly rejected
+// does not fail the call, it is in the result with ok false.
+func (h *JSONRPCHandler) forwardTelemetry(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	var params []any
+	if err := decodeParams(req.Params, &params); err != nil {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+	if len(params) != 4 {
+		return nil, jsonrpc2.ErrInvalidParams
+	}
+
+	endpointParam, _ := params[0].(string)
+	endpoint, err := h.forwardTo.Lookup(endpointParam)
+	if err != nil {
+		return nil, ErrEndpointNotAllowed
+	}
+
+	var headers map[string]string
+	if params[1] != nil {
+		raw, ok := params[1].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("headers must be an object of strings: %w", jsonrpc2.ErrInvalidParams)
+		}
+		headers = make(map[string]string, len(raw))
+		for k, v := range raw {
+			value, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("header %q must be a string: %w", k, jsonrpc2.ErrInvalidParams)
+			}
+			headers[k] = value
+		}
+	}
+
+	name, _ := params[2].(string)
+	signal, ok := otlpSignals[name]
+	if !ok {
+		return nil, fmt.Errorf(`signal must be "traces", "logs" or "metrics", got %v: %w`,
+			params[2], jsonrpc2.ErrInvalidParams)
+	}
+
+	raw, ok := params[3].([]any)
+	if !ok || len(raw) == 0 {
+		return nil, fmt.Errorf("ids must be a non-empty array: %w", jsonrpc2.ErrInvalidParams)
+	}
+	ids := make([]string, 0, len(raw))
+	for _, p := range raw {
+		id, err := h.parseIDParam(p, signal.invalidIDErr, normalizeUUID)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	batches, err := storeRead(h.store, func(db *sql.DB) ([]forward.Batch, error) {
+		return signal.batches(ctx, db, ids)
+	})
+	if err != nil {
+		return nil, h.handleStoreError(err)
+	}
+
+	results, err := forward.Send(ctx, endpoint, headers, batches)
+	if err != nil {
+		h.logger.Error("forwardTelemetry", zap.Stringer("endpoint", endpoint), zap.Error(err))
+		return nil, jsonrpc2.ErrInternal
+	}
+	return forwardResult{Endpoint: endpoint.String(), Batches: results}, nil
+}
+
+// searchResultIDs reads field out of each row of a search response, in the
+// dashed form the export queries bind. Trace summaries carry 32-char hex.
+func searchResultIDs(raw json.RawMessage, field string) ([]string, error) {
+	var rows []map[string]any
+	if err := json.Unmarshal(raw, &rows); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		s, _ := row[field].(string)
+		id, err := normalizeUUID(s)
+		if err != nil {
+			return nil, fmt.Errorf("search result %s %q: %w", field, s, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 // parseIDParams unmarshals a request's params as a non-empty array of entity
 // IDs, validating and normalizing each element with the given normalize
 // function. A malformed array returns ErrInvalidParams; a malformed element
@@ -943,6 +1662,18 @@ func normalizeSpanID(s string) (string, error) {
 	return normalizeUUID(s)
 }
 
+// normalizeFingerprint validates an issue fingerprint -- 16 hex chars, as
+// exception_fingerprint renders it -- and lowercases it to match.
+func normalizeFingerprint(s string) (string, error) {
+	if len(s) != 16 {
+		return "", fmt.Errorf("fingerprint must be 16-char hex, got %d chars", len(s))
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		return "", err
+	}
+	return strings.ToLower(s), nil
+}
+
 // parseTimestampParam parses a timestamp parameter that must be a JSON string
 // containing a base-10 int64. Large integers travel as strings to avoid
 // float64 precision loss in JSON.
@@ -990,6 +1721,73 @@ func (h *JSONRPCHandler) parseTimestampParam(param any, paramName string) (int64
 	return parsed, nil
 }
 
+// parseIntParam reads a count -- a page size, a number of buckets -- and
+// checks it against [min, max].
+//
+// Not parseTimestampParam: a count is a JSON integer, not a nanosecond
+// instant that has to travel as a string to survive float64, and its errors
+// should say what a count has to be. A float64 is taken when it is whole --
+// a count is nowhere near 2^53, so a bypassed decoder has lost nothing -- and
+// a string is refused, since a caller who quotes a page size has the wrong
+// idea of the parameter.
+func (h *JSONRPCHandler) parseIntParam(param any, paramName string, min, max int64) (int64, error) {
+	var n int64
+	switch v := param.(type) {
+	case json.Number:
+		parsed, err := strconv.ParseInt(v.String(), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s must be an integer between %d and %d, got %s: %w",
+				paramName, min, max, v, jsonrpc2.ErrInvalidParams)
+		}
+		n = parsed
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("%s must be an integer between %d and %d, got %v: %w",
+				paramName, min, max, v, jsonrpc2.ErrInvalidParams)
+		}
+		n = int64(v)
+	default:
+		return 0, fmt.Errorf("%s must be a JSON integer between %d and %d, got %T: %w",
+			paramName, min, max, param, jsonrpc2.ErrInvalidParams)
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("%s must be between %d and %d, got %d: %w",
+			paramName, min, max, n, jsonrpc2.ErrInvalidParams)
+	}
+	return n, nil
+}
+
+// parsePageParams reads the optional limit and cursor that follow a search's
+// query, at params[3] and params[4].
+//
+// Absent or null limit is search.DefaultPageLimit -- never unbounded. A limit
+// outside 1..search.MaxPageLimit is refused rather than clamped: a caller who
+// asked for 50,000 and silently got 10,000 would read the nextCursor as a
+// surprise instead of as the answer to a question they asked.
+func (h *JSONRPCHandler) parsePageParams(params []any) (search.Page, error) {
+	page := search.Page{Limit: search.DefaultPageLimit}
+	if len(params) >= 4 && params[3] != nil {
+		limit, err := h.parseIntParam(params[3], "limit", 1, search.MaxPageLimit)
+		if err != nil {
+			return page, err
+		}
+		page.Limit = int(limit)
+	}
+	if len(params) >= 5 && params[4] != nil {
+		token, ok := params[4].(string)
+		if !ok {
+			return page, fmt.Errorf("cursor must be the nextCursor string of a previous page: %w",
+				jsonrpc2.ErrInvalidParams)
+		}
+		cursor, err := search.ParseCursor(token)
+		if err != nil {
+			return page, fmt.Errorf("cursor is not one this server issued: %w", jsonrpc2.ErrInvalidParams)
+		}
+		page.After = cursor
+	}
+	return page, nil
+}
+
 // decodeParams unmarshals a request's params with UseNumber, so JSON numbers
 // arrive as json.Number rather than float64 and keep full integer precision.
 //
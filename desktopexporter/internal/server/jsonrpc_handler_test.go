@@ -1,15 +1,22 @@
 package server
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"database/sql/driver"
 
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/forward"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/metrics"
@@ -20,6 +27,7 @@ import (
 	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 	"go.uber.org/zap"
 	"golang.org/x/exp/jsonrpc2"
 )
@@ -97,6 +105,17 @@ func createRequest(method string, params any) *jsonrpc2.Request {
 
 const testTraceIDHex = "00000000000000000000000000000001"
 
+// pageItems unwraps a paged search result to its items array.
+func pageItems(t *testing.T, raw json.RawMessage) json.RawMessage {
+	t.Helper()
+	var page struct {
+		Items json.RawMessage `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &page))
+	require.NotNil(t, page.Items, "a paged search always carries items")
+	return page.Items
+}
+
 func TestSearchTraces(t *testing.T) {
 	t.Run("Empty", func(t *testing.T) {
 		handler, teardown := setupHandler(t)
@@ -109,7 +128,7 @@ func TestSearchTraces(t *testing.T) {
 		raw, ok := result.(json.RawMessage)
 		assert.True(t, ok, "Expected json.RawMessage, got %T", result)
 		var summaries []map[string]any
-		assert.NoError(t, json.Unmarshal(raw, &summaries))
+		assert.NoError(t, json.Unmarshal(pageItems(t, raw), &summaries))
 		assert.Len(t, summaries, 0)
 	})
 
@@ -124,7 +143,7 @@ func TestSearchTraces(t *testing.T) {
 		raw, ok := result.(json.RawMessage)
 		assert.True(t, ok, "Expected json.RawMessage, got %T", result)
 		var summaries []map[string]any
-		assert.NoError(t, json.Unmarshal(raw, &summaries))
+		assert.NoError(t, json.Unmarshal(pageItems(t, raw), &summaries))
 		require.Len(t, summaries, 1, "searchTraces should return the ingested trace")
 		assert.Equal(t, testTraceIDHex, summaries[0]["traceID"])
 	})
@@ -149,7 +168,7 @@ func TestSearchTraces(t *testing.T) {
 		raw, ok := result.(json.RawMessage)
 		require.True(t, ok)
 		var summaries []map[string]any
-		assert.NoError(t, json.Unmarshal(raw, &summaries))
+		assert.NoError(t, json.Unmarshal(pageItems(t, raw), &summaries))
 		assert.Empty(t, summaries)
 	})
 }
@@ -198,7 +217,7 @@ func TestClearTraces(t *testing.T) {
 	raw, ok := searchResult.(json.RawMessage)
 	assert.True(t, ok)
 	var summaries []map[string]any
-	assert.NoError(t, json.Unmarshal(raw, &summaries))
+	assert.NoError(t, json.Unmarshal(pageItems(t, raw), &summaries))
 	assert.Len(t, summaries, 0)
 }
 
@@ -214,7 +233,7 @@ func TestSearchLogs(t *testing.T) {
 		raw, ok := result.(json.RawMessage)
 		assert.True(t, ok, "Expected json.RawMessage, got %T", result)
 		var entries []map[string]any
-		assert.NoError(t, json.Unmarshal(raw, &entries))
+		assert.NoError(t, json.Unmarshal(pageItems(t, raw), &entries))
 		assert.Len(t, entries, 0)
 	})
 
@@ -229,7 +248,7 @@ func TestSearchLogs(t *testing.T) {
 		raw, ok := result.(json.RawMessage)
 		assert.True(t, ok, "Expected json.RawMessage, got %T", result)
 		var entries []map[string]any
-		assert.NoError(t, json.Unmarshal(raw, &entries))
+		assert.NoError(t, json.Unmarshal(pageItems(t, raw), &entries))
 		require.Len(t, entries, 1, "searchLogs should return the ingested log")
 		// searchLogs now returns LogSummary (lightweight) with
 		// bodyPreview rather than the full body; getLog returns
@@ -268,7 +287,7 @@ func TestSearchLogs(t *testing.T) {
 		raw, ok := result.(json.RawMessage)
 		require.True(t, ok)
 		var entries []map[string]any
-		assert.NoError(t, json.Unmarshal(raw, &entries))
+		assert.NoError(t, json.Unmarshal(pageItems(t, raw), &entries))
 		assert.Empty(t, entries)
 	})
 }
@@ -543,7 +562,7 @@ func TestClearLogs(t *testing.T) {
 	raw, ok := searchResult.(json.RawMessage)
 	require.True(t, ok)
 	var entries []map[string]any
-	assert.NoError(t, json.Unmarshal(raw, &entries))
+	assert.NoError(t, json.Unmarshal(pageItems(t, raw), &entries))
 	assert.Len(t, entries, 0)
 }
 
@@ -561,7 +580,7 @@ func TestClearMetrics(t *testing.T) {
 	raw, ok := searchResult.(json.RawMessage)
 	require.True(t, ok)
 	var summaries []map[string]any
-	assert.NoError(t, json.Unmarshal(raw, &summaries))
+	assert.NoError(t, json.Unmarshal(pageItems(t, raw), &summaries))
 	assert.Len(t, summaries, 0)
 }
 
@@ -593,7 +612,7 @@ func TestDeleteSpansByTraceID(t *testing.T) {
 	raw, ok := searchResult.(json.RawMessage)
 	require.True(t, ok)
 	var summaries []map[string]any
-	assert.NoError(t, json.Unmarshal(raw, &summaries))
+	assert.NoError(t, json.Unmarshal(pageItems(t, raw), &summaries))
 	assert.Len(t, summaries, 0, "trace should be gone after delete")
 }
 
@@ -614,7 +633,7 @@ func TestDeleteSpanByID(t *testing.T) {
 	raw, ok := searchResult.(json.RawMessage)
 	require.True(t, ok)
 	var summaries []map[string]any
-	assert.NoError(t, json.Unmarshal(raw, &summaries))
+	assert.NoError(t, json.Unmarshal(pageItems(t, raw), &summaries))
 	assert.Len(t, summaries, 0, "the trace's only span should be gone after delete")
 }
 
@@ -628,7 +647,7 @@ func TestDeleteLogByID(t *testing.T) {
 	raw, ok := searchResult.(json.RawMessage)
 	require.True(t, ok)
 	var entries []map[string]any
-	require.NoError(t, json.Unmarshal(raw, &entries))
+	require.NoError(t, json.Unmarshal(pageItems(t, raw), &entries))
 	require.Len(t, entries, 1)
 	logID, ok := entries[0]["id"].(string)
 	require.True(t, ok)
@@ -655,7 +674,7 @@ func TestDeleteMetricStream(t *testing.T) {
 	raw, ok := searchResult.(json.RawMessage)
 	require.True(t, ok)
 	var summaries []map[string]any
-	require.NoError(t, json.Unmarshal(raw, &summaries))
+	require.NoError(t, json.Unmarshal(pageItems(t, raw), &summaries))
 	require.NotEmpty(t, summaries, "fixture must provide at least one metric stream")
 	streamID, ok := summaries[0]["id"].(string)
 	require.True(t, ok)
@@ -670,7 +689,7 @@ func TestDeleteMetricStream(t *testing.T) {
 	require.NoError(t, err)
 	raw, ok = searchResult.(json.RawMessage)
 	require.True(t, ok)
-	require.NoError(t, json.Unmarshal(raw, &summaries))
+	require.NoError(t, json.Unmarshal(pageItems(t, raw), &summaries))
 	assert.Len(t, summaries, before-1, "exactly one stream should be gone")
 	for _, s := range summaries {
 		assert.NotEqual(t, streamID, s["id"], "deleted stream must not reappear")
@@ -797,6 +816,481 @@ func TestGetTraceSpanCount(t *testing.T) {
 	})
 }
 
+func TestDescribeTrace(t *testing.T) {
+	handler, teardown := setupHandlerWithData(t)
+	defer teardown()
+	ctx := context.Background()
+
+	t.Run("With Data", func(t *testing.T) {
+		result, err := handler.Handle(ctx, createRequest("describeTrace", map[string]any{"traceID": testTraceIDHex}))
+		require.NoError(t, err)
+		var d struct {
+			TraceID      string            `json:"traceID"`
+			SpanCount    int               `json:"spanCount"`
+			Depth        int               `json:"depth"`
+			SlowestSpans []json.RawMessage `json:"slowestSpans"`
+		}
+		require.NoError(t, json.Unmarshal(result.(json.RawMessage), &d))
+		assert.Equal(t, testTraceIDHex, d.TraceID)
+		assert.Equal(t, 1, d.SpanCount)
+		assert.Equal(t, 1, d.Depth)
+		assert.Len(t, d.SlowestSpans, 1)
+	})
+
+	t.Run("Unknown Trace", func(t *testing.T) {
+		_, err := handler.Handle(ctx, createRequest("describeTrace", []string{"00000000-0000-0000-0000-0000000000aa"}))
+		assert.ErrorIs(t, err, ErrTraceNotFound)
+	})
+
+	t.Run("Invalid Trace ID", func(t *testing.T) {
+		_, err := handler.Handle(ctx, createRequest("describeTrace", []string{"nope"}))
+		assert.ErrorIs(t, err, ErrInvalidTraceID)
+	})
+}
+
+func TestGetCriticalPath(t *testing.T) {
+	handler, teardown := setupHandlerWithData(t)
+	defer teardown()
+	ctx := context.Background()
+
+	t.Run("With Data", func(t *testing.T) {
+		result, err := handler.Handle(ctx, createRequest("getCriticalPath", map[string]any{"traceID": testTraceIDHex}))
+		require.NoError(t, err)
+		var p struct {
+			TraceID    string `json:"traceID"`
+			DurationNs string `json:"durationNs"`
+			Segments   []struct {
+				SpanID     *string `json:"spanID"`
+				DurationNs string  `json:"durationNs"`
+			} `json:"segments"`
+		}
+		require.NoError(t, json.Unmarshal(result.(json.RawMessage), &p))
+		assert.Equal(t, testTraceIDHex, p.TraceID)
+		require.Len(t, p.Segments, 1, "a lone span is its own critical path")
+		require.NotNil(t, p.Segments[0].SpanID)
+		assert.Equal(t, p.DurationNs, p.Segments[0].DurationNs)
+	})
+
+	t.Run("Unknown Trace", func(t *testing.T) {
+		_, err := handler.Handle(ctx, createRequest("getCriticalPath", []string{"00000000-0000-0000-0000-0000000000aa"}))
+		assert.ErrorIs(t, err, ErrTraceNotFound)
+	})
+
+	t.Run("Invalid Trace ID", func(t *testing.T) {
+		_, err := handler.Handle(ctx, createRequest("getCriticalPath", []string{"nope"}))
+		assert.ErrorIs(t, err, ErrInvalidTraceID)
+	})
+}
+
+func TestDiffTraces(t *testing.T) {
+	handler, teardown := setupHandlerWithData(t)
+	defer teardown()
+	ctx := context.Background()
+
+	t.Run("Against Itself", func(t *testing.T) {
+		result, err := handler.Handle(ctx, createRequest("diffTraces", map[string]any{
+			"traceA": testTraceIDHex, "traceB": testTraceIDHex,
+		}))
+		require.NoError(t, err)
+		var d struct {
+			DurationDeltaNs string `json:"durationDeltaNs"`
+			Summary         struct {
+				MatchedSpanCount int `json:"matchedSpanCount"`
+				AddedSpanCount   int `json:"addedSpanCount"`
+			} `json:"summary"`
+		}
+		require.NoError(t, json.Unmarshal(result.(json.RawMessage), &d))
+		assert.Equal(t, "0", d.DurationDeltaNs)
+		assert.Equal(t, 1, d.Summary.MatchedSpanCount)
+		assert.Zero(t, d.Summary.AddedSpanCount)
+	})
+
+	t.Run("Unknown Trace", func(t *testing.T) {
+		_, err := handler.Handle(ctx, createRequest("diffTraces", []string{testTraceIDHex, "00000000-0000-0000-0000-0000000000aa"}))
+		assert.ErrorIs(t, err, ErrTraceNotFound)
+	})
+
+	t.Run("Invalid Trace ID", func(t *testing.T) {
+		_, err := handler.Handle(ctx, createRequest("diffTraces", []string{testTraceIDHex, "nope"}))
+		assert.ErrorIs(t, err, ErrInvalidTraceID)
+	})
+
+	t.Run("One Trace", func(t *testing.T) {
+		_, err := handler.Handle(ctx, createRequest("diffTraces", []string{testTraceIDHex}))
+		assert.ErrorIs(t, err, jsonrpc2.ErrInvalidParams)
+	})
+}
+
+func TestIssues(t *testing.T) {
+	handler, teardown := setupHandler(t)
+	defer teardown()
+	ctx := context.Background()
+
+	ldata := plog.NewLogs()
+	rl := ldata.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "checkout")
+	lr := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.SetTimestamp(pcommon.Timestamp(1000))
+	lr.Attributes().PutStr("exception.type", "TimeoutError")
+	lr.Attributes().PutStr("exception.message", "gave up after 30 seconds")
+	require.NoError(t, handler.store.WithConn(func(conn driver.Conn) error {
+		return logs.Ingest(ctx, conn, ldata, handler.store.FlushedIDs())
+	}))
+
+	result, err := handler.Handle(ctx, createRequest("listIssues",
+		map[string]any{"startTime": "0", "endTime": strconv.FormatInt(1<<62, 10)}))
+	require.NoError(t, err)
+	var list []struct {
+		Fingerprint     string `json:"fingerprint"`
+		MessageTemplate string `json:"messageTemplate"`
+		Count           int    `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(result.(json.RawMessage), &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, "gave up after <n> seconds", list[0].MessageTemplate)
+	assert.Equal(t, 1, list[0].Count)
+
+	t.Run("Get", func(t *testing.T) {
+		result, err := handler.Handle(ctx, createRequest("getIssue", map[string]any{
+			"fingerprint": strings.ToUpper(list[0].Fingerprint),
+		}))
+		require.NoError(t, err)
+		var issue struct {
+			Fingerprint string           `json:"fingerprint"`
+			Occurrences []map[string]any `json:"occurrences"`
+		}
+		require.NoError(t, json.Unmarshal(result.(json.RawMessage), &issue))
+		assert.Equal(t, list[0].Fingerprint, issue.Fingerprint)
+		assert.Len(t, issue.Occurrences, 1)
+	})
+
+	t.Run("Unknown Fingerprint", func(t *testing.T) {
+		_, err := handler.Handle(ctx, createRequest("getIssue", []string{"0000000000000000"}))
+		assert.ErrorIs(t, err, ErrIssueNotFound)
+	})
+
+	t.Run("Malformed Fingerprint", func(t *testing.T) {
+		_, err := handler.Handle(ctx, createRequest("getIssue", []string{"not-a-fingerprint"}))
+		assert.ErrorIs(t, err, jsonrpc2.ErrInvalidParams)
+	})
+
+	t.Run("Invalid Query", func(t *testing.T) {
+		_, err := handler.Handle(ctx, createRequest("listIssues", []any{"0", "1", map[string]any{"type": "bogus"}}))
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+	})
+}
+
+func TestGetFacets(t *testing.T) {
+	handler, teardown := setupHandlerWithData(t)
+	defer teardown()
+	ctx := context.Background()
+	end := strconv.FormatInt(1<<62, 10)
+	name := map[string]any{"name": "name", "searchScope": "field"}
+
+	result, err := handler.Handle(ctx, createRequest("getFacets", map[string]any{
+		"signal": "traces", "startTime": "0", "endTime": end, "fields": []any{name}, "valueLimit": 5,
+	}))
+	require.NoError(t, err)
+	var f struct {
+		Total  int `json:"total"`
+		Facets []struct {
+			Field  map[string]any   `json:"field"`
+			Values []map[string]any `json:"values"`
+		} `json:"facets"`
+	}
+	require.NoError(t, json.Unmarshal(result.(json.RawMessage), &f))
+	assert.Equal(t, 1, f.Total)
+	require.Len(t, f.Facets, 1)
+	assert.Equal(t, "name", f.Facets[0].Field["name"])
+	assert.Len(t, f.Facets[0].Values, 1)
+
+	for desc, params := range map[string][]any{
+		"metrics":         {"metrics", "0", end, nil, []any{name}},
+		"no fields":       {"traces", "0", end, nil, []any{}},
+		"fields not list": {"traces", "0", end, nil, name},
+		"valueLimit zero": {"traces", "0", end, nil, []any{name}, 0},
+		"no fields param": {"traces", "0", end, nil},
+	} {
+		_, err := handler.Handle(ctx, createRequest("getFacets", params))
+		assert.ErrorIs(t, err, jsonrpc2.ErrInvalidParams, desc)
+	}
+
+	_, err = handler.Handle(ctx, createRequest("getFacets",
+		[]any{"logs", "0", end, nil, []any{map[string]any{"name": "x", "searchScope": "attribute", "attributeScope": "span"}}}))
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+func TestCompareAttributes(t *testing.T) {
+	handler, teardown := setupHandlerWithData(t)
+	defer teardown()
+	ctx := context.Background()
+	end := strconv.FormatInt(1<<62, 10)
+	named := map[string]any{
+		"id": "q1", "type": "condition",
+		"query": map[string]any{
+			"field":         map[string]any{"name": "name", "searchScope": "field"},
+			"fieldOperator": "=", "value": "test",
+		},
+	}
+
+	result, err := handler.Handle(ctx, createRequest("compareAttributes", map[string]any{
+		"signal": "traces", "startTime": "0", "endTime": end, "selectionQuery": named,
+	}))
+	require.NoError(t, err)
+	var c struct {
+		SelectionCount int              `json:"selectionCount"`
+		BaselineCount  int              `json:"baselineCount"`
+		Attributes     []map[string]any `json:"attributes"`
+	}
+	require.NoError(t, json.Unmarshal(result.(json.RawMessage), &c))
+	assert.Equal(t, 1, c.SelectionCount)
+	assert.Zero(t, c.BaselineCount, "the only span is selected")
+	assert.NotEmpty(t, c.Attributes)
+
+	for desc, params := range map[string][]any{
+		"metrics":           {"metrics", "0", end, named},
+		"null selection":    {"traces", "0", end, nil},
+		"no selection":      {"traces", "0", end},
+		"too many params":   {"traces", "0", end, named, nil, nil},
+		"startTime invalid": {"traces", "soon", end, named},
+	} {
+		_, err := handler.Handle(ctx, createRequest("compareAttributes", params))
+		assert.ErrorIs(t, err, jsonrpc2.ErrInvalidParams, desc)
+	}
+
+	_, err = handler.Handle(ctx, createRequest("compareAttributes", []any{"logs", "0", end, named, map[string]any{"type": "bogus"}}))
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+func TestGetServiceGraph(t *testing.T) {
+	handler, teardown := setupHandlerWithData(t)
+	defer teardown()
+	ctx := context.Background()
+
+	result, err := handler.Handle(ctx, createRequest("getServiceGraph",
+		map[string]any{"startTime": "0", "endTime": strconv.FormatInt(1<<62, 10)}))
+	require.NoError(t, err)
+	var g struct {
+		Nodes []map[string]any `json:"nodes"`
+		Edges []map[string]any `json:"edges"`
+	}
+	require.NoError(t, json.Unmarshal(result.(json.RawMessage), &g))
+	assert.Len(t, g.Nodes, 1, "one span, one service")
+	assert.Empty(t, g.Edges)
+
+	_, err = handler.Handle(ctx, createRequest("getServiceGraph", []any{"0", "1", map[string]any{"type": "bogus"}}))
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+func TestGetOperationStats(t *testing.T) {
+	handler, teardown := setupHandlerWithData(t)
+	defer teardown()
+	ctx := context.Background()
+	end := strconv.FormatInt(1<<62, 10)
+
+	result, err := handler.Handle(ctx, createRequest("getOperationStats",
+		map[string]any{"startTime": "0", "endTime": end, "groupBy": []string{"service"}, "targetBuckets": 10}))
+	require.NoError(t, err)
+	var st struct {
+		Groups []map[string]any `json:"groups"`
+	}
+	require.NoError(t, json.Unmarshal(result.(json.RawMessage), &st))
+	require.Len(t, st.Groups, 1)
+	assert.EqualValues(t, 1, st.Groups[0]["count"])
+	assert.NotContains(t, st.Groups[0], "name", "only the dimensions asked for")
+
+	_, err = handler.Handle(ctx, createRequest("getOperationStats", []any{"0", end, nil, "service"}))
+	assert.ErrorIs(t, err, jsonrpc2.ErrInvalidParams, "groupBy is an array")
+	_, err = handler.Handle(ctx, createRequest("getOperationStats", []any{"0", end, nil, []string{"host"}}))
+	assert.ErrorIs(t, err, ErrInvalidQuery, "an unknown dimension")
+	for _, buckets := range []any{-1, spans.MaxOperationBuckets + 1, 2.5, "10"} {
+		_, err = handler.Handle(ctx, createRequest("getOperationStats", []any{"0", end, nil, nil, buckets}))
+		assert.ErrorIs(t, err, jsonrpc2.ErrInvalidParams, "targetBuckets %v", buckets)
+	}
+}
+
+func TestExportBundle(t *testing.T) {
+	handler, teardown := setupHandlerWithData(t)
+	defer teardown()
+	ctx := context.Background()
+
+	// Through JSON, as a client sees it: data arrives as base64.
+	result, err := handler.Handle(ctx, createRequest("exportBundle", map[string]any{"traceIDs": []string{testTraceIDHex}}))
+	require.NoError(t, err)
+	raw, err := json.Marshal(result)
+	require.NoError(t, err)
+	var got exportBundleResult
+	require.NoError(t, json.Unmarshal(raw, &got))
+	assert.Regexp(t, `^otel-capture-\d{8}T\d{6}Z\.zip$`, got.FileName)
+	assert.Equal(t, len(got.Data), got.SizeBytes)
+	zr, err := zip.NewReader(bytes.NewReader(got.Data), int64(len(got.Data)))
+	require.NoError(t, err)
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	assert.Contains(t, names, "spans.parquet")
+
+	_, err = handler.Handle(ctx, createRequest("exportBundle", nil))
+	assert.NoError(t, err, "every param is optional")
+
+	_, err = handler.Handle(ctx, createRequest("exportBundle", []any{nil, nil, []string{"nope"}}))
+	assert.ErrorIs(t, err, ErrInvalidTraceID)
+	_, err = handler.Handle(ctx, createRequest("exportBundle", []any{nil, nil, []string{}}))
+	assert.ErrorIs(t, err, jsonrpc2.ErrInvalidParams, "an empty list would export nothing")
+	_, err = handler.Handle(ctx, createRequest("exportBundle", []any{"20", "10"}))
+	assert.ErrorIs(t, err, jsonrpc2.ErrInvalidParams)
+}
+
+func TestExportOTLP(t *testing.T) {
+	handler, teardown := setupHandlerWithData(t)
+	defer teardown()
+	ctx := context.Background()
+	window := timeRangeParams()
+
+	// By id: the trace comes back as OTLP/JSON a collector would accept.
+	result, err := handler.Handle(ctx, createRequest("exportOTLP", map[string]any{
+		"signal": "traces", "ids": []string{testTraceIDHex},
+	}))
+	require.NoError(t, err)
+	td, err := (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(result.(json.RawMessage))
+	require.NoError(t, err)
+	require.Equal(t, 1, td.SpanCount())
+	rs := td.ResourceSpans().At(0)
+	service, _ := rs.Resource().Attributes().Get("service.name")
+	assert.Equal(t, "pumpkin.pie", service.Str())
+	assert.Equal(t, "test", rs.ScopeSpans().At(0).Spans().At(0).Name())
+
+	// By window: what searchLogs would list.
+	result, err = handler.Handle(ctx, createRequest("exportOTLP", []any{"logs", nil, window[0], window[1]}))
+	require.NoError(t, err)
+	ld, err := (&plog.JSONUnmarshaler{}).UnmarshalLogs(result.(json.RawMessage))
+	require.NoError(t, err)
+	require.Equal(t, 1, ld.LogRecordCount())
+	assert.Equal(t, "test log message",
+		ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Body().Str())
+
+	// A window that matches nothing is an empty export, not an error.
+	result, err = handler.Handle(ctx, createRequest("exportOTLP", []any{"traces", nil, "0", "1"}))
+	require.NoError(t, err)
+	td, err = (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(result.(json.RawMessage))
+	require.NoError(t, err)
+	assert.Equal(t, 0, td.SpanCount())
+
+	_, err = handler.Handle(ctx, createRequest("exportOTLP", []any{"traces", []string{"0000000000000000000000000000000f"}}))
+	assert.ErrorIs(t, err, ErrTraceNotFound)
+	_, err = handler.Handle(ctx, createRequest("exportOTLP", []any{"traces", []string{"nope"}}))
+	assert.ErrorIs(t, err, ErrInvalidTraceID)
+	_, err = handler.Handle(ctx, createRequest("exportOTLP", []any{"logs", []string{"nope"}}))
+	assert.ErrorIs(t, err, ErrInvalidLogID)
+
+	for name, params := range map[string]any{
+		"unknown signal":        []any{"profiles", []string{testTraceIDHex}},
+		"nothing selected":      []any{"traces"},
+		"empty ids":             []any{"traces", []string{}},
+		"half a window":         []any{"traces", nil, window[0]},
+		"ids and query":         []any{"traces", []string{testTraceIDHex}, nil, nil, map[string]any{}},
+		"ids and trace window":  []any{"traces", []string{testTraceIDHex}, window[0], window[1]},
+		"non-string timestamps": []any{"logs", nil, true, false},
+	} {
+		_, err := handler.Handle(ctx, createRequest("exportOTLP", params))
+		assert.ErrorIs(t, err, jsonrpc2.ErrInvalidParams, name)
+	}
+}
+
+// For metrics the window bounds datapoints as well as choosing streams, and
+// stays allowed beside ids.
+func TestExportOTLPMetrics(t *testing.T) {
+	handler, teardown := setupHandlerWithMetrics(t)
+	defer teardown()
+	ctx := context.Background()
+	window := timeRangeParams()
+
+	result, err := handler.Handle(ctx, createRequest("exportOTLP", []any{"metrics", nil, window[0], window[1]}))
+	require.NoError(t, err)
+	md, err := (&pmetric.JSONUnmarshaler{}).UnmarshalMetrics(result.(json.RawMessage))
+	require.NoError(t, err)
+	require.Equal(t, 1, md.DataPointCount())
+	metric := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	assert.Equal(t, "test.gauge", metric.Name())
+	assert.Equal(t, 42.0, metric.Gauge().DataPoints().At(0).DoubleValue())
+
+	summaries, err := handler.Handle(ctx, createRequest("searchMetricSummaries", window))
+	require.NoError(t, err)
+	var rows []map[string]any
+	require.NoError(t, json.Unmarshal(pageItems(t, summaries.(json.RawMessage)), &rows))
+	require.Len(t, rows, 1)
+	streamID := rows[0]["id"].(string)
+
+	result, err = handler.Handle(ctx, createRequest("exportOTLP", []any{"metrics", []string{streamID}, "0", "1"}))
+	require.NoError(t, err)
+	md, err = (&pmetric.JSONUnmarshaler{}).UnmarshalMetrics(result.(json.RawMessage))
+	require.NoError(t, err)
+	assert.Equal(t, 0, md.DataPointCount(), "the stream exists but has nothing in the window")
+
+	_, err = handler.Handle(ctx, createRequest("exportOTLP", []any{"metrics", []string{"00000000-0000-0000-0000-000000000001"}}))
+	assert.ErrorIs(t, err, ErrMetricNotFound)
+}
+
+// forwardTelemetry sends only to a listed endpoint, and reports each batch.
+func TestForwardTelemetry(t *testing.T) {
+	handler, teardown := setupHandlerWithData(t)
+	defer teardown()
+	ctx := context.Background()
+
+	var spanCount int
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "Bearer k", r.Header.Get("Authorization"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req := ptraceotlp.NewExportRequest()
+		require.NoError(t, req.UnmarshalProto(body))
+		spanCount += req.Traces().SpanCount()
+	}))
+	defer backend.Close()
+
+	_, err := handler.Handle(ctx, createRequest("forwardTelemetry",
+		[]any{backend.URL, nil, "traces", []string{testTraceIDHex}}))
+	assert.ErrorIs(t, err, ErrEndpointNotAllowed, "nothing is listed by default")
+
+	handler.forwardTo, err = forward.NewAllowlist([]string{backend.URL})
+	require.NoError(t, err)
+
+	result, err := handler.Handle(ctx, createRequest("forwardTelemetry", map[string]any{
+		"endpoint": backend.URL + "/", "headers": map[string]any{"Authorization": "Bearer k"},
+		"signal": "traces", "ids": []string{testTraceIDHex},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, forwardResult{
+		Endpoint: backend.URL,
+		Batches:  []forward.Result{{Service: "pumpkin.pie", Items: 1, OK: true}},
+	}, result)
+	assert.Equal(t, 1, spanCount)
+
+	_, err = handler.Handle(ctx, createRequest("forwardTelemetry",
+		[]any{"https://elsewhere.example", nil, "traces", []string{testTraceIDHex}}))
+	assert.ErrorIs(t, err, ErrEndpointNotAllowed)
+	_, err = handler.Handle(ctx, createRequest("forwardTelemetry",
+		[]any{backend.URL, nil, "traces", []string{"0000000000000000000000000000000f"}}))
+	assert.ErrorIs(t, err, ErrTraceNotFound)
+	_, err = handler.Handle(ctx, createRequest("forwardTelemetry",
+		[]any{backend.URL, nil, "logs", []string{"nope"}}))
+	assert.ErrorIs(t, err, ErrInvalidLogID)
+	assert.Equal(t, 1, spanCount, "a refused call sends nothing")
+
+	for name, params := range map[string]any{
+		"no ids":            []any{backend.URL, nil, "traces"},
+		"empty ids":         []any{backend.URL, nil, "traces", []string{}},
+		"unknown signal":    []any{backend.URL, nil, "profiles", []string{testTraceIDHex}},
+		"headers not a map": []any{backend.URL, []string{"a"}, "traces", []string{testTraceIDHex}},
+		"non-string header": []any{backend.URL, map[string]any{"a": 1}, "traces", []string{testTraceIDHex}},
+	} {
+		_, err := handler.Handle(ctx, createRequest("forwardTelemetry", params))
+		assert.ErrorIs(t, err, jsonrpc2.ErrInvalidParams, name)
+	}
+}
+
 // buildTestMetrics returns pmetric.Metrics with one gauge metric for handler tests.
 func buildTestMetrics() pmetric.Metrics {
 	base := time.Now().UnixNano()
@@ -844,7 +1338,7 @@ func TestSearchMetricSummaries(t *testing.T) {
 		raw, ok := result.(json.RawMessage)
 		assert.True(t, ok, "Expected json.RawMessage, got %T", result)
 		var summaries []map[string]any
-		assert.NoError(t, json.Unmarshal(raw, &summaries))
+		assert.NoError(t, json.Unmarshal(pageItems(t, raw), &summaries))
 		assert.Len(t, summaries, 0)
 	})
 
@@ -859,7 +1353,7 @@ func TestSearchMetricSummaries(t *testing.T) {
 		raw, ok := result.(json.RawMessage)
 		assert.True(t, ok, "Expected json.RawMessage, got %T", result)
 		var summaries []map[string]any
-		require.NoError(t, json.Unmarshal(raw, &summaries))
+		require.NoError(t, json.Unmarshal(pageItems(t, raw), &summaries))
 		require.Len(t, summaries, 1, "should return one metric summary")
 		assert.Equal(t, "test.gauge", summaries[0]["name"])
 		assert.Equal(t, "A test gauge", summaries[0]["description"])
@@ -893,7 +1387,7 @@ func TestSearchMetricSummaries(t *testing.T) {
 		raw, ok := result.(json.RawMessage)
 		assert.True(t, ok, "Expected json.RawMessage, got %T", result)
 		var summaries []map[string]any
-		require.NoError(t, json.Unmarshal(raw, &summaries))
+		require.NoError(t, json.Unmarshal(pageItems(t, raw), &summaries))
 		require.Len(t, summaries, 1)
 		assert.Equal(t, "test.gauge", summaries[0]["name"])
 	})
@@ -912,7 +1406,7 @@ func TestGetMetric(t *testing.T) {
 		summaryRaw, ok := summaryResult.(json.RawMessage)
 		require.True(t, ok)
 		var summaries []map[string]any
-		require.NoError(t, json.Unmarshal(summaryRaw, &summaries))
+		require.NoError(t, json.Unmarshal(pageItems(t, summaryRaw), &summaries))
 		require.Len(t, summaries, 1)
 		streamID, ok := summaries[0]["id"].(string)
 		require.True(t, ok)
@@ -974,7 +1468,7 @@ func TestGetMetric(t *testing.T) {
 		summaryRaw, ok := summaryResult.(json.RawMessage)
 		require.True(t, ok)
 		var summaries []map[string]any
-		require.NoError(t, json.Unmarshal(summaryRaw, &summaries))
+		require.NoError(t, json.Unmarshal(pageItems(t, summaryRaw), &summaries))
 		require.Len(t, summaries, 1)
 		streamID, ok := summaries[0]["id"].(string)
 		require.True(t, ok)
@@ -1071,7 +1565,7 @@ func TestGetMetricAcceptsEveryParameter(t *testing.T) {
 		"searchMetricSummaries", []string{"0", strconv.FormatInt(1<<63-1, 10)}))
 	require.NoError(t, err)
 	var summaries []map[string]any
-	require.NoError(t, json.Unmarshal(summaryResult.(json.RawMessage), &summaries))
+	require.NoError(t, json.Unmarshal(pageItems(t, summaryResult.(json.RawMessage)), &summaries))
 	require.NotEmpty(t, summaries)
 	streamID := summaries[0]["id"].(string)
 	maxTime := strconv.FormatInt(1<<63-1, 10)
@@ -1185,3 +1679,34 @@ func TestTimestampParamErrorsSayWhatIsWrong(t *testing.T) {
 		require.Contains(t, err.Error(), `"not-a-number"`)
 	})
 }
+
+// TestIntParamReadsCounts pins parseIntParam apart from the timestamp parser
+// it replaced for counts: bounds checked in one place, and errors about a
+// count rather than a timestamp.
+func TestIntParamReadsCounts(t *testing.T) {
+	h := &JSONRPCHandler{}
+
+	var params []any
+	require.NoError(t, decodeParams(json.RawMessage(`[25, 2.0, 2.5, "25", 0, 10001]`), &params))
+
+	got, err := h.parseIntParam(params[0], "limit", 1, 10000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(25), got)
+
+	got, err = h.parseIntParam(float64(7), "limit", 1, 10000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), got, "a whole float64 is exact at a count's size")
+
+	for i, want := range map[int]string{
+		1: `limit must be an integer between 1 and 10000, got 2.0`,
+		2: `limit must be an integer between 1 and 10000, got 2.5`,
+		3: `limit must be a JSON integer between 1 and 10000, got string`,
+		4: `limit must be between 1 and 10000, got 0`,
+		5: `limit must be between 1 and 10000, got 10001`,
+	} {
+		_, err := h.parseIntParam(params[i], "limit", 1, 10000)
+		require.ErrorIs(t, err, jsonrpc2.ErrInvalidParams)
+		assert.Contains(t, err.Error(), want)
+		assert.NotContains(t, err.Error(), "timestamp")
+	}
+}
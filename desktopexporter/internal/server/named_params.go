@@ -33,11 +33,20 @@ import (
 // named call to them is refused with a message saying so, which is the honest
 // answer.
 var methodParamNames = map[string][]string{
-	"searchTraces":          {"startTime", "endTime", "query"},
+	"searchTraces":          {"startTime", "endTime", "query", "limit", "cursor"},
 	"searchSpans":           {"traceID", "query"},
-	"searchLogs":            {"startTime", "endTime", "query"},
+	"describeTrace":         {"traceID"},
+	"getCriticalPath":       {"traceID"},
+	"diffTraces":            {"traceA", "traceB"},
+	"getServiceGraph":       {"startTime", "endTime", "query"},
+	"getOperationStats":     {"startTime", "endTime", "query", "groupBy", "targetBuckets"},
+	"searchLogs":            {"startTime", "endTime", "query", "limit", "cursor"},
 	"getLog":                {"logID"},
-	"searchMetricSummaries": {"startTime", "endTime", "query"},
+	"listIssues":            {"startTime", "endTime", "query"},
+	"getIssue":              {"fingerprint"},
+	"getFacets":             {"signal", "startTime", "endTime", "query", "fields", "valueLimit"},
+	"compareAttributes":     {"signal", "startTime", "endTime", "selectionQuery", "baselineQuery"},
+	"searchMetricSummaries": {"startTime", "endTime", "query", "limit", "cursor"},
 	"getMetric": {
 		"streamID", "startTime", "endTime", "targetBuckets", "seriesIDs",
 		"quantiles", "tzOffsetNs", "fitToData", "viewBuckets",
@@ -55,6 +64,9 @@ var methodParamNames = map[string][]string{
 	"getMetricAttributes":    {"startTime", "endTime"},
 	"searchAttributes":       {"term"},
 	"getAttributesByTraceID": {"traceID"},
+	"exportBundle":           {"startTime", "endTime", "traceIDs"},
+	"exportOTLP":             {"signal", "ids", "startTime", "endTime", "query"},
+	"forwardTelemetry":       {"endpoint", "headers", "signal", "ids"},
 	"getTraceSpanCount":      {"traceID"},
 	"deleteMetricStream":     {"streamID"},
 }
@@ -0,0 +1,891 @@
+package server
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/ingest"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/search"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+)
+
+// rpc.discover serves an OpenRPC document describing every method Handle
+// dispatches.
+//
+// The document is assembled from the tables the handler already runs on
+// rather than written out beside them. Parameter names and order come from
+// methodParamNames, which is what normalizeParams reorders named calls by, so
+// the document cannot name a parameter the server would refuse. What those
+// tables cannot say -- types, which parameters are required, what comes back,
+// which domain errors a method can return -- lives in methodDocs and
+// paramDocs below, and TestOpenRPCCoversEveryMethod fails when a case is
+// added to Handle without an entry here. A generated client or an agent
+// reading this document is therefore reading the switch, one step removed.
+
+// openRPCVersion is the version of the OpenRPC specification the document
+// conforms to.
+const openRPCVersion = "1.3.2"
+
+// apiVersion is the version of the API the document describes, which OpenRPC
+// requires. Bump it when a method changes incompatibly; additive changes --
+// a new method, a new optional trailing parameter -- do not need it.
+const apiVersion = "1.0.0"
+
+// schema is a JSON Schema fragment. A map rather than a struct because the
+// fragments are irregular by nature and only ever serialised.
+type schema = map[string]any
+
+func ref(name string) schema {
+	return schema{"$ref": "#/components/schemas/" + name}
+}
+
+func arrayOf(items schema) schema {
+	return schema{"type": "array", "items": items}
+}
+
+func nullable(s schema) schema {
+	return schema{"oneOf": []any{s, schema{"type": "null"}}}
+}
+
+func object(props schema, required ...string) schema {
+	out := schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		out["required"] = required
+	}
+	return out
+}
+
+func pageOf(item string) schema {
+	return object(schema{
+		"items":      arrayOf(ref(item)),
+		"nextCursor": nullable(schema{"type": "string"}),
+	}, "items", "nextCursor")
+}
+
+var (
+	str     = schema{"type": "string"}
+	integer = schema{"type": "integer"}
+	number  = schema{"type": "number"}
+	boolean = schema{"type": "boolean"}
+)
+
+// paramDoc describes one named parameter.
+type paramDoc struct {
+	Schema      schema
+	Description string
+}
+
+// paramDocs is keyed by parameter name, not by method. A name means the same
+// thing in every method that takes it -- methodParamNames was written to that
+// rule -- so startTime is described once rather than once per search.
+var paramDocs = map[string]paramDoc{
+	"startTime": {ref("Int64"), "Window start, Unix nanoseconds."},
+	"endTime":   {ref("Int64"), "Window end, Unix nanoseconds."},
+	"query":     {nullable(ref("QueryNode")), "Search tree; null or absent matches everything in the window."},
+	"limit":     {schema{"type": "integer", "minimum": 1, "maximum": search.MaxPageLimit, "default": search.DefaultPageLimit}, "Page size."},
+	"cursor":    {nullable(str), "nextCursor from the previous page; absent for the first."},
+	"traceID":   {ref("TraceID"), "Trace ID."},
+	"traceA":    {ref("TraceID"), "The trace to compare from: the before."},
+	"traceB":    {ref("TraceID"), "The trace to compare to: the after."},
+	"spanID":    {ref("SpanID"), "Span ID."},
+	"logID":     {ref("UUID"), "Log ID, as returned by searchLogs."},
+	"fields": {
+		schema{"type": "array", "items": ref("QueryField"), "minItems": 1, "maxItems": search.MaxFacetFields},
+		"Fields and attributes to count values of, as query conditions name them. Global fields have no value to count.",
+	},
+	"valueLimit": {
+		schema{"type": "integer", "minimum": 1, "maximum": search.MaxFacetLimit, "default": search.DefaultFacetLimit},
+		"Values listed per facet; the rest are summed into otherCount.",
+	},
+	"selectionQuery": {ref("QueryNode"), "Search tree selecting the rows to explain."},
+	"baselineQuery": {
+		nullable(ref("QueryNode")),
+		"Search tree selecting the rows to compare them with, less any the selection matches; null or absent is the rest of the window.",
+	},
+	"fingerprint": {
+		schema{"type": "string", "pattern": "^[0-9a-fA-F]{16}$"},
+		"Issue fingerprint, as returned by listIssues.",
+	},
+	"streamID": {ref("UUID"), "Metric stream ID, as returned by searchMetricSummaries."},
+	"term":     {str, "Text to look for in attribute values."},
+	"traceIDs": {nullable(arrayOf(ref("TraceID"))), "Traces to keep; null or absent for all of them."},
+	"signal":   {schema{"type": "string", "enum": []string{"traces", "logs", "metrics"}}, "Which signal to read."},
+	"ids": {
+		nullable(arrayOf(str)),
+		"Trace IDs, log IDs or metric stream IDs, as the signal's search returns them. exportOTLP also takes null or absent, to select by window and query instead.",
+	},
+	"endpoint": {str, "An OTLP endpoint listed in the duckdb extension's forward_endpoints: an http(s):// base URL for OTLP/HTTP, or grpc(s)://host:port."},
+	"headers": {
+		nullable(schema{"type": "object", "additionalProperties": str}),
+		"Headers sent with every export request, as HTTP headers or gRPC metadata; null for none.",
+	},
+	"groupBy": {
+		nullable(arrayOf(schema{"type": "string", "enum": []string{"service", "name", "kind"}})),
+		"Dimensions to group spans by; absent for service and name, empty for one group.",
+	},
+
+	"targetBuckets": {
+		schema{"type": "integer", "minimum": 0},
+		"Time buckets to reduce the window to; 0 or absent for every datapoint. getOperationStats takes at most " +
+			strconv.Itoa(spans.MaxOperationBuckets) + ".",
+	},
+	"seriesIDs":            {nullable(arrayOf(ref("UUID"))), "Series to return; null or absent for all of them."},
+	"quantiles":            {nullable(arrayOf(schema{"type": "number", "minimum": 0, "maximum": 1})), "Quantiles to compute per histogram datapoint."},
+	"tzOffsetNs":           {ref("Int64"), "Viewer's UTC offset in nanoseconds, for day boundaries. Ignored when tzName is set."},
+	"fitToData":            {boolean, "Reduce over the data's own extent instead of the requested window."},
+	"viewBuckets":          {ref("Int64"), "Resolution of the scalar views; 0 or absent for none."},
+	"sparklineBuckets":     {ref("Int64"), "Resolution of the per-series sparklines; 0 or absent for none."},
+	"selectedSeriesIDs":    {nullable(arrayOf(ref("UUID"))), "Series the reader has checked, for the Selected aggregate line."},
+	"datapointSeriesIDs":   {nullable(arrayOf(ref("UUID"))), "Series that ship datapoints; null or absent for all of them."},
+	"datapointSeriesLimit": {ref("Int64"), "Cap on series shipping datapoints when datapointSeriesIDs is absent; 0 for no cap."},
+	"tzName":               {nullable(str), "IANA zone the viewer displays in; resolves DST per datapoint."},
+}
+
+// methodDoc is what the document says about a method beyond its parameter
+// names.
+type methodDoc struct {
+	Summary string
+	// Required is how many leading parameters the handler refuses to run
+	// without -- the lower bound of its length check.
+	Required int
+	// Variadic names the parameter a delete-by-ID method repeats: its params
+	// array is the list of IDs itself, which OpenRPC has no way to say, so it is
+	// described by position and flagged with x-variadic.
+	Variadic string
+	Result   schema
+	// Errors are the domain error codes from errors.go the method can return.
+	// The standard JSON-RPC codes apply to every method and are not repeated.
+	Errors []int64
+}
+
+var searchErrors = []int64{ErrCodeInvalidQuery, ErrCodeRequestCanceled}
+
+var methodDocs = map[string]methodDoc{
+	"rpc.discover": {
+		Summary: "This document.",
+		Result:  schema{"type": "object", "description": "OpenRPC document."},
+	},
+	"searchTraces": {
+		Summary:  "Trace summaries in a window, newest first, one page at a time.",
+		Required: 2,
+		Result:   pageOf("TraceSummary"),
+		Errors:   searchErrors,
+	},
+	"searchSpans": {
+		Summary:  "One trace with its spans, events, links and attributes.",
+		Required: 1,
+		Result:   ref("TraceData"),
+		Errors:   []int64{ErrCodeTraceNotFound, ErrCodeInvalidTraceID, ErrCodeInvalidQuery, ErrCodeRequestCanceled},
+	},
+	"describeTrace": {
+		Summary:  "One trace's counts, services, root, depth and slowest spans, without the spans.",
+		Required: 1,
+		Result:   ref("TraceDescription"),
+		Errors:   []int64{ErrCodeTraceNotFound, ErrCodeInvalidTraceID, ErrCodeRequestCanceled},
+	},
+	"getCriticalPath": {
+		Summary:  "The spans one trace's latency is made of, as time-ordered segments, with each span's self time on the path.",
+		Required: 1,
+		Result:   ref("CriticalPath"),
+		Errors:   []int64{ErrCodeTraceNotFound, ErrCodeInvalidTraceID, ErrCodeRequestCanceled},
+	},
+	"diffTraces": {
+		Summary:  "Two traces' span trees aligned by service, name and sibling position: added and removed spans, duration, status and attribute changes, and where the latency went.",
+		Required: 2,
+		Result:   ref("TraceDiff"),
+		Errors:   []int64{ErrCodeTraceNotFound, ErrCodeInvalidTraceID, ErrCodeRequestCanceled},
+	},
+	"getServiceGraph": {
+		Summary:  "Services and the call and messaging edges between them, over the traces searchTraces would list.",
+		Required: 2,
+		Result:   ref("ServiceGraph"),
+		Errors:   searchErrors,
+	},
+	"getOperationStats": {
+		Summary:  "Rate, error ratio and duration percentiles per service and operation, from the spans a trace query matches.",
+		Required: 2,
+		Result:   ref("OperationStats"),
+		Errors:   searchErrors,
+	},
+	"searchLogs": {
+		Summary:  "Log summaries in a window, newest first, one page at a time.",
+		Required: 2,
+		Result:   pageOf("LogSummary"),
+		Errors:   searchErrors,
+	},
+	"getLog": {
+		Summary:  "One log record in full.",
+		Required: 1,
+		Result:   ref("LogData"),
+		Errors:   []int64{ErrCodeLogNotFound, ErrCodeInvalidLogID, ErrCodeRequestCanceled},
+	},
+	"listIssues": {
+		Summary:  "Exceptions in a window, from span events and logs, grouped by type, top stack frame and message template; most frequent first.",
+		Required: 2,
+		Result:   arrayOf(ref("Issue")),
+		Errors:   searchErrors,
+	},
+	"getIssue": {
+		Summary:  "One issue across the whole store, with its latest stacktrace and newest occurrences.",
+		Required: 1,
+		Result:   ref("IssueDetail"),
+		Errors:   []int64{ErrCodeIssueNotFound, ErrCodeRequestCanceled},
+	},
+	"getFacets": {
+		Summary:  "The most frequent values of fields and attributes over the spans or logs a query matches, with the other and null counts.",
+		Required: 5,
+		Result:   ref("FacetResult"),
+		Errors:   searchErrors,
+	},
+	"compareAttributes": {
+		Summary:  "How often each attribute value occurs on the spans or logs one query matches and on those another matches, keys whose values differ most first.",
+		Required: 4,
+		Result:   ref("AttributeComparison"),
+		Errors:   searchErrors,
+	},
+	"searchMetricSummaries": {
+		Summary:  "Metric streams with data in a window, most recently seen first, one page at a time.",
+		Required: 2,
+		Result:   pageOf("MetricSummary"),
+		Errors:   searchErrors,
+	},
+	"getMetric": {
+		Summary:  "One metric stream: its series, datapoints and aggregates in a window.",
+		Required: 3,
+		Result:   ref("MetricData"),
+		Errors:   []int64{ErrCodeMetricNotFound, ErrCodeInvalidStreamID, ErrCodeRequestCanceled},
+	},
+	"getMetricAggregate": {
+		Summary:  "getMetric's cross-series aggregate alone, for a new series selection.",
+		Required: 3,
+		Result:   ref("MetricAggregateEnvelope"),
+		Errors:   []int64{ErrCodeMetricNotFound, ErrCodeInvalidStreamID, ErrCodeRequestCanceled},
+	},
+	"clearTraces":  {Summary: "Delete every span.", Result: str},
+	"clearLogs":    {Summary: "Delete every log record.", Result: str},
+	"clearMetrics": {Summary: "Delete every metric stream.", Result: str},
+	"deleteMetricStream": {
+		Summary:  "Delete one metric stream and everything under it. Unknown IDs are a no-op.",
+		Required: 1,
+		Result:   str,
+		Errors:   []int64{ErrCodeInvalidStreamID},
+	},
+	"deleteSpansByTraceID": {
+		Summary:  "Delete every span of one or more traces.",
+		Variadic: "traceID",
+		Result:   ref("DeleteResult"),
+		Errors:   []int64{ErrCodeInvalidTraceID},
+	},
+	"deleteSpanByID": {
+		Summary:  "Delete one or more spans.",
+		Variadic: "spanID",
+		Result:   ref("DeleteResult"),
+		Errors:   []int64{ErrCodeInvalidSpanID},
+	},
+	"deleteLogByID": {
+		Summary:  "Delete one or more log records.",
+		Variadic: "logID",
+		Result:   ref("DeleteResult"),
+		Errors:   []int64{ErrCodeInvalidLogID},
+	},
+	"getTraceAttributes": {
+		Summary:  "Searchable attribute keys on spans in a window.",
+		Required: 2,
+		Result:   arrayOf(ref("AttributeDefinition")),
+		Errors:   []int64{ErrCodeRequestCanceled},
+	},
+	"getLogAttributes": {
+		Summary:  "Searchable attribute keys on logs in a window.",
+		Required: 2,
+		Result:   arrayOf(ref("AttributeDefinition")),
+		Errors:   []int64{ErrCodeRequestCanceled},
+	},
+	"getMetricAttributes": {
+		Summary:  "Searchable attribute keys on metrics in a window.",
+		Required: 2,
+		Result:   arrayOf(ref("AttributeDefinition")),
+		Errors:   []int64{ErrCodeRequestCanceled},
+	},
+	"searchAttributes": {
+		Summary:  "Attribute keys, across every signal, whose values contain the term.",
+		Required: 1,
+		Result:   arrayOf(ref("AttributeMatch")),
+		Errors:   []int64{ErrCodeRequestCanceled},
+	},
+	"getAttributesByTraceID": {
+		Summary:  "Attribute keys present in one trace.",
+		Required: 1,
+		Result:   arrayOf(ref("AttributeDefinition")),
+		Errors:   []int64{ErrCodeInvalidTraceID, ErrCodeRequestCanceled},
+	},
+	"getStats": {
+		Summary: "Per-signal counts and store size.",
+		Result:  ref("Stats"),
+		Errors:  []int64{ErrCodeRequestCanceled},
+	},
+	"getTraceSpanCount": {
+		Summary:  "Number of spans in one trace; 0 for a trace the store does not hold.",
+		Required: 1,
+		Result:   integer,
+		Errors:   []int64{ErrCodeInvalidTraceID, ErrCodeRequestCanceled},
+	},
+	"exportBundle": {
+		Summary: "The store as a zipped DuckDB Parquet export, optionally narrowed to a window (null bounds are open) " +
+			"and to whole traces. Metrics are left out when traceIDs is given.",
+		Result: ref("ExportBundle"),
+		Errors: []int64{ErrCodeInvalidTraceID, ErrCodeRequestCanceled},
+	},
+	"exportOTLP": {
+		Summary: "Stored telemetry rebuilt as an OTLP/JSON export request, selected by ids or by what the signal's search " +
+			"would list for a window and query. For metrics the window also bounds the datapoints.",
+		Required: 1,
+		Result: schema{
+			"type":        "object",
+			"description": "An OTLP/JSON ExportTraceServiceRequest, ExportLogsServiceRequest or ExportMetricsServiceRequest.",
+		},
+		Errors: []int64{
+			ErrCodeTraceNotFound, ErrCodeLogNotFound, ErrCodeMetricNotFound,
+			ErrCodeInvalidTraceID, ErrCodeInvalidLogID, ErrCodeInvalidStreamID,
+			ErrCodeInvalidQuery, ErrCodeRequestCanceled,
+		},
+	},
+	"forwardTelemetry": {
+		Summary: "Stored traces, logs or metric streams rebuilt and sent to an allowlisted OTLP endpoint, one export request " +
+			"per resource. A batch the endpoint refuses is reported in the result rather than failing the call.",
+		Required: 4,
+		Result: schema{
+			"type":     "object",
+			"required": []string{"endpoint", "batches"},
+			"properties": schema{
+				"endpoint": schema{"type": "string", "description": "The endpoint in the allowlist's canonical form."},
+				"batches": arrayOf(schema{
+					"type":     "object",
+					"required": []string{"service", "items", "ok", "rejected"},
+					"properties": schema{
+						"service":  str,
+						"items":    schema{"type": "integer", "description": "Spans, log records or datapoints in the batch."},
+						"ok":       boolean,
+						"rejected": schema{"type": "integer", "description": "Items the endpoint reported dropping."},
+						"error":    schema{"type": "string", "description": "Why the batch failed or was partly rejected."},
+					},
+				}),
+			},
+		},
+		Errors: []int64{
+			ErrCodeEndpointNotAllowed, ErrCodeTraceNotFound, ErrCodeLogNotFound, ErrCodeMetricNotFound,
+			ErrCodeInvalidTraceID, ErrCodeInvalidLogID, ErrCodeInvalidStreamID, ErrCodeRequestCanceled,
+		},
+	},
+}
+
+// errorDocs pairs each domain code with its error, for the message.
+// TestOpenRPCCoversEveryMethod checks it against the ErrCode constants.
+var errorDocs = map[int64]error{
+	ErrCodeTraceNotFound:      ErrTraceNotFound,
+	ErrCodeLogNotFound:        ErrLogsNotFound,
+	ErrCodeMetricNotFound:     ErrMetricNotFound,
+	ErrCodeInvalidTraceID:     ErrInvalidTraceID,
+	ErrCodeInvalidLogID:       ErrInvalidLogID,
+	ErrCodeInvalidQuery:       ErrInvalidQuery,
+	ErrCodeInvalidSpanID:      ErrInvalidSpanID,
+	ErrCodeInvalidStreamID:    ErrInvalidStreamID,
+	ErrCodeRequestCanceled:    ErrRequestCanceled,
+	ErrCodeForbidden:          ErrForbidden,
+	ErrCodeReadOnly:           ErrReadOnly,
+	ErrCodeEndpointNotAllowed: ErrEndpointNotAllowed,
+	ErrCodeIssueNotFound:      ErrIssueNotFound,
+}
+
+// componentSchemas are the wire shapes results are built from. They describe
+// the json_object projections in the store packages at the level a client
+// needs to walk them -- every field of the list rows, the top level of the
+// detail payloads -- and stay open (no additionalProperties: false), so a
+// field added to a projection does not make the document wrong.
+func componentSchemas() schema {
+	attribute := object(schema{"key": str, "value": str, "type": ref("AttributeType")}, "key", "value", "type")
+	resource := object(schema{
+		"attributes":             arrayOf(attribute),
+		"droppedAttributesCount": integer,
+	})
+	scope := object(schema{
+		"name":                   str,
+		"version":                str,
+		"attributes":             arrayOf(attribute),
+		"droppedAttributesCount": integer,
+	})
+	attributeDefinition := object(schema{
+		"name":           str,
+		"attributeScope": ref("AttributeScope"),
+		"type":           ref("AttributeType"),
+	}, "name", "attributeScope", "type")
+	attributeMatch := object(schema{
+		"name":           str,
+		"attributeScope": ref("AttributeScope"),
+		"type":           ref("AttributeType"),
+		"matchCount":     integer,
+		"sampleValues":   arrayOf(str),
+	}, "name", "attributeScope", "type", "matchCount", "sampleValues")
+	lastReceived := nullable(ref("Int64String"))
+
+	return schema{
+		"Int64": schema{
+			"description": "A 64-bit integer, as a decimal string or a JSON number. Strings are the safe form: a number past 2^53 loses precision in most JSON decoders before it is sent.",
+			"oneOf":       []any{ref("Int64String"), integer},
+		},
+		"Int64String": schema{"type": "string", "pattern": "^-?[0-9]+$"},
+		"UUID":        schema{"type": "string", "format": "uuid"},
+		"TraceID": schema{
+			"type":        "string",
+			"description": "32 hex characters or a dashed UUID.",
+			"pattern":     "^([0-9a-fA-F]{32}|[0-9a-fA-F-]{36})$",
+		},
+		"SpanID": schema{
+			"type":        "string",
+			"description": "16 hex characters, or the zero-padded 32-character form.",
+			"pattern":     "^([0-9a-fA-F]{16}|[0-9a-fA-F]{32}|[0-9a-fA-F-]{36})$",
+		},
+		"AttributeType": schema{"type": "string", "enum": ingest.AttrTypes},
+		"AttributeScope": schema{"type": "string", "enum": []string{
+			ingest.ScopeResource, ingest.ScopeScope, ingest.ScopeSpan, ingest.ScopeEvent,
+			ingest.ScopeLink, ingest.ScopeLog, ingest.ScopeDatapoint, ingest.ScopeExemplar,
+		}},
+		"QueryNode": schema{"oneOf": []any{
+			object(schema{
+				"id":   str,
+				"type": schema{"const": "condition"},
+				"query": object(schema{
+					"field":         ref("QueryField"),
+					"fieldOperator": schema{"type": "string", "enum": []string{"=", "!=", ">", ">=", "<", "<=", "REGEXP", "CONTAINS", "NOT CONTAINS", "^", "$", "IN", "NOT IN"}},
+					"value":         str,
+				}, "field", "fieldOperator", "value"),
+			}, "id", "type", "query"),
+			object(schema{
+				"id":   str,
+				"type": schema{"const": "group"},
+				"group": object(schema{
+					"logicalOperator": schema{"type": "string", "enum": []string{"AND", "OR"}},
+					"children":        arrayOf(ref("QueryNode")),
+				}, "logicalOperator", "children"),
+			}, "id", "type", "group"),
+		}},
+		"QueryField": object(schema{
+			"name":           str,
+			"searchScope":    schema{"type": "string", "enum": []string{"field", "attribute", "global"}},
+			"attributeScope": ref("AttributeScope"),
+			"type":           ref("AttributeType"),
+			"path": schema{
+				"type":        "array",
+				"items":       str,
+				"description": "Walks into a map or any[] attribute value.",
+			},
+		}, "searchScope"),
+		"TraceSummary": object(schema{
+			"traceID":     str,
+			"hasRootSpan": boolean,
+			"rootSpan": nullable(object(schema{
+				"serviceName": nullable(str),
+				"name":        str,
+			})),
+			"startTime":  ref("Int64String"),
+			"durationNs": nullable(ref("Int64String")),
+			"spanCount":  integer,
+			"errorCount": integer,
+		}, "traceID", "hasRootSpan", "rootSpan", "startTime", "durationNs", "spanCount", "errorCount"),
+		"TraceDescription": object(schema{
+			"traceID":    str,
+			"spanCount":  integer,
+			"errorCount": integer,
+			"services":   arrayOf(str),
+			"rootSpan": nullable(object(schema{
+				"spanID":      str,
+				"name":        str,
+				"serviceName": nullable(str),
+				"durationNs":  ref("Int64String"),
+			})),
+			"depth":              schema{"type": "integer", "description": "Tree levels; 1 for a lone span."},
+			"startTime":          ref("Int64String"),
+			"endTime":            ref("Int64String"),
+			"durationNs":         ref("Int64String"),
+			"missingParentCount": schema{"type": "integer", "description": "Spans whose parent is not in the store."},
+			"unplacedSpanCount":  schema{"type": "integer", "description": "Spans on a parent cycle, which no root reaches."},
+			"slowestSpans": arrayOf(object(schema{
+				"spanID":        str,
+				"name":          str,
+				"serviceName":   nullable(str),
+				"statusCode":    str,
+				"depth":         nullable(integer),
+				"startOffsetNs": ref("Int64String"),
+				"durationNs":    ref("Int64String"),
+			})),
+		}, "traceID", "spanCount", "errorCount", "services", "rootSpan", "depth", "slowestSpans"),
+		"CriticalPath": object(schema{
+			"traceID":    str,
+			"startTime":  nullable(ref("Int64String")),
+			"endTime":    nullable(ref("Int64String")),
+			"durationNs": ref("Int64String"),
+			"segments": arrayOf(object(schema{
+				"spanID":        nullable(str),
+				"name":          nullable(str),
+				"serviceName":   nullable(str),
+				"depth":         nullable(integer),
+				"startOffsetNs": ref("Int64String"),
+				"durationNs":    ref("Int64String"),
+			}, "spanID", "startOffsetNs", "durationNs")),
+			"spans": arrayOf(object(schema{
+				"spanID":       str,
+				"name":         str,
+				"serviceName":  nullable(str),
+				"depth":        integer,
+				"selfTimeNs":   ref("Int64String"),
+				"segmentCount": integer,
+			}, "spanID", "selfTimeNs")),
+			"skewedSpanCount":   schema{"type": "integer", "description": "Spans outside their parent's bounds, clamped to them."},
+			"unplacedSpanCount": schema{"type": "integer", "description": "Spans on a parent cycle, which no root reaches."},
+		}, "traceID", "durationNs", "segments", "spans"),
+		"TraceDiff": object(schema{
+			"traceA":          ref("DiffTrace"),
+			"traceB":          ref("DiffTrace"),
+			"durationDeltaNs": ref("Int64String"),
+			"summary": object(schema{
+				"matchedSpanCount":     integer,
+				"addedSpanCount":       integer,
+				"removedSpanCount":     integer,
+				"statusChangeCount":    integer,
+				"attributeChangeCount": integer,
+				"gapDeltaNs":           ref("Int64String"),
+				"latencyContributors": arrayOf(object(schema{
+					"change":              str,
+					"serviceName":         nullable(str),
+					"name":                str,
+					"spanIDA":             nullable(str),
+					"spanIDB":             nullable(str),
+					"criticalPathDeltaNs": ref("Int64String"),
+				})),
+			}),
+			"spans": arrayOf(object(schema{
+				"change":              schema{"type": "string", "enum": []string{"matched", "added", "removed"}},
+				"serviceName":         nullable(str),
+				"name":                str,
+				"depth":               integer,
+				"a":                   nullable(ref("DiffSpanSide")),
+				"b":                   nullable(ref("DiffSpanSide")),
+				"durationDeltaNs":     nullable(ref("Int64String")),
+				"criticalPathDeltaNs": ref("Int64String"),
+				"statusChanged":       boolean,
+				"attributeChanges": arrayOf(object(schema{
+					"key": str,
+					"a":   nullable(attribute),
+					"b":   nullable(attribute),
+				}, "key", "a", "b")),
+			}, "change", "name", "depth", "a", "b")),
+		}, "traceA", "traceB", "durationDeltaNs", "summary", "spans"),
+		"DiffTrace": object(schema{
+			"traceID":           str,
+			"startTime":         nullable(ref("Int64String")),
+			"durationNs":        ref("Int64String"),
+			"spanCount":         integer,
+			"unplacedSpanCount": integer,
+		}, "traceID", "durationNs", "spanCount"),
+		"DiffSpanSide": object(schema{
+			"spanID":         str,
+			"startOffsetNs":  ref("Int64String"),
+			"durationNs":     ref("Int64String"),
+			"statusCode":     str,
+			"statusMessage":  str,
+			"criticalPathNs": ref("Int64String"),
+		}, "spanID", "durationNs"),
+		"ServiceGraph": object(schema{
+			"nodes": arrayOf(object(schema{
+				"service":    nullable(str),
+				"spanCount":  integer,
+				"errorCount": integer,
+			}, "service", "spanCount", "errorCount")),
+			"edges": arrayOf(object(schema{
+				"source":     nullable(str),
+				"target":     nullable(str),
+				"kind":       schema{"type": "string", "enum": []string{"call", "messaging"}},
+				"callCount":  integer,
+				"errorCount": integer,
+				"latencyNs":  ref("Percentiles"),
+			}, "source", "target", "kind", "callCount", "errorCount", "latencyNs")),
+		}, "nodes", "edges"),
+		"OperationStats": object(schema{
+			"windowNs":      nullable(ref("Int64String")),
+			"bucketWidthNs": nullable(ref("Int64String")),
+			"groups": arrayOf(object(schema{
+				"service":       nullable(str),
+				"name":          str,
+				"kind":          str,
+				"count":         integer,
+				"errorCount":    integer,
+				"errorRatio":    number,
+				"ratePerSecond": nullable(number),
+				"durationNs":    ref("Percentiles"),
+				"buckets": nullable(arrayOf(object(schema{
+					"start":         ref("Int64String"),
+					"count":         integer,
+					"errorCount":    integer,
+					"ratePerSecond": number,
+					"durationNs":    ref("Percentiles"),
+				}))),
+			}, "count", "errorCount", "errorRatio", "durationNs")),
+		}, "windowNs", "bucketWidthNs", "groups"),
+		"Issue": object(schema{
+			"fingerprint":     str,
+			"type":            nullable(str),
+			"messageTemplate": str,
+			"topFrame":        nullable(str),
+			"lastMessage":     nullable(str),
+			"count":           integer,
+			"spanEventCount":  integer,
+			"logCount":        integer,
+			"firstSeen":       ref("Int64String"),
+			"lastSeen":        ref("Int64String"),
+			"services":        arrayOf(str),
+			"exampleTraceIDs": arrayOf(str),
+			"exampleLogIDs":   arrayOf(ref("UUID")),
+		}, "fingerprint", "count", "firstSeen", "lastSeen", "services", "exampleTraceIDs", "exampleLogIDs"),
+		"IssueDetail": object(schema{
+			"fingerprint":     str,
+			"type":            nullable(str),
+			"messageTemplate": str,
+			"topFrame":        nullable(str),
+			"count":           integer,
+			"firstSeen":       ref("Int64String"),
+			"lastSeen":        ref("Int64String"),
+			"services":        arrayOf(str),
+			"stacktrace":      nullable(str),
+			"occurrences": arrayOf(object(schema{
+				"source":      schema{"type": "string", "enum": []string{"span", "log"}},
+				"timestamp":   ref("Int64String"),
+				"traceID":     nullable(str),
+				"spanID":      nullable(str),
+				"logID":       nullable(ref("UUID")),
+				"serviceName": nullable(str),
+				"message":     nullable(str),
+			}, "source", "timestamp")),
+		}, "fingerprint", "count", "firstSeen", "lastSeen", "services", "occurrences"),
+		"FacetResult": object(schema{
+			"total": integer,
+			"facets": arrayOf(object(schema{
+				"field": ref("QueryField"),
+				"values": arrayOf(object(schema{
+					"value": str,
+					"count": integer,
+				}, "value", "count")),
+				"distinctCount": integer,
+				"otherCount":    integer,
+				"nullCount":     integer,
+			}, "field", "values", "distinctCount", "otherCount", "nullCount")),
+		}, "total", "facets"),
+		"AttributeComparison": object(schema{
+			"selectionCount": integer,
+			"baselineCount":  integer,
+			"attributes": arrayOf(object(schema{
+				"key":                str,
+				"attributeScope":     ref("AttributeScope"),
+				"divergence":         number,
+				"distinctValueCount": integer,
+				"values": arrayOf(object(schema{
+					"value":              nullable(str),
+					"selectionCount":     integer,
+					"baselineCount":      integer,
+					"selectionFrequency": number,
+					"baselineFrequency":  number,
+					"difference":         number,
+				}, "value", "selectionCount", "baselineCount", "selectionFrequency", "baselineFrequency", "difference")),
+			}, "key", "attributeScope", "divergence", "distinctValueCount", "values")),
+		}, "selectionCount", "baselineCount", "attributes"),
+		"ExportBundle": object(schema{
+			"fileName":  str,
+			"sizeBytes": integer,
+			"data":      schema{"type": "string", "contentEncoding": "base64", "contentMediaType": "application/zip"},
+		}, "fileName", "sizeBytes", "data"),
+		"Percentiles": object(schema{
+			"p50": ref("Int64String"),
+			"p90": ref("Int64String"),
+			"p99": ref("Int64String"),
+		}, "p50", "p90", "p99"),
+		"LogSummary": object(schema{
+			"id":             ref("UUID"),
+			"timestamp":      ref("Int64String"),
+			"severityText":   str,
+			"severityNumber": integer,
+			"serviceName":    str,
+			"bodyPreview":    str,
+		}, "id", "timestamp", "severityText", "severityNumber", "serviceName", "bodyPreview"),
+		"MetricSummary": object(schema{
+			"id":                     ref("UUID"),
+			"name":                   str,
+			"description":            nullable(str),
+			"unit":                   str,
+			"metricType":             ref("MetricType"),
+			"aggregationTemporality": str,
+			"isMonotonic":            nullable(boolean),
+			"serviceName":            str,
+			"seriesCount":            integer,
+			"seriesCardinality":      integer,
+			"dataPointCount":         integer,
+			"lastValue":              nullable(number),
+			"lastSeen":               ref("Int64String"),
+		}, "id", "name", "metricType", "seriesCount", "dataPointCount", "lastSeen"),
+		"MetricType": schema{"type": "string", "enum": []string{"Empty", "Gauge", "Sum", "Histogram", "ExponentialHistogram", "Summary"}},
+		"TraceData": object(schema{
+			"traceID":           str,
+			"traceStart":        ref("Int64String"),
+			"resources":         schema{"type": "object", "additionalProperties": resource},
+			"scopes":            schema{"type": "object", "additionalProperties": scope},
+			"unplacedSpanCount": integer,
+			"spans":             arrayOf(schema{"type": "object"}),
+		}, "traceID", "traceStart", "resources", "scopes", "spans"),
+		"LogData": object(schema{
+			"id":                     ref("UUID"),
+			"timestamp":              ref("Int64String"),
+			"observedTimestamp":      ref("Int64String"),
+			"traceID":                nullable(str),
+			"spanID":                 nullable(str),
+			"severityText":           str,
+			"severityNumber":         integer,
+			"body":                   str,
+			"bodyType":               str,
+			"resource":               resource,
+			"scope":                  scope,
+			"droppedAttributesCount": integer,
+			"flags":                  integer,
+			"eventName":              str,
+			"attributes":             arrayOf(attribute),
+		}, "id", "timestamp", "body", "attributes"),
+		"MetricData": object(schema{
+			"id":              ref("UUID"),
+			"name":            str,
+			"description":     str,
+			"unit":            str,
+			"metricType":      ref("MetricType"),
+			"resource":        resource,
+			"scope":           scope,
+			"lastSeenNs":      nullable(ref("Int64String")),
+			"timeseries":      arrayOf(schema{"type": "object"}),
+			"aggregate":       nullable(arrayOf(schema{"type": "object"})),
+			"scalarAggregate": nullable(schema{"type": "object"}),
+			"datapointCount":  integer,
+			"boundsMismatch":  nullable(schema{"type": "object"}),
+			"window": object(schema{
+				"fittedToData": boolean,
+				"startNs":      nullable(ref("Int64String")),
+				"endNs":        nullable(ref("Int64String")),
+			}),
+		}, "id", "name", "metricType", "timeseries"),
+		"MetricAggregateEnvelope": object(schema{
+			"aggregate":       nullable(arrayOf(schema{"type": "object"})),
+			"scalarAggregate": nullable(schema{"type": "object"}),
+		}, "aggregate", "scalarAggregate"),
+		"AttributeDefinition": attributeDefinition,
+		"AttributeMatch":      attributeMatch,
+		"Stats": object(schema{
+			"storage": object(schema{"sizeBytes": integer, "maxSizeBytes": integer, "readOnly": boolean}),
+			"traces": object(schema{
+				"traceCount":   integer,
+				"spanCount":    integer,
+				"serviceCount": integer,
+				"errorCount":   integer,
+				"lastReceived": lastReceived,
+			}),
+			"logs": object(schema{
+				"logCount":     integer,
+				"errorCount":   integer,
+				"lastReceived": lastReceived,
+			}),
+			"metrics": object(schema{
+				"metricCount":    integer,
+				"dataPointCount": integer,
+				"lastReceived":   lastReceived,
+			}),
+		}, "storage", "traces", "logs", "metrics"),
+		"DeleteResult": object(schema{
+			"message": str,
+			"count":   schema{"type": "integer", "description": "IDs accepted, not rows removed."},
+		}, "message", "count"),
+	}
+}
+
+// openRPCDocument builds the document rpc.discover serves. Methods are sorted
+// by name so the output is stable across runs; Go map order would otherwise
+// reshuffle it on every call.
+func openRPCDocument() schema {
+	names := make([]string, 0, len(methodDocs))
+	for name := range methodDocs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	methods := make([]any, 0, len(names))
+	for _, name := range names {
+		doc := methodDocs[name]
+		method := schema{
+			"name":           name,
+			"summary":        doc.Summary,
+			"paramStructure": "either",
+			"result":         schema{"name": "result", "schema": doc.Result},
+		}
+
+		params := []any{}
+		if doc.Variadic != "" {
+			p := paramDocs[doc.Variadic]
+			method["paramStructure"] = "by-position"
+			params = append(params, schema{
+				"name":        doc.Variadic,
+				"description": p.Description + " The params array is the list of IDs: pass one or more.",
+				"required":    true,
+				"schema":      p.Schema,
+				"x-variadic":  true,
+			})
+		}
+		for i, pname := range methodParamNames[name] {
+			p := paramDocs[pname]
+			params = append(params, schema{
+				"name":        pname,
+				"description": p.Description,
+				"required":    i < doc.Required,
+				"schema":      p.Schema,
+			})
+		}
+		method["params"] = params
+
+		codes := doc.Errors
+		// The token requirement is stated by mutatingMethods, the table
+		// rpcHandler enforces, rather than repeated in each entry above.
+		// The same table decides which methods a read-only viewer refuses.
+		switch {
+		case mutatingMethods[name]:
+			method["x-requires-token"] = TokenHeader
+			codes = append(append([]int64(nil), codes...), ErrCodeForbidden, ErrCodeReadOnly)
+		case outboundMethods[name]:
+			method["x-requires-token"] = TokenHeader
+			codes = append(append([]int64(nil), codes...), ErrCodeForbidden)
+		}
+		if len(codes) > 0 {
+			errs := make([]any, 0, len(codes))
+			for _, code := range codes {
+				errs = append(errs, schema{"code": code, "message": errorDocs[code].Error()})
+			}
+			method["errors"] = errs
+		}
+		methods = append(methods, method)
+	}
+
+	return schema{
+		"openrpc": openRPCVersion,
+		"info": schema{
+			"title":       "otel-desktop-viewer",
+			"version":     apiVersion,
+			"description": "JSON-RPC 2.0 over HTTP POST. Params may be positional or named. Methods marked x-requires-token need the " + TokenHeader + " header, whose value index.html carries in <meta name=\"" + tokenMetaName + "\">.",
+		},
+		"servers":    []any{schema{"name": "local", "url": "/rpc"}},
+		"methods":    methods,
+		"components": schema{"schemas": componentSchemas()},
+	}
+}
+
+func (h *JSONRPCHandler) discover() (any, error) {
+	return openRPCDocument(), nil
+}
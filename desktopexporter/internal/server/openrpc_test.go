@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// TestOpenRPCCoversEveryMethod is the drift check rpc.discover is only as
+// good as: a method added to Handle without a methodDocs entry fails here
+// rather than going missing from every generated client.
+func TestOpenRPCCoversEveryMethod(t *testing.T) {
+	dispatched := map[string]bool{}
+	for _, m := range dispatchedMethods() {
+		dispatched[m] = true
+		_, ok := methodDocs[m]
+		assert.True(t, ok, "method %q is dispatched by Handle but has no methodDocs entry", m)
+	}
+	for m := range methodDocs {
+		assert.True(t, dispatched[m], "methodDocs describes %q, which Handle does not dispatch", m)
+	}
+
+	for m, names := range methodParamNames {
+		for _, name := range names {
+			_, ok := paramDocs[name]
+			assert.True(t, ok, "%s takes %q, which has no paramDocs entry", m, name)
+		}
+		assert.LessOrEqual(t, methodDocs[m].Required, len(names), m)
+	}
+	for m, doc := range methodDocs {
+		if doc.Variadic != "" {
+			_, ok := paramDocs[doc.Variadic]
+			assert.True(t, ok, "%s repeats %q, which has no paramDocs entry", m, doc.Variadic)
+			assert.NotContains(t, methodParamNames, m, "%s cannot be both variadic and named", m)
+		}
+		assert.NotNil(t, doc.Result, "%s has no result schema", m)
+		for _, code := range doc.Errors {
+			_, ok := errorDocs[code]
+			assert.True(t, ok, "%s lists error %d, which errorDocs does not know", m, code)
+		}
+	}
+
+	for _, code := range errorCodeConsts(t) {
+		_, ok := errorDocs[code]
+		assert.True(t, ok, "error code %d is declared in errors.go but missing from errorDocs", code)
+	}
+}
+
+// The Required counts are the handlers' lower bounds, written out by hand, so
+// they are checked against the handlers: one parameter short must be refused,
+// and the full named set must get past the length check.
+func TestOpenRPCRequiredMatchesHandlers(t *testing.T) {
+	handler, teardown := setupHandler(t)
+	defer teardown()
+	ctx, cancel := context.WithCancel(context.Background())
+	// Cancelled up front, so a request that passes parameter checks stops at
+	// the store instead of running against it.
+	cancel()
+
+	for m, names := range methodParamNames {
+		doc := methodDocs[m]
+		if doc.Required == 0 {
+			continue
+		}
+		short := make([]any, doc.Required-1)
+		_, err := handler.Handle(ctx, createRequest(m, short))
+		assert.ErrorIs(t, err, jsonrpc2.ErrInvalidParams, "%s with %d params", m, len(short))
+
+		long := make([]any, len(names)+1)
+		_, err = handler.Handle(ctx, createRequest(m, long))
+		assert.ErrorIs(t, err, jsonrpc2.ErrInvalidParams, "%s with %d params", m, len(long))
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	handler, teardown := setupHandler(t)
+	defer teardown()
+
+	for _, params := range []any{nil, map[string]any{}} {
+		result, err := handler.Handle(context.Background(), createRequest("rpc.discover", params))
+		require.NoError(t, err)
+
+		raw, err := json.Marshal(result)
+		require.NoError(t, err)
+		var doc struct {
+			OpenRPC string `json:"openrpc"`
+			Methods []struct {
+				Name           string `json:"name"`
+				ParamStructure string `json:"paramStructure"`
+				Params         []struct {
+					Name     string `json:"name"`
+					Required bool   `json:"required"`
+				} `json:"params"`
+				Errors []struct {
+					Code    int64  `json:"code"`
+					Message string `json:"message"`
+				} `json:"errors"`
+			} `json:"methods"`
+			Components struct {
+				Schemas map[string]any `json:"schemas"`
+			} `json:"components"`
+		}
+		require.NoError(t, json.Unmarshal(raw, &doc))
+		assert.Equal(t, openRPCVersion, doc.OpenRPC)
+		require.Len(t, doc.Methods, len(methodDocs))
+
+		byName := map[string]int{}
+		for i, m := range doc.Methods {
+			byName[m.Name] = i
+		}
+		search := doc.Methods[byName["searchTraces"]]
+		require.Len(t, search.Params, 5)
+		assert.Equal(t, "startTime", search.Params[0].Name)
+		assert.True(t, search.Params[1].Required)
+		assert.False(t, search.Params[2].Required)
+		assert.Equal(t, "either", search.ParamStructure)
+
+		del := doc.Methods[byName["deleteLogByID"]]
+		assert.Equal(t, "by-position", del.ParamStructure)
+
+		getLog := doc.Methods[byName["getLog"]]
+		require.NotEmpty(t, getLog.Errors)
+		assert.Equal(t, int64(ErrCodeLogNotFound), getLog.Errors[0].Code)
+		assert.Equal(t, ErrLogsNotFound.Error(), getLog.Errors[0].Message)
+
+		// Every $ref must resolve, or a client generator stops at the first one.
+		for _, target := range refsIn(raw) {
+			name := strings.TrimPrefix(target, "#/components/schemas/")
+			assert.Contains(t, doc.Components.Schemas, name, "dangling $ref %s", target)
+		}
+	}
+}
+
+func refsIn(raw []byte) []string {
+	var walk func(v any, out *[]string)
+	walk = func(v any, out *[]string) {
+		switch x := v.(type) {
+		case map[string]any:
+			for k, child := range x {
+				if s, ok := child.(string); ok && k == "$ref" {
+					*out = append(*out, s)
+				}
+				walk(child, out)
+			}
+		case []any:
+			for _, child := range x {
+				walk(child, out)
+			}
+		}
+	}
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		panic(err)
+	}
+	var out []string
+	walk(doc, &out)
+	return out
+}
+
+// errorCodeConsts reads the ErrCode constants out of errors.go, for the same
+// reason dispatchedMethods reads the switch: a hand-copied list would agree
+// with errorDocs by construction.
+func errorCodeConsts(t *testing.T) []int64 {
+	t.Helper()
+	f, err := parser.ParseFile(token.NewFileSet(), "errors.go", nil, 0)
+	require.NoError(t, err)
+	values := map[string]int64{
+		"ErrCodeTraceNotFound": ErrCodeTraceNotFound, "ErrCodeLogNotFound": ErrCodeLogNotFound,
+		"ErrCodeMetricNotFound": ErrCodeMetricNotFound, "ErrCodeInvalidTraceID": ErrCodeInvalidTraceID,
+		"ErrCodeInvalidLogID": ErrCodeInvalidLogID, "ErrCodeInvalidQuery": ErrCodeInvalidQuery,
+		"ErrCodeInvalidSpanID": ErrCodeInvalidSpanID, "ErrCodeInvalidStreamID": ErrCodeInvalidStreamID,
+		"ErrCodeRequestCanceled": ErrCodeRequestCanceled, "ErrCodeForbidden": ErrCodeForbidden,
+		"ErrCodeReadOnly": ErrCodeReadOnly, "ErrCodeEndpointNotAllowed": ErrCodeEndpointNotAllowed,
+		"ErrCodeIssueNotFound": ErrCodeIssueNotFound,
+	}
+	var out []int64
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		for _, name := range spec.Names {
+			if !strings.HasPrefix(name.Name, "ErrCode") {
+				continue
+			}
+			v, ok := values[name.Name]
+			require.True(t, ok, "errors.go declares %s; add it to errorDocs and to this test's table", name.Name)
+			out = append(out, v)
+		}
+		return true
+	})
+	return out
+}
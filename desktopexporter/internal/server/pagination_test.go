@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/metrics"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/search"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// setupHandlerForPaging ingests five of each signal. Two of the five share a
+// timestamp, which is the case the cursor's id tiebreak exists for: a cursor
+// holding only the timestamp would skip or repeat one of them at a page
+// boundary.
+func setupHandlerForPaging(t *testing.T) (*JSONRPCHandler, func()) {
+	t.Helper()
+	handler, teardown := setupHandler(t)
+	ctx := context.Background()
+	base := time.Now().UnixNano()
+	stamps := []int64{base, base + 10, base + 10, base + 20, base + 30}
+
+	tr := ptrace.NewTraces()
+	ss := tr.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty()
+	ld := plog.NewLogs()
+	sl := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty()
+	md := pmetric.NewMetrics()
+	sm := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	for i, ts := range stamps {
+		span := ss.Spans().AppendEmpty()
+		span.SetTraceID([16]byte{15: byte(i + 1)})
+		span.SetSpanID([8]byte{7: byte(i + 1)})
+		span.SetName("page")
+		span.SetStartTimestamp(pcommon.Timestamp(ts))
+		span.SetEndTimestamp(pcommon.Timestamp(ts + 1))
+
+		rec := sl.LogRecords().AppendEmpty()
+		rec.SetTimestamp(pcommon.Timestamp(ts))
+		rec.Body().SetStr("page")
+
+		met := sm.Metrics().AppendEmpty()
+		met.SetName("page.gauge." + string(rune('a'+i)))
+		dp := met.SetEmptyGauge().DataPoints().AppendEmpty()
+		dp.SetTimestamp(pcommon.Timestamp(ts))
+		dp.SetIntValue(int64(i))
+	}
+
+	s := handler.store
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return spans.Ingest(ctx, conn, tr, s.FlushedIDs())
+	}))
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return logs.Ingest(ctx, conn, ld, s.FlushedIDs())
+	}))
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return metrics.Ingest(ctx, conn, md, s.FlushedIDs())
+	}))
+	return handler, teardown
+}
+
+// pageWindowEnd is the widest end bound, as the decimal string the wire takes.
+var pageWindowEnd = strconv.FormatInt(1<<63-1, 10)
+
+type page struct {
+	Items      []map[string]any `json:"items"`
+	NextCursor *string          `json:"nextCursor"`
+}
+
+func fetchPage(t *testing.T, h *JSONRPCHandler, method string, params map[string]any) page {
+	t.Helper()
+	result, err := h.Handle(context.Background(), createRequest(method, params))
+	require.NoError(t, err)
+	var p page
+	require.NoError(t, json.Unmarshal(result.(json.RawMessage), &p))
+	return p
+}
+
+// Walking every page must visit every row exactly once, in the same order
+// one unbounded page would have served them.
+func TestSearchPagination(t *testing.T) {
+	handler, teardown := setupHandlerForPaging(t)
+	defer teardown()
+
+	for _, tc := range []struct{ method, idKey string }{
+		{"searchTraces", "traceID"},
+		{"searchLogs", "id"},
+		{"searchMetricSummaries", "id"},
+	} {
+		t.Run(tc.method, func(t *testing.T) {
+			window := map[string]any{"startTime": "0", "endTime": pageWindowEnd}
+
+			whole := fetchPage(t, handler, tc.method, window)
+			require.Len(t, whole.Items, 5)
+			assert.Nil(t, whole.NextCursor, "everything fitted, so there is no next page")
+
+			var walked []any
+			pages := 0
+			params := map[string]any{"startTime": "0", "endTime": pageWindowEnd, "limit": 2}
+			for {
+				p := fetchPage(t, handler, tc.method, params)
+				pages++
+				require.LessOrEqual(t, len(p.Items), 2)
+				for _, item := range p.Items {
+					walked = append(walked, item[tc.idKey])
+				}
+				if p.NextCursor == nil {
+					break
+				}
+				require.Less(t, pages, 5, "the walk must end")
+				params["cursor"] = *p.NextCursor
+			}
+
+			var want []any
+			for _, item := range whole.Items {
+				want = append(want, item[tc.idKey])
+			}
+			assert.Equal(t, want, walked)
+			assert.Equal(t, 3, pages, "5 rows at 2 a page")
+		})
+	}
+}
+
+// An exact fit must not promise a page that turns out empty.
+func TestSearchPaginationExactFit(t *testing.T) {
+	handler, teardown := setupHandlerForPaging(t)
+	defer teardown()
+
+	p := fetchPage(t, handler, "searchLogs", map[string]any{"startTime": "0", "endTime": pageWindowEnd, "limit": 5})
+	assert.Len(t, p.Items, 5)
+	assert.Nil(t, p.NextCursor)
+}
+
+func TestSearchPaginationRejectsBadPageParams(t *testing.T) {
+	handler, teardown := setupHandler(t)
+	defer teardown()
+
+	for _, params := range []map[string]any{
+		{"startTime": "0", "endTime": pageWindowEnd, "limit": 0},
+		{"startTime": "0", "endTime": pageWindowEnd, "limit": search.MaxPageLimit + 1},
+		{"startTime": "0", "endTime": pageWindowEnd, "limit": "many"},
+		{"startTime": "0", "endTime": pageWindowEnd, "cursor": 7},
+		{"startTime": "0", "endTime": pageWindowEnd, "cursor": "not-a-cursor"},
+	} {
+		_, err := handler.Handle(context.Background(), createRequest("searchTraces", params))
+		assert.True(t, errors.Is(err, jsonrpc2.ErrInvalidParams), "%v: got %v", params, err)
+	}
+}
@@ -0,0 +1,290 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/promql"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+)
+
+// The Prometheus HTTP API: instant and range queries, series, label names
+// and label values, answered from the stored metrics, so Grafana's
+// Prometheus data source -- and a dashboard built on one -- can be pointed at
+// the viewer's port.
+//
+// Queries are the PromQL subset the promql package evaluates, over the names
+// and labels the collector's Prometheus exporter gives OTel metrics. Each
+// route takes its parameters from the URL or from a form-encoded POST body,
+// as Prometheus does; Grafana sends POST by default.
+//
+// Reads only, like the Jaeger routes, so no token is asked for.
+
+// maxPromPoints caps the points a range query returns per series:
+// Prometheus's own limit, and the reason it gives for refusing a step.
+const maxPromPoints = 11000
+
+// promResponse is the API's envelope: data on success, errorType and error
+// otherwise.
+type promResponse struct {
+	Status    string `json:"status"`
+	Data      any    `json:"data,omitempty"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type promQueryData struct {
+	ResultType string       `json:"resultType"`
+	Result     promql.Value `json:"result"`
+}
+
+// promQueryHandler is /api/v1/query: query, evaluated at time, which
+// defaults to now.
+func (s *Server) promQueryHandler(writer http.ResponseWriter, request *http.Request) {
+	if err := request.ParseForm(); err != nil {
+		writePromError(writer, http.StatusBadRequest, "bad_data", err.Error())
+		return
+	}
+	query := request.Form.Get("query")
+	ts, err := parsePromTime(request.Form.Get("time"), "time", time.Now())
+	if err != nil {
+		writePromError(writer, http.StatusBadRequest, "bad_data", err.Error())
+		return
+	}
+	ctx := request.Context()
+	result, err := storeRead(s.store, func(db *sql.DB) (promql.Value, error) {
+		return promql.Instant(ctx, promql.NewSource(db), query, ts)
+	})
+	if err != nil {
+		s.writePromStoreError(writer, "/api/v1/query", err)
+		return
+	}
+	writeProm(writer, promQueryData{ResultType: result.Type(), Result: result})
+}
+
+// promQueryRangeHandler is /api/v1/query_range: query, evaluated from start
+// to end every step.
+func (s *Server) promQueryRangeHandler(writer http.ResponseWriter, request *http.Request) {
+	if err := request.ParseForm(); err != nil {
+		writePromError(writer, http.StatusBadRequest, "bad_data", err.Error())
+		return
+	}
+	query := request.Form.Get("query")
+	start, end, step, err := parsePromRange(request.Form.Get("start"), request.Form.Get("end"), request.Form.Get("step"))
+	if err != nil {
+		writePromError(writer, http.StatusBadRequest, "bad_data", err.Error())
+		return
+	}
+	ctx := request.Context()
+	result, err := storeRead(s.store, func(db *sql.DB) (promql.Matrix, error) {
+		return promql.Range(ctx, promql.NewSource(db), query, start, end, step)
+	})
+	if err != nil {
+		s.writePromStoreError(writer, "/api/v1/query_range", err)
+		return
+	}
+	writeProm(writer, promQueryData{ResultType: result.Type(), Result: result})
+}
+
+// promSeriesHandler is /api/v1/series: the label sets of the series matching
+// any of the match[] selectors, of which there must be at least one.
+func (s *Server) promSeriesHandler(writer http.ResponseWriter, request *http.Request) {
+	sets, ok := s.promLabelSets(writer, request, "/api/v1/series", true)
+	if !ok {
+		return
+	}
+	if sets == nil {
+		sets = []promql.Labels{}
+	}
+	writeProm(writer, sets)
+}
+
+// promLabelsHandler is /api/v1/labels: every label name, of every series or
+// of those matching match[].
+func (s *Server) promLabelsHandler(writer http.ResponseWriter, request *http.Request) {
+	sets, ok := s.promLabelSets(writer, request, "/api/v1/labels", false)
+	if !ok {
+		return
+	}
+	writeProm(writer, distinctLabels(sets, func(l promql.Label) string { return l.Name }))
+}
+
+// promLabelValuesHandler is /api/v1/label/{name}/values. Grafana's metric
+// browser reads the metric names from /api/v1/label/__name__/values.
+func (s *Server) promLabelValuesHandler(writer http.ResponseWriter, request *http.Request) {
+	name := request.PathValue("name")
+	sets, ok := s.promLabelSets(writer, request, "/api/v1/label/{name}/values", false)
+	if !ok {
+		return
+	}
+	writeProm(writer, distinctLabels(sets, func(l promql.Label) string {
+		if l.Name != name {
+			return ""
+		}
+		return l.Value
+	}))
+}
+
+// promLabelSets reads the label sets the three metadata routes are drawn
+// from, writing the error response itself when it fails.
+func (s *Server) promLabelSets(writer http.ResponseWriter, request *http.Request, route string, requireMatch bool) ([]promql.Labels, bool) {
+	if err := request.ParseForm(); err != nil {
+		writePromError(writer, http.StatusBadRequest, "bad_data", err.Error())
+		return nil, false
+	}
+	selectors := request.Form["match[]"]
+	if requireMatch && len(selectors) == 0 {
+		writePromError(writer, http.StatusBadRequest, "bad_data", "no match[] parameter provided")
+		return nil, false
+	}
+	var matcherSets [][]*promql.Matcher
+	for _, sel := range selectors {
+		matchers, err := promql.ParseSelector(sel)
+		if err != nil {
+			writePromError(writer, http.StatusBadRequest, "bad_data", err.Error())
+			return nil, false
+		}
+		matcherSets = append(matcherSets, matchers)
+	}
+	start, err := parsePromTime(request.Form.Get("start"), "start", time.UnixMilli(math.MinInt64))
+	if err != nil {
+		writePromError(writer, http.StatusBadRequest, "bad_data", err.Error())
+		return nil, false
+	}
+	end, err := parsePromTime(request.Form.Get("end"), "end", time.UnixMilli(math.MaxInt64))
+	if err != nil {
+		writePromError(writer, http.StatusBadRequest, "bad_data", err.Error())
+		return nil, false
+	}
+	ctx := request.Context()
+	sets, err := storeRead(s.store, func(db *sql.DB) ([]promql.Labels, error) {
+		return promql.NewSource(db).LabelSets(ctx, matcherSets, start.UnixMilli(), end.UnixMilli())
+	})
+	if err != nil {
+		s.writePromStoreError(writer, route, err)
+		return nil, false
+	}
+	return sets, true
+}
+
+// distinctLabels is the sorted set of what pick returns for every label of
+// every set, skipping empty strings.
+func distinctLabels(sets []promql.Labels, pick func(promql.Label) string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, ls := range sets {
+		for _, l := range ls {
+			if v := pick(l); v != "" && !seen[v] {
+				seen[v] = true
+				out = append(out, v)
+			}
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// parsePromRange reads a range query's start, end and step, which are all
+// required, and refuses a step that would give a series more than
+// maxPromPoints points.
+func parsePromRange(startParam, endParam, stepParam string) (time.Time, time.Time, time.Duration, error) {
+	var zero time.Time
+	if startParam == "" || endParam == "" || stepParam == "" {
+		return zero, zero, 0, errors.New("start, end and step are required")
+	}
+	start, err := parsePromTime(startParam, "start", zero)
+	if err != nil {
+		return zero, zero, 0, err
+	}
+	end, err := parsePromTime(endParam, "end", zero)
+	if err != nil {
+		return zero, zero, 0, err
+	}
+	if end.Before(start) {
+		return zero, zero, 0, errors.New("end timestamp must not be before start time")
+	}
+	step, err := parsePromDuration(stepParam)
+	if err != nil {
+		return zero, zero, 0, fmt.Errorf("invalid parameter \"step\": %w", err)
+	}
+	if step <= 0 {
+		return zero, zero, 0, errors.New("zero or negative query resolution step widths are not accepted. Try a positive integer")
+	}
+	if end.Sub(start)/step > maxPromPoints {
+		return zero, zero, 0, fmt.Errorf("exceeded maximum resolution of %d points per timeseries. Try decreasing the query resolution (?step=XX)", maxPromPoints)
+	}
+	return start, end, step, nil
+}
+
+// parsePromTime reads a timestamp as Prometheus does: unix seconds, with an
+// optional fraction, or RFC 3339. An empty parameter is fallback.
+func parsePromTime(v, name string, fallback time.Time) (time.Time, error) {
+	if v == "" {
+		return fallback, nil
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid parameter %q: cannot parse %q to a valid timestamp", name, v)
+}
+
+// parsePromDuration reads a step: seconds, with an optional fraction, or a
+// PromQL duration such as 30s.
+func parsePromDuration(v string) (time.Duration, error) {
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		if math.IsNaN(f) || math.IsInf(f, 0) || f*1e9 > math.MaxInt64 {
+			return 0, fmt.Errorf("cannot parse %q to a valid duration", v)
+		}
+		return time.Duration(f * 1e9), nil
+	}
+	d, err := promql.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %q to a valid duration", v)
+	}
+	return d, nil
+}
+
+// writePromStoreError answers a failed query the way Prometheus would: a
+// query it cannot run is bad_data, a request cut short is canceled, and
+// anything else is logged and internal.
+func (s *Server) writePromStoreError(writer http.ResponseWriter, route string, err error) {
+	switch mapped := mapStoreError(err); {
+	case errors.Is(err, promql.ErrBadQuery), errors.Is(mapped, ErrInvalidQuery):
+		writePromError(writer, http.StatusBadRequest, "bad_data", err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(mapped, ErrRequestCanceled):
+		writePromError(writer, http.StatusServiceUnavailable, "canceled", "query was canceled")
+	case errors.Is(err, store.ErrStoreConnectionClosed):
+		writePromError(writer, http.StatusServiceUnavailable, "unavailable", "viewer is shutting down")
+	default:
+		s.logger.Error("serving "+route, zap.Error(err))
+		writePromError(writer, http.StatusInternalServerError, "internal", "Internal server error")
+	}
+}
+
+func writeProm(writer http.ResponseWriter, data any) {
+	writePromResponse(writer, http.StatusOK, promResponse{Status: "success", Data: data})
+}
+
+func writePromError(writer http.ResponseWriter, status int, errorType, msg string) {
+	writePromResponse(writer, status, promResponse{Status: "error", ErrorType: errorType, Error: msg})
+}
+
+func writePromResponse(writer http.ResponseWriter, status int, body promResponse) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	_ = json.NewEncoder(writer).Encode(body)
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/sink"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/telemetry"
+)
+
+// prometheusFixture is a checkout service's request counter, split by route,
+// and its latency histogram, reported every 15s for a minute from
+// 1700000000: the two things a RED dashboard is drawn from.
+func prometheusFixture() pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "checkout")
+	rm.Resource().Attributes().PutStr("service.instance.id", "checkout-1")
+	sm := rm.ScopeMetrics().AppendEmpty()
+
+	requests := sm.Metrics().AppendEmpty()
+	requests.SetName("http.server.requests")
+	sum := requests.SetEmptySum()
+	sum.SetIsMonotonic(true)
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+
+	duration := sm.Metrics().AppendEmpty()
+	duration.SetName("http.server.duration")
+	duration.SetUnit("ms")
+	hist := duration.SetEmptyHistogram()
+	hist.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+
+	for i := 0; i <= 4; i++ {
+		ts := pcommon.NewTimestampFromTime(time.Unix(1_700_000_000+int64(i)*15, 0))
+		for route, perStep := range map[string]int64{"/cart": 30, "/pay": 3} {
+			dp := sum.DataPoints().AppendEmpty()
+			dp.SetTimestamp(ts)
+			dp.SetIntValue(int64(i) * perStep)
+			dp.Attributes().PutStr("http.route", route)
+		}
+		h := hist.DataPoints().AppendEmpty()
+		h.SetTimestamp(ts)
+		h.ExplicitBounds().FromRaw([]float64{10, 100})
+		h.BucketCounts().FromRaw([]uint64{6, 3, 1})
+		h.SetCount(10)
+		h.SetSum(400)
+	}
+	return md
+}
+
+func setupPrometheusServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	str, err := store.NewStore(context.Background(), "", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { str.Close() })
+	s, err := NewServer("localhost:8000", str, zap.NewNop(), telemetry.Disabled(), nil, nil)
+	require.NoError(t, err)
+	testServer := httptest.NewServer(s.server.Handler)
+	t.Cleanup(testServer.Close)
+
+	require.NoError(t, sink.Metrics(context.Background(), str, prometheusFixture(), nil))
+	return testServer
+}
+
+// TestPrometheusAPIGolden pins each route's response to a file in
+// testdata/prometheus. As with the Jaeger files, these are what Grafana
+// parses, so a diff here is a change a dashboard will see.
+//
+// Regenerate with: go test ./internal/server/ -run Golden -update-golden
+func TestPrometheusAPIGolden(t *testing.T) {
+	testServer := setupPrometheusServer(t)
+
+	query := func(q string, extra ...string) string {
+		v := url.Values{"query": {q}}
+		for i := 0; i < len(extra); i += 2 {
+			v.Set(extra[i], extra[i+1])
+		}
+		return v.Encode()
+	}
+	for _, tc := range []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"query_scalar", "/api/v1/query?" + query("1+1", "time", "1700000060"), http.StatusOK},
+		{"query_selector", "/api/v1/query?" + query(`http_server_requests_total{http_route="/cart"}`, "time", "1700000060"), http.StatusOK},
+		{"query_range_vector", "/api/v1/query?" + query(`http_server_requests_total{http_route="/pay"}[30s]`, "time", "1700000060"), http.StatusOK},
+		{"query_rate_by", "/api/v1/query?" + query(`sum by (job) (rate(http_server_requests_total[1m]))`, "time", "1700000060"), http.StatusOK},
+		{"query_error_ratio", "/api/v1/query?" + query(`sum(increase(http_server_requests_total{http_route="/pay"}[1m])) / sum(increase(http_server_requests_total[1m]))`, "time", "1700000060"), http.StatusOK},
+		{"query_histogram_quantile", "/api/v1/query?" + query(`histogram_quantile(0.75, sum by (le) (rate(http_server_duration_milliseconds_bucket[1m])))`, "time", "2023-11-14T22:14:20Z"), http.StatusOK},
+		{"query_range", "/api/v1/query_range?" + query(`sum(rate(http_server_requests_total[30s])) by (http_route)`, "start", "1700000030", "end", "1700000060", "step", "15s"), http.StatusOK},
+		{"query_range_scalar", "/api/v1/query_range?" + query("2", "start", "1700000000", "end", "1700000001.5", "step", "0.5"), http.StatusOK},
+		{"query_bad", "/api/v1/query?" + query("sum(", "time", "1700000060"), http.StatusBadRequest},
+		{"query_unsupported", "/api/v1/query?" + query("irate(x[1m])"), http.StatusBadRequest},
+		{"query_bad_time", "/api/v1/query?" + query("1", "time", "yesterday"), http.StatusBadRequest},
+		{"query_range_missing_step", "/api/v1/query_range?" + query("1", "start", "1", "end", "2"), http.StatusBadRequest},
+		{"query_range_too_many_points", "/api/v1/query_range?" + query("1", "start", "0", "end", "1700000000", "step", "1"), http.StatusBadRequest},
+		{"series", "/api/v1/series?" + url.Values{"match[]": {`{__name__=~"http_server_duration.*"}`, `http_server_requests_total{http_route="/pay"}`}}.Encode(), http.StatusOK},
+		{"series_no_match", "/api/v1/series", http.StatusBadRequest},
+		{"labels", "/api/v1/labels", http.StatusOK},
+		{"label_names", "/api/v1/label/__name__/values", http.StatusOK},
+		{"label_values_matched", "/api/v1/label/http_route/values?" + url.Values{"match[]": {`{http_route="/cart"}`}}.Encode(), http.StatusOK},
+		{"label_values_outside_window", "/api/v1/label/job/values?start=1800000000", http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := http.Get(testServer.URL + tc.path)
+			require.NoError(t, err)
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			require.NoError(t, err)
+			assert.Equal(t, tc.status, res.StatusCode)
+			assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+			var pretty bytes.Buffer
+			require.NoError(t, json.Indent(&pretty, body, "", "  "))
+
+			path := filepath.Join("testdata", "prometheus", tc.name+".json")
+			if *updateGolden {
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+				require.NoError(t, os.WriteFile(path, pretty.Bytes(), 0o644))
+				return
+			}
+			want, err := os.ReadFile(path)
+			require.NoError(t, err, "missing golden file; run with -update-golden")
+			assert.Equal(t, string(want), pretty.String())
+		})
+	}
+}
+
+// Grafana sends its queries as form-encoded POSTs; they answer as the GETs
+// with the same parameters do.
+func TestPrometheusAPIAcceptsPOST(t *testing.T) {
+	testServer := setupPrometheusServer(t)
+
+	form := url.Values{
+		"query": {`sum(http_server_requests_total)`},
+		"start": {"1700000000"},
+		"end":   {"1700000060"},
+		"step":  {"30"},
+	}
+	get, err := http.Get(testServer.URL + "/api/v1/query_range?" + form.Encode())
+	require.NoError(t, err)
+	want, err := io.ReadAll(get.Body)
+	get.Body.Close()
+	require.NoError(t, err)
+
+	post, err := http.Post(testServer.URL+"/api/v1/query_range", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	got, err := io.ReadAll(post.Body)
+	post.Body.Close()
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, post.StatusCode)
+	assert.JSONEq(t, string(want), string(got))
+	assert.Contains(t, string(got), `[1700000060,"132"]`)
+}
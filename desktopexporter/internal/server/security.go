@@ -0,0 +1,238 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// The viewer has no users and no login, and it serves methods that delete
+// everything it holds. Three checks stand between those methods and a web
+// page the developer happens to have open, each closing a different door:
+//
+//   - Host. A DNS-rebinding page gets the browser to send it to 127.0.0.1 under
+//     the attacker's own hostname, which makes every request same-origin from
+//     the browser's point of view and so invisible to CORS. The Host header
+//     still carries that hostname, and is refused unless it names this server.
+//   - Origin. A cross-site POST with a text/plain body is a "simple" request:
+//     the browser sends it without a preflight and only hides the response.
+//     CORS therefore cannot stop a clearTraces, it can only stop the caller
+//     reading the answer. A request whose Origin is not allowed is refused
+//     before it reaches a handler, which stops the call itself.
+//   - Token. Mutating methods also need the per-process token, which only a
+//     page that could read index.html has. It covers what the other two miss:
+//     a request from a context that sends no Origin at all.
+//
+// Requests with no Origin header -- curl, scripts, the collector's own
+// tooling -- pass the Origin check, since a browser always sends one on a
+// cross-origin request. They still need the token to mutate.
+
+// TokenHeader is the request header a mutating RPC presents the token in.
+// A custom header also forces a CORS preflight, so a cross-origin page cannot
+// send it without first being allowed to.
+const TokenHeader = "X-Viewer-Token"
+
+// tokenMetaName is the <meta name> index.html carries the token under.
+const tokenMetaName = "viewer-token"
+
+// viteDevPort is where `make dev-ts` serves the frontend. Its origin is allowed
+// by default so the dev server's proxied calls work without configuration.
+const viteDevPort = "3001"
+
+// mutatingMethods need the token. TestMutatingMethodsNeedToken fails when a
+// handler that takes the store's write lock is missing from this list.
+var mutatingMethods = map[string]bool{
+	"clearTraces":          true,
+	"clearLogs":            true,
+	"clearMetrics":         true,
+	"deleteMetricStream":   true,
+	"deleteSpansByTraceID": true,
+	"deleteSpanByID":       true,
+	"deleteLogByID":        true,
+}
+
+// outboundMethods need the token too, for a different reason: they write
+// nothing, but they send what the store holds off the machine. The allowlist
+// decides where; the token decides who may ask. A read-only viewer still
+// serves them, since forwarding from a capture someone handed over is as
+// useful as forwarding from a live store.
+var outboundMethods = map[string]bool{
+	"forwardTelemetry": true,
+}
+
+// newToken returns 32 random bytes as hex. Minted once per process: a
+// restarted viewer invalidates every page that was open against the old one,
+// which reloading index.html fixes.
+func newToken() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generating viewer token: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// accessPolicy is the Host and Origin half of the checks; the token half is
+// checked per method in rpcHandler.
+type accessPolicy struct {
+	// hosts are the hostnames the Host header may carry. Ports are not
+	// compared: rebinding is defeated by the name alone, and comparing ports
+	// would break every proxy and test harness that fronts the listener.
+	hosts map[string]bool
+	// anyIPHost admits any IP literal as Host. Set for a wildcard bind, where
+	// the server cannot know which of the machine's addresses a client used
+	// but a rebinding attack, which needs a hostname, is still excluded.
+	anyIPHost bool
+
+	// origins are the allowed cross-origin callers, normalised by
+	// normalizeOrigin. The viewer's own origin needs no entry: a request
+	// whose Origin matches its Host is same-origin and always allowed.
+	origins   map[string]bool
+	anyOrigin bool
+}
+
+// newAccessPolicy derives the policy from the bind address and the configured
+// origins. An empty allowedOrigins means the defaults: the viewer reached
+// through any loopback name, and the Vite dev server. "*" allows every origin,
+// as this server did before the policy existed.
+func newAccessPolicy(endpoint string, allowedOrigins []string) (*accessPolicy, error) {
+	bindHost, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("endpoint %q: %w", endpoint, err)
+	}
+	bindHost = strings.ToLower(bindHost)
+
+	p := &accessPolicy{hosts: map[string]bool{}, origins: map[string]bool{}}
+	loopback := []string{"localhost", "127.0.0.1", "::1"}
+	switch ip := net.ParseIP(bindHost); {
+	case bindHost == "" || (ip != nil && ip.IsUnspecified()):
+		p.anyIPHost = true
+		p.hosts["localhost"] = true
+	case bindHost == "localhost" || (ip != nil && ip.IsLoopback()):
+		for _, h := range loopback {
+			p.hosts[h] = true
+		}
+	default:
+		p.hosts[bindHost] = true
+	}
+
+	if len(allowedOrigins) == 0 {
+		for _, h := range loopback {
+			p.origins[normalizeOrigin("http", h, port)] = true
+			p.origins[normalizeOrigin("http", h, viteDevPort)] = true
+		}
+		return p, nil
+	}
+
+	for _, o := range allowedOrigins {
+		if o == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		scheme, host, port, err := ParseOrigin(o)
+		if err != nil {
+			return nil, err
+		}
+		p.origins[normalizeOrigin(scheme, host, port)] = true
+		// An origin someone allowed by name is a name they reach the viewer
+		// by, so it is also a Host this server answers to.
+		p.hosts[host] = true
+	}
+	return p, nil
+}
+
+// ParseOrigin splits an allowed-origin entry into scheme, lowercased host and
+// port, refusing anything that is not a bare scheme://host[:port]. Exported for
+// the extension's config validation, so a bad entry fails at startup with the
+// same rules the server applies.
+func ParseOrigin(origin string) (scheme, host, port string, err error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return "", "", "", fmt.Errorf("origin %q: %w", origin, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", "", "", fmt.Errorf("origin %q: scheme must be http or https", origin)
+	}
+	if u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", "", "", fmt.Errorf("origin %q: expected scheme://host[:port] and nothing else", origin)
+	}
+	return u.Scheme, strings.ToLower(u.Hostname()), u.Port(), nil
+}
+
+// normalizeOrigin renders an origin the way a browser serialises it, with
+// the scheme's default port left out, so configured and received origins
+// compare as strings.
+func normalizeOrigin(scheme, host, port string) string {
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port == "" {
+		return scheme + "://" + host
+	}
+	return scheme + "://" + host + ":" + port
+}
+
+// allowsHost reports whether the request's Host names this server.
+func (p *accessPolicy) allowsHost(r *http.Request) bool {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if p.hosts[host] {
+		return true
+	}
+	return p.anyIPHost && net.ParseIP(host) != nil
+}
+
+// allowsOrigin reports whether a request carrying this Origin may proceed.
+// Call it only after allowsHost: the same-origin rule trusts the Host header,
+// which is safe only once Host is known to name this server.
+func (p *accessPolicy) allowsOrigin(r *http.Request, origin string) bool {
+	if origin == "" || p.anyOrigin {
+		return true
+	}
+	scheme, host, port, err := ParseOrigin(origin)
+	if err != nil {
+		// Includes the literal "null" a sandboxed frame or file:// page sends.
+		return false
+	}
+	normalized := normalizeOrigin(scheme, host, port)
+	if p.origins[normalized] {
+		return true
+	}
+	reqHost, reqPort, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		reqHost, reqPort = r.Host, ""
+	}
+	return normalized == normalizeOrigin(scheme, strings.ToLower(strings.Trim(reqHost, "[]")), reqPort)
+}
+
+// guard refuses requests that fail the Host or Origin check before any
+// handler -- CORS included -- sees them.
+func (p *accessPolicy) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !p.allowsHost(request) {
+			http.Error(writer, "Host not allowed", http.StatusForbidden)
+			return
+		}
+		if !p.allowsOrigin(request, request.Header.Get("Origin")) {
+			http.Error(writer, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+// validToken compares in constant time, so the comparison does not leak how
+// much of a guess was right.
+func validToken(want, got string) bool {
+	return got != "" && subtle.ConstantTimeCompare([]byte(want), []byte(got)) == 1
+}
@@ -0,0 +1,313 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAccessPolicyHosts(t *testing.T) {
+	for _, tc := range []struct {
+		endpoint string
+		origins  []string
+		allowed  []string
+		refused  []string
+	}{
+		{
+			endpoint: "localhost:8000",
+			allowed:  []string{"localhost:8000", "127.0.0.1:8000", "[::1]:8000", "LOCALHOST"},
+			refused:  []string{"evil.example:8000", "192.168.1.5:8000", "localhost.evil.example"},
+		},
+		{
+			// A wildcard bind cannot list its addresses, but a rebinding page
+			// needs a hostname, and a hostname is still refused.
+			endpoint: "0.0.0.0:8000",
+			allowed:  []string{"localhost:8000", "192.168.1.5:8000", "[fe80::1]:8000"},
+			refused:  []string{"evil.example:8000", "mybox:8000"},
+		},
+		{
+			endpoint: "0.0.0.0:8000",
+			origins:  []string{"http://mybox:8000"},
+			allowed:  []string{"mybox:8000", "10.0.0.2:8000"},
+			refused:  []string{"evil.example:8000"},
+		},
+		{
+			endpoint: "mybox.lan:8000",
+			allowed:  []string{"mybox.lan:8000"},
+			refused:  []string{"localhost:8000", "evil.example:8000"},
+		},
+	} {
+		p, err := newAccessPolicy(tc.endpoint, tc.origins)
+		require.NoError(t, err)
+		for _, host := range tc.allowed {
+			assert.True(t, p.allowsHost(&http.Request{Host: host}), "%s bound, Host %s", tc.endpoint, host)
+		}
+		for _, host := range tc.refused {
+			assert.False(t, p.allowsHost(&http.Request{Host: host}), "%s bound, Host %s", tc.endpoint, host)
+		}
+	}
+}
+
+func TestAccessPolicyOrigins(t *testing.T) {
+	defaults, err := newAccessPolicy("localhost:8000", nil)
+	require.NoError(t, err)
+	configured, err := newAccessPolicy("localhost:8000", []string{"https://viewer.example", "http://LOCALHOST:9000"})
+	require.NoError(t, err)
+	wildcard, err := newAccessPolicy("localhost:8000", []string{"*"})
+	require.NoError(t, err)
+
+	req := &http.Request{Host: "localhost:8000"}
+	for _, tc := range []struct {
+		policy  *accessPolicy
+		origin  string
+		allowed bool
+	}{
+		{defaults, "", true},
+		{defaults, "http://localhost:8000", true},
+		{defaults, "http://127.0.0.1:8000", true},
+		{defaults, "http://localhost:3001", true},
+		{defaults, "http://[::1]:3001", true},
+		{defaults, "http://localhost:5173", false},
+		{defaults, "https://evil.example", false},
+		{defaults, "null", false},
+		{configured, "https://viewer.example:443", true},
+		{configured, "http://localhost:9000", true},
+		{configured, "http://localhost:8000", true},
+		{configured, "http://localhost:3001", false},
+		{wildcard, "https://evil.example", true},
+	} {
+		assert.Equal(t, tc.allowed, tc.policy.allowsOrigin(req, tc.origin), "%q", tc.origin)
+	}
+
+	// Same-origin is judged against the Host the request arrived with, which
+	// is what lets a wildcard bind be reached by any of its addresses.
+	wide, err := newAccessPolicy("0.0.0.0:8000", nil)
+	require.NoError(t, err)
+	assert.True(t, wide.allowsOrigin(&http.Request{Host: "192.168.1.5:8000"}, "http://192.168.1.5:8000"))
+	assert.False(t, wide.allowsOrigin(&http.Request{Host: "192.168.1.5:8000"}, "http://192.168.1.6:8000"))
+}
+
+func TestParseOrigin(t *testing.T) {
+	for _, bad := range []string{"localhost:3001", "ftp://x", "http://", "http://x/path", "http://x?q", "http://u@x"} {
+		_, _, _, err := ParseOrigin(bad)
+		assert.Error(t, err, bad)
+	}
+	_, host, port, err := ParseOrigin("http://Example.COM:81/")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", host)
+	assert.Equal(t, "81", port)
+}
+
+func TestOriginAllowlist(t *testing.T) {
+	testServer, teardown := setupServer(t)
+	defer teardown()
+
+	post := func(origin, host string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, testServer.URL+"/rpc",
+			strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"getStats"}`))
+		require.NoError(t, err)
+		// text/plain: the content type that makes a cross-site POST "simple",
+		// sent without a preflight. CORS alone would let it run.
+		req.Header.Set("Content-Type", "text/plain")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if host != "" {
+			req.Host = host
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res
+	}
+
+	assert.Equal(t, http.StatusOK, post("", "").StatusCode, "no Origin: not a browser cross-origin call")
+	assert.Equal(t, http.StatusOK, post("http://localhost:3001", "").StatusCode)
+	assert.Equal(t, http.StatusForbidden, post("https://evil.example", "").StatusCode)
+	assert.Equal(t, http.StatusForbidden, post("", "rebound.evil.example:8000").StatusCode, "DNS rebinding")
+}
+
+func TestMutatingMethodsNeedToken(t *testing.T) {
+	testServer, teardown := setupServer(t)
+	defer teardown()
+
+	res, err := http.Get(testServer.URL + "/")
+	require.NoError(t, err)
+	page, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	m := regexp.MustCompile(`<meta name="` + tokenMetaName + `" content="([0-9a-f]{64})"`).FindSubmatch(page)
+	require.NotNil(t, m, "index.html carries the token")
+	tok := string(m[1])
+
+	res, err = http.Get(testServer.URL + "/token")
+	require.NoError(t, err)
+	served, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, tok, string(served), "/token serves the same token")
+
+	call := func(method, token string) map[string]any {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, testServer.URL+"/rpc",
+			bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"`+method+`"}`))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set(TokenHeader, token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var out map[string]any
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&out))
+		return out
+	}
+	errCode := func(resp map[string]any) any {
+		if e, ok := resp["error"].(map[string]any); ok {
+			return e["code"]
+		}
+		return nil
+	}
+
+	assert.EqualValues(t, ErrCodeForbidden, errCode(call("clearTraces", "")))
+	assert.EqualValues(t, ErrCodeForbidden, errCode(call("clearTraces", strings.Repeat("0", 64))))
+	assert.Nil(t, errCode(call("clearTraces", tok)))
+	assert.Nil(t, errCode(call("getStats", "")), "reads need no token")
+
+	// Sending telemetry off the machine writes nothing, but needs the token
+	// all the same. With it, this call gets as far as its missing params.
+	assert.EqualValues(t, ErrCodeForbidden, errCode(call("forwardTelemetry", "")))
+	assert.EqualValues(t, -32602, errCode(call("forwardTelemetry", tok)))
+}
+
+// Every handler that takes the store's write lock is a mutation, and a
+// mutation missing from mutatingMethods would be callable without the token.
+// Read from the source, so adding a delete method without listing it fails
+// here rather than shipping unguarded.
+func TestMutatingMethodsCoverWriteHandlers(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "jsonrpc_handler.go", nil, 0)
+	require.NoError(t, err)
+
+	writes := map[string]bool{}
+	dispatch := map[string]string{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			ast.Inspect(n.Body, func(inner ast.Node) bool {
+				if sel, ok := inner.(*ast.SelectorExpr); ok && sel.Sel.Name == "WithDBWrite" {
+					writes[n.Name.Name] = true
+				}
+				return true
+			})
+		case *ast.CaseClause:
+			if len(n.List) != 1 || len(n.Body) != 1 {
+				return true
+			}
+			lit, ok := n.List[0].(*ast.BasicLit)
+			ret, ok2 := n.Body[0].(*ast.ReturnStmt)
+			if !ok || !ok2 || len(ret.Results) == 0 {
+				return true
+			}
+			if call, ok := ret.Results[0].(*ast.CallExpr); ok {
+				if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+					dispatch[strings.Trim(lit.Value, `"`)] = sel.Sel.Name
+				}
+			}
+		}
+		return true
+	})
+
+	require.NotEmpty(t, writes)
+	for method, fn := range dispatch {
+		if writes[fn] {
+			assert.True(t, mutatingMethods[method], "%s writes to the store but is not in mutatingMethods", method)
+		}
+	}
+	for method := range mutatingMethods {
+		_, ok := dispatch[method]
+		assert.True(t, ok, "mutatingMethods lists %s, which Handle does not dispatch", method)
+	}
+}
+
+func TestInjectToken(t *testing.T) {
+	out := injectToken([]byte("<html><head><title>x</title></head><body></body></html>"), "abc")
+	assert.Equal(t, `<html><head><title>x</title><meta name="viewer-token" content="abc" /></head><body></body></html>`, string(out))
+	assert.Equal(t, "<div>", string(injectToken([]byte("<div>"), "abc")), "no head, no injection")
+
+	rec := httptest.NewRecorder()
+	fsys := fstest.MapFS{"index.html": &fstest.MapFile{Data: []byte("<!doctype html><head></head>")}}
+	spaHandler(fsys, zap.NewNop(), "abc").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Contains(t, rec.Body.String(), `content="abc"`)
+}
+
+// A viewer started with --open refuses every mutating method, token or not,
+// and says why: the read-only code, not the token one.
+func TestReadOnlyViewerRefusesMutations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.db")
+	writable, err := store.NewStore(context.Background(), path, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, writable.Close())
+
+	str, err := store.NewStore(context.Background(), path, zap.NewNop(), store.ReadOnly())
+	require.NoError(t, err)
+	defer str.Close()
+	s, err := NewServer("localhost:8000", str, zap.NewNop(), telemetry.Disabled(), nil, nil)
+	require.NoError(t, err)
+	testServer := httptest.NewServer(s.server.Handler)
+	defer testServer.Close()
+
+	call := func(method string) map[string]any {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, testServer.URL+"/rpc",
+			strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"`+method+`"}`))
+		require.NoError(t, err)
+		req.Header.Set(TokenHeader, s.token)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var out map[string]any
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&out))
+		return out
+	}
+
+	for method := range mutatingMethods {
+		e, ok := call(method)["error"].(map[string]any)
+		require.True(t, ok, "%s must fail on a read-only viewer", method)
+		assert.EqualValues(t, ErrCodeReadOnly, e["code"], method)
+	}
+
+	for method := range outboundMethods {
+		e, ok := call(method)["error"].(map[string]any)
+		require.True(t, ok)
+		assert.NotEqualValues(t, ErrCodeReadOnly, e["code"], "%s writes nothing, so a capture can be forwarded", method)
+	}
+
+	resp := call("getStats")
+	require.Nil(t, resp["error"], "reads still work")
+	storage := resp["result"].(map[string]any)["storage"].(map[string]any)
+	assert.Equal(t, true, storage["readOnly"], "and say the viewer is read-only, so the UI can hide clear")
+
+	// A write that reaches the store some other way maps to the same code.
+	handler := NewJSONRPCHandler(str, zap.NewNop())
+	_, err = handler.Handle(context.Background(), createRequest("clearLogs", nil))
+	assert.Equal(t, ErrReadOnly, err)
+}
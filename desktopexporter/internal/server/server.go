@@ -17,6 +17,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/forward"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/telemetry"
 	"github.com/rs/cors"
@@ -41,12 +42,40 @@ type Server struct {
 
 	serveDone chan struct{}
 	startMu   sync.Mutex
+
+	// store is read directly by /stream, which is not JSON-RPC.
+	store *store.Store
+
+	// token is the per-process secret mutating RPCs must present, and policy
+	// the Host and Origin checks every request passes first. See security.go.
+	token  string
+	policy *accessPolicy
+
+	// streamsDone is closed when shutdown begins. http.Server.Shutdown waits
+	// for handlers to return but never cancels their contexts, so without this
+	// an open /stream would hold shutdown until its deadline.
+	streamsDone chan struct{}
 }
 
-func NewServer(endpoint string, store *store.Store, logger *zap.Logger, tel *telemetry.Telemetry) (*Server, error) {
+// NewServer builds the viewer's HTTP server. allowedOrigins lists the origins
+// other than the viewer's own that may call it; nil means the loopback names
+// and the Vite dev server. See newAccessPolicy.
+func NewServer(endpoint string, store *store.Store, logger *zap.Logger, tel *telemetry.Telemetry, allowedOrigins, forwardEndpoints []string) (*Server, error) {
 	if tel == nil {
 		tel = telemetry.Disabled()
 	}
+	policy, err := newAccessPolicy(endpoint, allowedOrigins)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize desktop exporter server: %w", err)
+	}
+	forwardTo, err := forward.NewAllowlist(forwardEndpoints)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize desktop exporter server: %w", err)
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize desktop exporter server: %w", err)
+	}
 	s := Server{
 		server: http.Server{
 			Addr: endpoint,
@@ -68,10 +97,18 @@ func NewServer(endpoint string, store *store.Store, logger *zap.Logger, tel *tel
 			// bounded by cancellation instead: the client aborts, and the
 			// context tears the DuckDB query down.
 		},
-		jsonrpcHandler: NewJSONRPCHandler(store, logger),
+		jsonrpcHandler: &JSONRPCHandler{store: store, logger: logger, forwardTo: forwardTo},
 		logger:         logger,
 		tel:            tel,
+		store:          store,
+		token:          token,
+		policy:         policy,
+		streamsDone:    make(chan struct{}),
 	}
+	var endStreams sync.Once
+	s.server.RegisterOnShutdown(func() {
+		endStreams.Do(func() { close(s.streamsDone) })
+	})
 
 	if err := s.initHandler(); err != nil {
 		return nil, fmt.Errorf("could not initialize desktop exporter server: %w", err)
@@ -126,6 +163,19 @@ func (s *Server) initHandler() error {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("POST /rpc", s.rpcHandler)
+	mux.HandleFunc("GET /stream", s.streamHandler)
+	mux.HandleFunc("POST /api/v2/spans", s.spansHandler)
+	mux.HandleFunc("GET /api/services", s.servicesHandler)
+	mux.HandleFunc("GET /api/services/{service}/operations", s.operationsHandler)
+	mux.HandleFunc("GET /api/traces", s.tracesHandler)
+	mux.HandleFunc("GET /api/traces/{id}", s.traceHandler)
+	for _, method := range []string{"GET", "POST"} {
+		mux.HandleFunc(method+" /api/v1/query", s.promQueryHandler)
+		mux.HandleFunc(method+" /api/v1/query_range", s.promQueryRangeHandler)
+		mux.HandleFunc(method+" /api/v1/series", s.promSeriesHandler)
+		mux.HandleFunc(method+" /api/v1/labels", s.promLabelsHandler)
+		mux.HandleFunc(method+" /api/v1/label/{name}/values", s.promLabelValuesHandler)
+	}
 
 	// Single-page app: serve a static asset when one exists at the request path,
 	// otherwise fall back to index.html so client-side routes (/traces,
@@ -134,18 +184,36 @@ func (s *Server) initHandler() error {
 	if err != nil {
 		return err
 	}
-	mux.Handle("/", spaHandler(fsys, s.logger))
+	mux.Handle("/", spaHandler(fsys, s.logger, s.token))
+	mux.HandleFunc("GET /token", s.tokenHandler)
 
-	// CORS for the Vite frontend
+	// CORS for the Vite frontend and any configured origin. The decision is
+	// the policy's, so the headers CORS sends and the requests guard admits
+	// cannot disagree; by the time a request reaches here guard has already
+	// refused a disallowed Origin outright.
 	c := cors.New(cors.Options{
-		AllowedOrigins: []string{"http://*", "https://*"},
+		AllowOriginVaryRequestFunc: func(r *http.Request, origin string) (bool, []string) {
+			return s.policy.allowsOrigin(r, origin), nil
+		},
 		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders: []string{"*"},
+		AllowedHeaders: []string{"Content-Type", TokenHeader},
 	})
-	s.server.Handler = c.Handler(mux)
+	s.server.Handler = s.policy.guard(c.Handler(mux))
 	return nil
 }
 
+// tokenHandler serves the token to a page that did not get it from
+// index.html -- in practice the Vite dev server, whose index.html is its own.
+// It reveals nothing index.html does not: guard has refused a disallowed
+// Origin, and a no-cors request (a <script> or <img> pointed here) cannot read
+// the body.
+func (s *Server) tokenHandler(writer http.ResponseWriter, _ *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+	io.WriteString(writer, s.token)
+}
+
 func staticFS() (fs.FS, error) {
 	return fs.Sub(assets, "static")
 }
@@ -174,16 +242,19 @@ const (
 	revalidateCacheControl = "no-cache"
 )
 
-func spaHandler(fsys fs.FS, logger *zap.Logger) http.Handler {
+func spaHandler(fsys fs.FS, logger *zap.Logger, token string) http.Handler {
 	fileServer := http.FileServerFS(fsys)
 
-	// The validator Go will not invent. Computed once: the FS is embedded, so
-	// these bytes cannot change while the process runs. It turns each
-	// revalidation of index.html into a 304 rather than another copy of the
-	// document.
+	// The validator Go will not invent. Computed once: the FS is embedded and
+	// the token fixed for the process, so these bytes cannot change while it
+	// runs. It turns each revalidation of index.html into a 304 rather than
+	// another copy of the document -- and because the token is part of what is
+	// hashed, a restart changes the ETag, and a revalidating browser picks up
+	// the new token rather than keeping the old one.
 	indexBytes, indexErr := fs.ReadFile(fsys, "index.html")
 	indexETag := ""
 	if indexErr == nil {
+		indexBytes = injectToken(indexBytes, token)
 		sum := sha256.Sum256(indexBytes)
 		indexETag = `"` + hex.EncodeToString(sum[:16]) + `"`
 	}
@@ -225,6 +296,21 @@ func spaHandler(fsys fs.FS, logger *zap.Logger) http.Handler {
 	})
 }
 
+// injectToken puts the token into index.html as a <meta> tag, where the UI
+// reads it. Inserted before </head>; a document without one gets nothing, and
+// its mutating calls fail loudly rather than the page failing to load.
+func injectToken(index []byte, token string) []byte {
+	i := bytes.Index(index, []byte("</head>"))
+	if i < 0 {
+		return index
+	}
+	meta := `<meta name="` + tokenMetaName + `" content="` + token + `" />`
+	out := make([]byte, 0, len(index)+len(meta))
+	out = append(out, index[:i]...)
+	out = append(out, meta...)
+	return append(out, index[i:]...)
+}
+
 func (s *Server) rpcHandler(writer http.ResponseWriter, request *http.Request) {
 	requestCtx := request.Context()
 
@@ -252,6 +338,19 @@ func (s *Server) rpcHandler(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
+	// Read-only is checked before the token: a viewer opened on a capture
+	// refuses the method whoever asks, and saying "bad token" to a caller
+	// who has the right one would send them looking in the wrong place.
+	if mutatingMethods[rpcRequest.Method] && s.store.ReadOnly() {
+		s.sendJSONRPCResponse(requestCtx, writer, rpcRequest.ID, nil, ErrReadOnly)
+		return
+	}
+	if (mutatingMethods[rpcRequest.Method] || outboundMethods[rpcRequest.Method]) &&
+		!validToken(s.token, request.Header.Get(TokenHeader)) {
+		s.sendJSONRPCResponse(requestCtx, writer, rpcRequest.ID, nil, ErrForbidden)
+		return
+	}
+
 	// The span covers dispatch *and* encoding. DuckDB builds the response JSON
 	// inside the query, so "query time" and "JSON construction" are not
 	// separable here -- the only clean split is EncodeMessage, which gets its
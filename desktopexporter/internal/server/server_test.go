@@ -24,7 +24,7 @@ func setupServer(t *testing.T) (*httptest.Server, func()) {
 	t.Helper()
 	str, err := store.NewStore(context.Background(), "", zap.NewNop())
 	require.NoError(t, err)
-	s, err := NewServer("localhost:8000", str, zap.NewNop(), telemetry.Disabled())
+	s, err := NewServer("localhost:8000", str, zap.NewNop(), telemetry.Disabled(), nil, nil)
 	require.NoError(t, err)
 	testServer := httptest.NewServer(s.server.Handler)
 
@@ -154,6 +154,8 @@ func TestRPCHandlerInvalidRequest(t *testing.T) {
 	assert.Equal(t, float64(-32601), errorObj["code"]) // Method not found
 }
 
+// The Vite dev server's origin is one of the defaults; see
+// TestOriginAllowlist for the origins that are not.
 func TestCORSHeaders(t *testing.T) {
 	testServer, teardown := setupServer(t)
 	defer teardown()
@@ -161,9 +163,11 @@ func TestCORSHeaders(t *testing.T) {
 	// Test preflight request
 	req, err := http.NewRequest("OPTIONS", fmt.Sprintf("%s/rpc", testServer.URL), nil)
 	assert.Nilf(t, err, "could not create OPTIONS request: %v", err)
-	req.Header.Set("Origin", "http://localhost:5173")
+	req.Header.Set("Origin", "http://localhost:3001")
 	req.Header.Set("Access-Control-Request-Method", "POST")
-	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	// Lowercase, as browsers send it: the Fetch standard serialises the
+	// header names that way, and an explicit header allowlist compares them so.
+	req.Header.Set("Access-Control-Request-Headers", "content-type")
 
 	client := &http.Client{}
 	res, err := client.Do(req)
@@ -171,9 +175,9 @@ func TestCORSHeaders(t *testing.T) {
 	defer res.Body.Close()
 
 	assert.Equal(t, http.StatusNoContent, res.StatusCode) // CORS preflight returns 204
-	assert.Equal(t, "http://localhost:5173", res.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "http://localhost:3001", res.Header.Get("Access-Control-Allow-Origin"))
 	assert.Contains(t, res.Header.Get("Access-Control-Allow-Methods"), "POST")
-	assert.Contains(t, res.Header.Get("Access-Control-Allow-Headers"), "Content-Type")
+	assert.Contains(t, strings.ToLower(res.Header.Get("Access-Control-Allow-Headers")), "content-type")
 }
 
 func TestStartBindConflict(t *testing.T) {
@@ -186,7 +190,7 @@ func TestStartBindConflict(t *testing.T) {
 	require.NoError(t, err)
 	defer str.Close()
 
-	s, err := NewServer(addr, str, zap.NewNop(), telemetry.Disabled())
+	s, err := NewServer(addr, str, zap.NewNop(), telemetry.Disabled(), nil, nil)
 	require.NoError(t, err)
 
 	err = s.Start()
@@ -206,7 +210,7 @@ func TestStaticCacheHeaders(t *testing.T) {
 		"assets/index-abc123.js":  &fstest.MapFile{Data: []byte("console.log(1)")},
 		"assets/style-def456.css": &fstest.MapFile{Data: []byte("body{}")},
 	}
-	handler := spaHandler(fsys, zap.NewNop())
+	handler := spaHandler(fsys, zap.NewNop(), "test-token")
 
 	get := func(target string) *http.Response {
 		t.Helper()
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/live"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+	"go.uber.org/zap"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment line. It keeps
+// intermediaries from reaping a quiet connection, and it is how the server
+// notices a client that vanished without closing: the write fails.
+const streamHeartbeat = 15 * time.Second
+
+// streamWriteTimeout bounds one event's write. A client that cannot take an
+// event in this long is not reading, and is disconnected rather than left to
+// hold a goroutine; EventSource reconnects on its own if it was only slow.
+//
+// Writes are the only thing that can block a stream, and they block only that
+// stream: ingest publishes into the subscription's bounded buffer and never
+// waits on a client.
+const streamWriteTimeout = 10 * time.Second
+
+// tailFunc reads back the rows of one signal that a batch of keys names.
+type tailFunc func(ctx context.Context, db *sql.DB, keys []string, criteria any) (json.RawMessage, error)
+
+func tailFor(signal live.Signal) tailFunc {
+	if signal == live.Spans {
+		return spans.Tail
+	}
+	return logs.Tail
+}
+
+// streamHandler serves GET /stream?signal=logs|spans&query=<query tree JSON>
+// as Server-Sent Events: newly ingested records matching the query, from the
+// moment of connection on.
+//
+// Events, each a single line of JSON:
+//
+//	event: logs | spans    an array shaped like searchLogs items, or spans
+//	event: dropped         {"count": n} -- records that arrived while the
+//	                       client was behind and were never checked
+//	event: error           {"message": "..."} -- the stream is ending
+//
+// The query tree is the one searchLogs or searchTraces takes, applied by the
+// same SQL builder, and is validated before the stream starts so a bad one is
+// a 400 rather than an event.
+func (s *Server) streamHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	signal, ok := live.ParseSignal(request.URL.Query().Get("signal"))
+	if !ok {
+		http.Error(writer, `signal must be "logs" or "spans"`, http.StatusBadRequest)
+		return
+	}
+	var criteria any
+	if q := request.URL.Query().Get("query"); q != "" {
+		if err := json.Unmarshal([]byte(q), &criteria); err != nil {
+			http.Error(writer, "query is not valid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	tail := tailFor(signal)
+	if _, err := storeRead(s.store, func(db *sql.DB) (json.RawMessage, error) {
+		return tail(ctx, db, nil, criteria)
+	}); err != nil {
+		if errors.Is(mapStoreError(err), ErrInvalidQuery) {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.logger.Error("validating stream query", zap.Error(err))
+		http.Error(writer, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	controller := http.NewResponseController(writer)
+	// The server's ReadTimeout leaves a deadline on the connection, and when it
+	// fires net/http cancels the request context -- which would end every
+	// stream thirty seconds in. A stream reads nothing after its headers, so
+	// the deadline has nothing left to protect.
+	_ = controller.SetReadDeadline(time.Time{})
+
+	sub := s.store.Live().Subscribe(signal, live.DefaultBufferLimit)
+	defer sub.Close()
+
+	send := func(chunk string) error {
+		if err := controller.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(writer, chunk); err != nil {
+			return err
+		}
+		return controller.Flush()
+	}
+	// DuckDB's to_json is compact and escapes newlines inside strings, so every
+	// payload here is one line and needs no splitting across data: fields.
+	event := func(name string, data []byte) error {
+		return send(fmt.Sprintf("event: %s\ndata: %s\n\n", name, data))
+	}
+
+	header := writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	// Asks a buffering reverse proxy, if one is in front, to pass events
+	// through as they are written.
+	header.Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+	if err := send(": tailing " + string(signal) + "\n\n"); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.streamsDone:
+			return
+		case <-heartbeat.C:
+			if err := send(": keepalive\n\n"); err != nil {
+				return
+			}
+		case <-sub.Ready():
+			keys, dropped := sub.Take()
+			if dropped > 0 {
+				if err := event("dropped", fmt.Appendf(nil, `{"count":%d}`, dropped)); err != nil {
+					return
+				}
+			}
+			items, err := storeRead(s.store, func(db *sql.DB) (json.RawMessage, error) {
+				return tail(ctx, db, keys, criteria)
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				s.logger.Error("reading tailed rows", zap.String("signal", string(signal)), zap.Error(err))
+				msg, _ := json.Marshal(map[string]string{"message": "tail query failed"})
+				_ = event("error", msg)
+				return
+			}
+			if string(items) == "[]" {
+				continue
+			}
+			if err := event(string(signal), items); err != nil {
+				return
+			}
+		}
+	}
+}
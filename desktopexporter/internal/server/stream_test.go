@@ -0,0 +1,180 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/live"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+)
+
+func setupStreamServer(t *testing.T) (*Server, *store.Store, *httptest.Server) {
+	t.Helper()
+	str, err := store.NewStore(context.Background(), "", zap.NewNop())
+	require.NoError(t, err)
+	s, err := NewServer("localhost:0", str, zap.NewNop(), telemetry.Disabled(), nil, nil)
+	require.NoError(t, err)
+	testServer := httptest.NewServer(s.server.Handler)
+	t.Cleanup(func() {
+		testServer.Close()
+		str.Close()
+	})
+	return s, str, testServer
+}
+
+// openStream connects and reads up to the opening comment, which is written
+// after the subscription exists -- so anything published from here on is the
+// stream's to deliver.
+func openStream(t *testing.T, base string, params url.Values) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	res, err := http.Get(base + "/stream?" + params.Encode())
+	require.NoError(t, err)
+	t.Cleanup(func() { res.Body.Close() })
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	r := bufio.NewReader(res.Body)
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(line, ": tailing"), "got %q", line)
+	_, err = r.ReadString('\n')
+	require.NoError(t, err)
+	return res, r
+}
+
+// readEvent returns the next event's name and data, skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) (name, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if name != "" {
+				return name, data
+			}
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// ingestLogs writes one record per severity and publishes the batch the way
+// the exporter does: after the write has committed.
+func ingestLogs(t *testing.T, str *store.Store, severities ...string) {
+	t.Helper()
+	ld := plog.NewLogs()
+	sl := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty()
+	for i, sev := range severities {
+		rec := sl.LogRecords().AppendEmpty()
+		rec.SetTimestamp(pcommon.Timestamp(time.Now().UnixNano() + int64(i)))
+		rec.SetSeverityText(sev)
+		rec.Body().SetStr(sev + " happened")
+	}
+	var keys []string
+	require.NoError(t, str.WithConn(func(conn driver.Conn) error {
+		var err error
+		keys, err = logs.IngestReturning(context.Background(), conn, ld, str.FlushedIDs())
+		return err
+	}))
+	str.Live().Publish(live.Logs, keys)
+}
+
+func TestStreamDeliversMatchingLogs(t *testing.T) {
+	_, str, testServer := setupStreamServer(t)
+
+	query, err := json.Marshal(map[string]any{
+		"id":   "q",
+		"type": "condition",
+		"query": map[string]any{
+			"field":         map[string]any{"name": "severity_text", "type": "string", "searchScope": "field"},
+			"fieldOperator": "=",
+			"value":         "ERROR",
+		},
+	})
+	require.NoError(t, err)
+	_, r := openStream(t, testServer.URL, url.Values{"signal": {"logs"}, "query": {string(query)}})
+
+	ingestLogs(t, str, "INFO")
+	ingestLogs(t, str, "ERROR", "INFO")
+
+	name, data := readEvent(t, r)
+	assert.Equal(t, "logs", name, "the INFO-only batch matched nothing and sent nothing")
+	var items []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(data), &items))
+	require.Len(t, items, 1)
+	assert.Equal(t, "ERROR", items[0]["severityText"])
+}
+
+func TestStreamReportsDrops(t *testing.T) {
+	_, str, testServer := setupStreamServer(t)
+	_, r := openStream(t, testServer.URL, url.Values{"signal": {"logs"}})
+
+	// Past the buffer in one publish: the handler cannot have taken any of it
+	// yet, so the overflow is certain rather than a race.
+	keys := make([]string, live.DefaultBufferLimit+7)
+	for i := range keys {
+		keys[i] = "00000000-0000-0000-0000-000000000000"
+	}
+	str.Live().Publish(live.Logs, keys)
+
+	name, data := readEvent(t, r)
+	assert.Equal(t, "dropped", name)
+	assert.JSONEq(t, `{"count":7}`, data)
+}
+
+func TestStreamEndsOnShutdown(t *testing.T) {
+	s, _, testServer := setupStreamServer(t)
+	res, r := openStream(t, testServer.URL, url.Values{"signal": {"spans"}})
+
+	// The handler runs under httptest's server, not s.server, but the hook is
+	// registered on s.server, and Shutdown runs hooks whether or not it served.
+	require.NoError(t, s.server.Shutdown(context.Background()))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.ReadString('\n')
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.Error(t, err, "the body ends")
+	case <-time.After(5 * time.Second):
+		res.Body.Close()
+		t.Fatal("stream still open after shutdown")
+	}
+}
+
+func TestStreamRejectsBadRequests(t *testing.T) {
+	_, _, testServer := setupStreamServer(t)
+
+	for _, params := range []url.Values{
+		{},
+		{"signal": {"metrics"}},
+		{"signal": {"logs"}, "query": {"{not json"}},
+		{"signal": {"spans"}, "query": {`{"type":"nonsense"}`}},
+	} {
+		res, err := http.Get(testServer.URL + "/stream?" + params.Encode())
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, "%v", params)
+	}
+}
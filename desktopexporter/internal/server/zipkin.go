@@ -0,0 +1,184 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/legacytrace"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/sink"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+)
+
+// maxSpansRequestBodyBytes caps a POST /api/v2/spans body once decompressed.
+// Zipkin reporters flush at around 500KB; a Jaeger download of a large trace
+// can run to a few megabytes. 32MB leaves room for both without letting one
+// request take the process's memory.
+const maxSpansRequestBodyBytes = 32 << 20
+
+// spansHandler is Zipkin's span intake, POST /api/v2/spans, so a service that
+// only reports Zipkin can be pointed at the viewer's port instead of 9411. A
+// JSON array is read as a Zipkin v2 span list; a JSON object as a Jaeger
+// download, which makes the same URL the way to curl one in. Either goes
+// through sink like any other batch, once settleStoredSpanIDs has squared
+// its ids with the store's, and the answer is Zipkin's 202.
+//
+// Only JSON is accepted. Zipkin's protobuf encoding is refused with 415, which
+// reporters treat as a configuration error rather than retrying.
+//
+// No token is asked for: Zipkin reporters could not send one, and the OTLP
+// receivers this stands beside take spans from anyone who can reach them.
+// guard's Host and Origin checks still apply, so a page on another origin
+// cannot post here.
+func (s *Server) spansHandler(writer http.ResponseWriter, request *http.Request) {
+	if ct := request.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != "application/json" && mediaType != "text/plain") {
+			http.Error(writer, "only JSON is accepted: Zipkin v2 JSON or a Jaeger JSON trace", http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	body, status, err := readSpansBody(writer, request)
+	if err != nil {
+		http.Error(writer, err.Error(), status)
+		return
+	}
+
+	td, err := decodeLegacyTraces(body)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = settleStoredSpanIDs(request.Context(), s.store, td); err != nil {
+		s.spansIngestFailed(writer, err)
+		return
+	}
+	if err = sink.Traces(request.Context(), s.store, td, nil); err != nil {
+		s.spansIngestFailed(writer, err)
+		return
+	}
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+// spansIngestFailed answers a batch spansHandler could not store.
+func (s *Server) spansIngestFailed(writer http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrStoreReadOnly):
+		http.Error(writer, "viewer is read-only", http.StatusForbidden)
+	case errors.Is(err, context.Canceled), errors.Is(err, store.ErrStoreConnectionClosed):
+		http.Error(writer, "viewer is shutting down", http.StatusServiceUnavailable)
+	default:
+		s.logger.Error("ingesting /api/v2/spans", zap.Error(err))
+		http.Error(writer, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// settleStoredSpanIDs squares a batch's span ids with the spans already
+// stored, which the store's key on span_id would otherwise turn into a failed
+// append, and the whole batch refused with it. A stored span of the same trace
+// under an id in the batch is one of two things:
+//
+//   - the same span sent again, by a reporter retrying: same kind. The copy
+//     is dropped; the store already has it.
+//   - the other half of a Zipkin shared span, which reporters send from the
+//     two services, in two batches: legacytrace.Zipkin can only unshare the
+//     halves it sees together. The incoming half moves to
+//     legacytrace.UnsharedSpanID -- the server under its client, as
+//     legacytrace.Unshare puts it; a client whose server half came first
+//     keeps its parent, beside the server it cannot be put above. And if
+//     that id is taken too, the half was moved once already and this is a
+//     retry of it.
+//
+// A span sharing an id with another trace's is left alone: OTLP makes that a
+// collision of random ids, not a convention, and the append's error says so.
+func settleStoredSpanIDs(ctx context.Context, st *store.Store, td ptrace.Traces) error {
+	var ids []pcommon.SpanID
+	for _, rs := range td.ResourceSpans().All() {
+		for _, ss := range rs.ScopeSpans().All() {
+			for _, span := range ss.Spans().All() {
+				ids = append(ids, span.SpanID(), legacytrace.UnsharedSpanID(span.TraceID(), span.SpanID()))
+			}
+		}
+	}
+	stored, err := storeRead(st, func(db *sql.DB) (map[pcommon.SpanID]spans.StoredSpan, error) {
+		return spans.LookupSpanIDs(ctx, db, ids)
+	})
+	if err != nil || len(stored) == 0 {
+		return err
+	}
+
+	for _, rs := range td.ResourceSpans().All() {
+		for _, ss := range rs.ScopeSpans().All() {
+			ss.Spans().RemoveIf(func(span ptrace.Span) bool {
+				other, ok := stored[span.SpanID()]
+				if !ok || other.TraceID != span.TraceID() {
+					return false
+				}
+				if other.Kind == span.Kind() {
+					return true
+				}
+				moved := legacytrace.UnsharedSpanID(span.TraceID(), span.SpanID())
+				if _, retried := stored[moved]; retried {
+					return true
+				}
+				if span.Kind() == ptrace.SpanKindServer {
+					legacytrace.Unshare(span)
+				} else {
+					span.SetSpanID(moved)
+				}
+				return false
+			})
+		}
+	}
+	return nil
+}
+
+// readSpansBody reads the request body, gunzipping it if the reporter
+// compressed it -- zipkin-reporter does when asked, and says so in
+// Content-Encoding.
+func readSpansBody(writer http.ResponseWriter, request *http.Request) ([]byte, int, error) {
+	var r io.Reader = http.MaxBytesReader(writer, request.Body, maxSpansRequestBodyBytes)
+	switch request.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		defer zr.Close()
+		r = io.LimitReader(zr, maxSpansRequestBodyBytes+1)
+	default:
+		return nil, http.StatusUnsupportedMediaType, errors.New("Content-Encoding must be gzip or absent")
+	}
+
+	body, err := io.ReadAll(r)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr), len(body) > maxSpansRequestBodyBytes:
+		return nil, http.StatusRequestEntityTooLarge, errors.New("request body too large")
+	case err != nil:
+		return nil, http.StatusBadRequest, err
+	}
+	return body, 0, nil
+}
+
+// decodeLegacyTraces tells the two formats apart by their first byte.
+func decodeLegacyTraces(body []byte) (ptrace.Traces, error) {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return legacytrace.Zipkin(trimmed)
+	}
+	return legacytrace.Jaeger(trimmed)
+}
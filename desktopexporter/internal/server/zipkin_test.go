@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/telemetry"
+)
+
+const zipkinBatch = `[{"traceId": "463ac35c9f6413ad48485a3953bb6124", "id": "a2fb4a1d1a96d312", "name": "get /cart",
+	"kind": "SERVER", "timestamp": 1700000000000000, "duration": 10, "localEndpoint": {"serviceName": "cart"}}]`
+
+const jaegerTraceDoc = `{"data": [{"spans": [{"traceID": "48485a3953bb6124", "spanID": "b7ad6b7169203331",
+	"operationName": "redis GET", "startTime": 1700000000000000, "duration": 5, "processID": "p1"}],
+	"processes": {"p1": {"serviceName": "cart"}}}]}`
+
+func TestSpansEndpoint(t *testing.T) {
+	str, err := store.NewStore(context.Background(), "", zap.NewNop())
+	require.NoError(t, err)
+	defer str.Close()
+	s, err := NewServer("localhost:8000", str, zap.NewNop(), telemetry.Disabled(), nil, nil)
+	require.NoError(t, err)
+	testServer := httptest.NewServer(s.server.Handler)
+	defer testServer.Close()
+
+	post := func(contentType, encoding string, body []byte) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, testServer.URL+"/api/v2/spans", bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		if encoding != "" {
+			req.Header.Set("Content-Encoding", encoding)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res
+	}
+	spanNames := func() []string {
+		names, err := storeRead(str, func(db *sql.DB) ([]string, error) {
+			rows, err := db.Query(`select name from spans order by name`)
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+			var out []string
+			for rows.Next() {
+				var name string
+				if err := rows.Scan(&name); err != nil {
+					return nil, err
+				}
+				out = append(out, name)
+			}
+			return out, rows.Err()
+		})
+		require.NoError(t, err)
+		return names
+	}
+
+	assert.Equal(t, http.StatusAccepted, post("application/json", "", []byte(zipkinBatch)).StatusCode)
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err = zw.Write([]byte(jaegerTraceDoc))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	assert.Equal(t, http.StatusAccepted, post("application/json; charset=utf-8", "gzip", gz.Bytes()).StatusCode)
+
+	assert.Equal(t, []string{"get /cart", "redis GET"}, spanNames())
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, post("application/x-protobuf", "", []byte{0x0a}).StatusCode)
+	assert.Equal(t, http.StatusUnsupportedMediaType, post("application/json", "br", []byte(zipkinBatch)).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, post("application/json", "", []byte(`[{"traceId": "nope"}]`)).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, post("application/json", "", []byte(`{"resourceSpans": []}`)).StatusCode)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post("application/json", "",
+		[]byte("["+strings.Repeat(" ", maxSpansRequestBodyBytes)+"]")).StatusCode)
+	assert.Len(t, spanNames(), 2, "a refused request stores nothing")
+}
+
+func TestSpansEndpointSharedSpans(t *testing.T) {
+	str, err := store.NewStore(context.Background(), "", zap.NewNop())
+	require.NoError(t, err)
+	defer str.Close()
+	s, err := NewServer("localhost:8000", str, zap.NewNop(), telemetry.Disabled(), nil, nil)
+	require.NoError(t, err)
+	testServer := httptest.NewServer(s.server.Handler)
+	defer testServer.Close()
+
+	post := func(body string) int {
+		t.Helper()
+		res, err := http.Post(testServer.URL+"/api/v2/spans", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	// Every trace's halves share its low half as their id: span ids are the
+	// store's key across traces.
+	half := func(trace, kind, service, extra string) string {
+		return `{"traceId": "` + trace + `", "id": "` + trace[16:] + `", "parentId": "0020000000000001", "kind": "` + kind +
+			`", "name": "get /api", "timestamp": 1700000000000000, "duration": 10, "localEndpoint": {"serviceName": "` +
+			service + `"}` + extra + `}`
+	}
+	// spansOf lists a trace's spans as "service kind parent", in span id order.
+	spansOf := func(trace string) []string {
+		t.Helper()
+		out, err := storeRead(str, func(db *sql.DB) ([]string, error) {
+			rows, err := db.Query(`select service_name || ' ' || kind || ' ' || coalesce(right(replace(parent_span_id::varchar, '-', ''), 16), '-')
+				from spans where replace(trace_id::varchar, '-', '') = ? order by span_id`, trace)
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+			var out []string
+			for rows.Next() {
+				var s string
+				if err := rows.Scan(&s); err != nil {
+					return nil, err
+				}
+				out = append(out, s)
+			}
+			return out, rows.Err()
+		})
+		require.NoError(t, err)
+		return out
+	}
+	unrelated := `{"traceId": "00000000000000000000000000000099", "id": "0000000000000099", "name": "cron",
+		"timestamp": 1700000000000000, "duration": 10, "localEndpoint": {"serviceName": "cron"}}`
+
+	t.Run("OneBatch", func(t *testing.T) {
+		const trace = "463ac35c9f6413ad48485a3953bb6124"
+		require.Equal(t, http.StatusAccepted, post("["+half(trace, "CLIENT", "frontend", "")+","+
+			half(trace, "SERVER", "backend", `, "shared": true`)+","+unrelated+"]"))
+		got := spansOf(trace)
+		assert.ElementsMatch(t, []string{"frontend Client 0020000000000001", "backend Server 48485a3953bb6124"}, got)
+		assert.Equal(t, []string{"cron Internal -"}, spansOf("00000000000000000000000000000099"), "the batch's other spans stored too")
+	})
+
+	t.Run("TwoBatches", func(t *testing.T) {
+		const trace = "563ac35c9f6413ad48485a3953bb6125"
+		require.Equal(t, http.StatusAccepted, post("["+half(trace, "CLIENT", "frontend", "")+"]"))
+		require.Equal(t, http.StatusAccepted, post("["+half(trace, "SERVER", "backend", "")+"]"))
+		assert.ElementsMatch(t, []string{"frontend Client 0020000000000001", "backend Server 48485a3953bb6125"}, spansOf(trace))
+
+		// A reporter retrying either batch adds nothing.
+		require.Equal(t, http.StatusAccepted, post("["+half(trace, "SERVER", "backend", "")+"]"))
+		require.Equal(t, http.StatusAccepted, post("["+half(trace, "CLIENT", "frontend", "")+"]"))
+		assert.Len(t, spansOf(trace), 2)
+	})
+
+	t.Run("ServerFirst", func(t *testing.T) {
+		const trace = "663ac35c9f6413ad48485a3953bb6126"
+		require.Equal(t, http.StatusAccepted, post("["+half(trace, "SERVER", "backend", "")+"]"))
+		require.Equal(t, http.StatusAccepted, post("["+half(trace, "CLIENT", "frontend", "")+"]"))
+		assert.ElementsMatch(t, []string{"backend Server 0020000000000001", "frontend Client 0020000000000001"}, spansOf(trace))
+	})
+}
@@ -0,0 +1,394 @@
+// Package bundle writes the store, or a slice of it, as a DuckDB export: one
+// Parquet file per table plus the schema.sql and load.sql that rebuild it.
+//
+// A bundle is a database, not a report. `IMPORT DATABASE` in any DuckDB --
+// the CLI, a notebook, this viewer's own store code -- brings back the tables,
+// the indexes and every macro, so attrs_json and the rest work on it with no
+// viewer running. That is what makes it worth attaching to a bug report: the
+// person reading it can ask their own questions of the data.
+//
+// An unfiltered bundle exports the live database as it stands. A filtered one
+// cannot, because EXPORT DATABASE takes a whole catalog, so the slice is first
+// copied into an empty in-memory database carrying the same schema, and that is
+// what gets exported. The copy is done in SQL (queries/bundle), in one
+// transaction, reading the store under whatever lock the caller holds.
+package bundle
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
+)
+
+var (
+	ErrBundleInternal = errors.New("bundle internal error")
+	ErrNotBundle      = errors.New("not a bundle")
+)
+
+// Filter restricts what a bundle holds. The zero value is the whole store.
+type Filter struct {
+	// Start and End bound a window in Unix nanoseconds, inclusive. A zero End
+	// means no upper bound. Spans are kept a trace at a time, so a trace that
+	// starts in the window comes with every span it has.
+	Start, End int64
+
+	// TraceIDs, when non-empty, keeps only these traces and the logs that
+	// carry one of their ids; metrics are left out. Canonical dashed uuids.
+	TraceIDs []string
+}
+
+func (f Filter) whole() bool {
+	return f.Start == 0 && f.End == 0 && len(f.TraceIDs) == 0
+}
+
+// Export writes a bundle of db into dir, which must not exist yet or be empty.
+// db is the store's pool: the caller holds the store's read lock around the
+// call, and the export runs on one connection taken from the pool, which is
+// discarded afterwards rather than returned.
+func Export(ctx context.Context, db *sql.DB, dir string, f Filter) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("Export: %w: %w", ErrBundleInternal, err)
+	}
+	defer func() {
+		// A filtered export leaves temp tables on the connection, and a failed
+		// one can leave a transaction open. Neither belongs in the pool, so
+		// the connection is thrown away instead of reset by hand.
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		_ = conn.Close()
+	}()
+
+	var src string
+	if err := conn.QueryRowContext(ctx, `select current_database()`).Scan(&src); err != nil {
+		return fmt.Errorf("Export: %w: %w", ErrBundleInternal, err)
+	}
+
+	if f.whole() {
+		if _, err := conn.ExecContext(ctx, exportStatement(src, dir)); err != nil {
+			return fmt.Errorf("Export: %w: %w", ErrBundleInternal, err)
+		}
+		if err := emptyLocalTables(ctx, conn, src, dir); err != nil {
+			return fmt.Errorf("Export: %w: %w", ErrBundleInternal, err)
+		}
+		return nil
+	}
+
+	dst, err := scratchName()
+	if err != nil {
+		return fmt.Errorf("Export: %w: %w", ErrBundleInternal, err)
+	}
+	if _, err := conn.ExecContext(ctx, `attach ':memory:' as `+ident(dst)); err != nil {
+		return fmt.Errorf("Export: %w: %w", ErrBundleInternal, err)
+	}
+	// Attached databases belong to the DuckDB instance, not the connection,
+	// so discarding the connection would not take this one with it.
+	defer conn.ExecContext(context.Background(), `detach database if exists `+ident(dst))
+
+	// Copies types, sequences, tables, indexes and macros -- including the
+	// schema_meta stamp's table -- so the bundle imports as the same schema
+	// the store has, whatever version that is.
+	if _, err := conn.ExecContext(ctx, `copy from database `+ident(src)+` to `+ident(dst)+` (schema)`); err != nil {
+		return fmt.Errorf("Export: %w: %w", ErrBundleInternal, err)
+	}
+
+	end := f.End
+	if end == 0 {
+		end = math.MaxInt64
+	}
+	var traceIDs any
+	if len(f.TraceIDs) > 0 {
+		traceIDs = f.TraceIDs
+	}
+	filter, err := queries.Render(queries.BundleFilter, nil)
+	if err != nil {
+		return fmt.Errorf("Export: %w: %w", ErrBundleInternal, err)
+	}
+	if _, err := conn.ExecContext(ctx, filter, f.Start, end, traceIDs); err != nil {
+		return fmt.Errorf("Export: %w: %w", ErrBundleInternal, err)
+	}
+	copyRows, err := queries.Render(queries.BundleCopyRows, copyRowsParams{Src: ident(src), Dst: ident(dst)})
+	if err != nil {
+		return fmt.Errorf("Export: %w: %w", ErrBundleInternal, err)
+	}
+	if _, err := conn.ExecContext(ctx, copyRows); err != nil {
+		return fmt.Errorf("Export: %w: %w", ErrBundleInternal, err)
+	}
+
+	if _, err := conn.ExecContext(ctx, exportStatement(dst, dir)); err != nil {
+		return fmt.Errorf("Export: %w: %w", ErrBundleInternal, err)
+	}
+	return nil
+}
+
+// localTables are the store's own bookkeeping about this machine rather than
+// telemetry: file_offsets names every file the directory watcher has tailed,
+// home directories and all. A bundle is made to be handed to someone else, so
+// it carries these tables empty. A filtered bundle never copies their rows; a
+// whole one exports the live catalog, and has each table's Parquet file
+// written over with none.
+var localTables = []string{"file_offsets"}
+
+// emptyLocalTables rewrites the Parquet file EXPORT DATABASE wrote for each
+// local table with one of the same columns and no rows. A table the store
+// does not have -- a read-only file from before it existed -- has no file.
+func emptyLocalTables(ctx context.Context, conn *sql.Conn, catalog, dir string) error {
+	for _, table := range localTables {
+		path := filepath.Join(dir, table+".parquet")
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		query := `copy (select * from ` + ident(catalog) + `.` + ident(table) + ` limit 0) to ` +
+			literal(path) + ` (format parquet)`
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyRowsParams are the two catalogs copy_rows.sql moves rows between, both
+// already quoted as identifiers.
+type copyRowsParams struct {
+	Src, Dst string
+}
+
+func exportStatement(catalog, dir string) string {
+	return `export database ` + ident(catalog) + ` to ` + literal(dir) + ` (format parquet)`
+}
+
+// scratchName is a catalog name no other export can be using at the same
+// time: attached names are instance-wide.
+func scratchName() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return "bundle_" + hex.EncodeToString(b[:]), nil
+}
+
+// ident and literal quote for the statements that take no parameters --
+// ATTACH, COPY FROM DATABASE and EXPORT DATABASE name catalogs and paths in
+// the statement text. The catalog name of a file-backed store is its file
+// name, which the user chose.
+func ident(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func literal(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
+
+// readme travels at the top of every archive, for whoever receives it.
+const readme = `This is an OpenTelemetry capture exported by otel-desktop-viewer.
+
+It is a DuckDB database export: one Parquet file per table, plus schema.sql and
+load.sql. To open it, unzip it into a directory and import that directory:
+
+    unzip capture.zip -d capture
+    duckdb capture.duckdb "import database 'capture'"
+
+The import recreates the tables and the viewer's SQL macros, so for example
+
+    select name, attrs_json(attribute_ids) from spans limit 10;
+
+works in the DuckDB shell afterwards.
+`
+
+// Zip writes the files Export left in dir to w as one archive, flat, with a
+// README.txt explaining how to open it.
+func Zip(w io.Writer, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("Zip: %w: %w", ErrBundleInternal, err)
+	}
+	zw := zip.NewWriter(w)
+	readmeEntry, err := zw.Create("README.txt")
+	if err != nil {
+		return fmt.Errorf("Zip: %w: %w", ErrBundleInternal, err)
+	}
+	if _, err := io.WriteString(readmeEntry, readme); err != nil {
+		return fmt.Errorf("Zip: %w: %w", ErrBundleInternal, err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if err := addFile(zw, filepath.Join(dir, e.Name()), e.Name()); err != nil {
+			return fmt.Errorf("Zip: %w: %w", ErrBundleInternal, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("Zip: %w: %w", ErrBundleInternal, err)
+	}
+	return nil
+}
+
+func addFile(zw *zip.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Parquet is compressed already; deflating it again costs time for a
+	// few percent. The SQL files are text and shrink well.
+	method := zip.Deflate
+	if strings.HasSuffix(name, ".parquet") {
+		method = zip.Store
+	}
+	out, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, f)
+	return err
+}
+
+// Limits on what Unzip writes. A bundle is one Parquet file per table plus
+// three small text files, so the entry count has room to spare. The byte caps
+// are what stop an archive that inflates far past its own size -- a zip bomb
+// -- from filling the temporary directory it is unzipped into: the text files
+// are kilobytes, and the Parquet is the telemetry, for which the total is
+// generous on a desktop.
+const (
+	maxEntries       = 64
+	maxTextBytes     = 16 << 20
+	maxUnzippedBytes = 8 << 30
+)
+
+// bundleFile reports whether name is a file Zip writes: the readme, the two
+// SQL files, or a table's Parquet.
+func bundleFile(name string) bool {
+	switch name {
+	case "README.txt", "schema.sql", "load.sql":
+		return true
+	}
+	return parquetFile.MatchString(name)
+}
+
+// Unzip extracts an archive Zip wrote into dir, which must exist. The archive
+// may be someone else's, so it is held to what Zip writes: a flat list of
+// bundle files, each once, inflating to no more than the caps above. Anything
+// else -- a directory, a ".." climbing out of dir, a file Export never
+// writes, an entry bigger than it says or than the caps allow -- is refused
+// with ErrNotBundle rather than followed.
+func Unzip(r io.ReaderAt, size int64, dir string) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("Unzip: %w: %w", ErrNotBundle, err)
+	}
+	if len(zr.File) > maxEntries {
+		return fmt.Errorf("Unzip: %w: %d entries, more than a bundle holds", ErrNotBundle, len(zr.File))
+	}
+
+	// The sizes the archive declares are checked up front, so an honest
+	// archive over the cap is refused before anything is written; the
+	// limits in extractFile catch one whose declared sizes lie.
+	seen := make(map[string]bool, len(zr.File))
+	var declared uint64
+	for _, zf := range zr.File {
+		if !bundleFile(zf.Name) {
+			return fmt.Errorf("Unzip: %w: entry %q is not a file a bundle holds", ErrNotBundle, zf.Name)
+		}
+		if seen[zf.Name] {
+			return fmt.Errorf("Unzip: %w: entry %q appears twice", ErrNotBundle, zf.Name)
+		}
+		seen[zf.Name] = true
+		if zf.UncompressedSize64 > uint64(entryLimit(zf.Name)) {
+			return fmt.Errorf("Unzip: %w: entry %q is larger than a bundle's", ErrNotBundle, zf.Name)
+		}
+		declared += zf.UncompressedSize64
+	}
+	if declared > maxUnzippedBytes {
+		return fmt.Errorf("Unzip: %w: it unzips to more than %d bytes", ErrNotBundle, int64(maxUnzippedBytes))
+	}
+
+	remaining := int64(maxUnzippedBytes)
+	for _, zf := range zr.File {
+		n, err := extractFile(zf, filepath.Join(dir, zf.Name), min(entryLimit(zf.Name), remaining))
+		// archive/zip stops an entry at the size it declares, with
+		// ErrFormat, and errTooLarge at the cap; either way it is the
+		// archive at fault, not this end.
+		if errors.Is(err, errTooLarge) || errors.Is(err, zip.ErrFormat) || errors.Is(err, zip.ErrChecksum) {
+			return fmt.Errorf("Unzip: %w: entry %q: %w", ErrNotBundle, zf.Name, err)
+		}
+		if err != nil {
+			return fmt.Errorf("Unzip: %w: %w", ErrBundleInternal, err)
+		}
+		remaining -= n
+	}
+	return nil
+}
+
+// entryLimit is how large the named entry may inflate to.
+func entryLimit(name string) int64 {
+	if strings.HasSuffix(name, ".parquet") {
+		return maxUnzippedBytes
+	}
+	return maxTextBytes
+}
+
+var errTooLarge = errors.New("unzips to more than a bundle entry may")
+
+// extractFile writes zf to path, reading at most limit bytes of it, and
+// returns how many it wrote. One byte past the limit is read to tell an
+// entry that fills it exactly from one that goes on.
+func extractFile(zf *zip.File, path string, limit int64) (int64, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, io.LimitReader(rc, limit+1))
+	if err == nil && n > limit {
+		err = errTooLarge
+	}
+	if err != nil {
+		out.Close()
+		return n, err
+	}
+	return n, out.Close()
+}
+
+// IsDir reports whether dir holds an unzipped bundle. schema.sql is the file
+// Import cannot do without, so its presence is the test.
+func IsDir(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "schema.sql"))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// Write exports a bundle of db and zips it to w, using a temporary directory
+// it makes under root and removes afterwards; an empty root is the system's
+// temporary directory. lock runs the export half under the caller's store
+// lock; the zip half runs after it is released, since compressing needs
+// nothing from the store.
+func Write(ctx context.Context, w io.Writer, root string, f Filter, lock func(func(db *sql.DB) error) error) error {
+	tmp, err := os.MkdirTemp(root, "otel-bundle-")
+	if err != nil {
+		return fmt.Errorf("Write: %w: %w", ErrBundleInternal, err)
+	}
+	defer os.RemoveAll(tmp)
+
+	dir := filepath.Join(tmp, "export")
+	if err := lock(func(db *sql.DB) error {
+		return Export(ctx, db, dir, f)
+	}); err != nil {
+		return err
+	}
+	return Zip(w, dir)
+}
@@ -0,0 +1,324 @@
+package bundle_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/bundle"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/metrics"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+	_ "github.com/duckdb/duckdb-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+const (
+	traceA = "0af7651916cd43dd8448eb211c80319c"
+	traceB = "4bf92f3577b34da6a3ce929d0e0e4736"
+	// base is an arbitrary fixed time: trace A runs at base, trace B an hour
+	// later, and the metric in between.
+	base = int64(1_700_000_000_000_000_000)
+	hour = int64(3_600_000_000_000)
+)
+
+func traceID(s string) pcommon.TraceID {
+	var id pcommon.TraceID
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	copy(id[:], b)
+	return id
+}
+
+// seedStore writes two traces (B's second span starting well after the
+// first), a log on each trace plus one on none, and a gauge with metadata.
+func seedStore(t *testing.T) *store.Store {
+	t.Helper()
+	ctx := context.Background()
+	s, err := store.NewStore(ctx, "", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "checkout")
+	ss := rs.ScopeSpans().AppendEmpty()
+	ss.Scope().SetName("tracer")
+	for i, sp := range []struct {
+		trace string
+		start int64
+		attr  string
+	}{
+		{traceA, base, "a-only"},
+		{traceB, base + hour, "b-root"},
+		{traceB, base + 2*hour, "b-late"},
+	} {
+		span := ss.Spans().AppendEmpty()
+		span.SetTraceID(traceID(sp.trace))
+		span.SetSpanID(pcommon.SpanID{0, 0, 0, 0, 0, 0, 0, byte(i + 1)})
+		span.SetName(sp.attr)
+		span.SetStartTimestamp(pcommon.Timestamp(sp.start))
+		span.SetEndTimestamp(pcommon.Timestamp(sp.start + 1000))
+		span.Attributes().PutStr("step", sp.attr)
+		span.Events().AppendEmpty().Attributes().PutStr("event.attr", sp.attr)
+	}
+
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "worker")
+	sl := rl.ScopeLogs().AppendEmpty()
+	for _, lg := range []struct {
+		trace string
+		at    int64
+	}{
+		{traceA, base},
+		{traceB, base + hour},
+		{"", base + hour},
+	} {
+		rec := sl.LogRecords().AppendEmpty()
+		rec.SetTimestamp(pcommon.Timestamp(lg.at))
+		if lg.trace != "" {
+			rec.SetTraceID(traceID(lg.trace))
+		}
+		rec.Body().SetStr("log for " + lg.trace)
+		rec.Attributes().PutStr("log.attr", lg.trace)
+	}
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "meter")
+	m := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("queue.depth")
+	m.Metadata().PutStr("origin", "test")
+	dp := m.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetTimestamp(pcommon.Timestamp(base + hour))
+	dp.SetIntValue(7)
+	dp.Attributes().PutStr("queue", "main")
+
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		if err := spans.Ingest(ctx, conn, td, s.FlushedIDs()); err != nil {
+			return err
+		}
+		if err := logs.Ingest(ctx, conn, ld, s.FlushedIDs()); err != nil {
+			return err
+		}
+		return metrics.Ingest(ctx, conn, md, s.FlushedIDs())
+	}))
+	return s
+}
+
+// importBundle writes a bundle, unzips it and imports it into a fresh DuckDB
+// that has none of the store's schema -- which is how a recipient opens one.
+func importBundle(t *testing.T, s *store.Store, f bundle.Filter) *sql.DB {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, bundle.Write(context.Background(), &buf, s.ExportDir(), f, s.WithDBRead))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	dir := t.TempDir()
+	names := map[string]bool{}
+	for _, zf := range zr.File {
+		names[zf.Name] = true
+		rc, err := zf.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, zf.Name), b, 0o600))
+	}
+	assert.True(t, names["README.txt"])
+	assert.True(t, names["schema.sql"])
+	assert.True(t, names["spans.parquet"])
+
+	db, err := sql.Open("duckdb", "")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec(`import database '` + dir + `'`)
+	require.NoError(t, err)
+	return db
+}
+
+func count(t *testing.T, db *sql.DB, query string) int {
+	t.Helper()
+	var n int
+	require.NoError(t, db.QueryRow(query).Scan(&n))
+	return n
+}
+
+func column(t *testing.T, db *sql.DB, query string) []string {
+	t.Helper()
+	rows, err := db.Query(query)
+	require.NoError(t, err)
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		require.NoError(t, rows.Scan(&s))
+		out = append(out, s)
+	}
+	require.NoError(t, rows.Err())
+	return out
+}
+
+func TestWholeStoreRoundTrips(t *testing.T) {
+	s := seedStore(t)
+	db := importBundle(t, s, bundle.Filter{})
+
+	assert.Equal(t, 3, count(t, db, `select count(*) from spans`))
+	assert.Equal(t, 3, count(t, db, `select count(*) from logs`))
+	assert.Equal(t, 1, count(t, db, `select count(*) from datapoints`))
+	assert.Equal(t, 1, count(t, db, `select count(*) from schema_meta`))
+
+	// The macros came with it, and they resolve against the bundle's own
+	// dictionary.
+	assert.Equal(t, []string{"a-only"}, column(t, db,
+		`select attr_value(attribute_ids, 'step') from spans where name = 'a-only'`))
+}
+
+// What the directory watcher has read is about this machine, not the
+// telemetry, and its paths are not for whoever the bundle is sent to.
+func TestBundleLeavesFileOffsetsBehind(t *testing.T) {
+	for _, f := range []bundle.Filter{{}, {Start: base - hour, End: base + 2*hour}} {
+		s := seedStore(t)
+		require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+			_, err := conn.(driver.ExecerContext).ExecContext(context.Background(),
+				`insert into file_offsets (fingerprint, path, read_to) values ('f1', '/home/someone/traces.json', 10)`, nil)
+			return err
+		}))
+		db := importBundle(t, s, f)
+		assert.Equal(t, 0, count(t, db, `select count(*) from file_offsets`), "filter %+v", f)
+		assert.Equal(t, 3, count(t, db, `select count(*) from logs`), "filter %+v", f)
+	}
+}
+
+func TestTraceFilter(t *testing.T) {
+	s := seedStore(t)
+	db := importBundle(t, s, bundle.Filter{TraceIDs: []string{"0af76519-16cd-43dd-8448-eb211c80319c"}})
+
+	assert.Equal(t, []string{"a-only"}, column(t, db, `select name from spans`))
+	assert.Equal(t, 1, count(t, db, `select count(*) from events`))
+	assert.Equal(t, []string{"log for " + traceA}, column(t, db, `select body from logs`))
+	assert.Zero(t, count(t, db, `select count(*) from datapoints`), "metrics belong to no trace")
+	assert.Zero(t, count(t, db, `select count(*) from metric_streams`))
+
+	// Only what the kept rows reference: the two resources, and no
+	// attribute from trace B, the unrelated log or the metric.
+	assert.Equal(t, 2, count(t, db, `select count(*) from resources`))
+	assert.ElementsMatch(t, []string{"a-only", traceA}, column(t, db,
+		`select value from attributes where key in ('step', 'event.attr', 'log.attr', 'queue', 'origin')
+		 group by value`))
+	assert.Equal(t, 1, count(t, db, `select count(*) from schema_meta`))
+
+	// The scratch database the slice was copied into is gone from the store.
+	require.NoError(t, s.WithDBRead(func(storeDB *sql.DB) error {
+		assert.Zero(t, count(t, storeDB, `select count(*) from duckdb_databases() where database_name like 'bundle_%'`))
+		return nil
+	}))
+}
+
+func TestWindowKeepsWholeTraces(t *testing.T) {
+	s := seedStore(t)
+	db := importBundle(t, s, bundle.Filter{Start: base + hour - 1, End: base + hour + 1})
+
+	// b-late starts an hour past the window, and comes anyway: its trace
+	// started inside it.
+	assert.ElementsMatch(t, []string{"b-root", "b-late"}, column(t, db, `select name from spans`))
+	assert.Equal(t, 2, count(t, db, `select count(*) from logs`))
+	assert.Equal(t, 1, count(t, db, `select count(*) from datapoints`))
+
+	// The metric's metadata is a dictionary reference too, and survives.
+	assert.Equal(t, []string{"test"}, column(t, db,
+		`select attr_value(metadata_ids, 'origin') from metric_ingests`))
+}
+
+// An archive handed to --open is held to what Zip writes. Each of these would
+// otherwise write somewhere it should not, or far more than it weighs.
+func TestUnzipRefusesWhatZipDoesNotWrite(t *testing.T) {
+	deflated := func(n int) (raw []byte, crc uint32) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, err := zw.Create("x")
+		require.NoError(t, err)
+		_, err = w.Write(make([]byte, n))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		rc, err := zr.File[0].OpenRaw()
+		require.NoError(t, err)
+		raw, err = io.ReadAll(rc)
+		require.NoError(t, err)
+		return raw, zr.File[0].CRC32
+	}
+
+	for name, build := range map[string]func(zw *zip.Writer){
+		"a path": func(zw *zip.Writer) {
+			_, err := zw.Create("../schema.sql")
+			require.NoError(t, err)
+		},
+		"a file Export never writes": func(zw *zip.Writer) {
+			_, err := zw.Create("run.sh")
+			require.NoError(t, err)
+		},
+		"an entry twice": func(zw *zip.Writer) {
+			for range 2 {
+				_, err := zw.Create("schema.sql")
+				require.NoError(t, err)
+			}
+		},
+		"too many entries": func(zw *zip.Writer) {
+			for i := range 100 {
+				_, err := zw.Create(fmt.Sprintf("t%d.parquet", i))
+				require.NoError(t, err)
+			}
+		},
+		// 17 MiB of zeros deflates to a few KB, and says so honestly.
+		"a text file over its cap": func(zw *zip.Writer) {
+			raw, crc := deflated(17 << 20)
+			w, err := zw.CreateRaw(&zip.FileHeader{Name: "schema.sql", Method: zip.Deflate,
+				CRC32: crc, CompressedSize64: uint64(len(raw)), UncompressedSize64: 17 << 20})
+			require.NoError(t, err)
+			_, err = w.Write(raw)
+			require.NoError(t, err)
+		},
+		// The same, claiming to inflate to almost nothing.
+		"an entry larger than it says": func(zw *zip.Writer) {
+			raw, crc := deflated(1 << 20)
+			w, err := zw.CreateRaw(&zip.FileHeader{Name: "spans.parquet", Method: zip.Deflate,
+				CRC32: crc, CompressedSize64: uint64(len(raw)), UncompressedSize64: 10})
+			require.NoError(t, err)
+			_, err = w.Write(raw)
+			require.NoError(t, err)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			build(zw)
+			require.NoError(t, zw.Close())
+
+			dir := t.TempDir()
+			err := bundle.Unzip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), dir)
+			require.ErrorIs(t, err, bundle.ErrNotBundle)
+			assert.NoFileExists(t, filepath.Join(filepath.Dir(dir), "schema.sql"))
+		})
+	}
+}
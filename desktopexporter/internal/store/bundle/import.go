@@ -0,0 +1,225 @@
+package bundle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A bundle opened with --open is usually someone else's, and its schema.sql
+// and load.sql are SQL, not data. IMPORT DATABASE runs both as written, with
+// the filesystem open to them, so a crafted capture could hold a
+// `COPY ... TO '~/.ssh/authorized_keys'`, a read_text of /etc/passwd or an
+// INSTALL, and opening it to look would run that. Import therefore never runs
+// either file. It reads schema.sql for the statement shapes EXPORT DATABASE
+// writes and runs only those, and reads load.sql for which tables to fill
+// from which Parquet files, writing each COPY itself against the bundle's
+// own directory. Under both, the database is cut off from the filesystem
+// first; see sandbox.
+
+// Import loads the bundle in dir into db, which should be empty: the tables,
+// types, sequences and indexes schema.sql creates, filled from the Parquet
+// files load.sql names. A file holding anything else is refused with
+// ErrNotBundle before a statement has run.
+//
+// exportRoot is the one other directory the database may touch afterwards,
+// where exports of it are written; see sandbox. Runs on one connection, so
+// the COPYs see the tables the CREATEs made however the pool is sized.
+func Import(ctx context.Context, db *sql.DB, dir, exportRoot string) error {
+	if !IsDir(dir) {
+		return fmt.Errorf("Import: %w: %s has no schema.sql", ErrNotBundle, dir)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("Import: %w: %w", ErrBundleInternal, err)
+	}
+
+	schemaSQL, err := os.ReadFile(filepath.Join(dir, "schema.sql"))
+	if err != nil {
+		return fmt.Errorf("Import: %w: %w", ErrBundleInternal, err)
+	}
+	stmts, err := schemaStatements(string(schemaSQL))
+	if err != nil {
+		return fmt.Errorf("Import: %w: schema.sql: %w", ErrNotBundle, err)
+	}
+	loadSQL, err := os.ReadFile(filepath.Join(dir, "load.sql"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("Import: %w: %w", ErrBundleInternal, err)
+	}
+	copies, err := loadStatements(string(loadSQL), dir)
+	if err != nil {
+		return fmt.Errorf("Import: %w: load.sql: %w", ErrNotBundle, err)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("Import: %w: %w", ErrBundleInternal, err)
+	}
+	defer conn.Close()
+	if err := sandbox(ctx, conn, dir, exportRoot); err != nil {
+		return fmt.Errorf("Import: %w: %w", ErrBundleInternal, err)
+	}
+	for _, stmt := range append(stmts, copies...) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("Import: %w: %w", ErrBundleInternal, err)
+		}
+	}
+	return nil
+}
+
+// sandbox turns external access off for db's whole instance, bar dirs, and
+// locks the configuration so no later statement can turn it back on. These
+// are instance settings, not the connection's, and a lock cannot be lifted,
+// so they hold for the life of the store -- which needs nothing more: it
+// reads the bundle's Parquet once, here, and writes only exports, under the
+// export root. allowed_directories goes first, since DuckDB refuses to change
+// it once external access is off.
+func sandbox(ctx context.Context, conn *sql.Conn, dirs ...string) error {
+	allowed := make([]string, len(dirs))
+	for i, d := range dirs {
+		abs, err := filepath.Abs(d)
+		if err != nil {
+			return err
+		}
+		allowed[i] = literal(abs)
+	}
+	for _, stmt := range []string{
+		`set allowed_directories = [` + strings.Join(allowed, ", ") + `]`,
+		`set enable_external_access = false`,
+		`set lock_configuration = true`,
+	} {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaKinds are the objects EXPORT DATABASE writes a CREATE for, from a
+// catalog holding what the store's does.
+var schemaKinds = map[string]bool{
+	"SEQUENCE": true,
+	"TYPE":     true,
+	"TABLE":    true,
+	"MACRO":    true,
+	"INDEX":    true,
+}
+
+// schemaStatements returns the statements of schema.sql to run, refusing the
+// file if any is something other than a CREATE of one of schemaKinds.
+//
+// The bundle's macros pass the check but are left out. createSchema creates
+// this build's over every one the store's queries call, and one it does not
+// replace would still be there to be called: a macro in main shadows a
+// built-in function of the same name, so a bundle's md5 would run inside our
+// queries.
+func schemaStatements(sql string) ([]string, error) {
+	stmts, err := splitStatements(sql)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, stmt := range stmts {
+		words := strings.Fields(strings.ToUpper(stmt))
+		if len(words) > 2 && words[1] == "UNIQUE" {
+			words = append(words[:1], words[2:]...)
+		}
+		if len(words) < 2 || words[0] != "CREATE" || !schemaKinds[words[1]] {
+			return nil, fmt.Errorf("%.40q is not a statement a bundle's schema holds", stmt)
+		}
+		if words[1] == "MACRO" {
+			continue
+		}
+		out = append(out, stmt)
+	}
+	return out, nil
+}
+
+// splitStatements cuts sql at the semicolons outside quoted strings and
+// identifiers. It is only as good as its agreement with DuckDB's lexer about
+// where a statement ends, so it refuses what EXPORT DATABASE never writes and
+// the lexer reads differently: comments, dollar quoting, and prefixed
+// literals such as E'...', in which a backslash escapes the closing quote.
+func splitStatements(sql string) ([]string, error) {
+	var stmts []string
+	start := 0
+	var quote byte // ' or " while inside a quoted string or identifier
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if quote != 0 {
+			// A doubled quote closes the string and opens it again, which
+			// comes to the same thing as reading it as an escape.
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"':
+			if i > 0 && (isIdentByte(sql[i-1]) || sql[i-1] == '&') {
+				return nil, fmt.Errorf("prefixed literal at byte %d", i)
+			}
+			quote = c
+		case c == ';':
+			if stmt := strings.TrimSpace(sql[start:i]); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			start = i + 1
+		case c == '$':
+			return nil, fmt.Errorf("dollar quote or parameter at byte %d", i)
+		case strings.HasPrefix(sql[i:], "--") || strings.HasPrefix(sql[i:], "/*"):
+			return nil, fmt.Errorf("comment at byte %d", i)
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c", quote)
+	}
+	if stmt := strings.TrimSpace(sql[start:]); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// copyLine is a line of load.sql as EXPORT DATABASE writes it: the table,
+// quoted only when its name needs it, and the absolute path of its Parquet
+// file on the machine that exported it.
+var copyLine = regexp.MustCompile(`^COPY (?:([A-Za-z_][A-Za-z0-9_]*)|"([A-Za-z_][A-Za-z0-9_]*)") FROM '((?:[^']|'')*)' \(FORMAT 'parquet'\);$`)
+
+// parquetFile is the file name load.sql may point a table at.
+var parquetFile = regexp.MustCompile(`^[A-Za-z0-9_]+\.parquet$`)
+
+// loadStatements rewrites load.sql as the COPYs to run: one per line, each
+// into the table the line names, from the file of the same base name in dir.
+// Only the base name is kept from the line's path, which was the exporting
+// machine's and so means nothing here. A line of any other shape refuses the
+// file.
+func loadStatements(sql, dir string) ([]string, error) {
+	var out []string
+	for _, line := range strings.Split(sql, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		m := copyLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("%.40q is not a COPY a bundle's load holds", line)
+		}
+		table := m[1] + m[2]
+		path := strings.ReplaceAll(m[3], "''", "'")
+		file := path[strings.LastIndexAny(path, `/\`)+1:]
+		if !parquetFile.MatchString(file) {
+			return nil, fmt.Errorf("%s loads from %q, not a Parquet file in the bundle", table, path)
+		}
+		out = append(out, `copy `+ident(table)+` from `+literal(filepath.Join(dir, file))+` (format parquet)`)
+	}
+	return out, nil
+}
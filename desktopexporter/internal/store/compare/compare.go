@@ -0,0 +1,75 @@
+// Package compare sets the attributes of the rows one search matches against
+// those of the rows another matches, to say what sets the first apart: the
+// failing spans are the ones on pod-7, the slow logs all came from one
+// tenant.
+//
+// Facets count one set's values for the fields a caller names. A comparison
+// has no fields to be told: it counts every key either set carries, in the
+// dictionary ids the rows already hold, and ranks the keys by how differently
+// their values fall on the two sides.
+package compare
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/search"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+)
+
+var (
+	ErrInvalidCompareQuery  = errors.New("invalid compare query")
+	ErrCompareStoreInternal = errors.New("compare store internal error")
+)
+
+// Attributes compares the attribute values of the rows of signal -- "traces",
+// comparing spans, or "logs" -- that selection matches in [startTime,
+// endTime] with those of the rows baseline matches there and selection does
+// not. A nil baseline matches every row, making the baseline the rest of the
+// window.
+//
+// Metrics are refused, as facets refuses them: their search matches per-batch
+// ingests, and how often a batch carried an attribute says nothing about the
+// streams.
+func Attributes(ctx context.Context, db *sql.DB, signal string, startTime, endTime int64, selection, baseline any) (json.RawMessage, error) {
+	var src search.CompareSource
+	var err error
+	switch signal {
+	case "traces":
+		src, err = spans.CompareSource(selection, baseline, startTime, endTime)
+	case "logs":
+		src, err = logs.CompareSource(selection, baseline, startTime, endTime)
+	default:
+		return nil, fmt.Errorf("Attributes: signal %q: %w", signal, ErrInvalidCompareQuery)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Attributes: %w: %w", ErrInvalidCompareQuery, err)
+	}
+
+	query, err := queries.Render(queries.CompareAttributes, src)
+	if err != nil {
+		return nil, fmt.Errorf("Attributes: %w: %w", ErrCompareStoreInternal, err)
+	}
+	args := append(src.Args, search.MaxCompareValues)
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("Attributes: %w: %w", ErrCompareStoreInternal, err)
+	}
+
+	// Decoded and encoded again rather than passed through, so the answer is
+	// the wire type's shape whatever DuckDB made of the numbers.
+	var result search.AttributeComparison
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("Attributes: %w: %w", ErrCompareStoreInternal, err)
+	}
+	out, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("Attributes: %w: %w", ErrCompareStoreInternal, err)
+	}
+	return json.RawMessage(out), nil
+}
@@ -0,0 +1,224 @@
+package compare_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/compare"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/search"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// readStore runs a query under the store's read lock and returns its result.
+func readStore[T any](s *store.Store, fn func(db *sql.DB) (T, error)) (T, error) {
+	var out T
+	err := s.WithDBRead(func(db *sql.DB) error {
+		var err error
+		out, err = fn(db)
+		return err
+	})
+	return out, err
+}
+
+// seed stores eight spans of one service: four failing, three of those on
+// pod-7, and four succeeding, one on pod-7. Only the succeeding ones carry a
+// retry.count. Every span has its own request.id. And three logs, two of
+// them from tenant "acme".
+func seed(t *testing.T, s *store.Store, ctx context.Context, base int64) {
+	t.Helper()
+
+	traces := ptrace.NewTraces()
+	for i, sp := range []struct {
+		pod    string
+		failed bool
+	}{
+		{"pod-7", true}, {"pod-7", true}, {"pod-7", true}, {"pod-2", true},
+		{"pod-7", false}, {"pod-2", false}, {"pod-2", false}, {"pod-3", false},
+	} {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", "cart")
+		rs.Resource().Attributes().PutStr("k8s.pod.name", sp.pod)
+		// A resource's identity is its service triplet; without an instance
+		// id every pod would share the first pod's row.
+		rs.Resource().Attributes().PutStr("service.instance.id", sp.pod)
+		span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.SetTraceID(pcommon.TraceID{byte(i + 1)})
+		span.SetSpanID(pcommon.SpanID{byte(i + 1)})
+		span.SetName("checkout")
+		span.SetStartTimestamp(pcommon.Timestamp(base + int64(i)))
+		span.SetEndTimestamp(pcommon.Timestamp(base + int64(i) + 1000))
+		span.Attributes().PutStr("request.id", string(rune('a'+i)))
+		if sp.failed {
+			span.Status().SetCode(ptrace.StatusCodeError)
+		} else {
+			span.Attributes().PutInt("retry.count", 1)
+		}
+	}
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return spans.Ingest(ctx, conn, traces, s.FlushedIDs())
+	}))
+
+	ldata := plog.NewLogs()
+	records := ldata.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for i, tenant := range []string{"acme", "acme", "globex"} {
+		lr := records.AppendEmpty()
+		lr.SetTimestamp(pcommon.Timestamp(base + int64(i)))
+		lr.SetSeverityText("WARN")
+		if i == 0 {
+			lr.SetSeverityText("ERROR")
+		}
+		lr.Attributes().PutStr("tenant", tenant)
+	}
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return logs.Ingest(ctx, conn, ldata, s.FlushedIDs())
+	}))
+}
+
+// find returns the comparison's entry for key, failing the test without one.
+func find(t *testing.T, got search.AttributeComparison, key string) search.ComparedAttribute {
+	t.Helper()
+	for _, a := range got.Attributes {
+		if a.Key == key {
+			return a
+		}
+	}
+	require.Failf(t, "missing key", "%q not compared", key)
+	return search.ComparedAttribute{}
+}
+
+func condition(name, scope, op, value string) *search.QueryNode {
+	field := &search.FieldDefinition{Name: name, SearchScope: scope}
+	if scope == "attribute" {
+		field.AttributeScope = "resource"
+	}
+	return &search.QueryNode{
+		ID:   "q1",
+		Type: "condition",
+		Query: &search.Query{
+			Field:         field,
+			FieldOperator: op,
+			Value:         value,
+		},
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	ctx := context.Background()
+	s, err := store.NewStore(ctx, "", zap.NewNop())
+	require.NoError(t, err)
+	defer s.Close()
+
+	base := time.Now().UnixNano()
+	seed(t, s, ctx, base)
+	start, end := base-int64(time.Hour), base+int64(time.Hour)
+
+	get := func(t *testing.T, signal string, selection, baseline any) search.AttributeComparison {
+		t.Helper()
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return compare.Attributes(ctx, db, signal, start, end, selection, baseline)
+		})
+		require.NoError(t, err)
+		var out search.AttributeComparison
+		require.NoError(t, json.Unmarshal(raw, &out))
+		return out
+	}
+
+	failed := condition("statusCode", "field", "=", "Error")
+
+	t.Run("AgainstTheRest", func(t *testing.T) {
+		got := get(t, "traces", failed, nil)
+		assert.Equal(t, int64(4), got.SelectionCount)
+		assert.Equal(t, int64(4), got.BaselineCount, "the selection is not also its baseline")
+
+		// retry.count is on none of the failing spans and all the others;
+		// nothing can diverge further.
+		require.NotEmpty(t, got.Attributes)
+		assert.Equal(t, "retry.count", got.Attributes[0].Key)
+		assert.InDelta(t, 1.0, got.Attributes[0].Divergence, 1e-9)
+		var absent *search.ComparedValue
+		for i, v := range got.Attributes[0].Values {
+			if v.Value == nil {
+				absent = &got.Attributes[0].Values[i]
+			}
+		}
+		require.NotNil(t, absent, "absence is a value of its own")
+		assert.Equal(t, int64(4), absent.SelectionCount)
+		assert.Zero(t, absent.BaselineCount)
+
+		pod := find(t, got, "k8s.pod.name")
+		assert.Equal(t, "resource", pod.AttributeScope)
+		assert.InDelta(t, 0.5, pod.Divergence, 1e-9)
+		assert.Equal(t, int64(3), pod.DistinctValueCount)
+		require.NotEmpty(t, pod.Values)
+		assert.Equal(t, "pod-7", *pod.Values[0].Value)
+		assert.Equal(t, int64(3), pod.Values[0].SelectionCount)
+		assert.Equal(t, int64(1), pod.Values[0].BaselineCount)
+		assert.InDelta(t, 0.75, pod.Values[0].SelectionFrequency, 1e-9)
+		assert.InDelta(t, 0.25, pod.Values[0].BaselineFrequency, 1e-9)
+		assert.InDelta(t, 0.5, pod.Values[0].Difference, 1e-9)
+
+		// A key with a value per row shares none between the sides, and
+		// still ranks below one that tells them apart.
+		requestID := find(t, got, "request.id")
+		assert.InDelta(t, 0.25, requestID.Divergence, 1e-9)
+		assert.Len(t, requestID.Values, 8)
+
+		service := find(t, got, "service.name")
+		assert.Zero(t, service.Divergence)
+		assert.Equal(t, "service.name", got.Attributes[len(got.Attributes)-1].Key)
+	})
+
+	t.Run("AgainstABaseline", func(t *testing.T) {
+		got := get(t, "traces", failed, condition("k8s.pod.name", "attribute", "=", "pod-2"))
+		assert.Equal(t, int64(4), got.SelectionCount)
+		assert.Equal(t, int64(2), got.BaselineCount, "the failing pod-2 span is in the selection")
+	})
+
+	t.Run("EmptySide", func(t *testing.T) {
+		got := get(t, "traces", condition("statusCode", "field", "=", "Ok"), nil)
+		assert.Zero(t, got.SelectionCount)
+		assert.Equal(t, int64(8), got.BaselineCount)
+		for _, a := range got.Attributes {
+			for _, v := range a.Values {
+				assert.Zero(t, v.SelectionFrequency, a.Key)
+			}
+		}
+	})
+
+	t.Run("Logs", func(t *testing.T) {
+		got := get(t, "logs", condition("severityText", "field", "=", "ERROR"), nil)
+		assert.Equal(t, int64(1), got.SelectionCount)
+		assert.Equal(t, int64(2), got.BaselineCount)
+		tenant := find(t, got, "tenant")
+		assert.Equal(t, "log", tenant.AttributeScope)
+		assert.InDelta(t, 0.5, tenant.Divergence, 1e-9)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			signal    string
+			selection any
+		}{
+			"metrics":       {"metrics", failed},
+			"unknown field": {"traces", condition("nope", "field", "=", "x")},
+			"not a tree":    {"logs", "severity = ERROR"},
+		} {
+			_, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+				return compare.Attributes(ctx, db, tc.signal, start, end, tc.selection, nil)
+			})
+			assert.ErrorIs(t, err, compare.ErrInvalidCompareQuery, name)
+		}
+	})
+}
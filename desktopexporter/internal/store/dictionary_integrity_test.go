@@ -111,6 +111,15 @@ func integrityTraces(seed byte) ptrace.Traces {
 	sp.SetName("GET /checkout")
 	sp.Attributes().PutStr("http.method", "GET")
 	sp.Attributes().PutInt("http.status_code", 200)
+	// One of each rendering that is more than a scalar's text, so the id
+	// checks below cover the JSON and base64 encodings too.
+	hdr := sp.Attributes().PutEmptyMap("http.request.header")
+	hdr.PutEmptySlice("accept").AppendEmpty().SetStr("text/html")
+	hdr.PutStr("user-agent", "curl/8.4")
+	sp.Attributes().PutEmptyBytes("tls.session").FromRaw([]byte{0xde, 0xad, 0xbe, 0xef})
+	mixed := sp.Attributes().PutEmptySlice("retry.delays")
+	mixed.AppendEmpty().SetInt(100)
+	mixed.AppendEmpty().SetStr("jittered")
 
 	ev := sp.Events().AppendEmpty()
 	ev.SetName("exception")
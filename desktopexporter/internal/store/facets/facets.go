@@ -0,0 +1,114 @@
+// Package facets counts the values of fields and attributes over the rows a
+// search matches: which http.route values the failing spans carry, which
+// services the matching logs came from.
+//
+// Discovery already answers the neighbouring questions, and neither is this
+// one. getTraceAttributes and its siblings list the keys in a window, not
+// their values; attributes.Search lists values, but a few samples per key
+// across the whole database, unfiltered by any query and uncounted. A facet
+// is the query's own rows, grouped.
+//
+// The signal packages supply the half that is theirs -- the search, and how
+// each field is read off a row -- as a search.FacetSource, and this package
+// runs it through the one statement every signal shares.
+package facets
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/search"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+)
+
+var (
+	ErrInvalidFacetQuery   = errors.New("invalid facet query")
+	ErrFacetsStoreInternal = errors.New("facets store internal error")
+)
+
+// facetRow is one facet as facets.sql reports it, by its position in the
+// request.
+type facetRow struct {
+	Facet         int                 `json:"facet"`
+	Values        []search.FacetValue `json:"values"`
+	DistinctCount int64               `json:"distinctCount"`
+	OtherCount    int64               `json:"otherCount"`
+}
+
+type facetsRow struct {
+	Total  int64      `json:"total"`
+	Facets []facetRow `json:"facets"`
+	Nulls  []struct {
+		Facet int   `json:"facet"`
+		Count int64 `json:"count"`
+	} `json:"nulls"`
+}
+
+// Get returns the limit most frequent values of each field over the rows of
+// signal -- "traces", counting spans, or "logs" -- that criteria matches in
+// [startTime, endTime], with the rest of each field's rows split into
+// otherCount and nullCount.
+//
+// Metrics have no facets. Their search matches the per-batch ingests behind
+// a stream, and a value count over those measures how often a stream was
+// exported rather than anything about the stream.
+func Get(ctx context.Context, db *sql.DB, signal string, startTime, endTime int64, criteria any, fields []search.FieldDefinition, limit int) (json.RawMessage, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("Get: no fields: %w", ErrInvalidFacetQuery)
+	}
+
+	var src search.FacetSource
+	var err error
+	switch signal {
+	case "traces":
+		src, err = spans.FacetSource(criteria, startTime, endTime, fields, limit)
+	case "logs":
+		src, err = logs.FacetSource(criteria, startTime, endTime, fields, limit)
+	default:
+		return nil, fmt.Errorf("Get: signal %q: %w", signal, ErrInvalidFacetQuery)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Get: %w", err)
+	}
+
+	query, err := queries.Render(queries.Facets, src)
+	if err != nil {
+		return nil, fmt.Errorf("Get: %w: %w", ErrFacetsStoreInternal, err)
+	}
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, src.Args...).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("Get: %w: %w", ErrFacetsStoreInternal, err)
+	}
+	var row facetsRow
+	if err := json.Unmarshal(raw, &row); err != nil {
+		return nil, fmt.Errorf("Get: %w: %w", ErrFacetsStoreInternal, err)
+	}
+
+	// A facet with no value on any matched row has no row of its own, so
+	// every requested field starts out empty and the rows fill it in.
+	result := search.FacetResult{Total: row.Total, Facets: make([]search.Facet, len(fields))}
+	for i, f := range fields {
+		result.Facets[i] = search.Facet{Field: f, Values: []search.FacetValue{}}
+	}
+	for _, r := range row.Facets {
+		f := &result.Facets[r.Facet]
+		if r.Values != nil {
+			f.Values = r.Values
+		}
+		f.DistinctCount, f.OtherCount = r.DistinctCount, r.OtherCount
+	}
+	for _, n := range row.Nulls {
+		result.Facets[n.Facet].NullCount = n.Count
+	}
+
+	out, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("Get: %w: %w", ErrFacetsStoreInternal, err)
+	}
+	return json.RawMessage(out), nil
+}
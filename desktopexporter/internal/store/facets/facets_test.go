@@ -0,0 +1,202 @@
+package facets_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/facets"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/search"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// readStore runs a query under the store's read lock and returns its result.
+func readStore[T any](s *store.Store, fn func(db *sql.DB) (T, error)) (T, error) {
+	var out T
+	err := s.WithDBRead(func(db *sql.DB) error {
+		var err error
+		out, err = fn(db)
+		return err
+	})
+	return out, err
+}
+
+// seed stores seven spans across two services: three failing on /cart, one
+// succeeding on /cart, two on /pay, and one with no route at all -- the last
+// also carrying routes on its events. And two logs, one of them
+// without a log.file.path.
+func seed(t *testing.T, s *store.Store, ctx context.Context, base int64) {
+	t.Helper()
+
+	traces := ptrace.NewTraces()
+	for i, sp := range []struct {
+		service, route string
+		failed         bool
+	}{
+		{"cart", "/cart", true}, {"cart", "/cart", true}, {"frontend", "/cart", true},
+		{"cart", "/cart", false}, {"frontend", "/pay", false}, {"frontend", "/pay", true},
+		{"frontend", "", false},
+	} {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", sp.service)
+		span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.SetTraceID(pcommon.TraceID{byte(i + 1)})
+		span.SetSpanID(pcommon.SpanID{byte(i + 1)})
+		span.SetName("handle")
+		span.SetStartTimestamp(pcommon.Timestamp(base + int64(i)))
+		span.SetEndTimestamp(pcommon.Timestamp(base + int64(i) + 1000))
+		if sp.route != "" {
+			span.Attributes().PutStr("http.route", sp.route)
+		} else {
+			// /login on two events, to be counted once for the span, and
+			// /logout on a third, to be counted for it as well.
+			for _, route := range []string{"/login", "/login", "/logout"} {
+				ev := span.Events().AppendEmpty()
+				ev.SetName("redirect")
+				ev.Attributes().PutStr("http.route", route)
+			}
+		}
+		if sp.failed {
+			span.Status().SetCode(ptrace.StatusCodeError)
+		}
+	}
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return spans.Ingest(ctx, conn, traces, s.FlushedIDs())
+	}))
+
+	ldata := plog.NewLogs()
+	records := ldata.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for i, path := range []string{"/var/log/app.log", ""} {
+		lr := records.AppendEmpty()
+		lr.SetTimestamp(pcommon.Timestamp(base + int64(i)))
+		lr.SetSeverityText("WARN")
+		if path != "" {
+			lr.Attributes().PutStr("log.file.path", path)
+		}
+	}
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return logs.Ingest(ctx, conn, ldata, s.FlushedIDs())
+	}))
+}
+
+func TestGet(t *testing.T) {
+	ctx := context.Background()
+	s, err := store.NewStore(ctx, "", zap.NewNop())
+	require.NoError(t, err)
+	defer s.Close()
+
+	base := time.Now().UnixNano()
+	seed(t, s, ctx, base)
+	start, end := base-int64(time.Hour), base+int64(time.Hour)
+
+	get := func(t *testing.T, signal string, criteria any, fields []search.FieldDefinition, limit int) search.FacetResult {
+		t.Helper()
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return facets.Get(ctx, db, signal, start, end, criteria, fields, limit)
+		})
+		require.NoError(t, err)
+		var out search.FacetResult
+		require.NoError(t, json.Unmarshal(raw, &out))
+		return out
+	}
+
+	route := search.FieldDefinition{Name: "http.route", SearchScope: "attribute", AttributeScope: "span", Type: "string"}
+	service := search.FieldDefinition{Name: "service.name", SearchScope: "attribute", AttributeScope: "resource", Type: "string"}
+	status := search.FieldDefinition{Name: "statusCode", SearchScope: "field"}
+
+	t.Run("Counts", func(t *testing.T) {
+		got := get(t, "traces", nil, []search.FieldDefinition{route, service, status}, 10)
+		assert.Equal(t, int64(7), got.Total)
+		require.Len(t, got.Facets, 3)
+
+		assert.Equal(t, route, got.Facets[0].Field)
+		assert.Equal(t, []search.FacetValue{{Value: "/cart", Count: 4}, {Value: "/pay", Count: 2}}, got.Facets[0].Values)
+		assert.Equal(t, int64(2), got.Facets[0].DistinctCount)
+		assert.Equal(t, int64(1), got.Facets[0].NullCount, "the span without a route")
+
+		assert.Equal(t, []search.FacetValue{{Value: "frontend", Count: 4}, {Value: "cart", Count: 3}}, got.Facets[1].Values)
+		assert.Equal(t, []search.FacetValue{{Value: "Error", Count: 4}, {Value: "Unset", Count: 3}}, got.Facets[2].Values)
+	})
+
+	t.Run("LimitAndOther", func(t *testing.T) {
+		got := get(t, "traces", nil, []search.FieldDefinition{route}, 1)
+		f := got.Facets[0]
+		assert.Equal(t, []search.FacetValue{{Value: "/cart", Count: 4}}, f.Values)
+		assert.Equal(t, int64(2), f.DistinctCount)
+		assert.Equal(t, int64(2), f.OtherCount)
+		assert.Equal(t, int64(1), f.NullCount)
+	})
+
+	t.Run("UnderQuery", func(t *testing.T) {
+		failed := &search.QueryNode{
+			ID:   "q1",
+			Type: "condition",
+			Query: &search.Query{
+				Field:         &status,
+				FieldOperator: "=",
+				Value:         "Error",
+			},
+		}
+		got := get(t, "traces", failed, []search.FieldDefinition{route}, 10)
+		assert.Equal(t, int64(4), got.Total)
+		assert.Equal(t, []search.FacetValue{{Value: "/cart", Count: 3}, {Value: "/pay", Count: 1}}, got.Facets[0].Values)
+		assert.Zero(t, got.Facets[0].NullCount)
+	})
+
+	t.Run("EventAttributeCountsSpans", func(t *testing.T) {
+		eventRoute := route
+		eventRoute.AttributeScope = "event"
+		got := get(t, "traces", nil, []search.FieldDefinition{eventRoute}, 10)
+		assert.Equal(t, []search.FacetValue{{Value: "/login", Count: 1}, {Value: "/logout", Count: 1}}, got.Facets[0].Values)
+		assert.Equal(t, int64(6), got.Facets[0].NullCount)
+		// One span, two values: an event facet's counts can outrun Total.
+		assert.Equal(t, int64(7), got.Total)
+	})
+
+	t.Run("NoValues", func(t *testing.T) {
+		missing := search.FieldDefinition{Name: "no.such.key", SearchScope: "attribute", AttributeScope: "span"}
+		got := get(t, "traces", nil, []search.FieldDefinition{missing}, 10)
+		assert.Empty(t, got.Facets[0].Values)
+		assert.NotNil(t, got.Facets[0].Values)
+		assert.Equal(t, int64(7), got.Facets[0].NullCount)
+	})
+
+	t.Run("Logs", func(t *testing.T) {
+		path := search.FieldDefinition{Name: "log.file.path", SearchScope: "attribute", AttributeScope: "log", Type: "string"}
+		severity := search.FieldDefinition{Name: "severityText", SearchScope: "field"}
+		got := get(t, "logs", nil, []search.FieldDefinition{path, severity}, 10)
+		assert.Equal(t, int64(2), got.Total)
+		assert.Equal(t, []search.FacetValue{{Value: "/var/log/app.log", Count: 1}}, got.Facets[0].Values)
+		assert.Equal(t, int64(1), got.Facets[0].NullCount)
+		assert.Equal(t, []search.FacetValue{{Value: "WARN", Count: 2}}, got.Facets[1].Values)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			signal string
+			field  search.FieldDefinition
+		}{
+			"metrics":            {"metrics", status},
+			"event field":        {"traces", search.FieldDefinition{Name: "event.name", SearchScope: "field"}},
+			"global scope":       {"traces", search.FieldDefinition{SearchScope: "global"}},
+			"unknown log scope":  {"logs", route},
+			"unknown span field": {"traces", search.FieldDefinition{Name: "nope", SearchScope: "field"}},
+		} {
+			_, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+				return facets.Get(ctx, db, tc.signal, start, end, nil, []search.FieldDefinition{tc.field}, 10)
+			})
+			assert.Error(t, err, name)
+		}
+	})
+}
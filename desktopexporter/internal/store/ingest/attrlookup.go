@@ -0,0 +1,47 @@
+package ingest
+
+import (
+	"fmt"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/search"
+)
+
+// AttrLookup is the slow-path counterpart to IDProbe: the bound parameters for
+// resolving an attribute field's value, in the two shapes the signal mappers
+// embed it.
+//
+// It exists so the three mappers do not each grow a second copy of every
+// branch for path predicates. A plain field reads a.value and attr_value; a
+// field with a Path reads the leaf through attr_json_path and attr_path, and
+// nothing else about the predicate changes -- the hoisting into resources and
+// scopes, the EXISTS over events and links, all stay as they are.
+type AttrLookup struct {
+	// Key is the search_params column holding the attribute key.
+	Key string
+	// Value reads the value (or the leaf the path names) off a dictionary row
+	// joined as "a".
+	Value string
+	path  string
+}
+
+// BindAttrLookup appends the key -- and the JSON pointer, for a path
+// predicate -- to params.
+func BindAttrLookup(field *search.FieldDefinition, params *[]search.NamedParam) AttrLookup {
+	l := AttrLookup{Key: fmt.Sprintf("attr_key_%d", len(*params)), Value: "a.value"}
+	*params = append(*params, search.NamedParam{Name: l.Key, Value: field.Name})
+	if len(field.Path) > 0 {
+		l.path = fmt.Sprintf("attr_path_%d", len(*params))
+		*params = append(*params, search.NamedParam{Name: l.path, Value: search.JSONPointer(field.Path)})
+		l.Value = fmt.Sprintf("attr_json_path(a.value, a.type, %s)", l.path)
+	}
+	return l
+}
+
+// In resolves the value against an attribute id array: attr_value, or
+// attr_path for a path predicate.
+func (l AttrLookup) In(ids string) string {
+	if l.path != "" {
+		return fmt.Sprintf("attr_path(%s, %s, %s)", ids, l.Key, l.path)
+	}
+	return fmt.Sprintf("attr_value(%s, %s)", ids, l.Key)
+}
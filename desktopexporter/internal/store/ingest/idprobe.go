@@ -40,6 +40,8 @@ import (
 //     a membership test.
 //   - Any type token the schema enum does not contain, which can only mean the
 //     caller and the store disagree about what types exist.
+//   - A path predicate. The id covers the whole map, and the search names one
+//     leaf inside it.
 //
 // # Why it trusts the declared type
 //
@@ -79,7 +81,7 @@ func IDProbe(arrayExpr string, field *search.FieldDefinition, query *search.Quer
 	if query == nil || field == nil {
 		return ""
 	}
-	if query.FieldOperator != "=" || query.Value == "NULL" {
+	if query.FieldOperator != "=" || query.Value == "NULL" || len(field.Path) > 0 {
 		return ""
 	}
 	if !slices.Contains(AttrTypes, field.Type) {
@@ -97,4 +99,5 @@ func IDProbe(arrayExpr string, field *search.FieldDefinition, query *search.Quer
 var AttrTypes = []string{
 	"string", "int64", "float64", "bool",
 	"string[]", "int64[]", "float64[]", "boolean[]",
+	"bytes", "map", "any[]",
 }
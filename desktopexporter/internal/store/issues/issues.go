@@ -0,0 +1,129 @@
+// Package issues groups the exceptions the store holds into issues: one per
+// distinct failure, however many times and through whichever signal it was
+// reported.
+//
+// An exception arrives either as a span event named "exception" or as a log
+// record carrying exception.* attributes, and the two are the same thing as
+// far as anyone triaging is concerned, so both are read and pooled. What makes
+// two exceptions one issue is their fingerprint: the exception type, the top
+// stack frame with its line number stripped, and the message with its
+// variable parts -- ids, numbers, quoted values -- replaced by placeholders.
+// All three are computed in SQL by the exception_* macros, so an issue is a
+// GROUP BY and nothing is persisted: a new deploy that changes a message's
+// wording starts a new issue, and retention that drops the last occurrence
+// ends one, with no table to keep in step.
+package issues
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+)
+
+var (
+	ErrInvalidIssueQuery   = errors.New("invalid issue search query")
+	ErrIssuesStoreInternal = errors.New("issues store internal error")
+	ErrIssueNotFound       = errors.New("issue not found")
+)
+
+// maxOccurrences caps how many occurrences Get returns. The count it reports
+// is the whole issue's; the list is for opening a few, newest first, and an
+// exception thrown in a loop would otherwise return every one of them.
+const maxOccurrences = 100
+
+// occurrencesData fills occurrences.sql. A signal whose CTE is empty is left
+// out of the statement.
+type occurrencesData struct {
+	SpanCTE, SpanWhere string
+	LogCTE, LogWhere   string
+}
+
+// issuesData fills list_issues.sql and get_issue.sql.
+type issuesData struct {
+	Occurrences string
+}
+
+// List returns the exceptions in [startTime, endTime] grouped into issues,
+// most occurrences first, each with its first and last sighting, the services
+// it was seen in, and a few of the traces and logs it was reported by.
+//
+// criteria is a query tree in searchTraces' and searchLogs' shape, and is
+// applied to each signal with that signal's field vocabulary. A tree one
+// signal cannot express -- a span field, say, which logs do not have -- drops
+// that signal rather than failing the call, so a query aimed at one signal
+// narrows the issues to that signal; it fails only if neither can take it.
+func List(ctx context.Context, db *sql.DB, startTime, endTime int64, criteria any) (json.RawMessage, error) {
+	occurrences, args, err := renderOccurrences(startTime, endTime, criteria)
+	if err != nil {
+		return nil, fmt.Errorf("List: %w", err)
+	}
+	query, err := queries.Render(queries.ListIssues, issuesData{Occurrences: occurrences})
+	if err != nil {
+		return nil, fmt.Errorf("List: %w: %w", ErrIssuesStoreInternal, err)
+	}
+
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("List: %w: %w", ErrIssuesStoreInternal, err)
+	}
+	return json.RawMessage(raw), nil
+}
+
+// Get returns one issue by fingerprint, with its occurrences newest first
+// across the whole store -- a fingerprint is stable, so the issue a list
+// showed is found whatever window the list was drawn from.
+func Get(ctx context.Context, db *sql.DB, fingerprint string) (json.RawMessage, error) {
+	occurrences, args, err := renderOccurrences(math.MinInt64, math.MaxInt64, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Get: %w", err)
+	}
+	query, err := queries.Render(queries.GetIssue, issuesData{Occurrences: occurrences})
+	if err != nil {
+		return nil, fmt.Errorf("Get: %w: %w", ErrIssuesStoreInternal, err)
+	}
+
+	var raw []byte
+	args = append(args, fingerprint, maxOccurrences)
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&raw); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("Get: %w", ErrIssueNotFound)
+		}
+		return nil, fmt.Errorf("Get: %w: %w", ErrIssuesStoreInternal, err)
+	}
+	return json.RawMessage(raw), nil
+}
+
+// renderOccurrences renders occurrences.sql for a window and query tree, and
+// returns the arguments it binds: the span side's, then the log side's, in
+// the order the two CTEs appear.
+func renderOccurrences(startTime, endTime int64, criteria any) (string, []any, error) {
+	var data occurrencesData
+	var args []any
+
+	spanCTE, spanWhere, spanArgs, spanErr := spans.SearchPredicate(criteria, startTime, endTime, "span_params")
+	if spanErr == nil {
+		data.SpanCTE, data.SpanWhere = spanCTE, spanWhere
+		args = append(args, spanArgs...)
+	}
+	logCTE, logWhere, logArgs, logErr := logs.SearchPredicate(criteria, startTime, endTime, "log_params")
+	if logErr == nil {
+		data.LogCTE, data.LogWhere = logCTE, logWhere
+		args = append(args, logArgs...)
+	}
+	if spanErr != nil && logErr != nil {
+		return "", nil, fmt.Errorf("%w: %w", ErrInvalidIssueQuery, errors.Join(spanErr, logErr))
+	}
+
+	occurrences, err := queries.Render(queries.IssueOccurrences, data)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %w", ErrIssuesStoreInternal, err)
+	}
+	return occurrences, args, nil
+}
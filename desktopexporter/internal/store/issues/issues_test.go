@@ -0,0 +1,304 @@
+package issues_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/issues"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/search"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// readStore runs a query under the store's read lock and returns its result.
+func readStore[T any](s *store.Store, fn func(db *sql.DB) (T, error)) (T, error) {
+	var out T
+	err := s.WithDBRead(func(db *sql.DB) error {
+		var err error
+		out, err = fn(db)
+		return err
+	})
+	return out, err
+}
+
+type issueJSON struct {
+	Fingerprint     string   `json:"fingerprint"`
+	Type            *string  `json:"type"`
+	MessageTemplate string   `json:"messageTemplate"`
+	TopFrame        *string  `json:"topFrame"`
+	LastMessage     *string  `json:"lastMessage"`
+	Count           int      `json:"count"`
+	SpanEventCount  int      `json:"spanEventCount"`
+	LogCount        int      `json:"logCount"`
+	FirstSeen       int64    `json:"firstSeen,string"`
+	LastSeen        int64    `json:"lastSeen,string"`
+	Services        []string `json:"services"`
+	ExampleTraceIDs []string `json:"exampleTraceIDs"`
+	ExampleLogIDs   []string `json:"exampleLogIDs"`
+}
+
+type occurrenceJSON struct {
+	Source      string  `json:"source"`
+	Timestamp   int64   `json:"timestamp,string"`
+	TraceID     *string `json:"traceID"`
+	SpanID      *string `json:"spanID"`
+	LogID       *string `json:"logID"`
+	ServiceName *string `json:"serviceName"`
+	Message     *string `json:"message"`
+}
+
+type issueDetailJSON struct {
+	issueJSON
+	Stacktrace  *string          `json:"stacktrace"`
+	Occurrences []occurrenceJSON `json:"occurrences"`
+}
+
+const (
+	traceA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	traceB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	traceC = "cccccccccccccccccccccccccccccccc"
+)
+
+// goStack is a Go panic trace whose top frame sits at line, as the same code
+// would report it from two builds a line apart.
+func goStack(line string) string {
+	return "goroutine 7 [running]:\n" +
+		"main.(*Cart).Checkout(0xc000010000)\n" +
+		"\t/src/cart.go:" + line + " +0x1d\n" +
+		"main.main()\n" +
+		"\t/src/main.go:12 +0x25\n"
+}
+
+// seed stores three exceptions of one kind -- two as span events in
+// different services and traces, one as a log -- whose messages and line
+// numbers differ only in what the fingerprint ignores, plus one unrelated
+// exception and a span event that is not an exception at all.
+func seed(t *testing.T, s *store.Store, ctx context.Context, base int64) {
+	t.Helper()
+
+	traces := ptrace.NewTraces()
+	addSpan := func(service, traceID string, spanID byte, at int64, events func(ptrace.SpanEventSlice)) {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+		span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		var tid pcommon.TraceID
+		_, err := hex.Decode(tid[:], []byte(traceID))
+		require.NoError(t, err)
+		span.SetTraceID(tid)
+		span.SetSpanID(pcommon.SpanID{spanID, spanID, spanID, spanID, spanID, spanID, spanID, spanID})
+		span.SetName("checkout")
+		span.SetStartTimestamp(pcommon.Timestamp(at))
+		span.SetEndTimestamp(pcommon.Timestamp(at + int64(time.Millisecond)))
+		events(span.Events())
+	}
+	exception := func(at int64, exType, message, stack string) func(ptrace.SpanEventSlice) {
+		return func(evs ptrace.SpanEventSlice) {
+			ev := evs.AppendEmpty()
+			ev.SetName("exception")
+			ev.SetTimestamp(pcommon.Timestamp(at))
+			ev.Attributes().PutStr("exception.type", exType)
+			ev.Attributes().PutStr("exception.message", message)
+			if stack != "" {
+				ev.Attributes().PutStr("exception.stacktrace", stack)
+			}
+		}
+	}
+
+	addSpan("cart", traceA, 1, base, exception(base+10, "*errors.errorString",
+		`order 1234 not found for user "alice"`, goStack("88")))
+	addSpan("frontend", traceB, 2, base+100, exception(base+110, "*errors.errorString",
+		`order 98 not found for user "bob"`, goStack("91")))
+	addSpan("cart", traceC, 3, base+200, func(evs ptrace.SpanEventSlice) {
+		exception(base+210, "ValueError", "bad quantity", "")(evs)
+		ev := evs.AppendEmpty()
+		ev.SetName("cache miss")
+		ev.SetTimestamp(pcommon.Timestamp(base + 220))
+	})
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return spans.Ingest(ctx, conn, traces, s.FlushedIDs())
+	}))
+
+	ldata := plog.NewLogs()
+	rl := ldata.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "worker")
+	records := rl.ScopeLogs().AppendEmpty().LogRecords()
+	lr := records.AppendEmpty()
+	lr.SetTimestamp(pcommon.Timestamp(base + 300))
+	lr.SetSeverityText("ERROR")
+	lr.Body().SetStr("checkout failed")
+	lr.Attributes().PutStr("exception.type", "*errors.errorString")
+	lr.Attributes().PutStr("exception.message", `order 7 not found for user "carol"`)
+	lr.Attributes().PutStr("exception.stacktrace", goStack("90"))
+	plain := records.AppendEmpty()
+	plain.SetTimestamp(pcommon.Timestamp(base + 310))
+	plain.Body().SetStr("an ordinary log")
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return logs.Ingest(ctx, conn, ldata, s.FlushedIDs())
+	}))
+}
+
+func listIssues(t *testing.T, s *store.Store, ctx context.Context, start, end int64, criteria any) []issueJSON {
+	t.Helper()
+	raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+		return issues.List(ctx, db, start, end, criteria)
+	})
+	require.NoError(t, err)
+	var out []issueJSON
+	require.NoError(t, json.Unmarshal(raw, &out))
+	return out
+}
+
+func TestIssues(t *testing.T) {
+	ctx := context.Background()
+	s, err := store.NewStore(ctx, "", zap.NewNop())
+	require.NoError(t, err)
+	defer s.Close()
+
+	base := time.Now().UnixNano()
+	seed(t, s, ctx, base)
+
+	t.Run("GroupsAcrossSignals", func(t *testing.T) {
+		got := listIssues(t, s, ctx, base-int64(time.Hour), base+int64(time.Hour), nil)
+		require.Len(t, got, 2)
+
+		top := got[0]
+		assert.Len(t, top.Fingerprint, 16)
+		assert.Equal(t, "*errors.errorString", *top.Type)
+		assert.Equal(t, `order <n> not found for user "<str>"`, top.MessageTemplate)
+		assert.Equal(t, "main.(*Cart).Checkout", *top.TopFrame)
+		assert.Equal(t, `order 7 not found for user "carol"`, *top.LastMessage)
+		assert.Equal(t, 3, top.Count)
+		assert.Equal(t, 2, top.SpanEventCount)
+		assert.Equal(t, 1, top.LogCount)
+		assert.Equal(t, base+10, top.FirstSeen)
+		assert.Equal(t, base+300, top.LastSeen)
+		assert.Equal(t, []string{"cart", "frontend", "worker"}, top.Services)
+		assert.Equal(t, []string{traceB, traceA}, top.ExampleTraceIDs)
+		assert.Len(t, top.ExampleLogIDs, 1)
+
+		other := got[1]
+		assert.Equal(t, "ValueError", *other.Type)
+		assert.Equal(t, "bad quantity", other.MessageTemplate)
+		assert.Nil(t, other.TopFrame)
+		assert.Equal(t, 1, other.Count)
+		assert.Equal(t, []string{traceC}, other.ExampleTraceIDs)
+		assert.Empty(t, other.ExampleLogIDs)
+	})
+
+	t.Run("Window", func(t *testing.T) {
+		got := listIssues(t, s, ctx, base+250, base+int64(time.Hour), nil)
+		require.Len(t, got, 1)
+		assert.Equal(t, 1, got[0].Count)
+		assert.Equal(t, 1, got[0].LogCount)
+
+		assert.Empty(t, listIssues(t, s, ctx, base-2*int64(time.Hour), base-int64(time.Hour), nil))
+	})
+
+	t.Run("QueryAppliesToBothSignals", func(t *testing.T) {
+		service := func(name string) search.QueryNode {
+			return search.QueryNode{
+				ID:   name,
+				Type: "condition",
+				Query: &search.Query{
+					Field: &search.FieldDefinition{
+						Name: "service.name", SearchScope: "attribute", AttributeScope: "resource", Type: "string",
+					},
+					FieldOperator: "=",
+					Value:         name,
+				},
+			}
+		}
+		query := &search.QueryNode{
+			ID:   "q1",
+			Type: "group",
+			Group: &search.QueryGroup{
+				LogicalOperator: "OR",
+				Children:        []search.QueryNode{service("frontend"), service("worker")},
+			},
+		}
+		got := listIssues(t, s, ctx, base-int64(time.Hour), base+int64(time.Hour), query)
+		require.Len(t, got, 1)
+		assert.Equal(t, 2, got[0].Count)
+		assert.Equal(t, []string{"frontend", "worker"}, got[0].Services)
+	})
+
+	t.Run("QueryOneSignalCannotTakeDropsIt", func(t *testing.T) {
+		query := &search.QueryNode{
+			ID:   "q1",
+			Type: "condition",
+			Query: &search.Query{
+				Field:         &search.FieldDefinition{Name: "severityText", SearchScope: "field", Type: "string"},
+				FieldOperator: "=",
+				Value:         "ERROR",
+			},
+		}
+		got := listIssues(t, s, ctx, base-int64(time.Hour), base+int64(time.Hour), query)
+		require.Len(t, got, 1)
+		assert.Equal(t, 1, got[0].Count)
+		assert.Equal(t, 0, got[0].SpanEventCount)
+	})
+
+	t.Run("QueryNeitherSignalCanTake", func(t *testing.T) {
+		query := &search.QueryNode{
+			ID:   "q1",
+			Type: "condition",
+			Query: &search.Query{
+				Field:         &search.FieldDefinition{Name: "noSuchField", SearchScope: "field", Type: "string"},
+				FieldOperator: "=",
+				Value:         "x",
+			},
+		}
+		_, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return issues.List(ctx, db, base-int64(time.Hour), base+int64(time.Hour), query)
+		})
+		assert.ErrorIs(t, err, issues.ErrInvalidIssueQuery)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		top := listIssues(t, s, ctx, base-int64(time.Hour), base+int64(time.Hour), nil)[0]
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return issues.Get(ctx, db, top.Fingerprint)
+		})
+		require.NoError(t, err)
+		var got issueDetailJSON
+		require.NoError(t, json.Unmarshal(raw, &got))
+
+		assert.Equal(t, top.Fingerprint, got.Fingerprint)
+		assert.Equal(t, 3, got.Count)
+		assert.Equal(t, goStack("90"), *got.Stacktrace)
+		require.Len(t, got.Occurrences, 3)
+
+		log, second, first := got.Occurrences[0], got.Occurrences[1], got.Occurrences[2]
+		assert.Equal(t, "log", log.Source)
+		assert.Equal(t, base+300, log.Timestamp)
+		assert.Equal(t, top.ExampleLogIDs[0], *log.LogID)
+		assert.Nil(t, log.TraceID)
+		assert.Equal(t, "worker", *log.ServiceName)
+
+		assert.Equal(t, "span", second.Source)
+		assert.Equal(t, traceB, *second.TraceID)
+		assert.Equal(t, "0202020202020202", *second.SpanID)
+		assert.Nil(t, second.LogID)
+		assert.Equal(t, `order 98 not found for user "bob"`, *second.Message)
+		assert.Equal(t, traceA, *first.TraceID)
+	})
+
+	t.Run("GetNotFound", func(t *testing.T) {
+		_, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return issues.Get(ctx, db, "0000000000000000")
+		})
+		assert.ErrorIs(t, err, issues.ErrIssueNotFound)
+	})
+}
@@ -0,0 +1,161 @@
+// Package live carries "these rows just landed" from ingest to whoever is
+// tailing the store.
+//
+// It moves keys, not records. Ingest publishes the ids of the rows a batch
+// committed; a subscriber reads them back through the signal package's Tail
+// query, which is where the caller's query tree is applied. That keeps one
+// definition of what a search matches -- the SQL search.BuildConditions
+// writes -- instead of a second, Go-side evaluator that would drift from it.
+//
+// Publish never blocks. Ingest holds the store's write lock, and a tail client
+// on a slow link must not be able to stall it, so each subscription has its own
+// bounded buffer: keys past the bound are counted rather than kept, and the
+// subscriber is told how many it missed.
+package live
+
+import "sync"
+
+// Signal names what a subscription tails.
+type Signal string
+
+const (
+	Logs  Signal = "logs"
+	Spans Signal = "spans"
+)
+
+// ParseSignal returns the Signal s names, or false for anything else.
+func ParseSignal(s string) (Signal, bool) {
+	switch Signal(s) {
+	case Logs, Spans:
+		return Signal(s), true
+	}
+	return "", false
+}
+
+// DefaultBufferLimit is how many unread keys a subscription holds before it
+// starts dropping. It also bounds the id list one Tail query binds, so it is
+// sized for the query as much as for memory: a few thousand uuids is a
+// parameter DuckDB joins in milliseconds, and more than a person can read
+// scrolling past anyway.
+const DefaultBufferLimit = 5000
+
+// Hub fans published keys out to subscriptions. The zero value is not usable;
+// call NewHub.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[*Subscription]struct{}{}}
+}
+
+// Subscribe starts buffering keys published for signal from now on. limit is
+// the buffer bound; zero or less means DefaultBufferLimit. The caller must
+// Close the subscription.
+func (h *Hub) Subscribe(signal Signal, limit int) *Subscription {
+	if limit <= 0 {
+		limit = DefaultBufferLimit
+	}
+	sub := &Subscription{
+		hub:    h,
+		signal: signal,
+		limit:  limit,
+		ready:  make(chan struct{}, 1),
+	}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// Watching reports whether anything subscribes to signal. Ingest asks before
+// collecting keys, so that with no tail open a batch pays nothing for the
+// feature.
+func (h *Hub) Watching(signal Signal) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if sub.signal == signal {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish hands keys to every subscription for signal. Call it after the rows
+// are committed: a subscriber may query for them the moment it wakes.
+func (h *Hub) Publish(signal Signal, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if sub.signal == signal {
+			sub.offer(keys)
+		}
+	}
+}
+
+// Subscription is one tail's buffer.
+type Subscription struct {
+	hub    *Hub
+	signal Signal
+	limit  int
+
+	mu      sync.Mutex
+	pending []string
+	dropped int
+
+	// ready has room for one wake-up. Publish does a non-blocking send, so any
+	// number of batches landing while the subscriber is busy collapse into a
+	// single wake, and the subscriber takes everything buffered at once.
+	ready chan struct{}
+}
+
+func (s *Subscription) offer(keys []string) {
+	s.mu.Lock()
+	room := s.limit - len(s.pending)
+	if room < 0 {
+		room = 0
+	}
+	if len(keys) > room {
+		s.dropped += len(keys) - room
+		keys = keys[:room]
+	}
+	s.pending = append(s.pending, keys...)
+	s.mu.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Ready receives when Take has something to return.
+func (s *Subscription) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Take empties the buffer, returning the keys in publish order and how many
+// were dropped since the last Take because the buffer was full.
+//
+// Dropping keeps the oldest keys and counts the newest. The alternative --
+// keeping the newest -- reads better on a screen, but it would show a client
+// records out of order across the gap, and a count of what was skipped is the
+// honest thing to show either way.
+func (s *Subscription) Take() (keys []string, dropped int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys, dropped = s.pending, s.dropped
+	s.pending, s.dropped = nil, 0
+	return keys, dropped
+}
+
+// Close stops the subscription. Keys published afterwards are not buffered.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	delete(s.hub.subs, s)
+	s.hub.mu.Unlock()
+}
@@ -0,0 +1,71 @@
+package live
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishReachesOnlyItsSignal(t *testing.T) {
+	hub := NewHub()
+	logs := hub.Subscribe(Logs, 0)
+	defer logs.Close()
+	spans := hub.Subscribe(Spans, 0)
+	defer spans.Close()
+
+	hub.Publish(Logs, []string{"a", "b"})
+	hub.Publish(Logs, []string{"c"})
+
+	select {
+	case <-logs.Ready():
+	default:
+		t.Fatal("a publish must wake the subscriber")
+	}
+	keys, dropped := logs.Take()
+	assert.Equal(t, []string{"a", "b", "c"}, keys, "two batches, one wake, publish order")
+	assert.Zero(t, dropped)
+
+	select {
+	case <-spans.Ready():
+		t.Fatal("a logs publish must not wake a spans tail")
+	default:
+	}
+	keys, _ = spans.Take()
+	assert.Empty(t, keys)
+}
+
+// A subscriber that stops reading costs the publisher nothing past its bound:
+// Publish returns at once, and the overflow is a count rather than memory.
+func TestFullBufferCountsInsteadOfGrowing(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(Logs, 3)
+	defer sub.Close()
+
+	hub.Publish(Logs, []string{"a", "b"})
+	hub.Publish(Logs, []string{"c", "d", "e"})
+	hub.Publish(Logs, []string{"f"})
+
+	keys, dropped := sub.Take()
+	assert.Equal(t, []string{"a", "b", "c"}, keys, "the oldest are kept")
+	assert.Equal(t, 3, dropped)
+
+	hub.Publish(Logs, []string{"g"})
+	keys, dropped = sub.Take()
+	assert.Equal(t, []string{"g"}, keys, "Take makes room again")
+	assert.Zero(t, dropped, "and resets the count")
+}
+
+func TestWatching(t *testing.T) {
+	hub := NewHub()
+	assert.False(t, hub.Watching(Logs))
+
+	sub := hub.Subscribe(Logs, 0)
+	assert.True(t, hub.Watching(Logs))
+	assert.False(t, hub.Watching(Spans))
+
+	sub.Close()
+	assert.False(t, hub.Watching(Logs))
+	hub.Publish(Logs, []string{"a"})
+	keys, _ := sub.Take()
+	assert.Empty(t, keys, "nothing is buffered after Close")
+}
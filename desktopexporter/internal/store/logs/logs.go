@@ -7,7 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
+	"math"
 
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/ingest"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
@@ -65,7 +65,24 @@ const flushIntervalLogs = 500
 // Two passes, matching spans.Ingest: hash and resolve resource/scope
 // identities, flush the dictionary, then append the log rows with the id
 // arrays. See spans.Ingest for why the dictionary cannot ride the appender.
-func Ingest(ctx context.Context, conn driver.Conn, logs plog.Logs, flushed *ingest.FlushedIDs) (err error) {
+func Ingest(ctx context.Context, conn driver.Conn, logs plog.Logs, flushed *ingest.FlushedIDs) error {
+	return ingestLogs(ctx, conn, logs, flushed, nil)
+}
+
+// IngestReturning is Ingest, also returning the ids it minted for the new
+// rows, in append order. The ids exist nowhere in the pdata -- OTLP logs are
+// anonymous -- so this is the only way a caller can name what it just wrote,
+// which is what a live tail needs. The keys cost a string per record, so
+// callers with no use for them should call Ingest.
+func IngestReturning(ctx context.Context, conn driver.Conn, logs plog.Logs, flushed *ingest.FlushedIDs) ([]string, error) {
+	keys := make([]string, 0, logs.LogRecordCount())
+	if err := ingestLogs(ctx, conn, logs, flushed, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func ingestLogs(ctx context.Context, conn driver.Conn, logs plog.Logs, flushed *ingest.FlushedIDs, keys *[]string) (err error) {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
@@ -142,8 +159,9 @@ func Ingest(ctx context.Context, conn driver.Conn, logs plog.Logs, flushed *inge
 				logAttrIDs := logAttrs[logCur]
 				logCur++
 
+				logID := uuid.New()
 				err := appenders["logs"].AppendRow(
-					duckdb.UUID(uuid.New()),        // ID UUID
+					duckdb.UUID(logID),             // ID UUID
 					int64(log.Timestamp()),         // Timestamp BIGINT
 					int64(log.ObservedTimestamp()), // ObservedTimestamp BIGINT
 					traceUUID,                      // TraceID UUID
@@ -165,6 +183,9 @@ func Ingest(ctx context.Context, conn driver.Conn, logs plog.Logs, flushed *inge
 				if err != nil {
 					return fmt.Errorf("Ingest: %w: %w", ErrLogsStoreInternal, err)
 				}
+				if keys != nil {
+					*keys = append(*keys, logID.String())
+				}
 
 				logCount++
 				if logCount%flushIntervalLogs == 0 {
@@ -198,34 +219,102 @@ func Ingest(ctx context.Context, conn driver.Conn, logs plog.Logs, flushed *inge
 //
 // `bodyPreview` is server-truncated by the body_preview macro.
 func Search(ctx context.Context, db *sql.DB, startTime, endTime int64, criteria any) (json.RawMessage, error) {
+	raw, _, _, err := searchLogs(ctx, db, startTime, endTime, criteria, search.Page{})
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return json.RawMessage("[]"), nil
+	}
+	return json.RawMessage(raw), nil
+}
+
+// SearchPage is Search one page at a time, newest first, wrapped in a
+// search.PageResult. Ties on timestamp break on the log's id.
+func SearchPage(ctx context.Context, db *sql.DB, startTime, endTime int64, criteria any, page search.Page) (json.RawMessage, error) {
+	raw, nextKey, nextID, err := searchLogs(ctx, db, startTime, endTime, criteria, page)
+	if err != nil {
+		return nil, err
+	}
+	out, err := search.PageFromRow(raw, nextKey, nextID)
+	if err != nil {
+		return nil, fmt.Errorf("SearchPage: %w: %w", ErrLogsStoreInternal, err)
+	}
+	return out, nil
+}
+
+func searchLogs(ctx context.Context, db *sql.DB, startTime, endTime int64, criteria any, page search.Page) ([]byte, *int64, *string, error) {
 	var searchTree *search.QueryNode
 	if criteria != nil {
 		var err error
 		searchTree, err = search.ParseQueryTree(criteria)
 		if err != nil {
-			return nil, fmt.Errorf("Search: %w: %w", ErrInvalidLogQuery, err)
+			return nil, nil, nil, fmt.Errorf("Search: %w: %w", ErrInvalidLogQuery, err)
 		}
 	}
 
-	cteSQL, whereClause, args, err := buildLogSQL(searchTree, startTime, endTime)
+	cteSQL, whereClause, args, err := buildLogSQL(searchTree, startTime, endTime, page.Params()...)
 	if err != nil {
-		return nil, fmt.Errorf("Search: %w: %w", ErrInvalidLogQuery, err)
+		return nil, nil, nil, fmt.Errorf("Search: %w: %w", ErrInvalidLogQuery, err)
 	}
 
-	logTimeExpr := `(case when l.timestamp is null or l.timestamp = 0 then l.observed_timestamp else l.timestamp end)`
-	whereWithTime := strings.ReplaceAll(whereClause, "l.log_time", logTimeExpr)
 	finalQuery, err := queries.Render(queries.SearchLogs, searchLogsParams{
+		CTEs:    cteSQL,
+		From:    logSearchFrom,
+		Where:   whereClause,
+		PageSQL: page.SQL(),
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var raw []byte
+	var nextKey *int64
+	var nextID *string
+	if err := db.QueryRowContext(ctx, finalQuery, args...).Scan(&raw, &nextKey, &nextID); err != nil {
+		return nil, nil, nil, fmt.Errorf("Search: %w: %w", ErrLogsStoreInternal, err)
+	}
+	return raw, nextKey, nextID, nil
+}
+
+// Tail returns the logs among keys -- ids from IngestReturning -- that match
+// criteria, oldest first, shaped like Search items. It is the read half of a
+// live tail: the keys say what arrived, and the query tree is applied here, by
+// the same builder Search uses, so a tail and a search never disagree about
+// what a query matches.
+//
+// criteria is checked even when keys is empty, so a caller can validate a
+// query before it has anything to tail.
+func Tail(ctx context.Context, db *sql.DB, keys []string, criteria any) (json.RawMessage, error) {
+	var searchTree *search.QueryNode
+	if criteria != nil {
+		var err error
+		searchTree, err = search.ParseQueryTree(criteria)
+		if err != nil {
+			return nil, fmt.Errorf("Tail: %w: %w", ErrInvalidLogQuery, err)
+		}
+	}
+	// The key list is the window, so the time bounds are the widest there are.
+	cteSQL, whereClause, args, err := buildLogSQL(searchTree, 0, math.MaxInt64,
+		search.NamedParam{Name: "tail_keys", Value: keys})
+	if err != nil {
+		return nil, fmt.Errorf("Tail: %w: %w", ErrInvalidLogQuery, err)
+	}
+	if len(keys) == 0 {
+		return json.RawMessage("[]"), nil
+	}
+
+	query, err := queries.Render(queries.TailLogs, tailLogsParams{
 		CTEs:  cteSQL,
-		From:  logSearchFrom,
-		Where: whereWithTime,
+		Where: whereClause,
 	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("Tail: %w: %w", ErrLogsStoreInternal, err)
 	}
 
 	var raw []byte
-	if err := db.QueryRowContext(ctx, finalQuery, args...).Scan(&raw); err != nil {
-		return nil, fmt.Errorf("Search: %w: %w", ErrLogsStoreInternal, err)
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("Tail: %w: %w", ErrLogsStoreInternal, err)
 	}
 	if raw == nil {
 		return json.RawMessage("[]"), nil
@@ -314,8 +403,117 @@ func DeleteLogsByIDs(ctx context.Context, db *sql.DB, logIDs []any) error {
 	return nil
 }
 
-func buildLogSQL(queryNode *search.QueryNode, startTime, endTime int64) (cteSQL string, whereSQL string, args []any, err error) {
-	return search.BuildSearchSQL(queryNode, startTime, endTime, logFieldMapper(), "l.log_time >= time_start AND l.log_time <= time_end")
+func buildLogSQL(queryNode *search.QueryNode, startTime, endTime int64, extra ...search.NamedParam) (cteSQL string, whereSQL string, args []any, err error) {
+	return search.BuildSearchSQL(queryNode, startTime, endTime, logFieldMapper(), logTimeCondition, extra...)
+}
+
+// logTime is when a log happened: its timestamp, or when it was observed for
+// one that does not say -- OTLP leaves timestamp 0 when it is unknown. The
+// window selects on it, and search_logs.sql and tail_logs.sql order on it.
+const logTime = "coalesce(nullif(l.timestamp, 0), l.observed_timestamp)"
+
+const logTimeCondition = logTime + " >= time_start AND " + logTime + " <= time_end"
+
+// SearchPredicate renders a searchLogs query tree for a statement that reads
+// logs alongside another signal, as the issues package does: the parameters
+// as a CTE called name, and a WHERE clause selecting the logs in the window
+// by the time Tail orders them on. The clause is written against
+// logSearchFrom's aliases -- logs l, resources r, scopes sc -- and the
+// caller's FROM must provide them and list the CTE.
+func SearchPredicate(criteria any, startTime, endTime int64, name string) (cteSQL, whereSQL string, args []any, err error) {
+	var searchTree *search.QueryNode
+	if criteria != nil {
+		searchTree, err = search.ParseQueryTree(criteria)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("SearchPredicate: %w: %w", ErrInvalidLogQuery, err)
+		}
+	}
+	cteSQL, whereSQL, args, err = search.BuildNamedSearchSQL(searchTree, startTime, endTime, logFieldMapper(), logTimeCondition, name)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("SearchPredicate: %w: %w", ErrInvalidLogQuery, err)
+	}
+	return cteSQL, whereSQL, args, nil
+}
+
+// FacetSource renders a searchLogs query tree, and the fields getFacets
+// counts, over the log records it matches. limit is the values each facet
+// lists.
+func FacetSource(criteria any, startTime, endTime int64, fields []search.FieldDefinition, limit int) (search.FacetSource, error) {
+	var searchTree *search.QueryNode
+	if criteria != nil {
+		var err error
+		searchTree, err = search.ParseQueryTree(criteria)
+		if err != nil {
+			return search.FacetSource{}, fmt.Errorf("FacetSource: %w: %w", ErrInvalidLogQuery, err)
+		}
+	}
+	params := []search.NamedParam{{Name: "facet_limit", Value: limit}}
+	columns := make([]search.FacetColumn, len(fields))
+	for i := range fields {
+		col, err := logFacetColumn(i, &fields[i], &params)
+		if err != nil {
+			return search.FacetSource{}, fmt.Errorf("FacetSource: %w", err)
+		}
+		columns[i] = col
+	}
+	cteSQL, whereSQL, args, err := buildLogSQL(searchTree, startTime, endTime, params...)
+	if err != nil {
+		return search.FacetSource{}, fmt.Errorf("FacetSource: %w: %w", ErrInvalidLogQuery, err)
+	}
+	return search.FacetSource{
+		CTEs:    cteSQL,
+		From:    logSearchFrom,
+		Where:   whereSQL,
+		Args:    args,
+		Columns: columns,
+	}, nil
+}
+
+// logFacetColumn reads one facet field off a log row in logSearchFrom.
+func logFacetColumn(i int, field *search.FieldDefinition, params *[]search.NamedParam) (search.FacetColumn, error) {
+	switch field.SearchScope {
+	case "field":
+		expr, err := mapLogFieldExpression(field)
+		if err != nil {
+			return search.FacetColumn{}, err
+		}
+		return search.FacetColumn{Expr: expr}, nil
+	case "attribute":
+		switch field.AttributeScope {
+		case "resource":
+			return search.BindFacetAttribute(i, field, "r.attribute_ids", ingest.ScopeResource, params), nil
+		case "scope":
+			return search.BindFacetAttribute(i, field, "sc.attribute_ids", ingest.ScopeScope, params), nil
+		case "log":
+			return search.BindFacetAttribute(i, field, "l.attribute_ids", ingest.ScopeLog, params), nil
+		default:
+			return search.FacetColumn{}, fmt.Errorf("unknown attribute scope %q: %w", field.AttributeScope, ErrInvalidLogQuery)
+		}
+	default:
+		return search.FacetColumn{}, fmt.Errorf("cannot facet on search scope %q: %w", field.SearchScope, ErrInvalidLogQuery)
+	}
+}
+
+// CompareSource renders compareAttributes' two searchLogs query trees over
+// one window, each a SearchPredicate of its own.
+func CompareSource(selection, baseline any, startTime, endTime int64) (search.CompareSource, error) {
+	selCTE, selWhere, selArgs, err := SearchPredicate(selection, startTime, endTime, "selection_params")
+	if err != nil {
+		return search.CompareSource{}, fmt.Errorf("CompareSource: selection: %w", err)
+	}
+	baseCTE, baseWhere, baseArgs, err := SearchPredicate(baseline, startTime, endTime, "baseline_params")
+	if err != nil {
+		return search.CompareSource{}, fmt.Errorf("CompareSource: baseline: %w", err)
+	}
+	return search.CompareSource{
+		CTEs:           selCTE + ",\n\t\t" + baseCTE,
+		SelectionWhere: selWhere,
+		BaselineWhere:  baseWhere,
+		Args:           append(selArgs, baseArgs...),
+		From:           logCompareFrom,
+		ID:             "l.id",
+		AttributeIDs:   "list_concat(r.attribute_ids, sc.attribute_ids, l.attribute_ids)",
+	}, nil
 }
 
 // logSearchFrom is the FROM clause log search predicates are written against.
@@ -325,6 +523,12 @@ const logSearchFrom = `from search_params, logs l
 			join resources r on r.id = l.resource_id
 			join scopes sc on sc.id = l.scope_id`
 
+// logCompareFrom is logSearchFrom without search_params, for the same reason
+// as spans.spanCompareFrom.
+const logCompareFrom = `logs l
+			join resources r on r.id = l.resource_id
+			join scopes sc on sc.id = l.scope_id`
+
 var logColumns = map[string]struct{}{
 	"id":                       {},
 	"timestamp":                {},
@@ -428,20 +632,19 @@ func mapLogAttributeExpressions(field *search.FieldDefinition, query *search.Que
 		}
 	}
 
-	keyParam := fmt.Sprintf("attr_key_%d", len(*params))
-	*params = append(*params, search.NamedParam{Name: keyParam, Value: field.Name})
+	lookup := ingest.BindAttrLookup(field, params)
 
 	switch field.AttributeScope {
 	case "resource":
 		return []string{fmt.Sprintf(
-			"l.resource_id in (select id from resources where attr_value(attribute_ids, %s) {COND})",
-			keyParam)}, nil
+			"l.resource_id in (select id from resources where %s {COND})",
+			lookup.In("attribute_ids"))}, nil
 	case "scope":
 		return []string{fmt.Sprintf(
-			"l.scope_id in (select id from scopes where attr_value(attribute_ids, %s) {COND})",
-			keyParam)}, nil
+			"l.scope_id in (select id from scopes where %s {COND})",
+			lookup.In("attribute_ids"))}, nil
 	case "log":
-		return []string{fmt.Sprintf("attr_value(l.attribute_ids, %s)", keyParam)}, nil
+		return []string{lookup.In("l.attribute_ids")}, nil
 	default:
 		return nil, fmt.Errorf("unknown attribute scope %s: %w", field.AttributeScope, ErrInvalidLogQuery)
 	}
@@ -484,4 +687,15 @@ type searchLogsParams struct {
 	CTEs  string
 	From  string
 	Where string
+	// The page's counts are the one exception, rendered as literals because
+	// DuckDB wants a constant LIMIT; see search.PageSQL.
+	search.PageSQL
+}
+
+// tailLogsParams are the fragments Tail assembles into
+// queries/logs/tail_logs.sql. There is no From: the query drives from the key
+// list, so its FROM is its own rather than logSearchFrom.
+type tailLogsParams struct {
+	CTEs  string
+	Where string
 }
@@ -1151,3 +1151,78 @@ func TestLogs_ServiceNameDenormStaysConsistent(t *testing.T) {
 		"every log must resolve to a resource row, or the check above passes vacuously")
 	assert.Greater(t, joined, 0)
 }
+
+// A tail reads back exactly the rows IngestReturning named -- not the rest of
+// the table -- and narrows them with the same query tree Search takes.
+func TestTail(t *testing.T) {
+	s, ctx, teardown := setupStore(t)
+	defer teardown()
+
+	baseTime := time.Now().UnixNano()
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return logs.Ingest(ctx, conn, createTestLogsPdata(baseTime), s.FlushedIDs())
+	}))
+	var keys []string
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		var err error
+		keys, err = logs.IngestReturning(ctx, conn, createTestLogsPdata(baseTime+int64(time.Second)), s.FlushedIDs())
+		return err
+	}))
+	require.Len(t, keys, 3)
+	require.Len(t, searchLogsAll(t, s, ctx), 6)
+
+	tail := func(keys []string, query any) ([]logSummaryJSON, error) {
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return logs.Tail(ctx, db, keys, query)
+		})
+		if err != nil {
+			return nil, err
+		}
+		var out []logSummaryJSON
+		require.NoError(t, json.Unmarshal(raw, &out))
+		return out, nil
+	}
+
+	t.Run("NamedRowsOnly", func(t *testing.T) {
+		got, err := tail(keys, nil)
+		require.NoError(t, err)
+		require.Len(t, got, 3)
+		want := map[string]bool{}
+		for _, k := range keys {
+			want[k] = true
+		}
+		for i, e := range got {
+			assert.True(t, want[e.ID], "%s was not in this batch", e.ID)
+			if i > 0 {
+				assert.LessOrEqual(t, parseWireTimestamp(t, got[i-1].Timestamp), parseWireTimestamp(t, e.Timestamp), "oldest first")
+			}
+		}
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		query := &search.QueryNode{
+			ID:   "q1",
+			Type: "condition",
+			Query: &search.Query{
+				Field:         &search.FieldDefinition{SearchScope: "global"},
+				FieldOperator: "CONTAINS",
+				Value:         "Operation failed",
+			},
+		}
+		got, err := tail(keys, query)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, "ERROR", got[0].SeverityText)
+	})
+
+	t.Run("NothingArrived", func(t *testing.T) {
+		got, err := tail(nil, nil)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("BadQueryWithNoKeys", func(t *testing.T) {
+		_, err := tail(nil, map[string]any{"type": "nonsense"})
+		assert.ErrorIs(t, err, logs.ErrInvalidLogQuery)
+	})
+}
@@ -0,0 +1,133 @@
+package logs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/rebuild"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/util"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// otlpDocument is what export_logs.sql returns.
+type otlpDocument struct {
+	Resources map[string]rebuild.Resource `json:"resources"`
+	Scopes    map[string]rebuild.Scope    `json:"scopes"`
+	IDs       []string                    `json:"ids"`
+	Logs      []otlpLog                   `json:"logs"`
+}
+
+type otlpLog struct {
+	Timestamp              string              `json:"timestamp"`
+	ObservedTimestamp      string              `json:"observedTimestamp"`
+	TraceID                string              `json:"traceID"`
+	SpanID                 string              `json:"spanID"`
+	SeverityText           string              `json:"severityText"`
+	SeverityNumber         int32               `json:"severityNumber"`
+	Body                   string              `json:"body"`
+	BodyType               string              `json:"bodyType"`
+	DroppedAttributesCount uint32              `json:"droppedAttributesCount"`
+	Flags                  uint32              `json:"flags"`
+	EventName              string              `json:"eventName"`
+	Attributes             []rebuild.Attribute `json:"attributes"`
+	R                      int64               `json:"r"`
+	S                      int64               `json:"s"`
+	ResourceSchemaURL      string              `json:"resourceSchemaURL"`
+	ScopeSchemaURL         string              `json:"scopeSchemaURL"`
+}
+
+// ExportOTLP rebuilds the given log records as pdata, regrouped under their
+// resources and scopes. logIDs are the tool-minted ids Search returns; one the
+// store does not hold is ErrLogIDNotFound.
+//
+// The record order within a scope is by time, since the store does not keep
+// the order they were sent in. See spans.ExportOTLP for what else a rebuilt
+// batch cannot carry.
+func ExportOTLP(ctx context.Context, db *sql.DB, logIDs []string) (plog.Logs, error) {
+	ld := plog.NewLogs()
+	query, err := queries.Render(queries.ExportLogs, nil)
+	if err != nil {
+		return ld, fmt.Errorf("ExportOTLP: %w: %w", ErrLogsStoreInternal, err)
+	}
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, logIDs).Scan(&raw); err != nil {
+		return ld, fmt.Errorf("ExportOTLP: %w: %w", ErrLogsStoreInternal, err)
+	}
+	var doc otlpDocument
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return ld, fmt.Errorf("ExportOTLP: %w: %w", ErrLogsStoreInternal, err)
+	}
+	found := make(map[string]bool, len(doc.IDs))
+	for _, id := range doc.IDs {
+		found[id] = true
+	}
+	for _, id := range logIDs {
+		if !found[id] {
+			return ld, fmt.Errorf("ExportOTLP: %w: %s", ErrLogIDNotFound, id)
+		}
+	}
+
+	var rl plog.ResourceLogs
+	var sl plog.ScopeLogs
+	var cur rebuild.Batch
+	for i, row := range doc.Logs {
+		batch := rebuild.Batch{
+			Resource:          strconv.FormatInt(row.R, 10),
+			ResourceSchemaURL: row.ResourceSchemaURL,
+			Scope:             strconv.FormatInt(row.S, 10),
+			ScopeSchemaURL:    row.ScopeSchemaURL,
+		}
+		if i == 0 || !batch.SameResource(cur) {
+			rl = ld.ResourceLogs().AppendEmpty()
+			doc.Resources[batch.Resource].CopyTo(rl.Resource())
+			rl.SetSchemaUrl(batch.ResourceSchemaURL)
+		}
+		if i == 0 || batch != cur {
+			sl = rl.ScopeLogs().AppendEmpty()
+			doc.Scopes[batch.Scope].CopyTo(sl.Scope())
+			sl.SetSchemaUrl(batch.ScopeSchemaURL)
+		}
+		cur = batch
+		if err := row.copyTo(sl.LogRecords().AppendEmpty()); err != nil {
+			return ld, fmt.Errorf("ExportOTLP: %w: %w", ErrLogsStoreInternal, err)
+		}
+	}
+	return ld, nil
+}
+
+func (row otlpLog) copyTo(lr plog.LogRecord) error {
+	ts, err := rebuild.Timestamp(row.Timestamp)
+	if err != nil {
+		return err
+	}
+	observed, err := rebuild.Timestamp(row.ObservedTimestamp)
+	if err != nil {
+		return err
+	}
+	traceID, err := rebuild.TraceID(row.TraceID)
+	if err != nil {
+		return err
+	}
+	spanID, err := rebuild.SpanID(row.SpanID)
+	if err != nil {
+		return err
+	}
+	lr.SetTimestamp(ts)
+	lr.SetObservedTimestamp(observed)
+	lr.SetTraceID(traceID)
+	lr.SetSpanID(spanID)
+	lr.SetSeverityText(row.SeverityText)
+	lr.SetSeverityNumber(plog.SeverityNumber(row.SeverityNumber))
+	// The body is rendered by the same function as an attribute value, so it
+	// reads back by the same one.
+	util.StringAndTypeToValue(row.Body, row.BodyType, lr.Body())
+	lr.SetDroppedAttributesCount(row.DroppedAttributesCount)
+	lr.SetFlags(plog.LogRecordFlags(row.Flags))
+	lr.SetEventName(row.EventName)
+	rebuild.Attributes(lr.Attributes(), row.Attributes)
+	return nil
+}
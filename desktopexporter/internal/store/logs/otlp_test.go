@@ -0,0 +1,121 @@
+package logs_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
+)
+
+// otlpFixtureLogs is one export request from one service, with every field
+// the store keeps set and records already in time order, which is the order
+// the export puts them in.
+func otlpFixtureLogs(ld plog.Logs, service string, base int64) {
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.SetSchemaUrl("https://opentelemetry.io/schemas/1.27.0")
+	rl.Resource().Attributes().PutStr("service.name", service)
+	rl.Resource().SetDroppedAttributesCount(1)
+
+	sl := rl.ScopeLogs().AppendEmpty()
+	sl.SetSchemaUrl("https://opentelemetry.io/schemas/1.26.0")
+	sl.Scope().SetName("io.opentelemetry.log")
+	sl.Scope().SetVersion("0.9.0")
+	sl.Scope().SetDroppedAttributesCount(2)
+
+	for i := range 3 {
+		lr := sl.LogRecords().AppendEmpty()
+		lr.SetTimestamp(pcommon.Timestamp(base + int64(i)*1_000))
+		lr.SetObservedTimestamp(pcommon.Timestamp(base + int64(i)*1_000 + 7))
+		lr.SetTraceID(pcommon.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, byte(i + 1)})
+		lr.SetSpanID(pcommon.SpanID{0, 0, 0, 0, 0, 0, 0, byte(i + 1)})
+		lr.SetSeverityText("WARN")
+		lr.SetSeverityNumber(plog.SeverityNumberWarn)
+		lr.SetFlags(plog.DefaultLogRecordFlags.WithIsSampled(true))
+		lr.SetEventName("session.start")
+		lr.SetDroppedAttributesCount(3)
+		lr.Attributes().PutInt("attempt", int64(i))
+		lr.Attributes().PutStr("path", `C:\temp`)
+		switch i {
+		case 0:
+			lr.Body().SetStr("plain text")
+		case 1:
+			body := lr.Body().SetEmptyMap()
+			body.PutStr("event", "login")
+			body.PutStr("user", "ada")
+		case 2:
+			body := lr.Body().SetEmptySlice()
+			body.AppendEmpty().SetStr("a")
+			body.AppendEmpty().SetStr("b")
+		}
+	}
+}
+
+func ingestLogsReturning(t *testing.T, s *store.Store, ld plog.Logs) []string {
+	t.Helper()
+	var ids []string
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		var err error
+		ids, err = logs.IngestReturning(context.Background(), conn, ld, s.FlushedIDs())
+		return err
+	}))
+	return ids
+}
+
+func exportLogs(s *store.Store, ids ...string) (plog.Logs, error) {
+	return readStore(s, func(db *sql.DB) (plog.Logs, error) {
+		return logs.ExportOTLP(context.Background(), db, ids)
+	})
+}
+
+// Log records exported from the store are the records that were sent, under
+// the resources and scopes they were sent with.
+func TestExportOTLPRoundTrip(t *testing.T) {
+	s, _, teardown := setupStore(t)
+	defer teardown()
+
+	frontend := plog.NewLogs()
+	otlpFixtureLogs(frontend, "frontend", 1_700_000_000_000_000_000)
+	backend := plog.NewLogs()
+	otlpFixtureLogs(backend, "backend", 1_700_000_001_000_000_000)
+	ids := ingestLogsReturning(t, s, frontend)
+	ids = append(ids, ingestLogsReturning(t, s, backend)...)
+
+	// Noise that must not come along.
+	other := plog.NewLogs()
+	otlpFixtureLogs(other, "frontend", 1_700_000_002_000_000_000)
+	ingestLogsReturning(t, s, other)
+
+	want := plog.NewLogs()
+	frontend.ResourceLogs().MoveAndAppendTo(want.ResourceLogs())
+	backend.ResourceLogs().MoveAndAppendTo(want.ResourceLogs())
+
+	got, err := exportLogs(s, ids...)
+	require.NoError(t, err)
+
+	marshaler := &plog.JSONMarshaler{}
+	wantJSON, err := marshaler.MarshalLogs(want)
+	require.NoError(t, err)
+	gotJSON, err := marshaler.MarshalLogs(got)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(wantJSON), string(gotJSON))
+}
+
+func TestExportOTLPUnknownLog(t *testing.T) {
+	s, _, teardown := setupStore(t)
+	defer teardown()
+
+	ld := plog.NewLogs()
+	otlpFixtureLogs(ld, "frontend", 1_700_000_000_000_000_000)
+	ids := ingestLogsReturning(t, s, ld)
+
+	_, err := exportLogs(s, ids[0], "00000000-0000-0000-0000-000000000001")
+	assert.ErrorIs(t, err, logs.ErrLogIDNotFound)
+}
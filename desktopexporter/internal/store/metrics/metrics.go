@@ -331,6 +331,10 @@ func Ingest(ctx context.Context, conn driver.Conn, m pmetric.Metrics, flushed *i
 					if err := ingestExponentialHistogramDatapoints(appenders, streamID, ingestID, metric.ExponentialHistogram().DataPoints(), dpIdents, &dpCur); err != nil {
 						return fmt.Errorf("Ingest: %w: %w", ErrMetricsStoreInternal, err)
 					}
+				case pmetric.MetricTypeSummary:
+					if err := ingestSummaryDatapoints(appenders, streamID, ingestID, metric.Summary().DataPoints(), dpIdents, &dpCur); err != nil {
+						return fmt.Errorf("Ingest: %w: %w", ErrMetricsStoreInternal, err)
+					}
 				}
 				metricCount++
 				if metricCount%flushIntervalMetrics == 0 {
@@ -495,6 +499,12 @@ func eachDatapoint(metric pmetric.Metric, fn func(attrs pcommon.Map, exemplars p
 		for _, dp := range metric.ExponentialHistogram().DataPoints().All() {
 			fn(dp.Attributes(), dp.Exemplars())
 		}
+	case pmetric.MetricTypeSummary:
+		// OTLP gives a Summary datapoint no exemplars; an empty slice keeps
+		// the callback's shape the same for every type.
+		for _, dp := range metric.Summary().DataPoints().All() {
+			fn(dp.Attributes(), pmetric.NewExemplarSlice())
+		}
 	}
 }
 
@@ -635,8 +645,10 @@ func ingestGaugeDatapoints(appenders map[string]*duckdb.Appender, streamID, inge
 		ident := idents[*cur]
 		*cur++
 		if err := appenders["datapoints"].AppendRow(
-			datapointID, streamID, ident.series, ingestID, int64(dp.Timestamp()), int64(dp.StartTimestamp()), uint32(dp.Flags()),
+			datapointID, streamID, ident.series, ingestID, pmetric.MetricTypeGauge.String(),
+			int64(dp.Timestamp()), int64(dp.StartTimestamp()), uint32(dp.Flags()),
 			doubleVal, intVal, valType, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+			nil,
 			ident.attrs,
 		); err != nil {
 			return fmt.Errorf("Ingest: %w: %w", ErrMetricsStoreInternal, err)
@@ -661,9 +673,11 @@ func ingestSumDatapoints(appenders map[string]*duckdb.Appender, streamID, ingest
 		ident := idents[*cur]
 		*cur++
 		if err := appenders["datapoints"].AppendRow(
-			datapointID, streamID, ident.series, ingestID, int64(dp.Timestamp()), int64(dp.StartTimestamp()), uint32(dp.Flags()),
+			datapointID, streamID, ident.series, ingestID, pmetric.MetricTypeSum.String(),
+			int64(dp.Timestamp()), int64(dp.StartTimestamp()), uint32(dp.Flags()),
 			doubleVal, intVal, valType,
 			nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+			nil,
 			ident.attrs,
 		); err != nil {
 			return fmt.Errorf("Ingest: %w: %w", ErrMetricsStoreInternal, err)
@@ -681,10 +695,12 @@ func ingestHistogramDatapoints(appenders map[string]*duckdb.Appender, streamID,
 		ident := idents[*cur]
 		*cur++
 		if err := appenders["datapoints"].AppendRow(
-			datapointID, streamID, ident.series, ingestID, int64(dp.Timestamp()), int64(dp.StartTimestamp()), uint32(dp.Flags()),
+			datapointID, streamID, ident.series, ingestID, pmetric.MetricTypeHistogram.String(),
+			int64(dp.Timestamp()), int64(dp.StartTimestamp()), uint32(dp.Flags()),
 			nil, nil, nil,
 			dp.Count(), dp.Sum(), dp.Min(), dp.Max(), dp.BucketCounts().AsRaw(), ingest.BoundsID(dp.ExplicitBounds().AsRaw()),
 			nil, nil, nil, nil, nil, nil, nil,
+			nil,
 			ident.attrs,
 		); err != nil {
 			return fmt.Errorf("Ingest: %w: %w", ErrMetricsStoreInternal, err)
@@ -703,10 +719,12 @@ func ingestExponentialHistogramDatapoints(appenders map[string]*duckdb.Appender,
 		ident := idents[*cur]
 		*cur++
 		if err := appenders["datapoints"].AppendRow(
-			datapointID, streamID, ident.series, ingestID, int64(dp.Timestamp()), int64(dp.StartTimestamp()), uint32(dp.Flags()),
+			datapointID, streamID, ident.series, ingestID, pmetric.MetricTypeExponentialHistogram.String(),
+			int64(dp.Timestamp()), int64(dp.StartTimestamp()), uint32(dp.Flags()),
 			nil, nil, nil,
 			dp.Count(), dp.Sum(), dp.Min(), dp.Max(), nil, nil,
 			dp.Scale(), dp.ZeroCount(), dp.ZeroThreshold(), pos.Offset(), pos.BucketCounts().AsRaw(), neg.Offset(), neg.BucketCounts().AsRaw(),
+			nil,
 			ident.attrs,
 		); err != nil {
 			return fmt.Errorf("Ingest: %w: %w", ErrMetricsStoreInternal, err)
@@ -718,6 +736,42 @@ func ingestExponentialHistogramDatapoints(appenders map[string]*duckdb.Appender,
 	return nil
 }
 
+// quantileValue is one element of datapoints.quantile_values. The db tags are
+// what the appender matches struct fields to column fields by.
+type quantileValue struct {
+	Quantile float64 `db:"quantile"`
+	Value    float64 `db:"value"`
+}
+
+// ingestSummaryDatapoints writes Summary datapoints: count, sum and the
+// sender's quantiles, stored as reported. Summaries carry no exemplars, so
+// unlike the other ingest* helpers there is nothing to write beyond the row.
+func ingestSummaryDatapoints(appenders map[string]*duckdb.Appender, streamID, ingestID duckdb.UUID, dps pmetric.SummaryDataPointSlice, idents []dpIdentity, cur *int) error {
+	for _, dp := range dps.All() {
+		datapointID := duckdb.UUID(uuid.New())
+		ident := idents[*cur]
+		*cur++
+		// Always a list, even an empty one: quantile_values being set is what
+		// the table's CHECK reads as "this row is a Summary".
+		qv := make([]quantileValue, 0, dp.QuantileValues().Len())
+		for _, q := range dp.QuantileValues().All() {
+			qv = append(qv, quantileValue{Quantile: q.Quantile(), Value: q.Value()})
+		}
+		if err := appenders["datapoints"].AppendRow(
+			datapointID, streamID, ident.series, ingestID, pmetric.MetricTypeSummary.String(),
+			int64(dp.Timestamp()), int64(dp.StartTimestamp()), uint32(dp.Flags()),
+			nil, nil, nil,
+			dp.Count(), dp.Sum(), nil, nil, nil, nil,
+			nil, nil, nil, nil, nil, nil, nil,
+			qv,
+			ident.attrs,
+		); err != nil {
+			return fmt.Errorf("Ingest: %w: %w", ErrMetricsStoreInternal, err)
+		}
+	}
+	return nil
+}
+
 func numberDataPointValue(dp pmetric.NumberDataPoint) (doubleVal any, intVal any, typeStr string) {
 	typeStr = dp.ValueType().String()
 	switch dp.ValueType() {
@@ -741,35 +795,60 @@ func numberDataPointValue(dp pmetric.NumberDataPoint) (doubleVal any, intVal any
 // ingests match. The summary aggregation then runs over the matched
 // streams' in-range datapoints, identical to before.
 func SearchSummaries(ctx context.Context, db *sql.DB, startTime, endTime int64, criteria any) (json.RawMessage, error) {
+	raw, _, _, err := searchSummaries(ctx, db, startTime, endTime, criteria, search.Page{})
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return json.RawMessage("[]"), nil
+	}
+	return json.RawMessage(raw), nil
+}
+
+// SearchSummariesPage is SearchSummaries one page at a time, most recently
+// reporting stream first, wrapped in a search.PageResult.
+func SearchSummariesPage(ctx context.Context, db *sql.DB, startTime, endTime int64, criteria any, page search.Page) (json.RawMessage, error) {
+	raw, nextKey, nextID, err := searchSummaries(ctx, db, startTime, endTime, criteria, page)
+	if err != nil {
+		return nil, err
+	}
+	out, err := search.PageFromRow(raw, nextKey, nextID)
+	if err != nil {
+		return nil, fmt.Errorf("SearchSummariesPage: %w: %w", ErrMetricsStoreInternal, err)
+	}
+	return out, nil
+}
+
+func searchSummaries(ctx context.Context, db *sql.DB, startTime, endTime int64, criteria any, page search.Page) ([]byte, *int64, *string, error) {
 	var searchTree *search.QueryNode
 	if criteria != nil {
 		var err error
 		searchTree, err = search.ParseQueryTree(criteria)
 		if err != nil {
-			return nil, fmt.Errorf("SearchSummaries: %w: %w", ErrInvalidMetricQuery, err)
+			return nil, nil, nil, fmt.Errorf("SearchSummaries: %w: %w", ErrInvalidMetricQuery, err)
 		}
 	}
-	cteSQL, whereClause, args, err := buildMetricSQL(searchTree, startTime, endTime)
+	cteSQL, whereClause, args, err := buildMetricSQL(searchTree, startTime, endTime, page.Params()...)
 	if err != nil {
-		return nil, fmt.Errorf("SearchSummaries: %w: %w", ErrInvalidMetricQuery, err)
+		return nil, nil, nil, fmt.Errorf("SearchSummaries: %w: %w", ErrInvalidMetricQuery, err)
 	}
 
 	query, err := queries.Render(queries.SearchMetricSummaries, searchSummariesParams{
-		CTEs:  cteSQL,
-		From:  metricSearchFrom,
-		Where: whereClause,
+		CTEs:    cteSQL,
+		From:    metricSearchFrom,
+		Where:   whereClause,
+		PageSQL: page.SQL(),
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	var raw []byte
-	if err := db.QueryRowContext(ctx, query, args...).Scan(&raw); err != nil {
-		return nil, fmt.Errorf("SearchSummaries: %w: %w", ErrMetricsStoreInternal, err)
+	var nextKey *int64
+	var nextID *string
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&raw, &nextKey, &nextID); err != nil {
+		return nil, nil, nil, fmt.Errorf("SearchSummaries: %w: %w", ErrMetricsStoreInternal, err)
 	}
-	if raw == nil {
-		return json.RawMessage("[]"), nil
-	}
-	return json.RawMessage(raw), nil
+	return raw, nextKey, nextID, nil
 }
 
 // GetMetric returns full MetricData for a metric stream in the time window.
@@ -1048,9 +1127,9 @@ func DeleteMetricStream(ctx context.Context, db *sql.DB, streamID string) error
 //
 // Search-level field expressions still use the "m.<col>" / "s.<col>"
 // shape so callers don't need to know about the internal join.
-func buildMetricSQL(queryNode *search.QueryNode, startTime, endTime int64) (cteSQL string, whereSQL string, args []any, err error) {
+func buildMetricSQL(queryNode *search.QueryNode, startTime, endTime int64, extra ...search.NamedParam) (cteSQL string, whereSQL string, args []any, err error) {
 	timeCondition := "exists (select 1 from datapoints d where d.metric_ingest_id = m.id and d.timestamp >= time_start and d.timestamp <= time_end)"
-	return search.BuildSearchSQL(queryNode, startTime, endTime, metricFieldMapper(), timeCondition)
+	return search.BuildSearchSQL(queryNode, startTime, endTime, metricFieldMapper(), timeCondition, extra...)
 }
 
 // metricColumns lists field names the search expression syntax can
@@ -1107,7 +1186,7 @@ func metricFieldMapper() search.FieldMapper {
 const matchIngestByLabel = `m.id in (
 			select d.metric_ingest_id from %s
 			where %s && (
-				select list(a.id) from attributes a where a.key = %s and a.value {COND}
+				select list(a.id) from attributes a where a.key = %s and %s {COND}
 			)
 		)`
 
@@ -1192,24 +1271,24 @@ func mapMetricAttributeExpressions(field *search.FieldDefinition, query *search.
 		}
 	}
 
-	keyParam := fmt.Sprintf("attr_key_%d", len(*params))
-	*params = append(*params, search.NamedParam{Name: keyParam, Value: field.Name})
+	lookup := ingest.BindAttrLookup(field, params)
 
 	switch field.AttributeScope {
 	case "resource", "metric":
 		return []string{fmt.Sprintf(
-			"m.resource_id in (select id from resources where attr_value(attribute_ids, %s) {COND})",
-			keyParam)}, nil
+			"m.resource_id in (select id from resources where %s {COND})",
+			lookup.In("attribute_ids"))}, nil
 	case "scope":
 		return []string{fmt.Sprintf(
-			"m.scope_id in (select id from scopes where attr_value(attribute_ids, %s) {COND})",
-			keyParam)}, nil
+			"m.scope_id in (select id from scopes where %s {COND})",
+			lookup.In("attribute_ids"))}, nil
 	case "datapoint":
 		return []string{fmt.Sprintf(matchIngestByLabel,
-			"datapoints d", "d.attribute_ids", keyParam)}, nil
+			"datapoints d", "d.attribute_ids", lookup.Key, lookup.Value)}, nil
 	case "exemplar":
 		return []string{fmt.Sprintf(matchIngestByLabel,
-			"exemplars e join datapoints d on d.id = e.datapoint_id", "e.attribute_ids", keyParam)}, nil
+			"exemplars e join datapoints d on d.id = e.datapoint_id", "e.attribute_ids",
+			lookup.Key, lookup.Value)}, nil
 	default:
 		return nil, fmt.Errorf("unknown attribute scope %s: %w", field.AttributeScope, ErrInvalidMetricQuery)
 	}
@@ -1341,4 +1420,7 @@ type searchSummariesParams struct {
 	From string
 	// Where is the predicate, "true" when there are no criteria.
 	Where string
+	// PageSQL sizes the page and says whether a cursor applies; the zero
+	// value is the whole result.
+	search.PageSQL
 }
@@ -1428,6 +1428,44 @@ type histTestDP struct {
 	max       float64
 }
 
+// summaryTestDP is the Summary analogue of histTestDP. quantiles are
+// (quantile, value) pairs, in the order the sender would report them.
+type summaryTestDP struct {
+	timestamp time.Time
+	attrs     map[string]string
+	count     uint64
+	sum       float64
+	quantiles [][2]float64
+}
+
+// makeSummaryFixture builds a single-metric Summary fixture. Summaries have no
+// temporality, so unlike the histogram builders there is no T variant.
+func makeSummaryFixture(name string, dps []summaryTestDP) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "test-summary")
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName("test-scope")
+	m := sm.Metrics().AppendEmpty()
+	m.SetName(name)
+	summary := m.SetEmptySummary()
+	for _, dp := range dps {
+		d := summary.DataPoints().AppendEmpty()
+		d.SetTimestamp(pcommon.Timestamp(dp.timestamp.UnixNano()))
+		d.SetCount(dp.count)
+		d.SetSum(dp.sum)
+		for _, q := range dp.quantiles {
+			qv := d.QuantileValues().AppendEmpty()
+			qv.SetQuantile(q[0])
+			qv.SetValue(q[1])
+		}
+		for k, v := range dp.attrs {
+			d.Attributes().PutStr(k, v)
+		}
+	}
+	return md
+}
+
 // findMetricID looks up the ingested metric's UUID by name via Search. The
 // id is generated at ingest time so we can't predict it.
 func findMetricID(t *testing.T, s *store.Store, ctx context.Context, name string) string {
@@ -1580,6 +1618,29 @@ func TestSearchSummaries_CardFields(t *testing.T) {
 		assert.EqualValues(t, 1, summary["dataPointCount"])
 		assert.Nil(t, summary["lastValue"])
 	})
+
+	t.Run("SummaryIsListed", func(t *testing.T) {
+		s, ctx, teardown := setupStore(t)
+		defer teardown()
+
+		ts := time.Unix(1700000000, 0)
+		md := makeSummaryFixture("summary_card_test", []summaryTestDP{
+			{timestamp: ts, attrs: map[string]string{"host": "a"},
+				count: 6, sum: 7.0, quantiles: [][2]float64{{0.5, 1.0}}},
+			{timestamp: ts.Add(time.Second), attrs: map[string]string{"host": "b"},
+				count: 4, sum: 3.0, quantiles: [][2]float64{{0.5, 0.5}}},
+		})
+		require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+			return metrics.Ingest(ctx, conn, md, s.FlushedIDs())
+		}))
+
+		summary := findSummary(t, searchSummariesAll(t, s, ctx), "summary_card_test")
+		assert.Equal(t, "Summary", summary["metricType"])
+		assert.EqualValues(t, 2, summary["seriesCount"])
+		assert.EqualValues(t, 2, summary["dataPointCount"])
+		assert.Nil(t, summary["lastValue"], "a summary has no single value to show")
+		assert.NotEmpty(t, summary["lastSeen"])
+	})
 }
 
 // Datapoint and exemplar labels are searchable.
@@ -1681,6 +1742,29 @@ func TestMetricSearch_DatapointAndExemplarLabels(t *testing.T) {
 		}
 	})
 
+	t.Run("a path into a scalar datapoint label matches nothing", func(t *testing.T) {
+		query := map[string]any{
+			"type": "condition",
+			"query": map[string]any{
+				"field": map[string]any{
+					"name":           "memory.type",
+					"path":           []string{"kind"},
+					"searchScope":    "attribute",
+					"attributeScope": "datapoint",
+				},
+				"fieldOperator": "=",
+				"value":         "heap",
+			},
+		}
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return metrics.SearchSummaries(ctx, db, 0, time.Now().UnixNano()+int64(time.Hour), query)
+		})
+		require.NoError(t, err, "memory.type is not JSON; the path must not be applied to it")
+		var out []map[string]any
+		require.NoError(t, json.Unmarshal(raw, &out))
+		assert.Empty(t, out)
+	})
+
 	t.Run("LIKE on a datapoint label", func(t *testing.T) {
 		got := search(t, "datapoint", "memory.type", "CONTAINS", "hea")
 		require.Len(t, got, 1)
@@ -5116,6 +5200,21 @@ func TestIngest_NilArraysReachingTheAppender(t *testing.T) {
 			return metrics.Ingest(ctx, c, md, s.FlushedIDs())
 		}))
 	})
+
+	// A Summary with no quantiles is legal OTLP -- count and sum alone -- and
+	// quantile_values must still come out a list rather than NULL, because
+	// the table's CHECK reads a set quantile_values as "this row is a Summary".
+	t.Run("summary with no quantiles", func(t *testing.T) {
+		s, ctx, teardown := setupStore(t)
+		defer teardown()
+
+		md := makeSummaryFixture("no.quantiles", []summaryTestDP{{timestamp: base, count: 3, sum: 1.5}})
+		require.NoError(t, s.WithConn(func(c driver.Conn) error {
+			return metrics.Ingest(ctx, c, md, s.FlushedIDs())
+		}))
+		assert.Equal(t, 1, countRows(t, s, ctx,
+			`select count(*) from datapoints where len(quantile_values) = 0`))
+	})
 }
 
 // TestMetricMetadataRoundTrip pins OTLP's Metric.metadata through the store.
@@ -5190,3 +5289,157 @@ func TestMetricMetadataRoundTrip(t *testing.T) {
 		require.NotEqual(t, "owner", d["name"])
 	}
 }
+
+// TestSummaryRoundTrip covers the whole Summary path: it used to get a stream
+// and an ingest row and then lose every datapoint, so the metric appeared in
+// the list and opened onto nothing.
+func TestSummaryRoundTrip(t *testing.T) {
+	s, ctx, teardown := setupStore(t)
+	defer teardown()
+
+	base := time.Now().Add(-time.Minute)
+	fixture := makeSummaryFixture("rpc.latency", []summaryTestDP{
+		{timestamp: base, attrs: map[string]string{"method": "Get"},
+			count: 100, sum: 25.5,
+			quantiles: [][2]float64{{0, 0.01}, {0.5, 0.2}, {0.99, 1.8}, {1, 2.5}}},
+		{timestamp: base.Add(10 * time.Second), attrs: map[string]string{"method": "Get"},
+			count: 140, sum: 31.0,
+			quantiles: [][2]float64{{0, 0.01}, {0.5, 0.25}, {0.99, 1.9}, {1, 2.6}}},
+	})
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return metrics.Ingest(ctx, conn, fixture, s.FlushedIDs())
+	}))
+
+	assert.Equal(t, 2, countRows(t, s, ctx,
+		`select count(*) from datapoints where metric_type = 'Summary'`))
+
+	m := getMetricFullByName(t, s, ctx, "rpc.latency")
+	assert.Equal(t, "Summary", m["metricType"])
+	ts := m["timeseries"].([]any)
+	require.Len(t, ts, 1, "both datapoints carry the same labels")
+	series := ts[0].(map[string]any)
+	assert.Nil(t, series["stats"], "a summary has no scalar to summarise")
+	assert.Nil(t, series["sparkline"])
+
+	dps := series["datapoints"].([]any)
+	require.Len(t, dps, 2)
+	// Newest first, as every other type.
+	latest := dps[0].(map[string]any)
+	assert.Equal(t, "Summary", latest["metricType"])
+	assert.EqualValues(t, 140, latest["count"])
+	assert.InDelta(t, 31.0, latest["sum"], 1e-9)
+
+	qv := latest["quantileValues"].([]any)
+	require.Len(t, qv, 4, "every reported quantile is kept, in order")
+	first := qv[1].(map[string]any)
+	assert.InDelta(t, 0.5, first["quantile"], 1e-9)
+	assert.InDelta(t, 0.25, first["value"], 1e-9)
+
+	// The histogram-only fields stay absent rather than arriving as nulls a
+	// reader has to interpret.
+	for _, key := range []string{"bucketCounts", "explicitBounds", "scale", "doubleValue"} {
+		assert.NotContains(t, latest, key)
+	}
+}
+
+// A Summary's quantiles are drawn through the same keyed object a histogram's
+// are, so the chart needs no second code path. They are the sender's own
+// numbers: every stored quantile comes back whatever was requested, because
+// there is nothing to compute one it did not report from.
+func TestGetMetric_SummaryQuantiles(t *testing.T) {
+	s, ctx, teardown := setupStore(t)
+	defer teardown()
+
+	base := time.Now().Add(-time.Minute)
+	fixture := makeSummaryFixture("q.summary", []summaryTestDP{{
+		timestamp: base, count: 60, sum: 200,
+		quantiles: [][2]float64{{0.5, 4}, {0.9, 7}, {0.99, 7.9}},
+	}})
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return metrics.Ingest(ctx, conn, fixture, s.FlushedIDs())
+	}))
+	streamID := findMetricID(t, s, ctx, "q.summary")
+	end := time.Now().UnixNano() + int64(time.Hour)
+
+	datapoint := func(qs []float64) map[string]any {
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return metrics.GetMetric(ctx, db, streamID, 0, end, 0, nil, qs, 0, false, 0, 0, nil, "", nil, 0)
+		})
+		require.NoError(t, err)
+		var m map[string]any
+		require.NoError(t, json.Unmarshal(raw, &m))
+		ts := m["timeseries"].([]any)
+		require.Len(t, ts, 1)
+		dps := ts[0].(map[string]any)["datapoints"].([]any)
+		require.Len(t, dps, 1)
+		return dps[0].(map[string]any)
+	}
+
+	for _, qs := range [][]float64{nil, {0.5, 0.95}} {
+		q, ok := datapoint(qs)["quantiles"].(map[string]any)
+		require.True(t, ok, "quantiles must be an object keyed by the quantile")
+		assert.Len(t, q, 3)
+		assert.InDelta(t, 4.0, q["0.5"], 1e-9)
+		assert.InDelta(t, 7.0, q["0.9"], 1e-9)
+		assert.InDelta(t, 7.9, q["0.99"], 1e-9)
+		assert.NotContains(t, q, "0.95", "a quantile the sender did not report is not invented")
+	}
+}
+
+// Summaries are never reduced: their quantiles cannot be merged, and they have
+// no scalar to elect on. Asking for fewer buckets than datapoints must still
+// return every one of them.
+func TestGetMetric_SummaryIsNotReduced(t *testing.T) {
+	s, ctx, teardown := setupStore(t)
+	defer teardown()
+
+	base := time.Date(2026, 5, 24, 12, 0, 0, 0, time.UTC)
+	var dps []summaryTestDP
+	for i := range 40 {
+		dps = append(dps, summaryTestDP{
+			timestamp: base.Add(time.Duration(i) * time.Second),
+			count:     uint64(i + 1), sum: float64(i),
+			quantiles: [][2]float64{{0.5, float64(i)}},
+		})
+	}
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return metrics.Ingest(ctx, conn, makeSummaryFixture("unreduced.summary", dps), s.FlushedIDs())
+	}))
+	streamID := findMetricID(t, s, ctx, "unreduced.summary")
+
+	raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+		return metrics.GetMetric(ctx, db, streamID,
+			base.Add(-time.Minute).UnixNano(), base.Add(time.Minute).UnixNano(),
+			4, nil, nil, 0, false, 0, 0, nil, "", nil, 0)
+	})
+	require.NoError(t, err)
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(raw, &m))
+	ts := m["timeseries"].([]any)
+	require.Len(t, ts, 1)
+	assert.Len(t, ts[0].(map[string]any)["datapoints"].([]any), 40)
+	assert.Nil(t, m["aggregate"], "there is no cross-series merge for a summary")
+}
+
+// Deleting a Summary stream takes its datapoints with it, the same as any
+// other type -- the delete is by stream, not by shape, but a new column is a
+// new way to leave something behind.
+func TestDeleteMetricStream_Summary(t *testing.T) {
+	s, ctx, teardown := setupStore(t)
+	defer teardown()
+
+	base := time.Now().Add(-time.Minute)
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return metrics.Ingest(ctx, conn, makeSummaryFixture("doomed.summary", []summaryTestDP{
+			{timestamp: base, count: 1, sum: 1, quantiles: [][2]float64{{0.5, 1}}},
+		}), s.FlushedIDs())
+	}))
+	require.Equal(t, 1, countRows(t, s, ctx, `select count(*) from datapoints`))
+
+	streamID := findMetricID(t, s, ctx, "doomed.summary")
+	require.NoError(t, s.WithDBWrite(func(db *sql.DB) error {
+		return metrics.DeleteMetricStream(ctx, db, streamID)
+	}))
+	assert.Zero(t, countRows(t, s, ctx, `select count(*) from datapoints`))
+	assert.Empty(t, searchSummariesAll(t, s, ctx))
+}
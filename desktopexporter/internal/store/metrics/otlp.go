@@ -0,0 +1,320 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/rebuild"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// otlpDocument is what export_metrics.sql returns.
+type otlpDocument struct {
+	Resources map[string]rebuild.Resource `json:"resources"`
+	Scopes    map[string]rebuild.Scope    `json:"scopes"`
+	StreamIDs []string                    `json:"streamIDs"`
+	Metrics   []otlpMetric                `json:"metrics"`
+}
+
+type otlpMetric struct {
+	R                      int64               `json:"r"`
+	S                      int64               `json:"s"`
+	ResourceSchemaURL      string              `json:"resourceSchemaURL"`
+	ScopeSchemaURL         string              `json:"scopeSchemaURL"`
+	Name                   string              `json:"name"`
+	Unit                   string              `json:"unit"`
+	Description            string              `json:"description"`
+	Metadata               []rebuild.Attribute `json:"metadata"`
+	MetricType             string              `json:"metricType"`
+	AggregationTemporality string              `json:"aggregationTemporality"`
+	IsMonotonic            bool                `json:"isMonotonic"`
+	Datapoints             []otlpDatapoint     `json:"datapoints"`
+}
+
+// otlpDatapoint carries every representation's columns; metricType says which
+// are meaningful. Nulls decode as zero values.
+type otlpDatapoint struct {
+	Timestamp      string              `json:"timestamp"`
+	StartTime      string              `json:"startTime"`
+	Flags          uint32              `json:"flags"`
+	Attributes     []rebuild.Attribute `json:"attributes"`
+	ValueType      string              `json:"valueType"`
+	IntValue       int64               `json:"intValue"`
+	DoubleValue    float64             `json:"doubleValue"`
+	Count          uint64              `json:"count"`
+	Sum            float64             `json:"sum"`
+	Min            float64             `json:"min"`
+	Max            float64             `json:"max"`
+	BucketCounts   []uint64            `json:"bucketCounts"`
+	ExplicitBounds []float64           `json:"explicitBounds"`
+	Scale          int32               `json:"scale"`
+	ZeroCount      uint64              `json:"zeroCount"`
+	ZeroThreshold  float64             `json:"zeroThreshold"`
+	PositiveOffset int32               `json:"positiveOffset"`
+	PositiveCounts []uint64            `json:"positiveCounts"`
+	NegativeOffset int32               `json:"negativeOffset"`
+	NegativeCounts []uint64            `json:"negativeCounts"`
+	QuantileValues []quantileValue     `json:"quantileValues"`
+	Exemplars      []otlpExemplar      `json:"exemplars"`
+}
+
+// otlpExemplar is exemplar_json.
+type otlpExemplar struct {
+	Timestamp          string              `json:"timestamp"`
+	Value              float64             `json:"value"`
+	TraceID            string              `json:"traceID"`
+	SpanID             string              `json:"spanID"`
+	FilteredAttributes []rebuild.Attribute `json:"filteredAttributes"`
+}
+
+// ExportOTLP rebuilds the given streams as pdata, keeping the datapoints whose
+// timestamp falls in [startTime, endTime]. streamIDs are the ids
+// SearchSummaries returns; one the store does not hold is ErrStreamIDNotFound.
+// A stream with nothing in the window is found but exports no Metric.
+//
+// Datapoints that arrived in separate batches are regrouped into one Metric
+// wherever the batches agree on resource, scope, description and metadata.
+// Beyond what spans.ExportOTLP already cannot carry, the datapoint columns
+// lose two things: whether a histogram's optional sum, min and max were set
+// (see setOptional), and an exemplar's integer value, which ingest keeps only
+// as a double.
+func ExportOTLP(ctx context.Context, db *sql.DB, streamIDs []string, startTime, endTime int64) (pmetric.Metrics, error) {
+	md := pmetric.NewMetrics()
+	query, err := queries.Render(queries.ExportMetrics, nil)
+	if err != nil {
+		return md, fmt.Errorf("ExportOTLP: %w: %w", ErrMetricsStoreInternal, err)
+	}
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, startTime, endTime, streamIDs).Scan(&raw); err != nil {
+		return md, fmt.Errorf("ExportOTLP: %w: %w", ErrMetricsStoreInternal, err)
+	}
+	var doc otlpDocument
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return md, fmt.Errorf("ExportOTLP: %w: %w", ErrMetricsStoreInternal, err)
+	}
+	found := make(map[string]bool, len(doc.StreamIDs))
+	for _, id := range doc.StreamIDs {
+		found[id] = true
+	}
+	for _, id := range streamIDs {
+		if !found[id] {
+			return md, fmt.Errorf("ExportOTLP: %w: %s", ErrStreamIDNotFound, id)
+		}
+	}
+
+	var rm pmetric.ResourceMetrics
+	var sm pmetric.ScopeMetrics
+	var cur rebuild.Batch
+	for i, row := range doc.Metrics {
+		batch := rebuild.Batch{
+			Resource:          strconv.FormatInt(row.R, 10),
+			ResourceSchemaURL: row.ResourceSchemaURL,
+			Scope:             strconv.FormatInt(row.S, 10),
+			ScopeSchemaURL:    row.ScopeSchemaURL,
+		}
+		if i == 0 || !batch.SameResource(cur) {
+			rm = md.ResourceMetrics().AppendEmpty()
+			doc.Resources[batch.Resource].CopyTo(rm.Resource())
+			rm.SetSchemaUrl(batch.ResourceSchemaURL)
+		}
+		if i == 0 || batch != cur {
+			sm = rm.ScopeMetrics().AppendEmpty()
+			doc.Scopes[batch.Scope].CopyTo(sm.Scope())
+			sm.SetSchemaUrl(batch.ScopeSchemaURL)
+		}
+		cur = batch
+		if err := row.copyTo(sm.Metrics().AppendEmpty()); err != nil {
+			return md, fmt.Errorf("ExportOTLP: %w: %w", ErrMetricsStoreInternal, err)
+		}
+	}
+	return md, nil
+}
+
+func (row otlpMetric) copyTo(m pmetric.Metric) error {
+	m.SetName(row.Name)
+	m.SetUnit(row.Unit)
+	m.SetDescription(row.Description)
+	rebuild.Attributes(m.Metadata(), row.Metadata)
+	temporality := aggregationTemporality(row.AggregationTemporality)
+
+	switch row.MetricType {
+	case pmetric.MetricTypeGauge.String():
+		dps := m.SetEmptyGauge().DataPoints()
+		for _, p := range row.Datapoints {
+			if err := p.copyToNumber(dps.AppendEmpty()); err != nil {
+				return err
+			}
+		}
+	case pmetric.MetricTypeSum.String():
+		sum := m.SetEmptySum()
+		sum.SetAggregationTemporality(temporality)
+		sum.SetIsMonotonic(row.IsMonotonic)
+		for _, p := range row.Datapoints {
+			if err := p.copyToNumber(sum.DataPoints().AppendEmpty()); err != nil {
+				return err
+			}
+		}
+	case pmetric.MetricTypeHistogram.String():
+		hist := m.SetEmptyHistogram()
+		hist.SetAggregationTemporality(temporality)
+		for _, p := range row.Datapoints {
+			if err := p.copyToHistogram(hist.DataPoints().AppendEmpty()); err != nil {
+				return err
+			}
+		}
+	case pmetric.MetricTypeExponentialHistogram.String():
+		hist := m.SetEmptyExponentialHistogram()
+		hist.SetAggregationTemporality(temporality)
+		for _, p := range row.Datapoints {
+			if err := p.copyToExponentialHistogram(hist.DataPoints().AppendEmpty()); err != nil {
+				return err
+			}
+		}
+	case pmetric.MetricTypeSummary.String():
+		dps := m.SetEmptySummary().DataPoints()
+		for _, p := range row.Datapoints {
+			if err := p.copyToSummary(dps.AppendEmpty()); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown metric type %q", row.MetricType)
+	}
+	return nil
+}
+
+// dataPoint is what every datapoint type has in common.
+type dataPoint interface {
+	SetTimestamp(pcommon.Timestamp)
+	SetStartTimestamp(pcommon.Timestamp)
+	SetFlags(pmetric.DataPointFlags)
+	Attributes() pcommon.Map
+}
+
+func (p otlpDatapoint) copyCommon(dp dataPoint) error {
+	ts, err := rebuild.Timestamp(p.Timestamp)
+	if err != nil {
+		return err
+	}
+	start, err := rebuild.Timestamp(p.StartTime)
+	if err != nil {
+		return err
+	}
+	dp.SetTimestamp(ts)
+	dp.SetStartTimestamp(start)
+	dp.SetFlags(pmetric.DataPointFlags(p.Flags))
+	rebuild.Attributes(dp.Attributes(), p.Attributes)
+	return nil
+}
+
+func (p otlpDatapoint) copyToNumber(dp pmetric.NumberDataPoint) error {
+	if err := p.copyCommon(dp); err != nil {
+		return err
+	}
+	switch p.ValueType {
+	case pmetric.NumberDataPointValueTypeInt.String():
+		dp.SetIntValue(p.IntValue)
+	case pmetric.NumberDataPointValueTypeDouble.String():
+		dp.SetDoubleValue(p.DoubleValue)
+	}
+	return copyExemplars(dp.Exemplars(), p.Exemplars)
+}
+
+func (p otlpDatapoint) copyToHistogram(dp pmetric.HistogramDataPoint) error {
+	if err := p.copyCommon(dp); err != nil {
+		return err
+	}
+	dp.SetCount(p.Count)
+	dp.BucketCounts().FromRaw(p.BucketCounts)
+	dp.ExplicitBounds().FromRaw(p.ExplicitBounds)
+	setOptional(p, dp.SetSum, dp.SetMin, dp.SetMax)
+	return copyExemplars(dp.Exemplars(), p.Exemplars)
+}
+
+func (p otlpDatapoint) copyToExponentialHistogram(dp pmetric.ExponentialHistogramDataPoint) error {
+	if err := p.copyCommon(dp); err != nil {
+		return err
+	}
+	dp.SetCount(p.Count)
+	dp.SetScale(p.Scale)
+	dp.SetZeroCount(p.ZeroCount)
+	dp.SetZeroThreshold(p.ZeroThreshold)
+	dp.Positive().SetOffset(p.PositiveOffset)
+	dp.Positive().BucketCounts().FromRaw(p.PositiveCounts)
+	dp.Negative().SetOffset(p.NegativeOffset)
+	dp.Negative().BucketCounts().FromRaw(p.NegativeCounts)
+	setOptional(p, dp.SetSum, dp.SetMin, dp.SetMax)
+	return copyExemplars(dp.Exemplars(), p.Exemplars)
+}
+
+func (p otlpDatapoint) copyToSummary(dp pmetric.SummaryDataPoint) error {
+	if err := p.copyCommon(dp); err != nil {
+		return err
+	}
+	dp.SetCount(p.Count)
+	dp.SetSum(p.Sum)
+	for _, q := range p.QuantileValues {
+		qv := dp.QuantileValues().AppendEmpty()
+		qv.SetQuantile(q.Quantile)
+		qv.SetValue(q.Value)
+	}
+	return nil
+}
+
+// setOptional sets a histogram's sum, min and max, which OTLP lets a sender
+// leave out. Ingest stores the getter's zero for an absent one, so presence
+// is inferred: a sum is there unless both it and the count are zero, since a
+// histogram with observations and a zero sum is rare while an empty one
+// reporting a sum is not; min and max are there unless both are zero.
+func setOptional(p otlpDatapoint, setSum, setMin, setMax func(float64)) {
+	if p.Count != 0 || p.Sum != 0 {
+		setSum(p.Sum)
+	}
+	if p.Min != 0 || p.Max != 0 {
+		setMin(p.Min)
+		setMax(p.Max)
+	}
+}
+
+func copyExemplars(dest pmetric.ExemplarSlice, exemplars []otlpExemplar) error {
+	for _, e := range exemplars {
+		ts, err := rebuild.Timestamp(e.Timestamp)
+		if err != nil {
+			return err
+		}
+		traceID, err := rebuild.TraceID(e.TraceID)
+		if err != nil {
+			return err
+		}
+		spanID, err := rebuild.SpanID(e.SpanID)
+		if err != nil {
+			return err
+		}
+		ex := dest.AppendEmpty()
+		ex.SetTimestamp(ts)
+		ex.SetDoubleValue(e.Value)
+		ex.SetTraceID(traceID)
+		ex.SetSpanID(spanID)
+		rebuild.Attributes(ex.FilteredAttributes(), e.FilteredAttributes)
+	}
+	return nil
+}
+
+// aggregationTemporality reads back the String() form ingest stored on the
+// stream. Gauges and Summaries store none, which reads as Unspecified.
+func aggregationTemporality(s string) pmetric.AggregationTemporality {
+	for _, t := range []pmetric.AggregationTemporality{
+		pmetric.AggregationTemporalityDelta,
+		pmetric.AggregationTemporalityCumulative,
+	} {
+		if t.String() == s {
+			return t
+		}
+	}
+	return pmetric.AggregationTemporalityUnspecified
+}
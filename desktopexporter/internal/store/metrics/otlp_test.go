@@ -0,0 +1,219 @@
+package metrics_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/metrics"
+)
+
+const otlpFixtureBase = int64(1_700_000_000_000_000_000)
+
+// otlpFixtureMetrics is one export request carrying one metric of each type,
+// named so that they sort in the order they are appended, with one datapoint
+// per entry in points -- each an index into a timeline a second apart. Every
+// field the store keeps is set to something other than its zero value.
+func otlpFixtureMetrics(md pmetric.Metrics, points ...int) {
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.SetSchemaUrl("https://opentelemetry.io/schemas/1.27.0")
+	rm.Resource().Attributes().PutStr("service.name", "checkout")
+	rm.Resource().SetDroppedAttributesCount(1)
+
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.SetSchemaUrl("https://opentelemetry.io/schemas/1.26.0")
+	sm.Scope().SetName("io.opentelemetry.runtime")
+	sm.Scope().SetVersion("2.0.0")
+	sm.Scope().SetDroppedAttributesCount(2)
+
+	metric := func(name string) pmetric.Metric {
+		m := sm.Metrics().AppendEmpty()
+		m.SetName(name)
+		m.SetUnit("ms")
+		m.SetDescription(name + " description")
+		m.Metadata().PutStr("origin", "sdk")
+		return m
+	}
+	gauge := metric("a.gauge").SetEmptyGauge()
+	sum := metric("b.sum").SetEmptySum()
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	sum.SetIsMonotonic(true)
+	hist := metric("c.histogram").SetEmptyHistogram()
+	hist.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	expHist := metric("d.exponential").SetEmptyExponentialHistogram()
+	expHist.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	summary := metric("e.summary").SetEmptySummary()
+
+	for _, i := range points {
+		ts := pcommon.Timestamp(otlpFixtureBase + int64(i)*1_000_000_000)
+		start := pcommon.Timestamp(otlpFixtureBase - 1_000_000_000)
+		common := func(attrs pcommon.Map, setTimes func(pcommon.Timestamp, pcommon.Timestamp)) {
+			setTimes(start, ts)
+			attrs.PutStr("host", "a")
+		}
+		exemplar := func(exemplars pmetric.ExemplarSlice) {
+			ex := exemplars.AppendEmpty()
+			ex.SetTimestamp(ts - 5)
+			ex.SetDoubleValue(1.5)
+			ex.SetTraceID(pcommon.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+			ex.SetSpanID(pcommon.SpanID{0, 0, 0, 0, 0, 0, 0, 1})
+			ex.FilteredAttributes().PutStr("user", "ada")
+		}
+
+		g := gauge.DataPoints().AppendEmpty()
+		common(g.Attributes(), func(s, t pcommon.Timestamp) { g.SetStartTimestamp(s); g.SetTimestamp(t) })
+		g.SetDoubleValue(0.5 + float64(i))
+		g.SetFlags(pmetric.DefaultDataPointFlags.WithNoRecordedValue(true))
+		exemplar(g.Exemplars())
+
+		s := sum.DataPoints().AppendEmpty()
+		common(s.Attributes(), func(st, t pcommon.Timestamp) { s.SetStartTimestamp(st); s.SetTimestamp(t) })
+		s.SetIntValue(int64(10 + i))
+
+		h := hist.DataPoints().AppendEmpty()
+		common(h.Attributes(), func(s, t pcommon.Timestamp) { h.SetStartTimestamp(s); h.SetTimestamp(t) })
+		h.SetCount(6)
+		h.SetSum(42.5)
+		h.SetMin(0.5)
+		h.SetMax(20)
+		h.BucketCounts().FromRaw([]uint64{1, 2, 3})
+		h.ExplicitBounds().FromRaw([]float64{1, 10})
+		exemplar(h.Exemplars())
+
+		e := expHist.DataPoints().AppendEmpty()
+		common(e.Attributes(), func(s, t pcommon.Timestamp) { e.SetStartTimestamp(s); e.SetTimestamp(t) })
+		e.SetCount(7)
+		e.SetSum(-3)
+		e.SetMin(-2)
+		e.SetMax(4)
+		e.SetScale(3)
+		e.SetZeroCount(1)
+		e.SetZeroThreshold(math.SmallestNonzeroFloat64)
+		e.Positive().SetOffset(-2)
+		e.Positive().BucketCounts().FromRaw([]uint64{1, 2})
+		e.Negative().SetOffset(4)
+		e.Negative().BucketCounts().FromRaw([]uint64{3})
+
+		q := summary.DataPoints().AppendEmpty()
+		common(q.Attributes(), func(s, t pcommon.Timestamp) { q.SetStartTimestamp(s); q.SetTimestamp(t) })
+		q.SetCount(3)
+		q.SetSum(9)
+		for _, quantile := range []float64{0.5, 0.99} {
+			qv := q.QuantileValues().AppendEmpty()
+			qv.SetQuantile(quantile)
+			qv.SetValue(quantile * 10)
+		}
+	}
+}
+
+func ingestMetrics(t *testing.T, s *store.Store, md pmetric.Metrics) {
+	t.Helper()
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return metrics.Ingest(context.Background(), conn, md, s.FlushedIDs())
+	}))
+}
+
+func streamIDs(t *testing.T, s *store.Store, names ...string) []string {
+	t.Helper()
+	var ids []string
+	for _, name := range names {
+		id, err := readStore(s, func(db *sql.DB) (string, error) {
+			var id string
+			err := db.QueryRow(`select id::varchar from metric_streams where name = ?`, name).Scan(&id)
+			return id, err
+		})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func exportMetrics(s *store.Store, ids []string, start, end int64) (pmetric.Metrics, error) {
+	return readStore(s, func(db *sql.DB) (pmetric.Metrics, error) {
+		return metrics.ExportOTLP(context.Background(), db, ids, start, end)
+	})
+}
+
+func assertMetricsJSONEq(t *testing.T, want, got pmetric.Metrics) {
+	t.Helper()
+	marshaler := &pmetric.JSONMarshaler{}
+	wantJSON, err := marshaler.MarshalMetrics(want)
+	require.NoError(t, err)
+	gotJSON, err := marshaler.MarshalMetrics(got)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(wantJSON), string(gotJSON))
+}
+
+// Streams exported from the store are the metrics that were sent, with the
+// datapoints of successive batches gathered back into one Metric each.
+func TestExportOTLPRoundTrip(t *testing.T) {
+	s, _, teardown := setupStore(t)
+	defer teardown()
+
+	first := pmetric.NewMetrics()
+	otlpFixtureMetrics(first, 0)
+	second := pmetric.NewMetrics()
+	otlpFixtureMetrics(second, 1)
+	ingestMetrics(t, s, first)
+	ingestMetrics(t, s, second)
+
+	// Noise that must not come along.
+	other := pmetric.NewMetrics()
+	other.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().
+		SetName("z.noise")
+	other.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).SetEmptyGauge().
+		DataPoints().AppendEmpty().SetIntValue(1)
+	ingestMetrics(t, s, other)
+
+	want := pmetric.NewMetrics()
+	otlpFixtureMetrics(want, 0, 1)
+
+	ids := streamIDs(t, s, "a.gauge", "b.sum", "c.histogram", "d.exponential", "e.summary")
+	got, err := exportMetrics(s, ids, 0, math.MaxInt64)
+	require.NoError(t, err)
+	assertMetricsJSONEq(t, want, got)
+}
+
+// The window bounds datapoints, not streams: a stream with nothing inside it
+// is found and simply exports nothing.
+func TestExportOTLPWindow(t *testing.T) {
+	s, _, teardown := setupStore(t)
+	defer teardown()
+
+	md := pmetric.NewMetrics()
+	otlpFixtureMetrics(md, 0, 1, 2)
+	ingestMetrics(t, s, md)
+
+	want := pmetric.NewMetrics()
+	otlpFixtureMetrics(want, 1)
+
+	ids := streamIDs(t, s, "a.gauge", "b.sum", "c.histogram", "d.exponential", "e.summary")
+	got, err := exportMetrics(s, ids, otlpFixtureBase+500_000_000, otlpFixtureBase+1_500_000_000)
+	require.NoError(t, err)
+	assertMetricsJSONEq(t, want, got)
+
+	got, err = exportMetrics(s, ids, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, got.ResourceMetrics().Len())
+}
+
+func TestExportOTLPUnknownStream(t *testing.T) {
+	s, _, teardown := setupStore(t)
+	defer teardown()
+
+	md := pmetric.NewMetrics()
+	otlpFixtureMetrics(md, 0)
+	ingestMetrics(t, s, md)
+
+	ids := append(streamIDs(t, s, "a.gauge"), "00000000-0000-0000-0000-000000000001")
+	_, err := exportMetrics(s, ids, 0, math.MaxInt64)
+	assert.ErrorIs(t, err, metrics.ErrStreamIDNotFound)
+}
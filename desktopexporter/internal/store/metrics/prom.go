@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/rebuild"
+)
+
+// PromSeries is one stored series as the Prometheus view reads it: the
+// stream's identity, and the attributes its labels are made from.
+//
+// It is a series in the store's sense -- one instrument, one resource, one
+// label set -- which the promql package fans out into however many
+// Prometheus series that instrument's type becomes.
+type PromSeries struct {
+	ID          string              `json:"id"`
+	Name        string              `json:"name"`
+	Unit        string              `json:"unit"`
+	MetricType  string              `json:"metricType"`
+	Temporality string              `json:"temporality"`
+	IsMonotonic bool                `json:"isMonotonic"`
+	Attributes  []rebuild.Attribute `json:"attributes"`
+	Resource    []rebuild.Attribute `json:"resource"`
+	// Bounds is every explicit bucket bound a histogram series has used,
+	// ascending. Empty for every other type.
+	Bounds []float64 `json:"bounds"`
+	// Quantiles is every quantile a summary series has reported, ascending.
+	Quantiles []float64 `json:"quantiles"`
+}
+
+// PromPoint is one datapoint with each representation's columns; the
+// series' MetricType says which are set.
+type PromPoint struct {
+	SeriesID     string         `json:"seriesID"`
+	Timestamp    int64          `json:"timestamp,string"`
+	Value        float64        `json:"value"`
+	Count        uint64         `json:"count"`
+	Sum          float64        `json:"sum"`
+	BucketCounts []uint64       `json:"bucketCounts"`
+	Bounds       []float64      `json:"bounds"`
+	Quantiles    []PromQuantile `json:"quantiles"`
+}
+
+// PromQuantile is one of a Summary's reported quantiles.
+type PromQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// ListPromSeries returns every series with a datapoint in [startTime,
+// endTime], ordered by metric name.
+func ListPromSeries(ctx context.Context, db *sql.DB, startTime, endTime int64) ([]PromSeries, error) {
+	var out []PromSeries
+	if err := queryPromJSON(ctx, db, queries.PromSeries, &out, startTime, endTime); err != nil {
+		return nil, fmt.Errorf("ListPromSeries: %w: %w", ErrMetricsStoreInternal, err)
+	}
+	return out, nil
+}
+
+// PromPoints returns the datapoints of the given series in [startTime,
+// endTime], by series and then time. A delta series also returns its points
+// before startTime, so a caller accumulating it into a running total starts
+// from the series' first point rather than from the window's edge.
+func PromPoints(ctx context.Context, db *sql.DB, seriesIDs []string, startTime, endTime int64) ([]PromPoint, error) {
+	var out []PromPoint
+	if err := queryPromJSON(ctx, db, queries.PromPoints, &out, startTime, endTime, seriesIDs); err != nil {
+		return nil, fmt.Errorf("PromPoints: %w: %w", ErrMetricsStoreInternal, err)
+	}
+	return out, nil
+}
+
+func queryPromJSON(ctx context.Context, db *sql.DB, name queries.Name, dest any, args ...any) error {
+	query, err := queries.Render(name, nil)
+	if err != nil {
+		return err
+	}
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&raw); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, dest)
+}
@@ -0,0 +1,111 @@
+package metrics_test
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/metrics"
+)
+
+// Series are listed by whether they have a point in the window, with what
+// their Prometheus names and labels are made from.
+func TestListPromSeries(t *testing.T) {
+	s, _, teardown := setupStore(t)
+	defer teardown()
+
+	md := pmetric.NewMetrics()
+	otlpFixtureMetrics(md, 0, 1, 2)
+	ingestMetrics(t, s, md)
+
+	all, err := readStore(s, func(db *sql.DB) ([]metrics.PromSeries, error) {
+		return metrics.ListPromSeries(context.Background(), db, 0, math.MaxInt64)
+	})
+	require.NoError(t, err)
+	require.Len(t, all, 5)
+
+	byName := map[string]metrics.PromSeries{}
+	for _, ps := range all {
+		byName[ps.Name] = ps
+	}
+	sum := byName["b.sum"]
+	assert.Equal(t, "Sum", sum.MetricType)
+	assert.Equal(t, "Cumulative", sum.Temporality)
+	assert.True(t, sum.IsMonotonic)
+	assert.Equal(t, "ms", sum.Unit)
+	require.Len(t, sum.Attributes, 1)
+	assert.Equal(t, "host", sum.Attributes[0].Key)
+	assert.Equal(t, "a", sum.Attributes[0].Value)
+	var service string
+	for _, a := range sum.Resource {
+		if a.Key == "service.name" {
+			service = a.Value
+		}
+	}
+	assert.Equal(t, "checkout", service)
+
+	assert.Equal(t, []float64{1, 10}, byName["c.histogram"].Bounds)
+	assert.Empty(t, byName["c.histogram"].Quantiles)
+	assert.Equal(t, []float64{0.5, 0.99}, byName["e.summary"].Quantiles)
+	assert.Empty(t, byName["e.summary"].Bounds)
+
+	// A window after the last point has no series in it.
+	none, err := readStore(s, func(db *sql.DB) ([]metrics.PromSeries, error) {
+		return metrics.ListPromSeries(context.Background(), db, otlpFixtureBase+3_000_000_000, math.MaxInt64)
+	})
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+// Points come back by series and time, with every representation's columns.
+// A delta series' points before the window come back too; a cumulative
+// series' do not.
+func TestPromPoints(t *testing.T) {
+	s, _, teardown := setupStore(t)
+	defer teardown()
+
+	md := pmetric.NewMetrics()
+	otlpFixtureMetrics(md, 0, 1, 2)
+	ingestMetrics(t, s, md)
+
+	all, err := readStore(s, func(db *sql.DB) ([]metrics.PromSeries, error) {
+		return metrics.ListPromSeries(context.Background(), db, 0, math.MaxInt64)
+	})
+	require.NoError(t, err)
+	ids := map[string]string{}
+	for _, ps := range all {
+		ids[ps.Name] = ps.ID
+	}
+
+	points := func(name string, start int64) []metrics.PromPoint {
+		t.Helper()
+		out, err := readStore(s, func(db *sql.DB) ([]metrics.PromPoint, error) {
+			return metrics.PromPoints(context.Background(), db, []string{ids[name]}, start, math.MaxInt64)
+		})
+		require.NoError(t, err)
+		return out
+	}
+
+	sums := points("b.sum", otlpFixtureBase+1)
+	require.Len(t, sums, 2)
+	assert.Equal(t, otlpFixtureBase+1_000_000_000, sums[0].Timestamp)
+	assert.Equal(t, 11.0, sums[0].Value)
+	assert.Equal(t, 12.0, sums[1].Value)
+
+	hists := points("c.histogram", otlpFixtureBase+1)
+	require.Len(t, hists, 3, "delta points before the window are included")
+	assert.Equal(t, otlpFixtureBase, hists[0].Timestamp)
+	assert.Equal(t, uint64(6), hists[0].Count)
+	assert.Equal(t, 42.5, hists[0].Sum)
+	assert.Equal(t, []uint64{1, 2, 3}, hists[0].BucketCounts)
+	assert.Equal(t, []float64{1, 10}, hists[0].Bounds)
+
+	summaries := points("e.summary", 0)
+	require.Len(t, summaries, 3)
+	assert.Equal(t, []metrics.PromQuantile{{Quantile: 0.5, Value: 5}, {Quantile: 0.99, Value: 9.9}}, summaries[0].Quantiles)
+}
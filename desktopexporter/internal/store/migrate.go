@@ -0,0 +1,368 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/ingest"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/schema"
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// oldestMigratable is the earliest schema version a migration chain starts
+// from. Version 1 was the owner-keyed attributes schema: the dictionary that
+// replaced it is not a reshaping of its tables but a different model, and
+// rebuilding one from the other would mean re-ingesting telemetry the file
+// only holds in the old form. Files that old are still refused.
+const oldestMigratable = 2
+
+// migrationPrep computes, for the migration producing a version, what that
+// migration's SQL cannot: it runs in the same transaction, just before the
+// SQL, and leaves its results in temp tables the SQL reads.
+var migrationPrep = map[int]func(context.Context, *sql.Tx) error{
+	5: keyHistogramBounds,
+}
+
+// canMigrate reports whether a file stamped fileVersion has a chain of
+// migrations to this build's version.
+func canMigrate(fileVersion int64) bool {
+	return fileVersion >= oldestMigratable && fileVersion < schema.Version
+}
+
+// migrate brings a database stamped from up to schema.Version, taking a backup
+// first when the database is a file.
+//
+// Every step runs in one transaction on one connection, so a failure anywhere
+// leaves the file exactly as it was -- stamp included -- and the backup is an
+// extra, not the recovery plan.
+//
+// The steps do not alter the tables in place. DuckDB will not drop a column
+// an index covers, nor drop or rewrite a table another references, and
+// ingest's appenders are positional, so a column added by ALTER at the end of
+// a table would be in the wrong place for them anyway. Instead:
+//
+//  1. copy every table into a temporary, unconstrained working copy, with
+//     enum columns as varchar so the enum itself can be replaced;
+//  2. run each numbered migration against the working copies;
+//  3. drop the real tables and types and create them from this build's DDL,
+//     then copy the working copies back by column name.
+//
+// Step 3 is what makes the result this build's schema rather than an older
+// schema edited towards it: column order, constraints and defaults all come
+// from ddl/, exactly as for a fresh file. Indexes and macros are left to
+// createSchema, which builds them next.
+func migrate(ctx context.Context, db *sql.DB, src source, from int64, logger *zap.Logger) error {
+	var backup string
+	if src.path != "" && !src.bundle {
+		var err error
+		if backup, err = backupDatabase(ctx, db, src.path, from); err != nil {
+			return fmt.Errorf("%w while backing up %s before migrating it: %w", ErrStoreInitFailed, src, err)
+		}
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStoreInitFailed, err)
+	}
+	defer conn.Close()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStoreInitFailed, err)
+	}
+	defer tx.Rollback()
+
+	if err := runMigrations(ctx, tx, from); err != nil {
+		return fmt.Errorf("%w while migrating %s from schema version %d to %d: %w",
+			ErrStoreInitFailed, src, from, schema.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w while committing the migration of %s: %w", ErrStoreInitFailed, src, err)
+	}
+
+	fields := []zap.Field{
+		zap.String("database", src.String()),
+		zap.Int64("from_version", from),
+		zap.Int("to_version", schema.Version),
+	}
+	if backup != "" {
+		fields = append(fields, zap.String("backup", backup))
+	}
+	logger.Info("migrated database to this build's schema version", fields...)
+	return nil
+}
+
+func runMigrations(ctx context.Context, tx *sql.Tx, from int64) error {
+	var catalog string
+	if err := tx.QueryRowContext(ctx, `select current_database()`).Scan(&catalog); err != nil {
+		return err
+	}
+	// Fully qualified, because `main` alone is ambiguous once the working
+	// copies exist: the temp catalog's schema is called main too, and
+	// DuckDB resolves main.spans to the copy.
+	qualified := func(table string) string {
+		return fmt.Sprintf("%q.main.%q", catalog, table)
+	}
+
+	tables, err := stringColumn(ctx, tx, `select table_name from duckdb_tables()
+		where database_name = ? and schema_name = 'main' and not temporary and table_name <> 'schema_meta'`, catalog)
+	if err != nil {
+		return fmt.Errorf("listing tables: %w", err)
+	}
+
+	// 1) Working copies.
+	for _, t := range tables {
+		enums, err := stringColumn(ctx, tx, `select column_name from duckdb_columns()
+			where database_name = ? and schema_name = 'main' and table_name = ? and data_type like 'ENUM(%'`,
+			catalog, t)
+		if err != nil {
+			return fmt.Errorf("reading columns of %s: %w", t, err)
+		}
+		sel := "*"
+		if len(enums) > 0 {
+			casts := make([]string, len(enums))
+			for i, c := range enums {
+				casts[i] = fmt.Sprintf("%q::varchar as %q", c, c)
+			}
+			sel = "* replace (" + strings.Join(casts, ", ") + ")"
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`create temp table %q as select %s from %s`, t, sel, qualified(t))); err != nil {
+			return fmt.Errorf("copying %s: %w", t, err)
+		}
+	}
+
+	// 2) The numbered steps.
+	for _, m := range queries.Migrations() {
+		if int64(m.Version) <= from || m.Version > schema.Version {
+			continue
+		}
+		if prep, ok := migrationPrep[m.Version]; ok {
+			if err := prep(ctx, tx); err != nil {
+				return fmt.Errorf("preparing %s: %w", m.Name, err)
+			}
+		}
+		// A step with nothing for SQL to do is all comment, which DuckDB
+		// rejects as an empty query rather than running as nothing.
+		if !hasStatements(m.SQL) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+			return fmt.Errorf("%s: %w", m.Name, err)
+		}
+	}
+
+	// 3) Rebuild from this build's DDL.
+	order := make([]string, 0, len(queries.Tables()))
+	for _, stmt := range queries.Tables() {
+		order = append(order, ddlObjectName(stmt))
+	}
+	copies, err := stringColumn(ctx, tx, `select table_name from duckdb_tables() where temporary`)
+	if err != nil {
+		return fmt.Errorf("listing working copies: %w", err)
+	}
+	for _, t := range copies {
+		if !slices.Contains(order, t) {
+			return fmt.Errorf("the migrations left %s, which this build's schema has no table for", t)
+		}
+		// Out of the way of the real names: DuckDB resolves a foreign key's
+		// target the way it resolves any name, temp first, and refuses one
+		// that would cross into temp.
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`alter table temp.main.%q rename to %q`, t, workingCopy(t))); err != nil {
+			return fmt.Errorf("setting aside the working copy of %s: %w", t, err)
+		}
+	}
+
+	// Children go before parents, so no drop is blocked by a foreign key
+	// into it.
+	for _, t := range dropOrder(tables, order) {
+		if _, err := tx.ExecContext(ctx, `drop table `+qualified(t)); err != nil {
+			return fmt.Errorf("dropping %s: %w", t, err)
+		}
+	}
+	for _, stmt := range queries.Types() {
+		if _, err := tx.ExecContext(ctx, `drop type if exists `+qualified(ddlObjectName(stmt))); err != nil {
+			return fmt.Errorf("dropping %s: %w", stmt.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, stmt.SQL); err != nil {
+			return fmt.Errorf("creating %s: %w", stmt.Name, err)
+		}
+	}
+	for _, stmt := range queries.Tables() {
+		if _, err := tx.ExecContext(ctx, stmt.SQL); err != nil {
+			return fmt.Errorf("creating %s: %w", stmt.Name, err)
+		}
+	}
+
+	for _, t := range order {
+		if !slices.Contains(copies, t) {
+			continue // new in this build, and empty
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`insert into %s by name select * from temp.main.%q`,
+			qualified(t), workingCopy(t))); err != nil {
+			return fmt.Errorf("copying %s back: %w", t, err)
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`drop table temp.main.%q`, workingCopy(t))); err != nil {
+			return fmt.Errorf("dropping the working copy of %s: %w", t, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `delete from `+qualified("schema_meta")); err != nil {
+		return fmt.Errorf("clearing the old stamp: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, schema.StampVersionQuery, schema.Version); err != nil {
+		return fmt.Errorf("stamping: %w", err)
+	}
+	return nil
+}
+
+func workingCopy(table string) string { return "migrating_" + table }
+
+// dropOrder is tables in reverse creation order, then anything the manifest
+// does not name -- a table some older build had and this one does not.
+func dropOrder(tables, creation []string) []string {
+	out := make([]string, 0, len(tables))
+	for _, t := range slices.Backward(creation) {
+		if slices.Contains(tables, t) {
+			out = append(out, t)
+		}
+	}
+	for _, t := range tables {
+		if !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// ddlObjectName is the object a DDL file creates, which the files are named
+// after: "tables/spans.sql" creates spans.
+func ddlObjectName(stmt queries.Statement) string {
+	_, file, _ := strings.Cut(stmt.Name, "/")
+	return strings.TrimSuffix(file, ".sql")
+}
+
+// hasStatements reports whether sql has anything besides -- comments and
+// whitespace.
+func hasStatements(sql string) bool {
+	for _, line := range strings.Split(sql, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
+
+func stringColumn(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// keyHistogramBounds fills bounds_rekey (n, bounds, id) for
+// 005_histogram_bounds.sql: one row per distinct explicit_bounds vector, with
+// the id ingest.BoundsID gives it. BoundsID hashes IEEE-754 bits, so the id is
+// computed here, from the vector as DuckDB hands it back, rather than from
+// any text rendering of it in SQL.
+func keyHistogramBounds(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `create temp table bounds_rekey as
+		select row_number() over () as n, bounds, null::uuid as id
+		from (select distinct explicit_bounds as bounds from datapoints where explicit_bounds is not null)`); err != nil {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, `select n, bounds from bounds_rekey`)
+	if err != nil {
+		return err
+	}
+	ids := make(map[int64]duckdb.UUID)
+	for rows.Next() {
+		var n int64
+		var list []any
+		if err := rows.Scan(&n, &list); err != nil {
+			rows.Close()
+			return err
+		}
+		bounds := make([]float64, len(list))
+		for i, v := range list {
+			f, ok := v.(float64)
+			if !ok {
+				rows.Close()
+				return fmt.Errorf("explicit_bounds holds a %T, not a double", v)
+			}
+			bounds[i] = f
+		}
+		ids[n] = ingest.BoundsID(bounds)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for n, id := range ids {
+		if _, err := tx.ExecContext(ctx, `update bounds_rekey set id = ?::uuid where n = ?`,
+			uuid.UUID(id).String(), n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupDatabase copies the database file to path.v<version>.bak before a
+// migration rewrites it, adding a counter rather than overwriting an earlier
+// backup. The checkpoint first folds the write-ahead log into the file, so
+// the copy is the whole database and not just what had been checkpointed.
+func backupDatabase(ctx context.Context, db *sql.DB, path string, version int64) (string, error) {
+	if _, err := db.ExecContext(ctx, `force checkpoint`); err != nil {
+		return "", err
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s.v%d.bak", path, version)
+		if i > 1 {
+			name = fmt.Sprintf("%s.v%d.%d.bak", path, version, i)
+		}
+		out, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(out, in)
+		if err == nil {
+			err = out.Sync()
+		}
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(name)
+			return "", err
+		}
+		return name, nil
+	}
+}
@@ -0,0 +1,381 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/ingest"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/schema"
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+// Fixture ids. The two resources are one process seen before and after
+// something enriched its resource: under the version 3 identity they were
+// two rows, and migrating to version 4 must fold them into one.
+const (
+	fxServiceName = "11111111-0000-0000-0000-000000000001"
+	fxHostA       = "11111111-0000-0000-0000-000000000002"
+	fxHostB       = "11111111-0000-0000-0000-000000000003"
+	fxInstance    = "11111111-0000-0000-0000-000000000004"
+	fxRoute       = "11111111-0000-0000-0000-000000000005"
+	fxResourceA   = "22222222-0000-0000-0000-00000000000a"
+	fxResourceB   = "22222222-0000-0000-0000-00000000000b"
+	fxScope       = "33333333-0000-0000-0000-000000000001"
+	fxSpanA       = "44444444-0000-0000-0000-00000000000a"
+	fxSpanB       = "44444444-0000-0000-0000-00000000000b"
+	fxStream      = "55555555-0000-0000-0000-000000000001"
+	fxSeriesA     = "66666666-0000-0000-0000-00000000000a"
+	fxSeriesB     = "66666666-0000-0000-0000-00000000000b"
+	fxIngestA     = "77777777-0000-0000-0000-00000000000a"
+	fxIngestB     = "77777777-0000-0000-0000-00000000000b"
+	fxPointA      = "88888888-0000-0000-0000-00000000000a"
+	fxPointB      = "88888888-0000-0000-0000-00000000000b"
+)
+
+var fxBounds = []float64{1, 5, 10}
+
+func fxResourceID() duckdb.UUID {
+	attrs := pcommon.NewMap()
+	attrs.PutStr("service.name", "checkout")
+	attrs.PutStr("service.instance.id", "i-1")
+	return ingest.ResourceID(attrs)
+}
+
+func fxSeriesID() duckdb.UUID {
+	return ingest.SeriesID(
+		duckdb.UUID(uuid.MustParse(fxStream)), fxResourceID(),
+		[]duckdb.UUID{duckdb.UUID(uuid.MustParse(fxRoute))})
+}
+
+func uuidText(id duckdb.UUID) string { return uuid.UUID(id).String() }
+
+// fixtureDDL is this build's DDL with each later version's change undone, as
+// schema/version.go describes them -- the shape a file stamped version was
+// created with. Every edit must find what it undoes, so a DDL change that
+// moves one of these columns fails here rather than leaving a fixture that
+// quietly stopped being the old shape.
+func fixtureDDL(t *testing.T, version int) []string {
+	t.Helper()
+	dropLines := func(sql string, substrs ...string) string {
+		lines := strings.Split(sql, "\n")
+		for _, sub := range substrs {
+			found := false
+			kept := lines[:0]
+			for _, l := range lines {
+				if strings.Contains(l, sub) {
+					found = true
+					continue
+				}
+				kept = append(kept, l)
+			}
+			require.Truef(t, found, "fixture for version %d: nothing matches %q", version, sub)
+			lines = kept
+		}
+		// Dropping a table's last line strands the comma before it.
+		return regexp.MustCompile(`,(\s*\n\s*\)\s*)$`).ReplaceAllString(strings.Join(lines, "\n"), "$1")
+	}
+	replace := func(sql, old, new string) string {
+		require.Containsf(t, sql, old, "fixture for version %d", version)
+		return strings.Replace(sql, old, new, 1)
+	}
+
+	var out []string
+	for _, stmt := range queries.Types() {
+		sql := stmt.SQL
+		if version < 9 {
+			sql = replace(sql, ", 'bytes', 'map', 'any[]')", ")")
+		}
+		out = append(out, sql)
+	}
+	for _, stmt := range queries.Tables() {
+		sql := stmt.SQL
+		switch ddlObjectName(stmt) {
+		case "spans":
+			if version < 6 {
+				sql = dropLines(sql, "flags uinteger")
+			}
+			if version < 3 {
+				sql = dropLines(sql, "resource_schema_url", "scope_schema_url")
+			}
+		case "links":
+			if version < 6 {
+				sql = dropLines(sql, "flags uinteger")
+			}
+		case "logs":
+			if version < 3 {
+				sql = dropLines(sql, "resource_schema_url", "scope_schema_url")
+			}
+		case "metric_ingests":
+			if version < 7 {
+				sql = dropLines(sql, "metadata_ids")
+			}
+			if version < 3 {
+				sql = dropLines(sql, "resource_schema_url", "scope_schema_url")
+			}
+		case "histogram_bounds":
+			if version < 5 {
+				continue
+			}
+		case "datapoints":
+			if version < 8 {
+				sql = dropLines(sql, "metric_type varchar", "quantile_values", "constraint chk_")
+			}
+			if version < 5 {
+				sql = dropLines(sql, "foreign key (bounds_id)")
+				sql = replace(sql, "bounds_id uuid,", "explicit_bounds double[],")
+			}
+		}
+		out = append(out, sql)
+	}
+	for _, stmt := range queries.Indexes() {
+		out = append(out, stmt.SQL)
+	}
+	// No macros: they bind against the tables when created, and this build's
+	// read columns an older shape does not have. A file from that build had
+	// its own, which the migration leaves to createSchema to replace.
+	return out
+}
+
+// fixtureDatabase writes a database file as a build using version would
+// have left it, holding a little of every signal.
+func fixtureDatabase(t *testing.T, dir string, version int) string {
+	t.Helper()
+	path := filepath.Join(dir, fmt.Sprintf("v%d.db", version))
+	db, err := sql.Open("duckdb", path)
+	require.NoError(t, err)
+	defer db.Close()
+	exec := func(query string, args ...any) {
+		t.Helper()
+		_, err := db.Exec(query, args...)
+		require.NoErrorf(t, err, "%s", query)
+	}
+
+	for _, stmt := range fixtureDDL(t, version) {
+		exec(stmt)
+	}
+	exec(schema.VersionTableQuery)
+	exec(schema.StampVersionQuery, version)
+
+	exec(`insert into attributes (id, key, value, type, scope) values
+		(?, 'service.name', 'checkout', 'string', 'resource'),
+		(?, 'host.name', 'pod-a', 'string', 'resource'),
+		(?, 'host.name', 'pod-b', 'string', 'resource'),
+		(?, 'service.instance.id', 'i-1', 'string', 'resource'),
+		(?, 'http.route', '/cart', 'string', 'datapoint')`,
+		fxServiceName, fxHostA, fxHostB, fxInstance, fxRoute)
+
+	// From version 4 on, both resources already have the one identity.
+	resA, resB := fxResourceA, fxResourceB
+	seriesA, seriesB := fxSeriesA, fxSeriesB
+	if version >= 4 {
+		resA, resB = uuidText(fxResourceID()), uuidText(fxResourceID())
+		seriesA, seriesB = uuidText(fxSeriesID()), uuidText(fxSeriesID())
+	}
+	exec(`insert into resources (id, attribute_ids) values (?, [?, ?, ?]::uuid[]) on conflict do nothing`,
+		resA, fxServiceName, fxHostA, fxInstance)
+	exec(`insert into resources (id, attribute_ids) values (?, [?, ?, ?]::uuid[]) on conflict do nothing`,
+		resB, fxServiceName, fxHostB, fxInstance)
+	exec(`insert into scopes (id, name, version, attribute_ids) values (?, 'otelhttp', '1.2.0', [])`, fxScope)
+
+	exec(`insert into spans (trace_id, span_id, name, start_time, end_time, resource_id, scope_id,
+			attribute_ids, service_name) values
+		(uuid(), ?, 'GET /cart', 1000, 2000, ?, ?, [], 'checkout'),
+		(uuid(), ?, 'GET /cart', 3000, 4000, ?, ?, [], 'checkout')`,
+		fxSpanA, resA, fxScope, fxSpanB, resB, fxScope)
+	exec(`insert into events (id, span_id, name, timestamp, attribute_ids) values (uuid(), ?, 'retry', 1500, [])`, fxSpanA)
+	exec(`insert into links (id, span_id, trace_id, linked_span_id, attribute_ids) values (uuid(), ?, uuid(), uuid(), [])`, fxSpanA)
+	exec(`insert into logs (id, timestamp, body, resource_id, scope_id, attribute_ids, service_name)
+		values (uuid(), 1200, 'cart loaded', ?, ?, [], 'checkout')`, resB, fxScope)
+
+	exec(`insert into metric_streams (id, name, unit, metric_type, aggregation_temporality, service_name)
+		values (?, 'http.server.duration', 'ms', 'Histogram', 'Cumulative', 'checkout')`, fxStream)
+	exec(`insert into metric_series (id, stream_id, resource_id, attribute_ids)
+		values (?, ?, ?, [?]::uuid[]) on conflict do nothing`, seriesA, fxStream, resA, fxRoute)
+	exec(`insert into metric_series (id, stream_id, resource_id, attribute_ids)
+		values (?, ?, ?, [?]::uuid[]) on conflict do nothing`, seriesB, fxStream, resB, fxRoute)
+	exec(`insert into metric_ingests (id, stream_id, resource_id, scope_id) values (?, ?, ?, ?), (?, ?, ?, ?)`,
+		fxIngestA, fxStream, resA, fxScope, fxIngestB, fxStream, resB, fxScope)
+
+	cols := "id, stream_id, series_id, metric_ingest_id, timestamp, count, sum, bucket_counts, attribute_ids"
+	vals := "?, ?, ?, ?, 5000, 3, 12.5, [1, 1, 1, 0], [?]::uuid[]"
+	if version >= 8 {
+		cols += ", metric_type"
+		vals += ", 'Histogram'"
+	}
+	if version >= 5 {
+		exec(`insert into histogram_bounds (id, bounds) values (?, [1, 5, 10])`, uuidText(ingest.BoundsID(fxBounds)))
+		cols += ", bounds_id"
+		vals += ", '" + uuidText(ingest.BoundsID(fxBounds)) + "'"
+	} else {
+		cols += ", explicit_bounds"
+		vals += ", [1, 5, 10]"
+	}
+	for _, p := range [][3]string{{fxPointA, seriesA, fxIngestA}, {fxPointB, seriesB, fxIngestB}} {
+		exec(`insert into datapoints (`+cols+`) values (`+vals+`)`, p[0], fxStream, p[1], p[2], fxRoute)
+	}
+	exec(`insert into exemplars (id, datapoint_id, timestamp, value, attribute_ids) values (uuid(), ?, 5000, 7.5, [])`, fxPointA)
+	return path
+}
+
+func stampOf(t *testing.T, path string) int64 {
+	t.Helper()
+	db, err := sql.Open("duckdb", path+"?access_mode=read_only")
+	require.NoError(t, err)
+	defer db.Close()
+	var v int64
+	require.NoError(t, db.QueryRow(schema.ReadVersionQuery).Scan(&v))
+	return v
+}
+
+// The runner trusts the chain to have no gaps: a missing step would stamp a
+// file as current with one change never applied.
+func TestMigrationsReachThisVersion(t *testing.T) {
+	var versions []int
+	for _, m := range queries.Migrations() {
+		versions = append(versions, m.Version)
+	}
+	var want []int
+	for v := oldestMigratable + 1; v <= schema.Version; v++ {
+		want = append(want, v)
+	}
+	assert.Equal(t, want, versions, "one migration per version from %d to %d", oldestMigratable+1, schema.Version)
+}
+
+// A file from every version the chain starts at opens as a current one: same
+// rows, this build's ids and columns, and ingest working against it.
+func TestMigrateFromEachPriorVersion(t *testing.T) {
+	for version := oldestMigratable; version < schema.Version; version++ {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			path := fixtureDatabase(t, t.TempDir(), version)
+
+			s, err := NewStore(context.Background(), path, zap.NewNop())
+			require.NoError(t, err)
+			defer s.Close()
+			assert.Equal(t, SchemaMigrated, s.SchemaCompatibility())
+
+			for table, n := range map[string]int64{
+				"spans": 2, "events": 1, "links": 1, "logs": 1,
+				"resources": 1, "scopes": 1, "metric_streams": 1, "metric_series": 1,
+				"metric_ingests": 2, "datapoints": 2, "histogram_bounds": 1, "exemplars": 1,
+			} {
+				assert.Equalf(t, n, count(t, s, table), "rows in %s", table)
+			}
+
+			resource, series, bounds := uuidText(fxResourceID()), uuidText(fxSeriesID()), uuidText(ingest.BoundsID(fxBounds))
+			for query, want := range map[string]string{
+				`select string_agg(distinct id::varchar) from resources`:                         resource,
+				`select string_agg(distinct resource_id::varchar) from spans`:                    resource,
+				`select string_agg(distinct resource_id::varchar) from logs`:                     resource,
+				`select string_agg(distinct resource_id::varchar) from metric_ingests`:           resource,
+				`select string_agg(distinct id::varchar) from metric_series`:                     series,
+				`select string_agg(distinct series_id::varchar) from datapoints`:                 series,
+				`select string_agg(distinct bounds_id::varchar) from datapoints`:                 bounds,
+				`select string_agg(distinct metric_type) from datapoints`:                        "Histogram",
+				`select string_agg(distinct bounds::varchar) from histogram_bounds`:              "[1.0, 5.0, 10.0]",
+				`select string_agg(distinct resource_schema_url || scope_schema_url) from spans`: "",
+				`select count(*)::varchar from metric_ingests where metadata_ids = []`:           "2",
+			} {
+				var got string
+				require.NoError(t, s.db.QueryRow(query).Scan(&got), query)
+				assert.Equal(t, want, got, query)
+			}
+			var stamped int64
+			require.NoError(t, s.db.QueryRow(schema.ReadVersionQuery).Scan(&stamped))
+			assert.EqualValues(t, schema.Version, stamped)
+
+			// The enum took the new tokens, and the appenders find the
+			// columns where they expect them.
+			_, err = s.db.Exec(`insert into attributes values (uuid(), 'k', '{}', 'map', 'span')`)
+			require.NoError(t, err)
+			ingestAll(t, s, 1)
+			assertNoDanglingRefs(t, s, "after ingesting into a migrated file")
+
+			backup := fmt.Sprintf("%s.v%d.bak", path, version)
+			require.FileExists(t, backup)
+			assert.EqualValues(t, version, stampOf(t, backup), "the backup is the file as it was")
+		})
+	}
+}
+
+// Version 1 predates the dictionary, and no chain reaches it.
+func TestMigrationDoesNotReachVersion1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v1.db")
+	db, err := sql.Open("duckdb", path)
+	require.NoError(t, err)
+	_, err = db.Exec(schema.VersionTableQuery)
+	require.NoError(t, err)
+	_, err = db.Exec(schema.StampVersionQuery, 1)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	_, err = NewStore(context.Background(), path, zap.NewNop())
+	require.ErrorIs(t, err, ErrSchemaIncompatible)
+	assert.NoFileExists(t, path+".v1.bak", "nothing to back up for a migration that never ran")
+}
+
+// A migration that fails partway commits nothing: the file keeps its stamp
+// and its rows. An earlier backup is never overwritten by the next.
+func TestFailedMigrationLeavesTheFileAsItWas(t *testing.T) {
+	dir := t.TempDir()
+	path := fixtureDatabase(t, dir, 7)
+	db, err := sql.Open("duckdb", path)
+	require.NoError(t, err)
+	// A Gauge datapoint needs a value_type, which version 7 did not check
+	// and version 8 does, so copying this one back fails.
+	_, err = db.Exec(`insert into metric_streams (id, name, metric_type) values ('55555555-0000-0000-0000-000000000002', 'queue.depth', 'Gauge')`)
+	require.NoError(t, err)
+	_, err = db.Exec(`insert into metric_series (id, stream_id, resource_id, attribute_ids)
+		values ('66666666-0000-0000-0000-00000000000c', '55555555-0000-0000-0000-000000000002', ?, [])`, uuidText(fxResourceID()))
+	require.NoError(t, err)
+	_, err = db.Exec(`insert into metric_ingests (id, stream_id, resource_id, scope_id)
+		values ('77777777-0000-0000-0000-00000000000c', '55555555-0000-0000-0000-000000000002', ?, ?)`, uuidText(fxResourceID()), fxScope)
+	require.NoError(t, err)
+	_, err = db.Exec(`insert into datapoints (id, stream_id, series_id, metric_ingest_id, timestamp, attribute_ids)
+		values (uuid(), '55555555-0000-0000-0000-000000000002', '66666666-0000-0000-0000-00000000000c', '77777777-0000-0000-0000-00000000000c', 5000, [])`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	earlier := path + ".v7.bak"
+	require.NoError(t, os.WriteFile(earlier, []byte("an earlier backup"), 0o600))
+
+	_, err = NewStore(context.Background(), path, zap.NewNop())
+	require.ErrorIs(t, err, ErrStoreInitFailed)
+	assert.Contains(t, err.Error(), "from schema version 7")
+
+	assert.EqualValues(t, 7, stampOf(t, path))
+	raw, err := sql.Open("duckdb", path+"?access_mode=read_only")
+	require.NoError(t, err)
+	defer raw.Close()
+	var points int
+	require.NoError(t, raw.QueryRow(`select count(*) from datapoints`).Scan(&points))
+	assert.Equal(t, 3, points)
+
+	b, err := os.ReadFile(earlier)
+	require.NoError(t, err)
+	assert.Equal(t, "an earlier backup", string(b))
+	assert.FileExists(t, path+".v7.2.bak")
+}
+
+// A read-only open cannot migrate, so it says how to get a file that can be
+// opened: once with --db.
+func TestReadOnlyOlderDatabaseNamesTheUpgrade(t *testing.T) {
+	path := fixtureDatabase(t, t.TempDir(), schema.Version-1)
+
+	_, err := openReadOnly(t, path)
+	require.ErrorIs(t, err, ErrSchemaIncompatible)
+	assert.Contains(t, err.Error(), "an older build")
+	assert.Contains(t, err.Error(), "--db")
+	assert.Contains(t, err.Error(), "upgrade it in place")
+	assert.EqualValues(t, schema.Version-1, stampOf(t, path), "read-only leaves the file alone")
+}
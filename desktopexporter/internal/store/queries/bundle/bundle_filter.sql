@@ -0,0 +1,12 @@
+-- The selection a filtered bundle copies, bound once so the copy below can
+-- read it without parameters of its own -- a multi-statement script binds
+-- nothing. Temp, so it lives on the export's own connection and a concurrent
+-- export on another connection cannot see or replace it.
+--
+-- trace_ids is null when the bundle is not restricted to traces. The bounds
+-- are never null: an open end is 0 or the largest bigint.
+create or replace temp table bundle_filter as
+select
+	?::bigint as time_start,
+	?::bigint as time_end,
+	?::varchar[]::uuid[] as trace_ids
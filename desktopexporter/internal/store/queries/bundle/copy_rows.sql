@@ -0,0 +1,118 @@
+-- Copies the rows a filtered bundle holds from the live store ({{.Src}}) into
+-- the empty database the bundle is exported from ({{.Dst}}), which already has
+-- the store's schema. bundle_filter says what to take.
+--
+-- What the filter means per signal:
+--
+--   spans      whole traces. A trace is in if any of its spans starts in the
+--              window, and then every span of it is -- a bundle that cut a
+--              trace at the window edge would reproduce a broken trace that
+--              was never broken.
+--   logs       by their own time, the same coalesce searchLogs orders by;
+--              with trace ids, only logs that carry one of them.
+--   metrics    datapoints by their timestamp. None at all when the bundle is
+--              restricted to traces: a datapoint belongs to no trace, and a
+--              bundle asked for three traces should not carry every gauge the
+--              process wrote while they ran.
+--
+-- Everything else is pulled in because something copied points at it --
+-- events and links by span, exemplars by datapoint, the metric stream, series,
+-- ingest and bounds rows by datapoint, resources and scopes by owner, and the
+-- dictionary last, from every id array now in the bundle. Inserted parents
+-- first: the bundle keeps the store's foreign keys.
+--
+-- One transaction, so the bundle is a single snapshot even with ingest
+-- committing alongside it. The temp tables are left behind on purpose: the
+-- connection this runs on is discarded afterwards, and they go with it.
+begin transaction;
+
+create or replace temp table bundle_spans as
+select s.span_id
+from {{.Src}}.spans s, bundle_filter f
+where s.trace_id in (
+		select trace_id from {{.Src}}.spans
+		where start_time between f.time_start and f.time_end
+	)
+	and (f.trace_ids is null or list_contains(f.trace_ids, s.trace_id));
+
+create or replace temp table bundle_logs as
+select l.id
+from {{.Src}}.logs l, bundle_filter f
+where coalesce(nullif(l.timestamp, 0), l.observed_timestamp) between f.time_start and f.time_end
+	and (f.trace_ids is null or list_contains(f.trace_ids, l.trace_id));
+
+create or replace temp table bundle_datapoints as
+select d.id, d.stream_id, d.series_id, d.metric_ingest_id, d.bounds_id
+from {{.Src}}.datapoints d, bundle_filter f
+where f.trace_ids is null
+	and d.timestamp between f.time_start and f.time_end;
+
+insert into {{.Dst}}.resources by name
+select * from {{.Src}}.resources
+where id in (
+	select resource_id from {{.Src}}.spans where span_id in (select span_id from bundle_spans)
+	union select resource_id from {{.Src}}.logs where id in (select id from bundle_logs)
+	union select resource_id from {{.Src}}.metric_ingests where id in (select metric_ingest_id from bundle_datapoints)
+	union select resource_id from {{.Src}}.metric_series where id in (select series_id from bundle_datapoints)
+);
+
+insert into {{.Dst}}.scopes by name
+select * from {{.Src}}.scopes
+where id in (
+	select scope_id from {{.Src}}.spans where span_id in (select span_id from bundle_spans)
+	union select scope_id from {{.Src}}.logs where id in (select id from bundle_logs)
+	union select scope_id from {{.Src}}.metric_ingests where id in (select metric_ingest_id from bundle_datapoints)
+);
+
+insert into {{.Dst}}.metric_streams by name
+select * from {{.Src}}.metric_streams where id in (select stream_id from bundle_datapoints);
+
+insert into {{.Dst}}.metric_series by name
+select * from {{.Src}}.metric_series where id in (select series_id from bundle_datapoints);
+
+insert into {{.Dst}}.metric_ingests by name
+select * from {{.Src}}.metric_ingests where id in (select metric_ingest_id from bundle_datapoints);
+
+insert into {{.Dst}}.spans by name
+select * from {{.Src}}.spans where span_id in (select span_id from bundle_spans);
+
+insert into {{.Dst}}.events by name
+select * from {{.Src}}.events where span_id in (select span_id from bundle_spans);
+
+insert into {{.Dst}}.links by name
+select * from {{.Src}}.links where span_id in (select span_id from bundle_spans);
+
+insert into {{.Dst}}.logs by name
+select * from {{.Src}}.logs where id in (select id from bundle_logs);
+
+insert into {{.Dst}}.histogram_bounds by name
+select * from {{.Src}}.histogram_bounds where id in (select bounds_id from bundle_datapoints);
+
+insert into {{.Dst}}.datapoints by name
+select * from {{.Src}}.datapoints where id in (select id from bundle_datapoints);
+
+insert into {{.Dst}}.exemplars by name
+select * from {{.Src}}.exemplars where datapoint_id in (select id from bundle_datapoints);
+
+-- metadata_ids is read here as well as the owners' attribute_ids: it names
+-- dictionary rows too, and a bundle without them would render every metric's
+-- metadata as empty.
+insert into {{.Dst}}.attributes by name
+select * from {{.Src}}.attributes
+where id in (
+	select unnest(attribute_ids) from {{.Dst}}.spans
+	union select unnest(attribute_ids) from {{.Dst}}.events
+	union select unnest(attribute_ids) from {{.Dst}}.links
+	union select unnest(attribute_ids) from {{.Dst}}.logs
+	union select unnest(attribute_ids) from {{.Dst}}.datapoints
+	union select unnest(attribute_ids) from {{.Dst}}.metric_series
+	union select unnest(metadata_ids) from {{.Dst}}.metric_ingests
+	union select unnest(attribute_ids) from {{.Dst}}.exemplars
+	union select unnest(attribute_ids) from {{.Dst}}.resources
+	union select unnest(attribute_ids) from {{.Dst}}.scopes
+);
+
+insert into {{.Dst}}.schema_meta by name
+select * from {{.Src}}.schema_meta;
+
+commit;
@@ -0,0 +1,122 @@
+		-- compareAttributes: how often each attribute value occurs in a
+		-- selection and in a baseline, per key, the keys whose values differ
+		-- most first.
+		--
+		-- Counting is over the dictionary: every row's attribute ids are
+		-- unnested once per side and counted per id, and only the distinct
+		-- ids -- a few hundred, however many rows -- are joined to the
+		-- dictionary for their keys and values. No row has a value resolved.
+		with {{.CTEs}},
+
+		compare_params as (
+			select ? as max_values
+		),
+
+		selection as materialized (
+			select {{.ID}} as id, {{.AttributeIDs}} as attribute_ids
+			from selection_params, {{.From}}
+			where {{.SelectionWhere}}
+		),
+
+		-- The baseline is what its query matches outside the selection.
+		-- Overlapping rows would count on both sides and pull the two
+		-- distributions together; with no baseline query, this is "everything
+		-- else in the window", the comparison a selection usually means.
+		baseline as materialized (
+			select b.*
+			from (
+				select {{.ID}} as id, {{.AttributeIDs}} as attribute_ids
+				from baseline_params, {{.From}}
+				where {{.BaselineWhere}}
+			) b
+			anti join selection s on s.id = b.id
+		),
+
+		sizes as (
+			select (select count(*) from selection) as selection_n,
+				(select count(*) from baseline) as baseline_n
+		),
+
+		id_counts as (
+			select id, sum(sel) as sel, sum(base) as base
+			from (
+				select unnest(attribute_ids) as id, 1 as sel, 0 as base from selection
+				union all
+				select unnest(attribute_ids) as id, 0 as sel, 1 as base from baseline
+			)
+			group by id
+		),
+
+		-- By value rather than by id: the same value written under two types
+		-- is two ids, and one value to whoever is reading.
+		value_counts as (
+			select a.scope, a.key, a.value, sum(c.sel) as sel, sum(c.base) as base
+			from id_counts c
+			join attributes a on a.id = c.id
+			group by a.scope, a.key, a.value
+		),
+
+		-- A key some rows lack has one more value, reported as null: absent.
+		-- It is often the telling one -- the failing spans are the ones
+		-- without a retry header -- and it is what makes each side's
+		-- frequencies sum to one.
+		with_absent as (
+			select scope, key, value, sel, base from value_counts
+			union all
+			select v.scope, v.key, null, z.selection_n - sum(v.sel), z.baseline_n - sum(v.base)
+			from value_counts v, sizes z
+			group by v.scope, v.key, z.selection_n, z.baseline_n
+			having z.selection_n - sum(v.sel) > 0 or z.baseline_n - sum(v.base) > 0
+		),
+
+		frequencies as (
+			select w.*,
+				coalesce(w.sel / nullif(z.selection_n, 0), 0) as sel_freq,
+				coalesce(w.base / nullif(z.baseline_n, 0), 0) as base_freq
+			from with_absent w, sizes z
+		),
+
+		ranked as (
+			select f.*, f.sel_freq - f.base_freq as difference,
+				row_number() over (
+					partition by f.scope, f.key
+					order by abs(f.sel_freq - f.base_freq) desc, f.value nulls first
+				) as rn
+			from frequencies f
+		),
+
+		-- A key's divergence is its largest gap in any one value's frequency.
+		-- Not the sum of the gaps: a key unique to every row -- a request id
+		-- -- shares no value between the sides, which sums to the maximum
+		-- and would bury every key that means something under the ones that
+		-- are merely distinct.
+		keys as (
+			select r.scope, r.key,
+				max(abs(r.difference)) as divergence,
+				count(r.value) as distinct_value_count,
+				to_json(list(json_object(
+					'value',              r.value,
+					'selectionCount',     r.sel,
+					'baselineCount',      r.base,
+					'selectionFrequency', r.sel_freq,
+					'baselineFrequency',  r.base_freq,
+					'difference',         r.difference
+				) order by r.rn) filter (where r.rn <= p.max_values)) as vals
+			from ranked r, compare_params p
+			group by r.scope, r.key, p.max_values
+		)
+
+		select cast(json_object(
+			'selectionCount', (select selection_n from sizes),
+			'baselineCount',  (select baseline_n from sizes),
+			'attributes', coalesce((
+				select to_json(list(json_object(
+					'key',                key,
+					'attributeScope',     scope,
+					'divergence',         divergence,
+					'distinctValueCount', distinct_value_count,
+					'values',             vals
+				) order by divergence desc, key, scope))
+				from keys
+			), json('[]'))
+		) as varchar) as comparison
@@ -0,0 +1,15 @@
+-- attr_json_path reads one leaf out of a map or any[] attribute value, NULL
+-- when the value is neither or the path does not resolve.
+--
+-- p is an RFC 6901 JSON pointer ('/headers/content-type'), built in Go from
+-- the search field's path segments. A pointer rather than DuckDB's '$.a.b'
+-- form because attribute keys are dotted by convention: the pointer has one
+-- separator and a defined escape for it, where '$.' would need every segment
+-- quoted and still has no escape for a quote inside one.
+--
+-- The type guard is not an optimisation. json_extract_string raises on text
+-- that is not JSON, and the same key can carry a plain string from one
+-- producer and a map from another.
+create or replace macro attr_json_path(v, t, p) as (
+		case when t in ('map', 'any[]') then json_extract_string(v, p) end
+	)
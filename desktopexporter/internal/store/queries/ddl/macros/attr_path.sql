@@ -0,0 +1,9 @@
+-- attr_path is attr_value for a path predicate: the leaf at pointer p inside
+-- the attribute keyed k, NULL when absent. See attr_json_path for the pointer.
+create or replace macro attr_path(ids, k, p) as (
+		(select attr_json_path(a.value, a.type, p)
+		 from unnest(ids) as t(aid)
+		 join attributes a on a.id = t.aid
+		 where a.key = k
+		 limit 1)
+	)
@@ -94,6 +94,15 @@ create or replace macro datapoint_json(d, exemplars, exemplar_count, qs) as (
 							d.positive_bucket_offset, d.positive_bucket_counts, qs) end,
 					'aggregationTemporality', d.aggregation_temporality
 				)
+				-- No min, max or temporality: OTLP's Summary carries none of them.
+				-- quantiles ignores qs and returns what the sender computed, since
+				-- a summary cannot be asked for a quantile it did not report.
+				when 'Summary' then json_object(
+					'count', d.count,
+					'sum', d.sum,
+					'quantileValues', d.quantile_values,
+					'quantiles', summary_quantiles(d.quantile_values)
+				)
 			end
 		),
 		json_object('exemplarCount',
@@ -0,0 +1,13 @@
+-- exception_fingerprint is an issue's identity: the exception type, its top
+-- frame and its message template, framed and hashed.
+--
+-- Length-prefixed like attr_frame, so no choice of separator can make two
+-- different triples hash alike. Sixteen hex characters of md5 are plenty for
+-- the issues one store holds, and short enough to read out and paste.
+create or replace macro exception_fingerprint(ex_type, frame, template) as (
+    left(md5(
+        strlen(ex_type)::varchar || ':' || ex_type ||
+        strlen(frame)::varchar || ':' || frame ||
+        strlen(template)::varchar || ':' || template
+    ), 16)
+)
@@ -0,0 +1,21 @@
+-- exception_message_template reduces an exception message to the part that
+-- says what went wrong, by replacing the parts that say to what: ids,
+-- addresses, quoted values and numbers.
+--
+-- "order 8812 not found" and "order 9120 not found" are one bug, and an issue
+-- per order id would bury it. Replacement runs most specific first, so a
+-- uuid is one <uuid> rather than five runs of <n>, and a long hex id -- a
+-- trace or span id pasted into a message -- is one <hex>.
+--
+-- Quoted values go too, quotes kept: `unknown column "foo"` and
+-- `unknown column "bar"` are one message with a different argument.
+create or replace macro exception_message_template(msg) as (
+    trim(regexp_replace(regexp_replace(regexp_replace(regexp_replace(regexp_replace(regexp_replace(
+        msg,
+        '[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}', '<uuid>', 'g'),
+        '0x[0-9a-fA-F]+|\b[0-9a-fA-F]{16,}\b', '<hex>', 'g'),
+        '"[^"]*"', '"<str>"', 'g'),
+        '''[^'']*''', '''<str>''', 'g'),
+        '\d+(\.\d+)?', '<n>', 'g'),
+        '\s+', ' ', 'g'))
+)
@@ -0,0 +1,28 @@
+-- exception_top_frame picks the frame an exception was raised in out of an
+-- exception.stacktrace, with the parts that move between builds removed.
+--
+-- Three shapes are recognised, tried in turn, and anything else has no frame
+-- (an empty string, so the fingerprint still forms):
+--
+-- - Python prints the innermost frame last: the final `File "...", line N,
+--   in fn`.
+-- - Java, .NET and JavaScript print it first: the first `at ...` line.
+-- - Go prints the panicking goroutine's frames after its header: the first
+--   function named there, without its arguments. Only the last parenthesised
+--   group is the argument list; a method's receiver, `(*T)`, is part of the
+--   name.
+--
+-- Line and column numbers and hex offsets are dropped. A deploy that adds a
+-- line above the throw moves every number below it, and the exception it
+-- throws is still the same one.
+create or replace macro exception_top_frame(st) as (
+    trim(regexp_replace(
+        coalesce(
+            regexp_extract_all(st, 'File "[^"]+", line \d+, in \S+')[-1],
+            nullif(regexp_extract(st, '(?m)^\s*at\s+(\S.*?)\s*$', 1), ''),
+            nullif(regexp_extract(st, '(?m)^goroutine \d+ \[[^\]]*\]:\s*\n(\S+)\([^\n]*\)\s*$', 1), ''),
+            ''
+        ),
+        ', line \d+|:\d+|\s*\+0x[0-9a-fA-F]+', '', 'g'
+    ))
+)
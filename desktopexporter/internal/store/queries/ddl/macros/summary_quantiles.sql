@@ -0,0 +1,18 @@
+-- summary_quantiles: a Summary datapoint's stored quantiles, as a JSON object
+-- keyed by the quantile.
+--
+-- The same shape hist_quantiles returns, keyed the same way (`q::varchar`), so
+-- the client draws a Summary's quantile lines through the code that already
+-- draws a histogram's. The difference is where the numbers come from: these
+-- were computed by the sender and are passed through, so every stored quantile
+-- is returned rather than the ones a caller asked for -- there is nothing to
+-- compute the others from.
+--
+-- Null for an empty list rather than an empty object, which is what an
+-- unrequested histogram quantile object is too.
+create or replace macro summary_quantiles(qv) as (
+		case when len(qv) > 0 then
+			(select json_group_object(v.quantile::varchar, v.value)
+			 from unnest(qv) t(v))
+		end
+	)
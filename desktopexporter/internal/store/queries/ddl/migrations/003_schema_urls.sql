@@ -0,0 +1,11 @@
+-- Version 2 -> 3: resource_schema_url and scope_schema_url on every signal
+-- that carries a resource and a scope.
+--
+-- A version 2 file never recorded them, so '' -- the same value ingest writes
+-- when a batch does not set one -- is the honest fill, not a guess.
+alter table spans add column resource_schema_url varchar default '';
+alter table spans add column scope_schema_url varchar default '';
+alter table logs add column resource_schema_url varchar default '';
+alter table logs add column scope_schema_url varchar default '';
+alter table metric_ingests add column resource_schema_url varchar default '';
+alter table metric_ingests add column scope_schema_url varchar default '';
@@ -0,0 +1,85 @@
+-- Version 3 -> 4: resource ids re-derived from the identifying triplet.
+--
+-- A version 3 id hashed the resource's whole attribute set; a version 4 id is
+-- ingest.ResourceID -- sha256 over service.namespace, service.name and
+-- service.instance.id, each framed as "<byte length>:<value>" the way hashID
+-- frames its parts, absent fields as ''. Every value needed is already in
+-- the file: the resource's attribute_ids point at dictionary rows carrying
+-- the rendered value, which for a string is the string itself.
+--
+-- Several version 3 rows can collapse into one id -- that is the point of the
+-- change: one process whose resource was enriched mid-stream had minted a row
+-- per enrichment. The earliest row (lowest seq) survives, which is the row
+-- `on conflict do nothing` would have kept had the new identity applied from
+-- the start.
+--
+-- metric_series ids were hashed from the resource id, so they are re-derived
+-- afterwards with ingest.SeriesID's framing: the stream and resource ids
+-- formatted as uuids, then the label ids as undashed hex each followed by a
+-- comma. Series that collapse the same way keep their lowest old id's row;
+-- datapoint ids were never content-derived, so only their series_id moves.
+create or replace temp macro migrate_frame(s) as
+	strlen(s)::varchar || ':' || s;
+
+create or replace temp macro migrate_hash_id(frames) as
+	cast(
+		substr(sha256(frames),  1, 8) || '-' ||
+		substr(sha256(frames),  9, 4) || '-' ||
+		substr(sha256(frames), 13, 4) || '-' ||
+		substr(sha256(frames), 17, 4) || '-' ||
+		substr(sha256(frames), 21, 12)
+	as uuid);
+
+create or replace temp macro migrate_resource_field(ids, k) as
+	coalesce((
+		select a.value from attributes a
+		where a.scope = 'resource' and a.key = k and list_contains(ids, a.id)
+		limit 1
+	), '');
+
+create temp table resource_rekey as
+select
+	id as old_id,
+	migrate_hash_id(
+		migrate_frame(migrate_resource_field(attribute_ids, 'service.namespace')) ||
+		migrate_frame(migrate_resource_field(attribute_ids, 'service.name')) ||
+		migrate_frame(migrate_resource_field(attribute_ids, 'service.instance.id'))
+	) as new_id
+from resources;
+
+update spans set resource_id = k.new_id from resource_rekey k where spans.resource_id = k.old_id;
+update logs set resource_id = k.new_id from resource_rekey k where logs.resource_id = k.old_id;
+update metric_ingests set resource_id = k.new_id from resource_rekey k where metric_ingests.resource_id = k.old_id;
+update metric_series set resource_id = k.new_id from resource_rekey k where metric_series.resource_id = k.old_id;
+
+create temp table resources_rekeyed as
+select distinct on (k.new_id) r.* replace (k.new_id as id)
+from resources r join resource_rekey k on k.old_id = r.id
+order by k.new_id, r.seq;
+drop table resources;
+alter table resources_rekeyed rename to resources;
+
+create temp table series_rekey as
+select
+	id as old_id,
+	migrate_hash_id(
+		migrate_frame(stream_id::varchar) ||
+		migrate_frame(resource_id::varchar) ||
+		migrate_frame(array_to_string(list_transform(attribute_ids, a -> replace(a::varchar, '-', '') || ','), ''))
+	) as new_id
+from metric_series;
+
+update datapoints set series_id = k.new_id from series_rekey k where datapoints.series_id = k.old_id;
+
+create temp table series_rekeyed as
+select distinct on (k.new_id) s.* replace (k.new_id as id)
+from metric_series s join series_rekey k on k.old_id = s.id
+order by k.new_id, s.id;
+drop table metric_series;
+alter table series_rekeyed rename to metric_series;
+
+drop table resource_rekey;
+drop table series_rekey;
+drop macro migrate_resource_field;
+drop macro migrate_hash_id;
+drop macro migrate_frame;
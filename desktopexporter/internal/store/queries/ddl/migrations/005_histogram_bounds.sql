@@ -0,0 +1,16 @@
+-- Version 4 -> 5: explicit_bounds moves off datapoints into the
+-- histogram_bounds dictionary.
+--
+-- The ids are ingest.BoundsID, which hashes each bound's IEEE-754 bits -- not
+-- something SQL can spell -- so the runner computes them first, into
+-- bounds_rekey (bounds, id), one row per distinct vector. Everything after
+-- that is plain SQL.
+create temp table histogram_bounds as
+select id, bounds from bounds_rekey;
+
+alter table datapoints add column bounds_id uuid;
+update datapoints set bounds_id = k.id
+from bounds_rekey k where datapoints.explicit_bounds = k.bounds;
+alter table datapoints drop column explicit_bounds;
+
+drop table bounds_rekey;
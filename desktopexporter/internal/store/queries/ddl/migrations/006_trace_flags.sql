@@ -0,0 +1,7 @@
+-- Version 5 -> 6: flags on spans and links.
+--
+-- Left NULL: a version 5 file never recorded them, and 0 would claim the span
+-- was unsampled with a local parent -- a statement about the data, where
+-- NULL only says it was not kept.
+alter table spans add column flags uinteger;
+alter table links add column flags uinteger;
@@ -0,0 +1,3 @@
+-- Version 6 -> 7: metadata_ids on metric_ingests. Empty rather than NULL, as
+-- for an ingest whose metric carried no metadata.
+alter table metric_ingests add column metadata_ids uuid[] default [];
@@ -0,0 +1,9 @@
+-- Version 7 -> 8: metric_type and quantile_values on datapoints.
+--
+-- The type is the stream's: a datapoint never had one of its own, only the
+-- stream it belongs to. quantile_values stays NULL everywhere, because a
+-- version 7 build dropped every Summary datapoint -- there are none to fill.
+alter table datapoints add column metric_type varchar;
+update datapoints set metric_type = s.metric_type
+from metric_streams s where datapoints.stream_id = s.id;
+alter table datapoints add column quantile_values struct(quantile double, value double)[];
@@ -0,0 +1,13 @@
+-- Version 8 -> 9: bytes, map and any[] join the attr_type enum.
+--
+-- Nothing to do here. The working copy holds attributes.type as varchar, and
+-- the runner recreates attr_type from ddl/types before copying it back, so
+-- the new tokens arrive with the rebuild.
+--
+-- The values themselves are left as written. A version 8 file stored bytes as
+-- hex typed string and maps as Go's %v rendering, and neither can be turned
+-- back into the value it came from: hex is indistinguishable from a string
+-- that happens to be hex, and %v is not a format anything parses. Those rows
+-- stay readable, under the type they were written with; the same attribute
+-- arriving again is hashed from its new rendering and lands as a new row
+-- beside them.
@@ -11,6 +11,13 @@ create table if not exists datapoints (
 		-- without a join back through metric_series.
 		series_id uuid not null,
 		metric_ingest_id uuid not null,
+		-- Which of the five OTLP datapoint shapes this row holds. The stream
+		-- already knows, but a CHECK constraint cannot look across tables, and
+		-- without a discriminator on the row itself nothing stops a Summary
+		-- from arriving with bucket counts or a Gauge with quantiles -- the
+		-- read path would render whichever columns happened to be set. It
+		-- dictionary-compresses to almost nothing: five distinct values.
+		metric_type varchar not null,
 		timestamp bigint,
 		start_time bigint,
 		flags uinteger,
@@ -35,6 +42,12 @@ create table if not exists datapoints (
 		positive_bucket_counts ubigint[],
 		negative_bucket_offset integer,
 		negative_bucket_counts ubigint[],
+		-- A Summary's pre-computed quantiles, exactly as the sender reported
+		-- them. Inline rather than a sibling table: they are read only ever
+		-- together with their datapoint, are a handful of pairs long, and
+		-- cannot be merged across datapoints (metrics.proto says as much), so
+		-- there is no query that would want them on their own.
+		quantile_values struct(quantile double, value double)[],
 		-- Replaces attrs_canonical. That column materialised the datapoint's
 		-- attribute set as "key=value|..." so grouping by stream-within-stream
 		-- was an equality compare on a varchar. The array serves the same
@@ -45,6 +58,16 @@ create table if not exists datapoints (
 		-- 294,607 datapoints carry 591,890 attribute rows resolving to 89
 		-- distinct label sets -- 82% of the whole attributes table.
 		attribute_ids uuid[] not null,
+		-- One shape per row. Each representation's own columns are allowed
+		-- only under its own discriminator, so a row can be read by its
+		-- metric_type alone. count and sum are shared by the three
+		-- distribution types and are the one overlap.
+		constraint chk_metric_type_valid check (metric_type in ('Gauge', 'Sum', 'Histogram', 'ExponentialHistogram', 'Summary')),
+		constraint chk_number_fields check ((value_type is not null) = (metric_type in ('Gauge', 'Sum'))),
+		constraint chk_distribution_fields check (count is null or metric_type in ('Histogram', 'ExponentialHistogram', 'Summary')),
+		constraint chk_histogram_fields check (bounds_id is null or metric_type = 'Histogram'),
+		constraint chk_exponential_histogram_fields check (scale is null or metric_type = 'ExponentialHistogram'),
+		constraint chk_summary_fields check ((quantile_values is not null) = (metric_type = 'Summary')),
 		foreign key (stream_id) references metric_streams(id),
 		foreign key (series_id) references metric_series(id),
 		foreign key (metric_ingest_id) references metric_ingests(id),
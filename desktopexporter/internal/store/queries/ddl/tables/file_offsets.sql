@@ -0,0 +1,23 @@
+-- How far the directory watcher has read each file it tails, so a restart
+-- picks up where the last run stopped instead of ingesting the files again.
+--
+-- Keyed by fingerprint, not path: the file exporter rotates by renaming
+-- traces.json to traces-<time>.json and starting a new traces.json, and a
+-- path key would see the renamed file as new and the new file as a truncated
+-- old one, re-reading the first and skipping the second. The fingerprint is a
+-- hash of the file's first line -- one whole export request, timestamps
+-- included -- which is what stays with the file across a rename. path is only
+-- where it was last seen, for whoever reads the table.
+--
+-- read_to is a byte offset, always just past a newline: the watcher only
+-- consumes complete lines, and a line the exporter is still writing waits
+-- for the next poll.
+--
+-- Not telemetry, so nothing clears or prunes it: clearing the viewer must not
+-- make the next restart load the files all over again. A row is a few dozen
+-- bytes per file ever watched.
+create table if not exists file_offsets (
+		fingerprint varchar primary key,
+		path varchar not null,
+		read_to bigint not null
+	)
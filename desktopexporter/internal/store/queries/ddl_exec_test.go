@@ -107,3 +107,64 @@ func TestCrossSignalReferencesAreNotForeignKeys(t *testing.T) {
 		})
 	}
 }
+
+// Each datapoint shape's columns are allowed only under its own metric_type.
+//
+// Ingest always writes consistent rows, so no ingest test can see these
+// constraints; what they guard against is a later ingest helper passing its
+// arguments one position out, which the appender accepts without complaint
+// whenever the neighbouring column has a compatible type.
+func TestDatapointShapeConstraints(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, group := range [][]queries.Statement{queries.Types(), queries.Tables()} {
+		for _, stmt := range group {
+			db.Exec(stmt.SQL)
+		}
+	}
+	// Parents for the foreign keys, so only the CHECKs can reject a row.
+	for _, q := range []string{
+		`insert into metric_streams (id, name, metric_type) values ('00000000-0000-0000-0000-000000000001', 'm', 'Summary')`,
+		`insert into resources (id, attribute_ids) values ('00000000-0000-0000-0000-000000000003', [])`,
+		`insert into scopes (id, attribute_ids) values ('00000000-0000-0000-0000-000000000005', [])`,
+		`insert into metric_series (id, stream_id, resource_id, attribute_ids) values
+			('00000000-0000-0000-0000-000000000002', '00000000-0000-0000-0000-000000000001',
+			 '00000000-0000-0000-0000-000000000003', [])`,
+		`insert into metric_ingests (id, stream_id, description, metadata_ids, resource_id, scope_id) values
+			('00000000-0000-0000-0000-000000000004', '00000000-0000-0000-0000-000000000001', '', [],
+			 '00000000-0000-0000-0000-000000000003', '00000000-0000-0000-0000-000000000005')`,
+	} {
+		_, err := db.Exec(q)
+		require.NoError(t, err, q)
+	}
+
+	insert := func(metricType, columns, values string) error {
+		_, err := db.Exec(`insert into datapoints (id, stream_id, series_id, metric_ingest_id, metric_type, attribute_ids` + columns + `)
+			values (uuid(), '00000000-0000-0000-0000-000000000001', '00000000-0000-0000-0000-000000000002',
+			        '00000000-0000-0000-0000-000000000004', '` + metricType + `', []` + values + `)`)
+		return err
+	}
+
+	require.NoError(t, insert("Summary", ", count, sum, quantile_values", ", 3, 1.5, [{'quantile': 0.5, 'value': 0.4}]"))
+	require.NoError(t, insert("Summary", ", quantile_values", ", []"))
+	require.NoError(t, insert("Gauge", ", double_value, value_type", ", 1.0, 'Double'"))
+
+	for _, tc := range []struct{ name, metricType, columns, values string }{
+		{"unknown type", "Bogus", "", ""},
+		{"summary without quantiles", "Summary", ", count", ", 3"},
+		{"quantiles on a histogram", "Histogram", ", quantile_values", ", []"},
+		{"gauge without a value type", "Gauge", ", double_value", ", 1.0"},
+		{"count on a gauge", "Gauge", ", value_type, count", ", 'Double', 3"},
+		{"scale on a histogram", "Histogram", ", scale", ", 0"},
+		{"value type on a summary", "Summary", ", quantile_values, value_type", ", [], 'Double'"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := insert(tc.metricType, tc.columns, tc.values)
+			require.Error(t, err)
+			require.Contains(t, strings.ToLower(err.Error()), "check constraint",
+				"rejected for the wrong reason")
+		})
+	}
+}
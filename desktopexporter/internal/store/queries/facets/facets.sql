@@ -0,0 +1,99 @@
+		-- getFacets: the most frequent values of each requested field or
+		-- attribute over the rows a search matches, with what is left over
+		-- and what has no value at all.
+		--
+		-- The rows are matched once and every facet is read off that one
+		-- materialisation, so asking for five facets is one search and five
+		-- small aggregates rather than five searches.
+		{{.CTEs}},
+
+		matched as materialized (
+			select {{range $i, $c := .Columns}}{{if $i}},
+				{{end}}{{$c.Expr}} as facet_{{$i}}{{end}}
+			{{.From}}
+			where {{.Where}}
+		),
+		{{- range $i, $c := .Columns}}
+		{{- if $c.Dictionary}}
+
+		-- Attribute facet {{$i}}: its values are the dictionary rows for the
+		-- key -- a few, however many rows carry them -- so a matched row is
+		-- counted by which of those ids its array holds. The same content-
+		-- derived ids the search fast path compares against; no row has its
+		-- value resolved.
+		facet_ids_{{$i}} as materialized (
+			select a.id, {{$c.Value}} as value
+			from attributes a, search_params
+			where {{$c.Dictionary}}
+		),
+
+		facet_counts_{{$i}} as (
+			select f.value, count(*) as n
+			from (select unnest(facet_{{$i}}) as id from matched) u
+			join facet_ids_{{$i}} f on f.id = u.id
+			group by f.value
+
+			union all
+
+			-- Rows without the attribute: null, like a row whose path
+			-- leaf is missing, which the branch above reports as a null
+			-- value.
+			select null, count(*)
+			from matched
+			where not coalesce(list_has_any(facet_{{$i}}, (select list(id) from facet_ids_{{$i}})), false)
+		),
+		{{- else}}
+
+		facet_counts_{{$i}} as (
+			select facet_{{$i}}::varchar as value, count(*) as n
+			from matched
+			group by 1
+		),
+		{{- end}}
+		{{- end}}
+
+		facet_values as (
+			{{- range $i, $c := .Columns}}
+			{{- if $i}}
+
+			union all
+			{{- end}}
+			select {{$i}} as facet, value, sum(n) as n
+			from facet_counts_{{$i}}
+			group by value
+			{{- end}}
+		),
+
+		-- Most rows first, then by value, so a tie lists the same values on
+		-- every call.
+		ranked as (
+			select v.*, row_number() over (partition by v.facet order by v.n desc, v.value) as rn
+			from facet_values v
+			where v.value is not null
+		)
+
+		select cast(json_object(
+			'total', (select count(*) from matched),
+			'facets', coalesce((
+				select to_json(list(json_object(
+					'facet',         facet,
+					'values',        vals,
+					'distinctCount', distinct_count,
+					'otherCount',    other_count
+				)))
+				from (
+					select r.facet,
+						to_json(list(json_object('value', r.value, 'count', r.n) order by r.rn)
+							filter (where r.rn <= p.facet_limit)) as vals,
+						count(*) as distinct_count,
+						coalesce(sum(r.n) filter (where r.rn > p.facet_limit), 0) as other_count
+					from ranked r, search_params p
+					group by r.facet, p.facet_limit
+				)
+			), json('[]')),
+			'nulls', coalesce((
+				select to_json(list(json_object('facet', facet, 'count', n)))
+				from facet_values
+				where value is null
+			), json('[]'))
+		) as varchar) as facets
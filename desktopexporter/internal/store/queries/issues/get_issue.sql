@@ -0,0 +1,48 @@
+		-- getIssue: one fingerprint's occurrences across the whole store,
+		-- newest first and capped, with the issue's own facts.
+		with {{.Occurrences}},
+
+		issue_params as (
+			select ? as fingerprint, ? as max_occurrences
+		),
+
+		matched as materialized (
+			select o.*
+			from issue_occurrences o, issue_params p
+			where o.fingerprint = p.fingerprint
+		),
+
+		latest as (
+			select *
+			from matched
+			qualify row_number() over (order by ts desc, id) <= (select max_occurrences from issue_params)
+		)
+
+		select cast(json_object(
+			'fingerprint',     (select fingerprint from issue_params),
+			'type',            (select any_value(ex_type) from matched),
+			'messageTemplate', (select any_value(message_template) from matched),
+			'topFrame',        (select nullif(any_value(top_frame), '') from matched),
+			'count',           (select count(*) from matched),
+			'firstSeen',       (select min(ts)::varchar from matched),
+			'lastSeen',        (select max(ts)::varchar from matched),
+			'services',        (select list_sort(list_distinct(list(nullif(service_name, '')))) from matched),
+			-- One stacktrace in full, the latest: the frames past the top
+			-- one are what a fix starts from, and they repeat per occurrence.
+			'stacktrace',      (select arg_max(ex_stacktrace, ts) from matched),
+			'occurrences', coalesce((
+				select to_json(list(json_object(
+					'source',      source,
+					'timestamp',   ts::varchar,
+					'traceID',     case when trace_id is not null then trace_id_wire(trace_id) end,
+					'spanID',      case when span_id is not null then span_id_wire(span_id) end,
+					-- A log occurrence's handle for getLog. A span event
+					-- has none of its own; its span is the handle.
+					'logID',       case when source = 'log' then id::varchar end,
+					'serviceName', nullif(service_name, ''),
+					'message',     ex_message
+				) order by ts desc, id))
+				from latest
+			), json('[]'))
+		) as varchar) as issue
+		where exists (select 1 from matched)
@@ -0,0 +1,65 @@
+		-- listIssues: the exceptions in a window, grouped by fingerprint, most
+		-- frequent first.
+		with {{.Occurrences}},
+
+		issues as (
+			-- The type, frame and template are what the fingerprint hashes,
+			-- so any_value is every value.
+			select fingerprint,
+				any_value(ex_type) as ex_type,
+				any_value(top_frame) as top_frame,
+				any_value(message_template) as message_template,
+				arg_max(ex_message, ts) as last_message,
+				count(*) as n,
+				count(*) filter (where source = 'span') as span_n,
+				count(*) filter (where source = 'log') as log_n,
+				min(ts) as first_seen,
+				max(ts) as last_seen,
+				list_sort(list_distinct(list(nullif(service_name, '')))) as services
+			from issue_occurrences
+			group by fingerprint
+		),
+
+		-- A few traces and logs to open from each issue, the latest first and
+		-- each trace once however often it threw.
+		example_traces as (
+			select fingerprint, to_json(list(trace_id_wire(trace_id) order by ts desc, trace_id)) as ids
+			from (
+				select fingerprint, trace_id, max(ts) as ts
+				from issue_occurrences
+				where trace_id is not null
+				group by fingerprint, trace_id
+				qualify row_number() over (partition by fingerprint order by max(ts) desc, trace_id) <= 5
+			)
+			group by fingerprint
+		),
+
+		example_logs as (
+			select fingerprint, to_json(list(id order by ts desc, id)) as ids
+			from (
+				select fingerprint, id::varchar as id, ts
+				from issue_occurrences
+				where source = 'log'
+				qualify row_number() over (partition by fingerprint order by ts desc, id) <= 5
+			)
+			group by fingerprint
+		)
+
+		select cast(coalesce(to_json(list(json_object(
+			'fingerprint',     i.fingerprint,
+			'type',            i.ex_type,
+			'messageTemplate', i.message_template,
+			'topFrame',        nullif(i.top_frame, ''),
+			'lastMessage',     i.last_message,
+			'count',           i.n,
+			'spanEventCount',  i.span_n,
+			'logCount',        i.log_n,
+			'firstSeen',       i.first_seen::varchar,
+			'lastSeen',        i.last_seen::varchar,
+			'services',        i.services,
+			'exampleTraceIDs', coalesce(et.ids, json('[]')),
+			'exampleLogIDs',   coalesce(el.ids, json('[]'))
+		) order by i.n desc, i.last_seen desc, i.fingerprint)), '[]') as varchar) as issues
+		from issues i
+		left join example_traces et on et.fingerprint = i.fingerprint
+		left join example_logs el on el.fingerprint = i.fingerprint
@@ -0,0 +1,87 @@
+		-- The exception occurrences issues are built from, as a CTE chain that
+		-- list_issues.sql and get_issue.sql both open with: every exception in
+		-- the window the query selects, with its fields and its fingerprint.
+		--
+		-- An exception reaches the store two ways, and both are read: as a
+		-- span event named "exception", per the semantic conventions, and as a
+		-- log record carrying the same exception.* attributes. The query is
+		-- rendered once per signal, each under its own parameters CTE, and a
+		-- signal the Go side could not render it for is left out entirely --
+		-- see issues.List.
+		{{- if .SpanCTE}}
+		{{.SpanCTE}},
+		{{- end}}
+		{{- if .LogCTE}}
+		{{.LogCTE}},
+		{{- end}}
+
+		-- The three keys, from the dictionary rather than per owner: a few
+		-- dozen rows however many exceptions reference them, and the set the
+		-- log branch below probes before unnesting anything.
+		exception_attributes as materialized (
+			select id, key, value
+			from attributes
+			where key in ('exception.type', 'exception.message', 'exception.stacktrace')
+		),
+
+		occurrences as materialized (
+			{{- if .SpanCTE}}
+			-- The FROM is spanSearchFrom's, so the predicate's aliases
+			-- resolve, with the span's events joined on.
+			select 'span' as source, ev.id, ev.timestamp as ts, s.trace_id, s.span_id,
+				s.service_name, ev.attribute_ids
+			from span_params, spans s
+				join resources r on r.id = s.resource_id
+				join scopes sc on sc.id = s.scope_id
+				join events ev on ev.span_id = s.span_id
+			where ev.name = 'exception' and {{.SpanWhere}}
+			{{- end}}
+			{{- if and .SpanCTE .LogCTE}}
+
+			union all
+			{{- end}}
+			{{- if .LogCTE}}
+			-- Likewise logSearchFrom's. A log has no name that marks it as an
+			-- exception, so the attributes are what do: a type or a message,
+			-- which the conventions require one of.
+			select 'log' as source, l.id, coalesce(nullif(l.timestamp, 0), l.observed_timestamp) as ts,
+				l.trace_id, l.span_id, l.service_name, l.attribute_ids
+			from log_params, logs l
+				join resources r on r.id = l.resource_id
+				join scopes sc on sc.id = l.scope_id
+			where {{.LogWhere}}
+				and list_has_any(l.attribute_ids, (
+					select list(id) from exception_attributes
+					where key in ('exception.type', 'exception.message')
+				))
+			{{- end}}
+		),
+
+		exception_fields as (
+			select o.source, o.id,
+				max(x.value) filter (where x.key = 'exception.type') as ex_type,
+				max(x.value) filter (where x.key = 'exception.message') as ex_message,
+				max(x.value) filter (where x.key = 'exception.stacktrace') as ex_stacktrace
+			from (select source, id, unnest(attribute_ids) as aid from occurrences) o
+			join exception_attributes x on x.id = o.aid
+			group by o.source, o.id
+		),
+
+		-- An exception event with neither a type nor a message has nothing to
+		-- group on, and is left out rather than pooled into one issue of
+		-- blanks.
+		fielded as (
+			select o.source, o.id, o.ts, o.trace_id, o.span_id, o.service_name,
+				f.ex_type, f.ex_message, f.ex_stacktrace,
+				exception_top_frame(f.ex_stacktrace) as top_frame,
+				coalesce(exception_message_template(f.ex_message), '') as message_template
+			from occurrences o
+			join exception_fields f on f.source = o.source and f.id = o.id
+			where f.ex_type is not null or f.ex_message is not null
+		),
+
+		issue_occurrences as materialized (
+			select f.*,
+				exception_fingerprint(coalesce(f.ex_type, ''), f.top_frame, f.message_template) as fingerprint
+			from fielded f
+		)
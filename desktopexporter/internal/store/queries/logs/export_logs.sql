@@ -0,0 +1,57 @@
+		-- A set of log records in the shape logs.ExportOTLP rebuilds pdata from:
+		-- getLog's fields, with the resource and scope referenced by seq and
+		-- rendered once each, as searchSpans does, instead of copied into every
+		-- record.
+		--
+		-- Records come out grouped by resource and scope, each with its schema
+		-- url, for the caller to open a new ResourceLogs or ScopeLogs on each
+		-- change of key. Within a scope they go by time; the store keeps no
+		-- record of the order they were sent in.
+		with selected as materialized (
+			select l.*
+			from logs l
+			where l.id in (select unnest(?::varchar[])::uuid)
+		),
+
+		resource_data as (
+			select r.id, r.seq, resource_json(r.attribute_ids, r.dropped_attributes_count) as obj
+			from resources r
+			where r.id in (select resource_id from selected)
+		),
+
+		scope_data as (
+			select sc.id, sc.seq,
+				scope_json(sc.name, sc.version, sc.attribute_ids, sc.dropped_attributes_count) as obj
+			from scopes sc
+			where sc.id in (select scope_id from selected)
+		)
+
+		select cast(json_object(
+			'resources', coalesce((select json_group_object(seq::varchar, obj) from resource_data), json('{}')),
+			'scopes', coalesce((select json_group_object(seq::varchar, obj) from scope_data), json('{}')),
+			'ids', coalesce((select to_json(list(id::varchar)) from selected), json('[]')),
+			'logs', coalesce((
+				select to_json(list(json_object(
+					'timestamp', l.timestamp::varchar,
+					'observedTimestamp', l.observed_timestamp::varchar,
+					'traceID', trace_id_wire(l.trace_id),
+					'spanID', span_id_wire(l.span_id),
+					'severityText', l.severity_text,
+					'severityNumber', l.severity_number,
+					'body', l.body,
+					'bodyType', l.body_type,
+					'droppedAttributesCount', l.dropped_attributes_count,
+					'flags', l.flags,
+					'eventName', l.event_name,
+					'attributes', attrs_json(l.attribute_ids),
+					'r', rd.seq,
+					's', scd.seq,
+					'resourceSchemaURL', l.resource_schema_url,
+					'scopeSchemaURL', l.scope_schema_url
+				) order by rd.seq, l.resource_schema_url, scd.seq, l.scope_schema_url,
+					l.timestamp, l.observed_timestamp, l.id))
+				from selected l
+				join resource_data rd on rd.id = l.resource_id
+				join scope_data scd on scd.id = l.scope_id
+			), json('[]'))
+		) as varchar)
@@ -1,14 +1,37 @@
 {{.CTEs}},
 		filtered as (
-			select l.* {{.From}}
+			select l.*, coalesce(nullif(l.timestamp, 0), l.observed_timestamp) as sort_ts {{.From}}
 			where {{.Where}}
+		),
+		-- Keyset page over (sort_ts, id), newest first; see search_traces.sql.
+		page as (
+			select l.* from filtered l{{if .After}}, search_params
+			where l.sort_ts < cursor_key
+				or (l.sort_ts = cursor_key and l.id < cursor_id::uuid){{end}}
+			order by l.sort_ts desc, l.id desc{{if .Fetch}}
+			limit {{.Fetch}}{{end}}
+		),
+		numbered as (
+			select *, row_number() over (order by sort_ts desc, id desc) as rn
+			from page
 		)
 		select cast(coalesce(to_json(list(json_object(
 			'id',             l.id,
-			'timestamp',      cast(coalesce(nullif(l.timestamp, 0), l.observed_timestamp) as varchar),
+			'timestamp',      cast(l.sort_ts as varchar),
 			'severityText',   l.severity_text,
 			'severityNumber', l.severity_number,
 			'serviceName',    l.service_name,
 			'bodyPreview',    body_preview(l.body)
-		) order by coalesce(nullif(l.timestamp, 0), l.observed_timestamp) desc)), '[]') as varchar) as logs
-		from filtered l
\ No newline at end of file
+		) order by l.rn)), '[]') as varchar) as logs,
+		{{- if .Limit}}
+		(select n.sort_ts from numbered n
+		 where n.rn = {{.Limit}} and exists (select 1 from numbered x where x.rn > {{.Limit}})) as next_key,
+		(select n.id::varchar from numbered n
+		 where n.rn = {{.Limit}} and exists (select 1 from numbered x where x.rn > {{.Limit}})) as next_id
+		from numbered l
+		where l.rn <= {{.Limit}}
+		{{- else}}
+		null::bigint as next_key,
+		null::varchar as next_id
+		from numbered l
+		{{- end}}
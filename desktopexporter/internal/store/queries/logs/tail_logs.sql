@@ -0,0 +1,23 @@
+-- The rows one tail wake-up names, filtered by the caller's query and shaped
+-- like searchLogs items so a client renders both with the same code.
+--
+-- Driven from the key list rather than from logs: the list is at most a few
+-- thousand ids, and joining it to the primary key touches only those rows,
+-- where a predicate-first scan would walk the whole table on every batch.
+{{.CTEs}},
+		arrived as (
+			select unnest(tail_keys::varchar[])::uuid as id from search_params
+		)
+		select cast(coalesce(to_json(list(json_object(
+			'id',             l.id,
+			'timestamp',      cast(coalesce(nullif(l.timestamp, 0), l.observed_timestamp) as varchar),
+			'severityText',   l.severity_text,
+			'severityNumber', l.severity_number,
+			'serviceName',    l.service_name,
+			'bodyPreview',    body_preview(l.body)
+		) order by coalesce(nullif(l.timestamp, 0), l.observed_timestamp), l.id)), '[]') as varchar) as logs
+		from search_params, arrived a
+			join logs l on l.id = a.id
+			join resources r on r.id = l.resource_id
+			join scopes sc on sc.id = l.scope_id
+		where {{.Where}}
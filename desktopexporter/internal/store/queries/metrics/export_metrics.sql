@@ -0,0 +1,132 @@
+		-- A set of metric streams, with their datapoints inside a window, in the
+		-- shape metrics.ExportOTLP rebuilds pdata from.
+		--
+		-- A stream is an identity, not a Metric: every batch that reported it
+		-- wrote its own metric_ingests row, and it is the ingest that knows the
+		-- resource, scope, schema urls, description and metadata the Metric was
+		-- sent under. So one Metric comes out per stream per distinct set of
+		-- those, carrying every datapoint whose ingest shares them -- a counter
+		-- scraped a thousand times comes back as one Metric holding a thousand
+		-- points, not a thousand Metrics holding one.
+		--
+		-- Metrics come out grouped by resource and scope, as in export_spans.sql,
+		-- then by name. Datapoints go by time; exemplars, which the store keeps
+		-- no order for either, by time and then value.
+		with input as (
+			select ?::bigint as time_start,
+				?::bigint as time_end
+		),
+
+		selected_streams as materialized (
+			select st.*
+			from metric_streams st
+			where st.id in (select unnest(?::varchar[])::uuid)
+		),
+
+		selected_points as materialized (
+			select d.*
+			from datapoints d, input
+			where d.stream_id in (select id from selected_streams)
+				and d.timestamp >= input.time_start and d.timestamp <= input.time_end
+		),
+
+		ingest_data as (
+			select m.*
+			from metric_ingests m
+			where m.id in (select metric_ingest_id from selected_points)
+		),
+
+		resource_data as (
+			select r.id, r.seq, resource_json(r.attribute_ids, r.dropped_attributes_count) as obj
+			from resources r
+			where r.id in (select resource_id from ingest_data)
+		),
+
+		scope_data as (
+			select sc.id, sc.seq,
+				scope_json(sc.name, sc.version, sc.attribute_ids, sc.dropped_attributes_count) as obj
+			from scopes sc
+			where sc.id in (select scope_id from ingest_data)
+		),
+
+		exemplar_data as (
+			select e.datapoint_id,
+				to_json(list(exemplar_json(e) order by e.timestamp, e.value)) as exemplars
+			from exemplars e
+			where e.datapoint_id in (select id from selected_points)
+			group by e.datapoint_id
+		),
+
+		-- Every representation's columns, null where they do not apply; the
+		-- caller reads the ones metricType says are there.
+		point_data as (
+			select d.metric_ingest_id, d.timestamp, d.attribute_ids, d.id,
+				json_object(
+					'timestamp', d.timestamp::varchar,
+					'startTime', d.start_time::varchar,
+					'flags', d.flags,
+					'attributes', attrs_json(d.attribute_ids),
+					'valueType', d.value_type,
+					'intValue', d.int_value,
+					'doubleValue', d.double_value,
+					'count', d.count,
+					'sum', d.sum,
+					'min', d.min,
+					'max', d.max,
+					'bucketCounts', d.bucket_counts,
+					'explicitBounds', hb.bounds,
+					'scale', d.scale,
+					'zeroCount', d.zero_count,
+					'zeroThreshold', d.zero_threshold,
+					'positiveOffset', d.positive_bucket_offset,
+					'positiveCounts', d.positive_bucket_counts,
+					'negativeOffset', d.negative_bucket_offset,
+					'negativeCounts', d.negative_bucket_counts,
+					'quantileValues', d.quantile_values,
+					'exemplars', coalesce(ed.exemplars, json('[]'))
+				) as obj
+			from selected_points d
+			left join histogram_bounds hb on hb.id = d.bounds_id
+			left join exemplar_data ed on ed.datapoint_id = d.id
+		),
+
+		metric_data as (
+			select rd.seq as r, i.resource_schema_url, scd.seq as s, i.scope_schema_url,
+				st.id as stream_id, st.name, st.unit, st.metric_type,
+				st.aggregation_temporality, st.is_monotonic,
+				i.description, i.metadata_ids,
+				to_json(list(p.obj order by p.timestamp, p.attribute_ids, p.id)) as datapoints
+			from point_data p
+			join ingest_data i on i.id = p.metric_ingest_id
+			join selected_streams st on st.id = i.stream_id
+			join resource_data rd on rd.id = i.resource_id
+			join scope_data scd on scd.id = i.scope_id
+			group by rd.seq, i.resource_schema_url, scd.seq, i.scope_schema_url,
+				st.id, st.name, st.unit, st.metric_type,
+				st.aggregation_temporality, st.is_monotonic,
+				i.description, i.metadata_ids
+		)
+
+		select cast(json_object(
+			'resources', coalesce((select json_group_object(seq::varchar, obj) from resource_data), json('{}')),
+			'scopes', coalesce((select json_group_object(seq::varchar, obj) from scope_data), json('{}')),
+			'streamIDs', coalesce((select to_json(list(id::varchar)) from selected_streams), json('[]')),
+			'metrics', coalesce((
+				select to_json(list(json_object(
+					'r', m.r,
+					's', m.s,
+					'resourceSchemaURL', m.resource_schema_url,
+					'scopeSchemaURL', m.scope_schema_url,
+					'name', m.name,
+					'unit', m.unit,
+					'description', m.description,
+					'metadata', attrs_json(m.metadata_ids),
+					'metricType', m.metric_type,
+					'aggregationTemporality', m.aggregation_temporality,
+					'isMonotonic', m.is_monotonic,
+					'datapoints', m.datapoints
+				) order by m.r, m.resource_schema_url, m.s, m.scope_schema_url,
+					m.name, m.unit, m.metric_type, m.stream_id, m.description, m.metadata_ids))
+				from metric_data m
+			), json('[]'))
+		) as varchar)
@@ -117,7 +117,8 @@
 		),
 		-- Datapoints inherit aggregation_temporality / is_monotonic from
 		-- the stream so the per-type JSON projection below doesn't need
-		-- a per-row join.
+		-- a per-row join. metric_type is the row's own: datapoints carry it
+		-- for their CHECK constraints, and it always agrees with the stream's.
 		-- resource_id rides along for the per-batch resource of each datapoint.
 		-- It is NOT what groups a series: `resources` is content-addressed, so
 		-- one instance that gets enriched mid-stream owns several rows there,
@@ -136,7 +137,6 @@
 				-- when it named no zone.
 				coalesce(tz_offset_ns_at(d.timestamp, input.tz_name),
 				         input.tz_offset_ns) as tz_shift,
-				s.metric_type as metric_type,
 				s.aggregation_temporality as aggregation_temporality,
 				s.is_monotonic as is_monotonic
 			from datapoints d
@@ -367,6 +367,11 @@
 				when s.metric_type in ('Histogram', 'ExponentialHistogram')
 				     and s.aggregation_temporality in ('Delta', 'Cumulative')
 					then bucket_width_ns(rs.span_ns, i.target_buckets)
+				-- Summary falls through to null, so it is never reduced. It has
+				-- no scalar to elect on and its quantiles cannot be merged --
+				-- the p99 of two windows is not a function of their p99s -- so
+				-- the only honest reduction would be dropping datapoints, which
+				-- is the data loss the histogram note above refuses.
 			end as width_ns
 			-- reduction_span as a relation, not a scalar subquery: the comment
 			-- above applies to it for the same reason it applies to the window.
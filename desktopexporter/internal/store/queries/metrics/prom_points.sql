@@ -0,0 +1,30 @@
+		-- The datapoints of a set of series inside a window, in series and
+		-- time order, with each representation's columns.
+		--
+		-- A delta stream's points before the window come back too. Prometheus
+		-- only knows cumulative counters, so promql turns a delta series into
+		-- one by summing it from the start, and a running total that began at
+		-- the window's edge would make every rate over it wrong in the same
+		-- direction.
+		with input as (
+			select ?::bigint as time_start,
+				?::bigint as time_end
+		)
+
+		select cast(coalesce(to_json(list(json_object(
+			'seriesID', d.series_id::varchar,
+			'timestamp', d.timestamp::varchar,
+			'value', coalesce(d.double_value, d.int_value::double),
+			'count', d.count,
+			'sum', d.sum,
+			'bucketCounts', d.bucket_counts,
+			'bounds', hb.bounds,
+			'quantiles', d.quantile_values
+		) order by d.series_id, d.timestamp)), json('[]')) as varchar)
+		from datapoints d
+		join metric_streams st on st.id = d.stream_id
+		left join histogram_bounds hb on hb.id = d.bounds_id,
+		input
+		where d.series_id in (select id from uuid_list(?))
+			and d.timestamp <= input.time_end
+			and (d.timestamp >= input.time_start or st.aggregation_temporality = 'Delta')
@@ -0,0 +1,65 @@
+		-- Every series with a datapoint in the window, with what promql needs
+		-- to name and label it: the stream's identity, the datapoint labels,
+		-- and the resource's, which carry job and instance.
+		--
+		-- Labels are the series' own attribute_ids rather than a datapoint's:
+		-- the series id is derived from them, so every point in the series
+		-- shares them by construction.
+		--
+		-- A histogram's le labels come from its bounds, which live on the
+		-- datapoints rather than the series. Collected per series as the union
+		-- of every bounds vector it has used -- in practice one, since an SDK
+		-- fixes its buckets when the instrument is created.
+		with input as (
+			select ?::bigint as time_start,
+				?::bigint as time_end
+		),
+
+		active as materialized (
+			select distinct d.series_id
+			from datapoints d, input
+			where d.timestamp >= input.time_start and d.timestamp <= input.time_end
+		),
+
+		series_bounds as (
+			select d.series_id, list(distinct t.b order by t.b) as les
+			from (
+				select distinct series_id, bounds_id
+				from datapoints
+				where bounds_id is not null
+					and series_id in (select series_id from active)
+			) d
+			join histogram_bounds hb on hb.id = d.bounds_id,
+			unnest(hb.bounds) as t(b)
+			group by d.series_id
+		),
+
+		-- A summary's quantile labels the same way, from the quantiles its
+		-- points reported.
+		series_quantiles as (
+			select d.series_id, list(distinct t.q.quantile order by t.q.quantile) as qs
+			from datapoints d,
+			unnest(d.quantile_values) as t(q)
+			where d.quantile_values is not null
+				and d.series_id in (select series_id from active)
+			group by d.series_id
+		)
+
+		select cast(coalesce(to_json(list(json_object(
+			'id', ms.id::varchar,
+			'name', st.name,
+			'unit', st.unit,
+			'metricType', st.metric_type,
+			'temporality', st.aggregation_temporality,
+			'isMonotonic', st.is_monotonic,
+			'attributes', attrs_json(ms.attribute_ids),
+			'resource', attrs_json(r.attribute_ids),
+			'bounds', coalesce(sb.les, []),
+			'quantiles', coalesce(sq.qs, [])
+		) order by st.name, ms.id)), json('[]')) as varchar)
+		from metric_series ms
+		join metric_streams st on st.id = ms.stream_id
+		join resources r on r.id = ms.resource_id
+		left join series_bounds sb on sb.series_id = ms.id
+		left join series_quantiles sq on sq.series_id = ms.id
+		where ms.id in (select series_id from active)
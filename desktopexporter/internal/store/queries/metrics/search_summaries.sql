@@ -72,6 +72,26 @@
 			inner join filtered_streams fs on fs.id = d.stream_id
 			where fs.metric_type in ('Gauge', 'Sum')
 			group by d.stream_id
+		),
+		-- Keyset page over (last datapoint, stream id), newest first; see
+		-- search_traces.sql. The key coalesces a stream with no datapoint in
+		-- the window to the smallest bigint, which is where "nulls last"
+		-- already put it, so the cursor can compare it like any other.
+		ordered as (
+			select fs.*, coalesce(sldp.last_dp_ts, -9223372036854775808) as sort_ts
+			from filtered_streams fs
+			left join stream_latest_dp sldp on sldp.stream_id = fs.id
+		),
+		page as (
+			select o.* from ordered o{{if .After}}, search_params
+			where o.sort_ts < cursor_key
+				or (o.sort_ts = cursor_key and o.id < cursor_id::uuid){{end}}
+			order by o.sort_ts desc, o.id desc{{if .Fetch}}
+			limit {{.Fetch}}{{end}}
+		),
+		numbered as (
+			select *, row_number() over (order by sort_ts desc, id desc) as rn
+			from page
 		)
 		select cast(coalesce(to_json(list(json_object(
 			'id', cast(fs.id as varchar),
@@ -90,12 +110,23 @@
 			'dataPointCount', sdc.datapoint_count,
 			'lastValue', slv.last_value,
 			'lastSeen', sldp.last_dp_ts::varchar
-		) order by sldp.last_dp_ts desc nulls last)), '[]') as varchar) as summaries
-		from filtered_streams fs
+		) order by fs.rn)), '[]') as varchar) as summaries,
+		{{- if .Limit}}
+		(select n.sort_ts from numbered n
+		 where n.rn = {{.Limit}} and exists (select 1 from numbered x where x.rn > {{.Limit}})) as next_key,
+		(select n.id::varchar from numbered n
+		 where n.rn = {{.Limit}} and exists (select 1 from numbered x where x.rn > {{.Limit}})) as next_id
+		{{- else}}
+		null::bigint as next_key,
+		null::varchar as next_id
+		{{- end}}
+		from numbered fs
 		left join stream_latest_dp sldp on sldp.stream_id = fs.id
 		left join stream_description sd on sd.stream_id = fs.id
 		left join stream_series_count ssc on ssc.stream_id = fs.id
 		left join stream_series_cardinality ssx on ssx.stream_id = fs.id
 		left join stream_datapoint_count sdc on sdc.stream_id = fs.id
 		left join stream_last_value slv on slv.stream_id = fs.id
-	
\ No newline at end of file
+		{{- if .Limit}}
+		where fs.rn <= {{.Limit}}
+		{{- end}}
@@ -0,0 +1,64 @@
+package queries
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// Migration is one numbered up-migration: the SQL that brings a database
+// stamped Version-1 up to Version.
+//
+// Unlike the other ddl/ directories, migrations/ has no `_order` manifest.
+// The number in each filename is the order, and it is also the schema
+// version the step produces, so a second place writing it down could only
+// disagree with the first.
+//
+// A migration runs against the store's working copy, not the tables
+// themselves: temporary, unconstrained copies the runner in the store package
+// makes first, so a step may drop or rewrite columns that an index or a
+// foreign key would otherwise pin. Unqualified names resolve to those copies.
+// The runner rebuilds the real tables from ddl/tables once every step has
+// run, which is why no step creates an index, a constraint or a real table.
+type Migration struct {
+	Version int
+	Statement
+}
+
+var migrations = loadMigrations()
+
+// Migrations returns every up-migration, oldest first.
+func Migrations() []Migration { return append([]Migration(nil), migrations...) }
+
+// loadMigrations reads ddl/migrations and fails init on a name that does not
+// parse or a number that repeats or goes backwards -- the runner trusts this
+// list to be a chain, and the store's tests check that it reaches
+// schema.Version without a gap.
+func loadMigrations() []Migration {
+	entries, err := fs.ReadDir(files, "ddl/migrations")
+	if err != nil {
+		// Unreachable: go:embed fails at compile time if the directory is empty.
+		panic("queries: reading migrations: " + err.Error())
+	}
+	var out []Migration
+	for _, e := range entries {
+		n := e.Name()
+		num, _, ok := strings.Cut(n, "_")
+		v, err := strconv.Atoi(num)
+		if !ok || err != nil || len(num) != 3 || !strings.HasSuffix(n, ".sql") {
+			panic("queries: migration " + n + " is not named NNN_description.sql")
+		}
+		if len(out) > 0 && v <= out[len(out)-1].Version {
+			panic(fmt.Sprintf("queries: migration %s repeats or precedes version %d", n, out[len(out)-1].Version))
+		}
+		out = append(out, Migration{
+			Version: v,
+			Statement: Statement{
+				Name: "migrations/" + n,
+				SQL:  mustRead("ddl/migrations/" + n),
+			},
+		})
+	}
+	return out
+}
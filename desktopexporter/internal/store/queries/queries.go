@@ -4,8 +4,16 @@
 // which signal runs it:
 //
 //   - ddl/ is structure: types, tables, indexes and macros, run once when a
-//     store is opened. Ordered, because tables reference each other.
+//     store is opened. Ordered, because tables reference each other. Its
+//     migrations/ holds the numbered steps that bring an older file up to
+//     date first.
 //   - spans/, logs/, metrics/ are the read path: one file per query.
+//   - issues/ reads across signals -- exceptions recorded as span events and
+//     as logs alike -- so it belongs to neither signal's directory.
+//   - facets/ and compare/ are statements every signal's search feeds, with
+//     the FROM and the columns filled in by whichever signal is asked.
+//   - bundle/ copies a slice of the store into another database for export.
+//     A read of this store too, though what it writes is someone else's.
 //
 // Ingest stays in the signal packages. It is Go walking pdata and driving
 // appenders, not SQL, and moving it here would separate it from the types it
@@ -48,9 +56,9 @@ import (
 	"text/template"
 )
 
-//go:embed ddl/types/*.sql ddl/tables/*.sql ddl/indexes/*.sql ddl/macros/*.sql
+//go:embed ddl/types/*.sql ddl/tables/*.sql ddl/indexes/*.sql ddl/macros/*.sql ddl/migrations/*.sql
 //go:embed ddl/types/_order ddl/tables/_order ddl/indexes/_order ddl/macros/_order
-//go:embed spans/*.sql metrics/*.sql logs/*.sql
+//go:embed spans/*.sql metrics/*.sql logs/*.sql issues/*.sql facets/*.sql compare/*.sql bundle/*.sql
 var files embed.FS
 
 // Statement is one DDL object: the SQL, plus the file it came from.
@@ -69,6 +77,27 @@ func Tables() []Statement  { return ddl("tables", tableFiles) }
 func Indexes() []Statement { return ddl("indexes", indexFiles) }
 func Macros() []Statement  { return ddl("macros", macroFiles) }
 
+// TempMacros is Macros as temporary macros, for a file opened read-only. Its
+// own macros are whatever the build that wrote it had, which for a file of
+// this same version can be fewer than this build reads with -- macros added
+// without a schema change, the way the exception ones were. A temp macro
+// lives in the connection rather than the file, so a read-only file can take
+// one, and it shadows the file's own of the same name.
+func TempMacros() []Statement {
+	out := Macros()
+	for i, stmt := range out {
+		if strings.Count(stmt.SQL, createMacro) != 1 {
+			// Unreachable while every macro file is written the way the
+			// others are; a file that is not must fail here, not half-work.
+			panic("queries: " + stmt.Name + " does not hold exactly one " + createMacro)
+		}
+		out[i].SQL = strings.Replace(stmt.SQL, createMacro, "create or replace temp macro", 1)
+	}
+	return out
+}
+
+const createMacro = "create or replace macro"
+
 func ddl(kind string, names []string) []Statement {
 	out := make([]Statement, 0, len(names))
 	for _, n := range names {
@@ -100,32 +129,113 @@ const (
 	// and only a non-zero count sends the caller here.
 	SalvageSpans Name = "spans/salvage_spans.sql"
 
+	// TraceWalk is the CTE chain that places one trace's spans -- roots,
+	// depth and sibling order -- which SearchSpans, SalvageSpans,
+	// DescribeTrace and CriticalPath open with. Rendered first and passed to
+	// them as .Walk.
+	TraceWalk Name = "spans/trace_walk.sql"
+
 	// SearchTraces lists trace summaries for the trace list view.
 	SearchTraces Name = "spans/search_traces.sql"
 
+	// DescribeTrace summarises one trace -- counts, services, root, depth and
+	// its slowest spans -- without shipping the spans themselves.
+	DescribeTrace Name = "spans/describe_trace.sql"
+
+	// CriticalPath lists one trace's placed spans with their parentage and
+	// timings, for spans.CriticalPath to walk.
+	CriticalPath Name = "spans/critical_path.sql"
+
+	// DiffTraces lists one trace's placed spans, with their parentage,
+	// timings, status and attributes, for spans.DiffTraces to align against
+	// another's.
+	DiffTraces Name = "spans/diff_traces.sql"
+
+	// ServiceGraph derives service-to-service edges from parent/child spans
+	// and span links, over the traces a trace search selects.
+	ServiceGraph Name = "spans/service_graph.sql"
+
+	// OperationStats computes rate, errors and duration percentiles per
+	// service and operation from stored spans, optionally per time bucket.
+	OperationStats Name = "spans/operation_stats.sql"
+
+	// TailSpans returns the just-ingested spans a live tail names that match
+	// its query.
+	TailSpans Name = "spans/tail_spans.sql"
+
+	// ExportSpans returns every span of a set of traces, with resources and
+	// scopes, for rebuilding them as OTLP.
+	ExportSpans Name = "spans/export_spans.sql"
+
+	// ListServices and ListOperations name the services that reported spans
+	// and the span names one of them reported, for Jaeger's query API.
+	ListServices   Name = "spans/list_services.sql"
+	ListOperations Name = "spans/list_operations.sql"
+
 	// GetMetric returns one stream's series and datapoints in a time window.
 	GetMetric Name = "metrics/get_metric.sql"
 	// GetMetricAttributes lists the attribute keys metrics carry.
 	GetMetricAttributes Name = "metrics/get_metric_attributes.sql"
+	// ExportMetrics returns the datapoints of a set of streams, with the
+	// batches they arrived in, for rebuilding them as OTLP.
+	ExportMetrics Name = "metrics/export_metrics.sql"
+	// PromSeries lists the series with datapoints in a window, labelled the
+	// way the Prometheus API needs them.
+	PromSeries Name = "metrics/prom_series.sql"
+	// PromPoints returns the datapoints of a set of series in a window.
+	PromPoints Name = "metrics/prom_points.sql"
 
 	// GetLog returns one log record with its attributes resolved.
 	GetLog Name = "logs/get_log.sql"
 	// GetLogAttributes lists the attribute keys logs carry.
 	GetLogAttributes Name = "logs/get_log_attributes.sql"
+	// ExportLogs returns a set of log records, with resources and scopes, for
+	// rebuilding them as OTLP.
+	ExportLogs Name = "logs/export_logs.sql"
 
 	// SearchMetricSummaries lists metric streams for the metrics list view.
 	SearchMetricSummaries Name = "metrics/search_summaries.sql"
 	// SearchLogs lists log summaries for the logs list view.
 	SearchLogs Name = "logs/search_logs.sql"
+	// TailLogs returns the just-ingested logs a live tail names that match its
+	// query.
+	TailLogs Name = "logs/tail_logs.sql"
+
+	// IssueOccurrences is the CTE chain ListIssues and GetIssue open with:
+	// every exception a window holds, from span events and logs, with its
+	// fingerprint. Rendered first and passed to them as .Occurrences.
+	IssueOccurrences Name = "issues/occurrences.sql"
+	// ListIssues groups a window's exceptions by fingerprint.
+	ListIssues Name = "issues/list_issues.sql"
+	// GetIssue returns one fingerprint's occurrences.
+	GetIssue Name = "issues/get_issue.sql"
+
+	// Facets counts the values of fields and attributes over the rows a
+	// search matches, for any signal that builds a search.FacetSource.
+	Facets Name = "facets/facets.sql"
+
+	// CompareAttributes counts attribute values in two searches' rows, for
+	// any signal that builds a search.CompareSource.
+	CompareAttributes Name = "compare/compare_attributes.sql"
+
+	// BundleFilter binds what a filtered export bundle selects.
+	BundleFilter Name = "bundle/bundle_filter.sql"
+	// BundleCopyRows copies the rows BundleFilter selects, and everything they
+	// reference, into the database a bundle is exported from.
+	BundleCopyRows Name = "bundle/copy_rows.sql"
 )
 
 // queryNames is every read-path query. Kept beside the constants so adding one
 // without registering it is a visible omission rather than a silent one.
 var queryNames = []Name{
-	SearchSpans, SalvageSpans, SearchTraces,
-	GetMetric, GetMetricAttributes,
-	GetLog, GetLogAttributes,
-	SearchMetricSummaries, SearchLogs,
+	SearchSpans, SalvageSpans, TraceWalk, SearchTraces, DescribeTrace, CriticalPath, DiffTraces, ServiceGraph, OperationStats, TailSpans, ExportSpans,
+	ListServices, ListOperations,
+	GetMetric, GetMetricAttributes, ExportMetrics, PromSeries, PromPoints,
+	GetLog, GetLogAttributes, ExportLogs,
+	SearchMetricSummaries, SearchLogs, TailLogs,
+	IssueOccurrences, ListIssues, GetIssue,
+	Facets, CompareAttributes,
+	BundleFilter, BundleCopyRows,
 }
 
 // Names returns every registered read-path query, so callers that need to
@@ -161,6 +271,9 @@ func checkRegistered(parsed map[Name]*template.Template) {
 			known[path.Join("ddl", kind, n)] = true
 		}
 	}
+	for _, m := range migrations {
+		known[path.Join("ddl", m.Name)] = true
+	}
 	err := fs.WalkDir(files, ".", func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -1054,3 +1054,76 @@ func TestMacros_DiffBucketVectorsUnsignedReset(t *testing.T) {
 		assert.Equal(t, "[3, 6]", got)
 	})
 }
+
+// TestMacros_ExceptionFingerprint pins what the issue fingerprint ignores and
+// what it does not: two reports of one failure must hash alike across the
+// values in their messages and the line numbers in their traces, and two
+// different failures must not.
+func TestMacros_ExceptionFingerprint(t *testing.T) {
+	db := setupMacroDB(t)
+
+	scalar := func(t *testing.T, query string, args ...any) string {
+		t.Helper()
+		var v string
+		require.NoErrorf(t, db.QueryRow(query, args...).Scan(&v), "query failed: %s", query)
+		return v
+	}
+
+	t.Run("message template", func(t *testing.T) {
+		cases := []struct{ in, want string }{
+			{`order 1234 not found`, `order <n> not found`},
+			{`user "alice" has no cart`, `user "<str>" has no cart`},
+			{`key 'abc' missing`, `key '<str>' missing`},
+			{`session 3f2b8c1e-0d4a-4e6b-9b7a-1c2d3e4f5a6b expired`, `session <uuid> expired`},
+			{`bad pointer 0xc000010000`, `bad pointer <hex>`},
+			{`digest deadbeefdeadbeef0123 mismatch`, `digest <hex> mismatch`},
+			{"  timeout   after\n5.5s  ", `timeout after <n>s`},
+			{`ValueError`, `ValueError`},
+		}
+		for _, tc := range cases {
+			assert.Equal(t, tc.want, scalar(t, `select exception_message_template(?)`, tc.in), tc.in)
+		}
+	})
+
+	t.Run("top frame", func(t *testing.T) {
+		cases := []struct{ name, in, want string }{
+			{"python, innermost is last",
+				"Traceback (most recent call last):\n" +
+					"  File \"/app/main.py\", line 10, in <module>\n" +
+					"  File \"/app/cart.py\", line 42, in checkout\n" +
+					"ValueError: bad quantity\n",
+				`File "/app/cart.py", in checkout`},
+			{"java, innermost is first",
+				"java.lang.IllegalStateException: closed\n" +
+					"\tat com.shop.Cart.checkout(Cart.java:88)\n" +
+					"\tat com.shop.Main.main(Main.java:12)\n",
+				"com.shop.Cart.checkout(Cart.java)"},
+			{"go, receiver kept and arguments dropped",
+				"goroutine 1 [running]:\n" +
+					"main.(*Cart).Checkout(0xc000010000)\n" +
+					"\t/src/cart.go:88 +0x1d\n",
+				"main.(*Cart).Checkout"},
+			{"unrecognised", "something went wrong", ""},
+		}
+		for _, tc := range cases {
+			assert.Equal(t, tc.want, scalar(t, `select exception_top_frame(?)`, tc.in), tc.name)
+		}
+		var v sql.NullString
+		require.NoError(t, db.QueryRow(`select exception_top_frame(null::varchar)`).Scan(&v))
+		assert.Equal(t, "", v.String, "a missing stacktrace has no frame")
+	})
+
+	t.Run("fingerprint", func(t *testing.T) {
+		fp := func(exType, frame, template string) string {
+			return scalar(t, `select exception_fingerprint(?, ?, ?)`, exType, frame, template)
+		}
+		a := fp("ValueError", "cart.checkout", "bad <n>")
+		assert.Len(t, a, 16)
+		assert.Equal(t, a, fp("ValueError", "cart.checkout", "bad <n>"))
+		assert.NotEqual(t, a, fp("TypeError", "cart.checkout", "bad <n>"))
+		assert.NotEqual(t, a, fp("ValueError", "cart.pay", "bad <n>"))
+		// Length-prefixed, so moving text across the field boundary is a
+		// different fingerprint rather than the same concatenation.
+		assert.NotEqual(t, fp("ab", "c", ""), fp("a", "bc", ""))
+	})
+}
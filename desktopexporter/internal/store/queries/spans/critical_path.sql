@@ -0,0 +1,46 @@
+		-- getCriticalPath: one trace's placed spans, with the timings and
+		-- parentage spans.GetCriticalPath walks to find the path.
+		--
+		-- The walk is trace_walk.sql, the one searchSpans places spans with,
+		-- so a span is placed, and at the depth, the waterfall draws it. The
+		-- path itself is computed in Go: it is a backwards scan choosing one
+		-- child at a time against a moving cursor, which SQL can only express
+		-- as another recursive CTE carrying the whole remaining sibling set
+		-- per row.
+		with recursive
+		params as (
+			select ?::uuid as trace_id
+		),
+
+		{{.Walk}},
+
+		placed as materialized (
+			select s.span_id, s.parent_span_id, s.name, s.service_name,
+				s.start_time, s.end_time, st.depth
+			from spans_tree st
+			join spans s on s.span_id = st.span_id
+		)
+
+		select cast(json_object(
+			'traceID', trace_id_wire((select trace_id from params)),
+			-- The same baseline as searchSpans' traceStart: the earliest placed
+			-- start, whether or not the trace has a root and whatever the
+			-- clocks of the hosts that reported it.
+			'traceStart', (select min(start_time) from placed)::varchar,
+			'unplacedSpanCount', (select count(*) from trace_spans) - (select count(*) from placed),
+			'spans', coalesce((
+				select to_json(list(json_object(
+					'spanID', span_id_wire(span_id),
+					-- Null for a root, including one promoted because its
+					-- parent never arrived: depth 0 is what makes a root here.
+					'parentSpanID', case when depth > 0 then span_id_wire(parent_span_id) end,
+					'name', name,
+					'serviceName', nullif(service_name, ''),
+					'depth', depth,
+					'startTime', start_time::varchar,
+					'endTime', end_time::varchar
+				) order by start_time, span_id))
+				from placed
+			), json('[]'))
+		) as varchar) as spans
+		where exists (select 1 from trace_spans)
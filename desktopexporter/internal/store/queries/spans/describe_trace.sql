@@ -0,0 +1,108 @@
+		-- describeTrace: the facts about one trace that are otherwise only
+		-- learned by fetching all of it.
+		--
+		-- The walk is trace_walk.sql, the one searchSpans places spans with:
+		-- the same roots (a span whose parent is null or absent from the
+		-- trace) and the same descent, so a depth reported here is the
+		-- indentation the waterfall draws. Durations are end_time -
+		-- start_time, which is the bar width span_data_json ships as `dur` --
+		-- not clamped, not rounded, so "slowest" here and longest bar there
+		-- are the same span.
+		with recursive
+		params as (
+			select ?::uuid as trace_id
+		),
+
+		{{.Walk}},
+
+		-- What the summary reads of each span, joined onto the walk's
+		-- trace_spans by key rather than found in `spans` by trace id again.
+		trace_rows as materialized (
+			select s.span_id, s.parent_span_id, s.name, s.service_name,
+				s.start_time, s.end_time, s.status_code
+			from trace_spans t
+			join spans s on s.span_id = t.span_id
+		),
+
+		-- Every span, with a null depth for one the walk could not place.
+		placed as materialized (
+			select r.*, st.depth
+			from trace_rows r
+			left join spans_tree st on st.span_id = r.span_id
+		),
+
+		-- The root the trace list names: a span with no parent, earliest first
+		-- when a trace has several. A span promoted to the top because its
+		-- parent never arrived is not a root in this sense, and is counted as
+		-- missingParentCount instead.
+		root as (
+			select span_id, name, service_name, start_time, end_time
+			from trace_rows
+			where parent_span_id is null
+			order by start_time, span_id
+			limit 1
+		),
+
+		slowest as (
+			select span_id, name, service_name, depth, status_code,
+				start_time, end_time - start_time as dur
+			from placed
+			order by dur desc, span_id
+			limit 5
+		),
+
+		bounds as (
+			select min(start_time) as trace_start, max(end_time) as trace_end
+			from trace_rows
+		)
+
+		select cast(json_object(
+			'traceID', trace_id_wire((select trace_id from params)),
+			'spanCount', (select count(*) from trace_rows),
+			'errorCount', (select count(*) from trace_rows where status_code = 'Error'),
+			-- nullif as in the trace summary: an empty service name is no
+			-- service, not a service called ''.
+			'services', coalesce((
+				select to_json(list(distinct service_name order by service_name))
+				from trace_rows where service_name <> ''
+			), json('[]')),
+			'rootSpan', (
+				select json_object(
+					'spanID', span_id_wire(span_id),
+					'name', name,
+					'serviceName', nullif(service_name, ''),
+					'durationNs', (end_time - start_time)::varchar
+				) from root
+			),
+			-- Tree levels, so a lone span is 1. Unplaced spans have no depth and
+			-- do not count toward it.
+			'depth', (select coalesce(max(depth) + 1, 0) from placed),
+			'startTime', (select trace_start::varchar from bounds),
+			'endTime', (select trace_end::varchar from bounds),
+			'durationNs', (select (trace_end - trace_start)::varchar from bounds),
+			-- Spans whose parent is not in the store: shown as roots by the
+			-- waterfall, usually because the parent came from a service that
+			-- exports elsewhere or has not flushed yet.
+			'missingParentCount', (
+				select count(*) from trace_rows
+				where parent_span_id is not null
+					and parent_span_id not in (select span_id from trace_rows)
+			),
+			-- Spans on a parent cycle, which no root reaches. See
+			-- unplacedSpanCount in search_spans.sql.
+			'unplacedSpanCount', (select count(*) from placed where depth is null),
+			'slowestSpans', coalesce((
+				select to_json(list(json_object(
+					'spanID', span_id_wire(span_id),
+					'name', name,
+					'serviceName', nullif(service_name, ''),
+					'statusCode', status_code,
+					'depth', depth,
+					-- From trace start, as the waterfall positions the bar.
+					'startOffsetNs', (start_time - (select trace_start from bounds))::varchar,
+					'durationNs', dur::varchar
+				) order by dur desc, span_id))
+				from slowest
+			), json('[]'))
+		) as varchar) as description
+		where exists (select 1 from trace_rows)
@@ -0,0 +1,65 @@
+		-- diffTraces: one side of a diff -- a trace's placed spans, with what
+		-- spans.DiffTraces compares of each. Run once per trace.
+		--
+		-- The walk is trace_walk.sql, the one searchSpans places spans with,
+		-- so the diff places a span, and at the depth, the waterfall draws it:
+		-- a span whose parent never arrived is a root, and one on a parent
+		-- cycle is counted as unplaced. The key the two sides are aligned on
+		-- is built in Go from the walk's parentage; see alignKeys.
+		--
+		-- Attributes are resolved the way search_spans.sql resolves them, and
+		-- ids go through the same wire macros, so whatever the diff reports is
+		-- what the two waterfalls show.
+		with recursive
+		params as (
+			select ?::uuid as trace_id
+		),
+
+		{{.Walk}},
+
+		placed as materialized (
+			select s.span_id, s.parent_span_id, s.name, s.service_name,
+				s.start_time, s.end_time, s.status_code, s.status_message,
+				s.attribute_ids, st.depth
+			from spans_tree st
+			join spans s on s.span_id = st.span_id
+		),
+
+		-- The attribute dictionary narrowed to the trace's ids, as in
+		-- search_spans.sql, so attrs_mapped renders byte-identical arrays.
+		dict_map as materialized (
+			select map(list(id), list({
+				'k': key,
+				'i': id,
+				'j': json_object('key', key, 'value', value, 'type', type::varchar)
+			})) as m
+			from attributes
+			where id in (select unnest(attribute_ids) from placed)
+		)
+
+		select cast(json_object(
+			'traceID', trace_id_wire((select trace_id from params)),
+			-- searchSpans' baseline: the earliest placed start.
+			'traceStart', (select min(start_time) from placed)::varchar,
+			'unplacedSpanCount', (select count(*) from trace_spans) - (select count(*) from placed),
+			'spans', coalesce((
+				select to_json(list(json_object(
+					'spanID', span_id_wire(pl.span_id),
+					-- Null for a root, including one promoted because its
+					-- parent never arrived: depth 0 is what makes a root here.
+					'parentSpanID', case when pl.depth > 0 then span_id_wire(pl.parent_span_id) end,
+					'name', pl.name,
+					'serviceName', nullif(pl.service_name, ''),
+					'depth', pl.depth,
+					'startTime', pl.start_time::varchar,
+					'endTime', pl.end_time::varchar,
+					'statusCode', pl.status_code,
+					'statusMessage', pl.status_message,
+					'attributes', attrs_mapped(pl.attribute_ids, dm.m)
+				) order by pl.start_time, pl.span_id))
+				from placed pl, dict_map dm
+			), json('[]'))
+		) as varchar) as side
+		-- No row for a trace the store does not hold, so the caller can say
+		-- which of the two it was.
+		where exists (select 1 from trace_spans)
@@ -0,0 +1,97 @@
+		-- Every span of a set of traces, in the shape spans.ExportOTLP rebuilds
+		-- pdata from: the searchSpans wire objects, plus what that response
+		-- leaves out because the viewer never needed it -- the trace id per span,
+		-- and the batch-level schema urls.
+		--
+		-- Span order is the regrouping order. Rows come out sorted by resource,
+		-- then scope, each with its schema url, so the caller opens a new
+		-- ResourceSpans or ScopeSpans whenever the key changes and never has to
+		-- look back. seq rather than id puts owners in the order they were
+		-- first stored, which is the nearest thing to the order they were sent.
+		--
+		-- Nothing records the order events and links arrived in, so they are
+		-- put in one: events by time, links by what they point at.
+		with selected as materialized (
+			select s.*
+			from spans s
+			where s.trace_id in (select unnest(?::varchar[])::uuid)
+		),
+
+		-- See search_spans.sql for why attributes are probed out of a map.
+		dict_map as materialized (
+			select map(list(id), list({
+				'k': key,
+				'i': id,
+				'j': json_object('key', key, 'value', value, 'type', type::varchar)
+			})) as m
+			from attributes
+			where id in (
+				select unnest(attribute_ids) from selected
+				union select unnest(e.attribute_ids) from events e
+					where e.span_id in (select span_id from selected)
+				union select unnest(l.attribute_ids) from links l
+					where l.span_id in (select span_id from selected)
+			)
+		),
+
+		event_data as (
+			select e.span_id,
+				to_json(list(event_json(e, attrs_mapped(e.attribute_ids, dm.m))
+					order by e.timestamp, e.name)) as events
+			from events e, dict_map dm
+			where e.span_id in (select span_id from selected)
+			group by e.span_id
+		),
+
+		link_data as (
+			select l.span_id,
+				to_json(list(link_json(l, attrs_mapped(l.attribute_ids, dm.m))
+					order by l.trace_id, l.linked_span_id)) as links
+			from links l, dict_map dm
+			where l.span_id in (select span_id from selected)
+			group by l.span_id
+		),
+
+		resource_data as (
+			select r.id, r.seq, resource_json(r.attribute_ids, r.dropped_attributes_count) as obj
+			from resources r
+			where r.id in (select resource_id from selected)
+		),
+
+		scope_data as (
+			select sc.id, sc.seq,
+				scope_json(sc.name, sc.version, sc.attribute_ids, sc.dropped_attributes_count) as obj
+			from scopes sc
+			where sc.id in (select scope_id from selected)
+		),
+
+		ordered_spans as (
+			select json_merge_patch(
+					-- A zero baseline makes start the absolute start time.
+					span_data_json(s, attrs_mapped(s.attribute_ids, dm.m), ed.events, ld.links,
+						rd.seq, scd.seq, 0),
+					json_object(
+						'traceID', trace_id_wire(s.trace_id),
+						'resourceSchemaURL', s.resource_schema_url,
+						'scopeSchemaURL', s.scope_schema_url
+					)
+				) as span_json,
+				rd.seq as resource_seq, s.resource_schema_url,
+				scd.seq as scope_seq, s.scope_schema_url,
+				s.trace_id, s.start_time, s.span_id
+			from selected s
+			cross join dict_map dm
+			join resource_data rd on rd.id = s.resource_id
+			join scope_data scd on scd.id = s.scope_id
+			left join event_data ed on ed.span_id = s.span_id
+			left join link_data ld on ld.span_id = s.span_id
+		)
+
+		select cast(json_object(
+			'resources', coalesce((select json_group_object(seq::varchar, obj) from resource_data), json('{}')),
+			'scopes', coalesce((select json_group_object(seq::varchar, obj) from scope_data), json('{}')),
+			'traceIDs', coalesce((select to_json(list(distinct trace_id::varchar)) from selected), json('[]')),
+			'spans', coalesce((select to_json(list(span_json order by
+				resource_seq, resource_schema_url, scope_seq, scope_schema_url,
+				trace_id, start_time, span_id)) from ordered_spans), json('[]'))
+		) as varchar)
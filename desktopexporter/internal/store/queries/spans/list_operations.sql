@@ -0,0 +1,6 @@
+		-- The span names one service has reported: Jaeger's operations, which
+		-- its clients offer once a service is picked. Exact match, the way
+		-- Jaeger looks a service up; a pattern belongs in searchTraces.
+		select coalesce(to_json(list(distinct name order by name)), json('[]'))::varchar
+		from spans
+		where service_name = ?
@@ -0,0 +1,8 @@
+		-- The services that have reported spans, for a Jaeger client's service
+		-- picker. Read off the denormalised service_name rather than resolved
+		-- through resources: idx_spans_service answers it without touching the
+		-- attribute dictionary. Spans with no service.name are left out -- there
+		-- is no name to pick them by.
+		select coalesce(to_json(list(distinct service_name order by service_name)), json('[]'))::varchar
+		from spans
+		where service_name <> ''
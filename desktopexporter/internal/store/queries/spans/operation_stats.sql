@@ -0,0 +1,93 @@
+{{.CTEs}},
+		-- RED per group: rate, errors, duration. The span-derived equivalent
+		-- of a spanmetrics connector, computed from what is already stored
+		-- rather than recorded alongside it.
+		--
+		-- Rows are the spans the filter matches, not the traces: a condition
+		-- on http.route selects the spans carrying that route, and those are
+		-- the operations being measured.
+		selected as materialized (
+			select{{range .Dims}}
+				{{.Expr}} as {{.Col}},{{end}}
+				s.start_time,
+				s.end_time - s.start_time as dur,
+				s.status_code = 'Error' as failed
+			{{.From}}
+			where {{.Where}}
+		),
+
+		-- The period rates are measured over: the request clipped to the
+		-- spans actually selected. A window that opens at 0 -- how a script
+		-- asks for "everything" -- would otherwise divide a session's calls by
+		-- fifty-odd years. Reported back as windowNs so the rate can be read
+		-- against it.
+		period as (
+			select greatest(p.time_start, min(x.start_time)) as lo,
+				least(p.time_end, max(x.start_time)) as hi
+			from selected x, search_params p
+			group by p.time_start, p.time_end
+		),
+
+		-- The same ladder getMetric reduces to, so a span-derived series and
+		-- a recorded metric over one window share bucket boundaries. UTC: the
+		-- buckets this is for are minutes, not days.
+		grid as (
+			select bucket_width_ns(pe.hi - pe.lo, p.target_buckets) as w
+			from period pe, search_params p
+		),
+
+		groups as (
+			select{{range .Dims}} {{.Col}},{{end}}
+				count(*) as n,
+				count(*) filter (where failed) as errors,
+				quantile_disc(dur, [0.5, 0.9, 0.99]) as q
+			from selected
+			group by {{if .Dims}}{{range $i, $d := .Dims}}{{if $i}}, {{end}}{{$d.Col}}{{end}}{{else}}(){{end}}
+			having count(*) > 0
+		),
+
+		bucket_rows as (
+			select{{range .Dims}} x.{{.Col}},{{end}}
+				bucket_start_utc(floor_div(x.start_time, g.w) * g.w, null, 0) as bucket_start,
+				g.w,
+				count(*) as n,
+				count(*) filter (where x.failed) as errors,
+				quantile_disc(x.dur, [0.5, 0.9, 0.99]) as q
+			from selected x, grid g
+			where g.w is not null
+			group by{{range .Dims}} x.{{.Col}},{{end}} bucket_start, g.w
+		),
+
+		bucket_lists as (
+			select{{range .Dims}} {{.Col}},{{end}}
+				list(json_object(
+					'start', bucket_start::varchar,
+					'count', n,
+					'errorCount', errors,
+					'ratePerSecond', n / (w / 1e9),
+					'durationNs', json_object('p50', q[1]::varchar, 'p90', q[2]::varchar, 'p99', q[3]::varchar)
+				) order by bucket_start) as buckets
+			from bucket_rows
+			group by {{if .Dims}}{{range $i, $d := .Dims}}{{if $i}}, {{end}}{{$d.Col}}{{end}}{{else}}(){{end}}
+		)
+
+		select cast(json_object(
+			'windowNs', (select (hi - lo)::varchar from period),
+			'bucketWidthNs', (select w::varchar from grid),
+			'groups', coalesce((
+				select to_json(list(json_object({{range .Dims}}
+					'{{.Col}}', g.{{.Col}},{{end}}
+					'count', g.n,
+					'errorCount', g.errors,
+					'errorRatio', g.errors / g.n,
+					-- Null over a zero-length period: one span, or many sharing
+					-- a nanosecond, has no rate to speak of.
+					'ratePerSecond', (select case when hi > lo then g.n / ((hi - lo) / 1e9) end from period),
+					'durationNs', json_object('p50', g.q[1]::varchar, 'p90', g.q[2]::varchar, 'p99', g.q[3]::varchar),
+					'buckets', b.buckets
+				) order by g.n desc{{range .Dims}}, g.{{.Col}} nulls last{{end}}))
+				from groups g
+				left join bucket_lists b on true{{range .Dims}}
+					and b.{{.Col}} is not distinct from g.{{.Col}}{{end}}
+			), json('[]'))
+		) as varchar) as stats
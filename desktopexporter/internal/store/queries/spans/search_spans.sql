@@ -2,74 +2,7 @@
 		with recursive
 		{{.CTEs}},
 
-		-- This trace's spans, isolated once, before the walk begins.
-		--
-		-- The recursive arm below runs once per level of the tree, and a
-		-- recursive CTE cannot use an index on its working table: DuckDB
-		-- re-joins whatever relation the arm names on every iteration. Naming
-		-- `spans` there means each level hash-joins the entire table, so the
-		-- cost of fetching one trace tracks how much telemetry the store holds
-		-- rather than how big the trace is -- a point lookup priced as a scan.
-		-- Measured on a 2.3M-span store, fetching a 159-span trace 14 levels
-		-- deep: 54ms naming `spans`, 5ms naming this CTE, same rows out.
-		--
-		-- `materialized` is the load-bearing word. Without it DuckDB is free to
-		-- inline the definition into each reference, which puts the full-table
-		-- scan back exactly where it was removed from.
-		trace_spans as materialized (
-			select s.trace_id, s.span_id, s.parent_span_id, s.start_time
-			from spans s, search_params
-			where s.trace_id = search_params.trace_id
-		),
-
-		-- Sibling order, decided once, before the walk.
-		--
-		-- A span's rank among its siblings is a property of the trace, not of
-		-- the traversal: it depends only on parent and start time, both known
-		-- before the first row is walked. Computing it with a window inside
-		-- the recursive arm instead re-runs a WINDOW operator once per level
-		-- of the tree, paying full operator setup each time to rank a handful
-		-- of siblings, and that cost is set by tree depth rather than by
-		-- anything the query is being asked for.
-		--
-		-- It dominated. Profiled on a 122k-span store fetching a 159-span
-		-- trace 14 levels deep, WINDOW was 27.9ms of a 46ms query -- against
-		-- 0.8ms for the sequential scan over all 117,618 spans. Ranking once
-		-- here leaves a single WINDOW over the trace's own rows.
-		--
-		-- Traversal order is unchanged, and that is checked rather than
-		-- assumed: same rows, same depths, and the md5 of the span ids in
-		-- sort_path order is identical either way.
-		ranked as materialized (
-			select t.*,
-				row_number() over (
-					partition by t.parent_span_id order by t.start_time
-				) as sibling_rank,
-				row_number() over (order by
-					case when t.parent_span_id is null then 0 else 1 end,
-					t.start_time
-				) as root_rank
-			from trace_spans t
-		),
-
-		spans_tree as (
-			select
-				r.trace_id, r.span_id, r.parent_span_id, r.start_time,
-				0 as depth,
-				array[r.root_rank] as sort_path
-			from ranked r
-			where r.parent_span_id is null
-				or r.parent_span_id not in (select span_id from trace_spans)
-
-			union all
-
-			select
-				r.trace_id, r.span_id, r.parent_span_id, r.start_time,
-				st.depth + 1,
-				st.sort_path || array[r.sibling_rank] as sort_path
-			from ranked r
-			join spans_tree st on r.parent_span_id = st.span_id
-		){{.MatchedCTE}},
+		{{.Walk}}{{.MatchedCTE}},
 
 		-- The walk's result joined back to its payload, once.
 		tree as materialized (
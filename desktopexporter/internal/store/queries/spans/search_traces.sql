@@ -1,4 +1,34 @@
-{{.CTEs}}
+{{.CTEs}},
+		traces as (
+			select distinct on (s.trace_id)
+				s.trace_id,
+				(s.parent_span_id is null) as has_root_span,
+				case when s.parent_span_id is null then nullif(s.service_name, '') end as service_name,
+				case when s.parent_span_id is null then s.name end as root_name,
+				min(s.start_time) over (partition by s.trace_id) as trace_start_time,
+				max(s.end_time) over (partition by s.trace_id) as trace_end_time,
+				count(*) over (partition by s.trace_id) as span_count,
+				count(case when s.status_code = 'Error' then 1 end) over (partition by s.trace_id) as error_count
+			{{.From}}
+			where {{.Where}}
+			order by
+				s.trace_id,
+				case when s.parent_span_id is null then 0 else 1 end
+		),
+		-- Keyset page over (trace_start_time, trace_id), newest first. The
+		-- cursor predicate is spelled out rather than a row comparison so it
+		-- reads the same as the order by beneath it.
+		page as (
+			select t.* from traces t{{if .After}}, search_params
+			where t.trace_start_time < cursor_key
+				or (t.trace_start_time = cursor_key and t.trace_id < cursor_id::uuid){{end}}
+			order by t.trace_start_time desc, t.trace_id desc{{if .Fetch}}
+			limit {{.Fetch}}{{end}}
+		),
+		numbered as (
+			select *, row_number() over (order by trace_start_time desc, trace_id desc) as rn
+			from page
+		)
 		select cast(coalesce(to_json(list(json_object(
 			'traceID',      replace(sub.trace_id::varchar, '-', ''),
 			'hasRootSpan',  sub.has_root_span,
@@ -15,21 +45,17 @@
 			end,
 			'spanCount',    sub.span_count,
 			'errorCount',   sub.error_count
-		) order by sub.trace_start_time desc
-		)), '[]') as varchar) as summaries
-		from (
-			select distinct on (s.trace_id)
-				s.trace_id,
-				(s.parent_span_id is null) as has_root_span,
-				case when s.parent_span_id is null then nullif(s.service_name, '') end as service_name,
-				case when s.parent_span_id is null then s.name end as root_name,
-				min(s.start_time) over (partition by s.trace_id) as trace_start_time,
-				max(s.end_time) over (partition by s.trace_id) as trace_end_time,
-				count(*) over (partition by s.trace_id) as span_count,
-				count(case when s.status_code = 'Error' then 1 end) over (partition by s.trace_id) as error_count
-			{{.From}}
-			where {{.Where}}
-			order by
-				s.trace_id,
-				case when s.parent_span_id is null then 0 else 1 end
-		) sub
\ No newline at end of file
+		) order by sub.rn
+		)), '[]') as varchar) as summaries,
+		{{- if .Limit}}
+		(select n.trace_start_time from numbered n
+		 where n.rn = {{.Limit}} and exists (select 1 from numbered x where x.rn > {{.Limit}})) as next_key,
+		(select n.trace_id::varchar from numbered n
+		 where n.rn = {{.Limit}} and exists (select 1 from numbered x where x.rn > {{.Limit}})) as next_id
+		from numbered sub
+		where sub.rn <= {{.Limit}}
+		{{- else}}
+		null::bigint as next_key,
+		null::varchar as next_id
+		from numbered sub
+		{{- end}}
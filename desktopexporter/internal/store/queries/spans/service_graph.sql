@@ -0,0 +1,116 @@
+{{.CTEs}},
+		-- The traces searchTraces would list for the same window and filter.
+		-- The graph is drawn over every span of those traces, not only the
+		-- spans the filter matched: a filter on service.name = checkout should
+		-- show what checkout calls, and those calls are other services' spans.
+		matched_traces as (
+			select distinct s.trace_id
+			{{.From}}
+			where {{.Where}}
+		),
+
+		-- Materialised because both edge kinds and the node list read it; left
+		-- to inlining, each would rescan spans for the same trace set.
+		scoped as materialized (
+			select s.span_id, s.parent_span_id, s.kind, s.status_code,
+				nullif(s.service_name, '') as service,
+				s.end_time - s.start_time as dur
+			from spans s
+			where s.trace_id in (select trace_id from matched_traces)
+		),
+
+		-- A call edge is a child span whose parent belongs to another service.
+		-- The callee's span is the one that did the work the caller waited on,
+		-- so its status and duration are the edge's error and latency.
+		-- Same-service parent/child pairs are a service's own internals, not a
+		-- dependency, and are left out.
+		call_edges as (
+			select p.service as source, c.service as target, 'call' as kind,
+				c.status_code, c.dur
+			from scoped c
+			join scoped p on p.span_id = c.parent_span_id
+			where p.service is distinct from c.service
+		),
+
+		-- A messaging edge comes from a span link, which is how a consumer
+		-- records the producer that enqueued its message: the producer and
+		-- consumer usually sit in different traces, so no parent/child edge
+		-- joins them. Restricted to links touching a Producer or Consumer span,
+		-- so a link used for something else -- a retry pointing at its first
+		-- attempt -- does not draw a dependency.
+		--
+		-- The producer side is looked up in all of spans, not in scoped: it is
+		-- typically in a trace the filter did not select, and the edge is still
+		-- real.
+		link_edges as (
+			select nullif(p.service_name, '') as source, c.service as target,
+				'messaging' as kind, c.status_code, c.dur
+			from scoped c
+			join links l on l.span_id = c.span_id
+			join spans p on p.span_id = l.linked_span_id
+			where (c.kind = 'Consumer' or p.kind = 'Producer')
+				and nullif(p.service_name, '') is distinct from c.service
+		),
+
+		edges as (
+			select source, target, kind,
+				count(*) as call_count,
+				count(*) filter (where status_code = 'Error') as error_count,
+				quantile_disc(dur, [0.5, 0.9, 0.99]) as q
+			from (select * from call_edges union all select * from link_edges)
+			group by source, target, kind
+		),
+
+		node_stats as (
+			select service,
+				count(*) as span_count,
+				count(*) filter (where status_code = 'Error') as error_count
+			from scoped
+			group by service
+		),
+
+		-- Every edge endpoint is a node, including a producer reached only
+		-- through a link into a trace the filter did not select. Its counts
+		-- are its spans in the selected traces, which for that producer is 0.
+		nodes as (
+			select e.service,
+				coalesce(n.span_count, 0) as span_count,
+				coalesce(n.error_count, 0) as error_count
+			from (
+				select service from scoped
+				union select source from edges
+				union select target from edges
+			) e
+			left join node_stats n on n.service is not distinct from e.service
+		)
+
+		select cast(json_object(
+			-- An unnamed service is null rather than '', as in the trace
+			-- summary's rootSpan.serviceName.
+			'nodes', coalesce((
+				select to_json(list(json_object(
+					'service', service,
+					'spanCount', span_count,
+					'errorCount', error_count
+				) order by service nulls last))
+				from nodes
+			), json('[]')),
+			-- Percentiles of the callee's span duration, ns as strings like
+			-- every other duration on the wire. quantile_disc rather than
+			-- _cont so each is a duration some span actually had.
+			'edges', coalesce((
+				select to_json(list(json_object(
+					'source', source,
+					'target', target,
+					'kind', kind,
+					'callCount', call_count,
+					'errorCount', error_count,
+					'latencyNs', json_object(
+						'p50', q[1]::varchar,
+						'p90', q[2]::varchar,
+						'p99', q[3]::varchar
+					)
+				) order by call_count desc, source nulls last, target nulls last, kind))
+				from edges
+			), json('[]'))
+		) as varchar) as graph
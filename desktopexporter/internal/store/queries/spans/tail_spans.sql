@@ -0,0 +1,27 @@
+-- The spans one tail wake-up names, filtered by the caller's query. Each item
+-- is the span itself rather than a trace summary: a trace is still arriving
+-- while it is tailed, so any per-trace figure would be wrong by the next
+-- batch.
+--
+-- Keyed on span_id for the same reason tail_logs is keyed on id: the list is
+-- small and the join touches only the rows in it.
+{{.CTEs}},
+		arrived as (
+			select unnest(tail_keys::varchar[])::uuid as span_id from search_params
+		)
+		select cast(coalesce(to_json(list(json_object(
+			'traceID',      trace_id_wire(s.trace_id),
+			'spanID',       span_id_wire(s.span_id),
+			'parentSpanID', span_id_wire(s.parent_span_id),
+			'name',         s.name,
+			'kind',         s.kind,
+			'serviceName',  nullif(s.service_name, ''),
+			'statusCode',   s.status_code,
+			'startTime',    s.start_time::varchar,
+			'durationNs',   (s.end_time - s.start_time)::varchar
+		) order by s.start_time, s.span_id)), '[]') as varchar) as spans
+		from search_params, arrived a
+			join spans s on s.span_id = a.span_id
+			join resources r on r.id = s.resource_id
+			join scopes sc on sc.id = s.scope_id
+		where {{.Where}}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/bundle"
+)
+
+// ErrStoreReadOnly is returned by WithConn and WithDBWrite on a store opened
+// with ReadOnly. The server maps it to its own read-only error code, so a
+// mutating RPC that slips past the method check still fails cleanly.
+var ErrStoreReadOnly = errors.New("store is read-only")
+
+// Option adjusts how NewStore opens a database.
+type Option func(*options)
+
+type options struct {
+	readOnly bool
+}
+
+// ReadOnly opens dbPath for viewing only: ingest, clear, delete and retention
+// are refused with ErrStoreReadOnly. dbPath may be
+//
+//   - a .duckdb file, opened with DuckDB's own read_only access mode, so the
+//     file is not touched -- not even the schema_meta stamp a writable open
+//     would add to a fresh file;
+//   - a bundle zip, as exportBundle writes, or the directory one unzips to,
+//     imported into an in-memory database.
+//
+// A bundle is imported rather than attached because it is Parquet plus the
+// SQL to rebuild it, not a database DuckDB can open in place. The import is
+// a copy, so nothing stops it being written; the store refuses anyway, since
+// someone opening a capture to read it has no use for a clear button that
+// empties their copy of it.
+func ReadOnly() Option {
+	return func(o *options) { o.readOnly = true }
+}
+
+// ReadOnly reports whether the store was opened with ReadOnly.
+func (s *Store) ReadOnly() bool {
+	return s.readOnly
+}
+
+// ExportDir is the directory exports of the store are to be made under: its
+// own for a store imported from a bundle, which can write nowhere else, and
+// "" -- the system's temporary directory -- for any other.
+func (s *Store) ExportDir() string {
+	return s.exportDir
+}
+
+// source describes what NewStore was asked to open, for the version check and
+// its messages.
+type source struct {
+	path     string // as given; empty for a fresh in-memory store
+	readOnly bool
+	bundle   bool // imported from a bundle into memory, rather than opened in place
+}
+
+// readOnlyFile reports whether the database is a file DuckDB holds read-only:
+// nothing can be created in it, so NewStore skips every DDL step.
+func (src source) readOnlyFile() bool {
+	return src.readOnly && !src.bundle
+}
+
+func (src source) String() string {
+	if src.path == "" {
+		return "(in-memory)"
+	}
+	return src.path
+}
+
+// zipMagic opens every zip's first local file header.
+var zipMagic = []byte("PK\x03\x04")
+
+// resolveReadOnly works out what kind of thing a read-only path is. For a
+// bundle it returns the directory to import, unzipping into a temporary one
+// when path is an archive; cleanup removes that and must be called once the
+// import is done. For a database file it returns the DSN to open it with.
+func resolveReadOnly(path string) (dsn, bundleDir string, cleanup func(), err error) {
+	cleanup = func() {}
+	if path == "" {
+		return "", "", cleanup, fmt.Errorf("%w: read-only mode needs a bundle or database file to open", ErrStoreInitFailed)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", "", cleanup, fmt.Errorf("%w: %w", ErrStoreInitFailed, err)
+	}
+
+	if info.IsDir() {
+		if !bundle.IsDir(path) {
+			return "", "", cleanup, fmt.Errorf("%w: %s is a directory but not an unzipped bundle: it has no schema.sql",
+				ErrStoreInitFailed, path)
+		}
+		return "", path, cleanup, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", cleanup, fmt.Errorf("%w: %w", ErrStoreInitFailed, err)
+	}
+	defer f.Close()
+	head := make([]byte, len(zipMagic))
+	if _, err := io.ReadFull(f, head); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", "", cleanup, fmt.Errorf("%w: %w", ErrStoreInitFailed, err)
+	}
+	if !bytes.Equal(head, zipMagic) {
+		// access_mode is a DuckDB config option, passed the way the
+		// connector takes them: as a query string on the path.
+		return path + "?access_mode=read_only", "", cleanup, nil
+	}
+
+	dir, err := os.MkdirTemp("", "otel-open-")
+	if err != nil {
+		return "", "", cleanup, fmt.Errorf("%w: %w", ErrStoreInitFailed, err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	if err := bundle.Unzip(f, info.Size(), dir); err != nil {
+		cleanup()
+		return "", "", func() {}, fmt.Errorf("%w while unzipping %s: %w", ErrStoreInitFailed, path, err)
+	}
+	if !bundle.IsDir(dir) {
+		cleanup()
+		return "", "", func() {}, fmt.Errorf("%w: %s is a zip but not a bundle: it has no schema.sql",
+			ErrStoreInitFailed, path)
+	}
+	return "", dir, cleanup, nil
+}
@@ -0,0 +1,274 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/bundle"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/issues"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openReadOnly(t *testing.T, path string) (*Store, error) {
+	t.Helper()
+	s, err := NewStore(context.Background(), path, nil, ReadOnly())
+	if s != nil {
+		t.Cleanup(func() { s.Close() })
+	}
+	return s, err
+}
+
+// assertRefusesWrites checks both write paths: the pool, which clear, delete
+// and retention use, and the appender connection ingest uses.
+func assertRefusesWrites(t *testing.T, s *Store) {
+	t.Helper()
+	assert.True(t, s.ReadOnly())
+	assert.ErrorIs(t, s.WithDBWrite(func(*sql.DB) error {
+		t.Fatal("a read-only store ran a write")
+		return nil
+	}), ErrStoreReadOnly)
+	assert.ErrorIs(t, s.WithConn(func(driver.Conn) error {
+		t.Fatal("a read-only store handed out its appender connection")
+		return nil
+	}), ErrStoreReadOnly)
+}
+
+func TestReadOnlyDatabaseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.db")
+	s := newFileStore(t, path)
+	seedSpans(t, s, 3)
+	require.NoError(t, s.Close())
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	ro, err := openReadOnly(t, path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count(t, ro, "spans"))
+	assert.Equal(t, SchemaOK, ro.SchemaCompatibility())
+	// The macros resolve, this build's shadowing the file's own.
+	var name string
+	require.NoError(t, ro.WithDBRead(func(db *sql.DB) error {
+		return db.QueryRow(`select attr_value(attribute_ids, 'pad')[1:3] from spans limit 1`).Scan(&name)
+	}))
+	assert.Equal(t, "xxx", name)
+	assertRefusesWrites(t, ro)
+	require.NoError(t, ro.Close())
+
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(before, after), "opening read-only must leave the file byte for byte as it was")
+}
+
+func TestReadOnlyBundle(t *testing.T) {
+	s, err := NewStore(context.Background(), "", nil)
+	require.NoError(t, err)
+	defer s.Close()
+	seedSpans(t, s, 3)
+
+	var buf bytes.Buffer
+	require.NoError(t, bundle.Write(context.Background(), &buf, s.ExportDir(), bundle.Filter{}, s.WithDBRead))
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "capture.zip")
+	require.NoError(t, os.WriteFile(zipPath, buf.Bytes(), 0o600))
+	unzipped := filepath.Join(dir, "capture")
+	require.NoError(t, os.Mkdir(unzipped, 0o700))
+	require.NoError(t, bundle.Unzip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), unzipped))
+
+	for _, path := range []string{zipPath, unzipped} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			ro, err := openReadOnly(t, path)
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), count(t, ro, "spans"))
+			assert.Equal(t, SchemaOK, ro.SchemaCompatibility())
+			assertRefusesWrites(t, ro)
+
+			size, err := ro.SizeBytes(context.Background())
+			require.NoError(t, err)
+			assert.Positive(t, size, "measured in memory, where the import lives")
+		})
+	}
+	assert.FileExists(t, zipPath, "the archive is read, not consumed")
+}
+
+// unzippedBundle writes a bundle of a small store into a directory of its own,
+// for a test to tamper with before opening it.
+func unzippedBundle(t *testing.T) string {
+	t.Helper()
+	s, err := NewStore(context.Background(), "", nil)
+	require.NoError(t, err)
+	defer s.Close()
+	seedSpans(t, s, 3)
+
+	var buf bytes.Buffer
+	require.NoError(t, bundle.Write(context.Background(), &buf, s.ExportDir(), bundle.Filter{}, s.WithDBRead))
+	dir := t.TempDir()
+	require.NoError(t, bundle.Unzip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), dir))
+	return dir
+}
+
+func appendToFile(t *testing.T, path, text string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(text)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+// A capture is someone else's SQL. Opening one must run none of it beyond the
+// CREATEs and COPY FROMs an export writes, however it is dressed up.
+func TestReadOnlyBundleRefusesForeignSQL(t *testing.T) {
+	for name, tamper := range map[string]struct {
+		file, text string
+	}{
+		"copy to in load.sql": {"load.sql", "COPY (SELECT 1) TO '%s' (FORMAT 'csv');\n"},
+		"copy to after a create in schema.sql": {"schema.sql",
+			"CREATE TABLE decoy(x INTEGER);COPY (SELECT 1) TO '%s' (FORMAT 'csv');;\n"},
+		"copy to behind a comment in schema.sql": {"schema.sql",
+			"CREATE TABLE decoy(x INTEGER) -- ;\nCOPY (SELECT 1) TO '%s' (FORMAT 'csv');;\n"},
+		"load.sql reading outside the bundle": {"load.sql",
+			"COPY spans FROM '%s' (FORMAT 'parquet');\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := unzippedBundle(t)
+			target := filepath.Join(t.TempDir(), "written.csv")
+			appendToFile(t, filepath.Join(dir, tamper.file), fmt.Sprintf(tamper.text, target))
+
+			_, err := openReadOnly(t, dir)
+			require.ErrorIs(t, err, bundle.ErrNotBundle)
+			assert.NoFileExists(t, target)
+		})
+	}
+}
+
+// What an import does run, it runs cut off from the filesystem, and the store
+// stays that way: a macro the bundle brought is not run at all, and nothing
+// but an export of the capture can write a file.
+func TestReadOnlyBundleIsSandboxed(t *testing.T) {
+	dir := unzippedBundle(t)
+	appendToFile(t, filepath.Join(dir, "schema.sql"), "CREATE MACRO md5(x) AS 'shadowed';;\n")
+
+	ro, err := openReadOnly(t, dir)
+	require.NoError(t, err)
+	var sum string
+	require.NoError(t, ro.WithDBRead(func(db *sql.DB) error {
+		return db.QueryRow(`select md5('a')`).Scan(&sum)
+	}))
+	assert.Equal(t, "0cc175b9c0f1b6a831c399e269772661", sum, "the bundle's macros are not imported")
+
+	target := filepath.Join(t.TempDir(), "written.csv")
+	err = ro.WithDBRead(func(db *sql.DB) error {
+		_, err := db.Exec(`copy (select 1) to '` + target + `'`)
+		return err
+	})
+	assert.ErrorContains(t, err, "disabled by configuration")
+	assert.NoFileExists(t, target)
+
+	var buf bytes.Buffer
+	require.NoError(t, bundle.Write(context.Background(), &buf, ro.ExportDir(), bundle.Filter{Start: 1}, ro.WithDBRead),
+		"exporting a slice of a capture still works")
+	exportDir := ro.ExportDir()
+	require.NoError(t, ro.Close())
+	assert.NoDirExists(t, exportDir)
+}
+
+// A file of this version written before a macro was added does not hold it,
+// and a read-only open cannot add it to the file -- issues read with the
+// exception macros, which came without a schema change.
+func TestReadOnlyFileReadsWithThisBuildsMacros(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "before-issues.db")
+	s := newFileStore(t, path)
+	seedOwners(t, s)
+	_, err := s.db.Exec(`
+		insert into attributes (id, key, value, type, scope)
+		values (attr_id('exception.type', 'Boom', 'string', 'log'), 'exception.type', 'Boom', 'string', 'log')`)
+	require.NoError(t, err)
+	_, err = s.db.Exec(`
+		insert into logs (id, timestamp, observed_timestamp, body, resource_id, scope_id, attribute_ids)
+		values (uuid(), 5000, 5000, 'boom', ?::uuid, ?::uuid,
+		        [attr_id('exception.type', 'Boom', 'string', 'log')])`, seedResourceID, seedScopeID)
+	require.NoError(t, err)
+	for _, macro := range []string{"exception_fingerprint", "exception_message_template", "exception_top_frame"} {
+		_, err = s.db.Exec(`drop macro ` + macro)
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.Close())
+
+	ro, err := openReadOnly(t, path)
+	require.NoError(t, err)
+	// Reads in parallel, so more than one connection has to have them.
+	var wg sync.WaitGroup
+	for range maxPoolConns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			raw, err := storeReadIssues(ro)
+			if assert.NoError(t, err) {
+				assert.Contains(t, string(raw), `"Boom"`)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func storeReadIssues(s *Store) (json.RawMessage, error) {
+	var raw json.RawMessage
+	err := s.WithDBRead(func(db *sql.DB) error {
+		var err error
+		raw, err = issues.List(context.Background(), db, 0, 10000, nil)
+		return err
+	})
+	return raw, err
+}
+
+// A read-only open cannot fix up or start over, so the error has to say
+// which way the versions differ and what will read the file -- not the
+// writable store's advice to delete it.
+func TestReadOnlyVersionMismatchIsExplained(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.db")
+	s := newFileStore(t, path)
+	_, err := s.db.Exec(`update schema_meta set version = ?`, schema.Version+1)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	_, err = openReadOnly(t, path)
+	require.ErrorIs(t, err, ErrSchemaIncompatible)
+	assert.Contains(t, err.Error(), "future.db")
+	assert.Contains(t, err.Error(), "a newer build")
+	assert.Contains(t, err.Error(), fmt.Sprintf("a release that uses schema version %d", schema.Version+1))
+	assert.NotContains(t, err.Error(), "--db", "deleting someone's capture is not the remedy")
+}
+
+func TestReadOnlyRefusesWhatItCannotRead(t *testing.T) {
+	dir := t.TempDir()
+
+	foreign := filepath.Join(dir, "other.duckdb")
+	db, err := sql.Open("duckdb", foreign)
+	require.NoError(t, err)
+	_, err = db.Exec(`create table t (x int)`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	_, err = openReadOnly(t, foreign)
+	require.ErrorIs(t, err, ErrSchemaIncompatible)
+	assert.Contains(t, err.Error(), "not written by this viewer")
+
+	_, err = openReadOnly(t, dir)
+	require.ErrorIs(t, err, ErrStoreInitFailed)
+	assert.Contains(t, err.Error(), "schema.sql", "a directory is opened only as a bundle")
+
+	_, err = openReadOnly(t, filepath.Join(dir, "missing.zip"))
+	require.ErrorIs(t, err, ErrStoreInitFailed)
+
+	_, err = openReadOnly(t, "")
+	require.ErrorIs(t, err, ErrStoreInitFailed, "there is nothing to view in a fresh in-memory store")
+}
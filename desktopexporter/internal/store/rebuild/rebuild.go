@@ -0,0 +1,129 @@
+// Package rebuild turns the store's wire JSON back into pdata, for the OTLP
+// export each signal package offers.
+//
+// Ingest drops pdata once it is written to the normalized tables, so an
+// export has to reassemble it: the attribute dictionary back into maps,
+// resources and scopes back around the records that reference them. The read
+// queries already render every one of those shapes through the attrs_json,
+// resource_json and scope_json macros, so the export queries reuse them and
+// this package reads them, rather than each signal scanning dictionary rows
+// on its own.
+//
+// Only what the store kept can come back. Where a stored rendering lost
+// something, util.StringAndTypeToValue says what.
+package rebuild
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/util"
+)
+
+// Attribute is one element of attrs_json.
+type Attribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// Attributes writes attrs into dest, in the key order attrs_json gives them.
+func Attributes(dest pcommon.Map, attrs []Attribute) {
+	dest.EnsureCapacity(len(attrs))
+	for _, a := range attrs {
+		util.StringAndTypeToValue(a.Value, a.Type, dest.PutEmpty(a.Key))
+	}
+}
+
+// Resource is resource_json.
+type Resource struct {
+	Attributes             []Attribute `json:"attributes"`
+	DroppedAttributesCount uint32      `json:"droppedAttributesCount"`
+}
+
+// CopyTo writes r into dest.
+func (r Resource) CopyTo(dest pcommon.Resource) {
+	Attributes(dest.Attributes(), r.Attributes)
+	dest.SetDroppedAttributesCount(r.DroppedAttributesCount)
+}
+
+// Scope is scope_json.
+type Scope struct {
+	Name                   string      `json:"name"`
+	Version                string      `json:"version"`
+	Attributes             []Attribute `json:"attributes"`
+	DroppedAttributesCount uint32      `json:"droppedAttributesCount"`
+}
+
+// CopyTo writes s into dest.
+func (s Scope) CopyTo(dest pcommon.InstrumentationScope) {
+	dest.SetName(s.Name)
+	dest.SetVersion(s.Version)
+	Attributes(dest.Attributes(), s.Attributes)
+	dest.SetDroppedAttributesCount(s.DroppedAttributesCount)
+}
+
+// Batch is what OTLP wraps records in, one level for the resource and one for
+// the scope, each with the schema url the batch was sent under. Rows that
+// share a Batch go back into the same ResourceX and ScopeX.
+type Batch struct {
+	Resource          string
+	ResourceSchemaURL string
+	Scope             string
+	ScopeSchemaURL    string
+}
+
+// SameResource reports whether b and o belong in one ResourceX wrapper.
+func (b Batch) SameResource(o Batch) bool {
+	return b.Resource == o.Resource && b.ResourceSchemaURL == o.ResourceSchemaURL
+}
+
+// TraceID parses the wire form trace_id_wire renders. Empty, or SQL null
+// decoded as empty, is the empty id.
+func TraceID(s string) (pcommon.TraceID, error) {
+	var id pcommon.TraceID
+	if s == "" {
+		return id, nil
+	}
+	if err := decodeHex(id[:], s); err != nil {
+		return id, fmt.Errorf("trace id %q: %w", s, err)
+	}
+	return id, nil
+}
+
+// SpanID parses the wire form span_id_wire renders. The store pads span ids
+// into a uuid's low bytes; span_id_wire has already dropped the padding.
+func SpanID(s string) (pcommon.SpanID, error) {
+	var id pcommon.SpanID
+	if s == "" {
+		return id, nil
+	}
+	if err := decodeHex(id[:], s); err != nil {
+		return id, fmt.Errorf("span id %q: %w", s, err)
+	}
+	return id, nil
+}
+
+func decodeHex(dst []byte, s string) error {
+	if hex.DecodedLen(len(s)) != len(dst) {
+		return fmt.Errorf("want %d hex characters, got %d", 2*len(dst), len(s))
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+// Timestamp parses a nanosecond timestamp the queries render as text, the way
+// every bigint goes over the wire. Empty is zero.
+func Timestamp(s string) (pcommon.Timestamp, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("timestamp %q: %w", s, err)
+	}
+	return pcommon.Timestamp(n), nil
+}
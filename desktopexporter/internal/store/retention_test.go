@@ -95,8 +95,8 @@ func seedDatapoints(t *testing.T, s *Store, streamID, ingestID string, n int, st
 		streamID, streamID, seedResourceID)
 	require.NoError(t, err)
 	_, err = s.db.Exec(`
-		insert into datapoints (id, stream_id, series_id, metric_ingest_id, timestamp, double_value, value_type, attribute_ids)
-		select uuid(), ?::uuid, ?::uuid, ?::uuid, ? + range * 1000000, range, 'double', []::uuid[]
+		insert into datapoints (id, stream_id, series_id, metric_ingest_id, metric_type, timestamp, double_value, value_type, attribute_ids)
+		select uuid(), ?::uuid, ?::uuid, ?::uuid, 'Gauge', ? + range * 1000000, range, 'double', []::uuid[]
 		from range(?)`, streamID, streamID, ingestID, startTime, n)
 	require.NoError(t, err)
 }
@@ -8,11 +8,16 @@ package schema
 // and `create index if not exists` apply cleanly to an older file do not need a
 // bump.
 //
-// There is no migration machinery. This exists so an incompatible file is
-// reported clearly instead of failing later with something opaque: without it,
-// `create table if not exists` silently leaves an old table in place and the
-// mismatch first surfaces as a duckdb appender column-count error during ingest,
-// or as an index creation failure against a column that does not exist.
+// A bump comes with a migration: queries/ddl/migrations/NNN_*.sql, numbered
+// with the version it produces, which the store runs to bring an older file
+// up to this one (a test in the store package fails if the chain has a gap).
+// What the version still refuses is what no migration reaches -- a file from a
+// newer build, or one from before the chain starts -- and it exists so that
+// file is reported clearly instead of failing later with something opaque:
+// without it, `create table if not exists` silently leaves an old table in
+// place and the mismatch first surfaces as a duckdb appender column-count
+// error during ingest, or as an index creation failure against a column that
+// does not exist.
 //
 // Version 1 was the owner-keyed attributes schema -- the last shape before the
 // dictionary. It shipped stamped, so databases written by that build exist in
@@ -68,7 +73,22 @@ package schema
 // of stream identity. Same mechanism as versions 3 and 6: a new column on an
 // existing table, so a version 6 file fails the metric appender's column count
 // on its first metric batch.
-const Version = 7
+//
+// Version 8 adds Summary datapoints: a metric_type discriminator and a
+// quantile_values column on datapoints, with CHECK constraints tying each
+// representation's columns to its type. Before it, a Summary metric got a
+// stream and an ingest row and then had every datapoint dropped. Two new
+// columns on an existing table, so a version 7 file fails the datapoint
+// appender's column count the same way versions 3, 6 and 7 describe.
+//
+// Version 9 adds bytes, map and any[] to the attr_type enum and changes how
+// those values are written: bytes were hex typed as string, maps and mixed
+// lists were Go's %v rendering. No column changes, but the enum a version 8
+// file created lacks the new tokens, so its first map attribute would fail the
+// dictionary insert -- and the ids its existing rows carry were hashed from
+// the old renderings, so the same attribute arriving again would mint a second
+// row rather than dedupe against the first.
+const Version = 9
 
 // VersionTableQuery creates the version table.
 //
@@ -103,3 +123,7 @@ const (
 	SpansTableExistsQuery = `select count(*) from duckdb_tables() where table_name = 'spans'`
 	SpanCountQuery        = `select count(*) from spans`
 )
+
+// VersionTableExistsQuery asks whether schema_meta exists, for a file opened
+// read-only, where VersionTableQuery cannot run.
+const VersionTableExistsQuery = `select count(*) from duckdb_tables() where table_name = 'schema_meta'`
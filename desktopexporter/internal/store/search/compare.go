@@ -0,0 +1,61 @@
+package search
+
+// CompareSource is one signal's half of a compareAttributes statement: two
+// searches over the same rows, and where those rows keep their attributes.
+// Like FacetSource, the signal packages build it and the compare package runs
+// it.
+type CompareSource struct {
+	// CTEs are the two searches' parameters, selection_params then
+	// baseline_params, listed without a leading "with". Their WHERE clauses
+	// name parameters unqualified, so each is read from a FROM listing only
+	// its own CTE.
+	CTEs                          string
+	SelectionWhere, BaselineWhere string
+	Args                          []any
+	// From is the signal's tables and joins, without a parameters CTE.
+	From string
+	// ID is the row's id, which the baseline is told apart from the
+	// selection by.
+	ID string
+	// AttributeIDs is every dictionary id a row carries, across the scopes it
+	// inherits -- its resource's and its scope's as well as its own.
+	AttributeIDs string
+}
+
+// MaxCompareValues caps the values compareAttributes lists per key, most
+// divergent first. A key's divergence is still taken over all of them.
+const MaxCompareValues = 10
+
+// ComparedValue is one value of a key and how often each side carries it.
+// A nil Value is the key's absence: the rows that carry no value for it.
+type ComparedValue struct {
+	Value              *string `json:"value"`
+	SelectionCount     int64   `json:"selectionCount"`
+	BaselineCount      int64   `json:"baselineCount"`
+	SelectionFrequency float64 `json:"selectionFrequency"`
+	BaselineFrequency  float64 `json:"baselineFrequency"`
+	// Difference is SelectionFrequency - BaselineFrequency: positive for a
+	// value the selection carries more often.
+	Difference float64 `json:"difference"`
+}
+
+// ComparedAttribute is one attribute key's value distribution on both sides.
+type ComparedAttribute struct {
+	Key            string `json:"key"`
+	AttributeScope string `json:"attributeScope"`
+	// Divergence is the largest |Difference| over the key's values, absence
+	// included, listed or not: 0 for a key spread alike on both sides, 1 for
+	// a value every selected row carries and no baseline row does.
+	Divergence         float64 `json:"divergence"`
+	DistinctValueCount int64   `json:"distinctValueCount"`
+	// Values are the MaxCompareValues values with the largest |Difference|.
+	Values []ComparedValue `json:"values"`
+}
+
+// AttributeComparison is what compareAttributes answers with: the size of
+// each side, and every key either carries, most divergent first.
+type AttributeComparison struct {
+	SelectionCount int64               `json:"selectionCount"`
+	BaselineCount  int64               `json:"baselineCount"`
+	Attributes     []ComparedAttribute `json:"attributes"`
+}
@@ -0,0 +1,116 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultFacetLimit is how many values a facet lists when the caller names no
+// limit: a dropdown's worth, with the rest summed into otherCount.
+const DefaultFacetLimit = 10
+
+// MaxFacetLimit caps the values per facet. A facet is a summary; a caller
+// that wants every value of a high-cardinality key wants a search instead.
+const MaxFacetLimit = 1000
+
+// MaxFacetFields caps the fields one getFacets call counts. Each is another
+// pass over the matched rows.
+const MaxFacetFields = 20
+
+// FacetSource is one signal's half of a getFacets statement: the search that
+// selects the rows to count, and how to read each requested field off them.
+// The signal packages build it, since only they know their columns and
+// attribute arrays; the facets package runs it.
+type FacetSource struct {
+	// CTEs opens the statement: the search_params CTE, carrying the facet
+	// parameters alongside the query's.
+	CTEs string
+	// From and Where select the matched rows, as the signal's search does.
+	From, Where string
+	Args        []any
+	Columns     []FacetColumn
+}
+
+// FacetColumn reads one facet off a matched row.
+type FacetColumn struct {
+	// Expr is projected from each matched row: the value itself for a field,
+	// or the id array an attribute lives in.
+	Expr string
+	// Dictionary is set for an attribute facet. It selects the attribute's
+	// dictionary rows, joined as "a" -- one per distinct value of the key in
+	// that scope -- and counting becomes matching those ids against each
+	// row's Expr array, never resolving a value per row.
+	Dictionary string
+	// Value reads the facet value off a dictionary row: a.value, or the leaf
+	// a path names.
+	Value string
+}
+
+// BindFacetAttribute appends the parameters an attribute facet's dictionary
+// lookup reads and returns its column. ids is the array the attribute lives
+// in, for the signal's FROM; scope is the dictionary scope ids hold.
+//
+// Not ingest.IDProbe, which the search's own conditions still go through:
+// that computes the one id an "=" condition's value would have, and a facet
+// has no value to start from -- its values are what it is asked for. The
+// dictionary rows for the key are those values with their ids already
+// computed, so reading them gives the same membership test on stored ids,
+// for every value at once, with nothing hashed here.
+//
+// The facet's parameters are named by its position rather than by
+// len(*params), as BindAttrLookup names a condition's: they ride in the same
+// CTE as the conditions', after them, and must not collide.
+func BindFacetAttribute(i int, field *FieldDefinition, ids, scope string, params *[]NamedParam) FacetColumn {
+	key := fmt.Sprintf("facet_key_%d", i)
+	*params = append(*params, NamedParam{Name: key, Value: field.Name})
+	conditions := []string{"a.key = " + key, fmt.Sprintf("a.scope = '%s'", scope)}
+	// A key written under two types is two dictionary rows per value, and
+	// discovery lists it twice; a typed facet counts the one the caller
+	// picked.
+	if field.Type != "" {
+		typ := fmt.Sprintf("facet_type_%d", i)
+		*params = append(*params, NamedParam{Name: typ, Value: field.Type})
+		conditions = append(conditions, "a.type::varchar = "+typ)
+	}
+	col := FacetColumn{Expr: ids, Dictionary: strings.Join(conditions, " and "), Value: "a.value"}
+	if len(field.Path) > 0 {
+		path := fmt.Sprintf("facet_path_%d", i)
+		*params = append(*params, NamedParam{Name: path, Value: JSONPointer(field.Path)})
+		col.Value = fmt.Sprintf("attr_json_path(a.value, a.type, %s)", path)
+	}
+	return col
+}
+
+// FacetValue is one value of a facet and how many matched rows carry it.
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Facet is one requested field's value counts over the matched rows.
+type Facet struct {
+	// Field is the definition the caller sent, echoed so a response can be
+	// read without the request beside it.
+	Field FieldDefinition `json:"field"`
+	// Values are the most frequent values, most rows first.
+	Values []FacetValue `json:"values"`
+	// DistinctCount is every distinct non-null value, listed or not.
+	DistinctCount int64 `json:"distinctCount"`
+	// OtherCount is the rows carrying a value Values does not list.
+	OtherCount int64 `json:"otherCount"`
+	// NullCount is the rows with no value: a null column, or an owner
+	// without the attribute -- or without the leaf its path names.
+	NullCount int64 `json:"nullCount"`
+}
+
+// FacetResult is what getFacets answers with.
+//
+// A row holds one value of a field, and of a span, log, resource or scope
+// attribute, or none, so those facets' Values, OtherCount and NullCount sum
+// to Total. An event or link attribute is not one value per span: a span is
+// counted once under each distinct value its events or links carry, and such
+// a facet can sum to more.
+type FacetResult struct {
+	Total  int64   `json:"total"`
+	Facets []Facet `json:"facets"`
+}
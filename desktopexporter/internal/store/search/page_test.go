@@ -0,0 +1,44 @@
+package search
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, c := range []Cursor{
+		{Key: 1_700_000_000_000_000_000, ID: "00000000-0000-0000-0000-000000000001"},
+		// The metric key for a stream with no datapoint in the window.
+		{Key: -9223372036854775808, ID: "5f1b2c3d-0000-4000-8000-0123456789ab"},
+	} {
+		got, err := ParseCursor(EncodeCursor(c))
+		require.NoError(t, err)
+		assert.Equal(t, c, *got)
+	}
+}
+
+func TestParseCursorRejectsWhatItDidNotIssue(t *testing.T) {
+	for _, token := range []string{
+		"",
+		"!!!",
+		EncodeCursor(Cursor{Key: 1, ID: "not-a-uuid"}),
+		"MTIz", // "123": no separator
+	} {
+		_, err := ParseCursor(token)
+		assert.True(t, errors.Is(err, ErrInvalidQuery), "%q: got %v", token, err)
+	}
+}
+
+func TestPageFromRow(t *testing.T) {
+	raw, err := PageFromRow(nil, nil, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"items":[],"nextCursor":null}`, string(raw))
+
+	key, id := int64(5), "00000000-0000-0000-0000-000000000001"
+	raw, err = PageFromRow([]byte(`[{"a":1}]`), &key, &id)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"items":[{"a":1}],"nextCursor":"`+EncodeCursor(Cursor{Key: 5, ID: id})+`"}`, string(raw))
+}
@@ -28,11 +28,18 @@ type Query struct {
 }
 
 // FieldDefinition describes a field or attribute used in a condition.
+//
+// Path walks into a map or any[] attribute: attributes.foo.bar = x arrives as
+// Name "foo", Path ["bar"]. It is a separate list rather than split out of
+// Name because attribute keys are dotted themselves -- "http.request.header"
+// is one key -- so only the client, which picked the key from discovery,
+// knows where the key ends and the path begins.
 type FieldDefinition struct {
-	Name           string `json:"name,omitempty"`
-	SearchScope    string `json:"searchScope"`
-	AttributeScope string `json:"attributeScope,omitempty"`
-	Type           string `json:"type,omitempty"`
+	Name           string   `json:"name,omitempty"`
+	SearchScope    string   `json:"searchScope"`
+	AttributeScope string   `json:"attributeScope,omitempty"`
+	Type           string   `json:"type,omitempty"`
+	Path           []string `json:"path,omitempty"`
 }
 
 // QueryGroup holds a logical group (AND/OR) of children.
@@ -230,7 +237,10 @@ func BuildOperatorCondition(expression string, query *Query, params *[]NamedPara
 		return result + " " + operatorString, nil
 	}
 
-	if query.Field != nil && strings.HasSuffix(query.Field.Type, "[]") {
+	// A path predicate compares the leaf it reaches, and any[] has no DuckDB
+	// list type to cast to; both compare as text.
+	if query.Field != nil && len(query.Field.Path) == 0 &&
+		strings.HasSuffix(query.Field.Type, "[]") && query.Field.Type != "any[]" {
 		return handleArrayOperator(expression, query, params)
 	}
 
@@ -369,6 +379,19 @@ func handleArrayOperator(expression string, query *Query, params *[]NamedParam)
 	}
 }
 
+// JSONPointer renders path segments as an RFC 6901 JSON pointer, the form the
+// attr_json_path macro reads. "~" and "/" are the pointer's only special
+// characters, escaped as "~0" and "~1" -- in that order, or the "~" of an
+// already-escaped "/" would be escaped again and decode as "~1".
+func JSONPointer(path []string) string {
+	var b strings.Builder
+	for _, seg := range path {
+		b.WriteByte('/')
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(seg, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
+
 // ParseArrayValue parses array values from frontend format "[value1,value2,value3]"
 func ParseArrayValue(value string) []any {
 	value = strings.Trim(value, "[]")
@@ -410,7 +433,23 @@ func ConvertValueForArrayType(value, arrayType string) any {
 
 // BuildSearchSQL builds the search_params CTE, WHERE clause, and args for any signal.
 // timeCondition must reference time_start and time_end.
-func BuildSearchSQL(queryNode *QueryNode, startTime, endTime int64, mapper FieldMapper, timeCondition string) (cteSQL, whereSQL string, args []any, err error) {
+//
+// extra lands in the same CTE after the condition parameters -- after, so the
+// value_N names a condition renders do not shift with whether a page cursor
+// was sent.
+func BuildSearchSQL(queryNode *QueryNode, startTime, endTime int64, mapper FieldMapper, timeCondition string, extra ...NamedParam) (cteSQL, whereSQL string, args []any, err error) {
+	cteSQL, whereSQL, args, err = BuildNamedSearchSQL(queryNode, startTime, endTime, mapper, timeCondition, "search_params", extra...)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return "with " + cteSQL, whereSQL, args, nil
+}
+
+// BuildNamedSearchSQL is BuildSearchSQL for a statement that carries more
+// than one search: the parameters CTE is called name and comes without the
+// leading "with", so it can be listed among others. The WHERE clause names
+// its parameters unqualified, so it reads whichever CTE its own FROM lists.
+func BuildNamedSearchSQL(queryNode *QueryNode, startTime, endTime int64, mapper FieldMapper, timeCondition, name string, extra ...NamedParam) (cteSQL, whereSQL string, args []any, err error) {
 	params := []NamedParam{
 		{Name: "time_start", Value: startTime},
 		{Name: "time_end", Value: endTime},
@@ -422,6 +461,7 @@ func BuildSearchSQL(queryNode *QueryNode, startTime, endTime int64, mapper Field
 			return "", "", nil, err
 		}
 	}
+	params = append(params, extra...)
 
 	if len(conditions) > 0 {
 		whereSQL = "(" + strings.Join(conditions, " ") + ") AND " + timeCondition
@@ -435,6 +475,6 @@ func BuildSearchSQL(queryNode *QueryNode, startTime, endTime int64, mapper Field
 		args[i] = p.Value
 		cteParams[i] = fmt.Sprintf("? as %s", p.Name)
 	}
-	cteSQL = fmt.Sprintf("with search_params as (select %s)", strings.Join(cteParams, ", "))
+	cteSQL = fmt.Sprintf("%s as (select %s)", name, strings.Join(cteParams, ", "))
 	return cteSQL, whereSQL, args, nil
 }
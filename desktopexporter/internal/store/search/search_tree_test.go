@@ -385,6 +385,17 @@ func TestBuildOperatorCondition_ArrayTypes(t *testing.T) {
 			value:      "x",
 			wantErr:    true,
 		},
+		{
+			// A mixed list has no DuckDB list type to cast to, so it is
+			// searched as its typed-JSON text.
+			name:           "any[] compares as text",
+			expression:     "a.Value",
+			fieldType:      "any[]",
+			operator:       "CONTAINS",
+			value:          "jittered",
+			expectedSQL:    "a.Value LIKE value_2",
+			expectedParams: []NamedParam{{"value_2", "%jittered%"}},
+		},
 	}
 
 	for _, tt := range tests {
@@ -418,6 +429,30 @@ func TestBuildOperatorCondition_ArrayTypes(t *testing.T) {
 	}
 }
 
+// A path predicate compares the leaf it reaches, whatever the attribute's own
+// type, so a path into an array-typed attribute must not take the cast.
+func TestBuildOperatorCondition_PathSkipsArrayCast(t *testing.T) {
+	params := []NamedParam{}
+	query := &Query{
+		Field:         &FieldDefinition{Type: "any[]", Path: []string{"0", "value"}},
+		FieldOperator: "=",
+		Value:         "100",
+	}
+	sql, err := BuildOperatorCondition("leaf", query, &params)
+	require.NoError(t, err)
+	assert.Equal(t, "leaf = value_0", sql)
+	assert.Equal(t, []NamedParam{{"value_0", "100"}}, params)
+}
+
+func TestJSONPointer(t *testing.T) {
+	assert.Equal(t, "/user-agent", JSONPointer([]string{"user-agent"}))
+	assert.Equal(t, "/accept/0", JSONPointer([]string{"accept", "0"}))
+	// RFC 6901's two escapes, and the order that keeps them unambiguous.
+	assert.Equal(t, "/a~1b/c~0d/~01", JSONPointer([]string{"a/b", "c~d", "~1"}))
+	// Dots are not separators here: the key decides, not the text.
+	assert.Equal(t, "/gen_ai.prompt", JSONPointer([]string{"gen_ai.prompt"}))
+}
+
 func TestParseArrayValue(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -117,6 +117,9 @@ func TestIDProbeRefusesWhatItCannotGuarantee(t *testing.T) {
 			&search.Query{FieldOperator: "=", Value: "y"}, false},
 		{"unknown type token falls back", &search.FieldDefinition{Name: "x", Type: "decimal"},
 			&search.Query{FieldOperator: "=", Value: "y"}, false},
+		// The id covers the whole map; a path names one leaf inside it.
+		{"path predicate falls back", &search.FieldDefinition{Name: "http.request.header", Type: "map",
+			Path: []string{"user-agent"}}, &search.Query{FieldOperator: "=", Value: "curl/8.4"}, false},
 		{"nil query", str, nil, false},
 	} {
 		got := ingest.IDProbe("ids", tc.field, tc.query, ingest.ScopeSpan) != ""
@@ -202,3 +205,64 @@ func TestAttrTypesMatchSchemaEnum(t *testing.T) {
 	assert.Equal(t, fromSchema, ingest.AttrTypes,
 		"ingest.AttrTypes must list exactly the attr_type enum values, in order")
 }
+
+// A path predicate reaches inside a map attribute. The fixture span carries
+// http.request.header as a map and http.method as a plain string, so the same
+// machinery is exercised against both -- and the string must simply not
+// match, rather than fail the whole search when its text is not JSON.
+func TestPathPredicateOverMapAttribute(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewStore(ctx, "", zap.NewNop())
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return spans.Ingest(ctx, conn, integrityTraces(1), s.FlushedIDs())
+	}))
+
+	count := func(t *testing.T, scope, name string, path []string, op, value string) int {
+		t.Helper()
+		query := map[string]any{
+			"type": "condition",
+			"query": map[string]any{
+				"field": map[string]any{
+					"name": name, "type": "map", "path": path,
+					"searchScope": "attribute", "attributeScope": scope,
+				},
+				"fieldOperator": op,
+				"value":         value,
+			},
+		}
+		var n int
+		require.NoError(t, s.WithDBRead(func(db *sql.DB) error {
+			raw, err := spans.SearchTraces(ctx, db, 0, 1<<62, query)
+			if err != nil {
+				return err
+			}
+			var out []map[string]any
+			if err := json.Unmarshal(raw, &out); err != nil {
+				return err
+			}
+			n = len(out)
+			return nil
+		}))
+		return n
+	}
+
+	header := "http.request.header"
+	assert.Equal(t, 1, count(t, "span", header, []string{"user-agent"}, "=", "curl/8.4"))
+	assert.Equal(t, 1, count(t, "span", header, []string{"user-agent"}, "^", "curl/"))
+	assert.Zero(t, count(t, "span", header, []string{"user-agent"}, "=", "wget"))
+	assert.Equal(t, 1, count(t, "span", header, []string{"accept", "0"}, "=", "text/html"),
+		"a pointer segment indexes into a list")
+	assert.Zero(t, count(t, "span", header, []string{"no-such-header"}, "=", "curl/8.4"))
+	assert.Equal(t, 1, count(t, "span", header, []string{"no-such-header"}, "=", "NULL"),
+		"a path that does not resolve is NULL, like a missing key")
+
+	assert.Zero(t, count(t, "span", "http.method", []string{"x"}, "=", "GET"),
+		"a path into a plain string matches nothing, and must not error")
+	assert.Zero(t, count(t, "event", "exception.type", []string{"x"}, "=", "TimeoutError"),
+		"the EXISTS form takes the path too")
+	assert.Zero(t, count(t, "resource", "service.name", []string{"x"}, "=", "checkout"),
+		"and the hoisted resource form")
+}
@@ -0,0 +1,108 @@
+// Package sink is the write path every source of telemetry shares: the desktop
+// exporter's three push functions, replay, and the directory watcher all hand
+// their batches to Traces, Metrics or Logs, so a span is stored -- and
+// announced to the live tail -- the same way whichever door it came in by.
+//
+// It is the step after decoding and before the signal packages: take the
+// store's appender connection, run the signal's Ingest, and publish what
+// committed. What differs between callers stays with them -- the exporter's
+// ingest span and timeout, the watcher's offset checkpoint -- and the checkpoint
+// is the reason for the then argument below.
+package sink
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/live"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/logs"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/metrics"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+)
+
+// Then runs on the appender connection straight after a batch is ingested,
+// still inside the same WithConn, so nothing else is appended between the two.
+// nil means nothing to do.
+//
+// Ingest is not one transaction -- each appender commits on its own -- so Then
+// cannot be made atomic with the batch. What running here buys is order: Then
+// only ever sees a batch that was written in full, and an error from it is
+// returned after the rows are already in. A caller recording progress should
+// treat its record as at-least-once: lost if the process dies in between, and
+// the batch then arrives again.
+type Then func(conn driver.Conn) error
+
+// Traces ingests td into s. Span keys are collected only while a tail is open,
+// and published after WithConn returns -- once the rows are committed -- so a
+// subscriber that queries the moment it wakes finds them. A tail opened
+// mid-batch misses that batch: it starts from the next one, which is what
+// "from now on" means.
+func Traces(ctx context.Context, s *store.Store, td ptrace.Traces, then Then) error {
+	hub := s.Live()
+	var keys []string
+	ingested := false
+	err := s.WithConn(func(conn driver.Conn) error {
+		var err error
+		if hub.Watching(live.Spans) {
+			keys, err = spans.IngestReturning(ctx, conn, td, s.FlushedIDs())
+		} else {
+			err = spans.Ingest(ctx, conn, td, s.FlushedIDs())
+		}
+		if err != nil {
+			return err
+		}
+		ingested = true
+		return run(then, conn)
+	})
+	if ingested {
+		hub.Publish(live.Spans, keys)
+	}
+	return err
+}
+
+// Metrics ingests md into s. Metrics have no live tail, so nothing is
+// published.
+func Metrics(ctx context.Context, s *store.Store, md pmetric.Metrics, then Then) error {
+	return s.WithConn(func(conn driver.Conn) error {
+		if err := metrics.Ingest(ctx, conn, md, s.FlushedIDs()); err != nil {
+			return err
+		}
+		return run(then, conn)
+	})
+}
+
+// Logs ingests ld into s, feeding the live hub the way Traces does.
+func Logs(ctx context.Context, s *store.Store, ld plog.Logs, then Then) error {
+	hub := s.Live()
+	var keys []string
+	ingested := false
+	err := s.WithConn(func(conn driver.Conn) error {
+		var err error
+		if hub.Watching(live.Logs) {
+			keys, err = logs.IngestReturning(ctx, conn, ld, s.FlushedIDs())
+		} else {
+			err = logs.Ingest(ctx, conn, ld, s.FlushedIDs())
+		}
+		if err != nil {
+			return err
+		}
+		ingested = true
+		return run(then, conn)
+	})
+	if ingested {
+		hub.Publish(live.Logs, keys)
+	}
+	return err
+}
+
+func run(then Then, conn driver.Conn) error {
+	if then == nil {
+		return nil
+	}
+	return then(conn)
+}
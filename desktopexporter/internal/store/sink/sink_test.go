@@ -0,0 +1,71 @@
+package sink_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/live"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/sink"
+)
+
+func oneSpan() ptrace.Traces {
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	span.SetSpanID(pcommon.SpanID{1, 2, 3, 4, 5, 6, 7, 8})
+	return td
+}
+
+// then sees the batch already written, on the connection that wrote it.
+func TestThenRunsAfterTheBatch(t *testing.T) {
+	s, err := store.NewStore(context.Background(), "", nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	var seen int64 = -1
+	err = sink.Traces(context.Background(), s, oneSpan(), func(conn driver.Conn) error {
+		rows, err := conn.(driver.QueryerContext).QueryContext(context.Background(), `select count(*) from spans`, nil)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		dest := make([]driver.Value, 1)
+		if err := rows.Next(dest); err != nil {
+			return err
+		}
+		seen = dest[0].(int64)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, seen)
+}
+
+// An error from then is the caller's to see, but it does not unwrite the
+// batch, so a tail is still told the rows are there.
+func TestThenFailingStillPublishes(t *testing.T) {
+	s, err := store.NewStore(context.Background(), "", nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	sub := s.Live().Subscribe(live.Logs, 0)
+	defer sub.Close()
+
+	ld := plog.NewLogs()
+	ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("hi")
+	boom := errors.New("checkpoint failed")
+	err = sink.Logs(context.Background(), s, ld, func(driver.Conn) error { return boom })
+	require.ErrorIs(t, err, boom)
+
+	keys, dropped := sub.Take()
+	assert.Len(t, keys, 1)
+	assert.Zero(t, dropped)
+}
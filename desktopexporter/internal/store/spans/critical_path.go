@@ -0,0 +1,223 @@
+package spans
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
+)
+
+// pathSpan is one placed span as critical_path.sql reports it, plus the
+// window the walk clamps it to and the children that hang off it.
+type pathSpan struct {
+	SpanID       string  `json:"spanID"`
+	ParentSpanID *string `json:"parentSpanID"`
+	Name         string  `json:"name"`
+	ServiceName  *string `json:"serviceName"`
+	Depth        int     `json:"depth"`
+	StartTime    int64   `json:"startTime,string"`
+	EndTime      int64   `json:"endTime,string"`
+
+	// start and end are StartTime and EndTime cut to the parent's window:
+	// the only part of a span that can hold its parent up.
+	start, end int64
+	children   []*pathSpan
+}
+
+// pathTrace is the whole row critical_path.sql returns.
+type pathTrace struct {
+	TraceID           string      `json:"traceID"`
+	TraceStart        *int64      `json:"traceStart,string"`
+	UnplacedSpanCount int64       `json:"unplacedSpanCount"`
+	Spans             []*pathSpan `json:"spans"`
+}
+
+// PathSegment is one stretch of the critical path: an interval during which
+// the span named was doing the work the trace waited on. A segment with no
+// span is a gap between roots, when nothing the trace reported was running.
+type PathSegment struct {
+	SpanID        *string `json:"spanID"`
+	Name          *string `json:"name"`
+	ServiceName   *string `json:"serviceName"`
+	Depth         *int    `json:"depth"`
+	StartOffsetNs int64   `json:"startOffsetNs,string"`
+	DurationNs    int64   `json:"durationNs,string"`
+}
+
+// PathSpan is one span's share of the critical path: the sum of its
+// segments, which is time spent in the span itself rather than in any child
+// it was waiting on.
+type PathSpan struct {
+	SpanID       string  `json:"spanID"`
+	Name         string  `json:"name"`
+	ServiceName  *string `json:"serviceName"`
+	Depth        int     `json:"depth"`
+	SelfTimeNs   int64   `json:"selfTimeNs,string"`
+	SegmentCount int     `json:"segmentCount"`
+}
+
+// CriticalPathResult is what getCriticalPath answers with.
+type CriticalPathResult struct {
+	TraceID    string `json:"traceID"`
+	StartTime  *int64 `json:"startTime,string"`
+	EndTime    *int64 `json:"endTime,string"`
+	DurationNs int64  `json:"durationNs,string"`
+	// Segments run in time order and tile [StartTime, EndTime] exactly.
+	Segments []PathSegment `json:"segments"`
+	// Spans is every span with a segment, most self time first.
+	Spans []PathSpan `json:"spans"`
+	// SkewedSpanCount is the spans reported as starting before or ending
+	// after their parent, which the walk clamps to the parent.
+	SkewedSpanCount   int   `json:"skewedSpanCount"`
+	UnplacedSpanCount int64 `json:"unplacedSpanCount"`
+}
+
+// CriticalPath returns the chain of spans one trace's end-to-end latency is
+// made of, as time-ordered segments, and each span's self time on it.
+//
+// The path is found backwards, as Jaeger's critical-path view finds it: from
+// a span's end, the child that finished last before that point is what the
+// span was waiting on, so the time after that child ended is the span's own;
+// the walk descends into the child and then carries on from the child's
+// start. Children that overlap the one chosen are not on the path -- the
+// span was waiting on something else while they ran.
+//
+// Spans are placed the way DescribeTrace places them, and the path starts
+// where searchSpans' traceStart does: at the earliest placed start. With no
+// root, or with several, the roots are treated as children of one span
+// covering the whole trace, and whatever of the trace none of them covers is
+// a gap segment. A child whose clock puts it outside its parent is clamped to
+// the parent, since the parent cannot have waited on it outside its own
+// lifetime; spans on a parent cycle are never placed and only counted.
+func CriticalPath(ctx context.Context, db *sql.DB, traceID string) (json.RawMessage, error) {
+	query, err := renderWalkedTrace(queries.CriticalPath)
+	if err != nil {
+		return nil, fmt.Errorf("CriticalPath: %w: %w", ErrSpansStoreInternal, err)
+	}
+
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, traceID).Scan(&raw); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("CriticalPath: %w", ErrTraceIDNotFound)
+		}
+		return nil, fmt.Errorf("CriticalPath: %w: %w", ErrSpansStoreInternal, err)
+	}
+	var trace pathTrace
+	if err := json.Unmarshal(raw, &trace); err != nil {
+		return nil, fmt.Errorf("CriticalPath: %w: %w", ErrSpansStoreInternal, err)
+	}
+
+	out, err := json.Marshal(criticalPath(trace))
+	if err != nil {
+		return nil, fmt.Errorf("CriticalPath: %w: %w", ErrSpansStoreInternal, err)
+	}
+	return json.RawMessage(out), nil
+}
+
+// criticalPath walks the placed spans of one trace.
+func criticalPath(trace pathTrace) CriticalPathResult {
+	result := CriticalPathResult{
+		TraceID:           trace.TraceID,
+		Segments:          []PathSegment{},
+		Spans:             []PathSpan{},
+		UnplacedSpanCount: trace.UnplacedSpanCount,
+	}
+	if trace.TraceStart == nil {
+		// Every span is on a cycle: there is nothing to walk.
+		return result
+	}
+
+	byID := make(map[string]*pathSpan, len(trace.Spans))
+	for _, s := range trace.Spans {
+		byID[s.SpanID] = s
+	}
+	// The virtual root stands for the trace: it spans everything placed, and
+	// its self time is the gaps.
+	root := &pathSpan{start: *trace.TraceStart, end: *trace.TraceStart}
+	for _, s := range trace.Spans {
+		root.end = max(root.end, s.EndTime)
+		parent := root
+		if s.ParentSpanID != nil {
+			if p, ok := byID[*s.ParentSpanID]; ok {
+				parent = p
+			}
+		}
+		if parent != root && (s.StartTime < parent.StartTime || s.EndTime > parent.EndTime) {
+			result.SkewedSpanCount++
+		}
+		parent.children = append(parent.children, s)
+	}
+	result.StartTime, result.EndTime = &root.start, &root.end
+	result.DurationNs = root.end - root.start
+
+	var reversed []PathSegment
+	emit := func(s *pathSpan, from, to int64) {
+		if to <= from {
+			return
+		}
+		seg := PathSegment{StartOffsetNs: from - root.start, DurationNs: to - from}
+		if s != root {
+			seg.SpanID, seg.Name, seg.ServiceName, seg.Depth = &s.SpanID, &s.Name, s.ServiceName, &s.Depth
+		}
+		reversed = append(reversed, seg)
+	}
+
+	var walk func(s *pathSpan)
+	walk = func(s *pathSpan) {
+		var candidates []*pathSpan
+		for _, c := range s.children {
+			c.start, c.end = max(c.StartTime, s.start), min(c.EndTime, s.end)
+			// Zero-length after clamping, or wholly outside the parent:
+			// nothing the parent could have waited on.
+			if c.end > c.start {
+				candidates = append(candidates, c)
+			}
+		}
+		// Latest end first; among children ending together the longest,
+		// then span ID, so the path does not depend on row order.
+		slices.SortFunc(candidates, func(a, b *pathSpan) int {
+			return cmp.Or(cmp.Compare(b.end, a.end), cmp.Compare(a.start, b.start), cmp.Compare(a.SpanID, b.SpanID))
+		})
+
+		cursor := s.end
+		for _, c := range candidates {
+			if c.end > cursor {
+				continue
+			}
+			emit(s, c.end, cursor)
+			walk(c)
+			cursor = c.start
+		}
+		emit(s, s.start, cursor)
+	}
+	walk(root)
+
+	slices.Reverse(reversed)
+	result.Segments = append(result.Segments, reversed...)
+
+	index := map[string]int{}
+	for _, seg := range result.Segments {
+		if seg.SpanID == nil {
+			continue
+		}
+		i, ok := index[*seg.SpanID]
+		if !ok {
+			i = len(result.Spans)
+			index[*seg.SpanID] = i
+			result.Spans = append(result.Spans, PathSpan{
+				SpanID: *seg.SpanID, Name: *seg.Name, ServiceName: seg.ServiceName, Depth: *seg.Depth,
+			})
+		}
+		result.Spans[i].SelfTimeNs += seg.DurationNs
+		result.Spans[i].SegmentCount++
+	}
+	slices.SortStableFunc(result.Spans, func(a, b PathSpan) int {
+		return cmp.Compare(b.SelfTimeNs, a.SelfTimeNs)
+	})
+	return result
+}
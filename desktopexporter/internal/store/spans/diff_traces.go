@@ -0,0 +1,489 @@
+package spans
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
+)
+
+// maxLatencyContributors caps the summary's list of the spans whose
+// critical-path time moved the most.
+const maxLatencyContributors = 5
+
+// diffSpan is one placed span as diff_traces.sql reports it. The embedded
+// pathSpan is what lets the same row feed criticalPath.
+type diffSpan struct {
+	pathSpan
+	StatusCode    string            `json:"statusCode"`
+	StatusMessage string            `json:"statusMessage"`
+	Attributes    []json.RawMessage `json:"attributes"`
+
+	// key is where the span sits in its tree, as alignKeys builds it.
+	key string
+}
+
+// diffSide is one trace as diff_traces.sql returns it.
+type diffSide struct {
+	TraceID           string      `json:"traceID"`
+	TraceStart        *int64      `json:"traceStart,string"`
+	UnplacedSpanCount int64       `json:"unplacedSpanCount"`
+	Spans             []*diffSpan `json:"spans"`
+}
+
+// DiffTrace is one side of a diff: the trace's bounds and size.
+type DiffTrace struct {
+	TraceID           string `json:"traceID"`
+	StartTime         *int64 `json:"startTime,string"`
+	DurationNs        int64  `json:"durationNs,string"`
+	SpanCount         int    `json:"spanCount"`
+	UnplacedSpanCount int64  `json:"unplacedSpanCount"`
+}
+
+// DiffSpanSide is one aligned span as it appears in one of the traces.
+// CriticalPathNs is its self time on that trace's critical path, zero when it
+// is off the path.
+type DiffSpanSide struct {
+	SpanID         string `json:"spanID"`
+	StartOffsetNs  int64  `json:"startOffsetNs,string"`
+	DurationNs     int64  `json:"durationNs,string"`
+	StatusCode     string `json:"statusCode"`
+	StatusMessage  string `json:"statusMessage"`
+	CriticalPathNs int64  `json:"criticalPathNs,string"`
+}
+
+// AttributeChange is one attribute key whose value differs between two
+// aligned spans. A and B are the wire attribute objects, null on the side
+// the key is missing from.
+type AttributeChange struct {
+	Key string          `json:"key"`
+	A   json.RawMessage `json:"a"`
+	B   json.RawMessage `json:"b"`
+}
+
+// DiffEntry is one node of the merged tree: a span in both traces, or in
+// only one of them.
+type DiffEntry struct {
+	// Change is "matched", "added" (in B only) or "removed" (in A only).
+	Change      string        `json:"change"`
+	ServiceName *string       `json:"serviceName"`
+	Name        string        `json:"name"`
+	Depth       int           `json:"depth"`
+	A           *DiffSpanSide `json:"a"`
+	B           *DiffSpanSide `json:"b"`
+	// DurationDeltaNs is B's duration less A's, for a matched span only.
+	DurationDeltaNs     *int64            `json:"durationDeltaNs,string"`
+	CriticalPathDeltaNs int64             `json:"criticalPathDeltaNs,string"`
+	StatusChanged       bool              `json:"statusChanged"`
+	AttributeChanges    []AttributeChange `json:"attributeChanges"`
+}
+
+// LatencyContributor names one entry whose critical-path time changed.
+type LatencyContributor struct {
+	Change              string  `json:"change"`
+	ServiceName         *string `json:"serviceName"`
+	Name                string  `json:"name"`
+	SpanIDA             *string `json:"spanIDA"`
+	SpanIDB             *string `json:"spanIDB"`
+	CriticalPathDeltaNs int64   `json:"criticalPathDeltaNs,string"`
+}
+
+// DiffSummary counts the changes and says where the latency went. The
+// entries' critical-path deltas and GapDeltaNs sum to the diff's
+// DurationDeltaNs exactly: each trace's critical path tiles its duration.
+type DiffSummary struct {
+	MatchedSpanCount     int                  `json:"matchedSpanCount"`
+	AddedSpanCount       int                  `json:"addedSpanCount"`
+	RemovedSpanCount     int                  `json:"removedSpanCount"`
+	StatusChangeCount    int                  `json:"statusChangeCount"`
+	AttributeChangeCount int                  `json:"attributeChangeCount"`
+	GapDeltaNs           int64                `json:"gapDeltaNs,string"`
+	LatencyContributors  []LatencyContributor `json:"latencyContributors"`
+}
+
+// TraceDiff is what diffTraces answers with.
+type TraceDiff struct {
+	TraceA          DiffTrace   `json:"traceA"`
+	TraceB          DiffTrace   `json:"traceB"`
+	DurationDeltaNs int64       `json:"durationDeltaNs,string"`
+	Summary         DiffSummary `json:"summary"`
+	// Spans is the merged tree, depth first, siblings in start order.
+	Spans []DiffEntry `json:"spans"`
+}
+
+// DiffTraces compares two traces structurally: a before and an after of the
+// same request, typically. Spans are aligned by (service, name, occurrence
+// among same-named siblings) under an aligned parent -- see alignKeys -- and
+// each aligned pair is compared for duration, status and attributes,
+// while spans without a partner are reported as added or removed.
+//
+// Where the extra latency came from is answered with CriticalPath rather than
+// with durations: a parent's duration delta repeats every child's, so summing
+// them counts the same nanoseconds once per level. Critical-path self time
+// does not overlap, and its deltas add up to the change in end-to-end time.
+func DiffTraces(ctx context.Context, db *sql.DB, traceA, traceB string) (json.RawMessage, error) {
+	query, err := renderWalkedTrace(queries.DiffTraces)
+	if err != nil {
+		return nil, fmt.Errorf("DiffTraces: %w: %w", ErrSpansStoreInternal, err)
+	}
+
+	// One query per trace: the walk names its CTEs, so one statement holds
+	// it once. Both run under the caller's read lock, so neither trace
+	// changes between them.
+	side := func(param, traceID string) (*diffSide, error) {
+		var raw []byte
+		if err := db.QueryRowContext(ctx, query, traceID).Scan(&raw); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("DiffTraces: %s: %w", param, ErrTraceIDNotFound)
+			}
+			return nil, fmt.Errorf("DiffTraces: %w: %w", ErrSpansStoreInternal, err)
+		}
+		var out diffSide
+		if err := json.Unmarshal(raw, &out); err != nil {
+			return nil, fmt.Errorf("DiffTraces: %w: %w", ErrSpansStoreInternal, err)
+		}
+		alignKeys(&out)
+		return &out, nil
+	}
+	a, err := side("traceA", traceA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := side("traceB", traceB)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := diffTraces(a, b)
+	if err != nil {
+		return nil, fmt.Errorf("DiffTraces: %w: %w", ErrSpansStoreInternal, err)
+	}
+	out, err := json.Marshal(diff)
+	if err != nil {
+		return nil, fmt.Errorf("DiffTraces: %w: %w", ErrSpansStoreInternal, err)
+	}
+	return json.RawMessage(out), nil
+}
+
+// diffNode is one key of the merged tree, holding the span each side has
+// under it.
+type diffNode struct {
+	a, b     *diffSpan
+	children []*diffNode
+	// offset orders siblings: the span's start offset in B, or in A when B
+	// does not have it.
+	offset int64
+	key    string
+}
+
+func diffTraces(a, b *diffSide) (TraceDiff, error) {
+	pathA, pathB := sideCriticalPath(a), sideCriticalPath(b)
+	diff := TraceDiff{
+		TraceA:          sideSummary(a, pathA),
+		TraceB:          sideSummary(b, pathB),
+		DurationDeltaNs: pathB.DurationNs - pathA.DurationNs,
+		Spans:           []DiffEntry{},
+	}
+	diff.Summary.LatencyContributors = []LatencyContributor{}
+	cpA, gapA := selfTimes(pathA)
+	cpB, gapB := selfTimes(pathB)
+	diff.Summary.GapDeltaNs = gapB - gapA
+
+	nodes := map[string]*diffNode{}
+	var order []*diffNode
+	place := func(side *diffSide, set func(*diffNode, *diffSpan)) {
+		for _, s := range side.Spans {
+			n, ok := nodes[s.key]
+			if !ok {
+				n = &diffNode{key: s.key}
+				nodes[s.key] = n
+				order = append(order, n)
+			}
+			set(n, s)
+		}
+	}
+	place(a, func(n *diffNode, s *diffSpan) { n.a, n.offset = s, s.StartTime-*a.TraceStart })
+	place(b, func(n *diffNode, s *diffSpan) { n.b, n.offset = s, s.StartTime-*b.TraceStart })
+
+	// Hang each node off its parent's. Both sides agree on the parent's key,
+	// since a key is built from it, so whichever side is present will do.
+	keyOf := func(side *diffSide) map[string]string {
+		out := make(map[string]string, len(side.Spans))
+		for _, s := range side.Spans {
+			out[s.SpanID] = s.key
+		}
+		return out
+	}
+	keysA, keysB := keyOf(a), keyOf(b)
+	var roots []*diffNode
+	for _, n := range order {
+		s, keys := n.b, keysB
+		if s == nil {
+			s, keys = n.a, keysA
+		}
+		if s.ParentSpanID == nil {
+			roots = append(roots, n)
+			continue
+		}
+		if parent, ok := nodes[keys[*s.ParentSpanID]]; ok {
+			parent.children = append(parent.children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+
+	var walk func(siblings []*diffNode, depth int) error
+	walk = func(siblings []*diffNode, depth int) error {
+		slices.SortFunc(siblings, func(x, y *diffNode) int {
+			return cmp.Or(cmp.Compare(x.offset, y.offset), cmp.Compare(x.key, y.key))
+		})
+		for _, n := range siblings {
+			entry, err := diffEntry(n, depth, a, b, cpA, cpB)
+			if err != nil {
+				return err
+			}
+			switch entry.Change {
+			case "matched":
+				diff.Summary.MatchedSpanCount++
+			case "added":
+				diff.Summary.AddedSpanCount++
+			case "removed":
+				diff.Summary.RemovedSpanCount++
+			}
+			if entry.StatusChanged {
+				diff.Summary.StatusChangeCount++
+			}
+			if len(entry.AttributeChanges) > 0 {
+				diff.Summary.AttributeChangeCount++
+			}
+			diff.Spans = append(diff.Spans, entry)
+			if err := walk(n.children, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(roots, 0); err != nil {
+		return TraceDiff{}, err
+	}
+
+	for _, e := range diff.Spans {
+		if e.CriticalPathDeltaNs == 0 {
+			continue
+		}
+		c := LatencyContributor{
+			Change: e.Change, ServiceName: e.ServiceName, Name: e.Name,
+			CriticalPathDeltaNs: e.CriticalPathDeltaNs,
+		}
+		if e.A != nil {
+			c.SpanIDA = &e.A.SpanID
+		}
+		if e.B != nil {
+			c.SpanIDB = &e.B.SpanID
+		}
+		diff.Summary.LatencyContributors = append(diff.Summary.LatencyContributors, c)
+	}
+	// Largest change first, slowdowns before speedups of the same size.
+	slices.SortStableFunc(diff.Summary.LatencyContributors, func(x, y LatencyContributor) int {
+		return cmp.Or(cmp.Compare(abs(y.CriticalPathDeltaNs), abs(x.CriticalPathDeltaNs)),
+			cmp.Compare(y.CriticalPathDeltaNs, x.CriticalPathDeltaNs))
+	})
+	if len(diff.Summary.LatencyContributors) > maxLatencyContributors {
+		diff.Summary.LatencyContributors = diff.Summary.LatencyContributors[:maxLatencyContributors]
+	}
+	return diff, nil
+}
+
+// alignKeys gives each of side's spans the key DiffTraces aligns it on: its
+// parent's key plus (service, name, occurrence), where occurrence counts the
+// siblings that share its service and name, in start order. Counting among
+// those siblings rather than among all of them keeps one added call from
+// shifting every later sibling out of alignment; building on the parent's key
+// means a span can only match under a parent that matched. Roots -- depth 0,
+// parent absent or never set -- are siblings of each other, so a trace whose
+// root never arrived still lines up with one whose root did not either.
+//
+// The parentage is the walk's, so every parent is among the spans, and the
+// spans arrive in start order, ties by span id.
+func alignKeys(side *diffSide) {
+	type siblings struct{ parent, service, name string }
+	byID := make(map[string]*diffSpan, len(side.Spans))
+	occurrence := make(map[*diffSpan]int, len(side.Spans))
+	seen := map[siblings]int{}
+	for _, s := range side.Spans {
+		byID[s.SpanID] = s
+		group := siblings{service: deref(s.ServiceName), name: s.Name}
+		if s.ParentSpanID != nil {
+			group.parent = *s.ParentSpanID
+		}
+		seen[group]++
+		occurrence[s] = seen[group]
+	}
+
+	var keyOf func(s *diffSpan) string
+	keyOf = func(s *diffSpan) string {
+		if s.key == "" {
+			var parent string
+			if s.ParentSpanID != nil {
+				parent = keyOf(byID[*s.ParentSpanID])
+			}
+			// Quoted, so no service or name can run into the next step.
+			s.key = fmt.Sprintf("%s[%s %s %d]", parent,
+				strconv.Quote(deref(s.ServiceName)), strconv.Quote(s.Name), occurrence[s])
+		}
+		return s.key
+	}
+	for _, s := range side.Spans {
+		keyOf(s)
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// diffEntry compares the two sides of one node.
+func diffEntry(n *diffNode, depth int, a, b *diffSide, cpA, cpB map[string]int64) (DiffEntry, error) {
+	entry := DiffEntry{Depth: depth, AttributeChanges: []AttributeChange{}}
+	side := func(s *diffSpan, trace *diffSide, cp map[string]int64) *DiffSpanSide {
+		if s == nil {
+			return nil
+		}
+		entry.ServiceName, entry.Name = s.ServiceName, s.Name
+		return &DiffSpanSide{
+			SpanID:         s.SpanID,
+			StartOffsetNs:  s.StartTime - *trace.TraceStart,
+			DurationNs:     s.EndTime - s.StartTime,
+			StatusCode:     s.StatusCode,
+			StatusMessage:  s.StatusMessage,
+			CriticalPathNs: cp[s.SpanID],
+		}
+	}
+	entry.A, entry.B = side(n.a, a, cpA), side(n.b, b, cpB)
+
+	switch {
+	case entry.A == nil:
+		entry.Change = "added"
+		entry.CriticalPathDeltaNs = entry.B.CriticalPathNs
+	case entry.B == nil:
+		entry.Change = "removed"
+		entry.CriticalPathDeltaNs = -entry.A.CriticalPathNs
+	default:
+		entry.Change = "matched"
+		delta := entry.B.DurationNs - entry.A.DurationNs
+		entry.DurationDeltaNs = &delta
+		entry.CriticalPathDeltaNs = entry.B.CriticalPathNs - entry.A.CriticalPathNs
+		entry.StatusChanged = entry.A.StatusCode != entry.B.StatusCode ||
+			entry.A.StatusMessage != entry.B.StatusMessage
+		changes, err := attributeChanges(n.a.Attributes, n.b.Attributes)
+		if err != nil {
+			return DiffEntry{}, err
+		}
+		entry.AttributeChanges = changes
+	}
+	return entry, nil
+}
+
+// attributeChanges compares two wire attribute arrays by key. Both are sorted
+// by key, as attrs_mapped renders them, so the result is too.
+func attributeChanges(a, b []json.RawMessage) ([]AttributeChange, error) {
+	type wireAttr struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+		Type  string          `json:"type"`
+	}
+	index := func(attrs []json.RawMessage) (map[string]wireAttr, map[string]json.RawMessage, []string, error) {
+		parsed := make(map[string]wireAttr, len(attrs))
+		raw := make(map[string]json.RawMessage, len(attrs))
+		var keys []string
+		for _, r := range attrs {
+			var w wireAttr
+			if err := json.Unmarshal(r, &w); err != nil {
+				return nil, nil, nil, err
+			}
+			if _, seen := parsed[w.Key]; !seen {
+				keys = append(keys, w.Key)
+			}
+			parsed[w.Key], raw[w.Key] = w, r
+		}
+		return parsed, raw, keys, nil
+	}
+	parsedA, rawA, keysA, err := index(a)
+	if err != nil {
+		return nil, err
+	}
+	parsedB, rawB, keysB, err := index(b)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := slices.Concat(keysA, keysB)
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+	changes := []AttributeChange{}
+	for _, k := range keys {
+		wa, inA := parsedA[k]
+		wb, inB := parsedB[k]
+		if inA && inB && wa.Type == wb.Type && bytes.Equal(wa.Value, wb.Value) {
+			continue
+		}
+		changes = append(changes, AttributeChange{Key: k, A: rawA[k], B: rawB[k]})
+	}
+	return changes, nil
+}
+
+// sideCriticalPath walks one side's critical path over its own rows.
+func sideCriticalPath(side *diffSide) CriticalPathResult {
+	trace := pathTrace{
+		TraceID:           side.TraceID,
+		TraceStart:        side.TraceStart,
+		UnplacedSpanCount: side.UnplacedSpanCount,
+	}
+	for _, s := range side.Spans {
+		trace.Spans = append(trace.Spans, &s.pathSpan)
+	}
+	return criticalPath(trace)
+}
+
+// selfTimes is each span's critical-path time, and the time spent in gaps
+// between roots.
+func selfTimes(path CriticalPathResult) (map[string]int64, int64) {
+	out := make(map[string]int64, len(path.Spans))
+	for _, s := range path.Spans {
+		out[s.SpanID] = s.SelfTimeNs
+	}
+	var gaps int64
+	for _, seg := range path.Segments {
+		if seg.SpanID == nil {
+			gaps += seg.DurationNs
+		}
+	}
+	return out, gaps
+}
+
+func sideSummary(side *diffSide, path CriticalPathResult) DiffTrace {
+	return DiffTrace{
+		TraceID:           side.TraceID,
+		StartTime:         path.StartTime,
+		DurationNs:        path.DurationNs,
+		SpanCount:         len(side.Spans) + int(side.UnplacedSpanCount),
+		UnplacedSpanCount: side.UnplacedSpanCount,
+	}
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,219 @@
+package spans
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/rebuild"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// otlpDocument is what export_spans.sql returns. Resources and scopes are
+// keyed by seq, and each span names its owners by the same seq, as in the
+// searchSpans response.
+type otlpDocument struct {
+	Resources map[string]rebuild.Resource `json:"resources"`
+	Scopes    map[string]rebuild.Scope    `json:"scopes"`
+	TraceIDs  []string                    `json:"traceIDs"`
+	Spans     []otlpSpan                  `json:"spans"`
+}
+
+// otlpSpan is span_data_json plus the fields export_spans.sql patches in.
+// Nullable columns decode as their zero value, which is also what pdata
+// takes for absent.
+type otlpSpan struct {
+	TraceID                string              `json:"traceID"`
+	TraceState             string              `json:"traceState"`
+	SpanID                 string              `json:"spanID"`
+	ParentSpanID           string              `json:"parentSpanID"`
+	Flags                  uint32              `json:"flags"`
+	Name                   string              `json:"name"`
+	Kind                   string              `json:"kind"`
+	Start                  int64               `json:"start"`
+	Dur                    int64               `json:"dur"`
+	Attributes             []rebuild.Attribute `json:"attributes"`
+	Events                 []otlpEvent         `json:"events"`
+	Links                  []otlpLink          `json:"links"`
+	R                      int64               `json:"r"`
+	S                      int64               `json:"s"`
+	DroppedAttributesCount uint32              `json:"droppedAttributesCount"`
+	DroppedEventsCount     uint32              `json:"droppedEventsCount"`
+	DroppedLinksCount      uint32              `json:"droppedLinksCount"`
+	StatusCode             string              `json:"statusCode"`
+	StatusMessage          string              `json:"statusMessage"`
+	ResourceSchemaURL      string              `json:"resourceSchemaURL"`
+	ScopeSchemaURL         string              `json:"scopeSchemaURL"`
+}
+
+// otlpEvent is event_json.
+type otlpEvent struct {
+	Name                   string              `json:"name"`
+	Timestamp              string              `json:"timestamp"`
+	DroppedAttributesCount uint32              `json:"droppedAttributesCount"`
+	Attributes             []rebuild.Attribute `json:"attributes"`
+}
+
+// otlpLink is link_json.
+type otlpLink struct {
+	TraceID                string              `json:"traceID"`
+	SpanID                 string              `json:"spanID"`
+	TraceState             string              `json:"traceState"`
+	DroppedAttributesCount uint32              `json:"droppedAttributesCount"`
+	Flags                  uint32              `json:"flags"`
+	Attributes             []rebuild.Attribute `json:"attributes"`
+}
+
+// ExportOTLP rebuilds the given traces, every span of each, as pdata.
+// traceIDs are canonical dashed uuids; one the store does not hold is
+// ErrTraceIDNotFound rather than a silently shorter export.
+//
+// Spans are regrouped under their resource and scope, one ResourceSpans per
+// distinct resource and schema url, so a trace that arrived over many export
+// requests comes back as one. What cannot come back is anything the store
+// never kept: the order spans, events and links were sent in, the attribute
+// set of a resource beyond the one copy the store holds of it (see
+// ingest.ResourceID), and the value types util.StringAndTypeToValue lists.
+func ExportOTLP(ctx context.Context, db *sql.DB, traceIDs []string) (ptrace.Traces, error) {
+	td := ptrace.NewTraces()
+	query, err := queries.Render(queries.ExportSpans, nil)
+	if err != nil {
+		return td, fmt.Errorf("ExportOTLP: %w: %w", ErrSpansStoreInternal, err)
+	}
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, traceIDs).Scan(&raw); err != nil {
+		return td, fmt.Errorf("ExportOTLP: %w: %w", ErrSpansStoreInternal, err)
+	}
+	var doc otlpDocument
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return td, fmt.Errorf("ExportOTLP: %w: %w", ErrSpansStoreInternal, err)
+	}
+	if err := checkAllFound(traceIDs, doc.TraceIDs); err != nil {
+		return td, fmt.Errorf("ExportOTLP: %w", err)
+	}
+
+	var rs ptrace.ResourceSpans
+	var ss ptrace.ScopeSpans
+	var cur rebuild.Batch
+	for i, row := range doc.Spans {
+		batch := rebuild.Batch{
+			Resource:          strconv.FormatInt(row.R, 10),
+			ResourceSchemaURL: row.ResourceSchemaURL,
+			Scope:             strconv.FormatInt(row.S, 10),
+			ScopeSchemaURL:    row.ScopeSchemaURL,
+		}
+		if i == 0 || !batch.SameResource(cur) {
+			rs = td.ResourceSpans().AppendEmpty()
+			doc.Resources[batch.Resource].CopyTo(rs.Resource())
+			rs.SetSchemaUrl(batch.ResourceSchemaURL)
+		}
+		if i == 0 || batch != cur {
+			ss = rs.ScopeSpans().AppendEmpty()
+			doc.Scopes[batch.Scope].CopyTo(ss.Scope())
+			ss.SetSchemaUrl(batch.ScopeSchemaURL)
+		}
+		cur = batch
+		if err := row.copyTo(ss.Spans().AppendEmpty()); err != nil {
+			return td, fmt.Errorf("ExportOTLP: %w: %w", ErrSpansStoreInternal, err)
+		}
+	}
+	return td, nil
+}
+
+// checkAllFound returns ErrTraceIDNotFound for the first requested id the
+// export did not find.
+func checkAllFound(asked, found []string) error {
+	have := make(map[string]bool, len(found))
+	for _, id := range found {
+		have[id] = true
+	}
+	for _, id := range asked {
+		if !have[id] {
+			return fmt.Errorf("%w: %s", ErrTraceIDNotFound, id)
+		}
+	}
+	return nil
+}
+
+func (row otlpSpan) copyTo(span ptrace.Span) error {
+	traceID, err := rebuild.TraceID(row.TraceID)
+	if err != nil {
+		return err
+	}
+	spanID, err := rebuild.SpanID(row.SpanID)
+	if err != nil {
+		return err
+	}
+	parentID, err := rebuild.SpanID(row.ParentSpanID)
+	if err != nil {
+		return err
+	}
+	span.SetTraceID(traceID)
+	span.SetSpanID(spanID)
+	span.SetParentSpanID(parentID)
+	span.TraceState().FromRaw(row.TraceState)
+	span.SetFlags(row.Flags)
+	span.SetName(row.Name)
+	span.SetKind(spanKind(row.Kind))
+	span.SetStartTimestamp(pcommon.Timestamp(row.Start))
+	span.SetEndTimestamp(pcommon.Timestamp(row.Start + row.Dur))
+	rebuild.Attributes(span.Attributes(), row.Attributes)
+	span.SetDroppedAttributesCount(row.DroppedAttributesCount)
+	span.SetDroppedEventsCount(row.DroppedEventsCount)
+	span.SetDroppedLinksCount(row.DroppedLinksCount)
+	span.Status().SetCode(statusCode(row.StatusCode))
+	span.Status().SetMessage(row.StatusMessage)
+
+	for _, e := range row.Events {
+		ts, err := rebuild.Timestamp(e.Timestamp)
+		if err != nil {
+			return err
+		}
+		event := span.Events().AppendEmpty()
+		event.SetName(e.Name)
+		event.SetTimestamp(ts)
+		rebuild.Attributes(event.Attributes(), e.Attributes)
+		event.SetDroppedAttributesCount(e.DroppedAttributesCount)
+	}
+	for _, l := range row.Links {
+		traceID, err := rebuild.TraceID(l.TraceID)
+		if err != nil {
+			return err
+		}
+		spanID, err := rebuild.SpanID(l.SpanID)
+		if err != nil {
+			return err
+		}
+		link := span.Links().AppendEmpty()
+		link.SetTraceID(traceID)
+		link.SetSpanID(spanID)
+		link.TraceState().FromRaw(l.TraceState)
+		link.SetFlags(l.Flags)
+		rebuild.Attributes(link.Attributes(), l.Attributes)
+		link.SetDroppedAttributesCount(l.DroppedAttributesCount)
+	}
+	return nil
+}
+
+// spanKind and statusCode read back the String() forms ingest stored.
+func spanKind(s string) ptrace.SpanKind {
+	for k := ptrace.SpanKindUnspecified; k <= ptrace.SpanKindConsumer; k++ {
+		if k.String() == s {
+			return k
+		}
+	}
+	return ptrace.SpanKindUnspecified
+}
+
+func statusCode(s string) ptrace.StatusCode {
+	for c := ptrace.StatusCodeUnset; c <= ptrace.StatusCodeError; c++ {
+		if c.String() == s {
+			return c
+		}
+	}
+	return ptrace.StatusCodeUnset
+}
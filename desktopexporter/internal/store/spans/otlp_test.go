@@ -0,0 +1,142 @@
+package spans_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/spans"
+)
+
+// otlpFixtureService is one export request: a service's resource, one scope,
+// and its spans. Everything the store keeps is set to something other than
+// its zero value, and everything it puts in an order of its own -- attribute
+// keys, spans, events, links -- is already in that order, so the export can
+// be compared with the input as it stands.
+func otlpFixtureService(td ptrace.Traces, service string, traceID pcommon.TraceID, spanIDs ...byte) {
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.SetSchemaUrl("https://opentelemetry.io/schemas/1.27.0")
+	res := rs.Resource()
+	res.Attributes().PutStr("service.instance.id", service+"-1")
+	res.Attributes().PutStr("service.name", service)
+	res.SetDroppedAttributesCount(1)
+
+	ss := rs.ScopeSpans().AppendEmpty()
+	ss.SetSchemaUrl("https://opentelemetry.io/schemas/1.26.0")
+	ss.Scope().SetName("io.opentelemetry.http")
+	ss.Scope().SetVersion("1.2.3")
+	ss.Scope().Attributes().PutBool("sampled", true)
+	ss.Scope().SetDroppedAttributesCount(2)
+
+	for i, id := range spanIDs {
+		span := ss.Spans().AppendEmpty()
+		span.SetTraceID(traceID)
+		span.SetSpanID(pcommon.SpanID{0, 0, 0, 0, 0, 0, 0, id})
+		if i > 0 {
+			span.SetParentSpanID(pcommon.SpanID{0, 0, 0, 0, 0, 0, 0, spanIDs[0]})
+		}
+		span.TraceState().FromRaw("vendor=a")
+		span.SetFlags(0x101)
+		span.SetName(service + " work")
+		span.SetKind(ptrace.SpanKindServer)
+		start := pcommon.Timestamp(1_700_000_000_000_000_000 + int64(i)*1_000_000)
+		span.SetStartTimestamp(start)
+		span.SetEndTimestamp(start + 5_000_123)
+		attrs := span.Attributes()
+		attrs.PutEmptyBytes("body").FromRaw([]byte{0xca, 0xfe})
+		attrs.PutDouble("ratio", 0.25)
+		attrs.PutInt("retries", 3)
+		tags := attrs.PutEmptySlice("tags")
+		tags.AppendEmpty().SetStr("a")
+		tags.AppendEmpty().SetStr(`b"c`)
+		span.SetDroppedAttributesCount(4)
+		span.SetDroppedEventsCount(5)
+		span.SetDroppedLinksCount(6)
+		span.Status().SetCode(ptrace.StatusCodeError)
+		span.Status().SetMessage("boom")
+
+		for j, name := range []string{"retry", "exception"} {
+			event := span.Events().AppendEmpty()
+			event.SetName(name)
+			event.SetTimestamp(start + pcommon.Timestamp(j+1))
+			event.Attributes().PutEmptyMap("detail").PutInt("attempt", int64(j))
+			event.SetDroppedAttributesCount(7)
+		}
+
+		link := span.Links().AppendEmpty()
+		link.SetTraceID(pcommon.TraceID{9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9})
+		link.SetSpanID(pcommon.SpanID{8, 8, 8, 8, 8, 8, 8, 8})
+		link.TraceState().FromRaw("vendor=b")
+		link.SetFlags(1)
+		link.Attributes().PutStr("kind", "follows")
+		link.SetDroppedAttributesCount(8)
+	}
+}
+
+func ingestTraces(t *testing.T, s *store.Store, td ptrace.Traces) {
+	t.Helper()
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return spans.Ingest(context.Background(), conn, td, s.FlushedIDs())
+	}))
+}
+
+func exportTraces(s *store.Store, traceIDs ...string) (ptrace.Traces, error) {
+	return readStore(s, func(db *sql.DB) (ptrace.Traces, error) {
+		return spans.ExportOTLP(context.Background(), db, traceIDs)
+	})
+}
+
+// A trace exported from the store is the trace that was sent: same spans,
+// same resources and scopes around them, field for field.
+func TestExportOTLPRoundTrip(t *testing.T) {
+	s, _, teardown := setupStore(t)
+	defer teardown()
+
+	traceID := pcommon.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	// Two requests, as two services would send them. The same trace comes
+	// back as one export holding both.
+	frontend := ptrace.NewTraces()
+	otlpFixtureService(frontend, "frontend", traceID, 1, 2)
+	backend := ptrace.NewTraces()
+	otlpFixtureService(backend, "backend", traceID, 3)
+	ingestTraces(t, s, frontend)
+	ingestTraces(t, s, backend)
+
+	// Noise that must not come along.
+	other := ptrace.NewTraces()
+	otlpFixtureService(other, "frontend", pcommon.TraceID{7}, 4)
+	ingestTraces(t, s, other)
+
+	want := ptrace.NewTraces()
+	frontend.ResourceSpans().MoveAndAppendTo(want.ResourceSpans())
+	backend.ResourceSpans().MoveAndAppendTo(want.ResourceSpans())
+
+	got, err := exportTraces(s, "01020304-0506-0708-090a-0b0c0d0e0f10")
+	require.NoError(t, err)
+
+	marshaler := &ptrace.JSONMarshaler{}
+	wantJSON, err := marshaler.MarshalTraces(want)
+	require.NoError(t, err)
+	gotJSON, err := marshaler.MarshalTraces(got)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(wantJSON), string(gotJSON))
+}
+
+func TestExportOTLPUnknownTrace(t *testing.T) {
+	s, _, teardown := setupStore(t)
+	defer teardown()
+
+	td := ptrace.NewTraces()
+	otlpFixtureService(td, "frontend", pcommon.TraceID{1}, 1)
+	ingestTraces(t, s, td)
+
+	_, err := exportTraces(s, "01000000-0000-0000-0000-000000000000", "02000000-0000-0000-0000-000000000000")
+	assert.ErrorIs(t, err, spans.ErrTraceIDNotFound)
+}
@@ -1,5 +1,7 @@
 package spans
 
+import "github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/search"
+
 // searchSpansParams are the conditional fragments searchSpansSQL assembles into
 // queries/spans/search_spans.sql.
 //
@@ -18,6 +20,21 @@ type searchSpansParams struct {
 	MatchedCTE  string
 	MatchedExpr string
 	MatchedJoin string
+	// Walk is queries/spans/trace_walk.sql over search_params; see
+	// renderTraceWalk.
+	Walk string
+}
+
+// traceWalkParams names the CTE queries/spans/trace_walk.sql reads the trace
+// id from.
+type traceWalkParams struct {
+	Params string
+}
+
+// walkedTraceParams is what describe_trace.sql and critical_path.sql take:
+// the walk alone, over their own params CTE. Neither searches.
+type walkedTraceParams struct {
+	Walk string
 }
 
 // searchTracesParams are the fragments searchTracesSQL assembles into
@@ -30,4 +47,35 @@ type searchTracesParams struct {
 	From string
 	// Where is the predicate, "true" when there are no criteria.
 	Where string
+	// PageSQL sizes the page and says whether a cursor applies; the zero
+	// value is the whole result.
+	search.PageSQL
+}
+
+// serviceGraphParams are the fragments serviceGraphSQL assembles into
+// queries/spans/service_graph.sql: the trace search's own CTE, FROM and
+// predicate, which is what makes the graph cover exactly the traces the list
+// shows.
+type serviceGraphParams struct {
+	CTEs  string
+	From  string
+	Where string
+}
+
+// operationStatsParams are the fragments operationStatsSQL assembles into
+// queries/spans/operation_stats.sql. Dims come from groupByDims and never from
+// caller text, which is why they can be rendered rather than bound.
+type operationStatsParams struct {
+	CTEs  string
+	From  string
+	Where string
+	Dims  []groupByDim
+}
+
+// tailSpansParams are the fragments Tail assembles into
+// queries/spans/tail_spans.sql. No From: the query joins outward from the key
+// list, not from spans.
+type tailSpansParams struct {
+	CTEs  string
+	Where string
 }
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"strings"
 
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/ingest"
@@ -75,7 +76,24 @@ func resourceServiceName(attrs pcommon.Map) string {
 // because the inserts commit before any appender flush, and the appenders open
 // after the resolve -- the same shape metrics.Ingest already used for its
 // stream upsert.
-func Ingest(ctx context.Context, conn driver.Conn, traces ptrace.Traces, flushed *ingest.FlushedIDs) (err error) {
+func Ingest(ctx context.Context, conn driver.Conn, traces ptrace.Traces, flushed *ingest.FlushedIDs) error {
+	return ingestSpans(ctx, conn, traces, flushed, nil)
+}
+
+// IngestReturning is Ingest, also returning the key of every span row it
+// appended -- the span id, in the padded uuid form the table stores -- in
+// append order. A live tail reads these back to find what just arrived.
+// Callers with no use for them should call Ingest, which skips formatting a
+// string per span.
+func IngestReturning(ctx context.Context, conn driver.Conn, traces ptrace.Traces, flushed *ingest.FlushedIDs) ([]string, error) {
+	keys := make([]string, 0, traces.SpanCount())
+	if err := ingestSpans(ctx, conn, traces, flushed, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func ingestSpans(ctx context.Context, conn driver.Conn, traces ptrace.Traces, flushed *ingest.FlushedIDs, keys *[]string) (err error) {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
@@ -193,6 +211,9 @@ func Ingest(ctx context.Context, conn driver.Conn, traces ptrace.Traces, flushed
 				if err != nil {
 					return fmt.Errorf("Ingest: %w: %w", ErrSpansStoreInternal, err)
 				}
+				if keys != nil {
+					*keys = append(*keys, uuid.UUID(spanUUID).String())
+				}
 
 				for _, event := range span.Events().All() {
 					eventAttrIDs := ingest.NonNil(eventAttrs[eventCur])
@@ -258,26 +279,54 @@ func Ingest(ctx context.Context, conn driver.Conn, traces ptrace.Traces, flushed
 }
 
 // SearchTraces returns trace summaries in the time range matching the optional criteria.
+//
+// Every match, unbounded -- for in-process callers that want the whole set.
+// Anything answering a request goes through SearchTracesPage.
 func SearchTraces(ctx context.Context, db *sql.DB, startTime, endTime int64, criteria any) (json.RawMessage, error) {
-	finalQuery, args, err := searchTracesSQL(startTime, endTime, criteria)
+	raw, _, _, err := searchTraces(ctx, db, startTime, endTime, criteria, search.Page{})
 	if err != nil {
 		return nil, err
 	}
-
-	var raw []byte
-	if err := db.QueryRowContext(ctx, finalQuery, args...).Scan(&raw); err != nil {
-		return nil, fmt.Errorf("SearchTraces: %w: %w", ErrSpansStoreInternal, err)
-	}
 	if raw == nil {
 		return json.RawMessage("[]"), nil
 	}
 	return json.RawMessage(raw), nil
 }
 
+// SearchTracesPage is SearchTraces one page at a time: at most page.Limit
+// summaries, newest first, starting after page.After, wrapped in a
+// search.PageResult whose nextCursor continues the walk.
+func SearchTracesPage(ctx context.Context, db *sql.DB, startTime, endTime int64, criteria any, page search.Page) (json.RawMessage, error) {
+	raw, nextKey, nextID, err := searchTraces(ctx, db, startTime, endTime, criteria, page)
+	if err != nil {
+		return nil, err
+	}
+	out, err := search.PageFromRow(raw, nextKey, nextID)
+	if err != nil {
+		return nil, fmt.Errorf("SearchTracesPage: %w: %w", ErrSpansStoreInternal, err)
+	}
+	return out, nil
+}
+
+func searchTraces(ctx context.Context, db *sql.DB, startTime, endTime int64, criteria any, page search.Page) ([]byte, *int64, *string, error) {
+	finalQuery, args, err := searchTracesSQL(startTime, endTime, criteria, page)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var raw []byte
+	var nextKey *int64
+	var nextID *string
+	if err := db.QueryRowContext(ctx, finalQuery, args...).Scan(&raw, &nextKey, &nextID); err != nil {
+		return nil, nil, nil, fmt.Errorf("SearchTraces: %w: %w", ErrSpansStoreInternal, err)
+	}
+	return raw, nextKey, nextID, nil
+}
+
 // searchTracesSQL renders the trace-summary query and its bound arguments.
 // Split out for the same reason as searchSpansSQL: so a golden test can pin the
 // rendered text without standing up a store.
-func searchTracesSQL(startTime, endTime int64, criteria any) (string, []any, error) {
+func searchTracesSQL(startTime, endTime int64, criteria any, page search.Page) (string, []any, error) {
 	var searchTree *search.QueryNode
 	if criteria != nil {
 		var err error
@@ -287,7 +336,7 @@ func searchTracesSQL(startTime, endTime int64, criteria any) (string, []any, err
 		}
 	}
 
-	cteSQL, whereClause, args, err := buildTraceSQL(searchTree, startTime, endTime)
+	cteSQL, whereClause, args, err := buildTraceSQL(searchTree, startTime, endTime, page.Params()...)
 	if err != nil {
 		return "", nil, fmt.Errorf("SearchTraces: %w: %w", ErrInvalidTraceQuery, err)
 	}
@@ -305,15 +354,146 @@ func searchTracesSQL(startTime, endTime int64, criteria any) (string, []any, err
 	// durationNs are precomputed from span bounds so the summary always
 	// reflects wall-clock coverage.
 	finalQuery, err := queries.Render(queries.SearchTraces, searchTracesParams{
+		CTEs:    cteSQL,
+		From:    spanSearchFrom,
+		Where:   whereClause,
+		PageSQL: page.SQL(),
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("SearchTraces: %w: %w", ErrSpansStoreInternal, err)
+	}
+
+	return finalQuery, args, nil
+}
+
+// GetServiceGraph returns the services seen in the traces a trace search
+// selects and the edges between them: caller to callee from parent/child
+// spans, and producer to consumer from span links. Each edge carries a call
+// count, an error count and p50/p90/p99 of the callee's span duration.
+//
+// criteria is the searchTraces query tree, rendered through buildTraceSQL, so
+// a filter that lists a set of traces draws the graph of the same set.
+func GetServiceGraph(ctx context.Context, db *sql.DB, startTime, endTime int64, criteria any) (json.RawMessage, error) {
+	query, args, err := serviceGraphSQL(startTime, endTime, criteria)
+	if err != nil {
+		return nil, err
+	}
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("GetServiceGraph: %w: %w", ErrSpansStoreInternal, err)
+	}
+	return json.RawMessage(raw), nil
+}
+
+func serviceGraphSQL(startTime, endTime int64, criteria any) (string, []any, error) {
+	var searchTree *search.QueryNode
+	if criteria != nil {
+		var err error
+		searchTree, err = search.ParseQueryTree(criteria)
+		if err != nil {
+			return "", nil, fmt.Errorf("GetServiceGraph: %w: %w", ErrInvalidTraceQuery, err)
+		}
+	}
+	cteSQL, whereClause, args, err := buildTraceSQL(searchTree, startTime, endTime)
+	if err != nil {
+		return "", nil, fmt.Errorf("GetServiceGraph: %w: %w", ErrInvalidTraceQuery, err)
+	}
+	query, err := queries.Render(queries.ServiceGraph, serviceGraphParams{
 		CTEs:  cteSQL,
 		From:  spanSearchFrom,
 		Where: whereClause,
 	})
 	if err != nil {
-		return "", nil, fmt.Errorf("SearchTraces: %w: %w", ErrSpansStoreInternal, err)
+		return "", nil, fmt.Errorf("GetServiceGraph: %w: %w", ErrSpansStoreInternal, err)
 	}
+	return query, args, nil
+}
 
-	return finalQuery, args, nil
+// groupByDim is one dimension getOperationStats can group spans by: the
+// output key and column alias, and the expression producing it.
+type groupByDim struct {
+	Col  string
+	Expr string
+}
+
+// groupByDims is every dimension a caller may name, keyed by that name. An
+// allowlist rather than a column lookup: the expressions are rendered into
+// the SQL, so nothing a caller sends may reach the text.
+var groupByDims = map[string]groupByDim{
+	// nullif as everywhere service_name is served: no service is null, not ''.
+	"service": {Col: "service", Expr: "nullif(s.service_name, '')"},
+	"name":    {Col: "name", Expr: "s.name"},
+	"kind":    {Col: "kind", Expr: "s.kind"},
+}
+
+// DefaultOperationGroupBy is the grouping getOperationStats uses when the
+// caller names none: one row per operation, an operation being a span name
+// within a service.
+var DefaultOperationGroupBy = []string{"service", "name"}
+
+// MaxOperationBuckets caps getOperationStats' targetBuckets. Each group
+// carries a point per bucket, so the cap bounds the series by the groups
+// alone; a chart has no use for more points than it has pixels across.
+const MaxOperationBuckets = 1000
+
+// GetOperationStats returns RED figures -- rate, error count and ratio, and
+// p50/p90/p99 duration -- for the spans matching criteria in the window,
+// grouped by the dimensions in groupBy. An empty groupBy is one group for the
+// whole selection. targetBuckets above zero adds a per-group series on the
+// bucket_width_ns ladder, so "did checkout get slower" can be read across the
+// window as well as over it.
+//
+// criteria is the searchTraces query tree, applied per span.
+func GetOperationStats(ctx context.Context, db *sql.DB, startTime, endTime int64, criteria any, groupBy []string, targetBuckets int64) (json.RawMessage, error) {
+	query, args, err := operationStatsSQL(startTime, endTime, criteria, groupBy, targetBuckets)
+	if err != nil {
+		return nil, err
+	}
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("GetOperationStats: %w: %w", ErrSpansStoreInternal, err)
+	}
+	return json.RawMessage(raw), nil
+}
+
+func operationStatsSQL(startTime, endTime int64, criteria any, groupBy []string, targetBuckets int64) (string, []any, error) {
+	dims := make([]groupByDim, 0, len(groupBy))
+	seen := make(map[string]bool, len(groupBy))
+	for _, name := range groupBy {
+		dim, ok := groupByDims[name]
+		if !ok {
+			return "", nil, fmt.Errorf("GetOperationStats: cannot group by %q: %w", name, ErrInvalidTraceQuery)
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		dims = append(dims, dim)
+	}
+
+	var searchTree *search.QueryNode
+	if criteria != nil {
+		var err error
+		searchTree, err = search.ParseQueryTree(criteria)
+		if err != nil {
+			return "", nil, fmt.Errorf("GetOperationStats: %w: %w", ErrInvalidTraceQuery, err)
+		}
+	}
+	cteSQL, whereClause, args, err := buildTraceSQL(searchTree, startTime, endTime,
+		search.NamedParam{Name: "target_buckets", Value: targetBuckets})
+	if err != nil {
+		return "", nil, fmt.Errorf("GetOperationStats: %w: %w", ErrInvalidTraceQuery, err)
+	}
+	query, err := queries.Render(queries.OperationStats, operationStatsParams{
+		CTEs:  cteSQL,
+		From:  spanSearchFrom,
+		Where: whereClause,
+		Dims:  dims,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("GetOperationStats: %w: %w", ErrSpansStoreInternal, err)
+	}
+	return query, args, nil
 }
 
 // SearchSpans returns spans for a single trace, optionally filtered by search criteria.
@@ -382,6 +562,77 @@ func salvageSpans(ctx context.Context, db *sql.DB, traceID string, criteria any)
 	return json.RawMessage(raw), nil
 }
 
+// renderTraceWalk renders the walk every one-trace query places spans with,
+// reading the trace id from the CTE named params. Trimmed, since it is
+// spliced into the middle of a CTE list, indented by the query it lands in.
+func renderTraceWalk(params string) (string, error) {
+	walk, err := queries.Render(queries.TraceWalk, traceWalkParams{Params: params})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(walk), nil
+}
+
+// renderWalkedTrace renders describe_trace.sql or critical_path.sql, which
+// open with the walk over their own params CTE.
+func renderWalkedTrace(name queries.Name) (string, error) {
+	walk, err := renderTraceWalk("params")
+	if err != nil {
+		return "", err
+	}
+	return queries.Render(name, walkedTraceParams{Walk: walk})
+}
+
+// DescribeTrace returns the shape of one trace -- span, error and service
+// counts, its root, depth, time bounds, missing parents and slowest spans --
+// computed in SQL, so a caller can triage a trace without fetching it. On the
+// reference trace that is well under 2KB against SearchSpans' ~170KB.
+//
+// It walks the tree the way SearchSpans does but never salvages: spans on a
+// parent cycle are counted as unplacedSpanCount and carry a null depth, where
+// the waterfall would go on to place them. Describing a broken trace should
+// say that it is broken, not pay to hide it.
+func DescribeTrace(ctx context.Context, db *sql.DB, traceID string) (json.RawMessage, error) {
+	query, err := renderWalkedTrace(queries.DescribeTrace)
+	if err != nil {
+		return nil, fmt.Errorf("DescribeTrace: %w: %w", ErrSpansStoreInternal, err)
+	}
+
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, traceID).Scan(&raw); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("DescribeTrace: %w", ErrTraceIDNotFound)
+		}
+		return nil, fmt.Errorf("DescribeTrace: %w: %w", ErrSpansStoreInternal, err)
+	}
+	return json.RawMessage(raw), nil
+}
+
+// ListServices returns the names of the services that have reported spans, as
+// a sorted JSON array of strings.
+func ListServices(ctx context.Context, db *sql.DB) (json.RawMessage, error) {
+	return listNames(ctx, db, queries.ListServices, "ListServices")
+}
+
+// ListOperations returns the distinct span names one service has reported, as
+// a sorted JSON array of strings -- empty, not an error, for a service the
+// store has never seen, which is what Jaeger answers too.
+func ListOperations(ctx context.Context, db *sql.DB, service string) (json.RawMessage, error) {
+	return listNames(ctx, db, queries.ListOperations, "ListOperations", service)
+}
+
+func listNames(ctx context.Context, db *sql.DB, name queries.Name, caller string, args ...any) (json.RawMessage, error) {
+	query, err := queries.Render(name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", caller, ErrSpansStoreInternal, err)
+	}
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", caller, ErrSpansStoreInternal, err)
+	}
+	return json.RawMessage(raw), nil
+}
+
 // searchSpansSQL renders the trace-fetch query and its bound arguments.
 //
 // Split out from SearchSpans so the SQL is reachable without a database. That
@@ -441,11 +692,16 @@ func renderSpansQuery(name queries.Name, traceID string, criteria any) (string,
 	// performance difference -- DuckDB already materialises a recursive CTE once
 	// and reuses it across references, and the re-derivations were PK-indexed
 	// joins over a few thousand rows. Kept for the structure, not the speed.
+	walk, err := renderTraceWalk("search_params")
+	if err != nil {
+		return "", nil, fmt.Errorf("SearchSpans: %w: %w", ErrSpansStoreInternal, err)
+	}
 	query, err := queries.Render(name, searchSpansParams{
 		CTEs:        cteSQL,
 		MatchedCTE:  matchedCTE,
 		MatchedExpr: matchedExpr,
 		MatchedJoin: matchedJoin,
+		Walk:        walk,
 	})
 	if err != nil {
 		return "", nil, fmt.Errorf("SearchSpans: %w: %w", ErrSpansStoreInternal, err)
@@ -539,6 +795,55 @@ func DeleteSpansByIDs(ctx context.Context, db *sql.DB, spanIDs []any) error {
 	return nil
 }
 
+// StoredSpan is what LookupSpanIDs reports of a stored span: enough for an
+// intake to tell a resend of it from another span reusing its id.
+type StoredSpan struct {
+	TraceID pcommon.TraceID
+	Kind    ptrace.SpanKind
+}
+
+// LookupSpanIDs returns the stored spans among ids, by id. span_id is the
+// table's key, so a batch carrying one of these ids cannot be appended as it
+// is: OTLP says span ids do not repeat, but Zipkin's shared spans and a
+// reporter's retries both repeat them.
+func LookupSpanIDs(ctx context.Context, db *sql.DB, ids []pcommon.SpanID) (map[pcommon.SpanID]StoredSpan, error) {
+	found := map[pcommon.SpanID]StoredSpan{}
+	if len(ids) == 0 {
+		return found, nil
+	}
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		var padded [16]byte
+		copy(padded[8:], id[:])
+		list[i] = uuid.UUID(padded).String()
+	}
+	rows, err := db.QueryContext(ctx,
+		`select span_id::varchar, trace_id::varchar, kind from spans where span_id in (select id from uuid_list(?))`, list)
+	if err != nil {
+		return nil, fmt.Errorf("LookupSpanIDs: %w: %w", ErrSpansStoreInternal, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var spanID, traceID, kind string
+		if err := rows.Scan(&spanID, &traceID, &kind); err != nil {
+			return nil, fmt.Errorf("LookupSpanIDs: %w: %w", ErrSpansStoreInternal, err)
+		}
+		sid, err := uuid.Parse(spanID)
+		if err != nil {
+			return nil, fmt.Errorf("LookupSpanIDs: %w: %w", ErrSpansStoreInternal, err)
+		}
+		tid, err := uuid.Parse(traceID)
+		if err != nil {
+			return nil, fmt.Errorf("LookupSpanIDs: %w: %w", ErrSpansStoreInternal, err)
+		}
+		found[pcommon.SpanID(sid[8:])] = StoredSpan{TraceID: pcommon.TraceID(tid), Kind: spanKind(kind)}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("LookupSpanIDs: %w: %w", ErrSpansStoreInternal, err)
+	}
+	return found, nil
+}
+
 // DeleteSpansByTraceIDs deletes all spans for multiple traces.
 func DeleteSpansByTraceIDs(ctx context.Context, db *sql.DB, traceIDs []any) error {
 	if len(traceIDs) == 0 {
@@ -558,8 +863,166 @@ func DeleteSpansByTraceIDs(ctx context.Context, db *sql.DB, traceIDs []any) erro
 	return nil
 }
 
-func buildTraceSQL(queryNode *search.QueryNode, startTime, endTime int64) (cteSQL string, whereSQL string, args []any, err error) {
-	return search.BuildSearchSQL(queryNode, startTime, endTime, traceFieldMapper(), "s.start_time >= time_start and s.start_time <= time_end")
+// Tail returns the spans among keys -- span ids from IngestReturning -- that
+// match criteria, oldest first. criteria is a searchTraces query tree applied
+// span by span, so a condition picks out the spans that satisfy it rather than
+// the traces containing one.
+//
+// criteria is checked even when keys is empty, so a caller can validate a
+// query before it has anything to tail.
+func Tail(ctx context.Context, db *sql.DB, keys []string, criteria any) (json.RawMessage, error) {
+	var searchTree *search.QueryNode
+	if criteria != nil {
+		var err error
+		searchTree, err = search.ParseQueryTree(criteria)
+		if err != nil {
+			return nil, fmt.Errorf("Tail: %w: %w", ErrInvalidTraceQuery, err)
+		}
+	}
+	cteSQL, whereClause, args, err := buildTraceSQL(searchTree, 0, math.MaxInt64,
+		search.NamedParam{Name: "tail_keys", Value: keys})
+	if err != nil {
+		return nil, fmt.Errorf("Tail: %w: %w", ErrInvalidTraceQuery, err)
+	}
+	if len(keys) == 0 {
+		return json.RawMessage("[]"), nil
+	}
+
+	query, err := queries.Render(queries.TailSpans, tailSpansParams{CTEs: cteSQL, Where: whereClause})
+	if err != nil {
+		return nil, fmt.Errorf("Tail: %w: %w", ErrSpansStoreInternal, err)
+	}
+
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("Tail: %w: %w", ErrSpansStoreInternal, err)
+	}
+	if raw == nil {
+		return json.RawMessage("[]"), nil
+	}
+	return json.RawMessage(raw), nil
+}
+
+func buildTraceSQL(queryNode *search.QueryNode, startTime, endTime int64, extra ...search.NamedParam) (cteSQL string, whereSQL string, args []any, err error) {
+	return search.BuildSearchSQL(queryNode, startTime, endTime, traceFieldMapper(), traceTimeCondition, extra...)
+}
+
+const traceTimeCondition = "s.start_time >= time_start and s.start_time <= time_end"
+
+// SearchPredicate renders a searchTraces query tree for a statement that
+// reads spans alongside another signal, as the issues package does: the
+// parameters as a CTE called name, and a WHERE clause selecting the spans
+// that start in the window. The clause is written against spanSearchFrom's
+// aliases -- spans s, resources r, scopes sc -- and the caller's FROM must
+// provide them and list the CTE.
+func SearchPredicate(criteria any, startTime, endTime int64, name string) (cteSQL, whereSQL string, args []any, err error) {
+	var searchTree *search.QueryNode
+	if criteria != nil {
+		searchTree, err = search.ParseQueryTree(criteria)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("SearchPredicate: %w: %w", ErrInvalidTraceQuery, err)
+		}
+	}
+	cteSQL, whereSQL, args, err = search.BuildNamedSearchSQL(searchTree, startTime, endTime, traceFieldMapper(), traceTimeCondition, name)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("SearchPredicate: %w: %w", ErrInvalidTraceQuery, err)
+	}
+	return cteSQL, whereSQL, args, nil
+}
+
+// FacetSource renders a searchTraces query tree, and the fields getFacets
+// counts, over the spans it matches: spans rather than traces, so a facet
+// on http.route counts the calls to each route, not the traces that made
+// one. limit is the values each facet lists.
+func FacetSource(criteria any, startTime, endTime int64, fields []search.FieldDefinition, limit int) (search.FacetSource, error) {
+	var searchTree *search.QueryNode
+	if criteria != nil {
+		var err error
+		searchTree, err = search.ParseQueryTree(criteria)
+		if err != nil {
+			return search.FacetSource{}, fmt.Errorf("FacetSource: %w: %w", ErrInvalidTraceQuery, err)
+		}
+	}
+	params := []search.NamedParam{{Name: "facet_limit", Value: limit}}
+	columns := make([]search.FacetColumn, len(fields))
+	for i := range fields {
+		col, err := traceFacetColumn(i, &fields[i], &params)
+		if err != nil {
+			return search.FacetSource{}, fmt.Errorf("FacetSource: %w", err)
+		}
+		columns[i] = col
+	}
+	cteSQL, whereSQL, args, err := buildTraceSQL(searchTree, startTime, endTime, params...)
+	if err != nil {
+		return search.FacetSource{}, fmt.Errorf("FacetSource: %w: %w", ErrInvalidTraceQuery, err)
+	}
+	return search.FacetSource{CTEs: cteSQL, From: spanSearchFrom, Where: whereSQL, Args: args, Columns: columns}, nil
+}
+
+// traceFacetColumn reads one facet field off a span row in spanSearchFrom.
+//
+// A field is its search expression, except the event.* and link.* ones: those
+// are tests over a span's events or links, with no one value per span to
+// count. Event and link attributes have no such problem -- a span carries
+// each value once however many of its events repeat it, which is what the
+// list_distinct is for.
+func traceFacetColumn(i int, field *search.FieldDefinition, params *[]search.NamedParam) (search.FacetColumn, error) {
+	switch field.SearchScope {
+	case "field":
+		expr, err := mapTraceFieldExpression(field)
+		if err != nil {
+			return search.FacetColumn{}, err
+		}
+		if strings.Contains(expr, "{COND}") {
+			return search.FacetColumn{}, fmt.Errorf("trace field %q has no single value per span: %w", field.Name, ErrInvalidTraceQuery)
+		}
+		return search.FacetColumn{Expr: expr}, nil
+	case "attribute":
+		switch field.AttributeScope {
+		case "resource":
+			return search.BindFacetAttribute(i, field, "r.attribute_ids", ingest.ScopeResource, params), nil
+		case "scope":
+			return search.BindFacetAttribute(i, field, "sc.attribute_ids", ingest.ScopeScope, params), nil
+		case "span":
+			return search.BindFacetAttribute(i, field, "s.attribute_ids", ingest.ScopeSpan, params), nil
+		case "event":
+			return search.BindFacetAttribute(i, field,
+				"list_distinct(flatten((select list(e.attribute_ids) from events e where e.span_id = s.span_id)))",
+				ingest.ScopeEvent, params), nil
+		case "link":
+			return search.BindFacetAttribute(i, field,
+				"list_distinct(flatten((select list(l.attribute_ids) from links l where l.span_id = s.span_id)))",
+				ingest.ScopeLink, params), nil
+		default:
+			return search.FacetColumn{}, fmt.Errorf("unknown attribute scope %q: %w", field.AttributeScope, ErrInvalidTraceQuery)
+		}
+	default:
+		return search.FacetColumn{}, fmt.Errorf("cannot facet on search scope %q: %w", field.SearchScope, ErrInvalidTraceQuery)
+	}
+}
+
+// CompareSource renders compareAttributes' two searchTraces query trees over
+// one window, each a SearchPredicate of its own. Spans are compared, not
+// traces: selecting the slow spans should surface what the slow spans
+// carry, not what their traces' other spans do.
+func CompareSource(selection, baseline any, startTime, endTime int64) (search.CompareSource, error) {
+	selCTE, selWhere, selArgs, err := SearchPredicate(selection, startTime, endTime, "selection_params")
+	if err != nil {
+		return search.CompareSource{}, fmt.Errorf("CompareSource: selection: %w", err)
+	}
+	baseCTE, baseWhere, baseArgs, err := SearchPredicate(baseline, startTime, endTime, "baseline_params")
+	if err != nil {
+		return search.CompareSource{}, fmt.Errorf("CompareSource: baseline: %w", err)
+	}
+	return search.CompareSource{
+		CTEs:           selCTE + ",\n\t\t" + baseCTE,
+		SelectionWhere: selWhere,
+		BaselineWhere:  baseWhere,
+		Args:           append(selArgs, baseArgs...),
+		From:           spanCompareFrom,
+		ID:             "s.span_id",
+		AttributeIDs:   "list_concat(r.attribute_ids, sc.attribute_ids, s.attribute_ids)",
+	}, nil
 }
 
 // Two idioms compare a trace id in this file, and they are not
@@ -625,6 +1088,12 @@ const spanSearchFrom = `from search_params, spans s
 		join resources r on r.id = s.resource_id
 		join scopes sc on sc.id = s.scope_id`
 
+// spanCompareFrom is spanSearchFrom without search_params: compareAttributes
+// reads two searches, each from its own parameters CTE.
+const spanCompareFrom = `spans s
+		join resources r on r.id = s.resource_id
+		join scopes sc on sc.id = s.scope_id`
+
 var spanColumns = map[string]struct{}{
 	"trace_id":                 {},
 	"trace_state":              {},
@@ -806,32 +1275,31 @@ func mapTraceAttributeExpressions(field *search.FieldDefinition, query *search.Q
 		}
 	}
 
-	keyParam := fmt.Sprintf("attr_key_%d", len(*params))
-	*params = append(*params, search.NamedParam{Name: keyParam, Value: field.Name})
+	lookup := ingest.BindAttrLookup(field, params)
 
 	switch field.AttributeScope {
 	case "resource":
 		return []string{fmt.Sprintf(
-			"s.resource_id in (select id from resources where attr_value(attribute_ids, %s) {COND})",
-			keyParam)}, nil
+			"s.resource_id in (select id from resources where %s {COND})",
+			lookup.In("attribute_ids"))}, nil
 	case "scope":
 		return []string{fmt.Sprintf(
-			"s.scope_id in (select id from scopes where attr_value(attribute_ids, %s) {COND})",
-			keyParam)}, nil
+			"s.scope_id in (select id from scopes where %s {COND})",
+			lookup.In("attribute_ids"))}, nil
 	case "span":
-		return []string{fmt.Sprintf("attr_value(s.attribute_ids, %s)", keyParam)}, nil
+		return []string{lookup.In("s.attribute_ids")}, nil
 	case "event":
 		return []string{fmt.Sprintf(`exists(
 			select 1 from events e, unnest(e.attribute_ids) as t(aid)
 			join attributes a on a.id = t.aid
-			where e.span_id = s.span_id and a.key = %s and a.value {COND}
-		)`, keyParam)}, nil
+			where e.span_id = s.span_id and a.key = %s and %s {COND}
+		)`, lookup.Key, lookup.Value)}, nil
 	case "link":
 		return []string{fmt.Sprintf(`exists(
 			select 1 from links l, unnest(l.attribute_ids) as t(aid)
 			join attributes a on a.id = t.aid
-			where l.span_id = s.span_id and a.key = %s and a.value {COND}
-		)`, keyParam)}, nil
+			where l.span_id = s.span_id and a.key = %s and %s {COND}
+		)`, lookup.Key, lookup.Value)}, nil
 	default:
 		return nil, fmt.Errorf("unknown attribute scope %s: %w", field.AttributeScope, ErrInvalidTraceQuery)
 	}
@@ -1939,3 +1939,888 @@ func TestSpanAndLinkFlagsRoundTrip(t *testing.T) {
 	require.Len(t, got.Links, 1)
 	assert.Equal(t, linkFlags, got.Links[0].Flags, "link flags must survive too")
 }
+
+func TestDescribeTrace(t *testing.T) {
+	s, ctx, teardown := setupStore(t)
+	defer teardown()
+
+	base := time.Date(2026, 5, 24, 13, 0, 0, 0, time.UTC).UnixNano()
+	traces := ptrace.NewTraces()
+	add := func(traceHex, spanHex, parentHex, service string, start, end int64, failed bool) {
+		rs := traces.ResourceSpans().AppendEmpty()
+		if service != "" {
+			rs.Resource().Attributes().PutStr("service.name", service)
+		}
+		sp := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		sp.SetTraceID(mustDecodeTraceID(traceHex))
+		sp.SetSpanID(mustDecodeSpanID(spanHex))
+		if parentHex != "" {
+			sp.SetParentSpanID(mustDecodeSpanID(parentHex))
+		}
+		sp.SetName("op-" + spanHex[14:])
+		sp.SetStartTimestamp(pcommon.Timestamp(base + start))
+		sp.SetEndTimestamp(pcommon.Timestamp(base + end))
+		if failed {
+			sp.Status().SetCode(ptrace.StatusCodeError)
+		}
+	}
+
+	shaped := "000000000000000000000000000000d1"
+	add(shaped, "0000000000000001", "", "frontend", 0, 1000, false)
+	add(shaped, "0000000000000002", "0000000000000001", "cart", 10, 900, true)
+	add(shaped, "0000000000000003", "0000000000000001", "cart", 20, 100, false)
+	add(shaped, "0000000000000004", "0000000000000002", "db", 30, 800, false)
+	// Its parent never arrived: the waterfall draws it as a second root.
+	add(shaped, "0000000000000005", "00000000000000ff", "", 50, 60, false)
+
+	cyclic := "000000000000000000000000000000d2"
+	add(cyclic, "0000000000000011", "", "frontend", 0, 100, false)
+	add(cyclic, "0000000000000012", "0000000000000013", "frontend", 10, 20, false)
+	add(cyclic, "0000000000000013", "0000000000000012", "frontend", 20, 30, false)
+
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return spans.Ingest(ctx, conn, traces, s.FlushedIDs())
+	}))
+
+	type slow struct {
+		SpanID        string  `json:"spanID"`
+		Name          string  `json:"name"`
+		ServiceName   *string `json:"serviceName"`
+		StatusCode    string  `json:"statusCode"`
+		Depth         *int    `json:"depth"`
+		StartOffsetNs string  `json:"startOffsetNs"`
+		DurationNs    string  `json:"durationNs"`
+	}
+	type description struct {
+		TraceID    string   `json:"traceID"`
+		SpanCount  int      `json:"spanCount"`
+		ErrorCount int      `json:"errorCount"`
+		Services   []string `json:"services"`
+		RootSpan   *struct {
+			SpanID      string `json:"spanID"`
+			Name        string `json:"name"`
+			ServiceName string `json:"serviceName"`
+			DurationNs  string `json:"durationNs"`
+		} `json:"rootSpan"`
+		Depth              int    `json:"depth"`
+		StartTime          string `json:"startTime"`
+		DurationNs         string `json:"durationNs"`
+		MissingParentCount int    `json:"missingParentCount"`
+		UnplacedSpanCount  int    `json:"unplacedSpanCount"`
+		SlowestSpans       []slow `json:"slowestSpans"`
+	}
+	describe := func(traceHex string) description {
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return spans.DescribeTrace(ctx, db, traceHex)
+		})
+		require.NoError(t, err)
+		var d description
+		require.NoError(t, json.Unmarshal(raw, &d))
+		return d
+	}
+
+	t.Run("shape", func(t *testing.T) {
+		d := describe(shaped)
+		assert.Equal(t, shaped, d.TraceID)
+		assert.Equal(t, 5, d.SpanCount)
+		assert.Equal(t, 1, d.ErrorCount)
+		assert.Equal(t, []string{"cart", "db", "frontend"}, d.Services)
+		require.NotNil(t, d.RootSpan)
+		assert.Equal(t, "0000000000000001", d.RootSpan.SpanID)
+		assert.Equal(t, "frontend", d.RootSpan.ServiceName)
+		assert.Equal(t, "1000", d.RootSpan.DurationNs)
+		assert.Equal(t, 3, d.Depth)
+		assert.Equal(t, fmt.Sprint(base), d.StartTime)
+		assert.Equal(t, "1000", d.DurationNs)
+		assert.Equal(t, 1, d.MissingParentCount)
+		assert.Equal(t, 0, d.UnplacedSpanCount)
+
+		var ids []string
+		for _, sp := range d.SlowestSpans {
+			ids = append(ids, sp.SpanID)
+		}
+		assert.Equal(t, []string{
+			"0000000000000001", "0000000000000002", "0000000000000004",
+			"0000000000000003", "0000000000000005",
+		}, ids, "longest bar first")
+		db := d.SlowestSpans[2]
+		assert.Equal(t, "770", db.DurationNs)
+		assert.Equal(t, "30", db.StartOffsetNs)
+		require.NotNil(t, db.Depth)
+		assert.Equal(t, 2, *db.Depth, "the indentation the waterfall gives it")
+		assert.Equal(t, "Error", d.SlowestSpans[1].StatusCode)
+		assert.Nil(t, d.SlowestSpans[4].ServiceName)
+	})
+
+	// Durations must be the waterfall's bar widths, span for span.
+	t.Run("matches searchSpans", func(t *testing.T) {
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return spans.SearchSpans(ctx, db, shaped, nil)
+		})
+		require.NoError(t, err)
+		var td struct {
+			Spans []struct {
+				Depth    int `json:"depth"`
+				SpanData struct {
+					SpanID string `json:"spanID"`
+					Dur    int64  `json:"dur"`
+				} `json:"spanData"`
+			} `json:"spans"`
+		}
+		require.NoError(t, json.Unmarshal(raw, &td))
+		bars := map[string]int64{}
+		depths := map[string]int{}
+		for _, sp := range td.Spans {
+			bars[sp.SpanData.SpanID] = sp.SpanData.Dur
+			depths[sp.SpanData.SpanID] = sp.Depth
+		}
+		for _, sp := range describe(shaped).SlowestSpans {
+			assert.Equal(t, fmt.Sprint(bars[sp.SpanID]), sp.DurationNs, sp.SpanID)
+			assert.Equal(t, depths[sp.SpanID], *sp.Depth, sp.SpanID)
+		}
+	})
+
+	t.Run("cycle is reported, not placed", func(t *testing.T) {
+		d := describe(cyclic)
+		assert.Equal(t, 3, d.SpanCount)
+		assert.Equal(t, 2, d.UnplacedSpanCount)
+		assert.Equal(t, 0, d.MissingParentCount)
+		assert.Equal(t, 1, d.Depth)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return spans.DescribeTrace(ctx, db, "000000000000000000000000000000ee")
+		})
+		assert.ErrorIs(t, err, spans.ErrTraceIDNotFound)
+	})
+}
+
+func TestCriticalPath(t *testing.T) {
+	s, ctx, teardown := setupStore(t)
+	defer teardown()
+
+	base := time.Date(2026, 5, 24, 13, 0, 0, 0, time.UTC).UnixNano()
+	traces := ptrace.NewTraces()
+	add := func(traceHex, spanHex, parentHex, service string, start, end int64) {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+		sp := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		sp.SetTraceID(mustDecodeTraceID(traceHex))
+		sp.SetSpanID(mustDecodeSpanID(spanHex))
+		if parentHex != "" {
+			sp.SetParentSpanID(mustDecodeSpanID(parentHex))
+		}
+		sp.SetName("op-" + spanHex[14:])
+		sp.SetStartTimestamp(pcommon.Timestamp(base + start))
+		sp.SetEndTimestamp(pcommon.Timestamp(base + end))
+	}
+
+	skewed := "000000000000000000000000000000c1"
+	add(skewed, "0000000000000001", "", "frontend", 0, 1000)
+	add(skewed, "0000000000000002", "0000000000000001", "cart", 100, 400)
+	add(skewed, "0000000000000003", "0000000000000001", "cart", 300, 900)
+	// Both start before their parent by the reporting host's clock.
+	add(skewed, "0000000000000004", "0000000000000003", "db", 250, 600)
+	add(skewed, "0000000000000005", "0000000000000001", "auth", -100, 50)
+
+	rootless := "000000000000000000000000000000c2"
+	add(rootless, "0000000000000011", "00000000000000ff", "worker", 0, 100)
+	add(rootless, "0000000000000012", "00000000000000fe", "worker", 300, 500)
+	add(rootless, "0000000000000013", "0000000000000012", "db", 350, 450)
+
+	cyclic := "000000000000000000000000000000c3"
+	add(cyclic, "0000000000000021", "", "frontend", 0, 100)
+	add(cyclic, "0000000000000022", "0000000000000023", "frontend", 10, 20)
+	add(cyclic, "0000000000000023", "0000000000000022", "frontend", 20, 30)
+
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return spans.Ingest(ctx, conn, traces, s.FlushedIDs())
+	}))
+
+	type segment struct {
+		SpanID        *string `json:"spanID"`
+		StartOffsetNs string  `json:"startOffsetNs"`
+		DurationNs    string  `json:"durationNs"`
+	}
+	type selfTime struct {
+		SpanID       string `json:"spanID"`
+		Depth        int    `json:"depth"`
+		SelfTimeNs   string `json:"selfTimeNs"`
+		SegmentCount int    `json:"segmentCount"`
+	}
+	type path struct {
+		TraceID           string     `json:"traceID"`
+		StartTime         *string    `json:"startTime"`
+		DurationNs        string     `json:"durationNs"`
+		Segments          []segment  `json:"segments"`
+		Spans             []selfTime `json:"spans"`
+		SkewedSpanCount   int        `json:"skewedSpanCount"`
+		UnplacedSpanCount int        `json:"unplacedSpanCount"`
+	}
+	criticalPath := func(traceHex string) path {
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return spans.CriticalPath(ctx, db, traceHex)
+		})
+		require.NoError(t, err)
+		var p path
+		require.NoError(t, json.Unmarshal(raw, &p))
+		return p
+	}
+	// flatten renders segments as "span@offset+duration", "-" for a gap.
+	flatten := func(segs []segment) []string {
+		var out []string
+		for _, seg := range segs {
+			id := "-"
+			if seg.SpanID != nil {
+				id = (*seg.SpanID)[14:]
+			}
+			out = append(out, id+"@"+seg.StartOffsetNs+"+"+seg.DurationNs)
+		}
+		return out
+	}
+
+	t.Run("clock skew", func(t *testing.T) {
+		p := criticalPath(skewed)
+		assert.Equal(t, skewed, p.TraceID)
+		// The trace starts where the waterfall starts it: at the skewed
+		// child, 100ns before its root.
+		require.NotNil(t, p.StartTime)
+		assert.Equal(t, fmt.Sprint(base-100), *p.StartTime)
+		assert.Equal(t, "1100", p.DurationNs)
+		// 03 finished last, so the root waited on it; 02 overlaps it and is
+		// off the path. 04 is clamped to 03's start and 05 to the root's.
+		assert.Equal(t, []string{
+			"-@0+100", "05@100+50", "01@150+250", "04@400+300", "03@700+300", "01@1000+100",
+		}, flatten(p.Segments))
+		assert.Equal(t, []selfTime{
+			{SpanID: "0000000000000001", Depth: 0, SelfTimeNs: "350", SegmentCount: 2},
+			{SpanID: "0000000000000004", Depth: 2, SelfTimeNs: "300", SegmentCount: 1},
+			{SpanID: "0000000000000003", Depth: 1, SelfTimeNs: "300", SegmentCount: 1},
+			{SpanID: "0000000000000005", Depth: 1, SelfTimeNs: "50", SegmentCount: 1},
+		}, p.Spans)
+		assert.Equal(t, 2, p.SkewedSpanCount)
+	})
+
+	t.Run("no root", func(t *testing.T) {
+		p := criticalPath(rootless)
+		assert.Equal(t, fmt.Sprint(base), *p.StartTime)
+		assert.Equal(t, "500", p.DurationNs)
+		assert.Equal(t, []string{
+			"11@0+100", "-@100+200", "12@300+50", "13@350+100", "12@450+50",
+		}, flatten(p.Segments), "two orphaned roots, and the gap between them")
+		assert.Zero(t, p.SkewedSpanCount)
+	})
+
+	t.Run("cycle is counted, not walked", func(t *testing.T) {
+		p := criticalPath(cyclic)
+		assert.Equal(t, []string{"21@0+100"}, flatten(p.Segments))
+		assert.Equal(t, 2, p.UnplacedSpanCount)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return spans.CriticalPath(ctx, db, "000000000000000000000000000000ee")
+		})
+		assert.ErrorIs(t, err, spans.ErrTraceIDNotFound)
+	})
+}
+
+func TestDiffTraces(t *testing.T) {
+	s, ctx, teardown := setupStore(t)
+	defer teardown()
+
+	base := time.Date(2026, 5, 24, 13, 0, 0, 0, time.UTC).UnixNano()
+	traces := ptrace.NewTraces()
+	add := func(traceHex, spanHex, parentHex, service, name string, start, end int64) ptrace.Span {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+		sp := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		sp.SetTraceID(mustDecodeTraceID(traceHex))
+		sp.SetSpanID(mustDecodeSpanID(spanHex))
+		if parentHex != "" {
+			sp.SetParentSpanID(mustDecodeSpanID(parentHex))
+		}
+		sp.SetName(name)
+		sp.SetStartTimestamp(pcommon.Timestamp(base + start))
+		sp.SetEndTimestamp(pcommon.Timestamp(base + end))
+		return sp
+	}
+
+	before := "000000000000000000000000000000e1"
+	root := add(before, "0000000000000001", "", "frontend", "GET /checkout", 0, 1000)
+	root.Attributes().PutStr("http.route", "/checkout")
+	root.Attributes().PutInt("retries", 0)
+	add(before, "0000000000000002", "0000000000000001", "cart", "getCart", 100, 300)
+	add(before, "0000000000000003", "0000000000000001", "db", "query", 300, 600)
+	add(before, "0000000000000004", "0000000000000001", "db", "query", 600, 700)
+
+	after := "000000000000000000000000000000e2"
+	root = add(after, "0000000000000011", "", "frontend", "GET /checkout", 0, 1500)
+	root.Attributes().PutStr("http.route", "/checkout")
+	root.Attributes().PutInt("retries", 2)
+	root.Attributes().PutStr("new.attr", "x")
+	add(after, "0000000000000012", "0000000000000011", "cart", "getCart", 100, 300).
+		Status().SetCode(ptrace.StatusCodeError)
+	// Added ahead of the first sibling: it must not shift the others.
+	add(after, "0000000000000015", "0000000000000011", "cache", "get", 50, 100)
+	add(after, "0000000000000013", "0000000000000011", "db", "query", 300, 900)
+	add(after, "0000000000000016", "0000000000000011", "payment", "charge", 1000, 1500)
+
+	// Two orphans whose missing parents differ: still each other's match.
+	orphanA := "000000000000000000000000000000e3"
+	add(orphanA, "0000000000000021", "00000000000000fa", "worker", "job", 0, 100)
+	orphanB := "000000000000000000000000000000e4"
+	add(orphanB, "0000000000000031", "00000000000000fb", "worker", "job", 0, 150)
+
+	// A root, a span whose parent never arrived, and a two-span cycle with a
+	// child hanging off it.
+	broken := "000000000000000000000000000000e5"
+	add(broken, "0000000000000041", "", "frontend", "GET /", 0, 1000)
+	add(broken, "0000000000000042", "00000000000000fc", "worker", "job", 100, 400)
+	add(broken, "0000000000000043", "0000000000000044", "loop", "a", 200, 300)
+	add(broken, "0000000000000044", "0000000000000043", "loop", "b", 300, 500)
+	add(broken, "0000000000000045", "0000000000000044", "loop", "c", 350, 450)
+
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return spans.Ingest(ctx, conn, traces, s.FlushedIDs())
+	}))
+
+	type side struct {
+		SpanID         string `json:"spanID"`
+		DurationNs     string `json:"durationNs"`
+		StatusCode     string `json:"statusCode"`
+		CriticalPathNs string `json:"criticalPathNs"`
+	}
+	type entry struct {
+		Change              string  `json:"change"`
+		ServiceName         *string `json:"serviceName"`
+		Name                string  `json:"name"`
+		Depth               int     `json:"depth"`
+		A                   *side   `json:"a"`
+		B                   *side   `json:"b"`
+		DurationDeltaNs     *string `json:"durationDeltaNs"`
+		CriticalPathDeltaNs string  `json:"criticalPathDeltaNs"`
+		StatusChanged       bool    `json:"statusChanged"`
+		AttributeChanges    []struct {
+			Key string          `json:"key"`
+			A   json.RawMessage `json:"a"`
+			B   json.RawMessage `json:"b"`
+		} `json:"attributeChanges"`
+	}
+	type contributor struct {
+		Name                string `json:"name"`
+		Change              string `json:"change"`
+		CriticalPathDeltaNs string `json:"criticalPathDeltaNs"`
+	}
+	type diff struct {
+		TraceA struct {
+			TraceID           string `json:"traceID"`
+			DurationNs        string `json:"durationNs"`
+			SpanCount         int    `json:"spanCount"`
+			UnplacedSpanCount int    `json:"unplacedSpanCount"`
+		} `json:"traceA"`
+		DurationDeltaNs string `json:"durationDeltaNs"`
+		Summary         struct {
+			MatchedSpanCount     int           `json:"matchedSpanCount"`
+			AddedSpanCount       int           `json:"addedSpanCount"`
+			RemovedSpanCount     int           `json:"removedSpanCount"`
+			StatusChangeCount    int           `json:"statusChangeCount"`
+			AttributeChangeCount int           `json:"attributeChangeCount"`
+			GapDeltaNs           string        `json:"gapDeltaNs"`
+			LatencyContributors  []contributor `json:"latencyContributors"`
+		} `json:"summary"`
+		Spans []entry `json:"spans"`
+	}
+	diffTraces := func(a, b string) diff {
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return spans.DiffTraces(ctx, db, a, b)
+		})
+		require.NoError(t, err)
+		var d diff
+		require.NoError(t, json.Unmarshal(raw, &d))
+		return d
+	}
+
+	t.Run("before and after", func(t *testing.T) {
+		d := diffTraces(before, after)
+		assert.Equal(t, before, d.TraceA.TraceID)
+		assert.Equal(t, "1000", d.TraceA.DurationNs)
+		assert.Equal(t, 4, d.TraceA.SpanCount)
+		assert.Equal(t, "500", d.DurationDeltaNs)
+
+		var shape []string
+		for _, e := range d.Spans {
+			shape = append(shape, fmt.Sprintf("%d %s %s/%s", e.Depth, e.Change, *e.ServiceName, e.Name))
+		}
+		assert.Equal(t, []string{
+			"0 matched frontend/GET /checkout",
+			"1 added cache/get",
+			"1 matched cart/getCart",
+			"1 matched db/query",
+			"1 removed db/query",
+			"1 added payment/charge",
+		}, shape, "merged tree, siblings in start order")
+
+		cart := d.Spans[2]
+		assert.True(t, cart.StatusChanged)
+		assert.Equal(t, "Error", cart.B.StatusCode)
+		require.NotNil(t, cart.DurationDeltaNs)
+		assert.Equal(t, "0", *cart.DurationDeltaNs)
+
+		query := d.Spans[3]
+		assert.Equal(t, "0000000000000003", query.A.SpanID, "the first query aligns with the first")
+		assert.Equal(t, "0000000000000013", query.B.SpanID)
+		assert.Equal(t, "300", *query.DurationDeltaNs)
+		assert.Nil(t, d.Spans[4].B)
+		assert.Nil(t, d.Spans[4].DurationDeltaNs)
+
+		// Attribute values are the wire objects the detail panel shows.
+		rootChanges := d.Spans[0].AttributeChanges
+		require.Len(t, rootChanges, 2)
+		assert.Equal(t, "new.attr", rootChanges[0].Key)
+		assert.Equal(t, "null", string(rootChanges[0].A))
+		assert.JSONEq(t, `{"key": "new.attr", "value": "x", "type": "string"}`, string(rootChanges[0].B))
+		assert.Equal(t, "retries", rootChanges[1].Key)
+		assert.JSONEq(t, `{"key": "retries", "value": "2", "type": "int64"}`, string(rootChanges[1].B))
+
+		assert.Equal(t, 3, d.Summary.MatchedSpanCount)
+		assert.Equal(t, 2, d.Summary.AddedSpanCount)
+		assert.Equal(t, 1, d.Summary.RemovedSpanCount)
+		assert.Equal(t, 1, d.Summary.StatusChangeCount)
+		assert.Equal(t, 1, d.Summary.AttributeChangeCount)
+		assert.Equal(t, "0", d.Summary.GapDeltaNs)
+		// The trace got 500ns slower: the new payment call on the end, the
+		// slower query, and the cache lookup, less the frontend's own time
+		// and the query that went away.
+		assert.Equal(t, []contributor{
+			{Name: "charge", Change: "added", CriticalPathDeltaNs: "500"},
+			{Name: "query", Change: "matched", CriticalPathDeltaNs: "300"},
+			{Name: "GET /checkout", Change: "matched", CriticalPathDeltaNs: "-250"},
+			{Name: "query", Change: "removed", CriticalPathDeltaNs: "-100"},
+			{Name: "get", Change: "added", CriticalPathDeltaNs: "50"},
+		}, d.Summary.LatencyContributors)
+		var total int64
+		for _, e := range d.Spans {
+			var v int64
+			_, err := fmt.Sscan(e.CriticalPathDeltaNs, &v)
+			require.NoError(t, err)
+			total += v
+		}
+		assert.Equal(t, int64(500), total, "critical-path deltas account for the whole slowdown")
+	})
+
+	t.Run("orphans align", func(t *testing.T) {
+		d := diffTraces(orphanA, orphanB)
+		require.Len(t, d.Spans, 1)
+		assert.Equal(t, "matched", d.Spans[0].Change)
+		assert.Equal(t, "50", *d.Spans[0].DurationDeltaNs)
+	})
+
+	// The diff walks each trace with describeTrace's walk, so a span is placed,
+	// and at the depth, the same way in both -- a parent-less span as a root,
+	// a cycle and what hangs off it not at all.
+	t.Run("places spans as describeTrace does", func(t *testing.T) {
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return spans.DescribeTrace(ctx, db, broken)
+		})
+		require.NoError(t, err)
+		var described struct {
+			SpanCount         int `json:"spanCount"`
+			UnplacedSpanCount int `json:"unplacedSpanCount"`
+			SlowestSpans      []struct {
+				SpanID string `json:"spanID"`
+				Depth  *int   `json:"depth"`
+			} `json:"slowestSpans"`
+		}
+		require.NoError(t, json.Unmarshal(raw, &described))
+		require.Len(t, described.SlowestSpans, described.SpanCount, "every span is listed")
+		want := map[string]int{}
+		for _, sp := range described.SlowestSpans {
+			if sp.Depth != nil {
+				want[sp.SpanID] = *sp.Depth
+			}
+		}
+
+		d := diffTraces(broken, broken)
+		assert.Equal(t, described.SpanCount, d.TraceA.SpanCount)
+		assert.Equal(t, described.UnplacedSpanCount, d.TraceA.UnplacedSpanCount)
+		assert.Equal(t, 3, d.TraceA.UnplacedSpanCount, "the cycle and its child")
+		got := map[string]int{}
+		for _, e := range d.Spans {
+			assert.Equal(t, "matched", e.Change, "a trace diffed against itself")
+			got[e.A.SpanID] = e.Depth
+		}
+		assert.Equal(t, want, got)
+		assert.Equal(t, map[string]int{"0000000000000041": 0, "0000000000000042": 0}, got)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		for _, pair := range [][2]string{
+			{"000000000000000000000000000000ee", after},
+			{before, "000000000000000000000000000000ee"},
+		} {
+			_, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+				return spans.DiffTraces(ctx, db, pair[0], pair[1])
+			})
+			assert.ErrorIs(t, err, spans.ErrTraceIDNotFound)
+		}
+	})
+}
+
+func TestGetServiceGraph(t *testing.T) {
+	s, ctx, teardown := setupStore(t)
+	defer teardown()
+
+	base := time.Date(2026, 5, 24, 13, 0, 0, 0, time.UTC).UnixNano()
+	traces := ptrace.NewTraces()
+	add := func(traceHex, spanHex, parentHex, service string, kind ptrace.SpanKind, dur int64, failed bool) ptrace.Span {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+		sp := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		sp.SetTraceID(mustDecodeTraceID(traceHex))
+		sp.SetSpanID(mustDecodeSpanID(spanHex))
+		if parentHex != "" {
+			sp.SetParentSpanID(mustDecodeSpanID(parentHex))
+		}
+		sp.SetName("op")
+		sp.SetKind(kind)
+		sp.SetStartTimestamp(pcommon.Timestamp(base))
+		sp.SetEndTimestamp(pcommon.Timestamp(base + dur))
+		if failed {
+			sp.Status().SetCode(ptrace.StatusCodeError)
+		}
+		return sp
+	}
+
+	web := "000000000000000000000000000000e1"
+	add(web, "0000000000000001", "", "frontend", ptrace.SpanKindServer, 1000, false)
+	add(web, "0000000000000002", "0000000000000001", "cart", ptrace.SpanKindServer, 100, true)
+	add(web, "0000000000000003", "0000000000000001", "cart", ptrace.SpanKindServer, 300, false)
+	// cart's own internals: not a dependency.
+	add(web, "0000000000000004", "0000000000000002", "cart", ptrace.SpanKindInternal, 40, false)
+	add(web, "0000000000000005", "0000000000000004", "db", ptrace.SpanKindServer, 30, false)
+	add(web, "0000000000000006", "0000000000000003", "cart", ptrace.SpanKindProducer, 5, false)
+
+	// The consumer runs in a trace of its own and links back to the producer.
+	worker := "000000000000000000000000000000e2"
+	consumer := add(worker, "0000000000000011", "", "worker", ptrace.SpanKindConsumer, 70, false)
+	link := consumer.Links().AppendEmpty()
+	link.SetTraceID(mustDecodeTraceID(web))
+	link.SetSpanID(mustDecodeSpanID("0000000000000006"))
+
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return spans.Ingest(ctx, conn, traces, s.FlushedIDs())
+	}))
+
+	type edge struct {
+		Source     *string           `json:"source"`
+		Target     *string           `json:"target"`
+		Kind       string            `json:"kind"`
+		CallCount  int               `json:"callCount"`
+		ErrorCount int               `json:"errorCount"`
+		LatencyNs  map[string]string `json:"latencyNs"`
+	}
+	type node struct {
+		Service    *string `json:"service"`
+		SpanCount  int     `json:"spanCount"`
+		ErrorCount int     `json:"errorCount"`
+	}
+	graph := func(criteria any) (nodes map[string]node, edges map[string]edge) {
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return spans.GetServiceGraph(ctx, db, 0, base+1_000_000, criteria)
+		})
+		require.NoError(t, err)
+		var g struct {
+			Nodes []node `json:"nodes"`
+			Edges []edge `json:"edges"`
+		}
+		require.NoError(t, json.Unmarshal(raw, &g))
+		nodes, edges = map[string]node{}, map[string]edge{}
+		for _, n := range g.Nodes {
+			nodes[*n.Service] = n
+		}
+		for _, e := range g.Edges {
+			edges[*e.Source+"->"+*e.Target+"/"+e.Kind] = e
+		}
+		return nodes, edges
+	}
+
+	t.Run("whole window", func(t *testing.T) {
+		nodes, edges := graph(nil)
+		assert.Len(t, nodes, 4)
+		assert.Equal(t, node{Service: ptr("cart"), SpanCount: 4, ErrorCount: 1}, nodes["cart"])
+
+		require.Len(t, edges, 3, "%v", edges)
+		calls := edges["frontend->cart/call"]
+		assert.Equal(t, 2, calls.CallCount)
+		assert.Equal(t, 1, calls.ErrorCount, "the callee's status")
+		assert.Equal(t, map[string]string{"p50": "100", "p90": "300", "p99": "300"}, calls.LatencyNs)
+		assert.Equal(t, 1, edges["cart->db/call"].CallCount)
+		msg := edges["cart->worker/messaging"]
+		assert.Equal(t, 1, msg.CallCount)
+		assert.Equal(t, "70", msg.LatencyNs["p50"])
+		assert.NotContains(t, edges, "cart->cart/call")
+	})
+
+	// Filtered to worker's trace, the graph still reaches back through the
+	// link to the producer's service, which becomes a node with no spans of
+	// its own in the selection.
+	t.Run("filtered", func(t *testing.T) {
+		nodes, edges := graph(map[string]any{
+			"id":   "n1",
+			"type": "condition",
+			"query": map[string]any{
+				"field": map[string]any{
+					"name":           "service.name",
+					"searchScope":    "attribute",
+					"attributeScope": "resource",
+				},
+				"fieldOperator": "=",
+				"value":         "worker",
+			},
+		})
+		require.Len(t, edges, 1)
+		assert.Contains(t, edges, "cart->worker/messaging")
+		assert.Equal(t, 0, nodes["cart"].SpanCount)
+		assert.Equal(t, 1, nodes["worker"].SpanCount)
+	})
+
+	t.Run("empty window", func(t *testing.T) {
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return spans.GetServiceGraph(ctx, db, 0, 1, nil)
+		})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"nodes":[],"edges":[]}`, string(raw))
+	})
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestGetOperationStats(t *testing.T) {
+	s, ctx, teardown := setupStore(t)
+	defer teardown()
+
+	base := time.Date(2026, 5, 24, 13, 0, 0, 0, time.UTC).UnixNano()
+	traces := ptrace.NewTraces()
+	add := func(i int, service, name string, start, dur int64, failed bool) {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+		sp := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		sp.SetTraceID([16]byte{15: byte(i)})
+		sp.SetSpanID([8]byte{7: byte(i)})
+		sp.SetName(name)
+		sp.SetStartTimestamp(pcommon.Timestamp(base + start))
+		sp.SetEndTimestamp(pcommon.Timestamp(base + start + dur))
+		if failed {
+			sp.Status().SetCode(ptrace.StatusCodeError)
+		}
+	}
+	add(1, "checkout", "pay", 0, 100, false)
+	add(2, "checkout", "pay", 1e9, 200, true)
+	add(3, "checkout", "pay", 2e9, 300, false)
+	add(4, "frontend", "GET /", 1e9, 50, false)
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return spans.Ingest(ctx, conn, traces, s.FlushedIDs())
+	}))
+
+	type bucket struct {
+		Start         string            `json:"start"`
+		Count         int               `json:"count"`
+		RatePerSecond float64           `json:"ratePerSecond"`
+		DurationNs    map[string]string `json:"durationNs"`
+	}
+	type group struct {
+		Service       *string           `json:"service"`
+		Name          *string           `json:"name"`
+		Count         int               `json:"count"`
+		ErrorCount    int               `json:"errorCount"`
+		ErrorRatio    float64           `json:"errorRatio"`
+		RatePerSecond *float64          `json:"ratePerSecond"`
+		DurationNs    map[string]string `json:"durationNs"`
+		Buckets       []bucket          `json:"buckets"`
+	}
+	type stats struct {
+		WindowNs      *string `json:"windowNs"`
+		BucketWidthNs *string `json:"bucketWidthNs"`
+		Groups        []group `json:"groups"`
+	}
+	get := func(groupBy []string, targetBuckets int64) stats {
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return spans.GetOperationStats(ctx, db, 0, base+1e12, nil, groupBy, targetBuckets)
+		})
+		require.NoError(t, err)
+		var out stats
+		require.NoError(t, json.Unmarshal(raw, &out))
+		return out
+	}
+
+	t.Run("per operation", func(t *testing.T) {
+		st := get(spans.DefaultOperationGroupBy, 0)
+		require.NotNil(t, st.WindowNs)
+		assert.Equal(t, "2000000000", *st.WindowNs, "clipped to the spans, not the request")
+		assert.Nil(t, st.BucketWidthNs)
+		require.Len(t, st.Groups, 2)
+
+		pay := st.Groups[0]
+		assert.Equal(t, "checkout", *pay.Service)
+		assert.Equal(t, "pay", *pay.Name)
+		assert.Equal(t, 3, pay.Count)
+		assert.Equal(t, 1, pay.ErrorCount)
+		assert.InDelta(t, 1.0/3, pay.ErrorRatio, 1e-9)
+		require.NotNil(t, pay.RatePerSecond)
+		assert.InDelta(t, 1.5, *pay.RatePerSecond, 1e-9)
+		assert.Equal(t, map[string]string{"p50": "200", "p90": "300", "p99": "300"}, pay.DurationNs)
+		assert.Nil(t, pay.Buckets)
+	})
+
+	t.Run("bucketed", func(t *testing.T) {
+		st := get([]string{"name"}, 2)
+		require.NotNil(t, st.BucketWidthNs)
+		assert.Equal(t, "1000000000", *st.BucketWidthNs, "the ladder's 1s rung")
+		pay := st.Groups[0]
+		assert.Nil(t, pay.Service, "service was not asked for")
+		require.Len(t, pay.Buckets, 3)
+		assert.Equal(t, fmt.Sprint(base+1e9), pay.Buckets[1].Start)
+		assert.Equal(t, 1, pay.Buckets[1].Count)
+		assert.InDelta(t, 1.0, pay.Buckets[1].RatePerSecond, 1e-9)
+		assert.Equal(t, "200", pay.Buckets[1].DurationNs["p50"])
+	})
+
+	t.Run("one group", func(t *testing.T) {
+		st := get([]string{}, 0)
+		require.Len(t, st.Groups, 1)
+		assert.Equal(t, 4, st.Groups[0].Count)
+	})
+
+	t.Run("empty window", func(t *testing.T) {
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return spans.GetOperationStats(ctx, db, 0, 1, nil, spans.DefaultOperationGroupBy, 10)
+		})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"windowNs":null,"bucketWidthNs":null,"groups":[]}`, string(raw))
+	})
+
+	t.Run("unknown dimension", func(t *testing.T) {
+		_, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return spans.GetOperationStats(ctx, db, 0, 1, nil, []string{"s.name; drop table spans"}, 0)
+		})
+		assert.ErrorIs(t, err, spans.ErrInvalidTraceQuery)
+	})
+}
+
+func TestListServicesAndOperations(t *testing.T) {
+	s, ctx, teardown := setupStore(t)
+	defer teardown()
+
+	traces := ptrace.NewTraces()
+	add := func(i int, service, name string) {
+		rs := traces.ResourceSpans().AppendEmpty()
+		if service != "" {
+			rs.Resource().Attributes().PutStr("service.name", service)
+		}
+		sp := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		sp.SetTraceID([16]byte{15: byte(i)})
+		sp.SetSpanID([8]byte{7: byte(i)})
+		sp.SetName(name)
+	}
+	add(1, "checkout", "pay")
+	add(2, "checkout", "pay")
+	add(3, "checkout", "auth")
+	add(4, "frontend", "GET /")
+	add(5, "", "orphan")
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return spans.Ingest(ctx, conn, traces, s.FlushedIDs())
+	}))
+
+	list := func(fn func(db *sql.DB) (json.RawMessage, error)) []string {
+		raw, err := readStore(s, fn)
+		require.NoError(t, err)
+		var out []string
+		require.NoError(t, json.Unmarshal(raw, &out))
+		return out
+	}
+
+	assert.Equal(t, []string{"checkout", "frontend"}, list(func(db *sql.DB) (json.RawMessage, error) {
+		return spans.ListServices(ctx, db)
+	}), "sorted, distinct, and nothing for spans without a service")
+	assert.Equal(t, []string{"auth", "pay"}, list(func(db *sql.DB) (json.RawMessage, error) {
+		return spans.ListOperations(ctx, db, "checkout")
+	}))
+	assert.Equal(t, []string{}, list(func(db *sql.DB) (json.RawMessage, error) {
+		return spans.ListOperations(ctx, db, "nobody")
+	}), "an unknown service has no operations rather than an error")
+}
+
+func TestTail(t *testing.T) {
+	s, ctx, teardown := setupStore(t)
+	defer teardown()
+
+	batch := func(first int, services ...string) ptrace.Traces {
+		traces := ptrace.NewTraces()
+		for i, service := range services {
+			rs := traces.ResourceSpans().AppendEmpty()
+			rs.Resource().Attributes().PutStr("service.name", service)
+			sp := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+			sp.SetTraceID([16]byte{15: byte(first + i)})
+			sp.SetSpanID([8]byte{7: byte(first + i)})
+			sp.SetName("op")
+			sp.SetStartTimestamp(pcommon.Timestamp(int64(first+i) * 1000))
+			sp.SetEndTimestamp(pcommon.Timestamp(int64(first+i)*1000 + 10))
+		}
+		return traces
+	}
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		return spans.Ingest(ctx, conn, batch(1, "checkout", "frontend"), s.FlushedIDs())
+	}))
+	var keys []string
+	require.NoError(t, s.WithConn(func(conn driver.Conn) error {
+		var err error
+		keys, err = spans.IngestReturning(ctx, conn, batch(10, "frontend", "checkout", "checkout"), s.FlushedIDs())
+		return err
+	}))
+	require.Len(t, keys, 3)
+
+	type tailed struct {
+		SpanID       string  `json:"spanID"`
+		TraceID      string  `json:"traceID"`
+		ParentSpanID *string `json:"parentSpanID"`
+		ServiceName  string  `json:"serviceName"`
+		DurationNs   string  `json:"durationNs"`
+	}
+	tail := func(query any) []tailed {
+		raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+			return spans.Tail(ctx, db, keys, query)
+		})
+		require.NoError(t, err)
+		var out []tailed
+		require.NoError(t, json.Unmarshal(raw, &out))
+		return out
+	}
+
+	all := tail(nil)
+	require.Len(t, all, 3, "only the second batch")
+	assert.Equal(t, "000000000000000a", all[0].SpanID, "oldest first")
+	assert.Equal(t, "0000000000000000000000000000000a", all[0].TraceID)
+	assert.Nil(t, all[0].ParentSpanID)
+	assert.Equal(t, "10", all[0].DurationNs)
+
+	checkout := tail(map[string]any{
+		"id":   "q",
+		"type": "condition",
+		"query": map[string]any{
+			"field":         map[string]any{"name": "service.name", "type": "string", "searchScope": "attribute", "attributeScope": "resource"},
+			"fieldOperator": "=",
+			"value":         "checkout",
+		},
+	})
+	require.Len(t, checkout, 2)
+	for _, sp := range checkout {
+		assert.Equal(t, "checkout", sp.ServiceName)
+	}
+
+	_, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
+		return spans.Tail(ctx, db, nil, map[string]any{"type": "nonsense"})
+	})
+	assert.ErrorIs(t, err, spans.ErrInvalidTraceQuery)
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/search"
 	"github.com/stretchr/testify/require"
 )
 
@@ -99,10 +100,22 @@ func TestSearchSpansSQLBindsTraceID(t *testing.T) {
 
 // Same contract as the searchSpans golden, for the trace-summary query. Its two
 // shapes are the same two: a search predicate is either present or it is not.
+//
+// A page adds a third shape -- the limit and the cursor predicate are template
+// branches, not bound values -- so it gets a golden of its own.
 func TestSearchTracesSQLGolden(t *testing.T) {
-	for _, tc := range goldenCases {
+	cases := append(goldenCases[:len(goldenCases):len(goldenCases)], struct {
+		name     string
+		traceID  string
+		criteria any
+	}{name: "paged"})
+	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			query, _, err := searchTracesSQL(0, 1<<62, tc.criteria)
+			page := search.Page{}
+			if tc.name == "paged" {
+				page = search.Page{Limit: 50, After: &search.Cursor{Key: 1, ID: "00000000-0000-0000-0000-000000000001"}}
+			}
+			query, _, err := searchTracesSQL(0, 1<<62, tc.criteria, page)
 			require.NoError(t, err)
 
 			path := filepath.Join("testdata", "search_traces_"+tc.name+".sql")
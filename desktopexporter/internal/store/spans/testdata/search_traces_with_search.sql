@@ -1,23 +1,5 @@
-with search_params as (select ? as time_start, ? as time_end)
-		select cast(coalesce(to_json(list(json_object(
-			'traceID',      replace(sub.trace_id::varchar, '-', ''),
-			'hasRootSpan',  sub.has_root_span,
-			'rootSpan',     case when sub.has_root_span then json_object(
-				'serviceName', sub.service_name,
-				'name',        sub.root_name
-			) end,
-			'startTime',    sub.trace_start_time::varchar,
-			'durationNs',   case
-				when sub.trace_start_time is not null
-					and sub.trace_end_time is not null
-					then (sub.trace_end_time - sub.trace_start_time)::varchar
-				else null
-			end,
-			'spanCount',    sub.span_count,
-			'errorCount',   sub.error_count
-		) order by sub.trace_start_time desc
-		)), '[]') as varchar) as summaries
-		from (
+with search_params as (select ? as time_start, ? as time_end),
+		traces as (
 			select distinct on (s.trace_id)
 				s.trace_id,
 				(s.parent_span_id is null) as has_root_span,
@@ -34,4 +16,36 @@ with search_params as (select ? as time_start, ? as time_end)
 			order by
 				s.trace_id,
 				case when s.parent_span_id is null then 0 else 1 end
-		) sub
\ No newline at end of file
+		),
+		-- Keyset page over (trace_start_time, trace_id), newest first. The
+		-- cursor predicate is spelled out rather than a row comparison so it
+		-- reads the same as the order by beneath it.
+		page as (
+			select t.* from traces t
+			order by t.trace_start_time desc, t.trace_id desc
+		),
+		numbered as (
+			select *, row_number() over (order by trace_start_time desc, trace_id desc) as rn
+			from page
+		)
+		select cast(coalesce(to_json(list(json_object(
+			'traceID',      replace(sub.trace_id::varchar, '-', ''),
+			'hasRootSpan',  sub.has_root_span,
+			'rootSpan',     case when sub.has_root_span then json_object(
+				'serviceName', sub.service_name,
+				'name',        sub.root_name
+			) end,
+			'startTime',    sub.trace_start_time::varchar,
+			'durationNs',   case
+				when sub.trace_start_time is not null
+					and sub.trace_end_time is not null
+					then (sub.trace_end_time - sub.trace_start_time)::varchar
+				else null
+			end,
+			'spanCount',    sub.span_count,
+			'errorCount',   sub.error_count
+		) order by sub.rn
+		)), '[]') as varchar) as summaries,
+		null::bigint as next_key,
+		null::varchar as next_id
+		from numbered sub
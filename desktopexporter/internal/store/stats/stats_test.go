@@ -75,6 +75,7 @@ type statsJSON struct {
 type storageStatsJSON struct {
 	SizeBytes    float64 `json:"sizeBytes"`
 	MaxSizeBytes float64 `json:"maxSizeBytes"`
+	ReadOnly     bool    `json:"readOnly"`
 }
 
 type traceStatsJSON struct {
@@ -102,7 +103,7 @@ func getStats(t *testing.T, s *store.Store, ctx context.Context) statsJSON {
 	sizeBytes, err := s.SizeBytes(ctx)
 	require.NoError(t, err)
 	raw, err := readStore(s, func(db *sql.DB) (json.RawMessage, error) {
-		return stats.GetStats(ctx, db, sizeBytes, s.RetentionCap())
+		return stats.GetStats(ctx, db, sizeBytes, s.RetentionCap(), s.ReadOnly())
 	})
 	require.NoError(t, err)
 	var result statsJSON
@@ -116,6 +117,7 @@ func TestGetStats_Empty(t *testing.T) {
 
 	result := getStats(t, s, ctx)
 
+	assert.False(t, result.Storage.ReadOnly)
 	assert.Equal(t, float64(0), result.Traces.TraceCount)
 	assert.Equal(t, float64(0), result.Traces.SpanCount)
 	assert.Equal(t, float64(0), result.Traces.ServiceCount)
@@ -6,12 +6,16 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/bundle"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/ingest"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/live"
 	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/queries"
 	"github.com/duckdb/duckdb-go/v2"
 	"go.uber.org/zap"
@@ -34,6 +38,15 @@ type Store struct {
 	conn   driver.Conn
 	dbPath string // empty means in-memory mode
 
+	// exportDir is where exports of a store imported from a bundle are
+	// written, the one directory besides the bundle its database may touch.
+	// Made by NewStore, removed by Close; empty for any other store.
+	exportDir string
+
+	// readOnly is set by the ReadOnly option and never changes: WithConn and
+	// WithDBWrite refuse with ErrStoreReadOnly.
+	readOnly bool
+
 	// mu orders access to both handles. The write lock is shared by appender
 	// writes (WithConn), pool mutations (WithDBWrite), and Close, so those are
 	// mutually exclusive even though they run on different connections. Reads
@@ -77,6 +90,11 @@ type Store struct {
 	// dictionary rows.
 	flushed *ingest.FlushedIDs
 
+	// live is where ingest announces committed rows to tail subscribers. Per
+	// store for the same reason as flushed: a key means something only in the
+	// database it was written to.
+	live *live.Hub
+
 	// retentionCapBytes is the store size cap enforced by EnforceRetention
 	// and reported by getStats. 0 means retention is disabled. Set once via
 	// SetRetentionCap before the store is shared; read without locking.
@@ -107,39 +125,168 @@ func (s *Store) RetentionCap() int64 {
 // NewStore creates a new store for the given database path.
 // An empty dbPath will create a temporary in-memory database.
 //
-// logger may be nil, in which case nothing is logged.
-func NewStore(ctx context.Context, dbPath string, logger *zap.Logger) (*Store, error) {
+// logger may be nil, in which case nothing is logged. With ReadOnly, dbPath
+// may also name a bundle; see ReadOnly for what that accepts.
+func NewStore(ctx context.Context, dbPath string, logger *zap.Logger, opts ...Option) (*Store, error) {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 
 	if dbPath != "" {
 		dbPath = filepath.Clean(dbPath)
 	}
-	connector, err := duckdb.NewConnector(dbPath, nil)
+	src := source{path: dbPath, readOnly: o.readOnly}
+	dsn := dbPath
+	var bundleDir string
+	if o.readOnly {
+		var cleanup func()
+		var err error
+		dsn, bundleDir, cleanup, err = resolveReadOnly(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		src.bundle = bundleDir != ""
+	}
+
+	// A file opened read-only reads with this build's macros, created in
+	// each connection as temp macros; see queries.TempMacros. Not until its
+	// version has been checked, though: a macro binds against the tables
+	// when created, and against an incompatible file that would fail with a
+	// missing column before the version check could say why.
+	var macrosReady atomic.Bool
+	var connInit func(driver.ExecerContext) error
+	if src.readOnlyFile() {
+		connInit = func(execer driver.ExecerContext) error {
+			if !macrosReady.Load() {
+				return nil
+			}
+			return createTempMacros(context.Background(), execer)
+		}
+	}
+	connector, err := duckdb.NewConnector(dsn, connInit)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrStoreInitFailed, err)
 	}
 
 	conn, err := connector.Connect(ctx)
 	if err != nil {
+		connector.Close()
 		return nil, fmt.Errorf("%w: %w", ErrStoreInitFailed, err)
 	}
 
 	db := sql.OpenDB(connector)
 
-	// Idle connections are kept rather than dropped: we hold no connection-local
-	// temporary state, so reusing them is safe, and steady-state UI polling would
+	// A refusal from here on has to let go of the file. DuckDB keeps one
+	// instance per path per process, so a database left open by a failed
+	// NewStore is still held when the next one in this process tries -- a
+	// read-only look after a failed migration, say -- and that open fails
+	// on a configuration mismatch that has nothing to do with the file.
+	var exportDir string
+	fail := func(err error) (*Store, error) {
+		conn.Close()
+		db.Close() // closes the connector too
+		if exportDir != "" {
+			os.RemoveAll(exportDir)
+		}
+		return nil, err
+	}
+
+	// Idle connections are kept rather than dropped: the only connection-local
+	// state is a read-only file's temp macros, which every connection gets
+	// alike, so reusing them is safe, and steady-state UI polling would
 	// otherwise reopen DuckDB connections on every request.
 	db.SetMaxOpenConns(maxPoolConns)
 	db.SetMaxIdleConns(maxPoolConns)
 	db.SetConnMaxIdleTime(5 * time.Minute)
 
+	// A bundle arrives as the SQL and Parquet to rebuild a database, so it is
+	// rebuilt into the fresh in-memory one before anything else runs. From
+	// there it goes through the same steps as any store: its schema_meta
+	// came with it, so the version check judges the bundle, and the
+	// idempotent DDL below finds everything already in place.
+	//
+	// The import leaves the database unable to reach any file outside the
+	// bundle and exportDir, for good; see bundle.Import. exportDir is made
+	// here so exporting a slice of a capture still has somewhere to go.
+	if src.bundle {
+		if exportDir, err = os.MkdirTemp("", "otel-capture-"); err != nil {
+			return fail(fmt.Errorf("%w: %w", ErrStoreInitFailed, err))
+		}
+		if err := bundle.Import(ctx, db, bundleDir, exportDir); err != nil {
+			return fail(fmt.Errorf("%w while importing %s: %w", ErrStoreInitFailed, src, err))
+		}
+	}
+
+	var schemaCompat SchemaCompatibility
+	var flushed *ingest.FlushedIDs
+	if src.readOnlyFile() {
+		// The file carries its own tables and indexes, and could not take
+		// new ones. The flush cache stays nil: it serves ingest, which a
+		// read-only store refuses.
+		schemaCompat, err = checkSchemaVersion(ctx, db, src, logger)
+		if err == nil {
+			err = readyTempMacros(ctx, db, &macrosReady)
+		}
+	} else {
+		schemaCompat, flushed, err = createSchema(ctx, db, src, logger)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	// A bundle lives in memory, and the path it came from is not what
+	// retention should measure.
+	if src.bundle {
+		dbPath = ""
+	}
+	return &Store{
+		db:           db,
+		conn:         conn,
+		dbPath:       dbPath,
+		exportDir:    exportDir,
+		readOnly:     o.readOnly,
+		logger:       logger,
+		schemaCompat: schemaCompat,
+		flushed:      flushed,
+		live:         live.NewHub(),
+	}, nil
+}
+
+// createSchema brings a writable database up to this build's schema, checking
+// its version first, and loads the dictionary flush cache from what it holds.
+// readyTempMacros lets new connections create their temp macros, then drops
+// the idle one the version check ran on, which predates that, and opens one
+// so a macro that fails to create fails the open rather than the first read.
+func readyTempMacros(ctx context.Context, db *sql.DB, ready *atomic.Bool) error {
+	ready.Store(true)
+	db.SetMaxIdleConns(0)
+	db.SetMaxIdleConns(maxPoolConns)
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%w while creating temp macros: %w", ErrStoreInitFailed, err)
+	}
+	return nil
+}
+
+func createTempMacros(ctx context.Context, execer driver.ExecerContext) error {
+	for _, stmt := range queries.TempMacros() {
+		if _, err := execer.ExecContext(ctx, stmt.SQL, nil); err != nil {
+			return fmt.Errorf("creating macro %s: %w", stmt.Name, err)
+		}
+	}
+	return nil
+}
+
+func createSchema(ctx context.Context, db *sql.DB, src source, logger *zap.Logger) (SchemaCompatibility, *ingest.FlushedIDs, error) {
 	// 1) Create types - ignore "already exists" errors
 	for _, stmt := range queries.Types() {
-		if _, err = db.Exec(stmt.SQL); err != nil {
+		if _, err := db.Exec(stmt.SQL); err != nil {
 			if !strings.Contains(err.Error(), "already exists") {
-				return nil, fmt.Errorf("%w while creating type %s: %w", ErrStoreInitFailed, stmt.Name, err)
+				return SchemaOK, nil, fmt.Errorf("%w while creating type %s: %w", ErrStoreInitFailed, stmt.Name, err)
 			}
 		}
 	}
@@ -152,29 +299,29 @@ func NewStore(ctx context.Context, dbPath string, logger *zap.Logger) (*Store, e
 	// creation dies against a column that does not exist, and the user gets
 	// "failed to create index 4" instead of "this database was written by a
 	// different version".
-	schemaCompat, err := checkSchemaVersion(db, dbPath, logger)
+	schemaCompat, err := checkSchemaVersion(ctx, db, src, logger)
 	if err != nil {
-		return nil, err
+		return schemaCompat, nil, err
 	}
 
 	// 3) Create the tables for our signals
 	for _, stmt := range queries.Tables() {
-		if _, err = db.Exec(stmt.SQL); err != nil {
-			return nil, fmt.Errorf("%w while creating table %s: %w", ErrStoreInitFailed, stmt.Name, err)
+		if _, err := db.Exec(stmt.SQL); err != nil {
+			return schemaCompat, nil, fmt.Errorf("%w while creating table %s: %w", ErrStoreInitFailed, stmt.Name, err)
 		}
 	}
 
 	// 4) Create indexes - queries use IF NOT EXISTS so reopening is safe
 	for _, stmt := range queries.Indexes() {
-		if _, err = db.Exec(stmt.SQL); err != nil {
-			return nil, fmt.Errorf("%w while creating index %s: %w", ErrStoreInitFailed, stmt.Name, err)
+		if _, err := db.Exec(stmt.SQL); err != nil {
+			return schemaCompat, nil, fmt.Errorf("%w while creating index %s: %w", ErrStoreInitFailed, stmt.Name, err)
 		}
 	}
 
 	// 5) Create macros - queries use CREATE OR REPLACE so reopening is safe
 	for _, stmt := range queries.Macros() {
-		if _, err = db.Exec(stmt.SQL); err != nil {
-			return nil, fmt.Errorf("%w while creating macro %s: %w", ErrStoreInitFailed, stmt.Name, err)
+		if _, err := db.Exec(stmt.SQL); err != nil {
+			return schemaCompat, nil, fmt.Errorf("%w while creating macro %s: %w", ErrStoreInitFailed, stmt.Name, err)
 		}
 	}
 
@@ -187,17 +334,9 @@ func NewStore(ctx context.Context, dbPath string, logger *zap.Logger) (*Store, e
 	// on-disk dictionary itself is: by retention.
 	flushed, err := ingest.LoadFlushedIDs(ctx, db)
 	if err != nil {
-		return nil, fmt.Errorf("%w while warming the dictionary flush cache: %w", ErrStoreInitFailed, err)
+		return schemaCompat, nil, fmt.Errorf("%w while warming the dictionary flush cache: %w", ErrStoreInitFailed, err)
 	}
-
-	return &Store{
-		db:           db,
-		conn:         conn,
-		dbPath:       dbPath,
-		logger:       logger,
-		schemaCompat: schemaCompat,
-		flushed:      flushed,
-	}, nil
+	return schemaCompat, flushed, nil
 }
 
 // FlushedIDs is the store's record of which dictionary rows are already
@@ -211,6 +350,12 @@ func (s *Store) FlushedIDs() *ingest.FlushedIDs {
 	return s.flushed
 }
 
+// Live is the store's tail hub. Whatever writes spans or logs publishes the
+// keys it committed here; the /stream endpoint subscribes.
+func (s *Store) Live() *live.Hub {
+	return s.live
+}
+
 // Close closes the store and the underlying database connection.
 // It acquires the mutex to avoid racing with WithConn.
 // We explicitly set the connection to nil so that WithConn detects the
@@ -231,7 +376,13 @@ func (s *Store) Close() error {
 		s.db = nil
 	}
 
-	return errors.Join(connErr, dbErr)
+	var dirErr error
+	if s.exportDir != "" {
+		dirErr = os.RemoveAll(s.exportDir)
+		s.exportDir = ""
+	}
+
+	return errors.Join(connErr, dbErr, dirErr)
 }
 
 // WithConn runs fn against the store's dedicated appender connection. Ingest
@@ -254,6 +405,9 @@ func (s *Store) WithConn(fn func(conn driver.Conn) error) error {
 	if s.db == nil || s.conn == nil {
 		return ErrStoreConnectionClosed
 	}
+	if s.readOnly {
+		return ErrStoreReadOnly
+	}
 
 	return fn(s.conn)
 }
@@ -283,6 +437,9 @@ func (s *Store) WithDBWrite(fn func(db *sql.DB) error) error {
 	if s.db == nil {
 		return ErrStoreConnectionClosed
 	}
+	if s.readOnly {
+		return ErrStoreReadOnly
+	}
 
 	return fn(s.db)
 }
@@ -339,6 +339,32 @@ func TestStoreExponentialHistogramConstraint(t *testing.T) {
 	assert.NoError(t, err, "ExponentialHistogram ingest should satisfy chk_exponential_histogram_fields constraint")
 }
 
+// TestStoreSummaryConstraint verifies that a Summary datapoint written through
+// the real ingest path satisfies chk_summary_fields and the shared
+// chk_distribution_fields -- the appender enforces CHECKs as an insert does.
+func TestStoreSummaryConstraint(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewStore(ctx, "", zap.NewNop())
+	require.NoError(t, err)
+	defer s.Close()
+
+	m := pmetric.NewMetrics()
+	metric := m.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("summary_constraint_test")
+	dp := metric.SetEmptySummary().DataPoints().AppendEmpty()
+	dp.SetTimestamp(pcommon.Timestamp(time.Now().UnixNano()))
+	dp.SetCount(10)
+	dp.SetSum(100.0)
+	q := dp.QuantileValues().AppendEmpty()
+	q.SetQuantile(0.5)
+	q.SetValue(9.5)
+
+	err = s.WithConn(func(conn driver.Conn) error {
+		return metrics.Ingest(ctx, conn, m, s.FlushedIDs())
+	})
+	assert.NoError(t, err, "Summary ingest should satisfy chk_summary_fields constraint")
+}
+
 func TestStoreLifecycleErrors(t *testing.T) {
 	ctx := context.Background()
 	s, err := NewStore(ctx, "", zap.NewNop())
@@ -1,8 +1,12 @@
 package util
 
 import (
-	"encoding/hex"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -56,6 +60,12 @@ func buildPlaceholders(count int, mark string) string {
 
 // ValueToStringAndType serializes a pcommon.Value to a string and returns a type tag.
 // Used for both the attributes table (Key/Value/Type) and the logs table (Body/BodyType).
+//
+// Every encoding is reversible given the tag, because the pair is hashed into
+// the dictionary id: two values that render alike under one tag are one row.
+// Maps, bytes and mixed arrays used to fall back to fmt's %v, typed "string",
+// which merged byte values with hex-looking strings and turned a nested
+// http.request.header into Go map syntax nothing could search.
 func ValueToStringAndType(v pcommon.Value) (valueStr string, typeStr string) {
 	switch v.Type() {
 	case pcommon.ValueTypeStr:
@@ -67,8 +77,11 @@ func ValueToStringAndType(v pcommon.Value) (valueStr string, typeStr string) {
 	case pcommon.ValueTypeBool:
 		return strconv.FormatBool(v.Bool()), "bool"
 	case pcommon.ValueTypeBytes:
-		bytes := v.Bytes()
-		return hex.EncodeToString(bytes.AsRaw()), "string"
+		return base64.StdEncoding.EncodeToString(v.Bytes().AsRaw()), "bytes"
+	case pcommon.ValueTypeMap:
+		var b strings.Builder
+		writeJSONValue(&b, v)
+		return b.String(), "map"
 	case pcommon.ValueTypeSlice:
 		return valueSliceToStringAndType(v)
 	default:
@@ -77,6 +90,12 @@ func ValueToStringAndType(v pcommon.Value) (valueStr string, typeStr string) {
 }
 
 // valueSliceToStringAndType serializes a pcommon.Value slice to JSON array string and type.
+//
+// A slice whose elements are all one scalar type keeps the typed-array form
+// the search tree casts to a DuckDB list. Anything else -- mixed scalars, or
+// any map, bytes or nested slice element -- is "any[]": a JSON array of
+// {"type", "value"} objects, so an int 1 and a string "1" in the same list
+// stay distinguishable.
 func valueSliceToStringAndType(v pcommon.Value) (valueStr string, typeStr string) {
 	slice := v.Slice()
 	if slice.Len() == 0 {
@@ -94,7 +113,12 @@ func valueSliceToStringAndType(v pcommon.Value) (valueStr string, typeStr string
 	case pcommon.ValueTypeBool:
 		typeStr = "boolean[]"
 	default:
-		typeStr = "string[]"
+		return typedSliceJSON(slice), "any[]"
+	}
+	for i := 1; i < slice.Len(); i++ {
+		if slice.At(i).Type() != firstItem.Type() {
+			return typedSliceJSON(slice), "any[]"
+		}
 	}
 
 	var parts []string
@@ -109,13 +133,371 @@ func valueSliceToStringAndType(v pcommon.Value) (valueStr string, typeStr string
 			parts = append(parts, strconv.FormatFloat(item.Double(), 'f', -1, 64))
 		case pcommon.ValueTypeBool:
 			parts = append(parts, strconv.FormatBool(item.Bool()))
-		default:
-			parts = append(parts, fmt.Sprintf("%v", item.AsRaw()))
 		}
 	}
 	return "[" + strings.Join(parts, ",") + "]", typeStr
 }
 
+// typedSliceJSON renders each element as {"type": <tag>, "value": <json>},
+// using the same tags ValueToStringAndType returns at the top level, plus
+// "empty" for an unset element -- which a list can hold and the enum has no
+// token for.
+func typedSliceJSON(slice pcommon.Slice) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i := 0; i < slice.Len(); i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		item := slice.At(i)
+		b.WriteString(`{"type":`)
+		writeJSONString(&b, valueTypeTag(item))
+		b.WriteString(`,"value":`)
+		writeJSONValue(&b, item)
+		b.WriteByte('}')
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// valueTypeTag is the tag ValueToStringAndType would give v, without paying
+// to render the value.
+func valueTypeTag(v pcommon.Value) string {
+	switch v.Type() {
+	case pcommon.ValueTypeInt:
+		return "int64"
+	case pcommon.ValueTypeDouble:
+		return "float64"
+	case pcommon.ValueTypeBool:
+		return "bool"
+	case pcommon.ValueTypeBytes:
+		return "bytes"
+	case pcommon.ValueTypeMap:
+		return "map"
+	case pcommon.ValueTypeSlice:
+		_, typ := valueSliceToStringAndType(v)
+		return typ
+	case pcommon.ValueTypeEmpty:
+		return "empty"
+	default:
+		return "string"
+	}
+}
+
+// writeJSONValue writes v as plain JSON, following the spec's mapping of
+// AnyValue to JSON for non-OTLP formats: bytes as base64 strings, NaN and the
+// infinities as the strings "NaN", "Infinity" and "-Infinity", empty as null.
+//
+// Map keys are sorted. pcommon.Map keeps insertion order, and the rendered
+// text is hashed into the dictionary id, so two producers sending the same
+// headers in a different order would otherwise get two rows for one value.
+//
+// Hand-rolled rather than json.Marshal(v.AsRaw()): Marshal refuses NaN
+// outright, and escapes <, > and & into \u003c forms that a user searching
+// the stored text would never type.
+func writeJSONValue(b *strings.Builder, v pcommon.Value) {
+	switch v.Type() {
+	case pcommon.ValueTypeStr:
+		writeJSONString(b, v.Str())
+	case pcommon.ValueTypeInt:
+		b.WriteString(strconv.FormatInt(v.Int(), 10))
+	case pcommon.ValueTypeDouble:
+		switch d := v.Double(); {
+		case math.IsNaN(d):
+			b.WriteString(`"NaN"`)
+		case math.IsInf(d, 1):
+			b.WriteString(`"Infinity"`)
+		case math.IsInf(d, -1):
+			b.WriteString(`"-Infinity"`)
+		default:
+			b.WriteString(strconv.FormatFloat(d, 'f', -1, 64))
+		}
+	case pcommon.ValueTypeBool:
+		b.WriteString(strconv.FormatBool(v.Bool()))
+	case pcommon.ValueTypeBytes:
+		writeJSONString(b, base64.StdEncoding.EncodeToString(v.Bytes().AsRaw()))
+	case pcommon.ValueTypeMap:
+		m := v.Map()
+		keys := make([]string, 0, m.Len())
+		for k := range m.All() {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSONString(b, k)
+			b.WriteByte(':')
+			item, _ := m.Get(k)
+			writeJSONValue(b, item)
+		}
+		b.WriteByte('}')
+	case pcommon.ValueTypeSlice:
+		s := v.Slice()
+		b.WriteByte('[')
+		for i := 0; i < s.Len(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSONValue(b, s.At(i))
+		}
+		b.WriteByte(']')
+	default:
+		b.WriteString("null")
+	}
+}
+
+// writeJSONString writes s as a JSON string literal, without HTML escaping.
+func writeJSONString(b *strings.Builder, s string) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	// Encoding a string cannot fail.
+	_ = enc.Encode(s)
+	b.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+// StringAndTypeToValue is ValueToStringAndType run backwards: it writes the
+// value a stored (value, type) pair was rendered from into dest. Export uses
+// it to turn dictionary rows back into pdata.
+//
+// It is exact for everything the forward direction renders exactly, and where
+// the rendering dropped something it cannot be recovered here:
+//
+//   - Inside a map or an any[] element's value, the JSON mapping keeps no
+//     types. A whole number reads back as an int, anything else as a double,
+//     and bytes, NaN and the infinities as the strings they were written as.
+//   - An unset value had no tag of its own and was stored as the string
+//     "<nil>", so that string reads back as unset.
+//
+// A value that does not parse under its tag at all is written as a string
+// rather than dropped, so an export never silently loses an attribute.
+func StringAndTypeToValue(value, typ string, dest pcommon.Value) {
+	switch typ {
+	case "string":
+		if value != "<nil>" {
+			dest.SetStr(value)
+		}
+		return
+	case "int64":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			dest.SetInt(n)
+			return
+		}
+	case "float64":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			dest.SetDouble(f)
+			return
+		}
+	case "bool":
+		if b, err := strconv.ParseBool(value); err == nil {
+			dest.SetBool(b)
+			return
+		}
+	case "bytes":
+		if b, err := base64.StdEncoding.DecodeString(value); err == nil {
+			dest.SetEmptyBytes().FromRaw(b)
+			return
+		}
+	case "map":
+		if decoded, ok := decodeJSON(value); ok {
+			if _, isMap := decoded.(map[string]any); isMap {
+				setJSONValue(dest, decoded)
+				return
+			}
+		}
+	case "string[]":
+		if items, ok := parseStringList(value); ok {
+			s := dest.SetEmptySlice()
+			for _, item := range items {
+				s.AppendEmpty().SetStr(item)
+			}
+			return
+		}
+	case "int64[]", "float64[]", "boolean[]":
+		if parseScalarList(value, typ, dest) {
+			return
+		}
+	case "any[]":
+		if parseTypedList(value, dest) {
+			return
+		}
+	}
+	dest.SetStr(value)
+}
+
+// parseStringList reads a string[] rendering. Not through encoding/json: the
+// elements were quoted by escaping only the quote character, so a backslash in
+// one is literal, and a JSON decoder would read C:\temp as a tab. Undoing
+// exactly that one escape is exact -- "," can only occur between elements,
+// since every quote inside one carries a backslash.
+func parseStringList(value string) ([]string, bool) {
+	if value == "[]" {
+		return []string{}, true
+	}
+	body, ok := strings.CutPrefix(value, `["`)
+	if !ok {
+		return nil, false
+	}
+	if body, ok = strings.CutSuffix(body, `"]`); !ok {
+		return nil, false
+	}
+	var items []string
+	for _, item := range strings.Split(body, `","`) {
+		items = append(items, strings.ReplaceAll(item, `\"`, `"`))
+	}
+	return items, true
+}
+
+// parseScalarList reads an int64[], float64[] or boolean[] rendering, which
+// is strconv output between brackets -- not JSON, since a float64[] can hold
+// NaN.
+func parseScalarList(value, typ string, dest pcommon.Value) bool {
+	body, ok := strings.CutPrefix(value, "[")
+	if !ok {
+		return false
+	}
+	if body, ok = strings.CutSuffix(body, "]"); !ok {
+		return false
+	}
+	tmp := pcommon.NewValueEmpty()
+	s := tmp.SetEmptySlice()
+	if body != "" {
+		for _, part := range strings.Split(body, ",") {
+			var err error
+			switch typ {
+			case "int64[]":
+				var n int64
+				n, err = strconv.ParseInt(part, 10, 64)
+				s.AppendEmpty().SetInt(n)
+			case "float64[]":
+				var f float64
+				f, err = strconv.ParseFloat(part, 64)
+				s.AppendEmpty().SetDouble(f)
+			default:
+				var b bool
+				b, err = strconv.ParseBool(part)
+				s.AppendEmpty().SetBool(b)
+			}
+			if err != nil {
+				return false
+			}
+		}
+	}
+	tmp.CopyTo(dest)
+	return true
+}
+
+// parseTypedList reads the any[] rendering typedSliceJSON writes, using each
+// element's tag to undo what plain JSON could not say on its own.
+func parseTypedList(value string, dest pcommon.Value) bool {
+	var items []struct {
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(value), &items); err != nil {
+		return false
+	}
+	tmp := pcommon.NewValueEmpty()
+	s := tmp.SetEmptySlice()
+	for _, item := range items {
+		decoded, ok := decodeJSON(string(item.Value))
+		if !ok {
+			return false
+		}
+		elem := s.AppendEmpty()
+		switch item.Type {
+		case "empty":
+		case "bytes":
+			text, _ := decoded.(string)
+			b, err := base64.StdEncoding.DecodeString(text)
+			if err != nil {
+				return false
+			}
+			elem.SetEmptyBytes().FromRaw(b)
+		case "float64":
+			elem.SetDouble(jsonDouble(decoded))
+		case "float64[]":
+			list, _ := decoded.([]any)
+			inner := elem.SetEmptySlice()
+			for _, v := range list {
+				inner.AppendEmpty().SetDouble(jsonDouble(v))
+			}
+		default:
+			setJSONValue(elem, decoded)
+		}
+	}
+	tmp.CopyTo(dest)
+	return true
+}
+
+// decodeJSON decodes text keeping numbers as json.Number, so an int64 is read
+// exactly rather than through a float64.
+func decodeJSON(text string) (any, bool) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var out any
+	if err := dec.Decode(&out); err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// setJSONValue writes decoded JSON into dest. Map keys go in sorted, which is
+// the order writeJSONValue stored them in.
+func setJSONValue(dest pcommon.Value, v any) {
+	switch t := v.(type) {
+	case nil:
+	case string:
+		dest.SetStr(t)
+	case bool:
+		dest.SetBool(t)
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			dest.SetInt(n)
+		} else {
+			dest.SetDouble(jsonDouble(t))
+		}
+	case []any:
+		s := dest.SetEmptySlice()
+		for _, item := range t {
+			setJSONValue(s.AppendEmpty(), item)
+		}
+	case map[string]any:
+		m := dest.SetEmptyMap()
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			setJSONValue(m.PutEmpty(k), t[k])
+		}
+	}
+}
+
+// jsonDouble reads a double the way writeJSONValue wrote it: a number, or one
+// of the three strings standing in for the values JSON has no literal for.
+func jsonDouble(v any) float64 {
+	switch t := v.(type) {
+	case json.Number:
+		f, _ := t.Float64()
+		return f
+	case string:
+		switch t {
+		case "NaN":
+			return math.NaN()
+		case "Infinity":
+			return math.Inf(1)
+		case "-Infinity":
+			return math.Inf(-1)
+		}
+	}
+	return 0
+}
+
 // ToStringList converts the []any of ids the JSON-RPC layer hands us into the
 // []string the driver binds as varchar[].
 //
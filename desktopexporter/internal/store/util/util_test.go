@@ -1,9 +1,11 @@
 package util
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/pcommon"
 )
 
 func TestCamelToSnake(t *testing.T) {
@@ -27,3 +29,149 @@ func TestCamelToSnake(t *testing.T) {
 		})
 	}
 }
+
+func TestValueToStringAndType(t *testing.T) {
+	tests := []struct {
+		name      string
+		build     func(v pcommon.Value)
+		wantValue string
+		wantType  string
+	}{
+		{"string", func(v pcommon.Value) { v.SetStr("GET") }, "GET", "string"},
+		{"bytes are base64, typed bytes", func(v pcommon.Value) {
+			v.SetEmptyBytes().FromRaw([]byte{0xde, 0xad, 0xbe, 0xef})
+		}, "3q2+7w==", "bytes"},
+		{"map is JSON with sorted keys", func(v pcommon.Value) {
+			m := v.SetEmptyMap()
+			m.PutStr("user-agent", "curl/8.4")
+			m.PutEmptySlice("accept").AppendEmpty().SetStr("text/html")
+		}, `{"accept":["text/html"],"user-agent":"curl/8.4"}`, "map"},
+		{"nested map values follow the JSON mapping", func(v pcommon.Value) {
+			m := v.SetEmptyMap()
+			m.PutDouble("nan", math.NaN())
+			m.PutDouble("inf", math.Inf(-1))
+			m.PutEmptyBytes("raw").FromRaw([]byte("hi"))
+			m.PutEmpty("unset")
+			m.PutStr("html", "<a&b>")
+			m.PutEmptyMap("inner").PutBool("ok", true)
+		}, `{"html":"<a&b>","inf":"-Infinity","inner":{"ok":true},"nan":"NaN","raw":"aGk=","unset":null}`, "map"},
+		{"homogeneous list keeps its typed form", func(v pcommon.Value) {
+			s := v.SetEmptySlice()
+			s.AppendEmpty().SetInt(1)
+			s.AppendEmpty().SetInt(2)
+		}, "[1,2]", "int64[]"},
+		{"mixed list is typed JSON", func(v pcommon.Value) {
+			s := v.SetEmptySlice()
+			s.AppendEmpty().SetInt(1)
+			s.AppendEmpty().SetStr("1")
+		}, `[{"type":"int64","value":1},{"type":"string","value":"1"}]`, "any[]"},
+		{"list of maps is typed JSON", func(v pcommon.Value) {
+			s := v.SetEmptySlice()
+			s.AppendEmpty().SetEmptyMap().PutStr("role", "user")
+		}, `[{"type":"map","value":{"role":"user"}}]`, "any[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := pcommon.NewValueEmpty()
+			tt.build(v)
+			gotValue, gotType := ValueToStringAndType(v)
+			assert.Equal(t, tt.wantValue, gotValue)
+			assert.Equal(t, tt.wantType, gotType)
+		})
+	}
+}
+
+// Insertion order is not identity: the rendered text is hashed into the
+// dictionary id, so the same map built in two orders must render identically.
+func TestValueToStringAndType_MapOrderIndependent(t *testing.T) {
+	a := pcommon.NewValueEmpty()
+	a.SetEmptyMap().PutStr("x", "1")
+	a.Map().PutStr("y", "2")
+	b := pcommon.NewValueEmpty()
+	b.SetEmptyMap().PutStr("y", "2")
+	b.Map().PutStr("x", "1")
+
+	av, _ := ValueToStringAndType(a)
+	bv, _ := ValueToStringAndType(b)
+	assert.Equal(t, av, bv)
+}
+
+// Every value whose rendering keeps its types must come back as it went in.
+func TestStringAndTypeToValue_RoundTrips(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(v pcommon.Value)
+	}{
+		{"string", func(v pcommon.Value) { v.SetStr("GET /api") }},
+		{"int", func(v pcommon.Value) { v.SetInt(-9007199254740993) }},
+		{"double", func(v pcommon.Value) { v.SetDouble(0.1) }},
+		{"infinite double", func(v pcommon.Value) { v.SetDouble(math.Inf(1)) }},
+		{"bool", func(v pcommon.Value) { v.SetBool(true) }},
+		{"bytes", func(v pcommon.Value) { v.SetEmptyBytes().FromRaw([]byte{0, 1, 0xff}) }},
+		{"map", func(v pcommon.Value) {
+			m := v.SetEmptyMap()
+			m.PutStr("a", "x")
+			m.PutInt("b", 7)
+			m.PutEmptyMap("c").PutBool("d", false)
+		}},
+		{"string list with quotes", func(v pcommon.Value) {
+			s := v.SetEmptySlice()
+			s.AppendEmpty().SetStr(`say "hi"`)
+			s.AppendEmpty().SetStr("b")
+		}},
+		{"string list with a backslash", func(v pcommon.Value) {
+			v.SetEmptySlice().AppendEmpty().SetStr(`C:\temp "x"`)
+		}},
+		{"double list", func(v pcommon.Value) {
+			s := v.SetEmptySlice()
+			s.AppendEmpty().SetDouble(1)
+			s.AppendEmpty().SetDouble(2.5)
+		}},
+		{"bool list", func(v pcommon.Value) { v.SetEmptySlice().AppendEmpty().SetBool(false) }},
+		{"empty list", func(v pcommon.Value) { v.SetEmptySlice() }},
+		{"mixed list", func(v pcommon.Value) {
+			s := v.SetEmptySlice()
+			s.AppendEmpty().SetInt(1)
+			s.AppendEmpty().SetStr("1")
+			s.AppendEmpty().SetDouble(2)
+			s.AppendEmpty().SetEmptyBytes().FromRaw([]byte("hi"))
+			s.AppendEmpty()
+			s.AppendEmpty().SetEmptyMap().PutStr("role", "user")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := pcommon.NewValueEmpty()
+			tt.build(want)
+			value, typ := ValueToStringAndType(want)
+
+			got := pcommon.NewValueEmpty()
+			StringAndTypeToValue(value, typ, got)
+			assert.Equal(t, want.Type(), got.Type())
+			assert.Equal(t, want.AsRaw(), got.AsRaw())
+		})
+	}
+}
+
+// What the rendering lost stays lost, in the documented way, and text that
+// does not parse under its tag is kept as a string rather than dropped.
+func TestStringAndTypeToValue_Lossy(t *testing.T) {
+	in := pcommon.NewValueEmpty()
+	m := in.SetEmptyMap()
+	m.PutDouble("whole", 2)
+	m.PutEmptyBytes("raw").FromRaw([]byte("hi"))
+	value, typ := ValueToStringAndType(in)
+
+	got := pcommon.NewValueEmpty()
+	StringAndTypeToValue(value, typ, got)
+	assert.Equal(t, map[string]any{"raw": "aGk=", "whole": int64(2)}, got.Map().AsRaw())
+
+	unset, typ := ValueToStringAndType(pcommon.NewValueEmpty())
+	got = pcommon.NewValueEmpty()
+	StringAndTypeToValue(unset, typ, got)
+	assert.Equal(t, pcommon.ValueTypeEmpty, got.Type())
+
+	got = pcommon.NewValueEmpty()
+	StringAndTypeToValue("not a number", "int64", got)
+	assert.Equal(t, "not a number", got.Str())
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -19,8 +20,12 @@ import (
 // appender column-count error partway through an ingest, or "failed to create
 // index 4" against a column that does not exist.
 //
-// There is no migration. The remedy is to delete the file or point --db
-// somewhere else, which is what the message says.
+// An older file with a migration chain to this build is upgraded rather than
+// refused (see migrate), so what still arrives here is a file from a newer
+// build, one older than the chain reaches, or an older one opened read-only,
+// which cannot be rewritten. The remedy is to delete the file or point --db
+// somewhere else, which is what the message says -- or, for a bundle or a
+// file opened read-only, to open it with a release that uses its version.
 var ErrSchemaIncompatible = errors.New("database schema is incompatible with this build")
 
 // SchemaCompatibility describes what the version check found when the store was
@@ -38,6 +43,10 @@ const (
 
 	// SchemaMismatch means the file is stamped with a different version.
 	SchemaMismatch
+
+	// SchemaMigrated means the file was stamped with an older version and has
+	// been migrated to this one.
+	SchemaMigrated
 )
 
 // SchemaCompatibility reports what the version check found. SchemaOK for an
@@ -49,18 +58,32 @@ func (s *Store) SchemaCompatibility() SchemaCompatibility {
 // checkSchemaVersion inspects the version stamp and decides whether this build
 // can be expected to read the file.
 //
-// Four outcomes:
+// Five outcomes:
 //
-//	stamp absent, no data   -> brand new; stamp it and proceed
-//	stamp absent, has data  -> written before versioning; warn
-//	stamp present, matches  -> proceed
-//	stamp present, differs  -> warn
+//	stamp absent, no data         -> brand new; stamp it and proceed
+//	stamp absent, has data        -> written before versioning; refuse
+//	stamp present, matches        -> proceed
+//	stamp present, older, chained -> migrate, then proceed
+//	stamp present, otherwise      -> refuse
 //
 // A stamp-less file with data is treated as suspect rather than stamped,
 // because stamping it would assert a compatibility nobody has checked and
 // destroy the only evidence that it predates versioning.
-func checkSchemaVersion(db *sql.DB, dbPath string, logger *zap.Logger) (SchemaCompatibility, error) {
-	if _, err := db.Exec(schema.VersionTableQuery); err != nil {
+//
+// A file opened read-only cannot be stamped, or given a schema_meta table to
+// hold one, so there a missing stamp is refused whether or not data follows:
+// a file this viewer wrote was stamped the moment it was created. Nor can it
+// be migrated; a bundle can, since what is migrated is its in-memory import.
+func checkSchemaVersion(ctx context.Context, db *sql.DB, src source, logger *zap.Logger) (SchemaCompatibility, error) {
+	if src.readOnlyFile() {
+		var tables int
+		if err := db.QueryRow(schema.VersionTableExistsQuery).Scan(&tables); err != nil {
+			return SchemaOK, fmt.Errorf("%w while probing for schema_meta: %w", ErrStoreInitFailed, err)
+		}
+		if tables == 0 {
+			return unstamped(db, src, logger)
+		}
+	} else if _, err := db.Exec(schema.VersionTableQuery); err != nil {
 		return SchemaOK, fmt.Errorf("%w while creating schema_meta: %w", ErrStoreInitFailed, err)
 	}
 
@@ -74,29 +97,37 @@ func checkSchemaVersion(db *sql.DB, dbPath string, logger *zap.Logger) (SchemaCo
 		if stamped.Int64 == schema.Version {
 			return SchemaOK, nil
 		}
+		if canMigrate(stamped.Int64) && !src.readOnlyFile() {
+			if err := migrate(ctx, db, src, stamped.Int64, logger); err != nil {
+				return SchemaMismatch, err
+			}
+			return SchemaMigrated, nil
+		}
+		age := "an older build"
+		if stamped.Int64 > schema.Version {
+			age = "a newer build"
+		}
+		remedy := src.remedy(stamped.Int64)
 		logger.Error("database was written by a different schema version",
-			zap.String("database", describePath(dbPath)),
+			zap.String("database", src.String()),
 			zap.Int64("file_version", stamped.Int64),
 			zap.Int("expected_version", schema.Version),
-			zap.String("remedy", "delete it or pass a different --db path"))
-		return SchemaMismatch, fmt.Errorf("%w: %s was written by schema version %d, "+
-			"this build uses %d -- delete it or pass a different --db path",
-			ErrSchemaIncompatible, describePath(dbPath), stamped.Int64, schema.Version)
+			zap.String("written_by", age),
+			zap.String("remedy", remedy))
+		return SchemaMismatch, fmt.Errorf("%w: %s was written by %s, with schema version %d; "+
+			"this build uses %d -- %s",
+			ErrSchemaIncompatible, src, age, stamped.Int64, schema.Version, remedy)
 	}
 
+	if src.readOnlyFile() {
+		return unstamped(db, src, logger)
+	}
 	hasData, err := hasExistingData(db)
 	if err != nil {
 		return SchemaOK, err
 	}
 	if hasData {
-		logger.Error("database holds data but carries no schema version, so it predates "+
-			"versioning and its shape cannot be confirmed",
-			zap.String("database", describePath(dbPath)),
-			zap.Int("expected_version", schema.Version),
-			zap.String("remedy", "delete it or pass a different --db path"))
-		return SchemaPreVersioning, fmt.Errorf("%w: %s holds data but carries no schema "+
-			"version, so it predates versioning -- delete it or pass a different --db path",
-			ErrSchemaIncompatible, describePath(dbPath))
+		return unstamped(db, src, logger)
 	}
 
 	if _, err := db.Exec(schema.StampVersionQuery, schema.Version); err != nil {
@@ -105,6 +136,48 @@ func checkSchemaVersion(db *sql.DB, dbPath string, logger *zap.Logger) (SchemaCo
 	return SchemaOK, nil
 }
 
+// unstamped refuses a database that carries no version. One with telemetry in
+// it predates versioning; one without, reached only read-only, is not
+// something this viewer wrote at all.
+func unstamped(db *sql.DB, src source, logger *zap.Logger) (SchemaCompatibility, error) {
+	hasData, err := hasExistingData(db)
+	if err != nil {
+		return SchemaOK, err
+	}
+	if !hasData {
+		return SchemaPreVersioning, fmt.Errorf("%w: %s carries no schema version and holds no spans, "+
+			"so it was not written by this viewer", ErrSchemaIncompatible, src)
+	}
+	remedy := src.remedy(0)
+	logger.Error("database holds data but carries no schema version, so it predates "+
+		"versioning and its shape cannot be confirmed",
+		zap.String("database", src.String()),
+		zap.Int("expected_version", schema.Version),
+		zap.String("remedy", remedy))
+	return SchemaPreVersioning, fmt.Errorf("%w: %s holds data but carries no schema "+
+		"version, so it predates versioning -- %s",
+		ErrSchemaIncompatible, src, remedy)
+}
+
+// remedy says what to do about a database stamped with fileVersion, 0 for none.
+// A --db store can start over, since it only ever holds what this viewer
+// received; a bundle or a file opened read-only is someone's capture, and
+// the way to read it is a build that speaks its schema -- or, for an older
+// file this build can migrate, one writable open to upgrade it first.
+func (src source) remedy(fileVersion int64) string {
+	if !src.readOnly {
+		return "delete it or pass a different --db path"
+	}
+	if fileVersion == 0 {
+		return "open it with the release that wrote it"
+	}
+	if canMigrate(fileVersion) {
+		return fmt.Sprintf("open it with a release that uses schema version %d, or run once with "+
+			"--db pointing at it to upgrade it in place (the original is kept as a backup)", fileVersion)
+	}
+	return fmt.Sprintf("open it with a release that uses schema version %d", fileVersion)
+}
+
 // hasExistingData reports whether the file already holds telemetry. Two queries
 // rather than one: DuckDB binds a whole statement before running it, so a
 // subquery naming `spans` fails to bind on a database that has never had it.
@@ -123,10 +196,3 @@ func hasExistingData(db *sql.DB) (bool, error) {
 	}
 	return spans > 0, nil
 }
-
-func describePath(dbPath string) string {
-	if dbPath == "" {
-		return "(in-memory)"
-	}
-	return dbPath
-}
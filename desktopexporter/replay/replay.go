@@ -0,0 +1,357 @@
+// Package replay loads saved OTLP into the store: the collector file
+// exporter's output (OTLP JSON, one request per line, or its length-prefixed
+// protobuf format) and raw protobuf dumps of a single export request. Zipkin
+// v2 span lists and Jaeger UI downloads are read too, converted to OTLP on the
+// way in.
+//
+// It exists so telemetry captured somewhere else -- a CI run, a colleague's
+// machine -- can be looked at without re-running whatever produced it. The
+// payloads go through sink, the same write path the exporter takes, so a
+// replayed span is stored exactly as a received one would have been.
+//
+// A public package rather than part of internal/ because the binary's replay
+// subcommand lives in the root module's main package, which Go's internal rule
+// keeps out of desktopexporter/internal; this is the one door it needs.
+package replay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/legacytrace"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store/sink"
+)
+
+// ErrNotOTLP is returned by Read for input that is neither OTLP JSON, Zipkin
+// or Jaeger JSON, nor an OTLP protobuf export request.
+var ErrNotOTLP = errors.New("not an OTLP traces, metrics or logs export")
+
+// Capture is every payload read so far, by signal, in the order read.
+type Capture struct {
+	Traces  []ptrace.Traces
+	Metrics []pmetric.Metrics
+	Logs    []plog.Logs
+}
+
+// Counts reports what the capture holds: spans, metric datapoints and log
+// records.
+func (c *Capture) Counts() (spanCount, dataPointCount, logCount int) {
+	for _, td := range c.Traces {
+		spanCount += td.SpanCount()
+	}
+	for _, md := range c.Metrics {
+		dataPointCount += md.DataPointCount()
+	}
+	for _, ld := range c.Logs {
+		logCount += ld.LogRecordCount()
+	}
+	return spanCount, dataPointCount, logCount
+}
+
+// gzipMagic opens every gzip stream. The file exporter can compress its
+// output, and a CI artifact is often gzipped on the way out regardless.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Read decodes one file's worth of OTLP into the capture. name is only for
+// errors.
+//
+// The format is sniffed rather than declared, since one CI job's files are
+// rarely all one kind: JSON if the first non-blank byte is '{' or '[',
+// protobuf otherwise. A protobuf file is first read as the file exporter's
+// framing -- each request preceded by its length as a 4-byte big-endian
+// integer -- and as one bare request if the framing does not account for
+// every byte.
+//
+// Protobuf does not say which signal a request is: all three carry their
+// resources in field 1. Each message is tried as traces, then metrics, then
+// logs, and the first that parses and holds something wins. The shapes differ
+// in wire types early enough that the wrong unmarshaler fails rather than
+// producing a plausible misreading.
+func (c *Capture) Read(name string, r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if bytes.HasPrefix(b, gzipMagic) {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if b, err = io.ReadAll(zr); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if trimmed := bytes.TrimLeft(b, " \t\r\n"); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return c.readJSON(name, trimmed)
+	}
+
+	if msgs, ok := frames(b); ok {
+		var framed Capture
+		if err := framed.addProtos(msgs); err == nil {
+			c.append(&framed)
+			return nil
+		}
+	}
+	if err := c.addProtos([][]byte{b}); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// readJSON decodes a stream of OTLP JSON requests. One per line is what the
+// file exporter writes, but the decoder does not care about lines, so a
+// single pretty-printed request saved from a curl call reads the same way.
+//
+// A JSON array is a Zipkin v2 span list, the only format here that is not an
+// object; an object is Jaeger's if it has the keys legacytrace.IsJaeger looks
+// for, which no OTLP request carries.
+func (c *Capture) readJSON(name string, b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for n := 1; ; n++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: request %d: %w", name, n, err)
+		}
+		if raw[0] == '[' {
+			td, err := legacytrace.Zipkin(raw)
+			if err != nil {
+				return fmt.Errorf("%s: request %d: %w", name, n, err)
+			}
+			c.Traces = append(c.Traces, td)
+			continue
+		}
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &keys); err != nil {
+			return fmt.Errorf("%s: request %d: %w", name, n, err)
+		}
+		// pdata accepts both the proto3 JSON names and the proto field names.
+		has := func(camel, snake string) bool {
+			_, a := keys[camel]
+			_, b := keys[snake]
+			return a || b
+		}
+		var err error
+		switch {
+		case has("resourceSpans", "resource_spans"):
+			var td ptrace.Traces
+			if td, err = (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(raw); err == nil {
+				c.Traces = append(c.Traces, td)
+			}
+		case has("resourceMetrics", "resource_metrics"):
+			var md pmetric.Metrics
+			if md, err = (&pmetric.JSONUnmarshaler{}).UnmarshalMetrics(raw); err == nil {
+				c.Metrics = append(c.Metrics, md)
+			}
+		case has("resourceLogs", "resource_logs"):
+			var ld plog.Logs
+			if ld, err = (&plog.JSONUnmarshaler{}).UnmarshalLogs(raw); err == nil {
+				c.Logs = append(c.Logs, ld)
+			}
+		case legacytrace.IsJaeger(keys):
+			var td ptrace.Traces
+			if td, err = legacytrace.Jaeger(raw); err == nil {
+				c.Traces = append(c.Traces, td)
+			}
+		default:
+			err = fmt.Errorf("%w: no resourceSpans, resourceMetrics or resourceLogs", ErrNotOTLP)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: request %d: %w", name, n, err)
+		}
+	}
+}
+
+// frames splits b as the file exporter's length-prefixed protobuf stream,
+// reporting false unless the prefixes account for every byte.
+func frames(b []byte) ([][]byte, bool) {
+	var out [][]byte
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, false
+		}
+		n := binary.BigEndian.Uint32(b)
+		if uint64(n) > uint64(len(b)-4) {
+			return nil, false
+		}
+		out = append(out, b[4:4+n])
+		b = b[4+n:]
+	}
+	return out, len(out) > 0
+}
+
+func (c *Capture) addProtos(msgs [][]byte) error {
+	for _, msg := range msgs {
+		if td, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(msg); err == nil && td.SpanCount() > 0 {
+			c.Traces = append(c.Traces, td)
+			continue
+		}
+		if md, err := (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(msg); err == nil && md.MetricCount() > 0 {
+			c.Metrics = append(c.Metrics, md)
+			continue
+		}
+		if ld, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(msg); err == nil && ld.LogRecordCount() > 0 {
+			c.Logs = append(c.Logs, ld)
+			continue
+		}
+		return ErrNotOTLP
+	}
+	return nil
+}
+
+func (c *Capture) append(o *Capture) {
+	c.Traces = append(c.Traces, o.Traces...)
+	c.Metrics = append(c.Metrics, o.Metrics...)
+	c.Logs = append(c.Logs, o.Logs...)
+}
+
+// Rebase shifts every timestamp in the capture by one amount, so the latest
+// lands on end, and returns the shift. Relative timing -- durations, the gaps
+// between batches, which log sits inside which span -- is untouched.
+//
+// A zero timestamp means unset in OTLP (a log with only an observed time, a
+// gauge with no start), so it stays zero rather than becoming end minus the
+// capture's age.
+func (c *Capture) Rebase(end time.Time) time.Duration {
+	var latest pcommon.Timestamp
+	c.eachTimestamp(func(ts pcommon.Timestamp) pcommon.Timestamp {
+		latest = max(latest, ts)
+		return ts
+	})
+	if latest == 0 {
+		return 0
+	}
+	shift := end.Sub(latest.AsTime())
+	c.eachTimestamp(func(ts pcommon.Timestamp) pcommon.Timestamp {
+		return pcommon.NewTimestampFromTime(ts.AsTime().Add(shift))
+	})
+	return shift
+}
+
+// eachTimestamp replaces every set timestamp in the capture with f's result.
+func (c *Capture) eachTimestamp(f func(pcommon.Timestamp) pcommon.Timestamp) {
+	set := func(get func() pcommon.Timestamp, put func(pcommon.Timestamp)) {
+		if ts := get(); ts != 0 {
+			put(f(ts))
+		}
+	}
+
+	for _, td := range c.Traces {
+		for _, rs := range td.ResourceSpans().All() {
+			for _, ss := range rs.ScopeSpans().All() {
+				for _, span := range ss.Spans().All() {
+					set(span.StartTimestamp, span.SetStartTimestamp)
+					set(span.EndTimestamp, span.SetEndTimestamp)
+					for _, ev := range span.Events().All() {
+						set(ev.Timestamp, ev.SetTimestamp)
+					}
+				}
+			}
+		}
+	}
+
+	for _, ld := range c.Logs {
+		for _, rl := range ld.ResourceLogs().All() {
+			for _, sl := range rl.ScopeLogs().All() {
+				for _, lr := range sl.LogRecords().All() {
+					set(lr.Timestamp, lr.SetTimestamp)
+					set(lr.ObservedTimestamp, lr.SetObservedTimestamp)
+				}
+			}
+		}
+	}
+
+	exemplars := func(es pmetric.ExemplarSlice) {
+		for _, ex := range es.All() {
+			set(ex.Timestamp, ex.SetTimestamp)
+		}
+	}
+	numbers := func(dps pmetric.NumberDataPointSlice) {
+		for _, dp := range dps.All() {
+			set(dp.StartTimestamp, dp.SetStartTimestamp)
+			set(dp.Timestamp, dp.SetTimestamp)
+			exemplars(dp.Exemplars())
+		}
+	}
+	for _, md := range c.Metrics {
+		for _, rm := range md.ResourceMetrics().All() {
+			for _, sm := range rm.ScopeMetrics().All() {
+				for _, m := range sm.Metrics().All() {
+					switch m.Type() {
+					case pmetric.MetricTypeGauge:
+						numbers(m.Gauge().DataPoints())
+					case pmetric.MetricTypeSum:
+						numbers(m.Sum().DataPoints())
+					case pmetric.MetricTypeHistogram:
+						for _, dp := range m.Histogram().DataPoints().All() {
+							set(dp.StartTimestamp, dp.SetStartTimestamp)
+							set(dp.Timestamp, dp.SetTimestamp)
+							exemplars(dp.Exemplars())
+						}
+					case pmetric.MetricTypeExponentialHistogram:
+						for _, dp := range m.ExponentialHistogram().DataPoints().All() {
+							set(dp.StartTimestamp, dp.SetStartTimestamp)
+							set(dp.Timestamp, dp.SetTimestamp)
+							exemplars(dp.Exemplars())
+						}
+					case pmetric.MetricTypeSummary:
+						for _, dp := range m.Summary().DataPoints().All() {
+							set(dp.StartTimestamp, dp.SetStartTimestamp)
+							set(dp.Timestamp, dp.SetTimestamp)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// storeHost is the capability Ingest needs from the component it is handed,
+// declared here for the same reason the exporter declares its own: the duckdb
+// extension qualifies by exposing Store(), not by its type name.
+type storeHost interface {
+	Store() *store.Store
+}
+
+// Ingest writes the capture into the store owner holds -- a started duckdb
+// extension. Each payload is one ingest, as it would have been one batch
+// arriving at the exporter.
+func (c *Capture) Ingest(ctx context.Context, owner component.Component) error {
+	h, ok := owner.(storeHost)
+	if !ok || h.Store() == nil {
+		return errors.New("replay: the component given owns no started store")
+	}
+	s := h.Store()
+	for _, td := range c.Traces {
+		if err := sink.Traces(ctx, s, td, nil); err != nil {
+			return fmt.Errorf("replay: traces: %w", err)
+		}
+	}
+	for _, md := range c.Metrics {
+		if err := sink.Metrics(ctx, s, md, nil); err != nil {
+			return fmt.Errorf("replay: metrics: %w", err)
+		}
+	}
+	for _, ld := range c.Logs {
+		if err := sink.Logs(ctx, s, ld, nil); err != nil {
+			return fmt.Errorf("replay: logs: %w", err)
+		}
+	}
+	return nil
+}
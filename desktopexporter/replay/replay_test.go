@@ -0,0 +1,206 @@
+package replay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/internal/store"
+)
+
+var captured = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func ts(offset time.Duration) pcommon.Timestamp {
+	return pcommon.NewTimestampFromTime(captured.Add(offset))
+}
+
+func sampleTraces() ptrace.Traces {
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "checkout")
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	span.SetSpanID(pcommon.SpanID{1, 2, 3, 4, 5, 6, 7, 8})
+	span.SetName("GET /cart")
+	span.SetStartTimestamp(ts(0))
+	span.SetEndTimestamp(ts(2 * time.Second))
+	span.Events().AppendEmpty().SetTimestamp(ts(time.Second))
+	return td
+}
+
+func sampleMetrics() pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "checkout")
+	m := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("queue.depth")
+	dp := m.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetTimestamp(ts(3 * time.Second))
+	dp.SetIntValue(7)
+	return md
+}
+
+func sampleLogs() plog.Logs {
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "checkout")
+	lr := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	// No timestamp, only an observed one: the zero must survive a rebase.
+	lr.SetObservedTimestamp(ts(5 * time.Second))
+	lr.Body().SetStr("cart loaded")
+	return ld
+}
+
+func jsonLines(t *testing.T) []byte {
+	t.Helper()
+	tj, err := (&ptrace.JSONMarshaler{}).MarshalTraces(sampleTraces())
+	require.NoError(t, err)
+	mj, err := (&pmetric.JSONMarshaler{}).MarshalMetrics(sampleMetrics())
+	require.NoError(t, err)
+	lj, err := (&plog.JSONMarshaler{}).MarshalLogs(sampleLogs())
+	require.NoError(t, err)
+	return bytes.Join([][]byte{tj, mj, lj}, []byte("\n"))
+}
+
+func protos(t *testing.T) [][]byte {
+	t.Helper()
+	tp, err := (&ptrace.ProtoMarshaler{}).MarshalTraces(sampleTraces())
+	require.NoError(t, err)
+	mp, err := (&pmetric.ProtoMarshaler{}).MarshalMetrics(sampleMetrics())
+	require.NoError(t, err)
+	lp, err := (&plog.ProtoMarshaler{}).MarshalLogs(sampleLogs())
+	require.NoError(t, err)
+	return [][]byte{tp, mp, lp}
+}
+
+func assertOneOfEach(t *testing.T, c *Capture) {
+	t.Helper()
+	spans, points, logs := c.Counts()
+	assert.Equal(t, 1, spans)
+	assert.Equal(t, 1, points)
+	assert.Equal(t, 1, logs)
+}
+
+func TestReadFormats(t *testing.T) {
+	var framed []byte
+	for _, p := range protos(t) {
+		framed = binary.BigEndian.AppendUint32(framed, uint32(len(p)))
+		framed = append(framed, p...)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err := zw.Write(jsonLines(t))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	for name, files := range map[string][][]byte{
+		"json lines":          {jsonLines(t)},
+		"file exporter proto": {framed},
+		"raw proto dumps":     protos(t),
+		"gzipped json":        {gz.Bytes()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var c Capture
+			for _, f := range files {
+				require.NoError(t, c.Read(name, bytes.NewReader(f)))
+			}
+			assertOneOfEach(t, &c)
+			assert.Equal(t, "GET /cart", c.Traces[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name())
+			assert.Equal(t, "queue.depth", c.Metrics[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Name())
+		})
+	}
+}
+
+// Zipkin span lists and Jaeger downloads are read as traces, converted by
+// legacytrace; its own tests cover the mapping.
+func TestReadLegacyTraceFormats(t *testing.T) {
+	for name, input := range map[string]string{
+		"zipkin": `[{"traceId": "463ac35c9f6413ad48485a3953bb6124", "id": "a2fb4a1d1a96d312", "name": "GET /cart",
+			"timestamp": 1700000000000000, "duration": 10, "localEndpoint": {"serviceName": "checkout"}}]`,
+		"jaeger": `{"data": [{"traceID": "463ac35c9f6413ad48485a3953bb6124", "spans": [{"traceID": "463ac35c9f6413ad48485a3953bb6124",
+			"spanID": "a2fb4a1d1a96d312", "operationName": "GET /cart", "startTime": 1700000000000000, "duration": 10,
+			"processID": "p1"}], "processes": {"p1": {"serviceName": "checkout"}}}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var c Capture
+			require.NoError(t, c.Read(name+".json", bytes.NewReader([]byte(input))))
+			require.Len(t, c.Traces, 1)
+			rs := c.Traces[0].ResourceSpans().At(0)
+			service, _ := rs.Resource().Attributes().Get("service.name")
+			assert.Equal(t, "checkout", service.Str())
+			assert.Equal(t, "GET /cart", rs.ScopeSpans().At(0).Spans().At(0).Name())
+		})
+	}
+}
+
+func TestReadRejectsWhatIsNotOTLP(t *testing.T) {
+	for name, input := range map[string]string{
+		"json of another shape": `{"hello": "world"}`,
+		"text":                  "2026-03-01 12:00:00 INFO started\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			var c Capture
+			err := c.Read("capture.txt", bytes.NewReader([]byte(input)))
+			require.ErrorIs(t, err, ErrNotOTLP)
+			assert.Contains(t, err.Error(), "capture.txt")
+		})
+	}
+}
+
+func TestRebaseEndsTheCaptureAtTheGivenTime(t *testing.T) {
+	var c Capture
+	require.NoError(t, c.Read("capture.json", bytes.NewReader(jsonLines(t))))
+	end := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+
+	shift := c.Rebase(end)
+	assert.Equal(t, end.Sub(captured.Add(5*time.Second)), shift)
+
+	span := c.Traces[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, end.Add(-5*time.Second), span.StartTimestamp().AsTime().UTC())
+	assert.Equal(t, 2*time.Second, span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime()), "durations are kept")
+	assert.Equal(t, end.Add(-4*time.Second), span.Events().At(0).Timestamp().AsTime().UTC())
+
+	dp := c.Metrics[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0)
+	assert.Equal(t, end.Add(-2*time.Second), dp.Timestamp().AsTime().UTC())
+	assert.Zero(t, dp.StartTimestamp(), "an unset start stays unset")
+
+	lr := c.Logs[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	assert.Equal(t, end, lr.ObservedTimestamp().AsTime().UTC(), "the latest timestamp lands on end")
+	assert.Zero(t, lr.Timestamp())
+}
+
+type storeOwner struct{ s *store.Store }
+
+func (storeOwner) Start(context.Context, component.Host) error { return nil }
+func (storeOwner) Shutdown(context.Context) error              { return nil }
+func (o storeOwner) Store() *store.Store                       { return o.s }
+
+func TestIngestWritesEverySignal(t *testing.T) {
+	s, err := store.NewStore(context.Background(), "", nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	var c Capture
+	require.NoError(t, c.Read("capture.json", bytes.NewReader(jsonLines(t))))
+	require.NoError(t, c.Ingest(context.Background(), storeOwner{s}))
+
+	for table, want := range map[string]int{"spans": 1, "events": 1, "datapoints": 1, "logs": 1} {
+		var n int
+		require.NoError(t, s.WithDBRead(func(db *sql.DB) error {
+			return db.QueryRow(`select count(*) from ` + table).Scan(&n)
+		}))
+		assert.Equalf(t, want, n, "rows in %s", table)
+	}
+}
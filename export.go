@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// exportOptions are the export subcommand's flags, resolved.
+type exportOptions struct {
+	host        string
+	browserPort int
+	out         string
+	start, end  time.Time
+	traceIDs    []string
+}
+
+// newExportCommand builds `otel-desktop-viewer export`, which asks a running
+// viewer for a bundle over its own JSON-RPC API and writes it to a file.
+//
+// It goes through the running process rather than opening the database,
+// because there is usually no database to open: the default store is in
+// memory, and a --db file is held open by the viewer that owns it. The same
+// exportBundle method serves the UI's export, so the two cannot disagree
+// about what a bundle holds.
+func newExportCommand() *cobra.Command {
+	var o exportOptions
+	var startFlag, endFlag string
+	var sinceFlag time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write a running viewer's telemetry to a zipped DuckDB bundle",
+		Long: "Write the telemetry a running viewer holds to a single zip: a DuckDB Parquet export " +
+			"that anyone can open with `import database` in the DuckDB CLI. Narrow it with a time " +
+			"window, trace IDs, or both.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			var err error
+			if sinceFlag > 0 {
+				if startFlag != "" {
+					return fmt.Errorf("export: --since and --start both set the window start; pass one")
+				}
+				o.start = time.Now().Add(-sinceFlag)
+			}
+			if startFlag != "" {
+				if o.start, err = time.Parse(time.RFC3339Nano, startFlag); err != nil {
+					return fmt.Errorf("export: --start: %w", err)
+				}
+			}
+			if endFlag != "" {
+				if o.end, err = time.Parse(time.RFC3339Nano, endFlag); err != nil {
+					return fmt.Errorf("export: --end: %w", err)
+				}
+			}
+			path, size, err := runExport(cmd.Context(), http.DefaultClient, o)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "wrote %s (%d bytes)\n", path, size)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&o.out, "out", "", "Path of the zip to write. Defaults to a timestamped name in the current directory.")
+	cmd.Flags().StringVar(&o.host, "host", "localhost", "Host of the running viewer.")
+	cmd.Flags().IntVar(&o.browserPort, "browser-port", 8000, "Port of the running viewer.")
+	cmd.Flags().StringVar(&startFlag, "start", "", "Window start, RFC 3339 (e.g. 2026-10-17T09:00:00Z).")
+	cmd.Flags().StringVar(&endFlag, "end", "", "Window end, RFC 3339.")
+	cmd.Flags().DurationVar(&sinceFlag, "since", 0, "Window start as a duration before now (e.g. 15m). Exclusive with --start.")
+	cmd.Flags().StringSliceVar(&o.traceIDs, "trace-id", nil, "Keep only these traces and their logs; metrics are left out. Repeatable or comma-separated.")
+	return cmd
+}
+
+// runExport calls exportBundle and writes what comes back, returning the path
+// written and its size.
+func runExport(ctx context.Context, client *http.Client, o exportOptions) (string, int, error) {
+	params := map[string]any{}
+	if !o.start.IsZero() {
+		params["startTime"] = strconv.FormatInt(o.start.UnixNano(), 10)
+	}
+	if !o.end.IsZero() {
+		params["endTime"] = strconv.FormatInt(o.end.UnixNano(), 10)
+	}
+	if len(o.traceIDs) > 0 {
+		params["traceIDs"] = o.traceIDs
+	}
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "exportBundle",
+		"params":  params,
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("export: %w", err)
+	}
+
+	url := "http://" + net.JoinHostPort(browserHostFor(o.host), strconv.Itoa(o.browserPort)) + "/rpc"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", 0, fmt.Errorf("export: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("export: is the viewer running at %s? %w", url, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return "", 0, fmt.Errorf("export: %s answered %s: %s", url, res.Status, bytes.TrimSpace(msg))
+	}
+
+	var reply struct {
+		Result *struct {
+			FileName string `json:"fileName"`
+			Data     []byte `json:"data"`
+		} `json:"result"`
+		Error *struct {
+			Code    int64  `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&reply); err != nil {
+		return "", 0, fmt.Errorf("export: reading the reply: %w", err)
+	}
+	if reply.Error != nil {
+		return "", 0, fmt.Errorf("export: %s (code %d)", reply.Error.Message, reply.Error.Code)
+	}
+	if reply.Result == nil {
+		return "", 0, fmt.Errorf("export: the reply carried no bundle")
+	}
+
+	path := o.out
+	if path == "" {
+		// The server's name, stripped of any directory, so a reply cannot
+		// choose where on this machine it lands.
+		path = filepath.Base(reply.Result.FileName)
+	}
+	if err := writeFileAtomic(path, reply.Result.Data); err != nil {
+		return "", 0, fmt.Errorf("export: %w", err)
+	}
+	return path, len(reply.Result.Data), nil
+}
+
+// writeFileAtomic writes through a temporary file beside path and renames it
+// into place, so an interrupted export never leaves a truncated zip under the
+// name someone is about to attach to a bug report.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubViewer answers /rpc with reply and records the request it got.
+func stubViewer(t *testing.T, reply string) (exportOptions, *map[string]any) {
+	t.Helper()
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rpc", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(reply))
+	}))
+	t.Cleanup(srv.Close)
+
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	p, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return exportOptions{host: host, browserPort: p}, &got
+}
+
+func TestExportWritesBundle(t *testing.T) {
+	// "UEsFBg==" is base64 for "PK\x05\x06", the start of an empty zip.
+	o, got := stubViewer(t, `{"jsonrpc":"2.0","id":1,"result":{"fileName":"../../capture.zip","sizeBytes":4,"data":"UEsFBg=="}}`)
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	o.start = time.Unix(0, 1_700_000_000_000_000_000)
+	o.traceIDs = []string{"0af7651916cd43dd8448eb211c80319c"}
+	path, size, err := runExport(context.Background(), http.DefaultClient, o)
+	require.NoError(t, err)
+
+	assert.Equal(t, "capture.zip", path, "the server's name, with no say over the directory")
+	assert.Equal(t, 4, size)
+	b, err := os.ReadFile(filepath.Join(dir, "capture.zip"))
+	require.NoError(t, err)
+	assert.Equal(t, "PK\x05\x06", string(b))
+
+	assert.Equal(t, "exportBundle", (*got)["method"])
+	assert.Equal(t, map[string]any{
+		"startTime": "1700000000000000000",
+		"traceIDs":  []any{"0af7651916cd43dd8448eb211c80319c"},
+	}, (*got)["params"], "an unset bound is left out, not sent as zero")
+}
+
+func TestExportReportsRPCError(t *testing.T) {
+	o, _ := stubViewer(t, `{"jsonrpc":"2.0","id":1,"error":{"code":-32004,"message":"Invalid trace ID"}}`)
+	o.out = filepath.Join(t.TempDir(), "capture.zip")
+
+	_, _, err := runExport(context.Background(), http.DefaultClient, o)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid trace ID")
+	_, statErr := os.Stat(o.out)
+	assert.True(t, os.IsNotExist(statErr), "nothing is written on failure")
+}
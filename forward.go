@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// viewerTokenHeader is the header mutating and outbound RPCs present the
+// viewer's token in. The server package is internal to desktopexporter, so
+// the name is repeated here rather than imported.
+const viewerTokenHeader = "X-Viewer-Token"
+
+// forwardOptions are the forward subcommand's flags and arguments, resolved.
+type forwardOptions struct {
+	host        string
+	browserPort int
+	endpoint    string
+	headers     map[string]string
+	signal      string
+	ids         []string
+}
+
+// forwardBatch is one entry of forwardTelemetry's result.
+type forwardBatch struct {
+	Service  string `json:"service"`
+	Items    int    `json:"items"`
+	OK       bool   `json:"ok"`
+	Rejected int64  `json:"rejected"`
+	Error    string `json:"error"`
+}
+
+// newForwardCommand builds `otel-desktop-viewer forward`, which has a running
+// viewer send stored traces, logs or metric streams to another OTLP endpoint.
+//
+// Like export it goes through the viewer's API, and so it can only reach the
+// endpoints the viewer was started with in forward_endpoints: the command
+// picks one, it cannot add one.
+func newForwardCommand() *cobra.Command {
+	var o forwardOptions
+	var headerFlags []string
+
+	cmd := &cobra.Command{
+		Use:   "forward --endpoint URL [flags] ID...",
+		Short: "Send a running viewer's traces, logs or metrics to another OTLP endpoint",
+		Long: "Have a running viewer rebuild the given traces, log records or metric streams as OTLP and send " +
+			"them to an endpoint listed in the duckdb extension's forward_endpoints, over OTLP/HTTP (http:// or " +
+			"https://) or gRPC (grpc:// or grpcs://). Each service's batch is sent and reported separately.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if o.endpoint == "" {
+				return fmt.Errorf("forward: --endpoint is required")
+			}
+			o.headers = map[string]string{}
+			for _, h := range headerFlags {
+				k, v, ok := strings.Cut(h, "=")
+				if !ok || strings.TrimSpace(k) == "" {
+					return fmt.Errorf("forward: --header %q: want name=value", h)
+				}
+				o.headers[strings.TrimSpace(k)] = v
+			}
+			o.ids = args
+
+			endpoint, batches, err := runForward(cmd.Context(), http.DefaultClient, o)
+			if err != nil {
+				return err
+			}
+			failed := 0
+			for _, b := range batches {
+				service := b.Service
+				if service == "" {
+					service = "(no service.name)"
+				}
+				switch {
+				case b.OK:
+					fmt.Fprintf(cmd.OutOrStdout(), "ok      %s: %d sent\n", service, b.Items)
+				case b.Rejected > 0:
+					failed++
+					fmt.Fprintf(cmd.OutOrStdout(), "partial %s: %d of %d rejected: %s\n", service, b.Rejected, b.Items, b.Error)
+				default:
+					failed++
+					fmt.Fprintf(cmd.OutOrStdout(), "failed  %s: %s\n", service, b.Error)
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("forward: %d of %d batches to %s did not go through", failed, len(batches), endpoint)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "forwarded %d batches to %s\n", len(batches), endpoint)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&o.endpoint, "endpoint", "", "OTLP endpoint to send to; must be listed in forward_endpoints.")
+	cmd.Flags().StringArrayVar(&headerFlags, "header", nil, "Header to send with each request, as name=value (e.g. Authorization=Bearer ...). Repeatable.")
+	cmd.Flags().StringVar(&o.signal, "signal", "traces", `What the IDs name: "traces", "logs" or "metrics" (stream IDs).`)
+	cmd.Flags().StringVar(&o.host, "host", "localhost", "Host of the running viewer.")
+	cmd.Flags().IntVar(&o.browserPort, "browser-port", 8000, "Port of the running viewer.")
+	return cmd
+}
+
+// runForward fetches the viewer's token and calls forwardTelemetry with it,
+// returning the endpoint as the viewer named it and the per-batch results.
+func runForward(ctx context.Context, client *http.Client, o forwardOptions) (string, []forwardBatch, error) {
+	base := "http://" + net.JoinHostPort(browserHostFor(o.host), strconv.Itoa(o.browserPort))
+
+	// /token serves requests that carry no Origin, which is to say tools
+	// like this one rather than pages.
+	tokenReq, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/token", nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("forward: %w", err)
+	}
+	res, err := client.Do(tokenReq)
+	if err != nil {
+		return "", nil, fmt.Errorf("forward: is the viewer running at %s? %w", base, err)
+	}
+	token, err := io.ReadAll(io.LimitReader(res.Body, 512))
+	res.Body.Close()
+	if err != nil {
+		return "", nil, fmt.Errorf("forward: reading the viewer token: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("forward: %s/token answered %s", base, res.Status)
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "forwardTelemetry",
+		"params": map[string]any{
+			"endpoint": o.endpoint,
+			"headers":  o.headers,
+			"signal":   o.signal,
+			"ids":      o.ids,
+		},
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("forward: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/rpc", bytes.NewReader(body))
+	if err != nil {
+		return "", nil, fmt.Errorf("forward: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(viewerTokenHeader, string(bytes.TrimSpace(token)))
+	res, err = client.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("forward: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return "", nil, fmt.Errorf("forward: %s/rpc answered %s: %s", base, res.Status, bytes.TrimSpace(msg))
+	}
+
+	var reply struct {
+		Result *struct {
+			Endpoint string         `json:"endpoint"`
+			Batches  []forwardBatch `json:"batches"`
+		} `json:"result"`
+		Error *struct {
+			Code    int64  `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&reply); err != nil {
+		return "", nil, fmt.Errorf("forward: reading the reply: %w", err)
+	}
+	if reply.Error != nil {
+		return "", nil, fmt.Errorf("forward: %s (code %d)", reply.Error.Message, reply.Error.Code)
+	}
+	if reply.Result == nil {
+		return "", nil, fmt.Errorf("forward: the reply carried no result")
+	}
+	return reply.Result.Endpoint, reply.Result.Batches, nil
+}
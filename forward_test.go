@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubForwardViewer serves /token and answers /rpc with reply, recording the
+// request and the token it carried.
+func stubForwardViewer(t *testing.T, reply string) (forwardOptions, *map[string]any, *string) {
+	t.Helper()
+	var got map[string]any
+	var token string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /token", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("abc123"))
+	})
+	mux.HandleFunc("POST /rpc", func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get(viewerTokenHeader)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(reply))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	p, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return forwardOptions{host: host, browserPort: p}, &got, &token
+}
+
+func TestForwardSendsWithToken(t *testing.T) {
+	o, got, token := stubForwardViewer(t, `{"jsonrpc":"2.0","id":1,"result":{"endpoint":"https://otlp.example.com",`+
+		`"batches":[{"service":"checkout","items":3,"ok":true,"rejected":0},`+
+		`{"service":"frontend","items":2,"ok":false,"rejected":1,"error":"span too large"}]}}`)
+	o.endpoint = "https://otlp.example.com/"
+	o.headers = map[string]string{"Authorization": "Bearer k"}
+	o.signal = "traces"
+	o.ids = []string{"0af7651916cd43dd8448eb211c80319c"}
+
+	endpoint, batches, err := runForward(context.Background(), http.DefaultClient, o)
+	require.NoError(t, err)
+	assert.Equal(t, "https://otlp.example.com", endpoint)
+	assert.Equal(t, []forwardBatch{
+		{Service: "checkout", Items: 3, OK: true},
+		{Service: "frontend", Items: 2, Rejected: 1, Error: "span too large"},
+	}, batches)
+
+	assert.Equal(t, "abc123", *token)
+	assert.Equal(t, "forwardTelemetry", (*got)["method"])
+	assert.Equal(t, map[string]any{
+		"endpoint": "https://otlp.example.com/",
+		"headers":  map[string]any{"Authorization": "Bearer k"},
+		"signal":   "traces",
+		"ids":      []any{"0af7651916cd43dd8448eb211c80319c"},
+	}, (*got)["params"])
+}
+
+func TestForwardReportsRPCError(t *testing.T) {
+	o, _, _ := stubForwardViewer(t, `{"jsonrpc":"2.0","id":1,"error":{"code":-32013,"message":"Endpoint is not in forward_endpoints"}}`)
+	o.endpoint = "https://elsewhere.example"
+	o.signal = "traces"
+	o.ids = []string{"0af7651916cd43dd8448eb211c80319c"}
+
+	_, _, err := runForward(context.Background(), http.DefaultClient, o)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Endpoint is not in forward_endpoints (code -32013)")
+}
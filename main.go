@@ -5,6 +5,9 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log"
 	"net"
 	"runtime/debug"
@@ -17,8 +20,10 @@ import (
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/confmap"
 	envprovider "go.opentelemetry.io/collector/confmap/provider/envprovider"
+	fileprovider "go.opentelemetry.io/collector/confmap/provider/fileprovider"
 	yamlprovider "go.opentelemetry.io/collector/confmap/provider/yamlprovider"
 	"go.opentelemetry.io/collector/otelcol"
+	"go.yaml.in/yaml/v3"
 )
 
 var version = "dev" // overridden by ldflags in release builds
@@ -50,10 +55,7 @@ func main() {
 		Factories: components,
 		ConfigProviderSettings: otelcol.ConfigProviderSettings{
 			ResolverSettings: confmap.ResolverSettings{
-				ProviderFactories: []confmap.ProviderFactory{
-					envprovider.NewFactory(),
-					yamlprovider.NewFactory(),
-				},
+				ProviderFactories: providerFactories(),
 			},
 		},
 	}
@@ -63,6 +65,17 @@ func main() {
 	}
 }
 
+// providerFactories are the config sources the binary understands: yaml for
+// the fragments built from flags, file for --config, and env for ${VAR}
+// references inside either.
+func providerFactories() []confmap.ProviderFactory {
+	return []confmap.ProviderFactory{
+		envprovider.NewFactory(),
+		fileprovider.NewFactory(),
+		yamlprovider.NewFactory(),
+	}
+}
+
 func runInteractive(params otelcol.CollectorSettings) error {
 	cmd := newCommand(params)
 	if err := cmd.Execute(); err != nil {
@@ -82,30 +95,50 @@ type configOptions struct {
 	browserPort int
 	db          string
 	dbMaxSize   string
+	watchDir    string
 
 	// selfTelemetry turns on the exporter's own instrumentation and points the
 	// collector's service telemetry back at this process's own OTLP receiver,
 	// so the viewer renders its own spans and metrics.
 	selfTelemetry bool
+
+	// configFiles are the --config paths, in the order given. Each is merged
+	// over everything before it, so a later file wins over an earlier one and
+	// every file wins over the flag defaults.
+	configFiles []string
+
+	// explicit names the flags the user actually typed. Only consulted when
+	// there are config files: a flag left at its default must not clobber
+	// what a file says, but one that was set on the command line is the most
+	// specific thing the user told us and goes on top of the files.
+	explicit map[string]bool
 }
 
 // collectorURIs builds the yaml config fragments that stand in for a config
 // file. Keys use confmap's "::" delimiter for flat values; the telemetry block
 // is a nested document because the declarative-config reader and processor
 // schemas are lists of maps.
+//
+// The full order, lowest precedence first, is: the fragments below with every
+// flag at its value (default or not), then each --config file in turn, then
+// the fragments again for just the flags that were set explicitly. Merging is
+// confmap's: maps merge key by key, and anything else -- lists included -- is
+// replaced outright, so a file that lists its own pipeline processors or
+// service extensions must name batch and duckdb itself if it still wants them.
 func collectorURIs(o configOptions) []string {
-	endpoint := formatEndpoint(o.host)
+	f := flagURIs(o)
 
 	uris := []string{
 		`yaml:receivers::otlp::protocols::http::cors::allowed_origins: [https://*,http://*]`,
-		`yaml:receivers::otlp::protocols::http::endpoint: "` + endpoint(o.httpPort) + `"`,
-		`yaml:receivers::otlp::protocols::grpc::endpoint: "` + endpoint(o.grpcPort) + `"`,
+		f.http,
+		f.grpc,
 		// The duckdb extension owns the store, the viewer server, and
 		// retention; the desktop exporter only writes and finds the store
 		// through the extension at startup.
-		`yaml:extensions::duckdb::endpoint: "` + endpoint(o.browserPort) + `"`,
-		`yaml:extensions::duckdb::db: ` + o.db,
-		`yaml:extensions::duckdb::db_max_size: "` + o.dbMaxSize + `"`,
+		f.browser,
+		f.db,
+		f.dbMaxSize,
+		f.watchDir,
 		`yaml:service::extensions: [duckdb]`,
 		// Batching happens here rather than in the exporter's sending queue.
 		// send_batch_max_size bounds a merged batch, which is what makes the
@@ -144,7 +177,113 @@ func collectorURIs(o configOptions) []string {
 		`yaml:service::pipelines::logs::exporters: [desktop]`,
 	}
 
-	return append(uris, telemetryURIs(o, endpoint(o.grpcPort))...)
+	uris = append(uris, f.telemetry...)
+	if len(o.configFiles) == 0 {
+		return uris
+	}
+
+	for _, path := range o.configFiles {
+		uris = append(uris, configFileURI(path))
+	}
+
+	// --host is part of every endpoint, so setting it re-applies all three:
+	// "listen on 0.0.0.0" has to hold even where a file picked the port.
+	set := func(names ...string) bool {
+		for _, n := range names {
+			if o.explicit[n] {
+				return true
+			}
+		}
+		return false
+	}
+	if set("http", "host") {
+		uris = append(uris, f.http)
+	}
+	if set("grpc", "host") {
+		uris = append(uris, f.grpc)
+	}
+	if set("browser-port", "host") {
+		uris = append(uris, f.browser)
+	}
+	if set("db") {
+		uris = append(uris, f.db)
+	}
+	if set("db-max-size") {
+		uris = append(uris, f.dbMaxSize)
+	}
+	if set("watch-dir") {
+		uris = append(uris, f.watchDir)
+	}
+	if set("telemetry") {
+		uris = append(uris, f.telemetry...)
+	}
+	return uris
+}
+
+// flagFragments are the config fragments that carry a flag's value, kept
+// apart from the static ones so collectorURIs can lay them down twice: once
+// under the config files as defaults, and again over them for the flags that
+// were set explicitly.
+type flagFragments struct {
+	http, grpc, browser, db, dbMaxSize, watchDir string
+	telemetry                                    []string
+}
+
+func flagURIs(o configOptions) flagFragments {
+	endpoint := formatEndpoint(o.host)
+	return flagFragments{
+		http:      `yaml:receivers::otlp::protocols::http::endpoint: "` + endpoint(o.httpPort) + `"`,
+		grpc:      `yaml:receivers::otlp::protocols::grpc::endpoint: "` + endpoint(o.grpcPort) + `"`,
+		browser:   `yaml:extensions::duckdb::endpoint: "` + endpoint(o.browserPort) + `"`,
+		db:        `yaml:extensions::duckdb::db: ` + o.db,
+		dbMaxSize: `yaml:extensions::duckdb::db_max_size: "` + o.dbMaxSize + `"`,
+		watchDir:  `yaml:extensions::duckdb::watch_dir: ` + o.watchDir,
+		telemetry: telemetryURIs(o, endpoint(o.grpcPort)),
+	}
+}
+
+// configFileURI turns a --config argument into a resolver URI. A bare path is
+// read through the file provider; anything already carrying a scheme the
+// binary knows (file:, yaml:, env:) is passed through, so an inline
+// `--config 'yaml:processors::batch::timeout: 5s'` works the same way it does
+// for the upstream collector.
+func configFileURI(arg string) string {
+	for _, scheme := range []string{"file:", "yaml:", "env:"} {
+		if strings.HasPrefix(arg, scheme) {
+			return arg
+		}
+	}
+	return "file:" + arg
+}
+
+// printConfig resolves the URIs the collector would have been started with and
+// writes the merged result to w as YAML, without starting anything.
+//
+// This is the resolved confmap, not the unmarshalled component configs, so it
+// shows exactly what was written and merged -- component defaults the files
+// left unset do not appear. ${env:...} references are expanded, which means a
+// secret pulled from the environment is printed in the clear.
+func printConfig(ctx context.Context, w io.Writer, settings confmap.ResolverSettings) (err error) {
+	resolver, err := confmap.NewResolver(settings)
+	if err != nil {
+		return fmt.Errorf("print-config: %w", err)
+	}
+	defer func() {
+		if shutdownErr := resolver.Shutdown(ctx); shutdownErr != nil && err == nil {
+			err = fmt.Errorf("print-config: %w", shutdownErr)
+		}
+	}()
+
+	conf, err := resolver.Resolve(ctx)
+	if err != nil {
+		return fmt.Errorf("print-config: %w", err)
+	}
+	out, err := yaml.Marshal(conf.ToStringMap())
+	if err != nil {
+		return fmt.Errorf("print-config: %w", err)
+	}
+	_, err = w.Write(out)
+	return err
 }
 
 // telemetryURIs composes the service::telemetry block and the exporter's own
@@ -196,8 +335,9 @@ service:
 
 func newCommand(set otelcol.CollectorSettings) *cobra.Command {
 	var httpPortFlag, grpcPortFlag, browserPortFlag int
-	var hostFlag, dbFlag, dbMaxSizeFlag string
-	var openBrowserFlag, telemetryFlag bool
+	var hostFlag, dbFlag, dbMaxSizeFlag, watchDirFlag, openFlag string
+	var openBrowserFlag, telemetryFlag, printConfigFlag bool
+	var configFlags []string
 
 	rootCmd := &cobra.Command{
 		Use:     set.BuildInfo.Command,
@@ -216,6 +356,18 @@ func newCommand(set otelcol.CollectorSettings) *cobra.Command {
 			// already carried it.
 			cmd.SilenceUsage = true
 			cmd.SilenceErrors = true
+			if openFlag != "" {
+				return runOpen(cmd.Context(), openOptions{
+					path:        openFlag,
+					host:        hostFlag,
+					browserPort: browserPortFlag,
+					openBrowser: openBrowserFlag,
+				}, set.BuildInfo)
+			}
+			explicit := map[string]bool{}
+			for _, name := range []string{"http", "grpc", "browser-port", "host", "db", "db-max-size", "watch-dir", "telemetry"} {
+				explicit[name] = cmd.Flags().Changed(name)
+			}
 			set.ConfigProviderSettings.ResolverSettings.URIs = collectorURIs(configOptions{
 				host:          hostFlag,
 				httpPort:      httpPortFlag,
@@ -223,17 +375,19 @@ func newCommand(set otelcol.CollectorSettings) *cobra.Command {
 				browserPort:   browserPortFlag,
 				db:            dbFlag,
 				dbMaxSize:     dbMaxSizeFlag,
+				watchDir:      watchDirFlag,
 				selfTelemetry: telemetryFlag,
+				configFiles:   configFlags,
+				explicit:      explicit,
 			})
 			set.ConfigProviderSettings.ResolverSettings.DefaultScheme = "env"
 
+			if printConfigFlag {
+				return printConfig(cmd.Context(), cmd.OutOrStdout(), set.ConfigProviderSettings.ResolverSettings)
+			}
+
 			if openBrowserFlag {
-				go func() {
-					// Wait a bit for the server to come up to avoid a 404 as a first experience
-					time.Sleep(300 * time.Millisecond)
-					browserHost := browserHostFor(hostFlag)
-					browser.OpenURL("http://" + browserHost + ":" + strconv.Itoa(browserPortFlag) + "/")
-				}()
+				openBrowserSoon(hostFlag, browserPortFlag)
 			}
 
 			col, err := otelcol.NewCollector(set)
@@ -251,11 +405,37 @@ func newCommand(set otelcol.CollectorSettings) *cobra.Command {
 	rootCmd.Flags().StringVar(&hostFlag, "host", "localhost", "The host where we expose our all endpoints (OTLP receivers and browser). Use '::' or '0.0.0.0' to listen on all interfaces.")
 	rootCmd.Flags().StringVar(&dbFlag, "db", "", "The path of your database file. Omitting this flag opens DuckDB in in-memory mode, with no data persisted to disk.")
 	rootCmd.Flags().BoolVar(&telemetryFlag, "telemetry", false, "Emit the viewer's own traces and metrics to its own OTLP receiver, so it can be observed in its own UI.")
+	rootCmd.Flags().StringArrayVar(&configFlags, "config", nil, "Path to a collector YAML config file merged over the flag-built config. Repeatable; later files win over earlier ones, and flags set explicitly win over every file.")
+	rootCmd.Flags().BoolVar(&printConfigFlag, "print-config", false, "Print the resolved collector config as YAML and exit without starting the collector.")
 	rootCmd.Flags().StringVar(&dbMaxSizeFlag, "db-max-size", "", "Maximum size of the telemetry store (e.g. 512MB, 2GB). The oldest telemetry is pruned once the limit is reached. Use 0 to disable pruning. Defaults to 512MB in in-memory mode and 2GB with a database file.")
 
+	rootCmd.Flags().StringVar(&watchDirFlag, "watch-dir", "", "A directory of OTLP JSON-lines files, as the collector's file exporter writes them, to tail into the viewer alongside what arrives over OTLP. Rotated files are followed, and with --db a restart resumes where it stopped.")
+
+	rootCmd.Flags().StringVar(&openFlag, "open", "", "Serve a bundle written by `export` (the zip, or the directory it unzips to) or an existing database file, read-only, with no OTLP receivers. Clearing and deleting are disabled.")
+	// --open starts no collector, so every flag that only shapes the collector
+	// config would be silently ignored beside it. Refusing the pair says so.
+	for _, name := range []string{"db", "db-max-size", "watch-dir", "config", "print-config", "http", "grpc", "telemetry"} {
+		rootCmd.MarkFlagsMutuallyExclusive("open", name)
+	}
+
+	rootCmd.AddCommand(newExportCommand())
+	rootCmd.AddCommand(newForwardCommand())
+	rootCmd.AddCommand(newReplayCommand(set.BuildInfo))
+
 	return rootCmd
 }
 
+// openBrowserSoon opens the viewer in the default browser once its server has
+// had a moment to come up.
+func openBrowserSoon(host string, port int) {
+	go func() {
+		// Wait a bit for the server to come up to avoid a 404 as a first experience
+		time.Sleep(300 * time.Millisecond)
+		browserHost := browserHostFor(host)
+		browser.OpenURL("http://" + browserHost + ":" + strconv.Itoa(port) + "/")
+	}()
+}
+
 // formatEndpoint returns a function that produces a properly formatted
 // host:port string for the given host. IPv6 addresses are wrapped in
 // brackets so that net.Dial / net.Listen can parse them correctly.
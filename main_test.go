@@ -12,9 +12,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/confmap"
-	envprovider "go.opentelemetry.io/collector/confmap/provider/envprovider"
-	yamlprovider "go.opentelemetry.io/collector/confmap/provider/yamlprovider"
 	"go.opentelemetry.io/collector/otelcol"
+	"go.yaml.in/yaml/v3"
 )
 
 func testOptions() configOptions {
@@ -35,12 +34,9 @@ func resolveConfig(t *testing.T, o configOptions) (*otelcol.Config, error) {
 
 	provider, err := otelcol.NewConfigProvider(otelcol.ConfigProviderSettings{
 		ResolverSettings: confmap.ResolverSettings{
-			URIs: collectorURIs(o),
-			ProviderFactories: []confmap.ProviderFactory{
-				envprovider.NewFactory(),
-				yamlprovider.NewFactory(),
-			},
-			DefaultScheme: "env",
+			URIs:              collectorURIs(o),
+			ProviderFactories: providerFactories(),
+			DefaultScheme:     "env",
 		},
 	})
 	require.NoError(t, err)
@@ -51,6 +47,31 @@ func resolveConfig(t *testing.T, o configOptions) (*otelcol.Config, error) {
 	return provider.Get(context.Background(), factories)
 }
 
+// resolveConf merges the composed URIs into the raw confmap, before any
+// component config is unmarshalled -- the layer precedence is decided here.
+func resolveConf(t *testing.T, o configOptions) *confmap.Conf {
+	t.Helper()
+
+	resolver, err := confmap.NewResolver(confmap.ResolverSettings{
+		URIs:              collectorURIs(o),
+		ProviderFactories: providerFactories(),
+		DefaultScheme:     "env",
+	})
+	require.NoError(t, err)
+
+	conf, err := resolver.Resolve(context.Background())
+	require.NoError(t, err)
+	return conf
+}
+
+// writeConfig drops a --config file into a temp dir and returns its path.
+func writeConfig(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+	return path
+}
+
 // validateServiceTelemetry unmarshals and validates the service::telemetry
 // block specifically.
 //
@@ -70,20 +91,7 @@ func resolveConfig(t *testing.T, o configOptions) (*otelcol.Config, error) {
 func validateServiceTelemetry(t *testing.T, o configOptions) error {
 	t.Helper()
 
-	resolver, err := confmap.NewResolver(confmap.ResolverSettings{
-		URIs: collectorURIs(o),
-		ProviderFactories: []confmap.ProviderFactory{
-			envprovider.NewFactory(),
-			yamlprovider.NewFactory(),
-		},
-		DefaultScheme: "env",
-	})
-	require.NoError(t, err)
-
-	conf, err := resolver.Resolve(context.Background())
-	require.NoError(t, err)
-
-	sub, err := conf.Sub("service::telemetry")
+	sub, err := resolveConf(t, o).Sub("service::telemetry")
 	require.NoError(t, err)
 
 	factories, err := components()
@@ -190,6 +198,141 @@ func TestSelfTelemetryFollowsGRPCEndpoint(t *testing.T) {
 	assert.Contains(t, joined, "http://127.0.0.1:15317")
 }
 
+// --watch-dir is the extension's watch_dir, and leaving it off watches
+// nothing rather than the working directory.
+func TestWatchDirFlag(t *testing.T) {
+	conf := resolveConf(t, testOptions())
+	assert.Empty(t, conf.Get("extensions::duckdb::watch_dir"))
+
+	o := testOptions()
+	o.watchDir = "/var/otlp"
+	assert.Equal(t, "/var/otlp", resolveConf(t, o).Get("extensions::duckdb::watch_dir"))
+	cfg, err := resolveConfig(t, o)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+}
+
+// TestConfigFilePrecedence pins the three layers: flag defaults, then each
+// --config file in order, then the flags that were set explicitly.
+func TestConfigFilePrecedence(t *testing.T) {
+	base := writeConfig(t, "base.yaml", `
+extensions:
+  duckdb:
+    endpoint: "localhost:9000"
+    db_max_size: 1GB
+processors:
+  batch:
+    timeout: 5s
+`)
+	override := writeConfig(t, "override.yaml", `
+processors:
+  batch:
+    timeout: 250ms
+`)
+
+	t.Run("a file wins over flag defaults", func(t *testing.T) {
+		o := testOptions()
+		o.configFiles = []string{base}
+		conf := resolveConf(t, o)
+
+		assert.Equal(t, "localhost:9000", conf.Get("extensions::duckdb::endpoint"))
+		assert.Equal(t, "5s", conf.Get("processors::batch::timeout"))
+		// Keys the file does not mention keep the flag-built value: maps
+		// merge, they are not replaced.
+		assert.Equal(t, 8192, conf.Get("processors::batch::send_batch_size"))
+		assert.Equal(t, "localhost:4318", conf.Get("receivers::otlp::protocols::http::endpoint"))
+	})
+
+	t.Run("a later file wins over an earlier one", func(t *testing.T) {
+		o := testOptions()
+		o.configFiles = []string{base, override}
+		conf := resolveConf(t, o)
+
+		assert.Equal(t, "250ms", conf.Get("processors::batch::timeout"))
+		assert.Equal(t, "localhost:9000", conf.Get("extensions::duckdb::endpoint"))
+	})
+
+	t.Run("an explicit flag wins over every file", func(t *testing.T) {
+		o := testOptions()
+		o.browserPort = 8123
+		o.dbMaxSize = "64MB"
+		o.configFiles = []string{base, override}
+		o.explicit = map[string]bool{"browser-port": true, "db-max-size": true}
+		conf := resolveConf(t, o)
+
+		assert.Equal(t, "localhost:8123", conf.Get("extensions::duckdb::endpoint"))
+		assert.Equal(t, "64MB", conf.Get("extensions::duckdb::db_max_size"))
+	})
+
+	// --host is in every endpoint, so setting it has to re-apply the ports a
+	// file picked too -- otherwise "listen on all interfaces" would quietly
+	// not apply to the one endpoint the file moved.
+	t.Run("an explicit host re-applies every endpoint", func(t *testing.T) {
+		o := testOptions()
+		o.host = "0.0.0.0"
+		o.configFiles = []string{base}
+		o.explicit = map[string]bool{"host": true}
+		conf := resolveConf(t, o)
+
+		assert.Equal(t, "0.0.0.0:8000", conf.Get("extensions::duckdb::endpoint"))
+		assert.Equal(t, "0.0.0.0:4317", conf.Get("receivers::otlp::protocols::grpc::endpoint"))
+	})
+
+	t.Run("inline yaml is accepted as a --config value", func(t *testing.T) {
+		o := testOptions()
+		o.configFiles = []string{"yaml:processors::batch::timeout: 2s"}
+		assert.Equal(t, "2s", resolveConf(t, o).Get("processors::batch::timeout"))
+	})
+}
+
+// A file has to be able to tune what the flags do not reach -- here the
+// exporter's sending queue -- and still produce a config the collector accepts.
+func TestConfigFileTunesExporter(t *testing.T) {
+	o := testOptions()
+	o.configFiles = []string{writeConfig(t, "queue.yaml", `
+exporters:
+  desktop:
+    sending_queue:
+      queue_size: 50
+`)}
+	cfg, err := resolveConfig(t, o)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+}
+
+// --print-config must print the merged result and return without starting
+// anything: no listener, no store, no browser.
+func TestPrintConfig(t *testing.T) {
+	set := otelcol.CollectorSettings{
+		BuildInfo: component.BuildInfo{Command: "otel-desktop-viewer", Version: "test"},
+		Factories: components,
+		ConfigProviderSettings: otelcol.ConfigProviderSettings{
+			ResolverSettings: confmap.ResolverSettings{
+				ProviderFactories: providerFactories(),
+			},
+		},
+	}
+	path := writeConfig(t, "print.yaml", `
+processors:
+  batch:
+    timeout: 5s
+`)
+
+	cmd := newCommand(set)
+	cmd.SetArgs([]string{"--config", path, "--browser-port", "8123", "--print-config"})
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	require.NoError(t, cmd.Execute())
+
+	var printed map[string]any
+	require.NoError(t, yaml.Unmarshal(out.Bytes(), &printed))
+	conf := confmap.NewFromStringMap(printed)
+	assert.Equal(t, "5s", conf.Get("processors::batch::timeout"))
+	assert.Equal(t, "localhost:8123", conf.Get("extensions::duckdb::endpoint"))
+	assert.Equal(t, []any{"duckdb"}, conf.Get("service::extensions"))
+}
+
 // TestStartupFailureIsNotAnsweredWithUsage covers the difference between "you
 // typed the command wrong" and "the collector could not start".
 //
@@ -208,10 +351,7 @@ func TestStartupFailureIsNotAnsweredWithUsage(t *testing.T) {
 		Factories: components,
 		ConfigProviderSettings: otelcol.ConfigProviderSettings{
 			ResolverSettings: confmap.ResolverSettings{
-				ProviderFactories: []confmap.ProviderFactory{
-					envprovider.NewFactory(),
-					yamlprovider.NewFactory(),
-				},
+				ProviderFactories: providerFactories(),
 			},
 		},
 	}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	duckdbextension "github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/duckdbextension"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/extension"
+	noopmetric "go.opentelemetry.io/otel/metric/noop"
+	nooptrace "go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// openOptions are the flags --open honours: where the capture is, and where to
+// serve it.
+type openOptions struct {
+	path        string
+	host        string
+	browserPort int
+	openBrowser bool
+}
+
+// runOpen serves a bundle or database file read-only until ctx is done or the
+// process is interrupted.
+//
+// It runs the duckdb extension on its own rather than through otelcol, because
+// there is nothing for a collector to do: no receivers, since nothing should
+// arrive, and so no pipelines -- and a collector refuses to start without at
+// least one. The extension is built through its factory with the same config
+// a collector would hand it, so the viewer served here is the one a normal run
+// serves, store opened with ReadOnly aside.
+func runOpen(ctx context.Context, o openOptions, info component.BuildInfo) (err error) {
+	logger, err := openLogger()
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer logger.Sync()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ext, cfg, err := startViewer(ctx, logger, info, o.host, o.browserPort, func(cfg *duckdbextension.Config) {
+		cfg.Db = o.path
+		cfg.ReadOnly = true
+	})
+	if err != nil {
+		return fmt.Errorf("open: %s: %w", o.path, err)
+	}
+	defer func() {
+		err = errors.Join(err, ext.Shutdown(context.Background()))
+	}()
+
+	logger.Info("Serving a read-only capture; clearing and deleting are disabled",
+		zap.String("source", o.path),
+		zap.String("endpoint", cfg.Endpoint))
+	if o.openBrowser {
+		openBrowserSoon(o.host, o.browserPort)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// startViewer builds the duckdb extension through its factory, with the
+// config a collector would hand it adjusted by configure, and starts it on
+// its own. The caller shuts it down.
+func startViewer(ctx context.Context, logger *zap.Logger, info component.BuildInfo, host string, port int,
+	configure func(*duckdbextension.Config)) (extension.Extension, *duckdbextension.Config, error) {
+	factory := duckdbextension.NewFactory()
+	cfg := factory.CreateDefaultConfig().(*duckdbextension.Config)
+	cfg.Endpoint = formatEndpoint(host)(port)
+	configure(cfg)
+
+	ext, err := factory.Create(ctx, extension.Settings{
+		ID: component.NewID(duckdbextension.Type),
+		TelemetrySettings: component.TelemetrySettings{
+			Logger:         logger,
+			TracerProvider: nooptrace.NewTracerProvider(),
+			MeterProvider:  noopmetric.NewMeterProvider(),
+		},
+		BuildInfo: info,
+	}, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ext.Start(ctx, openHost{}); err != nil {
+		return nil, nil, err
+	}
+	return ext, cfg, nil
+}
+
+// openLogger logs the way the collector's default does -- console encoding,
+// info and up -- so --open reads like a normal run.
+func openLogger() (*zap.Logger, error) {
+	cfg := zap.NewProductionConfig()
+	cfg.Encoding = "console"
+	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	return cfg.Build()
+}
+
+// openHost is the component.Host the extension is started with. It has no
+// extensions to offer, and the duckdb extension asks for none.
+type openHost struct{}
+
+func (openHost) GetExtensions() map[component.ID]component.Component {
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	duckdbextension "github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/duckdbextension"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/extension/extensiontest"
+	"go.opentelemetry.io/collector/otelcol"
+)
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// writeDatabase creates a database file the way a `--db` run would leave it.
+func writeDatabase(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "capture.db")
+	factory := duckdbextension.NewFactory()
+	cfg := factory.CreateDefaultConfig().(*duckdbextension.Config)
+	cfg.Endpoint = "localhost:" + strconv.Itoa(freePort(t))
+	cfg.Db = path
+	ext, err := factory.Create(context.Background(), extensiontest.NewNopSettings(duckdbextension.Type), cfg)
+	require.NoError(t, err)
+	require.NoError(t, ext.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, ext.Shutdown(context.Background()))
+	return path
+}
+
+func TestOpenServesReadOnly(t *testing.T) {
+	path := writeDatabase(t)
+	port := freePort(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- runOpen(ctx, openOptions{path: path, host: "localhost", browserPort: port}, component.BuildInfo{})
+	}()
+
+	url := "http://localhost:" + strconv.Itoa(port) + "/rpc"
+	call := func(method string) map[string]any {
+		t.Helper()
+		res, err := http.Post(url, "application/json",
+			bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"`+method+`"}`))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var out map[string]any
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&out))
+		return out
+	}
+	require.Eventually(t, func() bool {
+		res, err := http.Get("http://localhost:" + strconv.Itoa(port) + "/token")
+		if err == nil {
+			res.Body.Close()
+		}
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond, "the viewer never came up")
+
+	assert.Nil(t, call("getStats")["error"])
+	e, ok := call("clearTraces")["error"].(map[string]any)
+	require.True(t, ok)
+	assert.EqualValues(t, -32012, e["code"])
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("runOpen did not return after its context ended")
+	}
+}
+
+func TestOpenReportsWhatItCannotRead(t *testing.T) {
+	err := runOpen(context.Background(), openOptions{
+		path:        filepath.Join(t.TempDir(), "missing.zip"),
+		host:        "localhost",
+		browserPort: freePort(t),
+	}, component.BuildInfo{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing.zip")
+}
+
+// --open starts no collector, so a collector flag beside it would do nothing.
+func TestOpenRefusesCollectorFlags(t *testing.T) {
+	cmd := newCommand(otelcol.CollectorSettings{BuildInfo: component.BuildInfo{Command: "otel-desktop-viewer"}})
+	cmd.SetArgs([]string{"--open", "capture.zip", "--db", "other.db"})
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "open")
+	assert.Contains(t, err.Error(), "db")
+}
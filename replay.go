@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	duckdbextension "github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/duckdbextension"
+	"github.com/CtrlSpice/otel-desktop-viewer/desktopexporter/replay"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+)
+
+// replayOptions are the replay subcommand's arguments and flags, resolved.
+type replayOptions struct {
+	files       []string // "-" is stdin
+	db          string
+	rebaseTime  bool
+	host        string
+	browserPort int
+	openBrowser bool
+}
+
+// newReplayCommand builds `otel-desktop-viewer replay`, which loads saved OTLP
+// into a store and serves the viewer over it.
+//
+// Like --open it runs the duckdb extension without a collector: the telemetry
+// is already here, so there is nothing to receive. Unlike --open the store is
+// an ordinary writable one -- in memory unless --db says otherwise -- because
+// replay has to write into it, and a --db file it leaves behind is one a
+// normal run can pick up.
+func newReplayCommand(info component.BuildInfo) *cobra.Command {
+	var o replayOptions
+	cmd := &cobra.Command{
+		Use:   "replay <file>... | -",
+		Short: "Load OTLP JSON or protobuf files into the viewer",
+		Long: "Load saved OTLP -- the collector file exporter's JSON lines or protobuf output, or raw " +
+			"protobuf export requests -- into a store and serve the viewer over it. Zipkin v2 JSON span " +
+			"lists and Jaeger UI JSON downloads are converted to OTLP on the way in. Pass - to read stdin. " +
+			"Files may be gzipped.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			o.files = args
+			return runReplay(cmd.Context(), o, cmd.InOrStdin(), info)
+		},
+	}
+	cmd.Flags().StringVar(&o.db, "db", "", "Database file to load into. Omitting it loads into memory.")
+	cmd.Flags().BoolVar(&o.rebaseTime, "rebase-time", false, "Shift every timestamp by the same amount so the capture ends now.")
+	cmd.Flags().StringVar(&o.host, "host", "localhost", "The host the viewer listens on.")
+	cmd.Flags().IntVar(&o.browserPort, "browser-port", 8000, "The port the viewer listens on.")
+	cmd.Flags().BoolVar(&o.openBrowser, "open-browser", true, "Open the browser once the capture is loaded.")
+	return cmd
+}
+
+// runReplay reads every file, then starts the viewer, loads what was read and
+// serves it until ctx is done or the process is interrupted.
+//
+// Everything is decoded before anything starts, so a bad file fails the
+// command with nothing half-loaded -- and so --rebase-time can find the
+// capture's last moment across all of it, rather than shifting each file by
+// its own.
+func runReplay(ctx context.Context, o replayOptions, stdin io.Reader, info component.BuildInfo) (err error) {
+	var capture replay.Capture
+	for _, name := range o.files {
+		if err := readReplayFile(&capture, name, stdin); err != nil {
+			return fmt.Errorf("replay: %w", err)
+		}
+	}
+	spanCount, dataPointCount, logCount := capture.Counts()
+	if spanCount+dataPointCount+logCount == 0 {
+		return errors.New("replay: the files hold no spans, datapoints or logs")
+	}
+	var shift time.Duration
+	if o.rebaseTime {
+		shift = capture.Rebase(time.Now())
+	}
+
+	logger, err := openLogger()
+	if err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	defer logger.Sync()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ext, cfg, err := startViewer(ctx, logger, info, o.host, o.browserPort, func(cfg *duckdbextension.Config) {
+		cfg.Db = o.db
+	})
+	if err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	defer func() {
+		err = errors.Join(err, ext.Shutdown(context.Background()))
+	}()
+
+	if err := capture.Ingest(ctx, ext); err != nil {
+		return err
+	}
+	logger.Info("Replayed capture",
+		zap.Int("spans", spanCount),
+		zap.Int("datapoints", dataPointCount),
+		zap.Int("logs", logCount),
+		zap.Duration("shift", shift),
+		zap.String("endpoint", cfg.Endpoint))
+	if o.openBrowser {
+		openBrowserSoon(o.host, o.browserPort)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+func readReplayFile(capture *replay.Capture, name string, stdin io.Reader) error {
+	if name == "-" {
+		return capture.Read("stdin", stdin)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return capture.Read(name, f)
+}
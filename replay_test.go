@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// captureLines is what the collector file exporter writes for one span and
+// one log: a JSON export request per line.
+func captureLines(t *testing.T) []byte {
+	t.Helper()
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	span.SetSpanID(pcommon.SpanID{1, 2, 3, 4, 5, 6, 7, 8})
+	span.SetName("ci step")
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(time.Date(2026, 3, 1, 12, 0, 1, 0, time.UTC)))
+	traces, err := (&ptrace.JSONMarshaler{}).MarshalTraces(td)
+	require.NoError(t, err)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.SetTimestamp(pcommon.NewTimestampFromTime(time.Date(2026, 3, 1, 12, 0, 1, 0, time.UTC)))
+	lr.Body().SetStr("step failed")
+	logs, err := (&plog.JSONMarshaler{}).MarshalLogs(ld)
+	require.NoError(t, err)
+
+	return append(append(traces, '\n'), logs...)
+}
+
+func TestReplayServesTheCapture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	require.NoError(t, os.WriteFile(path, captureLines(t), 0o600))
+
+	for name, files := range map[string][]string{
+		"file":  {path},
+		"stdin": {"-"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			port := freePort(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() {
+				done <- runReplay(ctx, replayOptions{
+					files:       files,
+					rebaseTime:  true,
+					host:        "localhost",
+					browserPort: port,
+				}, bytes.NewReader(captureLines(t)), component.BuildInfo{})
+			}()
+
+			type stats struct {
+				Traces struct{ SpanCount int }
+				Logs   struct{ LogCount int }
+			}
+			var got stats
+			require.Eventually(t, func() bool {
+				res, err := http.Post("http://localhost:"+strconv.Itoa(port)+"/rpc", "application/json",
+					strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"getStats"}`))
+				if err != nil {
+					return false
+				}
+				defer res.Body.Close()
+				var out struct{ Result stats }
+				if json.NewDecoder(res.Body).Decode(&out) != nil {
+					return false
+				}
+				got = out.Result
+				return got.Traces.SpanCount > 0 && got.Logs.LogCount > 0
+			}, 5*time.Second, 20*time.Millisecond, "the capture never showed up")
+			assert.Equal(t, 1, got.Traces.SpanCount)
+			assert.Equal(t, 1, got.Logs.LogCount)
+
+			cancel()
+			select {
+			case err := <-done:
+				assert.NoError(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("runReplay did not return after its context ended")
+			}
+		})
+	}
+}
+
+// Nothing is started for a capture that cannot be loaded, so these fail
+// straight away rather than serving an empty viewer.
+func TestReplayRefusesWhatItCannotLoad(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.json")
+	require.NoError(t, os.WriteFile(empty, []byte(`{"resourceSpans":[]}`), 0o600))
+	notOTLP := filepath.Join(dir, "build.log")
+	require.NoError(t, os.WriteFile(notOTLP, []byte("npm ERR! code 1\n"), 0o600))
+
+	for name, c := range map[string]struct {
+		file string
+		want string
+	}{
+		"missing":  {filepath.Join(dir, "missing.json"), "missing.json"},
+		"empty":    {empty, "no spans, datapoints or logs"},
+		"not otlp": {notOTLP, "build.log"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := runReplay(context.Background(), replayOptions{
+				files:       []string{c.file},
+				host:        "localhost",
+				browserPort: freePort(t),
+			}, strings.NewReader(""), component.BuildInfo{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.want)
+		})
+	}
+}